@@ -2,20 +2,64 @@ package gomigration
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
 	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type CliConfig struct {
 	GoMigration *GoMigration
 	CliName     string
+
+	// UsageReporter optionally receives anonymous usage and timing data for
+	// every command run. Nothing is reported unless a reporter is provided.
+	UsageReporter UsageReporter
+
+	// Locale selects the language of CLI messages, e.g. "en" or "id". When
+	// empty it is read from GOMIGRATION_LOCALE, LC_ALL, LC_MESSAGES or LANG.
+	// Unknown locales and untranslated messages fall back to English.
+	Locale string
+
+	// Catalogs adds or overrides message translations, keyed by locale.
+	Catalogs map[string]Catalog
+
+	// Renderer formats the output of every command. Defaults to TableRenderer.
+	// The --output flag overrides it with a built-in renderer per invocation.
+	Renderer Renderer
 }
 
 type Cli struct {
-	migration *GoMigration
-	cliName   string
+	migration     *GoMigration
+	cliName       string
+	usageReporter UsageReporter
+	messages      messageCatalog
+	renderer      Renderer
+}
+
+// CommandUsage describes a single CLI command invocation. Only flag names are
+// recorded, never their values, so no arguments or credentials leak.
+type CommandUsage struct {
+	Command   string
+	Flags     []string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// UsageReporter receives a CommandUsage after each CLI command finishes.
+// Implementations decide where the data goes; the CLI makes no network calls.
+type UsageReporter interface {
+	ReportCommand(ctx context.Context, usage CommandUsage)
 }
 
 func NewCli(config CliConfig) (*Cli, error) {
@@ -25,34 +69,66 @@ func NewCli(config CliConfig) (*Cli, error) {
 	if config.CliName == "" {
 		config.CliName = "migration"
 	}
+	if config.Renderer == nil {
+		config.Renderer = TableRenderer{}
+	}
 
 	return &Cli{
-		migration: config.GoMigration,
-		cliName:   config.CliName,
+		migration:     config.GoMigration,
+		cliName:       config.CliName,
+		usageReporter: config.UsageReporter,
+		messages:      newMessageCatalog(config.Locale, config.Catalogs),
+		renderer:      config.Renderer,
 	}, nil
 }
 
 func (c *Cli) ListCommand(ctx context.Context) *cobra.Command {
 	var listCmd = &cobra.Command{
-		Use:   "list",
-		Short: "List all migrations",
+		Use: "list",
 		Run: func(cmd *cobra.Command, args []string) {
 			list, err := c.migration.List(ctx)
 			if err != nil {
-				log.Println("Error listing migrations:", err)
+				c.fail(cmd, MsgListError, err)
+				return
+			}
+			if err := c.rendererFor(cmd).List(cmd.OutOrStdout(), list); err != nil {
+				c.fail(cmd, MsgRenderError, err)
+			}
+		},
+	}
+
+	return c.instrument(ctx, listCmd)
+}
+
+func (c *Cli) StatusCommand(ctx context.Context) *cobra.Command {
+	var statusCmd = &cobra.Command{
+		Use:  "status",
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := c.migration.Status(ctx)
+			if err != nil {
+				c.fail(cmd, MsgStatusError, err)
 				return
 			}
-			list.Print()
+
+			renderer := c.rendererFor(cmd)
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				renderer = JSONRenderer{}
+			}
+			if err := renderer.Status(cmd.OutOrStdout(), status); err != nil {
+				c.fail(cmd, MsgStatusWriteError, err)
+			}
 		},
 	}
 
-	return listCmd
+	statusCmd.Flags().Bool("json", false, c.msg(MsgStatusFlagJSON))
+
+	return c.instrument(ctx, statusCmd)
 }
 
 func (c *Cli) MigrateCommand(ctx context.Context) *cobra.Command {
 	var migrateCmd = &cobra.Command{
-		Use:   "migrate",
-		Short: "Run all pending migrations",
+		Use: "migrate",
 		Run: func(cmd *cobra.Command, args []string) {
 			fresh := false
 			var err error
@@ -60,119 +136,707 @@ func (c *Cli) MigrateCommand(ctx context.Context) *cobra.Command {
 			if freshFlag != nil && freshFlag.Changed {
 				fresh, err = strconv.ParseBool(freshFlag.Value.String())
 				if err != nil {
-					log.Println("Invalid fresh flag:", err)
+					c.fail(cmd, MsgMigrateInvalidFresh, err)
+					return
+				}
+			}
+			if cmd.Flags().Changed("step") {
+				step, _ := cmd.Flags().GetInt("step")
+				err = c.migration.MigrateSteps(ctx, step)
+				if err != nil {
+					c.fail(cmd, MsgMigrateError, err)
+				}
+				return
+			}
+			if to, _ := cmd.Flags().GetString("to"); to != "" {
+				err = c.migration.MigrateTo(ctx, to)
+				if err != nil {
+					c.fail(cmd, MsgMigrateError, err)
+				}
+				return
+			}
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			if dryRun {
+				if fresh {
+					c.fail(cmd, MsgMigrateDryRunWithFresh, nil)
 					return
 				}
+				err = c.migration.MigrateDryRun(ctx)
+				if err != nil {
+					c.fail(cmd, MsgMigrateDryRunError, err)
+				}
+				return
 			}
 			if fresh {
-				err = c.migration.Fresh(ctx)
+				keepHistory, _ := cmd.Flags().GetBool("keep-history")
+				err = c.migration.FreshWithOptions(ctx, CleanOptions{KeepHistory: keepHistory})
 				if err != nil {
-					log.Println("Error running fresh migrations:", err)
+					c.fail(cmd, MsgMigrateFreshError, err)
 					return
 				}
 			} else {
-				err = c.migration.Migrate(ctx)
+				var opts []MigrateOption
+				if phase, _ := cmd.Flags().GetString("phase"); phase != "" {
+					opts = append(opts, WithPhase(Phase(phase)))
+				}
+				if tags, _ := cmd.Flags().GetStringSlice("tags"); len(tags) > 0 {
+					opts = append(opts, WithTagFilter(tags...))
+				}
+				tenant, _ := cmd.Flags().GetString("tenant")
+				allTenants, _ := cmd.Flags().GetBool("all-tenants")
+				switch {
+				case tenant != "":
+					err = c.migration.MigrateTenant(ctx, tenant, opts...)
+				case allTenants:
+					err = c.migration.MigrateAllTenants(ctx, opts...)
+				default:
+					err = c.migration.Migrate(ctx, opts...)
+				}
+				if err != nil {
+					c.fail(cmd, MsgMigrateError, err)
+					return
+				}
+			}
+			if seed, _ := cmd.Flags().GetBool("seed"); seed {
+				if err := c.migration.Seed(ctx, SeedOptions{}); err != nil {
+					c.fail(cmd, MsgSeedError, err)
+				}
+			}
+		},
+	}
+
+	migrateCmd.Flags().BoolP("fresh", "f", false, c.msg(MsgMigrateFlagFresh))
+	migrateCmd.Flags().Bool("dry-run", false, c.msg(MsgMigrateFlagDryRun))
+	migrateCmd.Flags().IntP("step", "s", 0, c.msg(MsgMigrateFlagStep))
+	migrateCmd.Flags().String("to", "", c.msg(MsgMigrateFlagTo))
+	migrateCmd.Flags().Bool("seed", false, c.msg(MsgMigrateFlagSeed))
+	migrateCmd.Flags().String("phase", "", c.msg(MsgMigrateFlagPhase))
+	migrateCmd.Flags().StringSlice("tags", nil, c.msg(MsgMigrateFlagTags))
+	migrateCmd.Flags().Bool("keep-history", false, c.msg(MsgMigrateFlagKeepHistory))
+	migrateCmd.Flags().String("tenant", "", c.msg(MsgMigrateFlagTenant))
+	migrateCmd.Flags().Bool("all-tenants", false, c.msg(MsgMigrateFlagAllTenants))
+	migrateCmd.MarkFlagsMutuallyExclusive("step", "to", "fresh", "dry-run", "phase")
+	migrateCmd.MarkFlagsMutuallyExclusive("step", "to", "fresh", "dry-run", "tags")
+	migrateCmd.MarkFlagsMutuallyExclusive("seed", "step", "to", "dry-run")
+	migrateCmd.MarkFlagsMutuallyExclusive("keep-history", "step", "to", "dry-run", "phase", "tags")
+	migrateCmd.MarkFlagsMutuallyExclusive("tenant", "all-tenants", "step", "to", "fresh", "dry-run", "seed")
+
+	return c.instrument(ctx, migrateCmd)
+}
+
+func (c *Cli) PlanCommand(ctx context.Context) *cobra.Command {
+	var planCmd = &cobra.Command{
+		Use: "plan",
+		Run: func(cmd *cobra.Command, args []string) {
+			out, _ := cmd.Flags().GetString("out")
+			estimateFrom, _ := cmd.Flags().GetStringSlice("estimate-from")
+
+			w := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					c.fail(cmd, MsgPlanCreateFileError, err)
+					return
+				}
+				defer f.Close()
+				w = f
+			}
+
+			err := c.migration.Plan(ctx, w)
+			if err != nil {
+				c.fail(cmd, MsgPlanError, err)
+				return
+			}
+			if len(estimateFrom) > 0 {
+				estimate, err := c.estimatePending(ctx, estimateFrom)
 				if err != nil {
-					log.Println("Error running migrations:", err)
+					c.fail(cmd, MsgPlanEstimateError, err)
 					return
 				}
+				fmt.Fprintf(w, "\n%s", estimate)
+			}
+			if out != "" {
+				c.message(cmd, fmt.Sprintf(c.msg(MsgPlanWritten), out))
 			}
 		},
 	}
 
-	migrateCmd.Flags().BoolP("fresh", "f", false, "Run fresh migrations")
+	planCmd.Flags().StringP("out", "o", "", c.msg(MsgPlanFlagOut))
+	planCmd.Flags().StringSlice("estimate-from", nil, c.msg(MsgPlanFlagEstimateFrom))
 
-	return migrateCmd
+	return c.instrument(ctx, planCmd)
+}
+
+// estimatePending estimates the pending migrations from the history exports
+// at paths.
+func (c *Cli) estimatePending(ctx context.Context, paths []string) (BatchEstimate, error) {
+	histories := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return BatchEstimate{}, err
+		}
+		defer f.Close()
+		histories = append(histories, f)
+	}
+	return c.migration.EstimatePending(ctx, histories...)
 }
 
 func (c *Cli) RollbackCommand(ctx context.Context) *cobra.Command {
 	var rollbackCmd = &cobra.Command{
-		Use:   "rollback",
-		Short: "Rollback the last migration",
-		Args:  cobra.ExactArgs(0),
+		Use:  "rollback",
+		Args: cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
 			var err error
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			to, _ := cmd.Flags().GetString("to")
+			if to != "" {
+				inclusive, _ := cmd.Flags().GetBool("inclusive")
+				if dryRun {
+					err = c.migration.RollbackToDryRun(ctx, to, inclusive)
+				} else {
+					err = c.migration.RollbackTo(ctx, to, inclusive)
+				}
+				if err != nil {
+					c.fail(cmd, MsgRollbackError, err)
+				}
+				return
+			}
+
+			if batch, _ := cmd.Flags().GetBool("batch"); batch {
+				if dryRun {
+					err = c.migration.RollbackLastBatchDryRun(ctx)
+				} else {
+					err = c.migration.RollbackLastBatch(ctx)
+				}
+				if err != nil {
+					c.fail(cmd, MsgRollbackError, err)
+				}
+				return
+			}
+
 			step := 1
 			stepFlag := cmd.Flags().Lookup("step")
 			if stepFlag != nil && stepFlag.Changed {
 				step, err = strconv.Atoi(stepFlag.Value.String())
 				if err != nil {
-					log.Println("Invalid step:", err)
+					c.fail(cmd, MsgRollbackInvalidStep, err)
 					return
 				}
 				if step < 1 {
-					log.Println("Step must be greater than 0")
+					c.fail(cmd, MsgRollbackStepNotPositive, nil)
 					return
 				}
 			}
 
-			err = c.migration.Rollback(ctx, step)
+			if dryRun {
+				err = c.migration.RollbackDryRun(ctx, step)
+			} else {
+				err = c.migration.Rollback(ctx, step)
+			}
 			if err != nil {
-				log.Println("Error rolling back migrations:", err)
+				c.fail(cmd, MsgRollbackError, err)
 				return
 			}
 		},
 	}
 
-	rollbackCmd.Flags().IntP("step", "s", 1, "Number of migrations to rollback")
+	rollbackCmd.Flags().IntP("step", "s", 1, c.msg(MsgRollbackFlagStep))
+	rollbackCmd.Flags().String("to", "", c.msg(MsgRollbackFlagTo))
+	rollbackCmd.Flags().Bool("inclusive", false, c.msg(MsgRollbackFlagInclusive))
+	rollbackCmd.Flags().Bool("batch", false, c.msg(MsgRollbackFlagBatch))
+	rollbackCmd.Flags().Bool("dry-run", false, c.msg(MsgRollbackFlagDryRun))
+	rollbackCmd.MarkFlagsMutuallyExclusive("step", "to", "batch")
 
-	return rollbackCmd
+	return c.instrument(ctx, rollbackCmd)
 }
 
 func (c *Cli) ResetCommand(ctx context.Context) *cobra.Command {
 	var resetCmd = &cobra.Command{
-		Use:   "reset",
-		Short: "Rollback all migrations and re-run all migrations",
+		Use: "reset",
 		Run: func(cmd *cobra.Command, args []string) {
 			err := c.migration.Reset(ctx)
 			if err != nil {
-				log.Println("Error resetting migrations:", err)
+				c.fail(cmd, MsgResetError, err)
 				return
 			}
 		},
 	}
 
-	return resetCmd
+	return c.instrument(ctx, resetCmd)
 }
 
 func (c *Cli) CleanCommand(ctx context.Context) *cobra.Command {
 	var cleanCmd = &cobra.Command{
-		Use:   "clean",
-		Short: "Clean database (delete all tables)",
+		Use: "clean",
+		Run: func(cmd *cobra.Command, args []string) {
+			keepHistory, _ := cmd.Flags().GetBool("keep-history")
+			err := c.migration.CleanWithOptions(ctx, CleanOptions{KeepHistory: keepHistory})
+			if err != nil {
+				c.fail(cmd, MsgCleanError, err)
+				return
+			}
+		},
+	}
+
+	cleanCmd.Flags().Bool("keep-history", false, c.msg(MsgCleanFlagKeepHistory))
+
+	return c.instrument(ctx, cleanCmd)
+}
+
+func (c *Cli) MarkCommand(ctx context.Context) *cobra.Command {
+	var markCmd = &cobra.Command{
+		Use:  "mark",
+		Args: cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			err := c.migration.Clean(ctx)
+			var err error
+
+			if name, _ := cmd.Flags().GetString("applied"); name != "" {
+				err = c.migration.MarkApplied(ctx, name)
+			} else {
+				name, _ = cmd.Flags().GetString("unapplied")
+				err = c.migration.MarkUnapplied(ctx, name)
+			}
 			if err != nil {
-				log.Println("Error cleaning database:", err)
+				c.fail(cmd, MsgMarkError, err)
 				return
 			}
 		},
 	}
 
-	return cleanCmd
+	markCmd.Flags().String("applied", "", c.msg(MsgMarkFlagApplied))
+	markCmd.Flags().String("unapplied", "", c.msg(MsgMarkFlagUnapplied))
+	markCmd.MarkFlagsMutuallyExclusive("applied", "unapplied")
+	markCmd.MarkFlagsOneRequired("applied", "unapplied")
+
+	return c.instrument(ctx, markCmd)
+}
+
+func (c *Cli) RepairCommand(ctx context.Context) *cobra.Command {
+	var repairCmd = &cobra.Command{
+		Use: "repair",
+		Run: func(cmd *cobra.Command, args []string) {
+			report, err := c.migration.Repair(ctx)
+			if err != nil {
+				c.fail(cmd, MsgRepairError, err)
+				return
+			}
+			if err := c.rendererFor(cmd).RepairReport(cmd.OutOrStdout(), report); err != nil {
+				c.fail(cmd, MsgRenderError, err)
+			}
+		},
+	}
+
+	return c.instrument(ctx, repairCmd)
+}
+
+// ValidateCommand reports inconsistencies between the migration history and
+// the registered migrations. It returns ErrValidationFailed when any is found,
+// so Execute fails and the process can exit with a non-zero status.
+func (c *Cli) ValidateCommand(ctx context.Context) *cobra.Command {
+	var validateCmd = &cobra.Command{
+		Use:           "validate",
+		Args:          cobra.ExactArgs(0),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := c.migration.Validate(ctx)
+			if err != nil {
+				c.fail(cmd, MsgValidateError, err)
+				return err
+			}
+			if err := c.rendererFor(cmd).ValidationReport(cmd.OutOrStdout(), report); err != nil {
+				c.fail(cmd, MsgRenderError, err)
+				return err
+			}
+			if !report.Valid() {
+				return fmt.Errorf("%w: %d issue(s)", ErrValidationFailed, len(report))
+			}
+			return nil
+		},
+	}
+
+	return c.instrument(ctx, validateCmd)
+}
+
+func (c *Cli) UpgradeTrackingTableCommand(ctx context.Context) *cobra.Command {
+	var upgradeCmd = &cobra.Command{
+		Use: "upgrade-tracking-table",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := c.migration.UpgradeTrackingTable(ctx)
+			if errors.Is(err, ErrNotSupported) {
+				c.fail(cmd, MsgUpgradeNotSupported, nil)
+				return
+			}
+			if err != nil {
+				c.fail(cmd, MsgUpgradeError, err)
+				return
+			}
+		},
+	}
+
+	return c.instrument(ctx, upgradeCmd)
 }
 
 func (c *Cli) CreateCommand(ctx context.Context) *cobra.Command {
 	var createCmd = &cobra.Command{
-		Use:   "create",
-		Short: "Create a new migration",
+		Use: "create",
 		Run: func(cmd *cobra.Command, args []string) {
 			dir, _ := cmd.Flags().GetString("dir")
 			name, _ := cmd.Flags().GetString("name")
+			sqlFile, _ := cmd.Flags().GetBool("sql")
+			fileType, _ := cmd.Flags().GetString("type")
 
-			err := c.migration.SetMigrationFilesDir(dir).Create(name)
-			if err != nil {
-				log.Println("Error creating migration:", err)
+			create := c.migration.SetMigrationFilesDir(dir).Create
+			switch {
+			case sqlFile || fileType == "sql":
+				create = c.migration.CreateSQL
+			case fileType == "go":
+				create = c.migration.CreateGo
+			case fileType != "":
+				c.fail(cmd, MsgCreateInvalidType, nil)
+				return
+			}
+			if err := create(name); err != nil {
+				c.fail(cmd, MsgCreateError, err)
 				return
 			}
 		},
 	}
 
-	createCmd.Flags().StringP("name", "n", "", "name of the migration")
-	createCmd.Flags().StringP("dir", "d", "", "directory of the migration")
+	createCmd.Flags().StringP("name", "n", "", c.msg(MsgCreateFlagName))
+	createCmd.Flags().StringP("dir", "d", "", c.msg(MsgCreateFlagDir))
+	createCmd.Flags().Bool("sql", false, c.msg(MsgCreateFlagSql))
+	createCmd.Flags().String("type", "", c.msg(MsgCreateFlagType))
+	createCmd.MarkFlagsMutuallyExclusive("sql", "type")
 	createCmd.MarkFlagRequired("name")
 	createCmd.MarkFlagRequired("dir")
 
-	return createCmd
+	return c.instrument(ctx, createCmd)
+}
+
+// DiffCommand generates a migration converging the database to the tables of
+// a schema file.
+func (c *Cli) DiffCommand(ctx context.Context) *cobra.Command {
+	var diffCmd = &cobra.Command{
+		Use: "diff",
+		Run: func(cmd *cobra.Command, args []string) {
+			schemaFile, _ := cmd.Flags().GetString("schema")
+			dir, _ := cmd.Flags().GetString("dir")
+			name, _ := cmd.Flags().GetString("name")
+
+			schema, err := os.Open(schemaFile)
+			if err != nil {
+				c.fail(cmd, MsgDiffError, err)
+				return
+			}
+			defer schema.Close()
+
+			err = c.migration.SetMigrationFilesDir(dir).Diff(ctx, name, schema)
+			if err != nil {
+				c.fail(cmd, MsgDiffError, err)
+				return
+			}
+		},
+	}
+
+	diffCmd.Flags().StringP("schema", "s", "", c.msg(MsgDiffFlagSchema))
+	diffCmd.Flags().StringP("name", "n", "", c.msg(MsgCreateFlagName))
+	diffCmd.Flags().StringP("dir", "d", "", c.msg(MsgCreateFlagDir))
+	diffCmd.MarkFlagRequired("schema")
+	diffCmd.MarkFlagRequired("name")
+	diffCmd.MarkFlagRequired("dir")
+
+	return c.instrument(ctx, diffCmd)
+}
+
+// SquashCommand consolidates applied migrations into a baseline migration.
+func (c *Cli) SquashCommand(ctx context.Context) *cobra.Command {
+	var squashCmd = &cobra.Command{
+		Use: "squash",
+		Run: func(cmd *cobra.Command, args []string) {
+			to, _ := cmd.Flags().GetString("to")
+			dir, _ := cmd.Flags().GetString("dir")
+
+			err := c.migration.SetMigrationFilesDir(dir).Squash(ctx, to)
+			if err != nil {
+				c.fail(cmd, MsgSquashError, err)
+				return
+			}
+		},
+	}
+
+	squashCmd.Flags().String("to", "", c.msg(MsgSquashFlagTo))
+	squashCmd.Flags().StringP("dir", "d", "", c.msg(MsgCreateFlagDir))
+	squashCmd.MarkFlagRequired("to")
+	squashCmd.MarkFlagRequired("dir")
+
+	return c.instrument(ctx, squashCmd)
+}
+
+func (c *Cli) DiagramCommand(ctx context.Context) *cobra.Command {
+	var diagramCmd = &cobra.Command{
+		Use: "diagram",
+		Run: func(cmd *cobra.Command, args []string) {
+			format, _ := cmd.Flags().GetString("format")
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			out, _ := cmd.Flags().GetString("out")
+
+			w := cmd.OutOrStdout()
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					c.fail(cmd, MsgDiagramCreateFileError, err)
+					return
+				}
+				defer f.Close()
+				w = f
+			}
+
+			err := c.migration.Diagram(ctx, w, DiagramOptions{Format: DiagramFormat(format), From: from, To: to})
+			if err != nil {
+				c.fail(cmd, MsgDiagramError, err)
+				return
+			}
+			if out != "" {
+				c.message(cmd, fmt.Sprintf(c.msg(MsgDiagramWritten), out))
+			}
+		},
+	}
+
+	diagramCmd.Flags().StringP("format", "f", string(DiagramMermaid), c.msg(MsgDiagramFlagFormat))
+	diagramCmd.Flags().String("from", "", c.msg(MsgDiagramFlagFrom))
+	diagramCmd.Flags().String("to", "", c.msg(MsgDiagramFlagTo))
+	diagramCmd.Flags().StringP("out", "o", "", c.msg(MsgDiagramFlagOut))
+
+	return c.instrument(ctx, diagramCmd)
+}
+
+func (c *Cli) SeedCommand(ctx context.Context) *cobra.Command {
+	var seedCmd = &cobra.Command{
+		Use:  "seed",
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			env, _ := cmd.Flags().GetString("env")
+			only, _ := cmd.Flags().GetStringSlice("only")
+
+			if err := c.migration.Seed(ctx, SeedOptions{Env: env, Only: only}); err != nil {
+				c.fail(cmd, MsgSeedError, err)
+			}
+		},
+	}
+
+	seedCmd.Flags().String("env", "", c.msg(MsgSeedFlagEnv))
+	seedCmd.Flags().StringSlice("only", nil, c.msg(MsgSeedFlagOnly))
+
+	return c.instrument(ctx, seedCmd)
+}
+
+func (c *Cli) FixturesCommand(ctx context.Context) *cobra.Command {
+	var fixturesCmd = &cobra.Command{
+		Use: "fixtures",
+	}
+	c.describe(fixturesCmd, commandSpecs["fixtures"])
+
+	var loadCmd = &cobra.Command{
+		Use:  "load",
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir, _ := cmd.Flags().GetString("dir")
+			truncate, _ := cmd.Flags().GetBool("truncate")
+			only, _ := cmd.Flags().GetStringSlice("only")
+
+			err := c.migration.LoadFixtures(ctx, FixtureOptions{Dir: dir, Truncate: truncate, Only: only})
+			if err != nil {
+				c.fail(cmd, MsgFixturesLoadError, err)
+			}
+		},
+	}
+
+	loadCmd.Flags().String("dir", "", c.msg(MsgFixturesFlagDir))
+	loadCmd.Flags().Bool("truncate", false, c.msg(MsgFixturesFlagTruncate))
+	loadCmd.Flags().StringSlice("only", nil, c.msg(MsgFixturesFlagOnly))
+
+	fixturesCmd.AddCommand(c.instrument(ctx, loadCmd))
+	return fixturesCmd
+}
+
+// instrument wraps the command's Run or RunE function to report its usage
+// when a UsageReporter is configured, and describes it from its commandSpec.
+func (c *Cli) instrument(ctx context.Context, cmd *cobra.Command) *cobra.Command {
+	if cmd.Run == nil && cmd.RunE == nil {
+		return cmd
+	}
+
+	cmd.Flags().String("events-out", "", c.msg(MsgEventsOutFlag))
+	cmd.Flags().String("summary-out", "", c.msg(MsgSummaryOutFlag))
+	cmd.Flags().String("output", "", c.msg(MsgOutputFlag))
+	cmd.Flags().Bool("progress", false, c.msg(MsgProgressFlag))
+	cmd.Flags().Bool("verbose", false, c.msg(MsgVerboseFlag))
+	if spec, ok := commandSpecs[cmd.Name()]; ok {
+		c.describe(cmd, spec)
+	}
+
+	run := cmd.RunE
+	if run == nil {
+		legacyRun := cmd.Run
+		run = func(cmd *cobra.Command, args []string) error {
+			legacyRun(cmd, args)
+			return nil
+		}
+	}
+	cmd.Run = nil
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		startedAt := time.Now()
+
+		if name, _ := cmd.Flags().GetString("output"); name != "" {
+			if _, ok := builtinRenderers[name]; !ok {
+				c.fail(cmd, MsgOutputInvalid, fmt.Errorf("%q", name))
+				return nil
+			}
+		}
+
+		if path, _ := cmd.Flags().GetString("events-out"); path != "" {
+			stop, err := c.streamEvents(path)
+			if err != nil {
+				c.fail(cmd, MsgEventsOutError, err)
+				return nil
+			}
+			defer stop()
+		}
+
+		recorder := &runSummaryRecorder{}
+		unsubscribe := c.migration.Subscribe(recorder)
+		previous := log.Writer()
+		warnings := &warningCounter{w: previous}
+		loggedWarnings := c.migration.logger.warningCount()
+		if progress, _ := cmd.Flags().GetBool("progress"); progress {
+			// The progress display replaces the log of the run.
+			warnings.w = io.Discard
+			defer c.migration.ReportProgress(NewProgressPrinter(cmd.ErrOrStderr()))()
+		}
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+			previous := c.migration.logger.setLogSQL(true)
+			defer c.migration.logger.setLogSQL(previous)
+		}
+		log.SetOutput(warnings)
+
+		err := run(cmd, args)
+
+		log.SetOutput(previous)
+		unsubscribe()
+		if summary, ran := recorder.result(); ran {
+			summary.Warnings = int(warnings.count.Load()) + c.migration.logger.warningCount() - loggedWarnings
+			c.summarize(ctx, cmd, summary, startedAt, err)
+		}
+
+		if c.usageReporter != nil {
+			c.reportUsage(ctx, cmd, startedAt)
+		}
+		return err
+	}
+
+	return cmd
+}
+
+// summarize completes the summary of the runs of cmd, renders it on its
+// standard error and writes it to the --summary-out file, if any.
+func (c *Cli) summarize(ctx context.Context, cmd *cobra.Command, summary RunSummary, startedAt time.Time, err error) {
+	summary.Command = cmd.Name()
+	summary.Duration = time.Since(startedAt)
+	if err != nil && summary.Error == "" {
+		summary.Error = err.Error()
+	}
+	if status, err := c.migration.Status(ctx); err == nil && status.LastExecuted != nil {
+		summary.SchemaVersion = status.LastExecuted.Name
+	}
+
+	if err := c.rendererFor(cmd).RunSummary(cmd.ErrOrStderr(), summary); err != nil {
+		c.fail(cmd, MsgRenderError, err)
+	}
+	if path, _ := cmd.Flags().GetString("summary-out"); path != "" {
+		if err := writeRunSummary(path, summary); err != nil {
+			c.fail(cmd, MsgSummaryOutError, err)
+		}
+	}
+}
+
+// streamEvents subscribes a JSON Lines writer on path for the duration of a
+// command. The file is appended to, so it may also be a FIFO.
+func (c *Cli) streamEvents(path string) (stop func(), err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	unsubscribe := c.migration.Subscribe(NewJSONLinesEventWriter(f))
+	return func() {
+		unsubscribe()
+		_ = f.Close()
+	}, nil
+}
+
+func (c *Cli) reportUsage(ctx context.Context, cmd *cobra.Command, startedAt time.Time) {
+	var flags []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		flags = append(flags, f.Name)
+	})
+
+	c.usageReporter.ReportCommand(ctx, CommandUsage{
+		Command:   cmd.CommandPath(),
+		Flags:     flags,
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	})
+}
+
+// rendererFor returns the built-in Renderer selected by the --output flag of
+// cmd, or the configured one.
+func (c *Cli) rendererFor(cmd *cobra.Command) Renderer {
+	if name, _ := cmd.Flags().GetString("output"); name != "" {
+		if renderer, ok := builtinRenderers[name]; ok {
+			return renderer
+		}
+	}
+	return c.renderer
+}
+
+// message renders an informational message on the standard error of cmd.
+func (c *Cli) message(cmd *cobra.Command, msg string) {
+	if logger := c.loggerFor(cmd); logger != nil {
+		logger.Info(msg)
+		return
+	}
+	_ = c.rendererFor(cmd).Message(cmd.ErrOrStderr(), msg)
+}
+
+// fail renders the failure described by key on the standard error of cmd.
+func (c *Cli) fail(cmd *cobra.Command, key MessageKey, err error) {
+	if logger := c.loggerFor(cmd); logger != nil {
+		if err == nil {
+			logger.Error(c.msg(key))
+		} else {
+			logger.Error(c.msg(key), "error", MaskCredentials(err.Error()))
+		}
+		return
+	}
+	_ = c.rendererFor(cmd).Error(cmd.ErrOrStderr(), c.msg(key), maskError(err))
+}
+
+// loggerFor returns Config.Logger when it takes the messages of cmd, those
+// TableRenderer would write as log lines, or nil.
+func (c *Cli) loggerFor(cmd *cobra.Command) *slog.Logger {
+	if _, ok := c.rendererFor(cmd).(TableRenderer); !ok {
+		return nil
+	}
+	return c.migration.logger.logger
+}
+
+// msg returns the CLI message for key in the configured locale.
+func (c *Cli) msg(key MessageKey) string {
+	return c.messages.get(key)
 }
 
 func (c *Cli) Execute(ctx context.Context) error {
@@ -181,20 +845,84 @@ func (c *Cli) Execute(ctx context.Context) error {
 		CompletionOptions: cobra.CompletionOptions{
 			HiddenDefaultCmd: true,
 		},
-		Short: "GoMigration CLI",
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
 	}
 
+	c.describe(rootCmd, rootCommandSpec)
+
+	ctx, stop := c.handleSignals(ctx, rootCmd.ErrOrStderr())
+	defer stop()
+
+	rootCmd.AddCommand(c.HelpTopicCommands()...)
 	rootCmd.AddCommand(
 		c.ListCommand(ctx),
+		c.StatusCommand(ctx),
 		c.MigrateCommand(ctx),
+		c.PlanCommand(ctx),
 		c.RollbackCommand(ctx),
 		c.ResetCommand(ctx),
 		c.CleanCommand(ctx),
+		c.MarkCommand(ctx),
+		c.RepairCommand(ctx),
+		c.ValidateCommand(ctx),
+		c.UpgradeTrackingTableCommand(ctx),
 		c.CreateCommand(ctx),
+		c.DiffCommand(ctx),
+		c.SquashCommand(ctx),
+		c.DiagramCommand(ctx),
+		c.SeedCommand(ctx),
+		c.FixturesCommand(ctx),
 	)
 
 	return rootCmd.Execute()
 }
+
+// handleSignals returns a context cancelled on SIGINT or SIGTERM, so a run
+// stops cleanly between migrations, after telling w which migration it
+// finishes first. A second signal terminates the process as usual. stop
+// restores the default handling of the signals.
+func (c *Cli) handleSignals(ctx context.Context, w io.Writer) (_ context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var mu sync.Mutex
+	inFlight := ""
+	unsubscribe := c.migration.Subscribe(EventListenerFunc(func(_ context.Context, event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch event.Type {
+		case EventMigrationStarted:
+			inFlight = event.Migration
+		case EventMigrationSucceeded, EventMigrationFailed:
+			inFlight = ""
+		}
+	}))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			mu.Lock()
+			migration := inFlight
+			mu.Unlock()
+			if migration != "" {
+				fmt.Fprintf(w, c.msg(MsgInterruptedMigration)+"\n", migration)
+			} else {
+				fmt.Fprintln(w, c.msg(MsgInterruptedIdle))
+			}
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		close(done)
+		signal.Stop(signals)
+		unsubscribe()
+		cancel()
+	}
+}
@@ -0,0 +1,357 @@
+package gomigration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// flagGroupAnnotation is the pflag annotation holding the localized title
+	// of the group a flag is listed under.
+	flagGroupAnnotation = "gomigration_flag_group"
+
+	// flagGroupsAnnotation is the command annotation holding the localized
+	// titles of its flag groups, one per line, in display order. The last
+	// title is used for flags that belong to no group.
+	flagGroupsAnnotation = "gomigration_flag_groups"
+
+	// localFlagsUsage is the flags section of cobra's default usage template.
+	localFlagsUsage = "Flags:\n{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}"
+)
+
+func init() {
+	cobra.AddTemplateFunc("groupedFlagUsages", groupedFlagUsages)
+}
+
+// commandSpec describes the help of a CLI command: its descriptions, usage
+// examples and how its flags are grouped. Examples may refer to the CLI name
+// as %[1]s.
+type commandSpec struct {
+	short      MessageKey
+	long       MessageKey
+	examples   []string
+	flagGroups []flagGroup
+}
+
+// flagGroup lists flags shown together under a title in the command help.
+type flagGroup struct {
+	title MessageKey
+	flags []string
+}
+
+// helpTopic is a concept explained by `help <topic>` rather than a command.
+type helpTopic struct {
+	name  string
+	short MessageKey
+	long  MessageKey
+}
+
+var outputFlags = flagGroup{title: MsgHelpGroupOutput, flags: []string{"output", "events-out", "summary-out", "progress", "verbose"}}
+
+var rootCommandSpec = commandSpec{
+	short: MsgRootShort,
+	long:  MsgRootLong,
+	examples: []string{
+		"%[1]s status",
+		"%[1]s migrate",
+		"%[1]s help topics",
+	},
+}
+
+// commandSpecs holds the help of every CLI command, by command name.
+var commandSpecs = map[string]commandSpec{
+	"list": {
+		short:      MsgListShort,
+		long:       MsgListLong,
+		examples:   []string{"%[1]s list"},
+		flagGroups: []flagGroup{outputFlags},
+	},
+	"status": {
+		short: MsgStatusShort,
+		long:  MsgStatusLong,
+		examples: []string{
+			"%[1]s status",
+			"%[1]s status --json",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupOutput, flags: []string{"json", "output", "events-out", "summary-out", "progress", "verbose"}},
+		},
+	},
+	"migrate": {
+		short: MsgMigrateShort,
+		long:  MsgMigrateLong,
+		examples: []string{
+			"%[1]s migrate",
+			"%[1]s migrate --step 2",
+			"%[1]s migrate --to 20240101120000_create_users_table",
+			"%[1]s migrate --dry-run",
+			"%[1]s migrate --fresh",
+			"%[1]s migrate --seed",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupSelection, flags: []string{"step", "to"}},
+			{title: MsgHelpGroupMode, flags: []string{"fresh", "dry-run", "seed"}},
+			outputFlags,
+		},
+	},
+	"plan": {
+		short: MsgPlanShort,
+		long:  MsgPlanLong,
+		examples: []string{
+			"%[1]s plan",
+			"%[1]s plan --out plan.sql",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupOutput, flags: []string{"out", "output", "events-out", "summary-out", "progress", "verbose"}},
+		},
+	},
+	"rollback": {
+		short: MsgRollbackShort,
+		long:  MsgRollbackLong,
+		examples: []string{
+			"%[1]s rollback",
+			"%[1]s rollback --step 3",
+			"%[1]s rollback --to 20240101120000_create_users_table --inclusive",
+			"%[1]s rollback --batch",
+			"%[1]s rollback --step 2 --dry-run",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupSelection, flags: []string{"step", "to", "inclusive", "batch"}},
+			{title: MsgHelpGroupMode, flags: []string{"dry-run"}},
+			outputFlags,
+		},
+	},
+	"reset": {
+		short:      MsgResetShort,
+		long:       MsgResetLong,
+		examples:   []string{"%[1]s reset"},
+		flagGroups: []flagGroup{outputFlags},
+	},
+	"clean": {
+		short:      MsgCleanShort,
+		long:       MsgCleanLong,
+		examples:   []string{"%[1]s clean"},
+		flagGroups: []flagGroup{outputFlags},
+	},
+	"mark": {
+		short: MsgMarkShort,
+		long:  MsgMarkLong,
+		examples: []string{
+			"%[1]s mark --applied 20240101120000_create_users_table",
+			"%[1]s mark --unapplied 20240101120000_create_users_table",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupSelection, flags: []string{"applied", "unapplied"}},
+			outputFlags,
+		},
+	},
+	"repair": {
+		short:      MsgRepairShort,
+		long:       MsgRepairLong,
+		examples:   []string{"%[1]s repair"},
+		flagGroups: []flagGroup{outputFlags},
+	},
+	"validate": {
+		short: MsgValidateShort,
+		long:  MsgValidateLong,
+		examples: []string{
+			"%[1]s validate",
+			"%[1]s validate --output json",
+		},
+		flagGroups: []flagGroup{outputFlags},
+	},
+	"upgrade-tracking-table": {
+		short:      MsgUpgradeShort,
+		long:       MsgUpgradeLong,
+		examples:   []string{"%[1]s upgrade-tracking-table"},
+		flagGroups: []flagGroup{outputFlags},
+	},
+	"create": {
+		short: MsgCreateShort,
+		long:  MsgCreateLong,
+		examples: []string{
+			"%[1]s create --name create_users_table --dir migrations",
+			"%[1]s create --name create_users_table --dir migrations --sql",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupMigrationFile, flags: []string{"name", "dir", "sql"}},
+			outputFlags,
+		},
+	},
+	"diff": {
+		short: MsgDiffShort,
+		long:  MsgDiffLong,
+		examples: []string{
+			"%[1]s diff --schema schema.sql --name sync_schema --dir migrations",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupMigrationFile, flags: []string{"schema", "name", "dir"}},
+			outputFlags,
+		},
+	},
+	"squash": {
+		short: MsgSquashShort,
+		long:  MsgSquashLong,
+		examples: []string{
+			"%[1]s squash --to 20250301120000_create_orders_table --dir migrations",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupMigrationFile, flags: []string{"to", "dir"}},
+			outputFlags,
+		},
+	},
+	"diagram": {
+		short: MsgDiagramShort,
+		long:  MsgDiagramLong,
+		examples: []string{
+			"%[1]s diagram",
+			"%[1]s diagram --format dot --out schema.dot",
+			"%[1]s diagram --from 20250301120000_create_orders_table",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupSelection, flags: []string{"from", "to"}},
+			{title: MsgHelpGroupOutput, flags: []string{"format", "out", "output", "events-out", "summary-out", "progress", "verbose"}},
+		},
+	},
+	"seed": {
+		short: MsgSeedShort,
+		long:  MsgSeedLong,
+		examples: []string{
+			"%[1]s seed",
+			"%[1]s seed --env development",
+			"%[1]s seed --env staging --only countries,demo_users",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupSelection, flags: []string{"env", "only"}},
+			outputFlags,
+		},
+	},
+	"fixtures": {
+		short: MsgFixturesShort,
+		long:  MsgFixturesLong,
+		examples: []string{
+			"%[1]s fixtures load --dir testdata/fixtures --truncate",
+		},
+	},
+	"load": {
+		short: MsgFixturesLoadShort,
+		long:  MsgFixturesLoadLong,
+		examples: []string{
+			"%[1]s fixtures load",
+			"%[1]s fixtures load --dir testdata/fixtures --truncate",
+			"%[1]s fixtures load --only users,posts",
+		},
+		flagGroups: []flagGroup{
+			{title: MsgHelpGroupSelection, flags: []string{"dir", "only"}},
+			{title: MsgHelpGroupMode, flags: []string{"truncate"}},
+			outputFlags,
+		},
+	},
+}
+
+// helpTopics are the concepts listed by `help topics`, in display order.
+var helpTopics = []helpTopic{
+	{name: "locking", short: MsgTopicLockingShort, long: MsgTopicLockingLong},
+	{name: "batches", short: MsgTopicBatchesShort, long: MsgTopicBatchesLong},
+	{name: "checksums", short: MsgTopicChecksumsShort, long: MsgTopicChecksumsLong},
+	{name: "transactions", short: MsgTopicTransactionsShort, long: MsgTopicTransactionsLong},
+}
+
+// describe sets the descriptions, examples and flag groups of cmd from spec.
+func (c *Cli) describe(cmd *cobra.Command, spec commandSpec) {
+	cmd.Short = c.msg(spec.short)
+	cmd.Long = c.msg(spec.long)
+
+	examples := make([]string, len(spec.examples))
+	for i, example := range spec.examples {
+		examples[i] = "  " + fmt.Sprintf(example, c.cliName)
+	}
+	cmd.Example = strings.Join(examples, "\n")
+
+	var titles []string
+	for _, group := range spec.flagGroups {
+		title := c.msg(group.title)
+		titles = append(titles, title)
+		for _, name := range group.flags {
+			_ = cmd.Flags().SetAnnotation(name, flagGroupAnnotation, []string{title})
+		}
+	}
+	if len(titles) == 0 {
+		titles = append(titles, c.msg(MsgHelpGroupFlags))
+	} else {
+		titles = append(titles, c.msg(MsgHelpGroupOther))
+	}
+
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[flagGroupsAnnotation] = strings.Join(titles, "\n")
+	cmd.SetUsageTemplate(strings.Replace(cmd.UsageTemplate(), localFlagsUsage, "{{groupedFlagUsages . | trimTrailingWhitespaces}}", 1))
+}
+
+// HelpTopicCommands returns the help topics explaining GoMigration concepts,
+// such as locking and checksums. They are shown by `help <topic>`, and
+// `help topics` lists them all.
+func (c *Cli) HelpTopicCommands() []*cobra.Command {
+	index := []string{c.msg(MsgTopicsLong), ""}
+	commands := []*cobra.Command{}
+	for _, topic := range helpTopics {
+		index = append(index, fmt.Sprintf("  %-14s %s", topic.name, c.msg(topic.short)))
+		commands = append(commands, &cobra.Command{
+			Use:   topic.name,
+			Short: c.msg(topic.short),
+			Long:  c.msg(topic.long),
+		})
+	}
+	index = append(index, "", fmt.Sprintf(c.msg(MsgTopicsFooter), c.cliName))
+
+	topics := &cobra.Command{
+		Use:   "topics",
+		Short: c.msg(MsgTopicsShort),
+		Long:  strings.Join(index, "\n"),
+	}
+
+	return append([]*cobra.Command{topics}, commands...)
+}
+
+// groupedFlagUsages renders the local flags of cmd under the titles of their
+// groups, in the order set by describe.
+func groupedFlagUsages(cmd *cobra.Command) string {
+	titles := strings.Split(cmd.Annotations[flagGroupsAnnotation], "\n")
+	if titles[len(titles)-1] == "" {
+		titles[len(titles)-1] = "Flags"
+	}
+	other := titles[len(titles)-1]
+
+	sets := map[string]*pflag.FlagSet{}
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		title := other
+		if group := f.Annotations[flagGroupAnnotation]; len(group) > 0 {
+			title = group[0]
+		}
+		if sets[title] == nil {
+			sets[title] = pflag.NewFlagSet(title, pflag.ContinueOnError)
+		}
+		sets[title].AddFlag(f)
+	})
+
+	var b strings.Builder
+	for _, title := range titles {
+		set, ok := sets[title]
+		if !ok {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(title + ":\n")
+		b.WriteString(set.FlagUsages())
+	}
+	return b.String()
+}
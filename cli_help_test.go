@@ -0,0 +1,80 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandSpecs(t *testing.T) {
+	cli, err := NewCli(CliConfig{GoMigration: &GoMigration{}, Locale: "en"})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	commands := []*cobra.Command{
+		cli.ListCommand(ctx),
+		cli.StatusCommand(ctx),
+		cli.MigrateCommand(ctx),
+		cli.PlanCommand(ctx),
+		cli.RollbackCommand(ctx),
+		cli.ResetCommand(ctx),
+		cli.CleanCommand(ctx),
+		cli.MarkCommand(ctx),
+		cli.RepairCommand(ctx),
+		cli.ValidateCommand(ctx),
+		cli.UpgradeTrackingTableCommand(ctx),
+		cli.CreateCommand(ctx),
+		cli.DiffCommand(ctx),
+		cli.SquashCommand(ctx),
+		cli.DiagramCommand(ctx),
+		cli.SeedCommand(ctx),
+	}
+	fixtures := cli.FixturesCommand(ctx)
+	commands = append(commands, fixtures)
+	commands = append(commands, fixtures.Commands()...)
+	assert.Len(t, commandSpecs, len(commands))
+
+	for _, cmd := range commands {
+		spec, ok := commandSpecs[cmd.Name()]
+		if !assert.True(t, ok, cmd.Name()) {
+			continue
+		}
+		assert.NotEmpty(t, cmd.Short, cmd.Name())
+		assert.NotEmpty(t, cmd.Long, cmd.Name())
+		assert.NotEmpty(t, cmd.Example, cmd.Name())
+		for _, group := range spec.flagGroups {
+			for _, name := range group.flags {
+				assert.NotNil(t, cmd.Flags().Lookup(name), "%s --%s", cmd.Name(), name)
+			}
+		}
+	}
+
+	for _, topic := range helpTopics {
+		assert.Contains(t, builtinCatalogs["en"], topic.short)
+		assert.Contains(t, builtinCatalogs["en"], topic.long)
+	}
+}
+
+func TestGroupedFlagUsages(t *testing.T) {
+	cli, err := NewCli(CliConfig{GoMigration: &GoMigration{}, CliName: "gomigration", Locale: "en"})
+	assert.NoError(t, err)
+
+	cmd := cli.MigrateCommand(context.Background())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	assert.NoError(t, cmd.Usage())
+
+	usage := out.String()
+	assert.Contains(t, usage, "gomigration migrate --step 2")
+	selection := bytes.Index(out.Bytes(), []byte("Selection Flags:\n"))
+	mode := bytes.Index(out.Bytes(), []byte("Mode Flags:\n"))
+	output := bytes.Index(out.Bytes(), []byte("Output Flags:\n"))
+	assert.True(t, selection >= 0 && selection < mode && mode < output, usage)
+	assert.Contains(t, usage[selection:mode], "--step")
+	assert.Contains(t, usage[mode:output], "--dry-run")
+	assert.Contains(t, usage[output:], "--events-out")
+	assert.NotContains(t, usage, "\nFlags:")
+}
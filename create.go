@@ -0,0 +1,126 @@
+package gomigration
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// createKind is the kind of file a new migration is written to.
+type createKind int
+
+const (
+	// createGoFile is a Go file with a migration struct.
+	createGoFile createKind = iota
+	// createRegisteredGoFile is a Go file with a migration struct registered
+	// with the package-level Register from an init function.
+	createRegisteredGoFile
+	// createSQLFile is a single SQL file with -- +migrate Up and Down sections.
+	createSQLFile
+)
+
+// registry holds the migrations registered with the package-level Register,
+// and where each was registered from.
+var registry struct {
+	mu             sync.Mutex
+	migrations     []Migration
+	registeredFrom []string
+}
+
+// Register registers migrations with the package rather than with a
+// GoMigration, usually from the init functions of migration files generated
+// by CreateGo, so a GoMigration picks them up with RegisterGlobal. It is safe
+// for concurrent use.
+func Register(migrations ...Migration) {
+	registeredFrom := callerLocation()
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, migration := range migrations {
+		registry.migrations = append(registry.migrations, migration)
+		registry.registeredFrom = append(registry.registeredFrom, registeredFrom)
+	}
+}
+
+// RegisterGlobal registers the migrations registered with the package-level
+// Register, as RegisterMany does: if one of them is invalid, none is
+// registered and the error names every problem.
+func (q *GoMigration) RegisterGlobal() error {
+	registry.mu.Lock()
+	migrations := slices.Clone(registry.migrations)
+	registeredFrom := slices.Clone(registry.registeredFrom)
+	registry.mu.Unlock()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if errs := q.registrationErrors(migrations); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	for i, migration := range migrations {
+		if err := q.register(registeredFrom[i], []Migration{migration}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateTemplates are text/template templates of the up and down scripts of
+// the migrations Create and CreateSQL generate, so teams can bake house-style
+// headers and safety settings into every new migration. They are executed
+// with a CreateTemplateData; an empty template leaves its script empty.
+type CreateTemplates struct {
+	Up   string
+	Down string
+}
+
+// CreateTemplateData is the data CreateTemplates are executed with.
+type CreateTemplateData struct {
+	// Name is the name of the new migration, prefix included, such as
+	// 20250301120000_create_users.
+	Name string
+	// ID is the prefix of Name, as returned by Config.MigrationIDGenerator.
+	ID        string
+	Timestamp time.Time
+	// Author is Config.AppliedBy.
+	Author string
+}
+
+// parse parses the templates of t, failing on the first invalid one.
+func (t CreateTemplates) parse() (up, down *template.Template, err error) {
+	up, err = template.New("up").Option("missingkey=error").Parse(t.Up)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid up template: %w", err)
+	}
+	down, err = template.New("down").Option("missingkey=error").Parse(t.Down)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid down template: %w", err)
+	}
+	return up, down, nil
+}
+
+// render returns the up and down scripts of a new migration described by
+// data.
+func (t CreateTemplates) render(data CreateTemplateData) (upScript, downScript string, err error) {
+	up, down, err := t.parse()
+	if err != nil {
+		return "", "", err
+	}
+
+	var b strings.Builder
+	if err := up.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("failed to render up template: %w", err)
+	}
+	upScript = b.String()
+
+	b.Reset()
+	if err := down.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("failed to render down template: %w", err)
+	}
+	return upScript, b.String(), nil
+}
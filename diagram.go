@@ -0,0 +1,483 @@
+package gomigration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ERModel describes the tables of a database, their columns and the foreign
+// keys between them, as drawn by Diagram.
+type ERModel struct {
+	Tables      []ERTable
+	ForeignKeys []ERForeignKey
+}
+
+// ERTable is a table of an ERModel.
+type ERTable struct {
+	Name    string
+	Columns []ERColumn
+}
+
+// ERColumn is a column of an ERTable.
+type ERColumn struct {
+	Name string
+	// Type is the column type as reported by the database, such as "integer"
+	// or "varchar(255)".
+	Type       string
+	Nullable   bool
+	PrimaryKey bool
+}
+
+// ERForeignKey is a foreign key of an ERModel, from Columns of Table to
+// RefColumns of RefTable.
+type ERForeignKey struct {
+	Name       string
+	Table      string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// ERInspector is implemented by drivers that can describe the tables,
+// columns and foreign keys of the live database. It is required by Diagram.
+type ERInspector interface {
+	// InspectER returns the tables of the current schema, their columns and
+	// foreign keys, leaving out the tracking table and the tables kept next
+	// to it.
+	InspectER(ctx context.Context) (ERModel, error)
+}
+
+// DiagramFormat is the notation of a diagram written by Diagram.
+type DiagramFormat string
+
+const (
+	// DiagramMermaid writes a Mermaid erDiagram.
+	DiagramMermaid DiagramFormat = "mermaid"
+	// DiagramPlantUML writes a PlantUML diagram of entities.
+	DiagramPlantUML DiagramFormat = "plantuml"
+	// DiagramDOT writes a Graphviz digraph of record nodes.
+	DiagramDOT DiagramFormat = "dot"
+)
+
+// DiagramOptions are the options of Diagram.
+type DiagramOptions struct {
+	// Format is the notation of the diagram, DiagramMermaid when empty.
+	Format DiagramFormat
+	// From and To restrict the diagram to the tables created or altered by
+	// the migrations from From to To, inclusive, in the order they are
+	// applied. An empty From starts at the first migration and an empty To
+	// ends at the last one. The whole schema is drawn when both are empty.
+	From string
+	To   string
+}
+
+// alterTablePattern matches the start of a CREATE TABLE or ALTER TABLE
+// statement, up to the table name.
+var alterTablePattern = regexp.MustCompile(`(?i)\b(?:CREATE\s+(?:TEMPORARY\s+|TEMP\s+|UNLOGGED\s+)?TABLE(?:\s+IF\s+NOT\s+EXISTS)?|ALTER\s+TABLE(?:\s+IF\s+EXISTS)?(?:\s+ONLY)?)\s+`)
+
+// Diagram writes an entity relationship diagram of the migrated schema to w:
+// its tables, their columns and the foreign keys between them. The database
+// is only read.
+func (q *GoMigration) Diagram(ctx context.Context, w io.Writer, opts DiagramOptions) error {
+	q = q.snapshot()
+
+	inspector, ok := q.driver.(ERInspector)
+	if !ok {
+		return ErrDiagramNotSupported
+	}
+
+	if opts.Format == "" {
+		opts.Format = DiagramMermaid
+	}
+	render, ok := diagramRenderers[opts.Format]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidDiagramFormat, opts.Format)
+	}
+
+	var tables map[string]bool
+	if opts.From != "" || opts.To != "" {
+		var err error
+		tables, err = q.migratedTables(opts.From, opts.To)
+		if err != nil {
+			return err
+		}
+	}
+
+	model, err := inspector.InspectER(ctx)
+	if err != nil {
+		return err
+	}
+	if tables != nil {
+		model = model.only(tables)
+	}
+
+	return render(w, model)
+}
+
+// migratedTables returns the keys, as returned by schemaKey, of the tables
+// created or altered by the migrations from from to to, inclusive.
+func (q *GoMigration) migratedTables(from, to string) (map[string]bool, error) {
+	names, err := q.orderedMigrationNames()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := 0, len(names)-1
+	if from != "" {
+		if start = slices.Index(names, from); start < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationNotRegistered, from)
+		}
+	}
+	if to != "" {
+		if end = slices.Index(names, to); end < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationNotRegistered, to)
+		}
+	}
+	if start > end {
+		return nil, fmt.Errorf("migration %s is applied after %s", from, to)
+	}
+
+	return q.createdOrAlteredTables(names[start : end+1])
+}
+
+// createdOrAlteredTables returns the keys, as returned by schemaKey, of the
+// tables created or altered by the named migrations.
+func (q *GoMigration) createdOrAlteredTables(names []string) (map[string]bool, error) {
+	tables := map[string]bool{}
+	for _, name := range names {
+		script, err := q.renderScript(name, q.upScript(q.migrations[name]))
+		if err != nil {
+			return nil, err
+		}
+		stripped := stripSQLCommentsAndStrings(script)
+		for _, loc := range alterTablePattern.FindAllStringIndex(stripped, -1) {
+			if table := sqlIdentifier(script[loc[1]:]); table != "" {
+				tables[schemaKey(table)] = true
+			}
+		}
+	}
+	return tables, nil
+}
+
+// only returns the part of the model made of the tables whose keys are in
+// tables and their foreign keys, which still show the tables they refer to.
+func (m ERModel) only(tables map[string]bool) ERModel {
+	model := ERModel{}
+	for _, table := range m.Tables {
+		if tables[schemaKey(table.Name)] {
+			model.Tables = append(model.Tables, table)
+		}
+	}
+	for _, fk := range m.ForeignKeys {
+		if tables[schemaKey(fk.Table)] {
+			model.ForeignKeys = append(model.ForeignKeys, fk)
+		}
+	}
+	return model
+}
+
+// createOrder returns the tables of the model in an order they can be
+// created in: tables referred to by foreign keys come before the tables
+// referring to them, unless the foreign keys form a cycle.
+func (m ERModel) createOrder() []ERTable {
+	byName := make(map[string]ERTable, len(m.Tables))
+	for _, table := range m.Tables {
+		byName[table.Name] = table
+	}
+
+	var ordered []ERTable
+	visited := map[string]bool{}
+	var visit func(table ERTable)
+	visit = func(table ERTable) {
+		if visited[table.Name] {
+			return
+		}
+		visited[table.Name] = true
+		for _, fk := range m.ForeignKeys {
+			if ref, ok := byName[fk.RefTable]; ok && fk.Table == table.Name {
+				visit(ref)
+			}
+		}
+		ordered = append(ordered, table)
+	}
+	for _, table := range m.Tables {
+		visit(table)
+	}
+	return ordered
+}
+
+// upScript returns the CREATE TABLE statements of the model in dialect: its
+// columns with their types and nullability, primary keys and foreign keys.
+func (m ERModel) upScript(dialect Dialect) string {
+	quote := func(names []string) string {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = quoteIdentifier(dialect, name)
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	var statements []string
+	for _, table := range m.createOrder() {
+		var definitions, primaryKey []string
+		for _, column := range table.Columns {
+			definition := quoteIdentifier(dialect, column.Name)
+			if column.Type != "" {
+				definition += " " + column.Type
+			}
+			if !column.Nullable {
+				definition += " NOT NULL"
+			}
+			definitions = append(definitions, definition)
+			if column.PrimaryKey {
+				primaryKey = append(primaryKey, column.Name)
+			}
+		}
+		if len(primaryKey) > 0 {
+			definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", quote(primaryKey)))
+		}
+		for _, fk := range m.ForeignKeys {
+			if fk.Table != table.Name {
+				continue
+			}
+			definition := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", quote(fk.Columns), quoteIdentifier(dialect, fk.RefTable))
+			if !slices.Contains(fk.RefColumns, "") {
+				definition += fmt.Sprintf(" (%s)", quote(fk.RefColumns))
+			}
+			definitions = append(definitions, definition)
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (\n\t%s\n);", quoteIdentifier(dialect, table.Name), strings.Join(definitions, ",\n\t")))
+	}
+	return strings.Join(statements, "\n\n")
+}
+
+// downScript returns the statements dropping the tables of the model, in the
+// reverse order of upScript.
+func (m ERModel) downScript(dialect Dialect) string {
+	tables := m.createOrder()
+	statements := make([]string, len(tables))
+	for i, table := range tables {
+		statements[len(tables)-1-i] = fmt.Sprintf("DROP TABLE %s;", quoteIdentifier(dialect, table.Name))
+	}
+	return strings.Join(statements, "\n")
+}
+
+// inspectER runs columnsQuery and foreignKeysQuery, dialect specific catalog
+// queries of the current schema. columnsQuery returns the table name, column
+// name, type, whether the column is nullable and whether it is part of the
+// primary key, ordered by table and column position. foreignKeysQuery
+// returns the constraint name, table name, column name, referenced table
+// name and referenced column name, ordered by table, constraint and column
+// position; an empty referenced column stands for the column of the
+// referenced primary key at the same position. The tracking table named
+// table and the tables kept next to it are left out.
+func inspectER(ctx context.Context, db *sql.DB, table, columnsQuery, foreignKeysQuery string) (ERModel, error) {
+	skip := func(name string) bool {
+		return name == table || strings.HasPrefix(name, table+"_")
+	}
+
+	rows, err := db.QueryContext(ctx, columnsQuery)
+	if err != nil {
+		return ERModel{}, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	defer rows.Close()
+
+	model := ERModel{}
+	for rows.Next() {
+		var tableName string
+		var column ERColumn
+		if err := rows.Scan(&tableName, &column.Name, &column.Type, &column.Nullable, &column.PrimaryKey); err != nil {
+			return ERModel{}, err
+		}
+		if skip(tableName) {
+			continue
+		}
+		if len(model.Tables) == 0 || model.Tables[len(model.Tables)-1].Name != tableName {
+			model.Tables = append(model.Tables, ERTable{Name: tableName})
+		}
+		last := &model.Tables[len(model.Tables)-1]
+		last.Columns = append(last.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return ERModel{}, err
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, foreignKeysQuery)
+	if err != nil {
+		return ERModel{}, fmt.Errorf("failed to inspect foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, tableName, column, refTable, refColumn string
+		if err := rows.Scan(&name, &tableName, &column, &refTable, &refColumn); err != nil {
+			return ERModel{}, err
+		}
+		if skip(tableName) {
+			continue
+		}
+		n := len(model.ForeignKeys)
+		if n == 0 || model.ForeignKeys[n-1].Table != tableName || model.ForeignKeys[n-1].Name != name {
+			model.ForeignKeys = append(model.ForeignKeys, ERForeignKey{Name: name, Table: tableName, RefTable: refTable})
+			n++
+		}
+		fk := &model.ForeignKeys[n-1]
+		if refColumn == "" {
+			refColumn = model.primaryKeyColumn(refTable, len(fk.Columns))
+		}
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+	}
+
+	return model, rows.Err()
+}
+
+// primaryKeyColumn returns the name of the i-th primary key column of the
+// named table, if any.
+func (m ERModel) primaryKeyColumn(table string, i int) string {
+	for _, t := range m.Tables {
+		if t.Name != table {
+			continue
+		}
+		for _, column := range t.Columns {
+			if !column.PrimaryKey {
+				continue
+			}
+			if i == 0 {
+				return column.Name
+			}
+			i--
+		}
+	}
+	return ""
+}
+
+// foreignKeyColumns returns the names of the columns of the named table that
+// are part of a foreign key.
+func (m ERModel) foreignKeyColumns(table string) map[string]bool {
+	columns := map[string]bool{}
+	for _, fk := range m.ForeignKeys {
+		if fk.Table != table {
+			continue
+		}
+		for _, column := range fk.Columns {
+			columns[column] = true
+		}
+	}
+	return columns
+}
+
+// diagramRenderers write an ERModel in each DiagramFormat.
+var diagramRenderers = map[DiagramFormat]func(w io.Writer, model ERModel) error{
+	DiagramMermaid:  renderMermaid,
+	DiagramPlantUML: renderPlantUML,
+	DiagramDOT:      renderDOT,
+}
+
+// mermaidTypeReplacer makes column types valid Mermaid attribute types, which
+// cannot contain spaces or commas.
+var mermaidTypeReplacer = strings.NewReplacer(" ", "_", ",", "_", "\"", "")
+
+func renderMermaid(w io.Writer, model ERModel) error {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, table := range model.Tables {
+		fkColumns := model.foreignKeyColumns(table.Name)
+		fmt.Fprintf(&b, "    %s {\n", table.Name)
+		for _, column := range table.Columns {
+			var keys []string
+			if column.PrimaryKey {
+				keys = append(keys, "PK")
+			}
+			if fkColumns[column.Name] {
+				keys = append(keys, "FK")
+			}
+			typ := mermaidTypeReplacer.Replace(column.Type)
+			if typ == "" {
+				typ = "unknown"
+			}
+			fmt.Fprintf(&b, "        %s %s", typ, column.Name)
+			if len(keys) > 0 {
+				fmt.Fprintf(&b, " %s", strings.Join(keys, ","))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, fk := range model.ForeignKeys {
+		fmt.Fprintf(&b, "    %s }o--|| %s : %q\n", fk.Table, fk.RefTable, strings.Join(fk.Columns, ", "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderPlantUML(w io.Writer, model ERModel) error {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	for _, table := range model.Tables {
+		fkColumns := model.foreignKeyColumns(table.Name)
+		fmt.Fprintf(&b, "entity %s {\n", table.Name)
+		for _, column := range table.Columns {
+			mandatory := ""
+			if !column.Nullable {
+				mandatory = "* "
+			}
+			fmt.Fprintf(&b, "  %s%s : %s", mandatory, column.Name, column.Type)
+			if column.PrimaryKey {
+				b.WriteString(" <<PK>>")
+			}
+			if fkColumns[column.Name] {
+				b.WriteString(" <<FK>>")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n")
+	}
+	for _, fk := range model.ForeignKeys {
+		fmt.Fprintf(&b, "%s }o--|| %s : %s\n", fk.Table, fk.RefTable, strings.Join(fk.Columns, ", "))
+	}
+	b.WriteString("@enduml\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotRecordEscaper escapes the characters with a meaning in the labels of
+// Graphviz record nodes.
+var dotRecordEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`)
+
+func renderDOT(w io.Writer, model ERModel) error {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=record];\n")
+	for _, table := range model.Tables {
+		fkColumns := model.foreignKeyColumns(table.Name)
+		fields := make([]string, 0, len(table.Columns))
+		for _, column := range table.Columns {
+			field := column.Name + " : " + column.Type
+			if column.PrimaryKey {
+				field += " PK"
+			}
+			if fkColumns[column.Name] {
+				field += " FK"
+			}
+			fields = append(fields, dotRecordEscaper.Replace(field)+`\l`)
+		}
+		fmt.Fprintf(&b, "  %q [label=\"{%s|%s}\"];\n", table.Name, dotRecordEscaper.Replace(table.Name), strings.Join(fields, ""))
+	}
+	for _, fk := range model.ForeignKeys {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", fk.Table, fk.RefTable, strings.Join(fk.Columns, ", "))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,140 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqliteDriver_InspectER(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "er.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	assert.NoError(t, driver.CreateMigrationsTable(ctx))
+	_, err = driver.db.ExecContext(ctx, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, email VARCHAR(255) NOT NULL);
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users, title TEXT);
+	`)
+	assert.NoError(t, err)
+
+	model, err := driver.InspectER(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, ERModel{
+		Tables: []ERTable{
+			{Name: "posts", Columns: []ERColumn{
+				{Name: "id", Type: "INTEGER", Nullable: true, PrimaryKey: true},
+				{Name: "user_id", Type: "INTEGER"},
+				{Name: "title", Type: "TEXT", Nullable: true},
+			}},
+			{Name: "users", Columns: []ERColumn{
+				{Name: "id", Type: "INTEGER", Nullable: true, PrimaryKey: true},
+				{Name: "email", Type: "VARCHAR(255)"},
+			}},
+		},
+		ForeignKeys: []ERForeignKey{
+			{Name: "0", Table: "posts", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}},
+		},
+	}, model)
+}
+
+func TestERModel_UpScript(t *testing.T) {
+	model := ERModel{
+		Tables: []ERTable{
+			{Name: "posts", Columns: []ERColumn{
+				{Name: "id", Type: "INT", PrimaryKey: true},
+				{Name: "user_id", Type: "INT"},
+			}},
+			{Name: "users", Columns: []ERColumn{
+				{Name: "id", Type: "INT", PrimaryKey: true},
+				{Name: "email", Type: "VARCHAR(255)", Nullable: true},
+			}},
+		},
+		ForeignKeys: []ERForeignKey{
+			{Name: "posts_user_id_fkey", Table: "posts", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}},
+		},
+	}
+
+	assert.Equal(t, "CREATE TABLE `users` (\n\t`id` INT NOT NULL,\n\t`email` VARCHAR(255),\n\tPRIMARY KEY (`id`)\n);\n\n"+
+		"CREATE TABLE `posts` (\n\t`id` INT NOT NULL,\n\t`user_id` INT NOT NULL,\n\tPRIMARY KEY (`id`),\n\tFOREIGN KEY (`user_id`) REFERENCES `users` (`id`)\n);", model.upScript(DialectMySQL))
+	assert.Equal(t, "DROP TABLE `posts`;\nDROP TABLE `users`;", model.downScript(DialectMySQL))
+
+	// The script creates the same model again.
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "er.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	_, err = driver.db.ExecContext(ctx, model.upScript(DialectSQLite))
+	assert.NoError(t, err)
+	inspected, err := driver.InspectER(ctx)
+	assert.NoError(t, err)
+	model.ForeignKeys[0].Name = "0"
+	assert.Equal(t, model, inspected)
+}
+
+func TestGoMigration_Diagram(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "diagram.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	assert.NoError(t, driver.CreateMigrationsTable(ctx))
+	_, err = driver.db.ExecContext(ctx, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id));
+	`)
+	assert.NoError(t, err)
+
+	q := &GoMigration{driver: driver, migrations: map[string]Migration{
+		"20250101000000_create_users": &scriptMigration{name: "20250101000000_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);"},
+		"20250102000000_create_posts": &scriptMigration{name: "20250102000000_create_posts", upScript: "CREATE TABLE IF NOT EXISTS posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id));"},
+	}}
+
+	var out bytes.Buffer
+	assert.NoError(t, q.Diagram(ctx, &out, DiagramOptions{}))
+	assert.Equal(t, `erDiagram
+    posts {
+        INTEGER id PK
+        INTEGER user_id FK
+    }
+    users {
+        INTEGER id PK
+        TEXT email
+    }
+    posts }o--|| users : "user_id"
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, q.Diagram(ctx, &out, DiagramOptions{Format: DiagramPlantUML, From: "20250102000000_create_posts"}))
+	assert.Equal(t, `@startuml
+entity posts {
+  id : INTEGER <<PK>>
+  * user_id : INTEGER <<FK>>
+}
+posts }o--|| users : user_id
+@enduml
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, q.Diagram(ctx, &out, DiagramOptions{Format: DiagramDOT, To: "20250101000000_create_users"}))
+	assert.Equal(t, `digraph schema {
+  rankdir=LR;
+  node [shape=record];
+  "users" [label="{users|id : INTEGER PK\lemail : TEXT\l}"];
+}
+`, out.String())
+
+	err = q.Diagram(ctx, &out, DiagramOptions{Format: "svg"})
+	assert.ErrorIs(t, err, ErrInvalidDiagramFormat)
+
+	err = q.Diagram(ctx, &out, DiagramOptions{From: "unknown"})
+	assert.ErrorIs(t, err, ErrMigrationNotRegistered)
+
+	q = &GoMigration{driver: &mockDriver{}}
+	assert.ErrorIs(t, q.Diagram(ctx, &out, DiagramOptions{}), ErrDiagramNotSupported)
+}
@@ -2,6 +2,21 @@ package gomigration
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/ncruces/go-sqlite3"
 )
 
 // Driver defines the contract for a migration driver implementation.
@@ -9,17 +24,28 @@ type Driver interface {
 	// SetMigrationTableName sets the name of the table that stores executed migration records.
 	SetMigrationTableName(name string)
 
-	// CreateMigrationsTable creates the migration history table if it does not already exist.
+	// CreateMigrationsTable creates the migration history table if it does not already exist
+	// and adds any columns introduced since it was created.
 	CreateMigrationsTable(ctx context.Context) error
 
-	// GetExecutedMigrations returns the list of already executed migrations.
-	// If reverse is true, the list is returned in descending order (most recent first).
-	GetExecutedMigrations(ctx context.Context, reverse bool) ([]ExecutedMigration, error)
+	// GetExecutedMigrations returns the list of already executed migrations
+	// sorted according to order.
+	GetExecutedMigrations(ctx context.Context, order HistoryOrder) ([]ExecutedMigration, error)
+
+	// IterateExecutedMigrations streams the executed migrations sorted according
+	// to order, calling fn for each one without loading the whole history into
+	// memory. Iteration stops at the first error returned by fn.
+	IterateExecutedMigrations(ctx context.Context, order HistoryOrder, fn func(migration ExecutedMigration) error) error
+
+	// GetExecutedMigrationsPage returns at most limit executed migrations sorted
+	// according to order, skipping the first offset ones.
+	GetExecutedMigrationsPage(ctx context.Context, order HistoryOrder, limit, offset int) ([]ExecutedMigration, error)
 
 	// CleanDatabase drops or truncates all user tables in the database.
 	CleanDatabase(ctx context.Context) error
 
-	// ApplyMigrations applies a list of "up" migrations in sequence.
+	// ApplyMigrations applies a list of "up" migrations in sequence, recording
+	// them all under the batch number following the last one recorded.
 	// The onRunning, onSuccess, and onFailed callbacks are triggered accordingly for each migration.
 	ApplyMigrations(
 		ctx context.Context,
@@ -39,6 +65,1245 @@ type Driver interface {
 		onFailed func(migration *Migration, err error),
 	) error
 
+	// AcquireLock obtains an exclusive, database-wide migration lock, blocking
+	// until it is available or ctx is done. It prevents concurrent processes
+	// from running migrations against the same tracking table.
+	AcquireLock(ctx context.Context) error
+
+	// ReleaseLock releases the lock obtained by AcquireLock.
+	ReleaseLock(ctx context.Context) error
+
 	// Close gracefully closes the connection to the database or releases resources.
 	Close() error
 }
+
+// HistoryWriter is implemented by drivers that can write the tracking table
+// without running migrations. MarkApplied, MarkUnapplied, Repair, Squash,
+// NamespaceHistory, ImportHistory, the MigrateFrom methods and
+// FreshWithOptions keeping the history need it, and fail with a
+// *CapabilityError without it. The built-in drivers implement it.
+type HistoryWriter interface {
+	// InsertExecutedMigration records a migration as executed without running
+	// its up script.
+	InsertExecutedMigration(ctx context.Context, record ExecutedMigration) error
+
+	// UpdateExecutedMigration overwrites the execution time and checksum of the
+	// record with the same name.
+	UpdateExecutedMigration(ctx context.Context, record ExecutedMigration) error
+
+	// RemoveExecutedMigration deletes the record of the named migration without
+	// running its down script.
+	RemoveExecutedMigration(ctx context.Context, name string) error
+}
+
+// TrackingTableUpgrader is implemented by drivers that can bring a tracking
+// table created by an older version up to date, as UpgradeTrackingTable
+// needs. The built-in drivers implement it.
+type TrackingTableUpgrader interface {
+	// UpgradeMigrationsTable brings a tracking table created by an older version
+	// up to date with the indexes and constraints of new tables. Building them
+	// may lock a large table for a while, so it is an explicit step.
+	UpgradeMigrationsTable(ctx context.Context) error
+}
+
+// Dialect identifies the SQL dialect of a built-in driver.
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// dialects are the dialects of the built-in drivers.
+var dialects = []Dialect{DialectPostgres, DialectMySQL, DialectSQLite}
+
+// dialectDriver is implemented by the built-in drivers, so the engine shows
+// the DialectMigration scripts they run in dry runs and plans.
+type dialectDriver interface {
+	dialect() Dialect
+}
+
+// trackingTableDriver is implemented by the built-in drivers, so operations
+// that only read can tell a tracking table not created yet from a failing read,
+// and plans can create it.
+type trackingTableDriver interface {
+	trackingTableExists(ctx context.Context) (bool, error)
+	// trackingTableDDL returns the statements creating the tracking table
+	// with its columns and indexes.
+	trackingTableDDL() []string
+}
+
+// squashingDriver is implemented by the built-in drivers, so Squash replaces
+// the records of the squashed migrations with that of their baseline in a
+// single transaction.
+type squashingDriver interface {
+	squashExecutedMigrations(ctx context.Context, squashed []string, baseline ExecutedMigration) error
+}
+
+// tableQuotingDriver is implemented by the built-in drivers, so plans record
+// migrations in the tracking table quoted the way the driver quotes it.
+type tableQuotingDriver interface {
+	quotedTableName() string
+}
+
+// StatementRewriter is called by the built-in drivers with every statement of
+// a migration script right before it is executed, and returns the SQL to
+// execute instead, e.g. with a /* ticket:JIRA-123 */ comment prepended or
+// ALGORITHM=INPLACE enforced on MySQL ALTERs. Returning an error fails the
+// migration.
+//
+// MySQL, and Postgres outside of a transaction, execute a script a statement
+// at a time, so each statement is rewritten on its own, after templates and
+// environment variables are expanded. SQLite, and Postgres in a transaction,
+// execute a script in a single Exec, so the script is rewritten as a whole.
+// Checksums are computed from the original script.
+type StatementRewriter func(migration string, dialect Dialect, sql string) (string, error)
+
+// configurableDriver is implemented by the built-in drivers so New can pass
+// them the Config options they support.
+type configurableDriver interface {
+	configure(config *Config)
+}
+
+// ManifestStore is implemented by drivers that can store a hash of the
+// registered migrations next to the tracking table. HasPending compares it
+// against the local manifest to skip reading the history when nothing changed.
+type ManifestStore interface {
+	// GetManifestHash returns the stored manifest hash, empty if none is stored.
+	GetManifestHash(ctx context.Context) (string, error)
+
+	// SetManifestHash stores hash, or removes the stored one when hash is empty.
+	SetManifestHash(ctx context.Context, hash string) error
+}
+
+// Pinger is implemented by drivers that can check their database is reachable
+// without reading the tracking table. The built-in drivers do. It backs
+// GoMigration.Ping.
+type Pinger interface {
+	// Ping verifies a connection to the database is still alive.
+	Ping(ctx context.Context) error
+}
+
+// AuditLogger is implemented by drivers that can keep an append-only audit
+// log of migration operations next to the tracking table. It is required by
+// Config.AuditLog.
+type AuditLogger interface {
+	// AppendAuditEvent appends event to the audit log.
+	AppendAuditEvent(ctx context.Context, event AuditEvent) error
+}
+
+// ChunkedCleaner is implemented by drivers that drop the tables of
+// CleanDatabase a chunk at a time, so huge schemas stay within statement
+// limits and timeouts. The built-in drivers do. Chunks already dropped stay
+// dropped when a later one fails, so cleaning again resumes where it stopped.
+type ChunkedCleaner interface {
+	// CleanDatabaseChunked drops the tables CleanDatabase drops, in chunks of
+	// Config.CleanBatchSize tables, calling onChunk, if not nil, after each
+	// chunk with its tables and how many of total are dropped so far. With
+	// opts.KeepHistory it leaves the tracking table and its audit log.
+	CleanDatabaseChunked(ctx context.Context, opts CleanOptions, onChunk func(tables []string, dropped, total int)) error
+}
+
+// TenantDriver is implemented by drivers that can connect to the schema or
+// database of a tenant. The built-in drivers do: Postgres uses a schema and
+// MySQL a database named after the tenant, both created if missing, and SQLite
+// a database file next to its own, e.g. app_acme.db for tenant acme.
+type TenantDriver interface {
+	// ForTenant returns a driver configured like this one, whose migrations
+	// and tracking table live in the schema or database of tenant. The caller
+	// closes it.
+	ForTenant(ctx context.Context, tenant string) (Driver, error)
+}
+
+// execer is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// statementRecorder is an execer that records the progress of a migration
+// run through it.
+type statementRecorder struct {
+	execer
+	progress *migrationProgress
+	// retry retries statements failing with transient errors, for statements
+	// run outside of transactions that apply all or nothing.
+	retry  RetryPolicy
+	logger runLogger
+}
+
+func (r *statementRecorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	exec := func() (sql.Result, error) {
+		started := time.Now()
+		res, err := r.execer.ExecContext(ctx, query, args...)
+		r.logger.statement(query, time.Since(started), res, err)
+		return res, err
+	}
+
+	res, err := exec()
+	for attempt := 0; err != nil && attempt < r.retry.Attempts && isTransientError(err); attempt++ {
+		r.logger.warn(fmt.Sprintf("🔁 Retrying statement after transient error: %v", err), "retrying statement after transient error", "error", err)
+		if r.retry.wait(ctx, attempt) != nil {
+			break
+		}
+		res, err = exec()
+	}
+	if err != nil {
+		r.progress.failed(ctx, query)
+		return res, err
+	}
+
+	r.progress.succeeded(ctx)
+	return res, nil
+}
+
+// migrationProgress follows a migration run statement by statement, to tell
+// where it stood when a deadline stopped it.
+type migrationProgress struct {
+	started time.Time
+	// ran reports whether any statement succeeded.
+	ran bool
+	// tracked reports whether the tracking table was updated and the update
+	// was kept.
+	tracked bool
+	// statements is the number of statements of the script that succeeded.
+	statements int
+	// statement is the statement that failed, and deadline the deadline it
+	// ran under, if any. index is its position in the script, from 1, or 0
+	// for a statement of the tracking table.
+	statement string
+	index     int
+	deadline  time.Time
+	// expired reports whether the statement failed because its deadline
+	// passed.
+	expired bool
+}
+
+// attempt starts another attempt at the migration, forgetting where the
+// previous one failed.
+func (p *migrationProgress) attempt() {
+	p.statements = 0
+	p.statement, p.index = "", 0
+	p.deadline, p.expired = time.Time{}, false
+}
+
+// succeeded records that statement succeeded under ctx.
+func (p *migrationProgress) succeeded(ctx context.Context) {
+	p.ran = true
+	if ctx.Value(trackingStatementKey{}) != nil {
+		p.tracked = true
+	} else {
+		p.statements++
+	}
+}
+
+// failed records that statement failed under ctx.
+func (p *migrationProgress) failed(ctx context.Context, statement string) {
+	p.statement = statement
+	p.index = 0
+	if ctx.Value(trackingStatementKey{}) == nil {
+		p.index = p.statements + 1
+	}
+	p.deadline, _ = ctx.Deadline()
+	p.expired = errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// migrationError returns err as a *MigrationError of m, telling the statement
+// that failed, if any. Its direction is left to the caller.
+func (p *migrationProgress) migrationError(m Migration, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &MigrationError{Migration: m.Name(), Statement: p.statement, StatementIndex: p.index, Err: err}
+}
+
+// deadlineError returns err as a *DeadlineError when a deadline stopped m,
+// err itself otherwise.
+func (p *migrationProgress) deadlineError(m Migration, err error) error {
+	if err == nil || !p.expired {
+		return err
+	}
+
+	deadlineErr := &DeadlineError{
+		Migration:       m.Name(),
+		Statement:       p.statement,
+		Elapsed:         time.Since(p.started),
+		TrackingUpdated: p.tracked,
+		Err:             err,
+	}
+	if !p.deadline.IsZero() {
+		deadlineErr.Allowed = p.deadline.Sub(p.started)
+	}
+	return deadlineErr
+}
+
+// trackingStatementKey marks the context of tracking table statements.
+type trackingStatementKey struct{}
+
+// isConnectionError reports whether err means the connection to the database
+// was lost, rather than the database rejecting the statement.
+func isConnectionError(err error) bool {
+	// Context errors satisfy net.Error but mean the caller gave up.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isTransientError reports whether err is a failure that may not happen again
+// when the statement is retried, such as a deadlock.
+func isTransientError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_LOCK_DEADLOCK and ER_LOCK_WAIT_TIMEOUT.
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40P01", "40001", "55P03": // deadlock_detected, serialization_failure, lock_not_available
+			return true
+		}
+		return false
+	}
+
+	return errors.Is(err, sqlite3.BUSY) || errors.Is(err, sqlite3.LOCKED)
+}
+
+// connectingDriver is implemented by drivers that can wait for their database
+// to accept connections.
+type connectingDriver interface {
+	waitForDatabase(ctx context.Context, policy ConnectRetryPolicy) error
+}
+
+// connectPingTimeout bounds each ping of pingWithRetry, so a database that
+// accepts TCP connections but never answers does not hang the constructors
+// and New, which have no context to cancel.
+const connectPingTimeout = 10 * time.Second
+
+// pingWithRetry pings db, retrying as policy says while it fails. Each ping
+// gives up after connectPingTimeout, so the wait is bounded by the policy even
+// when ctx is never canceled.
+func pingWithRetry(ctx context.Context, db *sql.DB, policy ConnectRetryPolicy, logger runLogger) error {
+	ping := func() error {
+		ctx, cancel := context.WithTimeout(ctx, connectPingTimeout)
+		defer cancel()
+		return db.PingContext(ctx)
+	}
+
+	err := ping()
+	for attempt := 0; err != nil && attempt < policy.Attempts; attempt++ {
+		logger.warn(fmt.Sprintf("⏳ Database not reachable, retrying in %s: %v", policy.Interval, err), "database not reachable, retrying", "interval", policy.Interval, "error", err)
+		timer := time.NewTimer(policy.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = ping()
+	}
+	return err
+}
+
+// lockPollInterval is how often drivers without blocking lock primitives retry
+// acquiring the migration lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// defaultCleanBatchSize is the number of tables CleanDatabase drops per chunk
+// unless Config.CleanBatchSize says otherwise.
+const defaultCleanBatchSize = 100
+
+// migrationLockName derives the lock name used by a driver from its tracking
+// table and lock scope, if any.
+func migrationLockName(migrationTableName, scope string) string {
+	if scope == "" {
+		return "gomigration:" + migrationTableName
+	}
+	return "gomigration:" + migrationTableName + ":" + scope
+}
+
+// historyOrderClause returns the ORDER BY expression implementing the given
+// history order. Application order is the execution time, ties broken by name.
+func historyOrderClause(order HistoryOrder) string {
+	switch order {
+	case HistoryOrderAppliedDesc:
+		return "executed_at DESC, name DESC"
+	case HistoryOrderName:
+		return "name ASC"
+	case HistoryOrderNameDesc:
+		return "name DESC"
+	default:
+		return "executed_at ASC, name ASC"
+	}
+}
+
+// executedMigrationColumns is the column list selected from the tracking table,
+// matching the scan order of scanExecutedMigrations.
+const executedMigrationColumns = "name, executed_at, checksum, batch, applied_by, namespace, build, skipped"
+
+// trackingColumn is a column added to the tracking table after its first release.
+type trackingColumn struct {
+	name       string
+	definition string
+}
+
+// trackingTableColumns lists the columns drivers add to existing tracking
+// tables, in order. Definitions must be valid for every built-in dialect.
+var trackingTableColumns = []trackingColumn{
+	{name: "checksum", definition: "VARCHAR(64) CHECK (checksum IS NULL OR LENGTH(checksum) = 64)"},
+	{name: "batch", definition: "INTEGER"},
+	{name: "applied_by", definition: "VARCHAR(255)"},
+	{name: "namespace", definition: "VARCHAR(255)"},
+	{name: "build", definition: "TEXT"},
+	{name: "skipped", definition: "BOOLEAN"},
+}
+
+// trackingIndex is a secondary index of the tracking table, named after the
+// table with suffix appended.
+type trackingIndex struct {
+	suffix  string
+	columns string
+}
+
+// trackingTableIndexes lists the secondary indexes of the tracking table.
+var trackingTableIndexes = []trackingIndex{
+	{suffix: "executed_at_idx", columns: "executed_at, name"},
+}
+
+// addTrackingColumns adds the columns of trackingTableColumns missing from the
+// tracking table, given the names of its existing columns.
+func addTrackingColumns(ctx context.Context, ex execer, dialect Dialect, table string, existing map[string]bool) error {
+	for _, c := range trackingTableColumns {
+		if existing[c.name] {
+			continue
+		}
+		query := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, quoteIdentifier(dialect, table), c.name, c.definition)
+		if _, err := ex.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to add column %s to %s: %w", c.name, table, err)
+		}
+	}
+	return nil
+}
+
+// addTrackingIndexes creates the indexes of trackingTableIndexes missing from
+// the tracking table, given the names of its existing indexes.
+func addTrackingIndexes(ctx context.Context, ex execer, dialect Dialect, table string, existing map[string]bool) error {
+	for _, idx := range trackingTableIndexes {
+		name, query := trackingIndexStatement(dialect, table, idx)
+		if existing[name] {
+			continue
+		}
+		if _, err := ex.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create index %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// trackingIndexStatement returns the unqualified name of the index idx of
+// table and the statement creating it. Indexes live in the schema of their
+// table: SQLite qualifies the index with it, the others the table.
+func trackingIndexStatement(dialect Dialect, table string, idx trackingIndex) (name, query string) {
+	schema, unqualified := splitTableName(table)
+	name = unqualified + "_" + idx.suffix
+	index, on := name, quoteIdentifier(dialect, table)
+	if dialect == DialectSQLite && schema != "" {
+		index, on = quoteIdentifier(dialect, schema)+"."+name, quoteIdentifier(dialect, unqualified)
+	}
+	return name, fmt.Sprintf(`CREATE INDEX %s ON %s (%s)`, index, on, idx.columns)
+}
+
+// trackingTableStatements returns the statements creating table as a tracking
+// table of the current version, with the columns of trackingTableColumns and
+// the indexes of trackingTableIndexes.
+func trackingTableStatements(dialect Dialect, table string) []string {
+	columns := []string{
+		"name VARCHAR(255) PRIMARY KEY NOT NULL CHECK (name <> '')",
+		"executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
+	}
+	for _, c := range trackingTableColumns {
+		columns = append(columns, c.name+" "+c.definition)
+	}
+
+	statements := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", quoteIdentifier(dialect, table), strings.Join(columns, ",\n\t"))}
+	for _, idx := range trackingTableIndexes {
+		_, query := trackingIndexStatement(dialect, table, idx)
+		statements = append(statements, query)
+	}
+	return statements
+}
+
+// manifestTableName returns the single-row table holding the manifest hash of
+// the given tracking table.
+func manifestTableName(table string) string {
+	return table + "_manifest"
+}
+
+// getManifestHash reads the manifest hash stored for table.
+func getManifestHash(ctx context.Context, db *sql.DB, dialect Dialect, table string) (string, error) {
+	var hash string
+	query := fmt.Sprintf(`SELECT manifest_hash FROM %s WHERE id = 1`, quoteIdentifier(dialect, manifestTableName(table)))
+	err := db.QueryRowContext(ctx, query).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return hash, err
+}
+
+// setManifestHash stores the manifest hash of table with upsert, a dialect
+// specific statement taking the table name and the hash as its only argument.
+// An empty hash deletes the stored one.
+func setManifestHash(ctx context.Context, db *sql.DB, dialect Dialect, table, upsert, hash string) error {
+	manifestTable := manifestTableName(table)
+	quoted := quoteIdentifier(dialect, manifestTable)
+
+	create := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (id INTEGER PRIMARY KEY, manifest_hash VARCHAR(64) NOT NULL)`, quoted)
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create %s: %w", manifestTable, err)
+	}
+
+	if hash == "" {
+		_, err := db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s`, quoted))
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(upsert, quoted), hash)
+	return err
+}
+
+// auditTableName returns the name of the audit log table kept for table.
+func auditTableName(table string) string {
+	return table + "_audit"
+}
+
+// appendAuditEvent appends event to the audit log of table with insert, a
+// dialect specific statement taking the table name and the occurred_at,
+// operation, actor, migrations, outcome and error columns as arguments.
+func appendAuditEvent(ctx context.Context, db *sql.DB, dialect Dialect, table, insert string, event AuditEvent) error {
+	auditTable := auditTableName(table)
+	quoted := quoteIdentifier(dialect, auditTable)
+
+	create := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		occurred_at TIMESTAMP NOT NULL,
+		operation VARCHAR(32) NOT NULL,
+		actor VARCHAR(255),
+		migrations TEXT,
+		outcome VARCHAR(16) NOT NULL,
+		error TEXT
+	)`, quoted)
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create %s: %w", auditTable, err)
+	}
+
+	_, err := db.ExecContext(
+		ctx,
+		fmt.Sprintf(insert, quoted),
+		event.Time,
+		string(event.Operation),
+		nullableString(event.Actor),
+		nullableString(strings.Join(event.Migrations, ",")),
+		string(event.Outcome),
+		nullableString(event.Error),
+	)
+	return err
+}
+
+// queryNameSet runs a catalog query returning a single name column and
+// collects the lower-cased names.
+func queryNameSet(ctx context.Context, db *sql.DB, query string, args ...any) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[strings.ToLower(name)] = true
+	}
+
+	return names, rows.Err()
+}
+
+// nullableString maps an empty string to NULL.
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// failBatch handles the failure of a migration in a batch. Under FailureStop
+// it returns the error of the failure. Under FailureContinue it adds the
+// failure to failures and returns nil so the batch goes on, or failures once
+// ctx is done.
+func (o *driverOptions) failBatch(ctx context.Context, failures *BatchError, failure BatchFailure) error {
+	if o.failurePolicy != FailureContinue {
+		return failure.Err
+	}
+	failures.Failures = append(failures.Failures, failure)
+	if ctx.Err() != nil {
+		return failures
+	}
+	return nil
+}
+
+// nullableBuild maps build to its JSON encoding, nil to NULL.
+func nullableBuild(build *BuildInfo) sql.NullString {
+	if build == nil {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(build)
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// nullableBool maps false to NULL.
+func nullableBool(b bool) sql.NullBool {
+	return sql.NullBool{Bool: b, Valid: b}
+}
+
+// nullableInt maps zero to NULL.
+func nullableInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
+
+// scanExecutedMigrations calls fn for every row of a tracking table query
+// selecting executedMigrationColumns, closing rows when done.
+func scanExecutedMigrations(rows *sql.Rows, fn func(migration ExecutedMigration) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var m ExecutedMigration
+		var executedAt sql.NullTime
+		var checksum sql.NullString
+		var batch sql.NullInt64
+		var appliedBy sql.NullString
+		var namespace sql.NullString
+		var build sql.NullString
+		var skipped sql.NullBool
+		if err := rows.Scan(&m.Name, &executedAt, &checksum, &batch, &appliedBy, &namespace, &build, &skipped); err != nil {
+			return err
+		}
+		m.ExecutedAt = executedAt.Time
+		m.Checksum = checksum.String
+		m.Batch = int(batch.Int64)
+		m.AppliedBy = appliedBy.String
+		m.Namespace = namespace.String
+		m.Skipped = skipped.Bool
+		if build.Valid {
+			m.Build = &BuildInfo{}
+			if err := json.Unmarshal([]byte(build.String), m.Build); err != nil {
+				return fmt.Errorf("invalid build of migration %s: %w", m.Name, err)
+			}
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// collectExecutedMigrations gathers the executed migrations streamed by iterate into a slice.
+func collectExecutedMigrations(iterate func(fn func(migration ExecutedMigration) error) error) ([]ExecutedMigration, error) {
+	var migrations []ExecutedMigration
+	err := iterate(func(m ExecutedMigration) error {
+		migrations = append(migrations, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+// driverOptions holds the settings shared by the built-in drivers.
+type driverOptions struct {
+	useTransactions      bool
+	credentialRefresher  CredentialRefresher
+	trackingTimeout      time.Duration
+	statementTimeout     time.Duration
+	appliedBy            string
+	build                *BuildInfo
+	auditLog             bool
+	statementRewriter    StatementRewriter
+	templateData         map[string]any
+	envExpansion         EnvExpansion
+	maxMigrationDuration time.Duration
+	implicitCommitPolicy ImplicitCommitPolicy
+	failurePolicy        FailurePolicy
+	lockScope            string
+	sqliteFileLock       bool
+	cleanBatchSize       int
+	cleanParallelism     int
+	retry                RetryPolicy
+	// atomicStatements is set by drivers whose statements, and multiple
+	// statement scripts, apply all or nothing outside of transactions, so
+	// they can be retried in place.
+	atomicStatements bool
+	// sessionSettings are set on the connection of each migration by drivers
+	// with session-level timeouts, from the lock and statement timeouts.
+	sessionSettings []sessionSetting
+	logger          runLogger
+}
+
+// sessionSetting is a setting of the connection a migration runs on, with the
+// statements setting it and restoring the server default.
+type sessionSetting struct {
+	set   string
+	reset string
+}
+
+// configure copies the relevant Config fields into the driver options.
+func (o *driverOptions) configure(config *Config) {
+	o.useTransactions = config.UseTransactions
+	o.credentialRefresher = config.CredentialRefresher
+	o.trackingTimeout = config.TrackingTimeout
+	o.statementTimeout = config.StatementTimeout
+	o.appliedBy = config.AppliedBy
+	o.build = config.Build.orNil()
+	o.auditLog = config.AuditLog
+	o.statementRewriter = config.StatementRewriter
+	o.templateData = config.TemplateData
+	o.envExpansion = config.EnvExpansion
+	o.maxMigrationDuration = config.MaxMigrationDuration
+	o.implicitCommitPolicy = config.ImplicitCommitPolicy
+	o.failurePolicy = config.FailurePolicy
+	o.lockScope = config.LockScope
+	o.sqliteFileLock = config.SqliteFileLock
+	o.cleanBatchSize = config.CleanBatchSize
+	o.cleanParallelism = config.CleanParallelism
+	o.retry = config.Retry
+	o.sessionSettings = nil
+}
+
+// setLogger makes the driver write its log to logger, that of GoMigration.
+func (o *driverOptions) setLogger(logger runLogger) {
+	o.logger = logger
+}
+
+// trackingContext bounds a read or write of the tracking table by the
+// tracking timeout.
+func (o *driverOptions) trackingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withOptionalTimeout(context.WithValue(ctx, trackingStatementKey{}, true), o.trackingTimeout)
+}
+
+// trackingQueryContext bounds the start of a streaming read of the tracking
+// table by the tracking timeout. Calling started once the query returned lifts
+// the bound, so iterating its rows takes as long as the caller needs.
+func (o *driverOptions) trackingQueryContext(ctx context.Context) (_ context.Context, started func(), cancel context.CancelFunc) {
+	ctx, cancel = context.WithCancel(context.WithValue(ctx, trackingStatementKey{}, true))
+	if o.trackingTimeout <= 0 {
+		return ctx, func() {}, cancel
+	}
+	timer := time.AfterFunc(o.trackingTimeout, cancel)
+	return ctx, func() { timer.Stop() }, cancel
+}
+
+// statementContext bounds a migration script by the statement timeout.
+func (o *driverOptions) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withOptionalTimeout(ctx, o.statementTimeout)
+}
+
+// setSession applies the session settings to conn, returning a function
+// restoring them, so connections returned to the pool do not keep them.
+func (o *driverOptions) setSession(ctx context.Context, conn execer) (func(), error) {
+	var applied []sessionSetting
+	restore := func() {
+		ctx, cancel := withOptionalTimeout(context.WithoutCancel(ctx), o.trackingTimeout)
+		defer cancel()
+		for _, setting := range applied {
+			if _, err := conn.ExecContext(ctx, setting.reset); err != nil {
+				o.logger.warn(fmt.Sprintf("⚠️ Failed to restore session setting (%s): %v", setting.reset, err), "failed to restore session setting", "statement", setting.reset, "error", err)
+			}
+		}
+	}
+
+	for _, setting := range o.sessionSettings {
+		setCtx, cancel := withOptionalTimeout(ctx, o.trackingTimeout)
+		_, err := conn.ExecContext(setCtx, setting.set)
+		cancel()
+		if err != nil {
+			restore()
+			return nil, fmt.Errorf("failed to set session setting (%s): %w", setting.set, err)
+		}
+		applied = append(applied, setting)
+	}
+	return restore, nil
+}
+
+// ceilUnits returns d in whole units, rounded up, for settings that cannot
+// be finer than unit.
+func ceilUnits(d, unit time.Duration) int64 {
+	return int64((d + unit - 1) / unit)
+}
+
+// withOptionalTimeout applies timeout to ctx unless it is not positive.
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryCanceler asks the server to cancel the statement running on a
+// connection, for clients that give up on a statement when its context is done
+// without stopping it on the server.
+type queryCanceler struct {
+	// idQuery returns the server-side ID of the connection it runs on.
+	idQuery string
+	// cancel cancels the statement running on the connection with the ID
+	// given as its only format argument.
+	cancel string
+}
+
+// migrationConn is satisfied by *sql.DB and *sql.Conn.
+type migrationConn interface {
+	execer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// maxDuration returns how long m may run, 0 if it is not time-boxed.
+func (o *driverOptions) maxDuration(m Migration) time.Duration {
+	if timeBoxed, ok := m.(TimeBoxedMigration); ok && timeBoxed.MaxDuration() > 0 {
+		return timeBoxed.MaxDuration()
+	}
+	return o.maxMigrationDuration
+}
+
+// runMigration calls fn inside a transaction when transactions are enabled and
+// the migration did not opt out, otherwise fn runs directly against db. If the
+// connection turns out to be dead before any statement succeeded, nothing has
+// been applied yet, so fn is retried once on a fresh connection.
+//
+// A time-boxed migration is cancelled once it exceeds its maximum duration,
+// with canceler, if not nil, stopping the statement on the server, and fails
+// with ErrMigrationTimedOut. Its transaction, if any, is rolled back. A
+// migration stopped by any deadline fails with a *DeadlineError.
+func (o *driverOptions) runMigration(
+	ctx context.Context,
+	db *sql.DB,
+	m Migration,
+	canceler *queryCanceler,
+	fn func(ctx context.Context, ex execer) error,
+) error {
+	progress := &migrationProgress{started: time.Now()}
+
+	maxDuration := o.maxDuration(m)
+	if maxDuration <= 0 {
+		err := o.runMigrationRetrying(ctx, db, m, nil, progress, fn)
+		return progress.migrationError(m, progress.deadlineError(m, err))
+	}
+
+	timedOut := fmt.Errorf("%w: %s ran longer than %s", ErrMigrationTimedOut, m.Name(), maxDuration)
+	ctx, cancel := context.WithTimeoutCause(ctx, maxDuration, timedOut)
+	defer cancel()
+
+	err := o.runMigrationRetrying(ctx, db, m, canceler, progress, fn)
+	if err != nil && errors.Is(context.Cause(ctx), ErrMigrationTimedOut) {
+		err = timedOut
+	}
+	return progress.migrationError(m, progress.deadlineError(m, err))
+}
+
+// migrationFailure returns err, the failure of m run in direction, as a
+// *MigrationError.
+func migrationFailure(m Migration, direction Direction, err error) error {
+	var migrationErr *MigrationError
+	if errors.As(err, &migrationErr) && migrationErr.Migration == m.Name() {
+		migrationErr.Direction = direction
+		return err
+	}
+	return &MigrationError{Migration: m.Name(), Direction: direction, Err: err}
+}
+
+// skipMigration asks m, if it is a ConditionalMigration, whether it should
+// run against d. When it should not, it is recorded in batch as skipped with
+// insert, against ex, and skipMigration returns true.
+func (o *driverOptions) skipMigration(
+	ctx context.Context,
+	d Driver,
+	ex execer,
+	m Migration,
+	batch int,
+	insert func(ctx context.Context, ex execer, record ExecutedMigration) error,
+) (bool, error) {
+	run, err := shouldRun(ctx, d, m)
+	if err != nil {
+		return false, fmt.Errorf("failed to check whether migration %s should run: %w", m.Name(), err)
+	}
+	if run {
+		return false, nil
+	}
+
+	if err := insert(ctx, ex, ExecutedMigration{
+		Name:       m.Name(),
+		ExecutedAt: time.Now(),
+		Checksum:   migrationChecksum(m),
+		Batch:      batch,
+		AppliedBy:  o.appliedBy,
+		Build:      o.build,
+		Namespace:  migrationSetOf(m),
+		Skipped:    true,
+	}); err != nil {
+		return false, fmt.Errorf("failed to record skipped migration %s: %w", m.Name(), err)
+	}
+	o.logger.info("⏭️  Skipped: "+m.Name(), "skipped migration", "migration", m.Name())
+	return true, nil
+}
+
+// runMigrationRetrying runs fn as described by runMigration, retrying once on
+// a fresh connection.
+func (o *driverOptions) runMigrationRetrying(
+	ctx context.Context,
+	db *sql.DB,
+	m Migration,
+	canceler *queryCanceler,
+	progress *migrationProgress,
+	fn func(ctx context.Context, ex execer) error,
+) error {
+	err := o.runMigrationOnce(ctx, db, m, canceler, progress, fn)
+	if err != nil && !progress.ran && isConnectionError(err) {
+		if perr := o.ensureConnection(ctx, db); perr != nil {
+			return err
+		}
+		err = o.runMigrationOnce(ctx, db, m, canceler, progress, fn)
+	}
+
+	// A failed transaction was rolled back, so it is retried as a whole.
+	// Statements outside of transactions were retried in place.
+	if !o.inTransaction(m) {
+		return err
+	}
+	for attempt := 0; err != nil && attempt < o.retry.Attempts && isTransientError(err); attempt++ {
+		o.logger.warn(fmt.Sprintf("🔁 Retrying migration %s after transient error: %v", m.Name(), err), "retrying migration after transient error", "migration", m.Name(), "error", err)
+		if o.retry.wait(ctx, attempt) != nil {
+			break
+		}
+		err = o.runMigrationOnce(ctx, db, m, canceler, progress, fn)
+	}
+	return err
+}
+
+// inTransaction reports whether m runs in a transaction.
+func (o *driverOptions) inTransaction(m Migration) bool {
+	return o.useTransactions && !isNonTransactional(m)
+}
+
+// runMigrationOnce runs fn as described by runMigration, without retrying,
+// recording its statements in progress. With session settings, fn runs on a
+// connection pinned for the migration, with the settings applied.
+func (o *driverOptions) runMigrationOnce(
+	ctx context.Context,
+	db *sql.DB,
+	m Migration,
+	canceler *queryCanceler,
+	progress *migrationProgress,
+	fn func(ctx context.Context, ex execer) error,
+) error {
+	progress.attempt()
+
+	var conn migrationConn = db
+	if canceler != nil {
+		pinned, stop, err := o.cancelOnTimeout(ctx, db, canceler)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		conn = pinned
+	}
+
+	if len(o.sessionSettings) > 0 {
+		if canceler == nil {
+			pinned, err := db.Conn(ctx)
+			if err != nil {
+				return err
+			}
+			defer pinned.Close()
+			conn = pinned
+		}
+		restore, err := o.setSession(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	if !o.inTransaction(m) {
+		recorder := &statementRecorder{execer: conn, progress: progress, logger: o.logger}
+		if o.atomicStatements {
+			recorder.retry = o.retry
+		}
+		return fn(ctx, recorder)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		progress.failed(ctx, "BEGIN")
+		return fmt.Errorf("failed to begin transaction for migration %s: %w", m.Name(), err)
+	}
+
+	if err := fn(ctx, &statementRecorder{execer: tx, progress: progress, logger: o.logger}); err != nil {
+		_ = tx.Rollback()
+		progress.tracked = false
+		return err
+	}
+
+	progress.ran = true
+	if err := tx.Commit(); err != nil {
+		progress.failed(ctx, "COMMIT")
+		progress.tracked = false
+		return fmt.Errorf("failed to commit migration %s: %w", m.Name(), err)
+	}
+
+	return nil
+}
+
+// replaceExecutedMigrations records record and removes the records of names
+// in one transaction of db, using the insert and remove statements of a
+// driver.
+func replaceExecutedMigrations(
+	ctx context.Context,
+	db *sql.DB,
+	names []string,
+	record ExecutedMigration,
+	insert func(ctx context.Context, ex execer, record ExecutedMigration) error,
+	remove func(ctx context.Context, ex execer, name string) error,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := insert(ctx, tx, record); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to record %s: %w", record.Name, err)
+	}
+	for _, name := range names {
+		if err := remove(ctx, tx, name); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to remove record of %s: %w", name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// cancelOnTimeout pins a connection of db and arranges for canceler to stop
+// the statement running on it once ctx times out with ErrMigrationTimedOut.
+// stop releases the connection.
+func (o *driverOptions) cancelOnTimeout(ctx context.Context, db *sql.DB, canceler *queryCanceler) (*sql.Conn, func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var id int64
+	if err := conn.QueryRowContext(ctx, canceler.idQuery).Scan(&id); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to read connection id: %w", err)
+	}
+
+	stopAfter := context.AfterFunc(ctx, func() {
+		if !errors.Is(context.Cause(ctx), ErrMigrationTimedOut) {
+			return
+		}
+		cancelCtx, cancel := withOptionalTimeout(context.WithoutCancel(ctx), o.trackingTimeout)
+		defer cancel()
+		if _, err := db.ExecContext(cancelCtx, fmt.Sprintf(canceler.cancel, id)); err != nil {
+			o.logger.warn(fmt.Sprintf("⚠️ Failed to cancel timed out statement on connection %d: %v", id, err), "failed to cancel timed out statement", "connection", id, "error", err)
+		}
+	})
+
+	return conn, func() {
+		stopAfter()
+		conn.Close()
+	}, nil
+}
+
+// lastBatch returns the highest batch number recorded in table, 0 if no
+// migration has been applied in a batch yet.
+func (o *driverOptions) lastBatch(ctx context.Context, db *sql.DB, dialect Dialect, table string) (int, error) {
+	ctx, cancel := o.trackingContext(ctx)
+	defer cancel()
+
+	var batch int
+	query := fmt.Sprintf(`SELECT COALESCE(MAX(batch), 0) FROM %s`, quoteIdentifier(dialect, table))
+	if err := db.QueryRowContext(ctx, query).Scan(&batch); err != nil {
+		return 0, fmt.Errorf("failed to read last batch of %s: %w", table, err)
+	}
+	return batch, nil
+}
+
+// prepareScript passes the script of migration through text/template when
+// template data is configured, then expands environment variables when
+// enabled. Scripts are prepared whole, before they are split into statements.
+func (o *driverOptions) prepareScript(migration, sql string) (string, error) {
+	sql, err := renderScript(migration, sql, o.templateData)
+	if err != nil {
+		return "", err
+	}
+	return expandEnv(migration, sql, o.envExpansion)
+}
+
+// rewriteStatement passes a statement of migration through the configured
+// StatementRewriter, if any.
+func (o *driverOptions) rewriteStatement(dialect Dialect, migration, sql string) (string, error) {
+	if o.statementRewriter == nil {
+		return sql, nil
+	}
+
+	rewritten, err := o.statementRewriter(migration, dialect, sql)
+	if err != nil {
+		return "", fmt.Errorf("statement rewriter rejected %s: %w", migration, err)
+	}
+	return rewritten, nil
+}
+
+// keepOnClean reports whether CleanDatabase must leave table alone: the audit
+// log of migrationTable survives cleaning, so the clean itself stays on record,
+// and so does migrationTable itself with opts.KeepHistory. The tables cleaned
+// are not schema-qualified, so neither is migrationTable when compared.
+func (o *driverOptions) keepOnClean(table, migrationTable string, opts CleanOptions) bool {
+	_, migrationTable = splitTableName(migrationTable)
+	if opts.KeepHistory && strings.EqualFold(table, migrationTable) {
+		return true
+	}
+	return (o.auditLog || opts.KeepHistory) && strings.EqualFold(table, auditTableName(migrationTable))
+}
+
+// tableChunks hands out the tables to clean a chunk at a time to the workers
+// of cleanInChunks, and reports the chunks they dropped.
+type tableChunks struct {
+	mu        sync.Mutex
+	tables    []string
+	next      int
+	batchSize int
+	dropped   int
+	onChunk   func(tables []string, dropped, total int)
+}
+
+// take returns the next chunk of tables to drop, false once there is none.
+func (c *tableChunks) take() ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next >= len(c.tables) {
+		return nil, false
+	}
+	end := min(c.next+c.batchSize, len(c.tables))
+	chunk := c.tables[c.next:end]
+	c.next = end
+	return chunk, true
+}
+
+// done reports chunk as dropped.
+func (c *tableChunks) done(chunk []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropped += len(chunk)
+	if c.onChunk != nil {
+		c.onChunk(chunk, c.dropped, len(c.tables))
+	}
+}
+
+// cleanInChunks drops tables in chunks of o.cleanBatchSize, running worker on
+// up to parallelism goroutines, each dropping the chunks it takes until none
+// is left. The first failure stops the other workers and is returned.
+func (o *driverOptions) cleanInChunks(
+	ctx context.Context,
+	tables []string,
+	parallelism int,
+	onChunk func(tables []string, dropped, total int),
+	worker func(ctx context.Context, chunks *tableChunks) error,
+) error {
+	batchSize := o.cleanBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultCleanBatchSize
+	}
+	chunks := &tableChunks{tables: tables, batchSize: batchSize, onChunk: onChunk}
+	parallelism = max(1, min(parallelism, (len(tables)+batchSize-1)/batchSize))
+	if parallelism == 1 {
+		return worker(ctx, chunks)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, parallelism)
+	for i := range parallelism {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = worker(ctx, chunks); errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Workers stopped by the failure of another report it as cancellation.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureConnection pings db so dead pooled connections are discarded and
+// replaced before the next migration uses them.
+func (o *driverOptions) ensureConnection(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := o.trackingContext(ctx)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database connection lost: %w", err)
+	}
+	return nil
+}
+
+// withCredentialRefresh calls fn and, when it fails because the database
+// rejected the credentials, obtains new ones from the configured refresher,
+// reconnects and calls fn once more.
+func (o *driverOptions) withCredentialRefresh(
+	ctx context.Context,
+	isAuthError func(err error) bool,
+	reconnect func(ctx context.Context, user, password string) error,
+	fn func() error,
+) error {
+	err := fn()
+	if err == nil || o.credentialRefresher == nil || !isAuthError(err) {
+		return err
+	}
+
+	user, password, rerr := o.credentialRefresher(ctx)
+	if rerr != nil {
+		return fmt.Errorf("failed to refresh credentials: %w (original error: %v)", rerr, err)
+	}
+	if rerr := reconnect(ctx, user, password); rerr != nil {
+		return fmt.Errorf("failed to reconnect with refreshed credentials: %w", rerr)
+	}
+
+	return fn()
+}
@@ -3,20 +3,38 @@ package gomigration
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql" // Import MySQL driver for database/sql
+	"github.com/go-sql-driver/mysql"
 )
 
 // MySqlDriver implements the Driver interface for MySQL.
 type MySqlDriver struct {
+	driverOptions
 	db                 *sql.DB
 	migrationTableName string
+	dsn                func(user, password, database string) string
+	user               string
+	password           string
+	database           string
+	endpoint           string
+	lockConn           *sql.Conn
 }
 
-// NewMySqlDriver initializes a new MySqlDriver with the given DB config.
+// mysqlQueryCanceler kills statements of time-boxed migrations that ran too
+// long. The client only closes its connection when a context is done, which
+// leaves a running ALTER going on the server.
+var mysqlQueryCanceler = &queryCanceler{
+	idQuery: `SELECT CONNECTION_ID()`,
+	cancel:  `KILL QUERY %d`,
+}
+
+// NewMySqlDriver initializes a new MySqlDriver with the given DB config. An
+// optional ConnectRetryPolicy makes it wait for a database that is still
+// starting.
 func NewMySqlDriver(
 	host string,
 	port string,
@@ -24,32 +42,44 @@ func NewMySqlDriver(
 	password string,
 	database string,
 	charset string,
+	connectRetry ...ConnectRetryPolicy,
 ) (*MySqlDriver, error) {
 	if charset == "" {
 		charset = "utf8mb4"
 	}
 
 	// Build DSN string for MySQL connection
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=True&loc=Local",
-		user, password, host, port, database, charset,
-	)
+	dsn := func(user, password, database string) string {
+		return fmt.Sprintf(
+			"%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=True&loc=Local",
+			user, password, host, port, database, charset,
+		)
+	}
 
 	// Open a new DB connection
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open("mysql", dsn(user, password, database))
 	if err != nil {
-		return nil, err
+		return nil, maskError(err)
 	}
 
-	// Test the DB connection
-	if err := db.Ping(); err != nil {
-		return nil, err
+	// Test the DB connection, waiting for it if asked to
+	var policy ConnectRetryPolicy
+	if len(connectRetry) > 0 {
+		policy = connectRetry[0]
+	}
+	if err := pingWithRetry(context.Background(), db, policy, runLogger{}); err != nil {
+		return nil, maskError(err)
 	}
 
 	// Return the driver with a default table name
 	return &MySqlDriver{
 		db:                 db,
 		migrationTableName: "migrations",
+		dsn:                dsn,
+		user:               user,
+		password:           password,
+		database:           database,
+		endpoint:           fmt.Sprintf("%s:%s/%s", host, port, database),
 	}, nil
 }
 
@@ -63,7 +93,29 @@ func (m *MySqlDriver) Close() error {
 	return nil
 }
 
-// SetMigrationTableName sets the name of the migration tracking table.
+// waitForDatabase pings the database until it accepts connections, as policy
+// says.
+func (m *MySqlDriver) waitForDatabase(ctx context.Context, policy ConnectRetryPolicy) error {
+	return maskError(pingWithRetry(ctx, m.db, policy, m.logger))
+}
+
+// configure copies the relevant Config fields into the driver options, setting
+// lock_wait_timeout on the connections migrations run on. Scripts run one
+// statement at a time, so failing statements are retried in place on
+// transient errors.
+func (m *MySqlDriver) configure(config *Config) {
+	m.driverOptions.configure(config)
+	m.atomicStatements = true
+	if config.DDLLockTimeout > 0 {
+		m.sessionSettings = append(m.sessionSettings, sessionSetting{
+			set:   fmt.Sprintf("SET SESSION lock_wait_timeout = %d", ceilUnits(config.DDLLockTimeout, time.Second)),
+			reset: "SET SESSION lock_wait_timeout = DEFAULT",
+		})
+	}
+}
+
+// SetMigrationTableName sets the name of the migration tracking table. The
+// name may be qualified by its database, e.g. "infra.migrations".
 func (m *MySqlDriver) SetMigrationTableName(name string) {
 	if name == "" {
 		name = "migrations"
@@ -71,53 +123,341 @@ func (m *MySqlDriver) SetMigrationTableName(name string) {
 	m.migrationTableName = name
 }
 
+// quotedTableName returns the migration table name quoted for use in SQL.
+func (m *MySqlDriver) quotedTableName() string {
+	return quoteIdentifier(m.dialect(), m.migrationTableName)
+}
+
+// Ping verifies the connection to the database is still alive.
+func (m *MySqlDriver) Ping(ctx context.Context) error {
+	return maskError(m.db.PingContext(ctx))
+}
+
+func (m *MySqlDriver) dialect() Dialect {
+	return DialectMySQL
+}
+
 // CreateMigrationsTable creates the migration table if it doesn't exist.
 func (m *MySqlDriver) CreateMigrationsTable(ctx context.Context) error {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	existing, err := m.trackingColumns(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s (
-			name VARCHAR(255) PRIMARY KEY NOT NULL,
-			executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			name VARCHAR(255) PRIMARY KEY NOT NULL CHECK (name <> ''),
+			executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 		)
-	`, m.migrationTableName)
-	_, err := m.db.ExecContext(ctx, query)
-	return err
+	`, m.quotedTableName())
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	if err := addTrackingColumns(ctx, m.db, m.dialect(), m.migrationTableName, existing); err != nil {
+		return err
+	}
+
+	// New tables get their indexes right away, existing ones through
+	// UpgradeMigrationsTable.
+	if len(existing) == 0 {
+		return m.createTrackingIndexes(ctx)
+	}
+	return nil
+}
+
+// UpgradeMigrationsTable adds the indexes and constraints that tracking tables
+// created by older versions are missing.
+func (m *MySqlDriver) UpgradeMigrationsTable(ctx context.Context) error {
+	if err := m.CreateMigrationsTable(ctx); err != nil {
+		return err
+	}
+	if err := m.createTrackingIndexes(ctx); err != nil {
+		return err
+	}
+
+	// Older versions allowed a NULL executed_at.
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET executed_at = CURRENT_TIMESTAMP WHERE executed_at IS NULL`, m.quotedTableName())); err != nil {
+		return fmt.Errorf("failed to backfill executed_at: %w", err)
+	}
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s MODIFY executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP`, m.quotedTableName())); err != nil {
+		return fmt.Errorf("failed to add NOT NULL constraint to executed_at: %w", err)
+	}
+
+	return nil
+}
+
+// trackingColumns returns the column names of the tracking table, which are
+// empty if it does not exist yet.
+func (m *MySqlDriver) trackingColumns(ctx context.Context) (map[string]bool, error) {
+	schema, table := splitTableName(m.migrationTableName)
+	columns, err := queryNameSet(ctx, m.db, `SELECT column_name FROM information_schema.columns WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?`, schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", m.migrationTableName, err)
+	}
+	return columns, nil
+}
+
+// trackingTableExists reports whether the tracking table has been created.
+func (m *MySqlDriver) trackingTableExists(ctx context.Context) (bool, error) {
+	columns, err := m.trackingColumns(ctx)
+	return len(columns) > 0, err
+}
+
+func (m *MySqlDriver) trackingTableDDL() []string {
+	return trackingTableStatements(m.dialect(), m.migrationTableName)
+}
+
+// createTrackingIndexes creates the tracking table indexes that do not exist yet.
+func (m *MySqlDriver) createTrackingIndexes(ctx context.Context) error {
+	schema, table := splitTableName(m.migrationTableName)
+	indexes, err := queryNameSet(ctx, m.db, `SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?`, schema, table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect indexes of %s: %w", m.migrationTableName, err)
+	}
+	return addTrackingIndexes(ctx, m.db, m.dialect(), m.migrationTableName, indexes)
+}
+
+// AcquireLock takes a named lock with GET_LOCK on a dedicated connection,
+// waiting until it becomes available or ctx is done.
+func (m *MySqlDriver) AcquireLock(ctx context.Context) error {
+	if m.lockConn != nil {
+		return errors.New("migration lock already held")
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A negative timeout waits indefinitely; cancellation is left to ctx.
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, -1)`, migrationLockName(m.migrationTableName, m.lockScope)).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		_ = conn.Close()
+		return errors.New("GET_LOCK did not grant the migration lock")
+	}
+
+	m.lockConn = conn
+	return nil
 }
 
-// GetExecutedMigrations returns a list of previously executed migrations, optionally in reverse order.
-func (m *MySqlDriver) GetExecutedMigrations(ctx context.Context, reverse bool) ([]ExecutedMigration, error) {
-	order := "ASC"
-	if reverse {
-		order = "DESC"
+// ReleaseLock releases the named lock and returns its connection to the pool.
+func (m *MySqlDriver) ReleaseLock(ctx context.Context) error {
+	if m.lockConn == nil {
+		return nil
 	}
 
-	query := fmt.Sprintf(`SELECT name, executed_at FROM %s ORDER BY name %s`, m.migrationTableName, order)
+	conn := m.lockConn
+	m.lockConn = nil
+	defer conn.Close()
+
+	_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, migrationLockName(m.migrationTableName, m.lockScope))
+	return err
+}
+
+// GetExecutedMigrations returns the executed migrations from the tracking table
+// sorted according to order.
+func (m *MySqlDriver) GetExecutedMigrations(ctx context.Context, order HistoryOrder) ([]ExecutedMigration, error) {
+	return collectExecutedMigrations(func(fn func(migration ExecutedMigration) error) error {
+		return m.IterateExecutedMigrations(ctx, order, fn)
+	})
+}
+
+// IterateExecutedMigrations streams the executed migrations sorted according to order.
+// The tracking timeout bounds the query, not the iteration, so fn may take its
+// time.
+func (m *MySqlDriver) IterateExecutedMigrations(ctx context.Context, order HistoryOrder, fn func(migration ExecutedMigration) error) error {
+	ctx, started, cancel := m.trackingQueryContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s`, executedMigrationColumns, m.quotedTableName(), historyOrderClause(order))
 	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	started()
+
+	return scanExecutedMigrations(rows, fn)
+}
+
+// GetExecutedMigrationsPage returns one page of the executed migrations sorted according to order.
+func (m *MySqlDriver) GetExecutedMigrationsPage(ctx context.Context, order HistoryOrder, limit, offset int) ([]ExecutedMigration, error) {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s LIMIT ? OFFSET ?`, executedMigrationColumns, m.quotedTableName(), historyOrderClause(order))
+	rows, err := m.db.QueryContext(ctx, query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var migrations []ExecutedMigration
-	for rows.Next() {
-		var name string
-		var executedAt time.Time
-		if err := rows.Scan(&name, &executedAt); err != nil {
-			return nil, err
-		}
-		migrations = append(migrations, ExecutedMigration{Name: name, ExecutedAt: executedAt})
+	return collectExecutedMigrations(func(fn func(migration ExecutedMigration) error) error {
+		return scanExecutedMigrations(rows, fn)
+	})
+}
+
+// InsertExecutedMigration records a migration without running the up script.
+func (m *MySqlDriver) InsertExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	return m.insertExecutedMigration(ctx, m.db, record)
+}
+
+// UpdateExecutedMigration overwrites the execution time and checksum of a record.
+func (m *MySqlDriver) UpdateExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %s SET executed_at = ?, checksum = ? WHERE name = ?`, m.quotedTableName())
+	_, err := m.db.ExecContext(ctx, query, record.ExecutedAt, nullableString(record.Checksum), record.Name)
+	return err
+}
+
+// RemoveExecutedMigration deletes a record without running the down script.
+func (m *MySqlDriver) RemoveExecutedMigration(ctx context.Context, name string) error {
+	return m.removeExecutedMigration(ctx, m.db, name)
+}
+
+// GetManifestHash returns the stored manifest hash, empty if none is stored.
+func (m *MySqlDriver) GetManifestHash(ctx context.Context) (string, error) {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	return getManifestHash(ctx, m.db, m.dialect(), m.migrationTableName)
+}
+
+// SetManifestHash stores the manifest hash, or removes it when hash is empty.
+func (m *MySqlDriver) SetManifestHash(ctx context.Context, hash string) error {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	return setManifestHash(ctx, m.db, m.dialect(), m.migrationTableName, `INSERT INTO %s (id, manifest_hash) VALUES (1, ?) ON DUPLICATE KEY UPDATE manifest_hash = VALUES(manifest_hash)`, hash)
+}
+
+// AppendAuditEvent appends event to the audit log table.
+func (m *MySqlDriver) AppendAuditEvent(ctx context.Context, event AuditEvent) error {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	return appendAuditEvent(ctx, m.db, m.dialect(), m.migrationTableName, `INSERT INTO %s (occurred_at, operation, actor, migrations, outcome, error) VALUES (?, ?, ?, ?, ?, ?)`, event)
+}
+
+// InspectSchema returns the tables of the current schema and their columns.
+func (m *MySqlDriver) InspectSchema(ctx context.Context) (Schema, error) {
+	return inspectSchema(ctx, m.db, m.migrationTableName, `
+		SELECT c.table_name, c.column_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = DATABASE() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`)
+}
+
+// InspectER returns the tables of the current schema, their columns and
+// foreign keys.
+func (m *MySqlDriver) InspectER(ctx context.Context) (ERModel, error) {
+	return inspectER(ctx, m.db, m.migrationTableName, `
+		SELECT c.table_name, c.column_name, c.column_type, c.is_nullable = 'YES', c.column_key = 'PRI'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = DATABASE() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`, `
+		SELECT k.constraint_name, k.table_name, k.column_name, k.referenced_table_name, k.referenced_column_name
+		FROM information_schema.key_column_usage k
+		WHERE k.table_schema = DATABASE() AND k.referenced_table_name IS NOT NULL
+		ORDER BY k.table_name, k.constraint_name, k.ordinal_position
+	`)
+}
+
+// InspectPlatform tells Aurora MySQL from MySQL, and reports read-only
+// servers, such as Aurora replicas behind a reader endpoint, as read-only.
+func (m *MySqlDriver) InspectPlatform(ctx context.Context) (PlatformInfo, error) {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	info := PlatformInfo{Platform: PlatformMySQL, Endpoint: m.endpoint}
+	var readOnly, innodbReadOnly bool
+	err := m.db.QueryRowContext(ctx, `SELECT @@global.read_only, @@global.innodb_read_only, VERSION()`).Scan(&readOnly, &innodbReadOnly, &info.Version)
+	if err != nil {
+		return PlatformInfo{}, fmt.Errorf("failed to inspect platform: %w", err)
+	}
+	info.FastDDL = majorVersion(info.Version) >= 8
+	switch {
+	case innodbReadOnly:
+		info.ReadOnly, info.ReadOnlyReason = true, "innodb_read_only is ON"
+	case readOnly:
+		info.ReadOnly, info.ReadOnlyReason = true, "read_only is ON"
+	}
+
+	auroraVersion, err := m.variable(ctx, "aurora_version")
+	if err != nil {
+		return PlatformInfo{}, fmt.Errorf("failed to inspect platform: %w", err)
+	}
+	if auroraVersion == "" {
+		return info, nil
+	}
+
+	info.Platform = PlatformAuroraMySQL
+	info.Version = auroraVersion
+	// Aurora MySQL 2 only adds columns instantly in lab mode.
+	labMode, err := m.variable(ctx, "aurora_lab_mode")
+	if err != nil {
+		return PlatformInfo{}, fmt.Errorf("failed to inspect platform: %w", err)
+	}
+	info.FastDDL = majorVersion(auroraVersion) >= 3 || labMode == "ON"
+	return info, nil
+}
+
+// variable returns the value of the named server variable, empty if the
+// server has no such variable.
+func (m *MySqlDriver) variable(ctx context.Context, name string) (string, error) {
+	var value string
+	err := m.db.QueryRowContext(ctx, `SHOW VARIABLES LIKE ?`, name).Scan(&name, &value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
 	}
+	return value, err
+}
+
+// GolangMigrateVersion returns the version recorded by golang-migrate.
+func (m *MySqlDriver) GolangMigrateVersion(ctx context.Context) (int64, bool, error) {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
 
-	return migrations, rows.Err()
+	return golangMigrateVersion(ctx, m.db)
+}
+
+// GooseVersions returns the history recorded by goose.
+func (m *MySqlDriver) GooseVersions(ctx context.Context) ([]GooseVersion, error) {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	return gooseVersions(ctx, m.db)
+}
+
+// FlywayHistory returns the history recorded by Flyway.
+func (m *MySqlDriver) FlywayHistory(ctx context.Context) ([]FlywayMigration, error) {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	return flywayHistory(ctx, m.db)
 }
 
 // CleanDatabase drops all tables from the current database.
 func (m *MySqlDriver) CleanDatabase(ctx context.Context) error {
-	// Disable FK checks temporarily
-	_, err := m.db.ExecContext(ctx, `SET FOREIGN_KEY_CHECKS = 0;`)
-	if err != nil {
-		return fmt.Errorf("failed to disable FK checks: %w", err)
-	}
+	return m.CleanDatabaseChunked(ctx, CleanOptions{}, nil)
+}
 
+// CleanDatabaseChunked drops all tables from the current database, a chunk
+// at a time, dropping up to Config.CleanParallelism chunks concurrently.
+func (m *MySqlDriver) CleanDatabaseChunked(ctx context.Context, opts CleanOptions, onChunk func(tables []string, dropped, total int)) error {
 	// Get all user-defined table names
 	rows, err := m.db.QueryContext(ctx, `
 		SELECT table_name 
@@ -135,7 +475,13 @@ func (m *MySqlDriver) CleanDatabase(ctx context.Context) error {
 		if err := rows.Scan(&table); err != nil {
 			return fmt.Errorf("failed to scan table name: %w", err)
 		}
-		tableNames = append(tableNames, fmt.Sprintf("`%s`", table))
+		if m.keepOnClean(table, m.migrationTableName, opts) {
+			continue
+		}
+		tableNames = append(tableNames, table)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query tables: %w", err)
 	}
 
 	// No tables to drop
@@ -143,19 +489,43 @@ func (m *MySqlDriver) CleanDatabase(ctx context.Context) error {
 		return nil
 	}
 
-	// Drop all tables in one statement
-	dropSQL := fmt.Sprintf("DROP TABLE %s;", strings.Join(tableNames, ", "))
-	_, err = m.db.ExecContext(ctx, dropSQL)
+	return m.cleanInChunks(ctx, tableNames, m.cleanParallelism, onChunk, m.dropTableChunks)
+}
+
+// dropTableChunks drops the chunks it takes on a connection of its own, as
+// foreign key checks are disabled per session.
+func (m *MySqlDriver) dropTableChunks(ctx context.Context, chunks *tableChunks) (err error) {
+	conn, err := m.db.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to drop tables: %w", err)
+		return fmt.Errorf("failed to get connection: %w", err)
 	}
+	defer conn.Close()
 
-	// Re-enable FK checks
-	_, err = m.db.ExecContext(ctx, `SET FOREIGN_KEY_CHECKS = 1;`)
-	if err != nil {
-		return fmt.Errorf("failed to re-enable FK checks: %w", err)
+	// Disable FK checks temporarily
+	if _, err := conn.ExecContext(ctx, `SET FOREIGN_KEY_CHECKS = 0;`); err != nil {
+		return fmt.Errorf("failed to disable FK checks: %w", err)
 	}
+	defer func() {
+		// Re-enable FK checks before the connection returns to the pool
+		if _, resetErr := conn.ExecContext(context.WithoutCancel(ctx), `SET FOREIGN_KEY_CHECKS = 1;`); resetErr != nil && err == nil {
+			err = fmt.Errorf("failed to re-enable FK checks: %w", resetErr)
+		}
+	}()
+
+	for chunk, ok := chunks.take(); ok; chunk, ok = chunks.take() {
+		quoted := make([]string, len(chunk))
+		for i, table := range chunk {
+			quoted[i] = fmt.Sprintf("`%s`", table)
+		}
 
+		stmtCtx, cancel := m.statementContext(ctx)
+		_, err := conn.ExecContext(stmtCtx, fmt.Sprintf("DROP TABLE %s;", strings.Join(quoted, ", ")))
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to drop tables: %w", err)
+		}
+		chunks.done(chunk)
+	}
 	return nil
 }
 
@@ -167,34 +537,97 @@ func (m *MySqlDriver) ApplyMigrations(
 	onSuccess func(migration *Migration),
 	onFailed func(migration *Migration, err error),
 ) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	batch, err := m.lastBatch(ctx, m.db, m.dialect(), m.migrationTableName)
+	if err != nil {
+		return err
+	}
+	batch++
+
+	failures := &BatchError{}
+
 	for i := range migrations {
 		mig := migrations[i]
+		upScript, _ := dialectScripts(mig, DialectMySQL)
+
+		skipped, err := m.skipMigration(ctx, m, m.db, mig, batch, m.insertExecutedMigration)
+		if skipped {
+			continue
+		}
 
 		if onRunning != nil {
 			onRunning(&mig)
 		}
 
-		// Execute the migration SQL
-		if err := m.executeMigrationSQL(ctx, mig.UpScript()); err != nil {
-			if onFailed != nil {
-				onFailed(&mig, err)
-			}
-			return fmt.Errorf("failed to apply migration %s: %w", mig.Name(), err)
+		stage := StageCondition
+		if err == nil {
+			stage = StageConnect
+			err = m.withCredentialRefresh(ctx, isMySqlAuthError, m.reconnect, func() error {
+				// Pooled connections may have died while the previous migration ran.
+				if i > 0 {
+					if err := m.ensureConnection(ctx, m.db); err != nil {
+						return err
+					}
+				}
+				stage = StageExecute
+				if err := m.checkImplicitCommits(mig, upScript); err != nil {
+					return err
+				}
+				return m.runMigration(ctx, m.db, mig, mysqlQueryCanceler, func(ctx context.Context, ex execer) error {
+					// Execute the migration SQL
+					stage = StageExecute
+					if err := m.executeMigrationSQL(ctx, ex, mig.Name(), upScript); err != nil {
+						return fmt.Errorf("failed to apply migration %s: %w", mig.Name(), err)
+					}
+					// Record the migration
+					stage = StageRecord
+					if err := m.insertExecutedMigration(ctx, ex, ExecutedMigration{
+						Name:       mig.Name(),
+						ExecutedAt: time.Now(),
+						Checksum:   migrationChecksum(mig),
+						Batch:      batch,
+						AppliedBy:  m.appliedBy,
+						Build:      m.build,
+						Namespace:  migrationSetOf(mig),
+					}); err != nil {
+						return fmt.Errorf("failed to record migration %s: %w", mig.Name(), err)
+					}
+					return nil
+				})
+			})
 		}
-
-		// Record the migration
-		if err := m.insertExecutedMigration(ctx, mig.Name(), time.Now()); err != nil {
+		if err != nil {
+			err = migrationFailure(mig, DirectionUp, err)
 			if onFailed != nil {
 				onFailed(&mig, err)
 			}
-			return fmt.Errorf("failed to record migration %s: %w", mig.Name(), err)
+			if err := m.failBatch(ctx, failures, BatchFailure{Migration: mig.Name(), Stage: stage, Err: err}); err != nil {
+				return err
+			}
+			continue
 		}
 
 		if onSuccess != nil {
 			onSuccess(&mig)
 		}
 	}
-	return nil
+	return failures.orNil()
+}
+
+// RunSeeder runs the script of seeder like a migration, without recording it.
+func (m *MySqlDriver) RunSeeder(ctx context.Context, seeder Seeder) error {
+	return m.runMigration(ctx, m.db, seederMigration{seeder: seeder}, mysqlQueryCanceler, func(ctx context.Context, ex execer) error {
+		return m.executeMigrationSQL(ctx, ex, seeder.Name(), seeder.SeedScript())
+	})
+}
+
+// LoadFixtures inserts the rows of fixtures in a single transaction, deleting
+// the rows of their tables first with truncate.
+func (m *MySqlDriver) LoadFixtures(ctx context.Context, fixtures []Fixture, truncate bool) error {
+	return loadFixtures(ctx, m.db, DialectMySQL, fixtures, truncate)
 }
 
 // UnapplyMigrations rolls back a batch of "down" migrations with optional callbacks.
@@ -207,25 +640,40 @@ func (m *MySqlDriver) UnapplyMigrations(
 ) error {
 	for i := range migrations {
 		mig := migrations[i]
+		_, downScript := dialectScripts(mig, DialectMySQL)
 
 		if onRunning != nil {
 			onRunning(&mig)
 		}
 
-		// Execute the down migration SQL
-		if err := m.executeMigrationSQL(ctx, mig.DownScript()); err != nil {
-			if onFailed != nil {
-				onFailed(&mig, err)
+		err := m.withCredentialRefresh(ctx, isMySqlAuthError, m.reconnect, func() error {
+			// Pooled connections may have died while the previous migration ran.
+			if i > 0 {
+				if err := m.ensureConnection(ctx, m.db); err != nil {
+					return err
+				}
 			}
-			return fmt.Errorf("failed to unapply migration %s: %w", mig.Name(), err)
-		}
-
-		// Remove migration record from tracking table
-		if err := m.removeExecutedMigration(ctx, mig.Name()); err != nil {
+			if err := m.checkImplicitCommits(mig, downScript); err != nil {
+				return err
+			}
+			return m.runMigration(ctx, m.db, mig, mysqlQueryCanceler, func(ctx context.Context, ex execer) error {
+				// Execute the down migration SQL
+				if err := m.executeMigrationSQL(ctx, ex, mig.Name(), downScript); err != nil {
+					return fmt.Errorf("failed to unapply migration %s: %w", mig.Name(), err)
+				}
+				// Remove migration record from tracking table
+				if err := m.removeExecutedMigration(ctx, ex, mig.Name()); err != nil {
+					return fmt.Errorf("failed to remove migration record %s: %w", mig.Name(), err)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			err = migrationFailure(mig, DirectionDown, err)
 			if onFailed != nil {
 				onFailed(&mig, err)
 			}
-			return fmt.Errorf("failed to remove migration record %s: %w", mig.Name(), err)
+			return err
 		}
 
 		if onSuccess != nil {
@@ -235,25 +683,199 @@ func (m *MySqlDriver) UnapplyMigrations(
 	return nil
 }
 
-// executeMigrationSQL runs a raw SQL migration script.
-func (m *MySqlDriver) executeMigrationSQL(ctx context.Context, sql string) error {
+// reconnect replaces the connection pool with one authenticated by the given credentials.
+func (m *MySqlDriver) reconnect(ctx context.Context, user, password string) error {
+	if m.dsn == nil {
+		return errors.New("driver was not created with connection details")
+	}
+
+	db, err := sql.Open("mysql", m.dsn(user, password, m.database))
+	if err != nil {
+		return maskError(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return maskError(err)
+	}
+
+	old := m.db
+	m.db = db
+	m.user, m.password = user, password
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
+
+// ForTenant connects to the database named after tenant on the server of m,
+// creating the database if it does not exist.
+func (m *MySqlDriver) ForTenant(ctx context.Context, tenant string) (Driver, error) {
+	if err := validateTenant(tenant); err != nil {
+		return nil, err
+	}
+	if m.dsn == nil {
+		return nil, errors.New("driver was not created with connection details")
+	}
+
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", tenant)); err != nil {
+		return nil, fmt.Errorf("failed to create database of tenant %s: %w", tenant, err)
+	}
+
+	db, err := sql.Open("mysql", m.dsn(m.user, m.password, tenant))
+	if err != nil {
+		return nil, maskError(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, maskError(err)
+	}
+
+	return &MySqlDriver{
+		driverOptions:      m.driverOptions,
+		db:                 db,
+		migrationTableName: m.migrationTableName,
+		dsn:                m.dsn,
+		user:               m.user,
+		password:           m.password,
+		database:           tenant,
+		endpoint:           m.endpoint + "#" + tenant,
+	}, nil
+}
+
+// isMySqlAuthError reports whether err was caused by rejected credentials.
+func isMySqlAuthError(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	// 1045: access denied, 1698: access denied without password, 1862: password expired
+	return myErr.Number == 1045 || myErr.Number == 1698 || myErr.Number == 1862
+}
+
+// checkImplicitCommits applies the implicit commit policy to script of mig
+// when it would run in a transaction. MySQL commits the transaction before
+// and after DDL, so such a migration is not atomic: a failure halfway leaves
+// the statements before it applied.
+func (m *MySqlDriver) checkImplicitCommits(mig Migration, script string) error {
+	if !m.useTransactions || isNonTransactional(mig) || m.implicitCommitPolicy == ImplicitCommitAllow {
+		return nil
+	}
+
+	statements := mysqlImplicitCommits(script)
+	if len(statements) == 0 {
+		return nil
+	}
+
+	if m.implicitCommitPolicy == ImplicitCommitFail {
+		return fmt.Errorf(
+			"%w: %s runs %s (make it NonTransactional or split it)",
+			ErrImplicitCommit,
+			mig.Name(),
+			strings.Join(statements, ", "),
+		)
+	}
+	m.logger.warn(
+		fmt.Sprintf("⚠️ %s runs %s, which commit implicitly on MySQL: the migration is not atomic", mig.Name(), strings.Join(statements, ", ")),
+		"migration is not atomic, its statements commit implicitly on MySQL",
+		"migration", mig.Name(),
+		"statements", statements,
+	)
+	return nil
+}
+
+// mysqlImplicitCommitKeywords are the leading keywords of statements that
+// cause an implicit commit on MySQL.
+var mysqlImplicitCommitKeywords = map[string]bool{
+	"ALTER":    true,
+	"ANALYZE":  true,
+	"BEGIN":    true,
+	"CREATE":   true,
+	"DROP":     true,
+	"FLUSH":    true,
+	"GRANT":    true,
+	"LOCK":     true,
+	"OPTIMIZE": true,
+	"RENAME":   true,
+	"REPAIR":   true,
+	"REVOKE":   true,
+	"START":    true,
+	"TRUNCATE": true,
+	"UNLOCK":   true,
+}
+
+// mysqlImplicitCommits returns the statements of script that cause an implicit
+// commit, as their first two keywords, e.g. "ALTER TABLE". Comments and quoted
+// strings are ignored, and so are temporary tables, which do not commit.
+func mysqlImplicitCommits(script string) []string {
+	var found []string
+	for _, statement := range splitStatements(script, DialectMySQL) {
+		words := strings.Fields(strings.ToUpper(stripSQLCommentsAndStrings(statement.sql)))
+		if len(words) == 0 || !mysqlImplicitCommitKeywords[words[0]] {
+			continue
+		}
+		if len(words) > 1 && words[1] == "TEMPORARY" {
+			continue
+		}
+		summary := words[0]
+		if len(words) > 1 {
+			summary += " " + words[1]
+		}
+		found = append(found, summary)
+	}
+	return found
+}
+
+// executeMigrationSQL runs a raw SQL migration script of the named migration.
+// The script is run a statement at a time, as the connection does not allow
+// multiple statements per Exec, each statement passing through the statement
+// rewriter first, and a failing statement is reported as a *StatementError.
+func (m *MySqlDriver) executeMigrationSQL(ctx context.Context, ex execer, name, sql string) error {
 	if sql == "" {
 		return nil
 	}
-	_, err := m.db.ExecContext(ctx, sql)
-	return err
+
+	sql, err := m.prepareScript(name, sql)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := m.statementContext(ctx)
+	defer cancel()
+
+	for i, statement := range splitStatements(sql, DialectMySQL) {
+		query, err := m.rewriteStatement(DialectMySQL, name, statement.sql)
+		if err != nil {
+			return err
+		}
+		if _, err := ex.ExecContext(ctx, query); err != nil {
+			return &StatementError{Migration: name, Index: i + 1, Line: statement.line, Statement: query, Err: err}
+		}
+	}
+	return nil
 }
 
-// insertExecutedMigration logs a migration into the migration tracking table.
-func (m *MySqlDriver) insertExecutedMigration(ctx context.Context, name string, executedAt time.Time) error {
-	query := fmt.Sprintf(`INSERT INTO %s (name, executed_at) VALUES (?, ?)`, m.migrationTableName)
-	_, err := m.db.ExecContext(ctx, query, name, executedAt)
+// squashExecutedMigrations replaces the records of squashed with that of
+// their baseline in a single transaction.
+func (m *MySqlDriver) squashExecutedMigrations(ctx context.Context, squashed []string, baseline ExecutedMigration) error {
+	return replaceExecutedMigrations(ctx, m.db, squashed, baseline, m.insertExecutedMigration, m.removeExecutedMigration)
+}
+
+// insertExecutedMigration records the given migration in the tracking table.
+func (m *MySqlDriver) insertExecutedMigration(ctx context.Context, ex execer, record ExecutedMigration) error {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`INSERT INTO %s (name, executed_at, checksum, batch, applied_by, namespace, build, skipped) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, m.quotedTableName())
+	_, err := ex.ExecContext(ctx, query, record.Name, record.ExecutedAt, nullableString(record.Checksum), nullableInt(record.Batch), nullableString(record.AppliedBy), nullableString(record.Namespace), nullableBuild(record.Build), nullableBool(record.Skipped))
 	return err
 }
 
 // removeExecutedMigration deletes a migration record from the migration table.
-func (m *MySqlDriver) removeExecutedMigration(ctx context.Context, name string) error {
-	query := fmt.Sprintf(`DELETE FROM %s WHERE name = ?`, m.migrationTableName)
-	_, err := m.db.ExecContext(ctx, query, name)
+func (m *MySqlDriver) removeExecutedMigration(ctx context.Context, ex execer, name string) error {
+	ctx, cancel := m.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE name = ?`, m.quotedTableName())
+	_, err := ex.ExecContext(ctx, query, name)
 	return err
 }
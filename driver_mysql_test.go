@@ -3,10 +3,17 @@ package gomigration
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,14 +48,42 @@ func TestCreateMigrationsTableMySqlDriver(t *testing.T) {
 	db, mock, driver := setupMockDBMySql(t)
 	defer db.Close()
 
-	// Simulate a successful table creation
-	mock.ExpectExec("CREATE TABLE IF NOT EXISTS migrations").WillReturnResult(sqlmock.NewResult(1, 1))
+	// Simulate creating the table from scratch
+	mock.ExpectQuery(`SELECT column_name FROM information_schema\.columns WHERE table_schema = COALESCE\(NULLIF\(\?, ''\), DATABASE\(\)\) AND table_name = \?`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `migrations`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE `migrations` ADD COLUMN checksum VARCHAR\\(64\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE `migrations` ADD COLUMN batch INTEGER").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE `migrations` ADD COLUMN applied_by VARCHAR\\(255\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE `migrations` ADD COLUMN namespace VARCHAR\\(255\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE `migrations` ADD COLUMN build TEXT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE `migrations` ADD COLUMN skipped BOOLEAN").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT DISTINCT index_name FROM information_schema\.statistics WHERE table_schema = COALESCE\(NULLIF\(\?, ''\), DATABASE\(\)\) AND table_name = \?`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"index_name"}))
+	mock.ExpectExec("CREATE INDEX migrations_executed_at_idx ON `migrations` \\(executed_at, name\\)").WillReturnResult(sqlmock.NewResult(0, 0))
 
 	// Call CreateMigrationsTable
 	err := driver.CreateMigrationsTable(context.Background())
 	assert.NoError(t, err)
 }
 
+func TestUpgradeMigrationsTableMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT column_name FROM information_schema\.columns`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("name").AddRow("executed_at").AddRow("checksum").AddRow("batch").AddRow("applied_by").AddRow("namespace").AddRow("build").AddRow("skipped"))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `migrations`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT DISTINCT index_name FROM information_schema\.statistics`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"index_name"}).AddRow("PRIMARY").AddRow("migrations_executed_at_idx"))
+	mock.ExpectExec("UPDATE `migrations` SET executed_at = CURRENT_TIMESTAMP WHERE executed_at IS NULL").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE `migrations` MODIFY executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.UpgradeMigrationsTable(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestSetMigrationTableNameMySqlDriver(t *testing.T) {
 	driver := &MySqlDriver{}
 
@@ -67,15 +102,15 @@ func TestGetExecutedMigrations(t *testing.T) {
 	defer db.Close()
 
 	// Simulate the query to fetch migrations
-	rows := sqlmock.NewRows([]string{"name", "executed_at"}).
-		AddRow("migration_1", time.Now()).
-		AddRow("migration_2", time.Now())
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_1", time.Now(), "abc", 1, "ci", nil, nil, nil).
+		AddRow("migration_2", time.Now(), nil, 1, "ci", nil, nil, nil)
 
-	mock.ExpectQuery("SELECT name, executed_at FROM migrations").
+	mock.ExpectQuery("SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM `migrations`").
 		WillReturnRows(rows)
 
 	// Call GetExecutedMigrations
-	migrations, err := driver.GetExecutedMigrations(context.Background(), false)
+	migrations, err := driver.GetExecutedMigrations(context.Background(), HistoryOrderApplied)
 	assert.NoError(t, err)
 	assert.Len(t, migrations, 2)
 	assert.Equal(t, "migration_1", migrations[0].Name)
@@ -87,10 +122,7 @@ func TestCleanDatabaseMySqlDriver(t *testing.T) {
 
 	ctx := context.Background()
 
-	// 1. Expect disabling foreign key checks
-	mock.ExpectExec(`SET FOREIGN_KEY_CHECKS = 0;`).WillReturnResult(sqlmock.NewResult(0, 0))
-
-	// 2. Expect selecting all table names
+	// 1. Expect selecting all table names
 	mock.ExpectQuery(`SELECT table_name FROM information_schema\.tables WHERE table_schema = DATABASE\(\);`).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"table_name"}).
@@ -98,6 +130,9 @@ func TestCleanDatabaseMySqlDriver(t *testing.T) {
 				AddRow("products"),
 		)
 
+	// 2. Expect disabling foreign key checks on the connection dropping them
+	mock.ExpectExec(`SET FOREIGN_KEY_CHECKS = 0;`).WillReturnResult(sqlmock.NewResult(0, 0))
+
 	// 3. Expect dropping tables
 	mock.ExpectExec(`DROP TABLE ` + "`users`, `products`;").WillReturnResult(sqlmock.NewResult(0, 0))
 
@@ -115,6 +150,64 @@ func TestCleanDatabaseMySqlDriver(t *testing.T) {
 	assert.NoError(t, err, "there were unfulfilled expectations")
 }
 
+func TestCleanDatabaseChunkedMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	driver.cleanBatchSize = 2
+
+	mock.ExpectQuery(`SELECT table_name FROM information_schema\.tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).AddRow("users").AddRow("posts").AddRow("tags"))
+	mock.ExpectExec(`SET FOREIGN_KEY_CHECKS = 0;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DROP TABLE `users`, `posts`;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DROP TABLE `tags`;").WillReturnError(errors.New("lock wait timeout exceeded"))
+	mock.ExpectExec(`SET FOREIGN_KEY_CHECKS = 1;`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var progress []int
+	err := driver.CleanDatabaseChunked(context.Background(), CleanOptions{}, func(tables []string, dropped, total int) {
+		progress = append(progress, dropped, total)
+	})
+	assert.ErrorContains(t, err, "lock wait timeout exceeded")
+	assert.Equal(t, []int{2, 3}, progress)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCleanInChunks_Parallel(t *testing.T) {
+	tables := make([]string, 10)
+	for i := range tables {
+		tables[i] = fmt.Sprintf("table%d", i)
+	}
+	options := &driverOptions{cleanBatchSize: 3}
+
+	var mu sync.Mutex
+	var dropped []string
+	var totals []int
+	err := options.cleanInChunks(context.Background(), tables, 3, func(chunk []string, done, total int) {
+		totals = append(totals, done)
+	}, func(ctx context.Context, chunks *tableChunks) error {
+		for chunk, ok := chunks.take(); ok; chunk, ok = chunks.take() {
+			mu.Lock()
+			dropped = append(dropped, chunk...)
+			mu.Unlock()
+			chunks.done(chunk)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, tables, dropped)
+	assert.Len(t, totals, 4)
+	assert.Equal(t, 10, totals[3])
+
+	err = options.cleanInChunks(context.Background(), tables, 3, nil, func(ctx context.Context, chunks *tableChunks) error {
+		chunk, _ := chunks.take()
+		if slices.Contains(chunk, "table0") {
+			return errors.New("lock wait timeout exceeded")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.EqualError(t, err, "lock wait timeout exceeded")
+}
+
 func TestApplyMigrationsMySqlDriver(t *testing.T) {
 	db, mock, driver := setupMockDBMySql(t)
 	defer db.Close()
@@ -125,8 +218,54 @@ func TestApplyMigrationsMySqlDriver(t *testing.T) {
 		down: "DROP TABLE test;",
 	}
 
-	mock.ExpectExec("CREATE TABLE test \\(id INT\\);").WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(`INSERT INTO migrations`).WithArgs("migration1", sqlmock.AnyArg()).
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(batch\\), 0\\) FROM `migrations`").WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec("CREATE TABLE test \\(id INT\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `migrations`").WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsLockWaitTimeoutMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	driver.configure(&Config{DDLLockTimeout: 1500 * time.Millisecond})
+
+	mig := &mockMigrationMySqlDriver{
+		name: "migration1",
+		up:   "ALTER TABLE test ADD name TEXT;",
+		down: "ALTER TABLE test DROP name;",
+	}
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(batch\\), 0\\) FROM `migrations`").WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec(`^SET SESSION lock_wait_timeout = 2$`).WillReturnError(errors.New("access denied"))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorContains(t, err, "failed to set session setting (SET SESSION lock_wait_timeout = 2): access denied")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsRetryMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	driver.configure(&Config{Retry: RetryPolicy{Attempts: 2, Backoff: time.Millisecond}})
+
+	mig := &mockMigrationMySqlDriver{
+		name: "migration1",
+		up:   "CREATE TABLE test (id INT);\nUPDATE counters SET n = n + 1;",
+		down: "DROP TABLE test;",
+	}
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(batch\\), 0\\) FROM `migrations`").WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec("CREATE TABLE test \\(id INT\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	// Only the deadlocked statement is retried.
+	mock.ExpectExec("UPDATE counters SET n = n \\+ 1$").WillReturnError(deadlock)
+	mock.ExpectExec("UPDATE counters SET n = n \\+ 1$").WillReturnError(deadlock)
+	mock.ExpectExec("UPDATE counters SET n = n \\+ 1$").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `migrations`").WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
@@ -144,8 +283,8 @@ func TestUnapplyMigrationsMySqlDriver(t *testing.T) {
 		down: "DROP TABLE test;",
 	}
 
-	mock.ExpectExec(mig.down).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(`DELETE FROM migrations WHERE name = ?`).WithArgs(mig.name).
+	mock.ExpectExec("DROP TABLE test$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM `migrations` WHERE name = ?").WithArgs(mig.name).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := driver.UnapplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
@@ -153,25 +292,266 @@ func TestUnapplyMigrationsMySqlDriver(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestApplyMigrationsMigrationErrorMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	mig := &mockMigrationMySqlDriver{
+		name: "migration1",
+		up:   "CREATE TABLE test (id INT);\nALTER TABLE test ADD COLUMN id INT;",
+		down: "DROP TABLE test;",
+	}
+
+	duplicate := &mysql.MySQLError{Number: 1060, Message: "Duplicate column name 'id'"}
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(batch\\), 0\\) FROM `migrations`").WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec("CREATE TABLE test \\(id INT\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE test ADD COLUMN id INT$").WillReturnError(duplicate)
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	var migrationErr *MigrationError
+	if assert.ErrorAs(t, err, &migrationErr) {
+		assert.Equal(t, "migration1", migrationErr.Migration)
+		assert.Equal(t, DirectionUp, migrationErr.Direction)
+		assert.Equal(t, "ALTER TABLE test ADD COLUMN id INT", migrationErr.Statement)
+		assert.Equal(t, 2, migrationErr.StatementIndex)
+	}
+	assert.ErrorIs(t, err, duplicate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnapplyMigrationsMigrationErrorMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	mig := &mockMigrationMySqlDriver{
+		name: "migration1",
+		up:   "CREATE TABLE test (id INT);",
+		down: "DROP TABLE test;",
+	}
+
+	mock.ExpectExec("DROP TABLE test$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM `migrations` WHERE name = ?").WithArgs(mig.name).WillReturnError(errors.New("table is read only"))
+
+	err := driver.UnapplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	var migrationErr *MigrationError
+	if assert.ErrorAs(t, err, &migrationErr) {
+		assert.Equal(t, DirectionDown, migrationErr.Direction)
+		assert.Contains(t, migrationErr.Statement, "DELETE FROM `migrations`")
+		assert.Equal(t, 0, migrationErr.StatementIndex, "the tracking table statement is not part of the script")
+	}
+	assert.EqualError(t, err, "failed to remove migration record migration1: table is read only")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcquireAndReleaseLockMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, -1\)`).WithArgs("gomigration:migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"GET_LOCK"}).AddRow(1))
+	mock.ExpectExec(`SELECT RELEASE_LOCK\(\?\)`).WithArgs("gomigration:migrations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, driver.AcquireLock(context.Background()))
+	assert.NoError(t, driver.ReleaseLock(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcquireLockNotGrantedMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, -1\)`).WithArgs("gomigration:migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"GET_LOCK"}).AddRow(nil))
+
+	assert.Error(t, driver.AcquireLock(context.Background()))
+	assert.Nil(t, driver.lockConn)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsMySqlAuthError(t *testing.T) {
+	assert.True(t, isMySqlAuthError(&mysql.MySQLError{Number: 1045}))
+	assert.False(t, isMySqlAuthError(&mysql.MySQLError{Number: 1146}))
+	assert.False(t, isMySqlAuthError(assert.AnError))
+}
+
 func TestExecuteMigrationSQLMySqlDriver(t *testing.T) {
 	db, mock, driver := setupMockDBMySql(t)
 	defer db.Close()
 
 	mock.ExpectExec(`SOME SQL STATEMENT`).WillReturnResult(sqlmock.NewResult(0, 0))
 
-	err := driver.executeMigrationSQL(context.Background(), "SOME SQL STATEMENT")
+	err := driver.executeMigrationSQL(context.Background(), db, "migration_name", "SOME SQL STATEMENT")
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestExecuteMigrationSQLMySqlDriver_Statements(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	script := "-- users\nCREATE TABLE users (id INT, bio TEXT DEFAULT 'a;b');\n\n# posts\nCREATE TABLE posts (id INT);\nINSERT INTO posts VALUES (1);\n-- done\n"
+	mock.ExpectExec(`^-- users CREATE TABLE users \(id INT, bio TEXT DEFAULT 'a;b'\)$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^# posts CREATE TABLE posts \(id INT\)$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^INSERT INTO posts VALUES \(1\)$`).WillReturnError(&mysql.MySQLError{Number: 1146, Message: "Table 'posts' doesn't exist"})
+
+	err := driver.executeMigrationSQL(context.Background(), db, "001_create_users", script)
+	var statementErr *StatementError
+	if assert.ErrorAs(t, err, &statementErr) {
+		assert.Equal(t, 3, statementErr.Index)
+		assert.Equal(t, 6, statementErr.Line)
+		assert.Equal(t, "INSERT INTO posts VALUES (1)", statementErr.Statement)
+	}
+	assert.EqualError(t, err, "statement 3 of 001_create_users, line 6 (INSERT INTO posts VALUES (1)): Error 1146: Table 'posts' doesn't exist")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteMigrationSQLMySqlDriver_StatementRewriter(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	driver.statementRewriter = func(migration string, dialect Dialect, sql string) (string, error) {
+		if migration == "002_rejected" {
+			return "", errors.New("missing ticket")
+		}
+		return fmt.Sprintf("/* %s %s */ %s, ALGORITHM=INPLACE", dialect, migration, sql), nil
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`/* mysql 001_add_email */ ALTER TABLE users ADD email TEXT, ALGORITHM=INPLACE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	assert.NoError(t, driver.executeMigrationSQL(ctx, db, "001_add_email", "ALTER TABLE users ADD email TEXT"))
+	assert.ErrorContains(t, driver.executeMigrationSQL(ctx, db, "002_rejected", "DROP TABLE users"), "statement rewriter rejected 002_rejected: missing ticket")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteMigrationSQLMySqlDriver_StatementRewriterPerStatement(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	var rewritten []string
+	driver.statementRewriter = func(migration string, dialect Dialect, sql string) (string, error) {
+		rewritten = append(rewritten, sql)
+		if strings.HasPrefix(sql, "ALTER TABLE") {
+			return sql + ", ALGORITHM=INPLACE", nil
+		}
+		return sql, nil
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE users ADD email TEXT, ALGORITHM=INPLACE`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET email = ''`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE users ADD INDEX users_email (email), ALGORITHM=INPLACE`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	script := "ALTER TABLE users ADD email TEXT;\nUPDATE users SET email = '';\nALTER TABLE users ADD INDEX users_email (email);\n"
+	assert.NoError(t, driver.executeMigrationSQL(context.Background(), db, "001_add_email", script))
+	assert.Equal(t, []string{
+		"ALTER TABLE users ADD email TEXT",
+		"UPDATE users SET email = ''",
+		"ALTER TABLE users ADD INDEX users_email (email)",
+	}, rewritten)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteMigrationSQLMySqlDriver_TemplateData(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	driver.templateData = map[string]any{"Schema": "tenant_a"}
+	driver.statementRewriter = func(migration string, dialect Dialect, sql string) (string, error) {
+		return sql + ", ALGORITHM=INPLACE", nil
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE tenant_a.users ADD email TEXT, ALGORITHM=INPLACE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	assert.NoError(t, driver.executeMigrationSQL(ctx, db, "001_add_email", "ALTER TABLE {{ .Schema }}.users ADD email TEXT"))
+	assert.ErrorContains(t, driver.executeMigrationSQL(ctx, db, "002_add_name", "ALTER TABLE {{ .Env }}.users ADD name TEXT"), "failed to render script of 002_add_name")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteMigrationSQLMySqlDriver_EnvExpansion(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	t.Setenv("GOMIGRATION_ROW_FORMAT", "COMPRESSED")
+	driver.envExpansion = EnvExpansionStrict
+	driver.templateData = map[string]any{"Table": "users"}
+
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE users ROW_FORMAT=COMPRESSED`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	assert.NoError(t, driver.executeMigrationSQL(ctx, db, "001_compress", "ALTER TABLE {{ .Table }} ROW_FORMAT=${GOMIGRATION_ROW_FORMAT}"))
+	assert.ErrorIs(t, driver.executeMigrationSQL(ctx, db, "002_compress", "ALTER TABLE posts ROW_FORMAT=${GOMIGRATION_UNSET}"), ErrUndefinedEnvVar)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsMaxDurationMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	mig := &timeBoxedMigrationMySqlDriver{
+		mockMigrationMySqlDriver: mockMigrationMySqlDriver{name: "migration1", up: "ALTER TABLE test ADD hung INT"},
+		maxDuration:              20 * time.Millisecond,
+	}
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(batch\\), 0\\) FROM `migrations`").WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectQuery(`SELECT CONNECTION_ID\(\)`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectExec(`ALTER TABLE test`).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`KILL QUERY 42`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorIs(t, err, ErrMigrationTimedOut)
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+}
+
+type timeBoxedMigrationMySqlDriver struct {
+	mockMigrationMySqlDriver
+	maxDuration time.Duration
+}
+
+func (m *timeBoxedMigrationMySqlDriver) MaxDuration() time.Duration { return m.maxDuration }
+
+func TestMysqlImplicitCommits(t *testing.T) {
+	script := `
+		-- ALTER TABLE in a comment; DROP TABLE too
+		INSERT INTO notes (body) VALUES ('CREATE TABLE; DROP TABLE');
+		CREATE TEMPORARY TABLE scratch (id INT);
+		alter table users add email text; /* rename table x */
+		create index users_email_idx on users (email);
+	`
+	assert.Equal(t, []string{"ALTER TABLE", "CREATE INDEX"}, mysqlImplicitCommits(script))
+	assert.Empty(t, mysqlImplicitCommits("UPDATE users SET active = 1;"))
+}
+
+func TestApplyMigrationsImplicitCommitFailMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+	driver.useTransactions = true
+	driver.implicitCommitPolicy = ImplicitCommitFail
+
+	mig := &mockMigrationMySqlDriver{name: "migration1", up: "UPDATE users SET a = 1; ALTER TABLE users ADD b INT;"}
+
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(batch\\), 0\\) FROM `migrations`").WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorIs(t, err, ErrImplicitCommit)
+	assert.ErrorContains(t, err, "migration1 runs ALTER TABLE")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestInsertExecutedMigrationMySqlDriver(t *testing.T) {
 	db, mock, driver := setupMockDBMySql(t)
 	defer db.Close()
 
-	mock.ExpectExec(`INSERT INTO migrations`).WithArgs("migration_name", sqlmock.AnyArg()).
+	mock.ExpectExec("INSERT INTO `migrations`").WithArgs("migration_name", sqlmock.AnyArg(), "abc", 2, "ci", nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := driver.insertExecutedMigration(context.Background(), "migration_name", time.Now())
+	err := driver.insertExecutedMigration(context.Background(), db, ExecutedMigration{
+		Name:       "migration_name",
+		ExecutedAt: time.Now(),
+		Checksum:   "abc",
+		Batch:      2,
+		AppliedBy:  "ci",
+	})
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -180,10 +560,10 @@ func TestRemoveExecutedMigrationMySqlDriver(t *testing.T) {
 	db, mock, driver := setupMockDBMySql(t)
 	defer db.Close()
 
-	mock.ExpectExec(`DELETE FROM migrations WHERE name = ?`).WithArgs("migration_name").
+	mock.ExpectExec("DELETE FROM `migrations` WHERE name = ?").WithArgs("migration_name").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := driver.removeExecutedMigration(context.Background(), "migration_name")
+	err := driver.removeExecutedMigration(context.Background(), db, "migration_name")
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -199,3 +579,29 @@ type mockMigrationMySqlDriver struct {
 func (m *mockMigrationMySqlDriver) Name() string       { return m.name }
 func (m *mockMigrationMySqlDriver) UpScript() string   { return m.up }
 func (m *mockMigrationMySqlDriver) DownScript() string { return m.down }
+
+func TestInspectPlatformMySqlDriver(t *testing.T) {
+	db, mock, driver := setupMockDBMySql(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT @@global.read_only, @@global.innodb_read_only, VERSION\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"read_only", "innodb_read_only", "version"}).AddRow(0, 1, "5.7.12"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE \?`).WithArgs("aurora_version").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("aurora_version", "2.11.2"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE \?`).WithArgs("aurora_lab_mode").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("aurora_lab_mode", "ON"))
+
+	info, err := driver.InspectPlatform(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, PlatformInfo{Platform: PlatformAuroraMySQL, Version: "2.11.2", ReadOnly: true, ReadOnlyReason: "innodb_read_only is ON", FastDDL: true}, info)
+
+	mock.ExpectQuery(`SELECT @@global.read_only, @@global.innodb_read_only, VERSION\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"read_only", "innodb_read_only", "version"}).AddRow(0, 0, "8.0.36"))
+	mock.ExpectQuery(`SHOW VARIABLES LIKE \?`).WithArgs("aurora_version").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}))
+
+	info, err = driver.InspectPlatform(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, PlatformInfo{Platform: PlatformMySQL, Version: "8.0.36", FastDDL: true}, info)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,22 +4,31 @@ package gomigration
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"hash/fnv"
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // PostgresDriver manages database connections and migration operations for PostgreSQL.
 type PostgresDriver struct {
+	driverOptions
 	db                 *sql.DB
 	migrationTableName string
+	dsn                func(user, password, schema string) string
+	user               string
+	password           string
+	schema             string
+	endpoint           string
+	lockConn           *sql.Conn
 }
 
 // NewPostgresDriver creates and returns a new instance of PostgresDriver.
 // It opens a connection to the given PostgreSQL database using the provided credentials and schema.
+// An optional ConnectRetryPolicy makes it wait for a database that is still starting.
 func NewPostgresDriver(
 	host string,
 	port string,
@@ -27,22 +36,36 @@ func NewPostgresDriver(
 	password string,
 	database string,
 	schema string,
+	connectRetry ...ConnectRetryPolicy,
 ) (*PostgresDriver, error) {
-	dsn := "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable search_path=%s"
-	dsn = fmt.Sprintf(dsn, host, port, user, password, database, schema)
+	dsn := func(user, password, schema string) string {
+		return fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable search_path=%s",
+			host, port, user, password, database, schema,
+		)
+	}
 
-	db, err := sql.Open("postgres", dsn)
+	db, err := sql.Open("postgres", dsn(user, password, schema))
 	if err != nil {
-		return nil, err
+		return nil, maskError(err)
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	var policy ConnectRetryPolicy
+	if len(connectRetry) > 0 {
+		policy = connectRetry[0]
+	}
+	if err := pingWithRetry(context.Background(), db, policy, runLogger{}); err != nil {
+		return nil, maskError(err)
 	}
 
 	return &PostgresDriver{
 		db:                 db,
 		migrationTableName: "migrations",
+		dsn:                dsn,
+		user:               user,
+		password:           password,
+		schema:             schema,
+		endpoint:           fmt.Sprintf("%s:%s/%s", host, port, database),
 	}, nil
 }
 
@@ -54,72 +77,424 @@ func (p *PostgresDriver) Close() error {
 	return nil
 }
 
+// waitForDatabase pings the database until it accepts connections, as policy
+// says.
+func (p *PostgresDriver) waitForDatabase(ctx context.Context, policy ConnectRetryPolicy) error {
+	return maskError(pingWithRetry(ctx, p.db, policy, p.logger))
+}
+
+// configure copies the relevant Config fields into the driver options, setting
+// lock_timeout and statement_timeout on the connections migrations run on.
+// Scripts run outside of transactions apply all or nothing, as Postgres runs
+// the statements of a single Exec in an implicit transaction, so they are
+// retried in place on transient errors.
+func (p *PostgresDriver) configure(config *Config) {
+	p.driverOptions.configure(config)
+	p.atomicStatements = true
+	if config.DDLLockTimeout > 0 {
+		p.sessionSettings = append(p.sessionSettings, sessionSetting{
+			set:   fmt.Sprintf("SET lock_timeout = %d", ceilUnits(config.DDLLockTimeout, time.Millisecond)),
+			reset: "RESET lock_timeout",
+		})
+	}
+	if config.StatementTimeout > 0 {
+		p.sessionSettings = append(p.sessionSettings, sessionSetting{
+			set:   fmt.Sprintf("SET statement_timeout = %d", ceilUnits(config.StatementTimeout, time.Millisecond)),
+			reset: "RESET statement_timeout",
+		})
+	}
+}
+
 // SetMigrationTableName sets the name of the table used to track executed migrations.
-// If the provided name is empty, the default "migrations" is used.
+// If the provided name is empty, the default "migrations" is used. The name may
+// be qualified by its schema, e.g. "infra.migrations". It is folded to lower
+// case, as Postgres does with unquoted names, so quoting it still finds the
+// tracking tables created before it was quoted.
 func (p *PostgresDriver) SetMigrationTableName(name string) {
 	if name == "" {
 		name = "migrations"
 	}
-	p.migrationTableName = name
+	p.migrationTableName = strings.ToLower(name)
+}
+
+// quotedTableName returns the migration table name quoted for use in SQL.
+func (p *PostgresDriver) quotedTableName() string {
+	return quoteIdentifier(p.dialect(), p.migrationTableName)
+}
+
+// Ping verifies the connection to the database is still alive.
+func (p *PostgresDriver) Ping(ctx context.Context) error {
+	return maskError(p.db.PingContext(ctx))
+}
+
+func (p *PostgresDriver) dialect() Dialect {
+	return DialectPostgres
 }
 
 // CreateMigrationsTable creates the migration tracking table if it does not exist.
 func (p *PostgresDriver) CreateMigrationsTable(ctx context.Context) error {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	existing, err := p.trackingColumns(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s (
-			name VARCHAR(255) PRIMARY KEY NOT NULL,
-			executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			name VARCHAR(255) PRIMARY KEY NOT NULL CHECK (name <> ''),
+			executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
-	`, p.migrationTableName)
-	_, err := p.db.ExecContext(ctx, query)
-	return err
+	`, p.quotedTableName())
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	if err := addTrackingColumns(ctx, p.db, p.dialect(), p.migrationTableName, existing); err != nil {
+		return err
+	}
+
+	// New tables get their indexes right away, existing ones through
+	// UpgradeMigrationsTable.
+	if len(existing) == 0 {
+		return p.createTrackingIndexes(ctx)
+	}
+	return nil
+}
+
+// UpgradeMigrationsTable adds the indexes and constraints that tracking tables
+// created by older versions are missing.
+func (p *PostgresDriver) UpgradeMigrationsTable(ctx context.Context) error {
+	if err := p.CreateMigrationsTable(ctx); err != nil {
+		return err
+	}
+	if err := p.createTrackingIndexes(ctx); err != nil {
+		return err
+	}
+
+	// Older versions allowed a NULL executed_at.
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET executed_at = CURRENT_TIMESTAMP WHERE executed_at IS NULL`, p.quotedTableName())); err != nil {
+		return fmt.Errorf("failed to backfill executed_at: %w", err)
+	}
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN executed_at SET NOT NULL`, p.quotedTableName())); err != nil {
+		return fmt.Errorf("failed to add NOT NULL constraint to executed_at: %w", err)
+	}
+
+	return nil
+}
+
+// trackingColumns returns the column names of the tracking table, which are
+// empty if it does not exist yet.
+func (p *PostgresDriver) trackingColumns(ctx context.Context) (map[string]bool, error) {
+	schema, table := splitTableName(p.migrationTableName)
+	columns, err := queryNameSet(ctx, p.db, `SELECT column_name FROM information_schema.columns WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2`, schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", p.migrationTableName, err)
+	}
+	return columns, nil
+}
+
+// trackingTableExists reports whether the tracking table has been created.
+func (p *PostgresDriver) trackingTableExists(ctx context.Context) (bool, error) {
+	columns, err := p.trackingColumns(ctx)
+	return len(columns) > 0, err
+}
+
+func (p *PostgresDriver) trackingTableDDL() []string {
+	return trackingTableStatements(p.dialect(), p.migrationTableName)
+}
+
+// createTrackingIndexes creates the tracking table indexes that do not exist yet.
+func (p *PostgresDriver) createTrackingIndexes(ctx context.Context) error {
+	schema, table := splitTableName(p.migrationTableName)
+	indexes, err := queryNameSet(ctx, p.db, `SELECT indexname FROM pg_indexes WHERE schemaname = COALESCE(NULLIF($1, ''), current_schema()) AND tablename = $2`, schema, table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect indexes of %s: %w", p.migrationTableName, err)
+	}
+	return addTrackingIndexes(ctx, p.db, p.dialect(), p.migrationTableName, indexes)
+}
+
+// AcquireLock takes a session-level advisory lock keyed by the migration table name.
+// The lock is held on a dedicated connection until ReleaseLock is called.
+func (p *PostgresDriver) AcquireLock(ctx context.Context) error {
+	if p.lockConn != nil {
+		return errors.New("migration lock already held")
+	}
+
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, p.lockKey()); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	p.lockConn = conn
+	return nil
 }
 
-// GetExecutedMigrations returns a list of executed migrations from the tracking table.
-// If reverse is true, the list is ordered descending by name.
-func (p *PostgresDriver) GetExecutedMigrations(ctx context.Context, reverse bool) ([]ExecutedMigration, error) {
-	order := "ASC"
-	if reverse {
-		order = "DESC"
+// ReleaseLock releases the advisory lock and returns its connection to the pool.
+func (p *PostgresDriver) ReleaseLock(ctx context.Context) error {
+	if p.lockConn == nil {
+		return nil
 	}
-	query := fmt.Sprintf(`SELECT name, executed_at FROM %s ORDER BY name %s;`, p.migrationTableName, order)
 
+	conn := p.lockConn
+	p.lockConn = nil
+	defer conn.Close()
+
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, p.lockKey())
+	return err
+}
+
+// lockKey maps the migration lock name to the bigint key used by advisory locks.
+func (p *PostgresDriver) lockKey() int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(migrationLockName(p.migrationTableName, p.lockScope)))
+	return int64(h.Sum64())
+}
+
+// GetExecutedMigrations returns the executed migrations from the tracking table
+// sorted according to order.
+func (p *PostgresDriver) GetExecutedMigrations(ctx context.Context, order HistoryOrder) ([]ExecutedMigration, error) {
+	return collectExecutedMigrations(func(fn func(migration ExecutedMigration) error) error {
+		return p.IterateExecutedMigrations(ctx, order, fn)
+	})
+}
+
+// IterateExecutedMigrations streams the executed migrations sorted according to order.
+// The tracking timeout bounds the query, not the iteration, so fn may take its
+// time.
+func (p *PostgresDriver) IterateExecutedMigrations(ctx context.Context, order HistoryOrder, fn func(migration ExecutedMigration) error) error {
+	ctx, started, cancel := p.trackingQueryContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s;`, executedMigrationColumns, p.quotedTableName(), historyOrderClause(order))
 	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	started()
+
+	return scanExecutedMigrations(rows, fn)
+}
+
+// GetExecutedMigrationsPage returns one page of the executed migrations sorted according to order.
+func (p *PostgresDriver) GetExecutedMigrationsPage(ctx context.Context, order HistoryOrder, limit, offset int) ([]ExecutedMigration, error) {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s LIMIT $1 OFFSET $2;`, executedMigrationColumns, p.quotedTableName(), historyOrderClause(order))
+	rows, err := p.db.QueryContext(ctx, query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var migrations []ExecutedMigration
+	return collectExecutedMigrations(func(fn func(migration ExecutedMigration) error) error {
+		return scanExecutedMigrations(rows, fn)
+	})
+}
 
-	for rows.Next() {
-		var name string
-		var executedAt time.Time
-		if err := rows.Scan(&name, &executedAt); err != nil {
-			return nil, err
-		}
-		migrations = append(migrations, ExecutedMigration{
-			Name:       name,
-			ExecutedAt: executedAt,
-		})
+// InsertExecutedMigration records a migration without running the up script.
+func (p *PostgresDriver) InsertExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	return p.insertExecutedMigration(ctx, p.db, record)
+}
+
+// UpdateExecutedMigration overwrites the execution time and checksum of a record.
+func (p *PostgresDriver) UpdateExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %s SET executed_at = $1, checksum = $2 WHERE name = $3`, p.quotedTableName())
+	_, err := p.db.ExecContext(ctx, query, record.ExecutedAt, nullableString(record.Checksum), record.Name)
+	return err
+}
+
+// RemoveExecutedMigration deletes a record without running the down script.
+func (p *PostgresDriver) RemoveExecutedMigration(ctx context.Context, name string) error {
+	return p.removeExecutedMigration(ctx, p.db, name)
+}
+
+// GetManifestHash returns the stored manifest hash, empty if none is stored.
+func (p *PostgresDriver) GetManifestHash(ctx context.Context) (string, error) {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	return getManifestHash(ctx, p.db, p.dialect(), p.migrationTableName)
+}
+
+// SetManifestHash stores the manifest hash, or removes it when hash is empty.
+func (p *PostgresDriver) SetManifestHash(ctx context.Context, hash string) error {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	return setManifestHash(ctx, p.db, p.dialect(), p.migrationTableName, `INSERT INTO %s (id, manifest_hash) VALUES (1, $1) ON CONFLICT (id) DO UPDATE SET manifest_hash = EXCLUDED.manifest_hash`, hash)
+}
+
+// AppendAuditEvent appends event to the audit log table.
+func (p *PostgresDriver) AppendAuditEvent(ctx context.Context, event AuditEvent) error {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	return appendAuditEvent(ctx, p.db, p.dialect(), p.migrationTableName, `INSERT INTO %s (occurred_at, operation, actor, migrations, outcome, error) VALUES ($1, $2, $3, $4, $5, $6)`, event)
+}
+
+// InspectSchema returns the tables of the current schema and their columns.
+func (p *PostgresDriver) InspectSchema(ctx context.Context) (Schema, error) {
+	return inspectSchema(ctx, p.db, p.migrationTableName, `
+		SELECT c.table_name, c.column_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`)
+}
+
+// InspectER returns the tables of the current schema, their columns and
+// foreign keys.
+func (p *PostgresDriver) InspectER(ctx context.Context) (ERModel, error) {
+	return inspectER(ctx, p.db, p.migrationTableName, `
+		SELECT c.table_name, c.column_name, format_type(a.atttypid, a.atttypmod), c.is_nullable = 'YES',
+			EXISTS (
+				SELECT 1
+				FROM information_schema.table_constraints tc
+				JOIN information_schema.key_column_usage k ON k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name
+				WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema AND tc.table_name = c.table_name AND k.column_name = c.column_name
+			)
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = format('%I.%I', c.table_schema, c.table_name)::regclass AND a.attname = c.column_name
+		WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position
+	`, `
+		SELECT k.constraint_name, k.table_name, k.column_name, r.table_name, r.column_name
+		FROM information_schema.referential_constraints rc
+		JOIN information_schema.key_column_usage k ON k.constraint_schema = rc.constraint_schema AND k.constraint_name = rc.constraint_name
+		JOIN information_schema.key_column_usage r ON r.constraint_schema = rc.unique_constraint_schema AND r.constraint_name = rc.unique_constraint_name AND r.ordinal_position = k.position_in_unique_constraint
+		WHERE k.table_schema = current_schema()
+		ORDER BY k.table_name, k.constraint_name, k.ordinal_position
+	`)
+}
+
+// InspectPlatform tells Aurora PostgreSQL and AlloyDB from Postgres, and
+// reports standbys, such as Aurora replicas and AlloyDB read pool instances,
+// and sessions defaulting to read-only transactions as read-only.
+func (p *PostgresDriver) InspectPlatform(ctx context.Context) (PlatformInfo, error) {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	info := PlatformInfo{Platform: PlatformPostgres, Endpoint: p.endpoint}
+	var inRecovery, readOnlyDefault, aurora, alloyDB bool
+	err := p.db.QueryRowContext(ctx, `
+		SELECT
+			pg_is_in_recovery(),
+			current_setting('default_transaction_read_only') = 'on',
+			EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'aurora_version'),
+			EXISTS (SELECT 1 FROM pg_settings WHERE name LIKE 'alloydb.%'),
+			current_setting('server_version'),
+			current_setting('server_version_num')::int >= 110000
+	`).Scan(&inRecovery, &readOnlyDefault, &aurora, &alloyDB, &info.Version, &info.FastDDL)
+	if err != nil {
+		return PlatformInfo{}, fmt.Errorf("failed to inspect platform: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	switch {
+	case aurora:
+		info.Platform = PlatformAuroraPostgres
+	case alloyDB:
+		info.Platform = PlatformAlloyDB
+	}
+	switch {
+	case inRecovery:
+		info.ReadOnly, info.ReadOnlyReason = true, "in recovery"
+	case readOnlyDefault:
+		info.ReadOnly, info.ReadOnlyReason = true, "default_transaction_read_only is on"
 	}
+	return info, nil
+}
+
+// GolangMigrateVersion returns the version recorded by golang-migrate.
+func (p *PostgresDriver) GolangMigrateVersion(ctx context.Context) (int64, bool, error) {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	return golangMigrateVersion(ctx, p.db)
+}
+
+// GooseVersions returns the history recorded by goose.
+func (p *PostgresDriver) GooseVersions(ctx context.Context) ([]GooseVersion, error) {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
 
-	return migrations, nil
+	return gooseVersions(ctx, p.db)
 }
 
-// CleanDatabase drops all tables in the "public" schema.
+// FlywayHistory returns the history recorded by Flyway.
+func (p *PostgresDriver) FlywayHistory(ctx context.Context) ([]FlywayMigration, error) {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	return flywayHistory(ctx, p.db)
+}
+
+// CleanDatabase drops all tables in the current schema, the first of the
+// search path: "public" by default, the schema of the tenant for ForTenant.
 func (p *PostgresDriver) CleanDatabase(ctx context.Context) error {
+	return p.CleanDatabaseChunked(ctx, CleanOptions{}, nil)
+}
+
+// CleanDatabaseChunked drops all tables in the current schema, a chunk at a
+// time. Chunks are dropped one after the other whatever
+// Config.CleanParallelism says, as CASCADE locks the tables referencing each
+// chunk and concurrent chunks could deadlock.
+func (p *PostgresDriver) CleanDatabaseChunked(ctx context.Context, opts CleanOptions, onChunk func(tables []string, dropped, total int)) error {
+	tables, err := p.cleanTables(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(tables) == 0 {
+		p.logger.info("no tables to drop", "no tables to drop")
+		return nil
+	}
+
+	err = p.cleanInChunks(ctx, tables, 1, onChunk, func(ctx context.Context, chunks *tableChunks) error {
+		for chunk, ok := chunks.take(); ok; chunk, ok = chunks.take() {
+			quoted := make([]string, len(chunk))
+			for i, table := range chunk {
+				quoted[i] = fmt.Sprintf(`"%s"`, table) // safely quote identifiers
+			}
+
+			stmtCtx, cancel := p.statementContext(ctx)
+			_, err := p.db.ExecContext(stmtCtx, fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE;`, strings.Join(quoted, ", ")))
+			cancel()
+			if err != nil {
+				return fmt.Errorf("drop tables: %w", err)
+			}
+			chunks.done(chunk)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.logger.info("all tables of the current schema dropped", "all tables dropped", "tables", len(tables))
+	return nil
+}
+
+// cleanTables returns the tables CleanDatabaseChunked drops with opts.
+func (p *PostgresDriver) cleanTables(ctx context.Context, opts CleanOptions) ([]string, error) {
 	rows, err := p.db.QueryContext(ctx, `
 		SELECT tablename
 		FROM pg_tables
-		WHERE schemaname = 'public';
+		WHERE schemaname = current_schema();
 	`)
 	if err != nil {
-		return fmt.Errorf("query table names: %w", err)
+		return nil, fmt.Errorf("query table names: %w", err)
 	}
 	defer rows.Close()
 
@@ -127,23 +502,14 @@ func (p *PostgresDriver) CleanDatabase(ctx context.Context) error {
 	for rows.Next() {
 		var table string
 		if err := rows.Scan(&table); err != nil {
-			return fmt.Errorf("scan table name: %w", err)
+			return nil, fmt.Errorf("scan table name: %w", err)
 		}
-		tables = append(tables, fmt.Sprintf(`"%s"`, table)) // safely quote identifiers
-	}
-
-	if len(tables) == 0 {
-		log.Println("no tables to drop")
-		return nil
-	}
-
-	query := fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE;`, strings.Join(tables, ", "))
-	if _, err := p.db.ExecContext(ctx, query); err != nil {
-		return fmt.Errorf("drop tables: %w", err)
+		if p.keepOnClean(table, p.migrationTableName, opts) {
+			continue
+		}
+		tables = append(tables, table)
 	}
-
-	log.Println("all public tables dropped")
-	return nil
+	return tables, rows.Err()
 }
 
 // ApplyMigrations runs the "up" SQL scripts for the given migrations.
@@ -155,25 +521,71 @@ func (p *PostgresDriver) ApplyMigrations(
 	onSuccess func(migration *Migration),
 	onFailed func(migration *Migration, err error),
 ) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	batch, err := p.lastBatch(ctx, p.db, p.dialect(), p.migrationTableName)
+	if err != nil {
+		return err
+	}
+	batch++
+
+	failures := &BatchError{}
+
 	for i := range migrations {
 		m := migrations[i]
+		upScript, _ := dialectScripts(m, DialectPostgres)
+
+		skipped, err := p.skipMigration(ctx, p, p.db, m, batch, p.insertExecutedMigration)
+		if skipped {
+			continue
+		}
 
 		if onRunning != nil {
 			onRunning(&m)
 		}
 
-		if err := p.executeMigrationSQL(ctx, m.UpScript()); err != nil {
-			if onFailed != nil {
-				onFailed(&m, err)
-			}
-			return fmt.Errorf("failed to apply migration %s: %w", m.Name(), err)
+		stage := StageCondition
+		if err == nil {
+			stage = StageConnect
+			err = p.withCredentialRefresh(ctx, isPostgresAuthError, p.reconnect, func() error {
+				// Pooled connections may have died while the previous migration ran.
+				if i > 0 {
+					if err := p.ensureConnection(ctx, p.db); err != nil {
+						return err
+					}
+				}
+				return p.runMigration(ctx, p.db, m, nil, func(ctx context.Context, ex execer) error {
+					stage = StageExecute
+					if err := p.executeMigrationSQL(ctx, ex, m.Name(), upScript, isNonTransactional(m)); err != nil {
+						return fmt.Errorf("failed to apply migration %s: %w", m.Name(), err)
+					}
+					stage = StageRecord
+					if err := p.insertExecutedMigration(ctx, ex, ExecutedMigration{
+						Name:       m.Name(),
+						ExecutedAt: time.Now(),
+						Checksum:   migrationChecksum(m),
+						Batch:      batch,
+						AppliedBy:  p.appliedBy,
+						Build:      p.build,
+						Namespace:  migrationSetOf(m),
+					}); err != nil {
+						return fmt.Errorf("failed to record migration %s: %w", m.Name(), err)
+					}
+					return nil
+				})
+			})
 		}
-
-		if err := p.insertExecutedMigration(ctx, m.Name(), time.Now()); err != nil {
+		if err != nil {
+			err = migrationFailure(m, DirectionUp, err)
 			if onFailed != nil {
 				onFailed(&m, err)
 			}
-			return fmt.Errorf("failed to record migration %s: %w", m.Name(), err)
+			if err := p.failBatch(ctx, failures, BatchFailure{Migration: m.Name(), Stage: stage, Err: err}); err != nil {
+				return err
+			}
+			continue
 		}
 
 		if onSuccess != nil {
@@ -181,7 +593,20 @@ func (p *PostgresDriver) ApplyMigrations(
 		}
 	}
 
-	return nil
+	return failures.orNil()
+}
+
+// RunSeeder runs the script of seeder like a migration, without recording it.
+func (p *PostgresDriver) RunSeeder(ctx context.Context, seeder Seeder) error {
+	return p.runMigration(ctx, p.db, seederMigration{seeder: seeder}, nil, func(ctx context.Context, ex execer) error {
+		return p.executeMigrationSQL(ctx, ex, seeder.Name(), seeder.SeedScript(), false)
+	})
+}
+
+// LoadFixtures inserts the rows of fixtures in a single transaction, deleting
+// the rows of their tables first with truncate.
+func (p *PostgresDriver) LoadFixtures(ctx context.Context, fixtures []Fixture, truncate bool) error {
+	return loadFixtures(ctx, p.db, DialectPostgres, fixtures, truncate)
 }
 
 // UnapplyMigrations runs the "down" SQL scripts for the given migrations in reverse order.
@@ -195,23 +620,35 @@ func (p *PostgresDriver) UnapplyMigrations(
 ) error {
 	for i := range migrations {
 		mig := migrations[i]
+		_, downScript := dialectScripts(mig, DialectPostgres)
 
 		if onRunning != nil {
 			onRunning(&mig)
 		}
 
-		if err := p.executeMigrationSQL(ctx, mig.DownScript()); err != nil {
-			if onFailed != nil {
-				onFailed(&mig, err)
+		err := p.withCredentialRefresh(ctx, isPostgresAuthError, p.reconnect, func() error {
+			// Pooled connections may have died while the previous migration ran.
+			if i > 0 {
+				if err := p.ensureConnection(ctx, p.db); err != nil {
+					return err
+				}
 			}
-			return fmt.Errorf("failed to unapply migration %s: %w", mig.Name(), err)
-		}
-
-		if err := p.removeExecutedMigration(ctx, mig.Name()); err != nil {
+			return p.runMigration(ctx, p.db, mig, nil, func(ctx context.Context, ex execer) error {
+				if err := p.executeMigrationSQL(ctx, ex, mig.Name(), downScript, isNonTransactional(mig)); err != nil {
+					return fmt.Errorf("failed to unapply migration %s: %w", mig.Name(), err)
+				}
+				if err := p.removeExecutedMigration(ctx, ex, mig.Name()); err != nil {
+					return fmt.Errorf("failed to remove migration record %s: %w", mig.Name(), err)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			err = migrationFailure(mig, DirectionDown, err)
 			if onFailed != nil {
 				onFailed(&mig, err)
 			}
-			return fmt.Errorf("failed to remove migration record %s: %w", mig.Name(), err)
+			return err
 		}
 
 		if onSuccess != nil {
@@ -222,26 +659,136 @@ func (p *PostgresDriver) UnapplyMigrations(
 	return nil
 }
 
-// executeMigrationSQL runs a given SQL script as part of a migration.
-func (p *PostgresDriver) executeMigrationSQL(ctx context.Context, sql string) error {
+// reconnect replaces the connection pool with one authenticated by the given credentials.
+func (p *PostgresDriver) reconnect(ctx context.Context, user, password string) error {
+	if p.dsn == nil {
+		return errors.New("driver was not created with connection details")
+	}
+
+	db, err := sql.Open("postgres", p.dsn(user, password, p.schema))
+	if err != nil {
+		return maskError(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return maskError(err)
+	}
+
+	old := p.db
+	p.db = db
+	p.user, p.password = user, password
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
+
+// ForTenant connects to the database of p with the search_path set to the
+// schema named after tenant, creating the schema if it does not exist. The
+// schema is quoted in both, so a tenant such as Acme keeps its case instead of
+// being created as "Acme" and searched as acme.
+func (p *PostgresDriver) ForTenant(ctx context.Context, tenant string) (Driver, error) {
+	if err := validateTenant(tenant); err != nil {
+		return nil, err
+	}
+	if p.dsn == nil {
+		return nil, errors.New("driver was not created with connection details")
+	}
+
+	schema := quoteIdentifier(DialectPostgres, tenant)
+	db, err := sql.Open("postgres", p.dsn(p.user, p.password, schema))
+	if err != nil {
+		return nil, maskError(err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, maskError(err)
+	}
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create schema of tenant %s: %w", tenant, err)
+	}
+
+	return &PostgresDriver{
+		driverOptions:      p.driverOptions,
+		db:                 db,
+		migrationTableName: p.migrationTableName,
+		dsn:                p.dsn,
+		user:               p.user,
+		password:           p.password,
+		schema:             schema,
+		endpoint:           p.endpoint + "#" + tenant,
+	}, nil
+}
+
+// isPostgresAuthError reports whether err was caused by rejected credentials.
+func isPostgresAuthError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "28P01" || pqErr.Code == "28000"
+}
+
+// executeMigrationSQL runs a given SQL script as part of the named migration,
+// after passing it through the statement rewriter. With split, the script runs
+// one statement at a time, each rewritten on its own, as Postgres runs the statements of a single Exec in
+// an implicit transaction, which statements such as CREATE INDEX CONCURRENTLY
+// refuse; a failing statement is then reported as a *StatementError.
+func (p *PostgresDriver) executeMigrationSQL(ctx context.Context, ex execer, name, sql string, split bool) error {
 	if sql == "" {
 		return nil
 	}
 
-	_, err := p.db.ExecContext(ctx, sql)
-	return err
+	sql, err := p.prepareScript(name, sql)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := p.statementContext(ctx)
+	defer cancel()
+
+	if !split {
+		if sql, err = p.rewriteStatement(DialectPostgres, name, sql); err != nil {
+			return err
+		}
+		_, err = ex.ExecContext(ctx, sql)
+		return err
+	}
+	for i, statement := range splitStatements(sql, DialectPostgres) {
+		query, err := p.rewriteStatement(DialectPostgres, name, statement.sql)
+		if err != nil {
+			return err
+		}
+		if _, err := ex.ExecContext(ctx, query); err != nil {
+			return &StatementError{Migration: name, Index: i + 1, Line: statement.line, Statement: query, Err: err}
+		}
+	}
+	return nil
+}
+
+// squashExecutedMigrations replaces the records of squashed with that of
+// their baseline in a single transaction.
+func (p *PostgresDriver) squashExecutedMigrations(ctx context.Context, squashed []string, baseline ExecutedMigration) error {
+	return replaceExecutedMigrations(ctx, p.db, squashed, baseline, p.insertExecutedMigration, p.removeExecutedMigration)
 }
 
-// insertExecutedMigration records the given migration name and execution time in the tracking table.
-func (p *PostgresDriver) insertExecutedMigration(ctx context.Context, name string, executedAt time.Time) error {
-	query := fmt.Sprintf(`INSERT INTO %s (name, executed_at) VALUES ($1, $2)`, p.migrationTableName)
-	_, err := p.db.ExecContext(ctx, query, name, executedAt)
+// insertExecutedMigration records the given migration in the tracking table.
+func (p *PostgresDriver) insertExecutedMigration(ctx context.Context, ex execer, record ExecutedMigration) error {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`INSERT INTO %s (name, executed_at, checksum, batch, applied_by, namespace, build, skipped) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, p.quotedTableName())
+	_, err := ex.ExecContext(ctx, query, record.Name, record.ExecutedAt, nullableString(record.Checksum), nullableInt(record.Batch), nullableString(record.AppliedBy), nullableString(record.Namespace), nullableBuild(record.Build), nullableBool(record.Skipped))
 	return err
 }
 
 // removeExecutedMigration deletes the record of the given migration from the tracking table.
-func (p *PostgresDriver) removeExecutedMigration(ctx context.Context, name string) error {
-	query := fmt.Sprintf(`DELETE FROM %s WHERE name = $1`, p.migrationTableName)
-	_, err := p.db.ExecContext(ctx, query, name)
+func (p *PostgresDriver) removeExecutedMigration(ctx context.Context, ex execer, name string) error {
+	ctx, cancel := p.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE name = $1`, p.quotedTableName())
+	_, err := ex.ExecContext(ctx, query, name)
 	return err
 }
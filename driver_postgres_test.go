@@ -3,10 +3,15 @@ package gomigration
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,7 +45,19 @@ func TestCreateMigrationsTablePostgresDriver(t *testing.T) {
 	db, mock, driver := setupMockDBPostgres(t)
 	defer db.Close()
 
-	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS migrations`).WillReturnResult(sqlmock.NewResult(1, 1))
+	// Simulate creating the table from scratch
+	mock.ExpectQuery(`SELECT column_name FROM information_schema\.columns WHERE table_schema = COALESCE\(NULLIF\(\$1, ''\), current_schema\(\)\) AND table_name = \$2`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS \"migrations\"").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN checksum VARCHAR\(64\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN batch INTEGER`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN applied_by VARCHAR\(255\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN namespace VARCHAR\(255\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN build TEXT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN skipped BOOLEAN`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT indexname FROM pg_indexes WHERE schemaname = COALESCE\(NULLIF\(\$1, ''\), current_schema\(\)\) AND tablename = \$2`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"indexname"}))
+	mock.ExpectExec(`CREATE INDEX migrations_executed_at_idx ON "migrations" \(executed_at, name\)`).WillReturnResult(sqlmock.NewResult(0, 0))
 
 	err := driver.CreateMigrationsTable(context.Background())
 	assert.NoError(t, err)
@@ -61,19 +78,160 @@ func TestGetExecutedMigrationsPostgresDriver(t *testing.T) {
 	db, mock, driver := setupMockDBPostgres(t)
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"name", "executed_at"}).
-		AddRow("migration_1", time.Now()).
-		AddRow("migration_2", time.Now())
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_1", time.Now(), "abc", 1, "ci", nil, nil, nil).
+		AddRow("migration_2", time.Now(), nil, 1, "ci", nil, nil, nil)
 
-	mock.ExpectQuery(`SELECT name, executed_at FROM migrations ORDER BY name ASC;`).
+	mock.ExpectQuery(`SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM "migrations" ORDER BY executed_at ASC, name ASC;`).
 		WillReturnRows(rows)
 
-	migrations, err := driver.GetExecutedMigrations(context.Background(), false)
+	migrations, err := driver.GetExecutedMigrations(context.Background(), HistoryOrderApplied)
 	assert.NoError(t, err)
 	assert.Len(t, migrations, 2)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestIterateExecutedMigrationsPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_1", time.Now(), "abc", 1, "ci", nil, nil, nil).
+		AddRow("migration_2", time.Now(), nil, 1, "ci", nil, nil, nil)
+
+	mock.ExpectQuery(`SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM "migrations" ORDER BY executed_at ASC, name ASC;`).
+		WillReturnRows(rows)
+
+	// Stopping early must not read further rows.
+	stop := errors.New("stop")
+	var seen []string
+	err := driver.IterateExecutedMigrations(context.Background(), HistoryOrderApplied, func(m ExecutedMigration) error {
+		seen = append(seen, m.Name)
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []string{"migration_1"}, seen)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIterateExecutedMigrationsPostgresDriver_SlowCallback(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.trackingTimeout = 20 * time.Millisecond
+
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_1", time.Now(), "abc", 1, "ci", nil, nil, nil).
+		AddRow("migration_2", time.Now(), nil, 1, "ci", nil, nil, nil)
+
+	mock.ExpectQuery(`SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM "migrations" ORDER BY executed_at ASC, name ASC;`).
+		WillReturnRows(rows)
+
+	// The tracking timeout bounds the query, not the time spent in fn.
+	var seen []string
+	err := driver.IterateExecutedMigrations(context.Background(), HistoryOrderApplied, func(m ExecutedMigration) error {
+		time.Sleep(50 * time.Millisecond)
+		seen = append(seen, m.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"migration_1", "migration_2"}, seen)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetExecutedMigrationsPagePostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_3", time.Now(), nil, 1, "ci", nil, nil, nil)
+
+	mock.ExpectQuery(`SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM "migrations" ORDER BY executed_at DESC, name DESC LIMIT \$1 OFFSET \$2;`).
+		WithArgs(1, 2).
+		WillReturnRows(rows)
+
+	migrations, err := driver.GetExecutedMigrationsPage(context.Background(), HistoryOrderAppliedDesc, 1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetExecutedMigrationsNullExecutedAtPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_1", nil, nil, nil, nil, nil, nil, nil)
+
+	mock.ExpectQuery(`SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM "migrations" ORDER BY name ASC;`).
+		WillReturnRows(rows)
+
+	migrations, err := driver.GetExecutedMigrations(context.Background(), HistoryOrderName)
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 1)
+	assert.True(t, migrations[0].ExecutedAt.IsZero())
+	assert.Empty(t, migrations[0].Checksum)
+	assert.Zero(t, migrations[0].Batch)
+}
+
+func TestUpdateExecutedMigrationPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	executedAt := time.Now()
+	mock.ExpectExec(`UPDATE "migrations" SET executed_at = \$1, checksum = \$2 WHERE name = \$3`).
+		WithArgs(executedAt, "abc", "migration_1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "migrations" WHERE name = \$1`).WithArgs("migration_2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	assert.NoError(t, driver.UpdateExecutedMigration(ctx, ExecutedMigration{Name: "migration_1", ExecutedAt: executedAt, Checksum: "abc"}))
+	assert.NoError(t, driver.RemoveExecutedMigration(ctx, "migration_2"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestManifestHashPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT manifest_hash FROM "migrations_manifest" WHERE id = 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"manifest_hash"}))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migrations_manifest"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations_manifest" \(id, manifest_hash\) VALUES \(1, \$1\) ON CONFLICT \(id\) DO UPDATE`).WithArgs("abc").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migrations_manifest"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "migrations_manifest"`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	hash, err := driver.GetManifestHash(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, hash)
+	assert.NoError(t, driver.SetManifestHash(ctx, "abc"))
+	assert.NoError(t, driver.SetManifestHash(ctx, ""))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendAuditEventPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	occurredAt := time.Now()
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migrations_audit"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations_audit" \(occurred_at, operation, actor, migrations, outcome, error\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6\)`).
+		WithArgs(occurredAt, "migrate", "ci", "001_a,002_b", "succeeded", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := driver.AppendAuditEvent(context.Background(), AuditEvent{
+		Time:       occurredAt,
+		Operation:  OperationMigrate,
+		Actor:      "ci",
+		Migrations: []string{"001_a", "002_b"},
+		Outcome:    AuditOutcomeSucceeded,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestCleanDatabasePostgresDriver(t *testing.T) {
 	db, mock, driver := setupMockDBPostgres(t)
 	defer db.Close()
@@ -83,7 +241,7 @@ func TestCleanDatabasePostgresDriver(t *testing.T) {
 		AddRow("table1").
 		AddRow("table2")
 
-	mock.ExpectQuery(`SELECT tablename FROM pg_tables WHERE schemaname = 'public';`).
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables WHERE schemaname = current_schema\(\);`).
 		WillReturnRows(tableRows)
 
 	// Mock dropping tables
@@ -95,6 +253,62 @@ func TestCleanDatabasePostgresDriver(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestCleanDatabaseTenantPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	// A driver returned by ForTenant connects with the tenant schema as its
+	// search path, so the current schema is the tenant's, not public.
+	driver.schema = `"acme"`
+
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables WHERE schemaname = current_schema\(\);`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("orders").AddRow("migrations"))
+	mock.ExpectExec(`DROP TABLE IF EXISTS "orders" CASCADE;`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.CleanDatabaseChunked(context.Background(), CleanOptions{KeepHistory: true}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCleanDatabaseChunkedPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.cleanBatchSize = 2
+	driver.cleanParallelism = 4
+
+	mock.ExpectQuery(`SELECT tablename FROM pg_tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename"}).AddRow("table1").AddRow("table2").AddRow("table3"))
+	mock.ExpectExec(`DROP TABLE IF EXISTS "table1", "table2" CASCADE;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DROP TABLE IF EXISTS "table3" CASCADE;`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var chunks [][]string
+	err := driver.CleanDatabaseChunked(context.Background(), CleanOptions{}, func(tables []string, dropped, total int) {
+		chunks = append(chunks, tables)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"table1", "table2"}, {"table3"}}, chunks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewConnectRetryPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mock.ExpectPing().WillReturnError(errors.New("the database system is starting up"))
+	mock.ExpectPing().WillReturnError(errors.New("the database system is starting up"))
+	mock.ExpectPing()
+
+	_, err := New(&Config{Driver: driver, ConnectRetry: ConnectRetryPolicy{Attempts: 2, Interval: time.Millisecond}})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	_, err = New(&Config{Driver: driver, ConnectRetry: ConnectRetryPolicy{Attempts: 1, Interval: time.Millisecond}})
+	assert.EqualError(t, err, "failed to connect to the database: connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestApplyMigrationsPostgresDriver(t *testing.T) {
 	db, mock, driver := setupMockDBPostgres(t)
 	defer db.Close()
@@ -104,9 +318,11 @@ func TestApplyMigrationsPostgresDriver(t *testing.T) {
 		up:   "CREATE TABLE test (id INT);",
 		down: "DROP TABLE test;",
 	}
+	driver.appliedBy = "ci"
 
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
 	mock.ExpectExec("CREATE TABLE test \\(id INT\\);").WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(`INSERT INTO migrations`).WithArgs("migration1", sqlmock.AnyArg()).
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "ci", nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
@@ -125,7 +341,7 @@ func TestUnapplyMigrationsPostgresDriver(t *testing.T) {
 	}
 
 	mock.ExpectExec(mig.down).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(`DELETE FROM migrations WHERE name = \$1`).WithArgs(mig.name).
+	mock.ExpectExec(`DELETE FROM "migrations" WHERE name = \$1`).WithArgs(mig.name).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := driver.UnapplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
@@ -133,25 +349,412 @@ func TestUnapplyMigrationsPostgresDriver(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestApplyMigrationsTransactionalPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.useTransactions = true
+
+	mig := &mockMigrationPostgresDriver{
+		name: "migration1",
+		up:   "CREATE TABLE test (id INT);",
+		down: "DROP TABLE test;",
+	}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE test \\(id INT\\);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsSessionTimeoutsPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.configure(&Config{UseTransactions: true, DDLLockTimeout: 1500 * time.Millisecond, StatementTimeout: time.Minute})
+
+	mig := &mockMigrationPostgresDriver{
+		name: "migration1",
+		up:   "ALTER TABLE test ADD name TEXT;",
+		down: "ALTER TABLE test DROP name;",
+	}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec(`^SET lock_timeout = 1500$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^SET statement_timeout = 60000$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE test ADD name TEXT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`^RESET lock_timeout$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^RESET statement_timeout$`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsRetryTransactionalPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.configure(&Config{UseTransactions: true, Retry: RetryPolicy{Attempts: 1, Backoff: time.Millisecond}})
+
+	mig := &mockMigrationPostgresDriver{
+		name: "migration1",
+		up:   "UPDATE counters SET n = n + 1;",
+		down: "UPDATE counters SET n = n - 1;",
+	}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE counters").WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE counters").WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+	mock.ExpectRollback()
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorContains(t, err, "deadlock detected")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsTransactionalRollbackPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.useTransactions = true
+
+	mig := &mockMigrationPostgresDriver{
+		name: "migration1",
+		up:   "CREATE TABLE test (id INT);",
+		down: "DROP TABLE test;",
+	}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE test \\(id INT\\);").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsTimeoutsPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.trackingTimeout = 20 * time.Millisecond
+
+	mig := &mockMigrationPostgresDriver{
+		name: "migration1",
+		up:   "CREATE INDEX test_idx ON test (id);",
+		down: "DROP INDEX test_idx;",
+	}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	// The slow migration statement is not bound by the tracking timeout,
+	// but the hung tracking write is.
+	mock.ExpectExec("CREATE INDEX test_idx").WillDelayFor(50 * time.Millisecond).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorContains(t, err, "failed to record migration migration1")
+}
+
+func TestExecuteMigrationSQLStatementTimeoutPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.statementTimeout = 20 * time.Millisecond
+
+	mock.ExpectExec(`SOME SLOW STATEMENT`).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.executeMigrationSQL(context.Background(), db, "migration_name", "SOME SLOW STATEMENT", false)
+	assert.Error(t, err)
+}
+
+func TestApplyMigrationsMaxDurationPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.useTransactions = true
+	driver.maxMigrationDuration = 20 * time.Millisecond
+
+	mig := &mockMigrationPostgresDriver{name: "migration1", up: "ALTER TABLE test ADD COLUMN hung INT;"}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE test`).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	var failed error
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, func(_ *Migration, err error) { failed = err })
+	assert.ErrorIs(t, err, ErrMigrationTimedOut)
+	assert.ErrorContains(t, err, "migration1 ran longer than 20ms")
+	assert.ErrorIs(t, failed, ErrMigrationTimedOut)
+
+	var deadlineErr *DeadlineError
+	if assert.ErrorAs(t, err, &deadlineErr) {
+		assert.Equal(t, "migration1", deadlineErr.Migration)
+		assert.Equal(t, "ALTER TABLE test ADD COLUMN hung INT;", deadlineErr.Statement)
+		assert.Equal(t, 20*time.Millisecond, deadlineErr.Allowed.Round(10*time.Millisecond))
+		assert.GreaterOrEqual(t, deadlineErr.Elapsed, deadlineErr.Allowed)
+		assert.False(t, deadlineErr.TrackingUpdated)
+	}
+}
+
+func TestApplyMigrationsTrackingDeadlinePostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.trackingTimeout = 20 * time.Millisecond
+
+	mig := &mockMigrationPostgresDriver{name: "migration1", up: "CREATE INDEX CONCURRENTLY idx ON test (id);"}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec(`CREATE INDEX CONCURRENTLY`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var deadlineErr *DeadlineError
+	if assert.ErrorAs(t, err, &deadlineErr) {
+		// The index was built, but the migration is not recorded.
+		assert.Contains(t, deadlineErr.Statement, "INSERT INTO \"migrations\"")
+		assert.False(t, deadlineErr.TrackingUpdated)
+	}
+	assert.ErrorContains(t, err, "migration1 stopped after")
+	assert.ErrorContains(t, err, "in flight: INSERT INTO \"migrations\" (name, executed_at, checksum, batch...")
+	assert.ErrorContains(t, err, "tracking table not updated")
+}
+
+func TestApplyMigrationsReconnectPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	first := &mockMigrationPostgresDriver{name: "migration1", up: "CREATE TABLE one (id INT);"}
+	second := &mockMigrationPostgresDriver{name: "migration2", up: "CREATE TABLE two (id INT);"}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec(`CREATE TABLE one`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// The connection is validated between migrations, and the first statement
+	// is retried when the connection turns out to be dead.
+	mock.ExpectPing()
+	mock.ExpectExec(`CREATE TABLE two`).WillReturnError(io.ErrUnexpectedEOF)
+	mock.ExpectPing()
+	mock.ExpectExec(`CREATE TABLE two`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration2", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{first, second}, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsNoRetryAfterFirstStatementPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mig := &mockMigrationPostgresDriver{name: "migration1", up: "CREATE TABLE one (id INT);"}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec(`CREATE TABLE one`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WillReturnError(io.ErrUnexpectedEOF)
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyMigrationsNonTransactionalPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.useTransactions = true
+
+	mig := &nonTransactionalMigrationPostgresDriver{
+		mockMigrationPostgresDriver{
+			name: "migration1",
+			up:   "CREATE INDEX CONCURRENTLY idx_test ON test (id);",
+		},
+	}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	// No BEGIN/COMMIT is expected for a migration that opted out.
+	mock.ExpectExec("CREATE INDEX CONCURRENTLY").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcquireAndReleaseLockPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(driver.lockKey()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(driver.lockKey()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, driver.AcquireLock(context.Background()))
+	assert.Error(t, driver.AcquireLock(context.Background()), "lock must not be acquired twice")
+	assert.NoError(t, driver.ReleaseLock(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockKeyScopedPostgresDriver(t *testing.T) {
+	db, _, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	unscoped := driver.lockKey()
+	driver.configure(&Config{LockScope: "tenant_a"})
+	tenantA := driver.lockKey()
+	driver.configure(&Config{LockScope: "tenant_b"})
+
+	assert.NotEqual(t, unscoped, tenantA)
+	assert.NotEqual(t, tenantA, driver.lockKey())
+}
+
+func TestInspectSchemaPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"table_name", "column_name"}).
+		AddRow("migrations", "name").
+		AddRow("migrations_audit", "operation").
+		AddRow("orders", "id").
+		AddRow("users", "id").
+		AddRow("users", "email")
+	mock.ExpectQuery(`SELECT c.table_name, c.column_name\s+FROM information_schema.columns c`).WillReturnRows(rows)
+
+	schema, err := driver.InspectSchema(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Schema{
+		{Name: "orders", Columns: []SchemaColumn{{Name: "id"}}},
+		{Name: "users", Columns: []SchemaColumn{{Name: "id"}, {Name: "email"}}},
+	}, schema)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsPostgresAuthError(t *testing.T) {
+	assert.True(t, isPostgresAuthError(&pq.Error{Code: "28P01"}))
+	assert.True(t, isPostgresAuthError(fmt.Errorf("wrapped: %w", &pq.Error{Code: "28000"})))
+	assert.False(t, isPostgresAuthError(&pq.Error{Code: "42P01"}))
+	assert.False(t, isPostgresAuthError(errors.New("some error")))
+}
+
+func TestWithCredentialRefreshPostgresDriver(t *testing.T) {
+	driver := &PostgresDriver{}
+	driver.credentialRefresher = func(ctx context.Context) (string, string, error) {
+		return "user", "fresh-token", nil
+	}
+
+	calls := 0
+	var reconnectedWith string
+	err := driver.withCredentialRefresh(
+		context.Background(),
+		isPostgresAuthError,
+		func(ctx context.Context, user, password string) error {
+			reconnectedWith = password
+			return nil
+		},
+		func() error {
+			calls++
+			if calls == 1 {
+				return &pq.Error{Code: "28P01"}
+			}
+			return nil
+		},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "fresh-token", reconnectedWith)
+}
+
+func TestWithCredentialRefreshWithoutRefresherPostgresDriver(t *testing.T) {
+	driver := &PostgresDriver{}
+	authErr := &pq.Error{Code: "28P01"}
+
+	err := driver.withCredentialRefresh(
+		context.Background(),
+		isPostgresAuthError,
+		func(ctx context.Context, user, password string) error {
+			t.Fatal("reconnect must not be called without a refresher")
+			return nil
+		},
+		func() error { return authErr },
+	)
+
+	assert.ErrorIs(t, err, authErr)
+}
+
 func TestExecuteMigrationSQLPostgresDriver(t *testing.T) {
 	db, mock, driver := setupMockDBPostgres(t)
 	defer db.Close()
 
 	mock.ExpectExec(`SOME SQL STATEMENT`).WillReturnResult(sqlmock.NewResult(0, 0))
 
-	err := driver.executeMigrationSQL(context.Background(), "SOME SQL STATEMENT")
+	err := driver.executeMigrationSQL(context.Background(), db, "migration_name", "SOME SQL STATEMENT", false)
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestExecuteMigrationSQLPostgresDriver_Split(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	script := "CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN NEW.updated_at = now(); RETURN NEW; END; $$ LANGUAGE plpgsql;\n" +
+		"CREATE INDEX CONCURRENTLY users_email ON users (email);\n"
+	mock.ExpectExec(`^CREATE FUNCTION touch\(\) RETURNS trigger AS \$\$ BEGIN NEW.updated_at = now\(\); RETURN NEW; END; \$\$ LANGUAGE plpgsql$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^CREATE INDEX CONCURRENTLY users_email ON users \(email\)$`).WillReturnError(errors.New("relation \"users\" does not exist"))
+
+	err := driver.executeMigrationSQL(context.Background(), db, "001_touch", script, true)
+	var statementErr *StatementError
+	if assert.ErrorAs(t, err, &statementErr) {
+		assert.Equal(t, 2, statementErr.Index)
+		assert.Equal(t, 2, statementErr.Line)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteMigrationSQLPostgresDriver_SplitStatementRewriter(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+	driver.statementRewriter = func(migration string, dialect Dialect, sql string) (string, error) {
+		return "/* " + migration + " */ " + sql, nil
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`/* 002_index_users */ CREATE INDEX CONCURRENTLY users_email ON users (email)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`/* 002_index_users */ CREATE INDEX CONCURRENTLY users_name ON users (name)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	script := "CREATE INDEX CONCURRENTLY users_email ON users (email);\nCREATE INDEX CONCURRENTLY users_name ON users (name);\n"
+	assert.NoError(t, driver.executeMigrationSQL(context.Background(), db, "002_index_users", script, true))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestInsertExecutedMigrationPostgresDriver(t *testing.T) {
 	db, mock, driver := setupMockDBPostgres(t)
 	defer db.Close()
 
-	mock.ExpectExec(`INSERT INTO migrations`).WithArgs("migration_name", sqlmock.AnyArg()).
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration_name", sqlmock.AnyArg(), "abc", 2, "ci", nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := driver.insertExecutedMigration(context.Background(), "migration_name", time.Now())
+	err := driver.insertExecutedMigration(context.Background(), db, ExecutedMigration{
+		Name:       "migration_name",
+		ExecutedAt: time.Now(),
+		Checksum:   "abc",
+		Batch:      2,
+		AppliedBy:  "ci",
+	})
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -160,10 +763,10 @@ func TestRemoveExecutedMigrationPostgresDriver(t *testing.T) {
 	db, mock, driver := setupMockDBPostgres(t)
 	defer db.Close()
 
-	mock.ExpectExec(`DELETE FROM migrations WHERE name = \$1`).WithArgs("migration_name").
+	mock.ExpectExec(`DELETE FROM "migrations" WHERE name = \$1`).WithArgs("migration_name").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := driver.removeExecutedMigration(context.Background(), "migration_name")
+	err := driver.removeExecutedMigration(context.Background(), db, "migration_name")
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -179,3 +782,94 @@ type mockMigrationPostgresDriver struct {
 func (m *mockMigrationPostgresDriver) Name() string       { return m.name }
 func (m *mockMigrationPostgresDriver) UpScript() string   { return m.up }
 func (m *mockMigrationPostgresDriver) DownScript() string { return m.down }
+
+type nonTransactionalMigrationPostgresDriver struct {
+	mockMigrationPostgresDriver
+}
+
+func (m *nonTransactionalMigrationPostgresDriver) NonTransactional() bool { return true }
+
+func TestCreateMigrationsTableExistingPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT column_name FROM information_schema\.columns`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("name").AddRow("executed_at"))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migrations"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN checksum`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN batch INTEGER`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN applied_by VARCHAR\(255\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN namespace VARCHAR\(255\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN build TEXT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN skipped BOOLEAN`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.CreateMigrationsTable(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpgradeMigrationsTablePostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT column_name FROM information_schema\.columns`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("name").AddRow("executed_at").AddRow("checksum").AddRow("batch").AddRow("applied_by").AddRow("namespace").AddRow("build").AddRow("skipped"))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migrations"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT indexname FROM pg_indexes`).WithArgs("", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"indexname"}).AddRow("migrations_pkey"))
+	mock.ExpectExec(`CREATE INDEX migrations_executed_at_idx ON "migrations" \(executed_at, name\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "migrations" SET executed_at = CURRENT_TIMESTAMP WHERE executed_at IS NULL`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ALTER COLUMN executed_at SET NOT NULL`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.UpgradeMigrationsTable(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInspectPlatformPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT\s+pg_is_in_recovery\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"in_recovery", "read_only_default", "aurora", "alloydb", "version", "fast_ddl"}).AddRow(true, false, false, true, "15.5", true))
+
+	driver.endpoint = "10.0.0.5:5432/app"
+	info, err := driver.InspectPlatform(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, PlatformInfo{Platform: PlatformAlloyDB, Version: "15.5", Endpoint: "10.0.0.5:5432/app", ReadOnly: true, ReadOnlyReason: "in recovery", FastDDL: true}, info)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGoMigration_Migrate_ReadOnlyEndpointPostgresDriver(t *testing.T) {
+	db, mock, driver := setupMockDBPostgres(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT\s+pg_is_in_recovery\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"in_recovery", "read_only_default", "aurora", "alloydb", "version", "fast_ddl"}).AddRow(false, true, true, false, "16.1", true))
+
+	driver.endpoint = "reader.cluster-ro-abc.eu-west-1.rds.amazonaws.com:5432/app"
+	q := &GoMigration{driver: driver, migrations: map[string]Migration{}}
+	err := q.Migrate(context.Background())
+	assert.ErrorIs(t, err, ErrReadOnlyTarget)
+	var readOnlyErr *ReadOnlyError
+	if assert.ErrorAs(t, err, &readOnlyErr) {
+		assert.Equal(t, ReadOnlyError{Endpoint: driver.endpoint, Platform: PlatformAuroraPostgres, Reason: "default_transaction_read_only is on"}, *readOnlyErr)
+	}
+	assert.EqualError(t, err, "database is read-only: aurora-postgresql reader.cluster-ro-abc.eu-west-1.rds.amazonaws.com:5432/app is read-only (default_transaction_read_only is on), connect to the writer or primary instead")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForTenantMixedCasePostgresDriver(t *testing.T) {
+	var searchPath string
+	driver := &PostgresDriver{
+		dsn: func(user, password, schema string) string {
+			searchPath = schema
+			// Nothing listens on port 1, so the driver stops at its ping.
+			return "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1"
+		},
+	}
+
+	_, err := driver.ForTenant(context.Background(), "Acme")
+	assert.Error(t, err)
+	assert.Equal(t, `"Acme"`, searchPath, "the search_path matches the quoted schema")
+}
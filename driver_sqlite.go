@@ -3,17 +3,28 @@ package gomigration
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
 )
 
+// sqliteReadOnlyPattern matches the mode=ro parameter of SQLite URIs.
+var sqliteReadOnlyPattern = regexp.MustCompile(`[?&]mode=ro(?:&|$)`)
+
 // SqliteDriver is a driver for sqlite
 type SqliteDriver struct {
+	driverOptions
 	db                 *sql.DB
 	migrationTableName string
+	database           string
+	lockFile           *os.File
 }
 
 // NewSqliteDriver creates a new SqliteDriver
@@ -32,7 +43,11 @@ func NewSqliteDriver(
 	}
 
 	// Return the driver with a default table name
-	return &(SqliteDriver{db, "migrations"}), nil
+	return &SqliteDriver{
+		db:                 db,
+		migrationTableName: "migrations",
+		database:           database,
+	}, nil
 }
 
 // Close closes the database connection
@@ -46,7 +61,8 @@ func (d *SqliteDriver) Close() error {
 	return nil
 }
 
-// SetMigrationTableName sets the migration table name of the migration tracking table
+// SetMigrationTableName sets the migration table name of the migration tracking table.
+// The name may be qualified by the schema of an attached database, e.g. "main.migrations".
 func (d *SqliteDriver) SetMigrationTableName(name string) {
 	if name == "" {
 		name = "migrations"
@@ -54,56 +70,448 @@ func (d *SqliteDriver) SetMigrationTableName(name string) {
 	d.migrationTableName = name
 }
 
+// quotedTableName returns the migration table name quoted for use in SQL.
+func (d *SqliteDriver) quotedTableName() string {
+	return quoteIdentifier(d.dialect(), d.migrationTableName)
+}
+
+// quotedLockTableName returns the lock table name quoted for use in SQL.
+func (d *SqliteDriver) quotedLockTableName() string {
+	return quoteIdentifier(d.dialect(), d.lockTableName())
+}
+
+// Ping verifies the connection to the database is still alive.
+func (d *SqliteDriver) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+func (d *SqliteDriver) dialect() Dialect {
+	return DialectSQLite
+}
+
 // CreateMigrationTable creates the migration tracking table
 func (d *SqliteDriver) CreateMigrationsTable(ctx context.Context) error {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	existing, err := d.trackingColumns(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s (
-			name VARCHAR(255) PRIMARY KEY NOT NULL,
-			executed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			name VARCHAR(255) PRIMARY KEY NOT NULL CHECK (name <> ''),
+			executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);
-	`, d.migrationTableName)
+	`, d.quotedTableName())
+	if _, err := d.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
 
-	_, err := d.db.ExecContext(ctx, query)
-	return err
+	if err := addTrackingColumns(ctx, d.db, d.dialect(), d.migrationTableName, existing); err != nil {
+		return err
+	}
+
+	// New tables get their indexes right away, existing ones through
+	// UpgradeMigrationsTable.
+	if len(existing) == 0 {
+		return d.createTrackingIndexes(ctx)
+	}
+	return nil
 }
 
-// GetExecutedMigrations returns a list of previously executed migrations
-func (d *SqliteDriver) GetExecutedMigrations(ctx context.Context, reverse bool) ([]ExecutedMigration, error) {
-	order := "ASC"
-	if reverse {
-		order = "DESC"
+// UpgradeMigrationsTable adds the indexes and constraints that tracking tables
+// created by older versions are missing.
+func (d *SqliteDriver) UpgradeMigrationsTable(ctx context.Context) error {
+	if err := d.CreateMigrationsTable(ctx); err != nil {
+		return err
+	}
+	if err := d.createTrackingIndexes(ctx); err != nil {
+		return err
 	}
 
-	query := fmt.Sprintf(`SELECT name, executed_at FROM %s ORDER BY name %s`, d.migrationTableName, order)
-	rows, err := d.db.QueryContext(ctx, query)
+	// SQLite cannot add NOT NULL or CHECK constraints to existing columns
+	// without rebuilding the table, so only new tables get them.
+	return nil
+}
+
+// trackingColumns returns the column names of the tracking table, which are
+// empty if it does not exist yet.
+func (d *SqliteDriver) trackingColumns(ctx context.Context) (map[string]bool, error) {
+	schema, table := splitTableName(d.migrationTableName)
+	columns, err := queryNameSet(ctx, d.db, `SELECT name FROM pragma_table_info(?, COALESCE(NULLIF(?, ''), 'main'))`, table, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", d.migrationTableName, err)
+	}
+	return columns, nil
+}
+
+// trackingTableExists reports whether the tracking table has been created.
+func (d *SqliteDriver) trackingTableExists(ctx context.Context) (bool, error) {
+	columns, err := d.trackingColumns(ctx)
+	return len(columns) > 0, err
+}
+
+func (d *SqliteDriver) trackingTableDDL() []string {
+	return trackingTableStatements(d.dialect(), d.migrationTableName)
+}
+
+// createTrackingIndexes creates the tracking table indexes that do not exist yet.
+func (d *SqliteDriver) createTrackingIndexes(ctx context.Context) error {
+	schema, table := splitTableName(d.migrationTableName)
+	indexes, err := queryNameSet(ctx, d.db, `SELECT name FROM pragma_index_list(?, COALESCE(NULLIF(?, ''), 'main'))`, table, schema)
 	if err != nil {
+		return fmt.Errorf("failed to inspect indexes of %s: %w", d.migrationTableName, err)
+	}
+	return addTrackingIndexes(ctx, d.db, d.dialect(), d.migrationTableName, indexes)
+}
+
+// AcquireLock inserts the single row of the lock table, busy-waiting while
+// another process holds it. SQLite has no named or advisory locks. With
+// Config.SqliteFileLock, an OS-level lock on a file next to the database is
+// taken first.
+func (d *SqliteDriver) AcquireLock(ctx context.Context) error {
+	if d.sqliteFileLock {
+		if err := d.acquireFileLock(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := d.acquireLockRow(ctx); err != nil {
+		return errors.Join(err, d.releaseFileLock())
+	}
+	return nil
+}
+
+// acquireLockRow inserts the single row of the lock table, busy-waiting while
+// another process holds it.
+func (d *SqliteDriver) acquireLockRow(ctx context.Context) error {
+	createQuery := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			locked_at TIMESTAMP NOT NULL
+		);
+	`, d.quotedLockTableName())
+	if _, err := d.db.ExecContext(ctx, createQuery); err != nil {
+		return fmt.Errorf("failed to create lock table: %w", err)
+	}
+
+	lockHeld := func() error {
+		return fmt.Errorf("migration lock is held by another process (remove the row from %s if it is stale): %w", d.lockTableName(), ctx.Err())
+	}
+
+	insertQuery := fmt.Sprintf(`INSERT OR IGNORE INTO %s (id, locked_at) VALUES (1, ?)`, d.quotedLockTableName())
+	for {
+		res, err := d.db.ExecContext(ctx, insertQuery, time.Now())
+		if err != nil {
+			// The deadline may interrupt the insert while it waits for the
+			// database to be unlocked.
+			if ctx.Err() != nil {
+				return lockHeld()
+			}
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return lockHeld()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// ReleaseLock deletes the lock row and releases the lock file, if any.
+func (d *SqliteDriver) ReleaseLock(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = 1`, d.quotedLockTableName()))
+	return errors.Join(err, d.releaseFileLock())
+}
+
+// ForTenant opens the database file of tenant next to the database of d,
+// named after both, e.g. app_acme.db for app.db and tenant acme. In-memory
+// databases have no file to put the tenant's next to.
+func (d *SqliteDriver) ForTenant(ctx context.Context, tenant string) (Driver, error) {
+	if err := validateTenant(tenant); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var migrations []ExecutedMigration
-	for rows.Next() {
-		var name string
-		var executedAt time.Time
-		if err := rows.Scan(&name, &executedAt); err != nil {
-			return nil, err
+	var database string
+	if err := d.db.QueryRowContext(ctx, `SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(&database); err != nil {
+		return nil, fmt.Errorf("failed to locate database file: %w", err)
+	}
+	if database == "" {
+		return nil, fmt.Errorf("%w: in-memory databases have no tenant files", ErrTenantsNotSupported)
+	}
+
+	ext := filepath.Ext(database)
+	tenantDatabase := strings.TrimSuffix(database, ext) + "_" + tenant + ext
+	db, err := sql.Open("sqlite3", tenantDatabase)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &SqliteDriver{
+		driverOptions:      d.driverOptions,
+		db:                 db,
+		migrationTableName: d.migrationTableName,
+		database:           tenantDatabase,
+	}, nil
+}
+
+// acquireFileLock locks the lock file of the database, polling while another
+// process holds it. In-memory databases have no file to lock.
+func (d *SqliteDriver) acquireFileLock(ctx context.Context) error {
+	if d.lockFile != nil {
+		return errors.New("migration lock already held")
+	}
+
+	var database string
+	if err := d.db.QueryRowContext(ctx, `SELECT file FROM pragma_database_list WHERE name = 'main'`).Scan(&database); err != nil {
+		return fmt.Errorf("failed to locate database file: %w", err)
+	}
+	if database == "" {
+		return nil
+	}
+
+	fileName := database + "." + d.lockTableName()
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to lock %s: %w", fileName, err)
+		}
+		if locked {
+			d.lockFile = f
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return fmt.Errorf("migration lock is held by another process (%s is unlocked when it exits, check the host holding it if it is stale): %w", fileName, ctx.Err())
+		case <-time.After(lockPollInterval):
 		}
-		migrations = append(migrations, ExecutedMigration{Name: name, ExecutedAt: executedAt})
 	}
+}
+
+// releaseFileLock releases and closes the lock file, if locked.
+func (d *SqliteDriver) releaseFileLock() error {
+	if d.lockFile == nil {
+		return nil
+	}
+
+	f := d.lockFile
+	d.lockFile = nil
+	return errors.Join(unlockFile(f), f.Close())
+}
+
+// lockTableName returns the name of the table holding the migration lock row.
+// Each lock scope has a table of its own.
+func (d *SqliteDriver) lockTableName() string {
+	if d.lockScope == "" {
+		return d.migrationTableName + "_lock"
+	}
+	return d.migrationTableName + "_" + d.lockScope + "_lock"
+}
+
+// GetExecutedMigrations returns the executed migrations from the tracking table
+// sorted according to order.
+func (d *SqliteDriver) GetExecutedMigrations(ctx context.Context, order HistoryOrder) ([]ExecutedMigration, error) {
+	return collectExecutedMigrations(func(fn func(migration ExecutedMigration) error) error {
+		return d.IterateExecutedMigrations(ctx, order, fn)
+	})
+}
+
+// IterateExecutedMigrations streams the executed migrations sorted according to order.
+// The tracking timeout bounds the query, not the iteration, so fn may take its
+// time.
+func (d *SqliteDriver) IterateExecutedMigrations(ctx context.Context, order HistoryOrder, fn func(migration ExecutedMigration) error) error {
+	ctx, started, cancel := d.trackingQueryContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s`, executedMigrationColumns, d.quotedTableName(), historyOrderClause(order))
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	started()
+
+	return scanExecutedMigrations(rows, fn)
+}
+
+// GetExecutedMigrationsPage returns one page of the executed migrations sorted according to order.
+func (d *SqliteDriver) GetExecutedMigrationsPage(ctx context.Context, order HistoryOrder, limit, offset int) ([]ExecutedMigration, error) {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s LIMIT ? OFFSET ?`, executedMigrationColumns, d.quotedTableName(), historyOrderClause(order))
+	rows, err := d.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectExecutedMigrations(func(fn func(migration ExecutedMigration) error) error {
+		return scanExecutedMigrations(rows, fn)
+	})
+}
+
+// InsertExecutedMigration records a migration without running the up script.
+func (d *SqliteDriver) InsertExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	return d.insertExecutedMigration(ctx, d.db, record)
+}
+
+// UpdateExecutedMigration overwrites the execution time and checksum of a record.
+func (d *SqliteDriver) UpdateExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %s SET executed_at = ?, checksum = ? WHERE name = ?`, d.quotedTableName())
+	_, err := d.db.ExecContext(ctx, query, record.ExecutedAt, nullableString(record.Checksum), record.Name)
+	return err
+}
+
+// RemoveExecutedMigration deletes a record without running the down script.
+func (d *SqliteDriver) RemoveExecutedMigration(ctx context.Context, name string) error {
+	return d.removeExecutedMigration(ctx, d.db, name)
+}
+
+// GetManifestHash returns the stored manifest hash, empty if none is stored.
+func (d *SqliteDriver) GetManifestHash(ctx context.Context) (string, error) {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	return getManifestHash(ctx, d.db, d.dialect(), d.migrationTableName)
+}
+
+// SetManifestHash stores the manifest hash, or removes it when hash is empty.
+func (d *SqliteDriver) SetManifestHash(ctx context.Context, hash string) error {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	return setManifestHash(ctx, d.db, d.dialect(), d.migrationTableName, `INSERT INTO %s (id, manifest_hash) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET manifest_hash = excluded.manifest_hash`, hash)
+}
+
+// AppendAuditEvent appends event to the audit log table.
+func (d *SqliteDriver) AppendAuditEvent(ctx context.Context, event AuditEvent) error {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	return appendAuditEvent(ctx, d.db, d.dialect(), d.migrationTableName, `INSERT INTO %s (occurred_at, operation, actor, migrations, outcome, error) VALUES (?, ?, ?, ?, ?, ?)`, event)
+}
+
+// InspectSchema returns the tables of the current schema and their columns.
+func (d *SqliteDriver) InspectSchema(ctx context.Context) (Schema, error) {
+	return inspectSchema(ctx, d.db, d.migrationTableName, `
+		SELECT m.name, p.name
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid
+	`)
+}
+
+// InspectER returns the tables of the current schema, their columns and
+// foreign keys.
+func (d *SqliteDriver) InspectER(ctx context.Context) (ERModel, error) {
+	return inspectER(ctx, d.db, d.migrationTableName, `
+		SELECT m.name, p.name, p.type, p."notnull" = 0, p.pk > 0
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid
+	`, `
+		SELECT CAST(f.id AS TEXT), m.name, f."from", f."table", COALESCE(f."to", '')
+		FROM sqlite_master m
+		JOIN pragma_foreign_key_list(m.name) f
+		WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, f.id, f.seq
+	`)
+}
+
+// InspectPlatform reports databases opened with mode=ro or in query_only mode
+// as read-only.
+func (d *SqliteDriver) InspectPlatform(ctx context.Context) (PlatformInfo, error) {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	info := PlatformInfo{Platform: PlatformSQLite, Endpoint: d.database, FastDDL: true}
+	if err := d.db.QueryRowContext(ctx, `SELECT sqlite_version()`).Scan(&info.Version); err != nil {
+		return PlatformInfo{}, fmt.Errorf("failed to inspect platform: %w", err)
+	}
+
+	var queryOnly bool
+	if err := d.db.QueryRowContext(ctx, `PRAGMA query_only`).Scan(&queryOnly); err != nil {
+		return PlatformInfo{}, fmt.Errorf("failed to inspect platform: %w", err)
+	}
+	switch {
+	case sqliteReadOnlyPattern.MatchString(d.database):
+		info.ReadOnly, info.ReadOnlyReason = true, "opened with mode=ro"
+	case queryOnly:
+		info.ReadOnly, info.ReadOnlyReason = true, "query_only is on"
+	}
+	return info, nil
+}
+
+// GolangMigrateVersion returns the version recorded by golang-migrate.
+func (d *SqliteDriver) GolangMigrateVersion(ctx context.Context) (int64, bool, error) {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	return golangMigrateVersion(ctx, d.db)
+}
+
+// GooseVersions returns the history recorded by goose.
+func (d *SqliteDriver) GooseVersions(ctx context.Context) ([]GooseVersion, error) {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	return gooseVersions(ctx, d.db)
+}
+
+// FlywayHistory returns the history recorded by Flyway.
+func (d *SqliteDriver) FlywayHistory(ctx context.Context) ([]FlywayMigration, error) {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
 
-	return migrations, rows.Err()
+	return flywayHistory(ctx, d.db)
 }
 
 // CleanDatabase drops all table from the current database.
 func (d *SqliteDriver) CleanDatabase(ctx context.Context) error {
+	return d.CleanDatabaseChunked(ctx, CleanOptions{}, nil)
+}
+
+// CleanDatabaseChunked drops all table from the current database, reporting
+// progress a chunk at a time. Chunks are dropped one after the other whatever
+// Config.CleanParallelism says, as SQLite allows a single writer.
+func (d *SqliteDriver) CleanDatabaseChunked(ctx context.Context, opts CleanOptions, onChunk func(tables []string, dropped, total int)) error {
+	// Foreign key enforcement is set per connection
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
 	// Disable FK checks temporarily
-	_, err := d.db.ExecContext(ctx, `PRAGMA foreign_keys = OFF;`)
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF;`)
 	if err != nil {
 		return fmt.Errorf("failed to disable FK checks: %w", err)
 	}
 
 	// Get all user-defined table names (excluding sqlite internal tables)
-	rows, err := d.db.QueryContext(ctx, `
+	rows, err := conn.QueryContext(ctx, `
 		SELECT name 
 		FROM sqlite_master 
 		WHERE type = 'table' 
@@ -120,27 +528,45 @@ func (d *SqliteDriver) CleanDatabase(ctx context.Context) error {
 		if err := rows.Scan(&table); err != nil {
 			return fmt.Errorf("failed to scan table name: %w", err)
 		}
-		tableNames = append(tableNames, fmt.Sprintf(`"%s"`, table))
+		if d.keepOnClean(table, d.migrationTableName, opts) {
+			continue
+		}
+		tableNames = append(tableNames, table)
 	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query tables: %w", err)
+	}
+	// The connection is busy until the rows are closed
+	rows.Close()
 
 	// No tables to drop
 	if len(tableNames) == 0 {
 		// Re-enable FK checks before returning
-		_, _ = d.db.ExecContext(ctx, `PRAGMA foreign_keys = ON;`)
+		_, _ = conn.ExecContext(ctx, `PRAGMA foreign_keys = ON;`)
 		return nil
 	}
 
-	// Drop all tables (SQLite doesn't support dropping multiple tables in one statement)
-	for _, tableName := range tableNames {
-		dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName)
-		_, err = d.db.ExecContext(ctx, dropSQL)
-		if err != nil {
-			return fmt.Errorf("failed to drop table %s: %w", tableName, err)
+	err = d.cleanInChunks(ctx, tableNames, 1, onChunk, func(ctx context.Context, chunks *tableChunks) error {
+		for chunk, ok := chunks.take(); ok; chunk, ok = chunks.take() {
+			// Drop the tables of the chunk (SQLite doesn't support dropping multiple tables in one statement)
+			for _, tableName := range chunk {
+				dropSQL := fmt.Sprintf(`DROP TABLE IF EXISTS "%s";`, tableName)
+				if _, err := conn.ExecContext(ctx, dropSQL); err != nil {
+					return fmt.Errorf("failed to drop table %s: %w", tableName, err)
+				}
+			}
+			chunks.done(chunk)
 		}
+		return nil
+	})
+	if err != nil {
+		// Re-enable FK checks before the connection returns to the pool
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `PRAGMA foreign_keys = ON;`)
+		return err
 	}
 
 	// Re-enable FK checks
-	_, err = d.db.ExecContext(ctx, `PRAGMA foreign_keys = ON;`)
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys = ON;`)
 	if err != nil {
 		return fmt.Errorf("failed to re-enable FK checks: %w", err)
 	}
@@ -156,34 +582,85 @@ func (d *SqliteDriver) ApplyMigrations(
 	onSuccess func(migration *Migration),
 	onFailed func(migration *Migration, err error),
 ) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	batch, err := d.lastBatch(ctx, d.db, d.dialect(), d.migrationTableName)
+	if err != nil {
+		return err
+	}
+	batch++
+
+	failures := &BatchError{}
+
 	for i := range migrations {
 		mig := migrations[i]
+		upScript, _ := dialectScripts(mig, DialectSQLite)
+
+		skipped, err := d.skipMigration(ctx, d, d.db, mig, batch, d.insertExecutedMigration)
+		if skipped {
+			continue
+		}
 
 		if onRunning != nil {
 			onRunning(&mig)
 		}
 
-		// Execute the migration SQL
-		if err := d.executeMigrationSQL(ctx, mig.UpScript()); err != nil {
-			if onFailed != nil {
-				onFailed(&mig, err)
-			}
-			return fmt.Errorf("failed to apply migration %s: %w", mig.Name(), err)
+		stage := StageCondition
+		if err == nil {
+			stage = StageConnect
+			err = d.runMigration(ctx, d.db, mig, nil, func(ctx context.Context, ex execer) error {
+				// Execute the migration SQL
+				stage = StageExecute
+				if err := d.executeMigrationSQL(ctx, ex, mig.Name(), upScript); err != nil {
+					return fmt.Errorf("failed to apply migration %s: %w", mig.Name(), err)
+				}
+				// Record the migration
+				stage = StageRecord
+				if err := d.insertExecutedMigration(ctx, ex, ExecutedMigration{
+					Name:       mig.Name(),
+					ExecutedAt: time.Now(),
+					Checksum:   migrationChecksum(mig),
+					Batch:      batch,
+					AppliedBy:  d.appliedBy,
+					Build:      d.build,
+					Namespace:  migrationSetOf(mig),
+				}); err != nil {
+					return fmt.Errorf("failed to record migration %s: %w", mig.Name(), err)
+				}
+				return nil
+			})
 		}
-
-		// Record the migration
-		if err := d.insertExecutedMigration(ctx, mig.Name(), time.Now()); err != nil {
+		if err != nil {
+			err = migrationFailure(mig, DirectionUp, err)
 			if onFailed != nil {
 				onFailed(&mig, err)
 			}
-			return fmt.Errorf("failed to record migration %s: %w", mig.Name(), err)
+			if err := d.failBatch(ctx, failures, BatchFailure{Migration: mig.Name(), Stage: stage, Err: err}); err != nil {
+				return err
+			}
+			continue
 		}
 
 		if onSuccess != nil {
 			onSuccess(&mig)
 		}
 	}
-	return nil
+	return failures.orNil()
+}
+
+// RunSeeder runs the script of seeder like a migration, without recording it.
+func (d *SqliteDriver) RunSeeder(ctx context.Context, seeder Seeder) error {
+	return d.runMigration(ctx, d.db, seederMigration{seeder: seeder}, nil, func(ctx context.Context, ex execer) error {
+		return d.executeMigrationSQL(ctx, ex, seeder.Name(), seeder.SeedScript())
+	})
+}
+
+// LoadFixtures inserts the rows of fixtures in a single transaction, deleting
+// the rows of their tables first with truncate.
+func (d *SqliteDriver) LoadFixtures(ctx context.Context, fixtures []Fixture, truncate bool) error {
+	return loadFixtures(ctx, d.db, DialectSQLite, fixtures, truncate)
 }
 
 // UnapplyMigrations rolls back a batch of "down" migrations with optional callbacks.
@@ -196,25 +673,29 @@ func (d *SqliteDriver) UnapplyMigrations(
 ) error {
 	for i := range migrations {
 		mig := migrations[i]
+		_, downScript := dialectScripts(mig, DialectSQLite)
 
 		if onRunning != nil {
 			onRunning(&mig)
 		}
 
-		// Execute the down migration SQL
-		if err := d.executeMigrationSQL(ctx, mig.DownScript()); err != nil {
-			if onFailed != nil {
-				onFailed(&mig, err)
+		err := d.runMigration(ctx, d.db, mig, nil, func(ctx context.Context, ex execer) error {
+			// Execute the down migration SQL
+			if err := d.executeMigrationSQL(ctx, ex, mig.Name(), downScript); err != nil {
+				return fmt.Errorf("failed to unapply migration %s: %w", mig.Name(), err)
 			}
-			return fmt.Errorf("failed to unapply migration %s: %w", mig.Name(), err)
-		}
-
-		// Remove migration record from tracking table
-		if err := d.removeExecutedMigration(ctx, mig.Name()); err != nil {
+			// Remove migration record from tracking table
+			if err := d.removeExecutedMigration(ctx, ex, mig.Name()); err != nil {
+				return fmt.Errorf("failed to remove migration record %s: %w", mig.Name(), err)
+			}
+			return nil
+		})
+		if err != nil {
+			err = migrationFailure(mig, DirectionDown, err)
 			if onFailed != nil {
 				onFailed(&mig, err)
 			}
-			return fmt.Errorf("failed to remove migration record %s: %w", mig.Name(), err)
+			return err
 		}
 
 		if onSuccess != nil {
@@ -224,25 +705,50 @@ func (d *SqliteDriver) UnapplyMigrations(
 	return nil
 }
 
-// executeMigrationSQL runs a raw SQL migration script.
-func (d *SqliteDriver) executeMigrationSQL(ctx context.Context, sql string) error {
+// executeMigrationSQL runs a raw SQL migration script of the named migration,
+// after passing it through the statement rewriter.
+func (d *SqliteDriver) executeMigrationSQL(ctx context.Context, ex execer, name, sql string) error {
 	if sql == "" {
 		return nil
 	}
-	_, err := d.db.ExecContext(ctx, sql)
+
+	sql, err := d.prepareScript(name, sql)
+	if err != nil {
+		return err
+	}
+	if sql, err = d.rewriteStatement(DialectSQLite, name, sql); err != nil {
+		return err
+	}
+
+	ctx, cancel := d.statementContext(ctx)
+	defer cancel()
+
+	_, err = ex.ExecContext(ctx, sql)
 	return err
 }
 
-// insertExecutedMigration logs a migration into the migration tracking table.
-func (d *SqliteDriver) insertExecutedMigration(ctx context.Context, name string, executedAt time.Time) error {
-	query := fmt.Sprintf(`INSERT INTO %s (name, executed_at) VALUES (?, ?)`, d.migrationTableName)
-	_, err := d.db.ExecContext(ctx, query, name, executedAt)
+// squashExecutedMigrations replaces the records of squashed with that of
+// their baseline in a single transaction.
+func (d *SqliteDriver) squashExecutedMigrations(ctx context.Context, squashed []string, baseline ExecutedMigration) error {
+	return replaceExecutedMigrations(ctx, d.db, squashed, baseline, d.insertExecutedMigration, d.removeExecutedMigration)
+}
+
+// insertExecutedMigration records the given migration in the tracking table.
+func (d *SqliteDriver) insertExecutedMigration(ctx context.Context, ex execer, record ExecutedMigration) error {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`INSERT INTO %s (name, executed_at, checksum, batch, applied_by, namespace, build, skipped) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, d.quotedTableName())
+	_, err := ex.ExecContext(ctx, query, record.Name, record.ExecutedAt, nullableString(record.Checksum), nullableInt(record.Batch), nullableString(record.AppliedBy), nullableString(record.Namespace), nullableBuild(record.Build), nullableBool(record.Skipped))
 	return err
 }
 
 // removeExecutedMigration deletes a migration record from the migration table.
-func (d *SqliteDriver) removeExecutedMigration(ctx context.Context, name string) error {
-	query := fmt.Sprintf(`DELETE FROM %s WHERE name = ?`, d.migrationTableName)
-	_, err := d.db.ExecContext(ctx, query, name)
+func (d *SqliteDriver) removeExecutedMigration(ctx context.Context, ex execer, name string) error {
+	ctx, cancel := d.trackingContext(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE name = ?`, d.quotedTableName())
+	_, err := ex.ExecContext(ctx, query, name)
 	return err
 }
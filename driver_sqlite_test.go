@@ -3,6 +3,8 @@ package gomigration
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -41,8 +43,19 @@ func TestCreateMigrationsTableSqliteDriver(t *testing.T) {
 	db, mock, driver := setupMockDBSqlite(t)
 	defer db.Close()
 
-	// Simulate a successful table creation
-	mock.ExpectExec("CREATE TABLE IF NOT EXISTS migrations").WillReturnResult(sqlmock.NewResult(1, 1))
+	// Simulate creating the table from scratch
+	mock.ExpectQuery(`SELECT name FROM pragma_table_info\(\?, COALESCE\(NULLIF\(\?, ''\), 'main'\)\)`).WithArgs("migrations", "").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS \"migrations\"").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN checksum VARCHAR\(64\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN batch INTEGER`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN applied_by VARCHAR\(255\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN namespace VARCHAR\(255\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN build TEXT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "migrations" ADD COLUMN skipped BOOLEAN`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT name FROM pragma_index_list\(\?, COALESCE\(NULLIF\(\?, ''\), 'main'\)\)`).WithArgs("migrations", "").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectExec(`CREATE INDEX migrations_executed_at_idx ON "migrations" \(executed_at, name\)`).WillReturnResult(sqlmock.NewResult(0, 0))
 
 	// Call CreateMigrationTable
 	err := driver.CreateMigrationsTable(context.Background())
@@ -61,26 +74,75 @@ func TestSetMigrationTableNameSqliteDriver(t *testing.T) {
 	assert.Equal(t, "custom_migrations", driver.migrationTableName)
 }
 
+func TestSchemaQualifiedMigrationTableSqliteDriver(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "qualified.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q, err := New(&Config{Driver: driver, MigrationTableName: "main.schema_migrations"})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(
+		&scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+	))
+
+	ctx := context.Background()
+	assert.NoError(t, q.Migrate(ctx))
+	// Creating the table again finds its columns and indexes in the schema.
+	assert.NoError(t, driver.UpgradeMigrationsTable(ctx))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 1) {
+		assert.Equal(t, "001_create_users", executed[0].Name)
+	}
+}
+
+func TestNewRejectsInvalidMigrationTableName(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "invalid.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	_, err = New(&Config{Driver: driver, MigrationTableName: "migrations; DROP TABLE users"})
+	assert.EqualError(t, err, "invalid migration table name: invalid table name: migrations; DROP TABLE users")
+}
+
 func TestGetExecutedMigrationsSqliteDriver(t *testing.T) {
 	// Create a mock database connection
 	db, mock, driver := setupMockDBSqlite(t)
 	defer db.Close()
 
 	// Simulate the query to fetch migrations
-	rows := sqlmock.NewRows([]string{"name", "executed_at"}).
-		AddRow("migration_1", time.Now()).
-		AddRow("migration_2", time.Now())
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_1", time.Now(), "abc", 1, "ci", nil, nil, nil).
+		AddRow("migration_2", time.Now(), nil, 1, "ci", nil, nil, nil)
 
-	mock.ExpectQuery("SELECT name, executed_at FROM migrations").
+	mock.ExpectQuery("SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM \"migrations\"").
 		WillReturnRows(rows)
 
 	// Call GetExecutedMigrations
-	migrations, err := driver.GetExecutedMigrations(context.Background(), false)
+	migrations, err := driver.GetExecutedMigrations(context.Background(), HistoryOrderApplied)
 	assert.NoError(t, err)
 	assert.Len(t, migrations, 2)
 	assert.Equal(t, "migration_1", migrations[0].Name)
 }
 
+func TestGetExecutedMigrationsAppliedDescSqliteDriver(t *testing.T) {
+	db, mock, driver := setupMockDBSqlite(t)
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"name", "executed_at", "checksum", "batch", "applied_by", "namespace", "build", "skipped"}).
+		AddRow("migration_2", time.Now(), nil, 1, "ci", nil, nil, nil).
+		AddRow("migration_1", time.Now().Add(-time.Minute), nil, 1, "ci", nil, nil, nil)
+
+	mock.ExpectQuery(`SELECT name, executed_at, checksum, batch, applied_by, namespace, build, skipped FROM "migrations" ORDER BY executed_at DESC, name DESC`).
+		WillReturnRows(rows)
+
+	migrations, err := driver.GetExecutedMigrations(context.Background(), HistoryOrderAppliedDesc)
+	assert.NoError(t, err)
+	assert.Equal(t, "migration_2", migrations[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestCleanDatabaseSqliteDriver(t *testing.T) {
 	db, mock, driver := setupMockDBSqlite(t)
 	defer db.Close()
@@ -116,6 +178,21 @@ func TestCleanDatabaseSqliteDriver(t *testing.T) {
 	assert.NoError(t, err, "there were unfulfilled expectations")
 }
 
+func TestCleanDatabaseSqliteDriver_KeepsAuditLog(t *testing.T) {
+	db, mock, driver := setupMockDBSqlite(t)
+	defer db.Close()
+	driver.auditLog = true
+
+	mock.ExpectExec(`PRAGMA foreign_keys = OFF;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT name FROM sqlite_master`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("users").AddRow("migrations_audit"))
+	mock.ExpectExec(`DROP TABLE IF EXISTS "users";`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`PRAGMA foreign_keys = ON;`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, driver.CleanDatabase(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestApplyMigrationsSqliteDriver(t *testing.T) {
 	db, mock, driver := setupMockDBSqlite(t)
 	defer db.Close()
@@ -126,8 +203,9 @@ func TestApplyMigrationsSqliteDriver(t *testing.T) {
 		down: "DROP TABLE test;",
 	}
 
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
 	mock.ExpectExec("CREATE TABLE test \\(id INTEGER\\);").WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(`INSERT INTO migrations`).WithArgs("migration1", sqlmock.AnyArg()).
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg(), nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
@@ -146,7 +224,7 @@ func TestUnapplyMigrationsSqliteDriver(t *testing.T) {
 	}
 
 	mock.ExpectExec(mig.down).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(`DELETE FROM migrations WHERE name = ?`).WithArgs(mig.name).
+	mock.ExpectExec(`DELETE FROM "migrations" WHERE name = ?`).WithArgs(mig.name).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := driver.UnapplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
@@ -154,13 +232,66 @@ func TestUnapplyMigrationsSqliteDriver(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestApplyMigrationsSqliteDriver_DialectVariant(t *testing.T) {
+	db, mock, driver := setupMockDBSqlite(t)
+	defer db.Close()
+
+	mig := scriptMigration{
+		name:     "migration1",
+		upScript: "CREATE TABLE test (id SERIAL);",
+		variants: map[Dialect]scriptVariant{
+			DialectSQLite: {upScript: "CREATE TABLE test (id INTEGER PRIMARY KEY AUTOINCREMENT);"},
+			DialectMySQL:  {upScript: "CREATE TABLE test (id INT AUTO_INCREMENT PRIMARY KEY);"},
+		},
+	}
+
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(batch\), 0\) FROM "migrations"`).WillReturnRows(sqlmock.NewRows([]string{"batch"}).AddRow(0))
+	mock.ExpectExec(`CREATE TABLE test \(id INTEGER PRIMARY KEY AUTOINCREMENT\);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration1", sqlmock.AnyArg(), migrationChecksum(mig), 1, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := driver.ApplyMigrations(context.Background(), []Migration{mig}, nil, nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcquireLockSqliteDriver(t *testing.T) {
+	db, mock, driver := setupMockDBSqlite(t)
+	defer db.Close()
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migrations_lock"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	// The first attempt finds the lock taken, the second one gets it.
+	mock.ExpectExec(`INSERT OR IGNORE INTO "migrations_lock"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT OR IGNORE INTO "migrations_lock"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "migrations_lock" WHERE id = 1`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, driver.AcquireLock(context.Background()))
+	assert.NoError(t, driver.ReleaseLock(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcquireLockTimeoutSqliteDriver(t *testing.T) {
+	db, mock, driver := setupMockDBSqlite(t)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "migrations_lock"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT OR IGNORE INTO "migrations_lock"`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := driver.AcquireLock(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "held by another process")
+}
+
 func TestExecuteMigrationSQLSqliteDriver(t *testing.T) {
 	db, mock, driver := setupMockDBSqlite(t)
 	defer db.Close()
 
 	mock.ExpectExec(`SOME SQL STATEMENT`).WillReturnResult(sqlmock.NewResult(0, 0))
 
-	err := driver.executeMigrationSQL(context.Background(), "SOME SQL STATEMENT")
+	err := driver.executeMigrationSQL(context.Background(), db, "migration_name", "SOME SQL STATEMENT")
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -169,10 +300,16 @@ func TestInsertExecutedMigrationSqliteDriver(t *testing.T) {
 	db, mock, driver := setupMockDBSqlite(t)
 	defer db.Close()
 
-	mock.ExpectExec(`INSERT INTO migrations`).WithArgs("migration_name", sqlmock.AnyArg()).
+	mock.ExpectExec(`INSERT INTO "migrations"`).WithArgs("migration_name", sqlmock.AnyArg(), "abc", 2, "ci", nil, nil, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := driver.insertExecutedMigration(context.Background(), "migration_name", time.Now())
+	err := driver.insertExecutedMigration(context.Background(), db, ExecutedMigration{
+		Name:       "migration_name",
+		ExecutedAt: time.Now(),
+		Checksum:   "abc",
+		Batch:      2,
+		AppliedBy:  "ci",
+	})
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -181,10 +318,10 @@ func TestRemoveExecutedMigrationSqliteDriver(t *testing.T) {
 	db, mock, driver := setupMockDBSqlite(t)
 	defer db.Close()
 
-	mock.ExpectExec(`DELETE FROM migrations WHERE name = ?`).WithArgs("migration_name").
+	mock.ExpectExec(`DELETE FROM "migrations" WHERE name = ?`).WithArgs("migration_name").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := driver.removeExecutedMigration(context.Background(), "migration_name")
+	err := driver.removeExecutedMigration(context.Background(), db, "migration_name")
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -200,3 +337,197 @@ type mockMigrationSqliteDriver struct {
 func (m *mockMigrationSqliteDriver) Name() string       { return m.name }
 func (m *mockMigrationSqliteDriver) UpScript() string   { return m.up }
 func (m *mockMigrationSqliteDriver) DownScript() string { return m.down }
+
+func TestAcquireLockFileSqliteDriver(t *testing.T) {
+	database := filepath.Join(t.TempDir(), "shared.db")
+	newDriver := func() *SqliteDriver {
+		driver, err := NewSqliteDriver(database)
+		assert.NoError(t, err)
+		t.Cleanup(func() { driver.Close() })
+		driver.configure(&Config{SqliteFileLock: true})
+		return driver
+	}
+
+	ctx := context.Background()
+	holder := newDriver()
+	assert.NoError(t, holder.AcquireLock(ctx))
+	assert.FileExists(t, database+".migrations_lock")
+
+	// The file lock alone keeps another process out, even without the lock row.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	waiter := newDriver()
+	err := waiter.acquireFileLock(timeoutCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "shared.db.migrations_lock is unlocked when it exits")
+
+	assert.NoError(t, holder.ReleaseLock(ctx))
+	assert.NoError(t, waiter.AcquireLock(ctx))
+	assert.NoError(t, waiter.ReleaseLock(ctx))
+}
+
+func TestAcquireLockFileInMemorySqliteDriver(t *testing.T) {
+	driver, err := NewSqliteDriver(":memory:")
+	assert.NoError(t, err)
+	defer driver.Close()
+	driver.configure(&Config{SqliteFileLock: true})
+
+	assert.NoError(t, driver.AcquireLock(context.Background()))
+	assert.Nil(t, driver.lockFile)
+	assert.NoError(t, driver.ReleaseLock(context.Background()))
+}
+
+func TestAcquireLockScopedSqliteDriver(t *testing.T) {
+	database := filepath.Join(t.TempDir(), "scoped.db")
+	newDriver := func(scope string) *SqliteDriver {
+		driver, err := NewSqliteDriver(database)
+		assert.NoError(t, err)
+		t.Cleanup(func() { driver.Close() })
+		driver.configure(&Config{LockScope: scope})
+		return driver
+	}
+
+	ctx := context.Background()
+	tenantA := newDriver("tenant_a")
+	assert.NoError(t, tenantA.AcquireLock(ctx))
+
+	// Another scope is not blocked by tenant_a.
+	tenantB := newDriver("tenant_b")
+	assert.NoError(t, tenantB.AcquireLock(ctx))
+	assert.NoError(t, tenantB.ReleaseLock(ctx))
+
+	// The same scope waits for tenant_a to release its lock.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	err := newDriver("tenant_a").AcquireLock(timeoutCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "migrations_tenant_a_lock")
+
+	assert.NoError(t, tenantA.ReleaseLock(ctx))
+	assert.NoError(t, newDriver("tenant_a").AcquireLock(ctx))
+}
+
+func TestInspectPlatformSqliteDriver(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "platform.db")
+	driver, err := NewSqliteDriver(path)
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	info, err := driver.InspectPlatform(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, PlatformSQLite, info.Platform)
+	assert.Equal(t, path, info.Endpoint)
+	assert.False(t, info.ReadOnly)
+
+	readOnly, err := NewSqliteDriver("file:" + path + "?mode=ro")
+	assert.NoError(t, err)
+	defer readOnly.Close()
+
+	q := &GoMigration{driver: readOnly, migrations: map[string]Migration{}}
+	err = q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrReadOnlyTarget)
+	assert.ErrorContains(t, err, "opened with mode=ro")
+}
+
+func TestBuildInfoSqliteDriver(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "build.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	build := BuildInfo{Commit: "4f2c1e9", Version: "v1.8.0", RunURL: "https://ci.example.com/runs/42"}
+	driver.configure(&Config{Build: build})
+	assert.NoError(t, driver.CreateMigrationsTable(ctx))
+	assert.NoError(t, driver.ApplyMigrations(ctx, []Migration{scriptMigration{name: "001_init", upScript: "CREATE TABLE users (id INTEGER);"}}, nil, nil, nil))
+	assert.NoError(t, driver.InsertExecutedMigration(ctx, ExecutedMigration{Name: "002_baseline", ExecutedAt: time.Now()}))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderName)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 2) {
+		assert.Equal(t, &build, executed[0].Build)
+		assert.Nil(t, executed[1].Build)
+	}
+}
+
+func TestApplyMigrationsContinueOnErrorSqliteDriver(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "continue.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	driver.configure(&Config{FailurePolicy: FailureContinue})
+	assert.NoError(t, driver.CreateMigrationsTable(ctx))
+
+	var failed []string
+	err = driver.ApplyMigrations(ctx, []Migration{
+		scriptMigration{name: "001_analyze_users", upScript: "CREATE TABLE users (id INTEGER);"},
+		scriptMigration{name: "002_analyze_orders", upScript: "ANALYZE orders_missing;"},
+		scriptMigration{name: "003_analyze_posts", upScript: "CREATE TABLE posts (id INTEGER);"},
+		scriptMigration{name: "004_analyze_items", upScript: "ANALYZE items_missing;"},
+	}, nil, nil, func(m *Migration, err error) {
+		failed = append(failed, (*m).Name())
+	})
+	var batchErr *BatchError
+	if assert.ErrorAs(t, err, &batchErr) && assert.Len(t, batchErr.Failures, 2) {
+		assert.Equal(t, "002_analyze_orders", batchErr.Failures[0].Migration)
+		assert.Equal(t, StageExecute, batchErr.Failures[0].Stage)
+		assert.ErrorContains(t, batchErr.Failures[0].Err, "failed to apply migration 002_analyze_orders")
+		assert.Equal(t, "004_analyze_items", batchErr.Failures[1].Migration)
+	}
+	assert.ErrorContains(t, err, "2 migration(s) failed; 002_analyze_orders (execute): failed to apply migration 002_analyze_orders")
+	assert.Equal(t, []string{"002_analyze_orders", "004_analyze_items"}, failed)
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderName)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 2) {
+		assert.Equal(t, "001_analyze_users", executed[0].Name)
+		assert.Equal(t, "003_analyze_posts", executed[1].Name)
+		assert.Equal(t, executed[0].Batch, executed[1].Batch)
+	}
+
+	driver.configure(&Config{})
+	err = driver.ApplyMigrations(ctx, []Migration{
+		scriptMigration{name: "002_analyze_orders", upScript: "ANALYZE orders_missing;"},
+		scriptMigration{name: "004_analyze_items", upScript: "ANALYZE items_missing;"},
+	}, nil, nil, nil)
+	assert.ErrorContains(t, err, "002_analyze_orders")
+	assert.NotContains(t, err.Error(), "004_analyze_items")
+	assert.False(t, errors.As(err, &batchErr))
+}
+
+func TestCleanDatabaseChunkedSqliteDriver_Resumes(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "clean.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+	driver.SetMigrationTableName("migrations")
+	driver.cleanBatchSize = 3
+
+	_, err = driver.db.ExecContext(ctx, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY);
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id));
+		CREATE TABLE tags (id INTEGER PRIMARY KEY);
+		CREATE TABLE post_tags (post_id INTEGER REFERENCES posts (id), tag_id INTEGER REFERENCES tags (id));
+		CREATE TABLE comments (id INTEGER PRIMARY KEY, post_id INTEGER REFERENCES posts (id));
+	`)
+	assert.NoError(t, err)
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	err = driver.CleanDatabaseChunked(cancelCtx, CleanOptions{}, func(tables []string, dropped, total int) {
+		assert.Equal(t, []int{3, 5}, []int{dropped, total})
+		cancel()
+	})
+	assert.ErrorContains(t, err, "failed to drop table")
+
+	var progress [][]int
+	err = driver.CleanDatabaseChunked(ctx, CleanOptions{}, func(tables []string, dropped, total int) {
+		progress = append(progress, []int{len(tables), dropped, total})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{2, 2, 2}}, progress)
+
+	var count int
+	assert.NoError(t, driver.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&count))
+	assert.Zero(t, count)
+}
@@ -1,6 +1,12 @@
 package gomigration
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 var (
 	ErrConfigNotProvided          = errors.New("config not provided")
@@ -8,9 +14,324 @@ var (
 	ErrMigrationDirNotProvided    = errors.New("migration directory not provided")
 	ErrMigrationDirNotExists      = errors.New("migration directory does not exist")
 	ErrMigrationNameNotProvided   = errors.New("migration name not provided")
+	ErrMigrationNotProvided       = errors.New("migration not provided")
 	ErrMigrationFileAlreadyExists = errors.New("migration file already exists")
 	ErrMigrationFileNotFound      = errors.New("migration file not found")
 	ErrInvalidRollbackStep        = errors.New("invalid rollback step")
+	ErrInvalidMigrateStep         = errors.New("invalid migrate step")
 	ErrEmbeddedFSNotProvided      = errors.New("embedded fs not provided")
 	ErrGoMigrationNotProvided     = errors.New("gomigration instance not provided")
+	ErrLockNotAcquired            = errors.New("migration lock not acquired")
+	ErrMigrationNotRegistered     = errors.New("migration not registered")
+	ErrMigrationNotExecuted       = errors.New("migration has not been executed")
+	ErrChecksumMismatch           = errors.New("executed migration has been modified")
+	ErrMigrationOrderMismatch     = errors.New("migration order does not match registered migrations")
+	ErrOutOfOrderMigration        = errors.New("pending migration is ordered before an executed one")
+	ErrDependencyNotExecuted      = errors.New("migration dependency is not executed")
+	ErrDependencyCycle            = errors.New("migrations depend on each other")
+	ErrMigrationGateClosed        = errors.New("migration gate is closed")
+	ErrUnknownPhase               = errors.New("unknown migration phase")
+	ErrMigrationTimedOut          = errors.New("migration exceeded its maximum duration")
+	ErrValidationFailed           = errors.New("migrations are not valid")
+	ErrImplicitCommit             = errors.New("transactional migration contains statements that commit implicitly")
+	ErrAuditLogNotSupported       = errors.New("driver does not support an audit log")
+	ErrSchemaDiffNotSupported     = errors.New("driver does not support schema inspection")
+	ErrInvalidHistory             = errors.New("invalid migration history")
+	ErrEmptyUpScript              = errors.New("migration has an empty up script")
+	ErrGolangMigrateNotSupported  = errors.New("driver cannot read golang-migrate history")
+	ErrGolangMigrateDirty         = errors.New("golang-migrate history is dirty")
+	ErrGooseNotSupported          = errors.New("driver cannot read goose history")
+	ErrFlywayNotSupported         = errors.New("driver cannot read Flyway history")
+	ErrFlywayFailed               = errors.New("flyway history has a failed migration")
+	ErrInvalidMigrationSet        = errors.New("invalid migration set")
+	ErrInvalidMigrationFile       = errors.New("invalid migration file")
+	ErrInvalidApplyHook           = errors.New("invalid apply hook")
+	ErrUndefinedEnvVar            = errors.New("environment variable is not set")
+	ErrDiagramNotSupported        = errors.New("driver does not support diagram inspection")
+	ErrInvalidDiagramFormat       = errors.New("invalid diagram format")
+	ErrPlatformNotSupported       = errors.New("driver cannot inspect the database platform")
+	ErrReadOnlyTarget             = errors.New("database is read-only")
+	ErrSeedNotSupported           = errors.New("driver cannot run seeders")
+	ErrSeederNameNotProvided      = errors.New("seeder name not provided")
+	ErrSeederNotRegistered        = errors.New("seeder not registered")
+	ErrSeederNotInEnvironment     = errors.New("seeder does not run in environment")
+	ErrFixturesNotSupported       = errors.New("driver cannot load fixtures")
+	ErrInvalidFixture             = errors.New("invalid fixture")
+	ErrNoTargets                  = errors.New("no targets configured")
+	ErrInvalidTarget              = errors.New("invalid target")
+	ErrTargetNotStarted           = errors.New("target not started")
+	ErrKeepHistoryNotSupported    = errors.New("driver cannot keep the history when cleaning")
+	ErrTenantsNotSupported        = errors.New("driver does not support tenants")
+	ErrNoTenantProvider           = errors.New("no tenant provider configured")
+	ErrInvalidTenant              = errors.New("invalid tenant")
+	ErrServerVersionUnsupported   = errors.New("server version not supported")
+	ErrMigrationNumberCollision   = errors.New("migrations share a sequence number")
+	ErrInterrupted                = errors.New("migration run interrupted")
+	ErrSquashNotSupported         = errors.New("migration cannot be squashed")
+	// ErrNotSupported is matched by every *CapabilityError.
+	ErrNotSupported = errors.New("not supported by the driver")
 )
+
+// CapabilityError is returned when an operation needs an optional interface
+// the driver does not implement, such as HistoryWriter. It matches
+// ErrNotSupported.
+type CapabilityError struct {
+	// Capability is the name of the missing interface.
+	Capability string
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Capability, ErrNotSupported)
+}
+
+// Is reports whether target is ErrNotSupported.
+func (e *CapabilityError) Is(target error) bool {
+	return target == ErrNotSupported
+}
+
+// ReadOnlyError is returned before a run that would change the database when
+// the driver is connected to a read-only target, such as a replica or an
+// Aurora reader endpoint. It matches ErrReadOnlyTarget.
+type ReadOnlyError struct {
+	// Endpoint is the host, port and database, or the file, the driver is
+	// connected to, when known.
+	Endpoint string
+	Platform Platform
+	// Reason tells why the target is read-only, e.g. "in recovery".
+	Reason string
+}
+
+func (e *ReadOnlyError) Error() string {
+	target := string(e.Platform)
+	if e.Endpoint != "" {
+		target += " " + e.Endpoint
+	}
+	return fmt.Sprintf("%s: %s is read-only (%s), connect to the writer or primary instead", ErrReadOnlyTarget, target, e.Reason)
+}
+
+// Is reports whether target is ErrReadOnlyTarget.
+func (e *ReadOnlyError) Is(target error) bool {
+	return target == ErrReadOnlyTarget
+}
+
+// InterruptedError is returned by Migrate and the operations built on it when
+// their context is done mid-batch. The migration in flight finishes and is
+// recorded, or is rolled back when it fails, and the run stops before the
+// next one. It matches ErrInterrupted and the error of the context.
+type InterruptedError struct {
+	// Applied are the migrations applied by the run, in order, and Remaining
+	// those left pending.
+	Applied   []string
+	Remaining []string
+	Err       error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("%s after applying %d of %d migration(s): %s",
+		ErrInterrupted, len(e.Applied), len(e.Applied)+len(e.Remaining), e.Err)
+}
+
+// Is reports whether target is ErrInterrupted.
+func (e *InterruptedError) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// BatchStage is the step of a migration that failed.
+type BatchStage string
+
+const (
+	// StageCondition is asking a ConditionalMigration whether it should run.
+	StageCondition BatchStage = "condition"
+	// StageConnect is getting a working connection before the migration.
+	StageConnect BatchStage = "connect"
+	// StageExecute is running the script of the migration.
+	StageExecute BatchStage = "execute"
+	// StageRecord is recording the migration in the tracking table and
+	// committing its transaction.
+	StageRecord BatchStage = "record"
+)
+
+// BatchFailure is the failure of one migration of a batch.
+type BatchFailure struct {
+	Migration string
+	Stage     BatchStage
+	Err       error
+}
+
+// BatchError aggregates the failures of a batch that went on past failed
+// migrations, such as under FailureContinue. errors.Is and errors.As look
+// through it into the error of every failure.
+type BatchError struct {
+	Failures []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d migration(s) failed", len(e.Failures))
+	for _, failure := range e.Failures {
+		fmt.Fprintf(&b, "; %s (%s): %s", failure.Migration, failure.Stage, failure.Err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the error of every failure.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure.Err
+	}
+	return errs
+}
+
+// orNil returns nil if e holds no failures, so a batch without any succeeds.
+func (e *BatchError) orNil() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// TargetFailure is the failure of one target of a MultiMigrator run.
+type TargetFailure struct {
+	Target string
+	Err    error
+}
+
+// MultiError aggregates the failures of the targets of a MultiMigrator run, or
+// of the tenants of MigrateAllTenants.
+// Targets left out by MultiConfig.FailFast or a cancelled context fail with
+// ErrTargetNotStarted. errors.Is and errors.As look through it into the error
+// of every failure.
+type MultiError struct {
+	Failures []TargetFailure
+}
+
+func (e *MultiError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d target(s) failed", len(e.Failures))
+	for _, failure := range e.Failures {
+		fmt.Fprintf(&b, "; %s: %s", failure.Target, failure.Err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the error of every failure.
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure.Err
+	}
+	return errs
+}
+
+// orNil returns nil if e holds no failures.
+func (e *MultiError) orNil() error {
+	if len(e.Failures) == 0 {
+		return nil
+	}
+	return e
+}
+
+// StatementError is returned when a statement of a migration script run
+// statement by statement fails, as the MySQL driver does. It tells which
+// statement failed and where it starts in the script.
+type StatementError struct {
+	Migration string
+	// Index is the position of the statement in the script, from 1, and Line
+	// the line of the script it starts on, from 1.
+	Index     int
+	Line      int
+	Statement string
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d of %s, line %d (%s): %s", e.Index, e.Migration, e.Line, summarizeStatement(e.Statement), e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// MigrationError is returned by the ApplyMigrations and UnapplyMigrations of
+// the built-in drivers when a migration fails, telling which one failed and
+// where, so callers need not parse error messages. errors.As finds it in the
+// error of a run and in every failure of a BatchError. Its message is that of
+// Err, which names the migration.
+type MigrationError struct {
+	Migration string
+	// Direction is DirectionUp when applying the migration, DirectionDown when
+	// rolling it back, and empty for a seeder.
+	Direction Direction
+	// Statement is the statement that failed, empty when the migration failed
+	// without one failing, e.g. while connecting. StatementIndex is its
+	// position in the script, from 1, or 0 for a statement of the tracking
+	// table. A script run in a single Exec is a single statement.
+	Statement      string
+	StatementIndex int
+	// Err is the error the migration failed with, wrapping that of the
+	// database driver.
+	Err error
+}
+
+func (e *MigrationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// DeadlineError is returned when a deadline stops a migration: the deadline of
+// the context passed to GoMigration, Config.MaxMigrationDuration or the
+// migration's own MaxDuration, or Config.StatementTimeout or
+// Config.TrackingTimeout. It tells where the migration stood, to help decide
+// whether it must be applied again or marked as applied.
+type DeadlineError struct {
+	Migration string
+	// Statement is the statement that was in flight, "BEGIN" or "COMMIT" for
+	// the transaction of the migration.
+	Statement string
+	// Elapsed is how long the migration ran, and Allowed how long it was
+	// allowed to run by the deadline that stopped it.
+	Elapsed time.Duration
+	Allowed time.Duration
+	// TrackingUpdated reports whether the tracking table records the outcome
+	// of the migration: its record inserted when applying it, removed when
+	// rolling it back. It is false when the migration's transaction was
+	// rolled back.
+	TrackingUpdated bool
+	Err             error
+}
+
+func (e *DeadlineError) Error() string {
+	tracking := "not updated"
+	if e.TrackingUpdated {
+		tracking = "updated"
+	}
+	return fmt.Sprintf("%s (%s stopped after %s of %s allowed, in flight: %s, tracking table %s)",
+		e.Err, e.Migration, e.Elapsed.Round(time.Millisecond), e.Allowed.Round(time.Millisecond), summarizeStatement(e.Statement), tracking)
+}
+
+// Unwrap returns Err and context.DeadlineExceeded, which database clients do
+// not always wrap in the errors of cancelled statements.
+func (e *DeadlineError) Unwrap() []error {
+	return []error{e.Err, context.DeadlineExceeded}
+}
+
+// summarizeStatement shortens statement to its first 60 characters, with
+// whitespace collapsed.
+func summarizeStatement(statement string) string {
+	statement = strings.Join(strings.Fields(statement), " ")
+	if statement == "" {
+		return "none"
+	}
+	if runes := []rune(statement); len(runes) > 60 {
+		return string(runes[:60]) + "..."
+	}
+	return statement
+}
@@ -0,0 +1,122 @@
+package gomigration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MigrationEstimate is the expected duration of a pending migration.
+type MigrationEstimate struct {
+	Name string `json:"name"`
+	// Duration is the mean duration of the migration in the histories, zero
+	// when Samples is.
+	Duration time.Duration `json:"duration_ns"`
+	// Samples is the number of runs of the migration the estimate is based on.
+	// It is 0 when no history recorded how long the migration took.
+	Samples int `json:"samples"`
+}
+
+// BatchEstimate is the expected duration of applying the pending migrations,
+// as returned by EstimatePending.
+type BatchEstimate struct {
+	// Migrations are the pending migrations, in apply order.
+	Migrations []MigrationEstimate `json:"migrations"`
+	// Total is the sum of the estimated durations. It leaves out the
+	// migrations without samples, so it is a lower bound when Unknown is not
+	// 0.
+	Total   time.Duration `json:"total_ns"`
+	Unknown int           `json:"unknown"`
+}
+
+// String formats the estimate as SQL comments, so it can follow a plan.
+func (e BatchEstimate) String() string {
+	var b strings.Builder
+	total := e.Total.Round(time.Second).String()
+	if e.Unknown > 0 {
+		total = fmt.Sprintf("at least %s, %d migration(s) without history", total, e.Unknown)
+	}
+	fmt.Fprintf(&b, "-- Estimated duration: %s\n", total)
+	for _, m := range e.Migrations {
+		if m.Samples == 0 {
+			fmt.Fprintf(&b, "--   %s: unknown\n", m.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "--   %s: %s (%d run(s))\n", m.Name, m.Duration.Round(time.Millisecond), m.Samples)
+	}
+	return b.String()
+}
+
+// EstimatePending estimates how long applying the pending migrations takes
+// from the history of environments that already applied them, such as
+// staging, each read from a HistoryExport JSON document written by
+// ExportHistory. The database is only read.
+//
+// The tracking table records when each migration finished, so a migration
+// took as long as the gap since the previous migration of its batch finished.
+// The first migration of a batch, and migrations absent from the histories,
+// have no samples and are left out of the total.
+func (q *GoMigration) EstimatePending(ctx context.Context, histories ...io.Reader) (BatchEstimate, error) {
+	q = q.snapshot()
+
+	samples := make(map[string][]time.Duration)
+	for _, r := range histories {
+		var history HistoryExport
+		if err := json.NewDecoder(r).Decode(&history); err != nil {
+			return BatchEstimate{}, fmt.Errorf("%w: %w", ErrInvalidHistory, err)
+		}
+		for name, duration := range historyDurations(history.Migrations) {
+			samples[name] = append(samples[name], duration)
+		}
+	}
+
+	migrationsToApply, err := q.pendingMigrations(ctx)
+	if err != nil {
+		return BatchEstimate{}, err
+	}
+
+	var estimate BatchEstimate
+	for _, m := range migrationsToApply {
+		durations := samples[m.Name()]
+		if len(durations) == 0 {
+			estimate.Migrations = append(estimate.Migrations, MigrationEstimate{Name: m.Name()})
+			estimate.Unknown++
+			continue
+		}
+
+		var sum time.Duration
+		for _, d := range durations {
+			sum += d
+		}
+		mean := sum / time.Duration(len(durations))
+		estimate.Migrations = append(estimate.Migrations, MigrationEstimate{Name: m.Name(), Duration: mean, Samples: len(durations)})
+		estimate.Total += mean
+	}
+	return estimate, nil
+}
+
+// historyDurations returns how long the migrations of records took, derived
+// from the gaps between the execution times of migrations applied in the same
+// batch. Records without a batch are skipped, as they may have been recorded
+// without running.
+func historyDurations(records []ExecutedMigration) map[string]time.Duration {
+	batches := make(map[int][]ExecutedMigration)
+	for _, record := range records {
+		if record.Batch > 0 {
+			batches[record.Batch] = append(batches[record.Batch], record)
+		}
+	}
+
+	durations := make(map[string]time.Duration)
+	for _, batch := range batches {
+		slices.SortStableFunc(batch, func(a, b ExecutedMigration) int { return a.ExecutedAt.Compare(b.ExecutedAt) })
+		for i := 1; i < len(batch); i++ {
+			durations[batch[i].Name] = batch[i].ExecutedAt.Sub(batch[i-1].ExecutedAt)
+		}
+	}
+	return durations
+}
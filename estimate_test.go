@@ -0,0 +1,59 @@
+package gomigration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoMigration_EstimatePending(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users", Batch: 1}}, nil)
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users":    dummyMigration{name: "001_create_users"},
+			"002_create_orders":   dummyMigration{name: "002_create_orders"},
+			"003_backfill_totals": dummyMigration{name: "003_backfill_totals"},
+			"004_add_index":       dummyMigration{name: "004_add_index"},
+		},
+	}
+
+	staging := `{"table": "migrations", "migrations": [
+		{"name": "001_create_users", "executed_at": "2025-03-01T10:00:00Z", "batch": 1},
+		{"name": "002_create_orders", "executed_at": "2025-03-01T10:00:02Z", "batch": 1},
+		{"name": "003_backfill_totals", "executed_at": "2025-03-01T10:04:02Z", "batch": 1},
+		{"name": "004_add_index", "executed_at": "2025-03-02T09:00:00Z", "batch": 2}
+	]}`
+	qa := `{"table": "migrations", "migrations": [
+		{"name": "001_create_users", "executed_at": "2025-02-27T08:00:00Z", "batch": 4},
+		{"name": "003_backfill_totals", "executed_at": "2025-02-27T08:02:00Z", "batch": 4},
+		{"name": "002_create_orders", "executed_at": "2025-02-27T08:02:04Z", "batch": 4},
+		{"name": "004_add_index", "executed_at": "2025-02-27T09:00:00Z"}
+	]}`
+
+	estimate, err := q.EstimatePending(ctx, strings.NewReader(staging), strings.NewReader(qa))
+	assert.NoError(t, err)
+	assert.Equal(t, BatchEstimate{
+		Migrations: []MigrationEstimate{
+			{Name: "002_create_orders", Duration: 3 * time.Second, Samples: 2},
+			{Name: "003_backfill_totals", Duration: 3 * time.Minute, Samples: 2},
+			{Name: "004_add_index"},
+		},
+		Total:   3*time.Minute + 3*time.Second,
+		Unknown: 1,
+	}, estimate)
+	assert.Equal(t, "-- Estimated duration: at least 3m3s, 1 migration(s) without history\n"+
+		"--   002_create_orders: 3s (2 run(s))\n"+
+		"--   003_backfill_totals: 3m0s (2 run(s))\n"+
+		"--   004_add_index: unknown\n", estimate.String())
+
+	_, err = q.EstimatePending(ctx, strings.NewReader("not json"))
+	assert.ErrorIs(t, err, ErrInvalidHistory)
+}
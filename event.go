@@ -0,0 +1,228 @@
+package gomigration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Operation names the GoMigration operation an event belongs to.
+type Operation string
+
+const (
+	OperationMigrate   Operation = "migrate"
+	OperationRollback  Operation = "rollback"
+	OperationClean     Operation = "clean"
+	OperationRepair    Operation = "repair"
+	OperationSquash    Operation = "squash"
+	OperationImport    Operation = "import"
+	OperationNamespace Operation = "namespace"
+	OperationReload    Operation = "reload"
+)
+
+// EventType identifies a lifecycle event emitted during a run.
+type EventType string
+
+const (
+	// EventRunStarted is emitted once the migrations of a run are known.
+	EventRunStarted EventType = "run_started"
+	// EventMigrationStarted is emitted before a migration script runs.
+	EventMigrationStarted EventType = "migration_started"
+	// EventMigrationSucceeded is emitted after a migration has been applied
+	// or rolled back and recorded.
+	EventMigrationSucceeded EventType = "migration_succeeded"
+	// EventMigrationFailed is emitted when a migration fails.
+	EventMigrationFailed EventType = "migration_failed"
+	// EventRunFinished is emitted when a run ends, successfully or not.
+	EventRunFinished EventType = "run_finished"
+	// EventTablesDropped is emitted by Clean and Fresh after each chunk of
+	// tables is dropped, when the driver is a ChunkedCleaner.
+	EventTablesDropped EventType = "tables_dropped"
+	// EventMigrationAdded and EventMigrationRemoved are emitted by Reload for
+	// every migration it registers or unregisters.
+	EventMigrationAdded   EventType = "migration_added"
+	EventMigrationRemoved EventType = "migration_removed"
+)
+
+// Event is a machine-readable lifecycle event of a migration run.
+type Event struct {
+	Type      EventType `json:"type"`
+	Operation Operation `json:"operation"`
+	Time      time.Time `json:"time"`
+	// Migration is the migration the event is about, empty for run events.
+	Migration string `json:"migration,omitempty"`
+	// Index is the position of Migration in the run, from 1.
+	Index int `json:"index,omitempty"`
+	// Total is the number of migrations in the run, of tables to drop for
+	// EventTablesDropped, or of migrations registered after a Reload.
+	Total int `json:"total"`
+	// Tables are the tables dropped by the chunk of an EventTablesDropped, and
+	// Dropped how many of Total are dropped so far.
+	Tables  []string `json:"tables,omitempty"`
+	Dropped int      `json:"dropped,omitempty"`
+	// Duration is how long the migration or run took, set on completion.
+	Duration time.Duration `json:"duration_ns,omitempty"`
+	// Error describes the failure of a failed migration or run.
+	Error string `json:"error,omitempty"`
+	// Tenant is the tenant the run migrates, for runs of a GoMigration
+	// returned by ForTenant.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// AuditOutcome is the result of an audited operation.
+type AuditOutcome string
+
+const (
+	AuditOutcomeSucceeded AuditOutcome = "succeeded"
+	AuditOutcomeFailed    AuditOutcome = "failed"
+)
+
+// AuditEvent is an entry of the audit log kept when Config.AuditLog is
+// enabled. One is appended for every apply, rollback, clean, repair, squash
+// and history import.
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	Operation Operation `json:"operation"`
+	// Actor is Config.AppliedBy.
+	Actor string `json:"actor,omitempty"`
+	// Migrations are the migrations the operation applied, rolled back or
+	// repaired, in order.
+	Migrations []string     `json:"migrations,omitempty"`
+	Outcome    AuditOutcome `json:"outcome"`
+	// Error describes why the operation failed.
+	Error string `json:"error,omitempty"`
+}
+
+// EventListener receives lifecycle events as they happen. HandleEvent is
+// called synchronously from the run, so slow listeners slow the run down.
+type EventListener interface {
+	HandleEvent(ctx context.Context, event Event)
+}
+
+// EventListenerFunc adapts a function to the EventListener interface.
+type EventListenerFunc func(ctx context.Context, event Event)
+
+// HandleEvent calls f(ctx, event).
+func (f EventListenerFunc) HandleEvent(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// JSONLinesEventWriter is an EventListener writing each event as one line of
+// JSON, e.g. to a file or FIFO read by deploy tooling in another process.
+type JSONLinesEventWriter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	failed bool
+}
+
+// NewJSONLinesEventWriter creates a JSONLinesEventWriter writing to w.
+func NewJSONLinesEventWriter(w io.Writer) *JSONLinesEventWriter {
+	return &JSONLinesEventWriter{enc: json.NewEncoder(w)}
+}
+
+// HandleEvent writes event as a single JSON line. Write errors are logged once
+// and never fail the run.
+func (w *JSONLinesEventWriter) HandleEvent(ctx context.Context, event Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.enc.Encode(event); err != nil && !w.failed {
+		w.failed = true
+		log.Printf("⚠️ Failed to write event: %s\n", err)
+	}
+}
+
+// subscription is a listener registered with Subscribe.
+type subscription struct {
+	id       int
+	listener EventListener
+}
+
+// Subscribe registers listener for the lifecycle events of subsequent runs and
+// returns a function that removes it again.
+func (q *GoMigration) Subscribe(listener EventListener) (unsubscribe func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextSubscriptionID++
+	id := q.nextSubscriptionID
+	q.subscriptions = append(q.subscriptions, subscription{id: id, listener: listener})
+
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		for i, s := range q.subscriptions {
+			if s.id == id {
+				q.subscriptions = append(q.subscriptions[:i:i], q.subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// emit delivers event to all subscribed listeners in subscription order.
+func (q *GoMigration) emit(ctx context.Context, event Event) {
+	q.mu.Lock()
+	subscriptions := q.subscriptions
+	q.mu.Unlock()
+
+	event.Tenant = q.tenant
+	for _, s := range subscriptions {
+		s.listener.HandleEvent(ctx, event)
+	}
+}
+
+// runEvents emits the lifecycle events of a single run.
+type runEvents struct {
+	q                *GoMigration
+	ctx              context.Context
+	operation        Operation
+	total            int
+	startedAt        time.Time
+	migrationStarted time.Time
+	index            int
+}
+
+// startRun emits EventRunStarted for a run of total migrations.
+func (q *GoMigration) startRun(ctx context.Context, operation Operation, total int) *runEvents {
+	r := &runEvents{q: q, ctx: ctx, operation: operation, total: total, startedAt: time.Now()}
+	r.emit(Event{Type: EventRunStarted})
+	return r
+}
+
+func (r *runEvents) emit(event Event) {
+	event.Operation = r.operation
+	event.Total = r.total
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	r.q.emit(r.ctx, event)
+}
+
+func (r *runEvents) migrationStartedEvent(m Migration) {
+	r.migrationStarted = time.Now()
+	r.index++
+	r.emit(Event{Type: EventMigrationStarted, Migration: m.Name(), Index: r.index})
+}
+
+func (r *runEvents) migrationSucceededEvent(m Migration) {
+	r.emit(Event{Type: EventMigrationSucceeded, Migration: m.Name(), Index: r.index, Duration: time.Since(r.migrationStarted)})
+}
+
+func (r *runEvents) migrationFailedEvent(m Migration, err error) {
+	r.emit(Event{Type: EventMigrationFailed, Migration: m.Name(), Index: r.index, Duration: time.Since(r.migrationStarted), Error: err.Error()})
+}
+
+// finish emits EventRunFinished and passes err through.
+func (r *runEvents) finish(err error) error {
+	event := Event{Type: EventRunFinished, Duration: time.Since(r.startedAt)}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.emit(event)
+	return err
+}
@@ -0,0 +1,70 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGoMigration_Migrate_EmitsEvents(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	users := dummyMigration{name: "001_create_users"}
+	posts := dummyMigration{name: "002_create_posts"}
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+	driver.On("ApplyMigrations", runContext(ctx), []Migration{users, posts}).Return(errors.New("boom"))
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{users.Name(): users, posts.Name(): posts},
+	}
+
+	var events []Event
+	unsubscribe := q.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+		events = append(events, event)
+	}))
+
+	assert.Error(t, q.Migrate(ctx))
+
+	var types []EventType
+	for _, e := range events {
+		assert.Equal(t, OperationMigrate, e.Operation)
+		assert.Equal(t, 2, e.Total)
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{EventRunStarted, EventMigrationStarted, EventMigrationFailed, EventRunFinished}, types)
+	assert.Equal(t, "001_create_users", events[2].Migration)
+	assert.Equal(t, "boom", events[2].Error)
+	assert.Equal(t, "boom", events[3].Error)
+
+	unsubscribe()
+	events = nil
+	assert.Error(t, q.Migrate(ctx))
+	assert.Empty(t, events)
+}
+
+func TestJSONLinesEventWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLinesEventWriter(&buf)
+
+	w.HandleEvent(context.TODO(), Event{Type: EventRunStarted, Operation: OperationRollback, Total: 1})
+	w.HandleEvent(context.TODO(), Event{Type: EventMigrationStarted, Operation: OperationRollback, Migration: "001_create_users", Total: 1})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var event Event
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, EventMigrationStarted, event.Type)
+	assert.Equal(t, "001_create_users", event.Migration)
+	assert.NotContains(t, lines[0], `"migration"`)
+}
@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package gomigration
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// tryLockFile fails: file locks are not supported on this platform.
+func tryLockFile(f *os.File) (bool, error) {
+	return false, fmt.Errorf("file locks are not supported on %s", runtime.GOOS)
+}
+
+// unlockFile does nothing, as no lock can be taken.
+func unlockFile(f *os.File) error {
+	return nil
+}
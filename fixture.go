@@ -0,0 +1,283 @@
+package gomigration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture holds rows to insert into a table, keyed by column name.
+type Fixture struct {
+	Table string
+	Rows  []map[string]any
+}
+
+// FixtureLoader is implemented by drivers that can load fixtures. The
+// built-in drivers do.
+type FixtureLoader interface {
+	// LoadFixtures inserts the rows of fixtures, in order, in a single
+	// transaction. With truncate, the rows of their tables are deleted first,
+	// in reverse order.
+	LoadFixtures(ctx context.Context, fixtures []Fixture, truncate bool) error
+}
+
+// FixtureOptions selects the fixtures LoadFixtures loads.
+type FixtureOptions struct {
+	// Dir is the directory of the fixture files. Defaults to
+	// Config.FixturesDir.
+	Dir string
+	// Truncate deletes the rows of the fixture tables before loading them.
+	Truncate bool
+	// Only restricts loading to the named tables.
+	Only []string
+}
+
+var (
+	// fixtureFilePattern matches the name of a fixture file: the table name,
+	// after an optional numeric prefix ordering the files, and the format.
+	fixtureFilePattern = regexp.MustCompile(`^(?:\d+_)?([a-zA-Z0-9_.]+)\.(ya?ml|json|csv)$`)
+	// fixtureColumnPattern matches valid column names.
+	fixtureColumnPattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+)
+
+// LoadFixtures inserts the rows of the fixture files in opts.Dir in a single
+// transaction, while holding the migration lock. Each file holds the rows of
+// one table, named after it, such as users.yaml, and files are loaded in name
+// order, so tables referenced by foreign keys can be loaded first with a
+// numeric prefix, as in 01_users.yaml and 02_posts.yaml.
+func (q *GoMigration) LoadFixtures(ctx context.Context, opts FixtureOptions) error {
+	q = q.snapshot()
+
+	loader, ok := q.driver.(FixtureLoader)
+	if !ok {
+		return ErrFixturesNotSupported
+	}
+
+	dir := opts.Dir
+	if dir == "" {
+		dir = q.fixturesDir
+	}
+	fixtures, err := ReadFixtures(os.DirFS(dir), ".")
+	if err != nil {
+		return err
+	}
+
+	if len(opts.Only) > 0 {
+		for _, table := range opts.Only {
+			if !slices.ContainsFunc(fixtures, func(f Fixture) bool { return f.Table == table }) {
+				return fmt.Errorf("%w: no fixture for table %s in %s", ErrInvalidFixture, table, dir)
+			}
+		}
+		fixtures = slices.DeleteFunc(fixtures, func(f Fixture) bool { return !slices.Contains(opts.Only, f.Table) })
+	}
+
+	return q.withLock(ctx, func() error {
+		if len(fixtures) == 0 {
+			q.logger.info("✅ No fixtures to load", "no fixtures to load")
+			return nil
+		}
+
+		if err := loader.LoadFixtures(ctx, fixtures, opts.Truncate); err != nil {
+			return err
+		}
+		for _, fixture := range fixtures {
+			q.logger.info(fmt.Sprintf("✅ Loaded %d row(s) into %s", len(fixture.Rows), fixture.Table), "loaded fixture", "table", fixture.Table, "rows", len(fixture.Rows))
+		}
+		return nil
+	})
+}
+
+// ReadFixtures reads the fixture files in the root directory of fsys, in name
+// order. YAML and JSON files hold a list of rows, each mapping column names to
+// values; nested lists and maps are stored as JSON. CSV files start with a
+// header row of column names, and their empty fields are NULL.
+func ReadFixtures(fsys fs.FS, root string) ([]Fixture, error) {
+	entries, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures directory: %w", err)
+	}
+
+	var fixtures []Fixture
+	tables := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fixtureFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		table, format := match[1], match[2]
+		if other, exists := tables[table]; exists {
+			return nil, fmt.Errorf("%w: %s and %s both hold table %s", ErrInvalidFixture, other, entry.Name(), table)
+		}
+		tables[table] = entry.Name()
+
+		data, err := fs.ReadFile(fsys, path.Join(root, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", entry.Name(), err)
+		}
+
+		rows, err := parseFixture(format, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidFixture, entry.Name(), err)
+		}
+		for _, row := range rows {
+			for column := range row {
+				if !fixtureColumnPattern.MatchString(column) {
+					return nil, fmt.Errorf("%w: %s: invalid column name %q", ErrInvalidFixture, entry.Name(), column)
+				}
+			}
+		}
+		fixtures = append(fixtures, Fixture{Table: table, Rows: rows})
+	}
+
+	return fixtures, nil
+}
+
+// parseFixture parses the rows of a fixture file in format.
+func parseFixture(format string, data []byte) ([]map[string]any, error) {
+	var rows []map[string]any
+	switch format {
+	case "csv":
+		return parseCSVFixture(data)
+	case "json":
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&rows); err != nil {
+			return nil, err
+		}
+	default:
+		if err := yaml.Unmarshal(data, &rows); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, row := range rows {
+		for column, value := range row {
+			normalized, err := fixtureValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column, err)
+			}
+			row[column] = normalized
+		}
+	}
+	return rows, nil
+}
+
+// parseCSVFixture parses a CSV fixture: a header row of column names followed
+// by rows of values, empty ones being NULL.
+func parseCSVFixture(data []byte) ([]map[string]any, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]any
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]any, len(header))
+		for i, column := range header {
+			if record[i] == "" {
+				row[column] = nil
+			} else {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// fixtureValue converts a value decoded from YAML or JSON to one the database
+// drivers accept.
+func fixtureValue(value any) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []any, map[string]any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return v, nil
+	}
+}
+
+// loadFixtures implements FixtureLoader for the built-in drivers.
+func loadFixtures(ctx context.Context, db *sql.DB, dialect Dialect, fixtures []Fixture, truncate bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for fixtures: %w", err)
+	}
+	defer tx.Rollback()
+
+	if truncate {
+		for _, fixture := range slices.Backward(fixtures) {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+quoteIdentifier(dialect, fixture.Table)); err != nil {
+				return fmt.Errorf("failed to truncate %s: %w", fixture.Table, err)
+			}
+		}
+	}
+
+	for _, fixture := range fixtures {
+		for i, row := range fixture.Rows {
+			columns := make([]string, 0, len(row))
+			for column := range row {
+				columns = append(columns, column)
+			}
+			slices.Sort(columns)
+
+			quoted := make([]string, len(columns))
+			placeholders := make([]string, len(columns))
+			args := make([]any, len(columns))
+			for j, column := range columns {
+				quoted[j] = quoteIdentifier(dialect, column)
+				placeholders[j] = "?"
+				if dialect == DialectPostgres {
+					placeholders[j] = fmt.Sprintf("$%d", j+1)
+				}
+				args[j] = row[column]
+			}
+
+			query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				quoteIdentifier(dialect, fixture.Table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to load row %d of %s: %w", i+1, fixture.Table, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit fixtures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package gomigration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFixtures(t *testing.T) {
+	fsys := fstest.MapFS{
+		"01_users.yaml": {Data: []byte("- id: 1\n  email: ada@example.com\n  settings: {theme: dark}\n- id: 2\n  email: bob@example.com\n")},
+		"02_posts.json": {Data: []byte(`[{"id": 1, "user_id": 1, "score": 4.5}]`)},
+		"tags.csv":      {Data: []byte("id,name,color\n1,go,\n2,sql,blue\n")},
+		"README.md":     {Data: []byte("not a fixture")},
+	}
+
+	fixtures, err := ReadFixtures(fsys, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, []Fixture{
+		{Table: "users", Rows: []map[string]any{
+			{"id": 1, "email": "ada@example.com", "settings": `{"theme":"dark"}`},
+			{"id": 2, "email": "bob@example.com"},
+		}},
+		{Table: "posts", Rows: []map[string]any{
+			{"id": int64(1), "user_id": int64(1), "score": 4.5},
+		}},
+		{Table: "tags", Rows: []map[string]any{
+			{"id": "1", "name": "go", "color": nil},
+			{"id": "2", "name": "sql", "color": "blue"},
+		}},
+	}, fixtures)
+
+	_, err = ReadFixtures(fstest.MapFS{"users.yaml": {Data: []byte("- id: 1\n")}, "users.json": {Data: []byte("[]")}}, ".")
+	assert.ErrorIs(t, err, ErrInvalidFixture)
+
+	_, err = ReadFixtures(fstest.MapFS{"users.json": {Data: []byte(`[{"id; DROP TABLE users": 1}]`)}}, ".")
+	assert.ErrorIs(t, err, ErrInvalidFixture)
+
+	_, err = ReadFixtures(fstest.MapFS{"users.json": {Data: []byte(`{"id": 1}`)}}, ".")
+	assert.ErrorIs(t, err, ErrInvalidFixture)
+}
+
+func TestGoMigration_LoadFixtures(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	driver, err := NewSqliteDriver(filepath.Join(dir, "fixtures.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	_, err = driver.db.ExecContext(ctx, `
+		CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id), title TEXT);
+		INSERT INTO users (id, email) VALUES (9, 'stale@example.com');
+	`)
+	assert.NoError(t, err)
+
+	fixturesDir := filepath.Join(dir, "fixtures")
+	assert.NoError(t, os.Mkdir(fixturesDir, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(fixturesDir, "01_users.yaml"), []byte("- id: 1\n  email: ada@example.com\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(fixturesDir, "02_posts.csv"), []byte("id,user_id,title\n1,1,Hello\n2,1,\n"), 0o644))
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations", migrations: make(map[string]Migration), fixturesDir: fixturesDir}
+	driver.SetMigrationTableName(q.migrationTableName)
+
+	count := func(query string) int {
+		var n int
+		assert.NoError(t, driver.db.QueryRowContext(ctx, query).Scan(&n))
+		return n
+	}
+
+	assert.NoError(t, q.LoadFixtures(ctx, FixtureOptions{Truncate: true}))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM users"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM posts WHERE title IS NULL"))
+
+	err = q.LoadFixtures(ctx, FixtureOptions{Only: []string{"users"}})
+	assert.ErrorContains(t, err, "failed to load row 1 of users")
+	assert.Equal(t, 2, count("SELECT COUNT(*) FROM posts"))
+
+	err = q.LoadFixtures(ctx, FixtureOptions{Only: []string{"comments"}})
+	assert.ErrorIs(t, err, ErrInvalidFixture)
+
+	q = &GoMigration{driver: &mockDriver{}}
+	assert.ErrorIs(t, q.LoadFixtures(ctx, FixtureOptions{}), ErrFixturesNotSupported)
+}
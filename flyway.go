@@ -0,0 +1,128 @@
+package gomigration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+)
+
+// flywayTable is the table in which Flyway records its history.
+const flywayTable = "flyway_schema_history"
+
+// FlywayMigration is a row of Flyway's flyway_schema_history table.
+type FlywayMigration struct {
+	InstalledRank int64
+	// Version is empty for repeatable migrations.
+	Version     string
+	Description string
+	// Type is the kind of row, such as SQL, JDBC, BASELINE or UNDO_SQL.
+	Type string
+	// Script is the file name of the migration, such as
+	// V1__create_users.sql.
+	Script string
+	// Checksum is the CRC32 Flyway computed from the script, nil for rows
+	// without a script.
+	Checksum    *int32
+	InstalledBy string
+	InstalledOn time.Time
+	Success     bool
+}
+
+// FlywayReader is implemented by drivers that can read the history recorded
+// by Flyway. It is required by MigrateFromFlyway.
+type FlywayReader interface {
+	// FlywayHistory returns the rows of Flyway's flyway_schema_history table,
+	// in installation order.
+	FlywayHistory(ctx context.Context) ([]FlywayMigration, error)
+}
+
+// flywayHistory reads the rows of Flyway's history table from db.
+func flywayHistory(ctx context.Context, db *sql.DB) ([]FlywayMigration, error) {
+	query := fmt.Sprintf(`
+		SELECT installed_rank, version, description, type, script, checksum, installed_by, installed_on, success
+		FROM %s
+		ORDER BY installed_rank
+	`, flywayTable)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Flyway history from %s: %w", flywayTable, err)
+	}
+	defer rows.Close()
+
+	var history []FlywayMigration
+	for rows.Next() {
+		var m FlywayMigration
+		var version sql.NullString
+		var checksum sql.NullInt32
+		if err := rows.Scan(&m.InstalledRank, &version, &m.Description, &m.Type, &m.Script, &checksum, &m.InstalledBy, &m.InstalledOn, &m.Success); err != nil {
+			return nil, err
+		}
+		m.Version = version.String
+		if checksum.Valid {
+			m.Checksum = &checksum.Int32
+		}
+		history = append(history, m)
+	}
+	return history, rows.Err()
+}
+
+// appliedFlywayMigrations returns the versioned migrations Flyway considers
+// applied, in installation order, and a migration that failed and has not
+// been applied since, if any. Undone and deleted migrations are left out, and
+// so are baselines, which mark migrations Flyway never ran.
+func appliedFlywayMigrations(history []FlywayMigration) (applied []FlywayMigration, failed *FlywayMigration) {
+	state := make(map[string]*FlywayMigration)
+	failures := make(map[string]*FlywayMigration)
+	var order []string
+	for i := range history {
+		m := &history[i]
+		switch {
+		case m.Version == "" || m.Type == "SCHEMA" || m.Type == "BASELINE":
+			continue
+		case !m.Success:
+			failures[m.Version] = m
+		case m.Type == "DELETE" || strings.HasPrefix(m.Type, "UNDO_"):
+			delete(state, m.Version)
+		default:
+			if _, seen := state[m.Version]; !seen {
+				order = append(order, m.Version)
+			}
+			state[m.Version] = m
+			delete(failures, m.Version)
+		}
+	}
+
+	for i := range history {
+		if m := &history[i]; failures[m.Version] == m {
+			return nil, m
+		}
+	}
+	for _, version := range order {
+		if m, ok := state[version]; ok {
+			applied = append(applied, *m)
+			delete(state, version)
+		}
+	}
+	return applied, nil
+}
+
+// flywayChecksum computes the checksum Flyway records for script: the CRC32
+// of its lines without line terminators or byte order mark.
+func flywayChecksum(script string) int32 {
+	script = strings.TrimPrefix(script, "\ufeff")
+	script = strings.NewReplacer("\r", "", "\n", "").Replace(script)
+	return int32(crc32.ChecksumIEEE([]byte(script)))
+}
+
+// flywayEditedChecksum returns the checksum recorded for a migration edited
+// since Flyway applied it with checksum. Tracking table checksums are SHA-256
+// hashes, so it is one derived from Flyway's, which no up script matches.
+func flywayEditedChecksum(checksum int32) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("flyway:%d", checksum)))
+	return hex.EncodeToString(sum[:])
+}
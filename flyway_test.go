@@ -0,0 +1,104 @@
+package gomigration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppliedFlywayMigrations(t *testing.T) {
+	history := []FlywayMigration{
+		{InstalledRank: 1, Version: "1", Type: "BASELINE", Success: true},
+		{InstalledRank: 2, Version: "2", Type: "SQL", Script: "V2__create_users.sql", Success: true},
+		{InstalledRank: 3, Version: "", Type: "SQL", Script: "R__views.sql", Success: true},
+		{InstalledRank: 4, Version: "3", Type: "SQL", Script: "V3__create_posts.sql", Success: true},
+		{InstalledRank: 5, Version: "3", Type: "UNDO_SQL", Script: "U3__create_posts.sql", Success: true},
+		{InstalledRank: 6, Version: "4", Type: "SQL", Script: "V4__create_tags.sql", Success: false},
+		{InstalledRank: 7, Version: "4", Type: "SQL", Script: "V4__create_tags.sql", Success: true},
+	}
+
+	applied, failed := appliedFlywayMigrations(history)
+	assert.Nil(t, failed)
+	assert.Equal(t, []FlywayMigration{history[1], history[6]}, applied)
+
+	history = append(history, FlywayMigration{InstalledRank: 8, Version: "5", Type: "SQL", Script: "V5__broken.sql", Success: false})
+	applied, failed = appliedFlywayMigrations(history)
+	assert.Nil(t, applied)
+	assert.Equal(t, &history[7], failed)
+}
+
+func TestFlywayChecksum(t *testing.T) {
+	script := "CREATE TABLE users (\n    id INT\n);\n"
+	assert.Equal(t, flywayChecksum(script), flywayChecksum("\ufeffCREATE TABLE users (\r\n    id INT\r\n);"))
+	assert.NotEqual(t, flywayChecksum(script), flywayChecksum("CREATE TABLE users (id INT);"))
+}
+
+func TestGoMigration_MigrateFromFlyway(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "flyway.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	users := scriptMigration{name: "V1__create_users", upScript: "CREATE TABLE users (id INT);"}
+	posts := scriptMigration{name: "0002_create_posts", upScript: "CREATE TABLE posts (id INT);"}
+	tags := scriptMigration{name: "V3__create_tags", upScript: "CREATE TABLE tags (id INT);", variants: map[Dialect]scriptVariant{
+		DialectSQLite: {upScript: "CREATE TABLE tags (id INTEGER);"},
+	}}
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{users.name: users, posts.name: posts, tags.name: tags},
+	}
+
+	_, err = driver.db.ExecContext(ctx, `
+		CREATE TABLE flyway_schema_history (
+			installed_rank INT PRIMARY KEY,
+			version VARCHAR(50),
+			description VARCHAR(200) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			script VARCHAR(1000) NOT NULL,
+			checksum INT,
+			installed_by VARCHAR(100) NOT NULL,
+			installed_on TIMESTAMP NOT NULL,
+			execution_time INT NOT NULL,
+			success BOOLEAN NOT NULL
+		)
+	`)
+	assert.NoError(t, err)
+	insert := `INSERT INTO flyway_schema_history VALUES (?, ?, ?, 'SQL', ?, ?, 'deployer', ?, 10, 1)`
+	installedOn := time.Date(2025, 4, 18, 22, 0, 0, 0, time.UTC)
+	_, err = driver.db.ExecContext(ctx, insert, 1, "1", "create users", "V1__create_users.sql", flywayChecksum(users.upScript), installedOn)
+	assert.NoError(t, err)
+	// The script of version 2 was edited after Flyway applied it.
+	_, err = driver.db.ExecContext(ctx, insert, 2, "2", "create posts", "V2__create_posts.sql", 42, installedOn.Add(time.Minute))
+	assert.NoError(t, err)
+	// Flyway applied the SQLite variant of version 3.
+	_, err = driver.db.ExecContext(ctx, insert, 3, "3", "create tags", "V3__create_tags.sql", flywayChecksum(tags.variants[DialectSQLite].upScript), installedOn.Add(2*time.Minute))
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.MigrateFromFlyway(ctx))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 3) {
+		assert.Equal(t, "V1__create_users", executed[0].Name)
+		assert.Equal(t, migrationChecksum(users), executed[0].Checksum)
+		assert.Equal(t, "deployer", executed[0].AppliedBy)
+		assert.True(t, executed[0].ExecutedAt.Equal(installedOn))
+
+		assert.Equal(t, "0002_create_posts", executed[1].Name)
+		assert.Equal(t, flywayEditedChecksum(42), executed[1].Checksum)
+		assert.True(t, q.editedSinceApplied(executed[1]))
+
+		assert.Equal(t, "V3__create_tags", executed[2].Name)
+		assert.Equal(t, migrationChecksum(tags), executed[2].Checksum)
+		assert.False(t, q.editedSinceApplied(executed[2]))
+	}
+}
+
+func TestGoMigration_MigrateFromFlyway_NotSupported(t *testing.T) {
+	q := &GoMigration{driver: new(mockDriver)}
+	assert.Equal(t, ErrFlywayNotSupported, q.MigrateFromFlyway(context.Background()))
+}
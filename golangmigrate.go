@@ -0,0 +1,35 @@
+package gomigration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// golangMigrateTable is the table in which golang-migrate records its version.
+const golangMigrateTable = "schema_migrations"
+
+// GolangMigrateReader is implemented by drivers that can read the version
+// recorded by golang-migrate. It is required by MigrateFromGolangMigrate.
+type GolangMigrateReader interface {
+	// GolangMigrateVersion returns the version and dirty flag recorded in
+	// golang-migrate's schema_migrations table. The version is -1 when no
+	// migration has been applied.
+	GolangMigrateVersion(ctx context.Context) (version int64, dirty bool, err error)
+}
+
+// golangMigrateVersion reads the version row of golang-migrate from db.
+func golangMigrateVersion(ctx context.Context, db *sql.DB) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	query := fmt.Sprintf(`SELECT version, dirty FROM %s LIMIT 1`, golangMigrateTable)
+	err := db.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read golang-migrate version from %s: %w", golangMigrateTable, err)
+	}
+	return version, dirty, nil
+}
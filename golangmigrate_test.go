@@ -0,0 +1,59 @@
+package gomigration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoMigration_MigrateFromGolangMigrate(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "golang-migrate.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+	driver.SetMigrationTableName("migrations")
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"000001_create_users": dummyMigration{name: "000001_create_users"},
+			"000002_create_posts": dummyMigration{name: "000002_create_posts"},
+			"000003_create_tags":  dummyMigration{name: "000003_create_tags"},
+		},
+	}
+
+	_, err = driver.db.ExecContext(ctx, `CREATE TABLE schema_migrations (version INTEGER NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	assert.NoError(t, err)
+
+	// Nothing applied yet.
+	assert.NoError(t, q.MigrateFromGolangMigrate(ctx))
+
+	_, err = driver.db.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES (2, true)`)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, q.MigrateFromGolangMigrate(ctx), ErrGolangMigrateDirty)
+
+	_, err = driver.db.ExecContext(ctx, `UPDATE schema_migrations SET version = 4, dirty = false`)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, q.MigrateFromGolangMigrate(ctx), ErrMigrationNotRegistered)
+
+	_, err = driver.db.ExecContext(ctx, `UPDATE schema_migrations SET version = 2`)
+	assert.NoError(t, err)
+	assert.NoError(t, q.MigrateFromGolangMigrate(ctx))
+	assert.NoError(t, q.MigrateFromGolangMigrate(ctx))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 2) {
+		assert.Equal(t, "000001_create_users", executed[0].Name)
+		assert.Equal(t, "000002_create_posts", executed[1].Name)
+		assert.Equal(t, migrationChecksum(dummyMigration{name: "000002_create_posts"}), executed[1].Checksum)
+	}
+}
+
+func TestGoMigration_MigrateFromGolangMigrate_NotSupported(t *testing.T) {
+	q := &GoMigration{driver: new(mockDriver)}
+	assert.Equal(t, ErrGolangMigrateNotSupported, q.MigrateFromGolangMigrate(context.Background()))
+}
@@ -5,20 +5,61 @@ package gomigration
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
+	"path"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 // GoMigration is the main struct for managing and executing database migrations.
 type GoMigration struct {
-	driver            Driver
-	migrationFilesDir string
-	debugSql          bool
-	migrations        map[string]Migration
-	mu                sync.Mutex
+	driver             Driver
+	migrationFilesDir  string
+	debugSql           bool
+	logger             runLogger
+	metrics            *metricsListener
+	migrationTableName string
+	lockTimeout        time.Duration
+	locker             Locker
+	lockRenewInterval  time.Duration
+	migrations         map[string]Migration
+	registeredFrom     map[string]string
+	sets               map[string]MigrationSet
+	fileSources        []fileSource
+	migrationOrder     []string
+	migrationOrderFile string
+	migrationID        MigrationIDGenerator
+	appliedBy          string
+	build              *BuildInfo
+	auditLog           bool
+	outOfOrderPolicy   OutOfOrderPolicy
+	emptyScriptPolicy  EmptyScriptPolicy
+	templateData       map[string]any
+	envExpansion       EnvExpansion
+	lockScope          string
+	seeders            []Seeder
+	environment        string
+	fixturesDir        string
+	createTemplates    CreateTemplates
+	namingStrategy     NamingStrategy
+	tenantProvider     TenantProvider
+	tenant             string
+	baseDriver         Driver
+	middleware         []DriverMiddleware
+	subscriptions      []subscription
+	runHooks           []runHook
+	nextSubscriptionID int
+	mu                 sync.Mutex
 }
 
 // New creates a new instance of GoMigration using the provided configuration.
@@ -38,285 +79,2658 @@ func New(config *Config) (*GoMigration, error) {
 	if config.MigrationTableName == "" {
 		config.MigrationTableName = "migrations"
 	}
+	if config.LockRenewInterval <= 0 {
+		config.LockRenewInterval = 10 * time.Second
+	}
+	if config.TrackingTimeout == 0 {
+		config.TrackingTimeout = 30 * time.Second
+	}
+	if config.AppliedBy == "" {
+		config.AppliedBy = appliedByFromEnv()
+	}
+	config.Build = buildInfoFromEnv(config.Build)
+	if config.FixturesDir == "" {
+		config.FixturesDir = "fixtures"
+	}
+	if config.Environment == "" {
+		config.Environment = os.Getenv("GOMIGRATION_ENV")
+	}
 
 	if _, err := sanitizeTableName(config.MigrationTableName); err != nil {
 		return nil, fmt.Errorf("invalid migration table name: %w", err)
 	}
+	if config.LockScope != "" {
+		if _, err := sanitizeTableName(config.LockScope); err != nil {
+			return nil, fmt.Errorf("invalid lock scope: %w", err)
+		}
+	}
+
+	if _, _, err := config.CreateTemplates.parse(); err != nil {
+		return nil, fmt.Errorf("invalid create templates: %w", err)
+	}
+
+	if _, ok := config.Driver.(AuditLogger); config.AuditLog && !ok {
+		return nil, ErrAuditLogNotSupported
+	}
+
+	logger := newRunLogger(config.Logger, config.LogSQL)
+	if d, ok := config.Driver.(loggingDriver); ok {
+		d.setLogger(logger)
+	}
+
+	if d, ok := config.Driver.(connectingDriver); ok && config.ConnectRetry.Attempts > 0 {
+		if err := d.waitForDatabase(context.Background(), config.ConnectRetry); err != nil {
+			return nil, fmt.Errorf("failed to connect to the database: %w", err)
+		}
+	}
 
 	config.Driver.SetMigrationTableName(config.MigrationTableName)
+	if d, ok := config.Driver.(configurableDriver); ok {
+		d.configure(config)
+	}
+
+	q := &GoMigration{
+		driver:             config.Driver,
+		migrationFilesDir:  config.MigrationFilesDir,
+		debugSql:           config.DebugSql,
+		logger:             logger,
+		migrationTableName: config.MigrationTableName,
+		lockTimeout:        config.LockTimeout,
+		locker:             config.Locker,
+		lockRenewInterval:  config.LockRenewInterval,
+		migrations:         make(map[string]Migration),
+		migrationOrderFile: config.MigrationOrderFile,
+		migrationID:        config.MigrationIDGenerator,
+		appliedBy:          config.AppliedBy,
+		build:              config.Build.orNil(),
+		auditLog:           config.AuditLog,
+		outOfOrderPolicy:   config.OutOfOrderPolicy,
+		emptyScriptPolicy:  config.EmptyScriptPolicy,
+		templateData:       config.TemplateData,
+		envExpansion:       config.EnvExpansion,
+		lockScope:          config.LockScope,
+		environment:        config.Environment,
+		fixturesDir:        config.FixturesDir,
+		createTemplates:    config.CreateTemplates,
+		namingStrategy:     config.NamingStrategy,
+		tenantProvider:     config.TenantProvider,
+	}
+
+	if config.MetricsCollector != nil {
+		q.metrics = &metricsListener{collector: config.MetricsCollector}
+		q.Subscribe(q.metrics)
+	}
+
+	if config.MigrationOrderFile != "" {
+		f, err := os.Open(config.MigrationOrderFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open migration order file: %w", err)
+		}
+		defer f.Close()
+
+		if err := q.LoadMigrationOrder(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// snapshot returns a copy of q with the registry, hooks and listeners it has
+// now, so an operation can read them without holding q.mu while Register,
+// Reload or Squash change those of q.
+func (q *GoMigration) snapshot() *GoMigration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.clone()
+}
 
+// clone returns a copy of q whose registry, hooks and listeners can be
+// changed without affecting q. It must be called with q.mu held.
+func (q *GoMigration) clone() *GoMigration {
 	return &GoMigration{
-		driver:            config.Driver,
-		migrationFilesDir: config.MigrationFilesDir,
-		debugSql:          config.DebugSql,
-		migrations:        make(map[string]Migration),
-	}, nil
+		driver:             q.driver,
+		migrationFilesDir:  q.migrationFilesDir,
+		debugSql:           q.debugSql,
+		logger:             q.logger,
+		metrics:            q.metrics,
+		migrationTableName: q.migrationTableName,
+		lockTimeout:        q.lockTimeout,
+		locker:             q.locker,
+		lockRenewInterval:  q.lockRenewInterval,
+		migrations:         maps.Clone(q.migrations),
+		registeredFrom:     maps.Clone(q.registeredFrom),
+		sets:               maps.Clone(q.sets),
+		fileSources:        slices.Clone(q.fileSources),
+		migrationOrder:     slices.Clone(q.migrationOrder),
+		migrationOrderFile: q.migrationOrderFile,
+		migrationID:        q.migrationID,
+		appliedBy:          q.appliedBy,
+		build:              q.build,
+		auditLog:           q.auditLog,
+		outOfOrderPolicy:   q.outOfOrderPolicy,
+		emptyScriptPolicy:  q.emptyScriptPolicy,
+		templateData:       q.templateData,
+		envExpansion:       q.envExpansion,
+		lockScope:          q.lockScope,
+		seeders:            slices.Clone(q.seeders),
+		environment:        q.environment,
+		fixturesDir:        q.fixturesDir,
+		createTemplates:    q.createTemplates,
+		namingStrategy:     q.namingStrategy,
+		tenantProvider:     q.tenantProvider,
+		tenant:             q.tenant,
+		baseDriver:         q.baseDriver,
+		middleware:         q.middleware,
+		subscriptions:      slices.Clone(q.subscriptions),
+		runHooks:           slices.Clone(q.runHooks),
+		nextSubscriptionID: q.nextSubscriptionID,
+	}
 }
 
 // Register adds one or more Migration instances to the internal registry.
-// It ensures no duplicate migration names are registered.
+// It ensures no duplicate migration names are registered, and registers none
+// of the migrations if one of them is invalid. Register is safe for concurrent
+// use, such as from the init functions of several packages, and while other
+// operations run: they use the registry as it was when they started.
 func (q *GoMigration) Register(migrations ...Migration) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	for _, migration := range migrations {
-		name := migration.Name()
-		if name == "" {
-			return ErrMigrationNameNotProvided
+	return q.register(callerLocation(), migrations)
+}
+
+// RegisterMany registers migrations atomically, like Register, but validates
+// all of them before failing: the error joins the problems of every invalid
+// migration, so a generated or plugin-provided batch can be fixed in one go.
+func (q *GoMigration) RegisterMany(migrations ...Migration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if errs := q.registrationErrors(migrations); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return q.register(callerLocation(), migrations)
+}
+
+// RegisterSet adds the migrations of one or more migration sets exported by
+// libraries, under the namespace of their set. Sets are applied before the
+// service's own migrations, each after the sets it lists in After, and sets
+// without such a constraint between them by name. Within a set, migrations
+// run by name, and a pending migration only counts as out of order when a
+// later migration of the same set was executed, so libraries can ship new
+// migrations independently of each other.
+func (q *GoMigration) RegisterSet(sets ...MigrationSet) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	registeredFrom := callerLocation()
+	for _, set := range sets {
+		if !migrationSetNameRegex.MatchString(set.Name) {
+			return fmt.Errorf("%w: invalid name %q", ErrInvalidMigrationSet, set.Name)
 		}
-		if _, exists := q.migrations[name]; exists {
-			return fmt.Errorf("migration %s registered more than once", name)
+		if _, exists := q.sets[set.Name]; exists {
+			return fmt.Errorf("%w: %s registered more than once", ErrInvalidMigrationSet, set.Name)
 		}
-		q.migrations[name] = migration
+
+		migrations := make([]Migration, 0, len(set.Migrations))
+		for _, migration := range set.Migrations {
+			if migration.Name() == "" {
+				return ErrMigrationNameNotProvided
+			}
+			migrations = append(migrations, setMigration{Migration: migration, set: set.Name})
+		}
+		if err := q.register(registeredFrom, migrations); err != nil {
+			return err
+		}
+
+		if q.sets == nil {
+			q.sets = make(map[string]MigrationSet)
+		}
+		q.sets[set.Name] = set
 	}
 
 	return nil
 }
 
-// Set migration files directory.
-func (q *GoMigration) SetMigrationFilesDir(dir string) *GoMigration {
-	q.migrationFilesDir = dir
-	return q
-}
-
-// Create generates a new migration file using the given name.
-// The generated file includes a timestamp prefix and basic template content.
-func (q *GoMigration) Create(fileName string) error {
-	if !migrationDirExists(q.migrationFilesDir) {
-		return fmt.Errorf("migration directory %q does not exist", q.migrationFilesDir)
+// register adds migrations to the registry, recording them as registered from
+// registeredFrom. Nothing is added if one of them is invalid.
+func (q *GoMigration) register(registeredFrom string, migrations []Migration) error {
+	if errs := q.registrationErrors(migrations); len(errs) > 0 {
+		return errs[0]
 	}
 
-	if fileName == "" {
-		return ErrMigrationNameNotProvided
+	if q.migrations == nil {
+		q.migrations = make(map[string]Migration)
+	}
+	if q.registeredFrom == nil {
+		q.registeredFrom = make(map[string]string)
+	}
+	for _, migration := range migrations {
+		q.migrations[migration.Name()] = migration
+		q.registeredFrom[migration.Name()] = registeredFrom
 	}
 
-	migrationName, err := sanitizeMigrationName(fileName)
+	return nil
+}
+
+// Unregister removes the named migration from the registry, so tests and
+// plugin systems can adjust the registry of an instance instead of building a
+// new one. It does nothing if no migration has that name. The tracking table
+// is left untouched, so Validate reports an executed migration that is
+// unregistered as no longer registered.
+func (q *GoMigration) Unregister(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.migrations, name)
+	delete(q.registeredFrom, name)
+}
+
+// Registered returns the registered migrations in the order they are applied,
+// or by name if the explicit migration order does not match them. Migrations
+// of a MigrationSet are named after their set, e.g. "billing/001_invoices".
+func (q *GoMigration) Registered() []Migration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	names, err := q.orderedMigrationNames()
 	if err != nil {
-		return err
+		names = getSortedMigrationName(q.migrations)
 	}
 
-	migrationName = fmt.Sprintf("%s_%s", time.Now().Format("20060102150405"), migrationName)
-	migrationFileName := fmt.Sprintf("%s/%s.go", q.migrationFilesDir, migrationName)
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		migrations = append(migrations, q.migrations[name])
+	}
+	return migrations
+}
 
-	if fileExists(migrationFileName) {
-		return ErrMigrationFileAlreadyExists
+// registrationErrors returns why migrations cannot be registered, in order.
+func (q *GoMigration) registrationErrors(migrations []Migration) []error {
+	var errs []error
+	seen := make(map[string]bool, len(migrations))
+	for i, migration := range migrations {
+		if migration == nil {
+			errs = append(errs, fmt.Errorf("%w: migration %d is nil", ErrMigrationNotProvided, i+1))
+			continue
+		}
+		name := migration.Name()
+		if name == "" {
+			errs = append(errs, ErrMigrationNameNotProvided)
+			continue
+		}
+		if _, exists := q.migrations[name]; exists || seen[name] {
+			errs = append(errs, fmt.Errorf("migration %s registered more than once", name))
+		}
+		seen[name] = true
 	}
+	return errs
+}
+
+// callerLocation returns the file and line of the call to the exported method
+// calling it.
+func callerLocation() string {
+	if _, file, line, ok := runtime.Caller(2); ok {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return "unknown"
+}
 
-	template, err := migrationFileTemplate(getPackageNameFromMigrationDir(q.migrationFilesDir), migrationName)
+// Migrations returns a snapshot of the registered migrations in the order they
+// are applied, for host applications building their own admin pages or
+// checks. If the explicit migration order does not match the registered
+// migrations, they are returned by name.
+func (q *GoMigration) Migrations() []MigrationInfo {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	names, err := q.orderedMigrationNames()
 	if err != nil {
-		return err
+		names = getSortedMigrationName(q.migrations)
 	}
 
-	err = os.WriteFile(migrationFileName, []byte(template), 0644)
+	infos := make([]MigrationInfo, 0, len(names))
+	for _, name := range names {
+		migration := q.migrations[name]
+		info := MigrationInfo{
+			Name:           name,
+			Source:         migrationSource(migration),
+			Checksum:       migrationChecksum(migration),
+			RegisteredFrom: q.registeredFrom[name],
+		}
+		if m, ok := migration.(setMigration); ok {
+			info.Set = m.set
+		}
+		if described, ok := migration.(DescribedMigration); ok {
+			info.Description = described.Description()
+		}
+		info.Tags = migrationTags(migration)
+		info.DependsOn = migrationDependencies(migration)
+		info.Phase = migrationPhase(migration)
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+// LoadMigrationOrder reads an explicit migration order, one name per line,
+// e.g. from an embedded migrations.list. Blank lines and lines starting with
+// # are ignored. Once loaded, migrations run in the listed order instead of
+// by name, and every operation fails if a registered migration is not listed
+// or a listed one is not registered.
+func (q *GoMigration) LoadMigrationOrder(r io.Reader) error {
+	order, err := parseMigrationOrder(r)
 	if err != nil {
 		return err
 	}
-	log.Printf("migration file created: %s\n", migrationFileName)
 
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.migrationOrder = order
 	return nil
 }
 
-// Migrate applies all pending migrations in the correct order.
-// It skips migrations that have already been executed.
-func (q *GoMigration) Migrate(ctx context.Context) error {
-	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
-		return err
+// orderedMigrationNames returns the names of the registered migrations in the
+// order they are applied: the explicit order if one was loaded, the order of
+// migration sets followed by the service's own migrations by name otherwise.
+func (q *GoMigration) orderedMigrationNames() ([]string, error) {
+	if q.migrationOrder == nil && len(q.sets) == 0 {
+		return getSortedMigrationName(q.migrations), nil
 	}
-
-	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, false)
-	if err != nil {
-		return err
+	if q.migrationOrder == nil {
+		return q.setMigrationOrder()
 	}
 
-	executedMap := make(map[string]struct{}, len(executedMigrations))
-	for _, m := range executedMigrations {
-		executedMap[m.Name] = struct{}{}
+	listed := make(map[string]bool, len(q.migrationOrder))
+	var notRegistered []string
+	for _, name := range q.migrationOrder {
+		listed[name] = true
+		if _, ok := q.migrations[name]; !ok {
+			notRegistered = append(notRegistered, name)
+		}
 	}
 
-	migrationsToApply := make([]Migration, 0, len(q.migrations))
+	var notListed []string
 	for _, name := range getSortedMigrationName(q.migrations) {
-		migration := q.migrations[name]
-		if _, found := executedMap[migration.Name()]; !found {
-			migrationsToApply = append(migrationsToApply, migration)
+		if !listed[name] {
+			notListed = append(notListed, name)
 		}
 	}
 
-	if len(migrationsToApply) == 0 {
-		log.Println("✅ No migrations to run")
-		return nil
+	if len(notListed) > 0 || len(notRegistered) > 0 {
+		var problems []string
+		if len(notListed) > 0 {
+			problems = append(problems, "not listed: "+strings.Join(notListed, ", "))
+		}
+		if len(notRegistered) > 0 {
+			problems = append(problems, "not registered: "+strings.Join(notRegistered, ", "))
+		}
+		return nil, fmt.Errorf("%w: %s", ErrMigrationOrderMismatch, strings.Join(problems, "; "))
 	}
 
-	log.Printf("🚀 Applying %d migration(s)...\n", len(migrationsToApply))
-
-	return q.driver.ApplyMigrations(
-		ctx,
-		migrationsToApply,
-		func(m *Migration) {
-			log.Printf("📦 Migrating: %s\n", (*m).Name())
-			if q.debugSql {
-				log.Println("🧾 Running SQL:")
-				fmt.Println("================================================")
-				fmt.Println((*m).UpScript())
-				fmt.Println("================================================")
-			}
-		},
-		func(m *Migration) {
-			log.Printf("✅ Migrated: %s\n", (*m).Name())
-		},
-		func(m *Migration, err error) {
-			log.Printf("❌ Migration failed: %s - %s\n", (*m).Name(), err)
-		},
-	)
+	return append([]string(nil), q.migrationOrder...), nil
 }
 
-// Fresh wipes the database clean and reapplies all registered migrations from scratch.
-func (q *GoMigration) Fresh(ctx context.Context) error {
-	log.Println("🧹 Cleaning database...")
-
-	if err := q.driver.CleanDatabase(ctx); err != nil {
-		return fmt.Errorf("failed to clean database: %w", err)
+// setMigrationOrder returns the names of the registered migrations ordered by
+// migration set, dependencies first, then by name, followed by the service's
+// own migrations.
+func (q *GoMigration) setMigrationOrder() ([]string, error) {
+	sets, err := sortMigrationSets(q.sets)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Println("🚀 Running fresh migrations...")
+	bySet := make(map[string][]string, len(sets)+1)
+	for _, name := range getSortedMigrationName(q.migrations) {
+		set := migrationSetOf(q.migrations[name])
+		bySet[set] = append(bySet[set], name)
+	}
 
-	if err := q.Migrate(ctx); err != nil {
-		return fmt.Errorf("failed to run migrations after cleaning: %w", err)
+	names := make([]string, 0, len(q.migrations))
+	for _, set := range sets {
+		names = append(names, bySet[set]...)
 	}
+	return append(names, bySet[""]...), nil
+}
 
-	log.Println("✅ Fresh migration completed successfully")
-	return nil
+// Set migration files directory.
+func (q *GoMigration) SetMigrationFilesDir(dir string) *GoMigration {
+	q.migrationFilesDir = dir
+	return q
 }
 
-// Reset rolls back all applied migrations and reapplies them from scratch.
-func (q *GoMigration) Reset(ctx context.Context) error {
-	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, true)
-	if err != nil {
-		return fmt.Errorf("failed to get executed migrations: %w", err)
+// LoadFromDir registers the SQL migrations of the migration files directory:
+// every <name>.up.sql file is registered as the migration <name>, with the
+// contents of <name>.down.sql as its down script, or none when that file does
+// not exist. A down script without its up script is an error.
+//
+// Both scripts can also live in a single <name>.sql file, the up script
+// following a -- +migrate Up line and the down script a -- +migrate Down
+// line. goose's -- +goose Up and -- +goose Down annotations are recognized
+// too, and a -- +migrate notransaction or -- +goose NO TRANSACTION line runs
+// the migration outside of a transaction. Go migration files and other files
+// in the directory are ignored.
+func (q *GoMigration) LoadFromDir() error {
+	return q.loadFromFS(os.DirFS(q.migrationFilesDir), ".", q.migrationFilesDir, q.migrationFilesDir)
+}
+
+// LoadFromFS registers the SQL migrations of the root directory of fsys like
+// LoadFromDir does, so migrations embedded with go:embed are registered from
+// the binary itself:
+//
+//	//go:embed migrations/*.sql
+//	var migrationFiles embed.FS
+//
+//	err := q.LoadFromFS(migrationFiles, "migrations")
+func (q *GoMigration) LoadFromFS(fsys fs.FS, root string) error {
+	if fsys == nil {
+		return ErrEmbeddedFSNotProvided
 	}
+	if root == "" {
+		root = "."
+	}
+	return q.loadFromFS(fsys, root, root, "")
+}
 
-	if len(executedMigrations) == 0 {
-		log.Println("✅ No migrations to reset")
-		return nil
+// loadFromFS registers the SQL migrations of the root directory of fsys,
+// recording them as registered from their path under location, and remembers
+// the directory for Reload. dir is the directory on disk fsys reads, if any.
+func (q *GoMigration) loadFromFS(fsys fs.FS, root, location, dir string) error {
+	source := fileSource{fsys: fsys, root: root, location: location, dir: dir}
+	migrations, registeredFrom, err := source.read()
+	if err != nil {
+		return err
 	}
 
-	log.Printf("🔁 Resetting %d executed migration(s)...\n", len(executedMigrations))
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	if err := q.Rollback(ctx, len(executedMigrations)); err != nil {
-		return fmt.Errorf("rollback failed during reset: %w", err)
+	if err := q.register("", migrations); err != nil {
+		return err
 	}
-
-	if err := q.Migrate(ctx); err != nil {
-		return fmt.Errorf("migration failed during reset: %w", err)
+	for name, file := range registeredFrom {
+		q.registeredFrom[name] = file
 	}
+	source.names = slices.Sorted(maps.Keys(registeredFrom))
+	q.fileSources = append(q.fileSources, source)
 
-	log.Println("✅ Migration reset completed successfully")
 	return nil
 }
 
-// Rollback undoes the last `step` number of executed migrations.
-func (q *GoMigration) Rollback(ctx context.Context, step int) error {
-	if step <= 0 {
-		return ErrInvalidRollbackStep
-	}
+// fileSource is a directory of SQL migrations registered by LoadFromDir or
+// LoadFromFS.
+type fileSource struct {
+	fsys     fs.FS
+	root     string
+	location string
+	// dir is the directory on disk the files are read from, empty for
+	// LoadFromFS.
+	dir string
+	// names are the migrations registered from the directory.
+	names []string
+}
 
-	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, true)
+// migrationFiles returns the files of the directory the migration name is
+// read from: its up and down scripts with their dialect variants, or its
+// annotated file.
+func (s fileSource) migrationFiles(name string) ([]string, error) {
+	entries, err := fs.ReadDir(s.fsys, s.root)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read migration directory: %w", err)
 	}
 
-	if len(executedMigrations) == 0 {
-		log.Println("✅ No migrations to rollback")
-		return nil
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		base, ok := strings.CutSuffix(entry.Name(), ".up.sql")
+		if !ok {
+			base, ok = strings.CutSuffix(entry.Name(), ".down.sql")
+		}
+		if ok {
+			base, _ = splitDialect(base)
+		} else if base, ok = strings.CutSuffix(entry.Name(), ".sql"); !ok {
+			continue
+		}
+		if base == name {
+			files = append(files, entry.Name())
+		}
 	}
+	return files, nil
+}
 
-	if step > len(executedMigrations) {
-		step = len(executedMigrations)
+// read parses the SQL migrations of the directory, returning them with the
+// file each one is registered from.
+func (s fileSource) read() ([]Migration, map[string]string, error) {
+	fsys, root, location := s.fsys, s.root, s.location
+	entries, err := fs.ReadDir(fsys, root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrMigrationDirNotExists, location)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read migration directory: %w", err)
 	}
 
-	migrationMap := make(map[string]Migration, len(q.migrations))
-	for _, m := range q.migrations {
-		migrationMap[m.Name()] = m
+	ups := make(map[string]bool)
+	downs := make(map[string]bool)
+	variantUps := make(map[string][]Dialect)
+	variantDowns := make(map[string][]Dialect)
+	var annotated []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if name, ok := strings.CutSuffix(entry.Name(), ".up.sql"); ok {
+			if name, dialect := splitDialect(name); dialect != "" {
+				variantUps[name] = append(variantUps[name], dialect)
+			} else {
+				ups[name] = true
+			}
+		} else if name, ok := strings.CutSuffix(entry.Name(), ".down.sql"); ok {
+			if name, dialect := splitDialect(name); dialect != "" {
+				variantDowns[name] = append(variantDowns[name], dialect)
+			} else {
+				downs[name] = true
+			}
+		} else if name, ok := strings.CutSuffix(entry.Name(), ".sql"); ok {
+			annotated = append(annotated, name)
+		}
 	}
 
-	migrationsToRollback := make([]Migration, 0, step)
-	for i := range step {
-		executedMigration := executedMigrations[i]
-		if migration, found := migrationMap[executedMigration.Name]; found {
-			migrationsToRollback = append(migrationsToRollback, migration)
-		} else {
-			log.Printf("⚠️  Migration not found for: %s\n", executedMigration.Name)
+	for name := range downs {
+		if !ups[name] && len(variantUps[name]) == 0 {
+			return nil, nil, fmt.Errorf("%w: %s.up.sql", ErrMigrationFileNotFound, path.Join(location, name))
+		}
+	}
+	for name, downDialects := range variantDowns {
+		for _, dialect := range downDialects {
+			if !ups[name] && !slices.Contains(variantUps[name], dialect) {
+				return nil, nil, fmt.Errorf("%w: %s.%s.up.sql", ErrMigrationFileNotFound, path.Join(location, name), dialect)
+			}
 		}
 	}
 
-	if len(migrationsToRollback) == 0 {
-		log.Println("✅ No migrations to rollback")
-		return nil
+	files := make(map[string]string, len(ups)+len(variantUps))
+	for name := range ups {
+		files[name] = name + ".up.sql"
+	}
+	for name, upDialects := range variantUps {
+		if !ups[name] {
+			files[name] = fmt.Sprintf("%s.%s.up.sql", name, slices.Min(upDialects))
+		}
 	}
 
-	log.Printf("🔁 Rolling back %d migration(s)...\n", len(migrationsToRollback))
+	readScript := func(file string) (string, error) {
+		script, err := fs.ReadFile(fsys, path.Join(root, file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		return string(script), nil
+	}
 
-	return q.driver.UnapplyMigrations(
-		ctx,
-		migrationsToRollback,
-		func(m *Migration) {
-			log.Printf("🔄 Rolling back: %s\n", (*m).Name())
-			if q.debugSql {
-				log.Println("🧾 Running SQL:")
-				fmt.Println("================================================")
-				fmt.Println((*m).DownScript())
-				fmt.Println("================================================")
+	names := slices.Sorted(maps.Keys(files))
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		migration := scriptMigration{name: name}
+		if ups[name] {
+			if migration.upScript, err = readScript(name + ".up.sql"); err != nil {
+				return nil, nil, err
 			}
-		},
-		func(m *Migration) {
-			log.Printf("✅ Rolled back: %s\n", (*m).Name())
-		},
-		func(m *Migration, err error) {
-			log.Printf("❌ Rollback failed: %s - %s\n", (*m).Name(), err)
-		},
-	)
-}
+		}
+		if downs[name] {
+			if migration.downScript, err = readScript(name + ".down.sql"); err != nil {
+				return nil, nil, err
+			}
+		}
 
-// Clean drops all database tables and objects managed by the migration system.
-func (q *GoMigration) Clean(ctx context.Context) error {
-	log.Println("🧹 Cleaning database...")
+		for _, dialect := range dialects {
+			hasUp := slices.Contains(variantUps[name], dialect)
+			hasDown := slices.Contains(variantDowns[name], dialect)
+			if !hasUp && !hasDown {
+				continue
+			}
+			// A variant of one script runs with the other script of the
+			// migration.
+			variant := scriptVariant{upScript: migration.upScript, downScript: migration.downScript}
+			if hasUp {
+				if variant.upScript, err = readScript(fmt.Sprintf("%s.%s.up.sql", name, dialect)); err != nil {
+					return nil, nil, err
+				}
+			}
+			if hasDown {
+				if variant.downScript, err = readScript(fmt.Sprintf("%s.%s.down.sql", name, dialect)); err != nil {
+					return nil, nil, err
+				}
+			}
+			if migration.variants == nil {
+				migration.variants = make(map[Dialect]scriptVariant)
+			}
+			migration.variants[dialect] = variant
+		}
 
-	if err := q.driver.CleanDatabase(ctx); err != nil {
-		return fmt.Errorf("failed to clean database: %w", err)
+		migrations = append(migrations, migration)
 	}
 
-	log.Println("✅ Database cleaned successfully")
-	return nil
-}
-
-// List returns all registered migrations along with their execution status.
-func (q *GoMigration) List(ctx context.Context) (RegisteredMigrationList, error) {
-	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
-		return nil, err
+	for _, name := range annotated {
+		content, err := fs.ReadFile(fsys, path.Join(root, name+".sql"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		migration, err := parseAnnotatedMigration(name, string(content))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s.sql", err, path.Join(location, name))
+		}
+		migrations = append(migrations, migration)
 	}
 
-	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, false)
-	if err != nil {
-		return nil, err
+	registeredFrom := make(map[string]string, len(migrations))
+	for _, name := range names {
+		registeredFrom[name] = path.Join(location, files[name])
 	}
-
-	executedMap := make(map[string]struct {
-		Executed   bool
-		ExecutedAt *time.Time
-	}, len(executedMigrations))
-
-	for _, m := range executedMigrations {
-		executedMap[m.Name] = struct {
-			Executed   bool
-			ExecutedAt *time.Time
-		}{
-			Executed:   true,
-			ExecutedAt: &m.ExecutedAt,
-		}
+	for _, name := range annotated {
+		registeredFrom[name] = path.Join(location, name+".sql")
+	}
+
+	return migrations, registeredFrom, nil
+}
+
+// Create generates a new migration file using the given name.
+// The name is prefixed by Config.MigrationIDGenerator: a timestamp by default,
+// or the next number with NamingSequential.
+// The file holds basic template content, with the scripts of
+// Config.CreateTemplates if set.
+func (q *GoMigration) Create(fileName string) error {
+	return q.snapshot().createMigrationFile(fileName, "", "", createGoFile)
+}
+
+// CreateGo generates a new Go migration file named like Create does, whose
+// init function registers the migration with the package-level Register, for
+// teams writing their migrations in Go. Importing the migrations package and
+// calling RegisterGlobal registers every such migration at once.
+func (q *GoMigration) CreateGo(fileName string) error {
+	return q.snapshot().createMigrationFile(fileName, "", "", createRegisteredGoFile)
+}
+
+// CreateSQL generates a new SQL migration file named like Create does, with
+// empty -- +migrate Up and -- +migrate Down sections, for migrations
+// registered with LoadFromDir or LoadFromFS.
+func (q *GoMigration) CreateSQL(fileName string) error {
+	return q.snapshot().createMigrationFile(fileName, "", "", createSQLFile)
+}
+
+// Diff compares the CREATE TABLE statements of the schema file read from
+// schema against the live database and generates a new migration, named like
+// Create does, creating the missing tables and columns. Its down script drops
+// them again. Tables and columns found only in the database are listed as
+// comments, and column types and constraints are not compared, so the
+// migration must be reviewed before it is applied. No file is generated when
+// the database already has everything the schema describes.
+//
+// The driver must implement SchemaInspector, which the built-in drivers do.
+func (q *GoMigration) Diff(ctx context.Context, fileName string, schema io.Reader) error {
+	q = q.snapshot()
+
+	inspector, ok := q.driver.(SchemaInspector)
+	if !ok {
+		return ErrSchemaDiffNotSupported
+	}
+
+	if !migrationDirExists(q.migrationFilesDir) {
+		return fmt.Errorf("migration directory %q does not exist", q.migrationFilesDir)
+	}
+
+	target, err := ParseSchema(schema)
+	if err != nil {
+		return fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	live, err := inspector.InspectSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	diff := diffSchema(target, live)
+	if diff.empty() {
+		q.logger.info("✅ Database already matches the schema, no migration created", "database already matches the schema, no migration created")
+		return nil
+	}
+
+	return q.createMigrationFile(fileName, diff.upScript(), diff.downScript(), createGoFile)
+}
+
+// createMigrationFile writes a new migration file of kind named after fileName
+// with the given scripts, or those of the create templates when both are
+// empty, and adds it to the migration order file, if any.
+func (q *GoMigration) createMigrationFile(fileName, upScript, downScript string, kind createKind) error {
+	if !migrationDirExists(q.migrationFilesDir) {
+		return fmt.Errorf("migration directory %q does not exist", q.migrationFilesDir)
+	}
+
+	if fileName == "" {
+		return ErrMigrationNameNotProvided
+	}
+
+	migrationName, err := sanitizeMigrationName(fileName)
+	if err != nil {
+		return err
+	}
+
+	generateID := q.migrationID
+	if generateID == nil {
+		generateID = TimestampID
+	}
+	now := time.Now()
+	var id string
+	if q.namingStrategy == NamingSequential {
+		names, err := q.existingMigrationNames()
+		if err != nil {
+			return err
+		}
+		id, err = nextSequentialID(names)
+		if err != nil {
+			return err
+		}
+	} else {
+		id, err = generateID(now)
+		if err != nil {
+			return fmt.Errorf("failed to generate migration ID: %w", err)
+		}
+	}
+
+	migrationName = fmt.Sprintf("%s_%s", id, migrationName)
+	if upScript == "" && downScript == "" {
+		upScript, downScript, err = q.createTemplates.render(CreateTemplateData{Name: migrationName, ID: id, Timestamp: now, Author: q.appliedBy})
+		if err != nil {
+			return err
+		}
+	}
+	migrationFileName := fmt.Sprintf("%s/%s.go", q.migrationFilesDir, migrationName)
+	if kind == createSQLFile {
+		migrationFileName = fmt.Sprintf("%s/%s.sql", q.migrationFilesDir, migrationName)
+	}
+
+	if fileExists(migrationFileName) {
+		return ErrMigrationFileAlreadyExists
+	}
+
+	template := annotatedMigrationTemplate(upScript, downScript)
+	if kind != createSQLFile {
+		template, err = migrationFileTemplate(getPackageNameFromMigrationDir(q.migrationFilesDir), migrationName, upScript, downScript, kind == createRegisteredGoFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.WriteFile(migrationFileName, []byte(template), 0644)
+	if err != nil {
+		return err
+	}
+	q.logger.info("migration file created: "+migrationFileName, "migration file created", "file", migrationFileName)
+
+	if q.migrationOrderFile != "" {
+		if err := appendMigrationOrder(q.migrationOrderFile, migrationName); err != nil {
+			return fmt.Errorf("failed to add %s to migration order file: %w", migrationName, err)
+		}
+		q.logger.info("migration added to order file: "+q.migrationOrderFile, "migration added to order file", "file", q.migrationOrderFile)
+	}
+
+	return nil
+}
+
+// existingMigrationNames returns the names of the registered migrations and of
+// the files of the migration files directory, without extensions.
+func (q *GoMigration) existingMigrationNames() ([]string, error) {
+	entries, err := os.ReadDir(q.migrationFilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration directory: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			name, _, _ := strings.Cut(entry.Name(), ".")
+			add(name)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, name := range getSortedMigrationName(q.migrations) {
+		add(name)
+	}
+	return names, nil
+}
+
+// Migrate applies all pending migrations in the correct order.
+// It skips migrations that have already been executed. The driver's migration
+// lock is held for the whole run so concurrent processes cannot race.
+//
+// WithPhase restricts the run to the migrations of a phase, so an
+// expand/contract rollout applies its PreDeploy migrations before deploying
+// the new code and its PostDeploy ones after. Such a run fails with
+// ErrDependencyNotExecuted when one of them depends on a pending migration of
+// the other phase.
+func (q *GoMigration) Migrate(ctx context.Context, opts ...MigrateOption) error {
+	q = q.snapshot()
+
+	var options migrateOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return q.withLock(ctx, func() error {
+		return q.migrate(ctx, options)
+	})
+}
+
+// MigrateSteps applies at most n pending migrations in the correct order, e.g.
+// one at a time during canary deploys. The driver's migration lock is held for
+// the whole run.
+func (q *GoMigration) MigrateSteps(ctx context.Context, n int) error {
+	q = q.snapshot()
+
+	if n < 1 {
+		return ErrInvalidMigrateStep
+	}
+
+	return q.withLock(ctx, func() error {
+		if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		migrationsToApply, err := q.pendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n < len(migrationsToApply) {
+			migrationsToApply = migrationsToApply[:n]
+		}
+		return q.apply(ctx, migrationsToApply)
+	})
+}
+
+// migrate applies all pending migrations selected by options without taking
+// the migration lock.
+func (q *GoMigration) migrate(ctx context.Context, options migrateOptions) error {
+	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	migrationsToApply, err := q.pendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if options.selective() {
+		selected, err := selectMigrations(migrationsToApply, options)
+		if err != nil {
+			return err
+		}
+		if deferred := len(migrationsToApply) - len(selected); deferred > 0 {
+			q.logger.info(fmt.Sprintf("⏭️  Leaving %d migration(s) out of this run", deferred), "leaving migrations out of this run", "count", deferred)
+		}
+		migrationsToApply = selected
+	}
+
+	if err := q.apply(ctx, migrationsToApply); err != nil {
+		return err
+	}
+
+	// Migrations left out of the run may still be pending.
+	if !options.selective() {
+		q.rememberManifest(ctx)
+	}
+	return nil
+}
+
+// selectMigrations returns the migrations to apply that options select, in
+// order. It fails if one of them depends on a migration to apply that options
+// leave out.
+func selectMigrations(migrationsToApply []Migration, options migrateOptions) ([]Migration, error) {
+	if options.phase != "" && options.phase != PreDeploy && options.phase != PostDeploy {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPhase, options.phase)
+	}
+
+	deferred := make(map[string]string)
+	var selected []Migration
+	for _, m := range migrationsToApply {
+		if reason := options.exclusion(m); reason != "" {
+			deferred[m.Name()] = reason
+		} else {
+			selected = append(selected, m)
+		}
+	}
+
+	var missing []string
+	for _, m := range selected {
+		for _, dependency := range migrationDependencies(m) {
+			if reason, ok := deferred[dependency]; ok {
+				missing = append(missing, fmt.Sprintf("%s (needed by %s, %s)", dependency, m.Name(), reason))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyNotExecuted, strings.Join(missing, ", "))
+	}
+	return selected, nil
+}
+
+// apply runs the given migrations in order, logging, emitting events and
+// running the hooks of the run.
+func (q *GoMigration) apply(ctx context.Context, migrationsToApply []Migration) (err error) {
+	run := q.startRun(ctx, OperationMigrate, len(migrationsToApply))
+	if len(migrationsToApply) == 0 {
+		q.logger.info("✅ No migrations to run", "no migrations to run")
+		return run.finish(nil)
+	}
+
+	var applied []string
+	failed := ""
+	defer func() { q.runAfterHooks(ctx, applied, failed, err) }()
+
+	if err := q.checkEmptyScripts(migrationsToApply); err != nil {
+		return run.finish(err)
+	}
+	if err := q.checkGates(ctx, migrationsToApply); err != nil {
+		return run.finish(err)
+	}
+	if err := q.checkServerRequirements(ctx, migrationsToApply); err != nil {
+		return run.finish(err)
+	}
+	if err := q.runBeforeHooks(ctx, migrationNames(migrationsToApply)); err != nil {
+		return run.finish(err)
+	}
+
+	q.logger.info(fmt.Sprintf("🚀 Applying %d migration(s)...", len(migrationsToApply)), "applying migrations", "count", len(migrationsToApply))
+
+	// Cancelling ctx stops the run between migrations rather than in the
+	// middle of one, which could leave it applied but not recorded.
+	guard := newInterruptGuard(ctx)
+	defer guard.stop()
+
+	started := false
+	err = q.driver.ApplyMigrations(
+		guard.ctx,
+		migrationsToApply,
+		func(m *Migration) {
+			if started = guard.begin(); !started {
+				return
+			}
+			run.migrationStartedEvent(*m)
+			q.logger.info("📦 Migrating: "+(*m).Name(), "migrating", "migration", (*m).Name())
+			q.logger.sql(q.debugSql, q.upScript(*m), "migration", (*m).Name())
+		},
+		func(m *Migration) {
+			guard.end()
+			applied = append(applied, (*m).Name())
+			run.migrationSucceededEvent(*m)
+			q.logger.info("✅ Migrated: "+(*m).Name(), "migrated", "migration", (*m).Name())
+		},
+		func(m *Migration, err error) {
+			if !started {
+				return
+			}
+			guard.end()
+			failed = (*m).Name()
+			run.migrationFailedEvent(*m, err)
+			q.logger.error(fmt.Sprintf("❌ Migration failed: %s - %s", (*m).Name(), err), "migration failed", "migration", (*m).Name(), "error", err)
+		},
+	)
+	auditCtx := ctx
+	// A migration stopped by the deadline of ctx failed with a *DeadlineError,
+	// which is reported as is.
+	var deadlineErr *DeadlineError
+	if guard.wasInterrupted() || (err != nil && ctx.Err() != nil && !errors.As(err, &deadlineErr)) {
+		interrupted := &InterruptedError{Applied: applied, Err: context.Cause(ctx)}
+		for _, m := range migrationsToApply {
+			if !slices.Contains(applied, m.Name()) {
+				interrupted.Remaining = append(interrupted.Remaining, m.Name())
+			}
+		}
+		q.logger.warn(fmt.Sprintf("🛑 Interrupted after applying %d of %d migration(s)", len(applied), len(migrationsToApply)), "interrupted", "applied", len(applied), "total", len(migrationsToApply))
+		err = interrupted
+		// The outcome of the run is still worth recording.
+		auditCtx = context.WithoutCancel(ctx)
+	}
+	q.audit(auditCtx, OperationMigrate, migrationNames(migrationsToApply), err)
+	return run.finish(err)
+}
+
+// MigrateTo brings the database to the state right after the named migration:
+// pending migrations up to and including it are applied, and migrations
+// ordered after it that have been applied are rolled back, most recent first.
+// The driver's migration lock is held for the whole run.
+func (q *GoMigration) MigrateTo(ctx context.Context, targetName string) error {
+	q = q.snapshot()
+
+	if targetName == "" {
+		return ErrMigrationNameNotProvided
+	}
+	if _, registered := q.migrations[targetName]; !registered {
+		return fmt.Errorf("%w: %s", ErrMigrationNotRegistered, targetName)
+	}
+
+	return q.withLock(ctx, func() error {
+		if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		pending, err := q.pendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		names, err := q.orderedMigrationNames()
+		if err != nil {
+			return err
+		}
+		position := make(map[string]int, len(names))
+		for i, name := range names {
+			position[name] = i
+		}
+		target := position[targetName]
+
+		executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderAppliedDesc)
+		if err != nil {
+			return err
+		}
+
+		var ahead []ExecutedMigration
+		kept := make(map[string]struct{}, len(executedMigrations))
+		for _, m := range executedMigrations {
+			if i, registered := position[m.Name]; registered && i > target {
+				ahead = append(ahead, m)
+			} else {
+				kept[m.Name] = struct{}{}
+			}
+		}
+
+		migrationsToApply := make([]Migration, 0, len(pending))
+		for _, m := range pending {
+			if position[m.Name()] <= target {
+				migrationsToApply = append(migrationsToApply, m)
+			}
+		}
+		if _, err := orderByDependencies(migrationsToApply, kept); err != nil {
+			return err
+		}
+
+		if len(ahead) > 0 {
+			if err := q.unapply(ctx, q.registeredFor(ahead)); err != nil {
+				return err
+			}
+		}
+		if len(migrationsToApply) == 0 && len(ahead) > 0 {
+			return nil
+		}
+		return q.apply(ctx, migrationsToApply)
+	})
+}
+
+// MigrateDryRun prints the names and SQL of the migrations Migrate would apply
+// without changing the database.
+func (q *GoMigration) MigrateDryRun(ctx context.Context) error {
+	q = q.snapshot()
+
+	migrationsToApply, err := q.pendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(migrationsToApply) == 0 {
+		q.logger.info("✅ No migrations to run", "no migrations to run")
+		return nil
+	}
+
+	q.logger.info(fmt.Sprintf("🔍 Dry run: %d migration(s) would be applied", len(migrationsToApply)), "dry run, migrations would be applied", "count", len(migrationsToApply))
+	for _, m := range migrationsToApply {
+		script, err := q.renderScript(m.Name(), q.upScript(m))
+		if err != nil {
+			return err
+		}
+		q.logger.info("📦 Would migrate: "+m.Name(), "would migrate", "migration", m.Name())
+		printScript(script)
+	}
+
+	return nil
+}
+
+// renderScript returns the script of migration as the built-in drivers execute
+// it, with Config.TemplateData and Config.EnvExpansion applied.
+func (q *GoMigration) renderScript(migration, script string) (string, error) {
+	script, err := renderScript(migration, script, q.templateData)
+	if err != nil {
+		return "", err
+	}
+	return expandEnv(migration, script, q.envExpansion)
+}
+
+// dialect returns the dialect of the driver, empty for drivers other than the
+// built-in ones.
+func (q *GoMigration) dialect() Dialect {
+	if d, ok := q.driver.(dialectDriver); ok {
+		return d.dialect()
+	}
+	return ""
+}
+
+// upScript returns the up script of m as the driver runs it: its variant for
+// the dialect of the driver if it has one.
+func (q *GoMigration) upScript(m Migration) string {
+	upScript, _ := dialectScripts(m, q.dialect())
+	return upScript
+}
+
+// downScript returns the down script of m as the driver runs it.
+func (q *GoMigration) downScript(m Migration) string {
+	_, downScript := dialectScripts(m, q.dialect())
+	return downScript
+}
+
+// Plan writes the SQL of all pending migrations to w, each followed by the
+// INSERT that records it in the tracking table, so the plan can be executed
+// manually by someone with DDL rights. When the built-in driver finds no
+// tracking table, the plan starts by creating it with its indexes. The
+// database is only read.
+func (q *GoMigration) Plan(ctx context.Context, w io.Writer) error {
+	q = q.snapshot()
+
+	migrationsToApply, err := q.pendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	executedMigrations, err := q.executedMigrations(ctx, HistoryOrderApplied)
+	if err != nil {
+		return err
+	}
+	batch := latestBatch(executedMigrations) + 1
+	appliedBy := "NULL"
+	if q.appliedBy != "" {
+		appliedBy = quoteSQLString(q.appliedBy)
+	}
+	build := "NULL"
+	if q.build != nil {
+		build = quoteSQLString(nullableBuild(q.build).String)
+	}
+	table := q.migrationTableName
+	if d, ok := q.driver.(tableQuotingDriver); ok {
+		table = d.quotedTableName()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Migration plan generated by gomigration at %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "-- %d pending migration(s)\n", len(migrationsToApply))
+
+	if d, ok := q.driver.(trackingTableDriver); ok && q.trackingTableMissing(ctx) {
+		b.WriteString("\n-- Tracking table\n")
+		for _, statement := range d.trackingTableDDL() {
+			b.WriteString(statement + ";\n")
+		}
+	}
+
+	for _, m := range migrationsToApply {
+		namespace := "NULL"
+		if set := migrationSetOf(m); set != "" {
+			namespace = quoteSQLString(set)
+		}
+
+		script, err := q.renderScript(m.Name(), q.upScript(m))
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&b, "\n-- Migration: %s\n", m.Name())
+		if isConditional(m) {
+			b.WriteString("-- Conditional: Migrate skips it when its ShouldRun returns false\n")
+		}
+		if script := strings.TrimSpace(script); script != "" {
+			b.WriteString(script)
+			if !strings.HasSuffix(script, ";") {
+				b.WriteString(";")
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(
+			&b,
+			"INSERT INTO %s (name, executed_at, checksum, batch, applied_by, namespace, build) VALUES (%s, CURRENT_TIMESTAMP, %s, %d, %s, %s, %s);\n",
+			table,
+			quoteSQLString(m.Name()),
+			quoteSQLString(migrationChecksum(m)),
+			batch,
+			appliedBy,
+			namespace,
+			build,
+		)
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// Ping reports whether the database is reachable, for liveness and readiness
+// probes. Drivers implementing Pinger are pinged; for the others, the first
+// executed migration is read from the tracking table instead.
+func (q *GoMigration) Ping(ctx context.Context) error {
+	q = q.snapshot()
+
+	if p, ok := q.driver.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	_, err := q.driver.GetExecutedMigrationsPage(ctx, HistoryOrderApplied, 1, 0)
+	return err
+}
+
+// IsUpToDate reports whether every registered migration has been executed, so
+// a readiness probe can refuse traffic until the schema matches the binary.
+// Like HasPending, it returns without reading the history when the stored
+// manifest hash matches the registered migrations, and fails when applied
+// scripts were edited. Unlike it, it only reads: the tracking table is not
+// created and no hash is stored, so probes can run with read-only credentials.
+// A reachable database without a tracking table, never migrated, is reported
+// as not up to date rather than failing like an unreachable one.
+// Executed migrations this binary does not know of, applied by a newer
+// release during a rolling deploy, do not make it report false.
+func (q *GoMigration) IsUpToDate(ctx context.Context) (bool, error) {
+	q = q.snapshot()
+
+	if store, ok := q.driver.(ManifestStore); ok {
+		if stored, err := store.GetManifestHash(ctx); err == nil && stored != "" && stored == manifestHash(q.migrations) {
+			return true, nil
+		}
+	}
+
+	migrationsToApply, err := q.pendingMigrations(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(migrationsToApply) == 0, nil
+}
+
+// HasPending reports whether any registered migration has not been executed
+// yet. It is meant for boot-time checks: when the manifest hash stored by the
+// last full comparison matches the registered migrations, the history is not
+// read at all. Otherwise the history is compared in full, failing like Migrate
+// when applied scripts were edited, and the hash is stored if nothing is
+// pending. Every operation that takes the migration lock clears the hash.
+func (q *GoMigration) HasPending(ctx context.Context) (bool, error) {
+	q = q.snapshot()
+
+	if store, ok := q.driver.(ManifestStore); ok {
+		// A missing or unreadable hash just means taking the slow path.
+		if stored, err := store.GetManifestHash(ctx); err == nil && stored != "" && stored == manifestHash(q.migrations) {
+			return false, nil
+		}
+	}
+
+	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+		return false, err
+	}
+
+	migrationsToApply, err := q.pendingMigrations(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(migrationsToApply) > 0 {
+		return true, nil
+	}
+
+	q.rememberManifest(ctx)
+	return false, nil
+}
+
+// rememberManifest stores the manifest hash of the registered migrations once
+// all of them are known to be executed. Failures only cost the fast path of
+// HasPending, so they are logged rather than returned.
+func (q *GoMigration) rememberManifest(ctx context.Context) {
+	store, ok := q.driver.(ManifestStore)
+	if !ok {
+		return
+	}
+	if err := store.SetManifestHash(ctx, manifestHash(q.migrations)); err != nil {
+		q.logger.warn(fmt.Sprintf("⚠️  Failed to store manifest hash: %s", err), "failed to store manifest hash", "error", err)
+	}
+}
+
+// forgetManifest removes the stored manifest hash before the history changes.
+func (q *GoMigration) forgetManifest(ctx context.Context) error {
+	store, ok := q.driver.(ManifestStore)
+	if !ok {
+		return nil
+	}
+	if err := store.SetManifestHash(ctx, ""); err != nil {
+		return fmt.Errorf("failed to clear manifest hash: %w", err)
+	}
+	return nil
+}
+
+// executedMigrations returns the executed migrations in order. A tracking
+// table the driver reports as not created yet, on a database never migrated,
+// reads as an empty history, so operations that only read need not create it.
+func (q *GoMigration) executedMigrations(ctx context.Context, order HistoryOrder) ([]ExecutedMigration, error) {
+	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, order)
+	if err == nil {
+		return executedMigrations, nil
+	}
+	if q.trackingTableMissing(ctx) {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// trackingTableMissing reports whether the driver knows the tracking table has
+// not been created yet.
+func (q *GoMigration) trackingTableMissing(ctx context.Context) bool {
+	d, ok := q.driver.(trackingTableDriver)
+	if !ok {
+		return false
+	}
+	exists, err := d.trackingTableExists(ctx)
+	return err == nil && !exists
+}
+
+// pendingMigrations returns the registered migrations that have not been
+// executed yet, in the order they should be applied.
+func (q *GoMigration) pendingMigrations(ctx context.Context) ([]Migration, error) {
+	executedMigrations, err := q.executedMigrations(ctx, HistoryOrderApplied)
+	if err != nil {
+		return nil, err
+	}
+
+	executedMap := make(map[string]struct{}, len(executedMigrations))
+	var edited []string
+	for _, m := range executedMigrations {
+		executedMap[m.Name] = struct{}{}
+		if q.editedSinceApplied(m) {
+			edited = append(edited, m.Name)
+		}
+	}
+	if err := checksumMismatchError(edited); err != nil {
+		return nil, err
+	}
+
+	names, err := q.orderedMigrationNames()
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsToApply := make([]Migration, 0, len(q.migrations))
+	// Migration sets, and the phases within them, are tracked separately: the
+	// service's own migrations are the set "", and PostDeploy migrations are
+	// left pending while later PreDeploy ones are applied.
+	type stream struct {
+		set   string
+		phase Phase
+	}
+	streamOf := func(m Migration) stream { return stream{migrationSetOf(m), migrationPhase(m)} }
+	var streams []stream
+	lastExecuted := make(map[stream]string)
+	for _, name := range names {
+		migration := q.migrations[name]
+		s := streamOf(migration)
+		if _, seen := lastExecuted[s]; !seen {
+			streams = append(streams, s)
+			lastExecuted[s] = ""
+		}
+		if _, found := executedMap[migration.Name()]; !found {
+			migrationsToApply = append(migrationsToApply, migration)
+		} else {
+			lastExecuted[s] = name
+		}
+	}
+	// Pending migrations ordered before the last executed one of their set
+	// and phase are out of order.
+	for _, s := range streams {
+		last := lastExecuted[s]
+		if last == "" {
+			continue
+		}
+		var outOfOrder []string
+		for _, name := range names {
+			if name == last {
+				break
+			}
+			if _, found := executedMap[name]; !found && streamOf(q.migrations[name]) == s {
+				outOfOrder = append(outOfOrder, name)
+			}
+		}
+		if err := q.checkOutOfOrder(outOfOrder, last); err != nil {
+			return nil, err
+		}
+	}
+
+	q.metrics.setPending(len(migrationsToApply))
+	return orderByDependencies(migrationsToApply, executedMap)
+}
+
+// orderByDependencies orders the migrations to apply after the ones they
+// depend on. It fails if a dependency is neither executed nor about to be
+// applied, or if migrations depend on each other.
+func orderByDependencies(migrationsToApply []Migration, executed map[string]struct{}) ([]Migration, error) {
+	applying := make(map[string]bool, len(migrationsToApply))
+	for _, m := range migrationsToApply {
+		applying[m.Name()] = true
+	}
+
+	var missing []string
+	for _, m := range migrationsToApply {
+		for _, dependency := range migrationDependencies(m) {
+			if _, found := executed[dependency]; !found && !applying[dependency] {
+				missing = append(missing, fmt.Sprintf("%s (needed by %s)", dependency, m.Name()))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyNotExecuted, strings.Join(missing, ", "))
+	}
+
+	sorted, cyclic := sortByDependencies(migrationsToApply)
+	if len(cyclic) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cyclic, ", "))
+	}
+	return sorted, nil
+}
+
+// checkOutOfOrder applies the out-of-order policy to the pending migrations
+// ordered before lastExecuted, the last executed migration.
+func (q *GoMigration) checkOutOfOrder(outOfOrder []string, lastExecuted string) error {
+	if len(outOfOrder) == 0 {
+		return nil
+	}
+
+	switch q.outOfOrderPolicy {
+	case OutOfOrderFail:
+		return fmt.Errorf(
+			"%w: %s (ordered before executed %s)",
+			ErrOutOfOrderMigration,
+			strings.Join(outOfOrder, ", "),
+			lastExecuted,
+		)
+	case OutOfOrderWarn:
+		q.logger.warn(fmt.Sprintf("⚠️ Applying out of order, before executed %s: %s", lastExecuted, strings.Join(outOfOrder, ", ")), "applying out of order", "last_executed", lastExecuted, "migrations", outOfOrder)
+	}
+	return nil
+}
+
+// checkEmptyScripts applies the empty script policy to the migrations about
+// to be applied.
+func (q *GoMigration) checkEmptyScripts(migrations []Migration) error {
+	if q.emptyScriptPolicy == EmptyScriptAllow {
+		return nil
+	}
+
+	var empty []string
+	for _, m := range migrations {
+		if isEmptyScript(q.upScript(m)) {
+			empty = append(empty, m.Name())
+		}
+	}
+	if len(empty) == 0 {
+		return nil
+	}
+
+	if q.emptyScriptPolicy == EmptyScriptFail {
+		return fmt.Errorf("%w: %s", ErrEmptyUpScript, strings.Join(empty, ", "))
+	}
+	q.logger.warn("⚠️ Applying migrations with an empty up script: "+strings.Join(empty, ", "), "applying migrations with an empty up script", "migrations", empty)
+	return nil
+}
+
+// checkGates fails with ErrMigrationGateClosed if the gate of a
+// GatedMigration about to be applied is closed. The history is only read
+// when a gate names a migration that must have been applied.
+func (q *GoMigration) checkGates(ctx context.Context, migrations []Migration) error {
+	var executed map[string]bool
+	var closed []string
+	for _, m := range migrations {
+		gated, ok := m.(GatedMigration)
+		if !ok {
+			continue
+		}
+		gate := gated.Gate()
+
+		if gate.After != "" {
+			if executed == nil {
+				executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+				if err != nil {
+					return err
+				}
+				executed = make(map[string]bool, len(executedMigrations))
+				for _, e := range executedMigrations {
+					executed[e.Name] = true
+				}
+			}
+			if !executed[gate.After] {
+				closed = append(closed, fmt.Sprintf("%s (needs %s applied by an earlier run)", m.Name(), gate.After))
+			}
+		}
+
+		if gate.MinVersion != "" {
+			version := ""
+			if q.build != nil {
+				version = q.build.Version
+			}
+			if reason := versionBelow(version, gate.MinVersion); reason != "" {
+				closed = append(closed, fmt.Sprintf("%s (needs application version %s or later, %s)", m.Name(), gate.MinVersion, reason))
+			}
+		}
+	}
+
+	if len(closed) > 0 {
+		return fmt.Errorf("%w: %s", ErrMigrationGateClosed, strings.Join(closed, ", "))
+	}
+	return nil
+}
+
+// editedSinceApplied reports whether the up script of a registered migration no
+// longer matches the checksum recorded when it was applied. Records without a
+// checksum predate checksum tracking and are trusted.
+func (q *GoMigration) editedSinceApplied(m ExecutedMigration) bool {
+	migration, registered := q.migrations[m.Name]
+	return registered && m.Checksum != "" && m.Checksum != migrationChecksum(migration)
+}
+
+// checksumMismatchError returns ErrChecksumMismatch naming the edited migrations,
+// or nil if there are none.
+func checksumMismatchError(edited []string) error {
+	if len(edited) == 0 {
+		return nil
+	}
+	return fmt.Errorf(
+		"%w: %s (restore the original scripts or run Repair to accept the edits)",
+		ErrChecksumMismatch,
+		strings.Join(edited, ", "),
+	)
+}
+
+// Fresh wipes the database clean and reapplies all registered migrations from scratch.
+func (q *GoMigration) Fresh(ctx context.Context) error {
+	return q.FreshWithOptions(ctx, CleanOptions{})
+}
+
+// FreshWithOptions is Fresh, cleaning the database with opts. With
+// opts.KeepHistory the tracking table is reconciled with the emptied database
+// rather than recreated: the records of registered migrations are removed, as
+// the objects they created are gone, so they are applied again, while the
+// records of migrations no longer registered stay as history. The audit log,
+// if enabled, lists the removed records with the clean.
+func (q *GoMigration) FreshWithOptions(ctx context.Context, opts CleanOptions) error {
+	q = q.snapshot()
+
+	if opts.KeepHistory {
+		if _, err := q.historyWriter(); err != nil {
+			return err
+		}
+	}
+
+	q.logger.info("🧹 Cleaning database...", "cleaning database")
+
+	err := q.cleanDatabase(ctx, opts)
+	var reset []string
+	if err == nil && opts.KeepHistory {
+		reset, err = q.reconcileHistory(ctx)
+	}
+	q.audit(ctx, OperationClean, reset, err)
+	if err != nil {
+		return fmt.Errorf("failed to clean database: %w", err)
+	}
+
+	q.logger.info("🚀 Running fresh migrations...", "running fresh migrations")
+
+	if err := q.Migrate(ctx); err != nil {
+		return fmt.Errorf("failed to run migrations after cleaning: %w", err)
+	}
+
+	q.logger.info("✅ Fresh migration completed successfully", "fresh migration completed")
+	return nil
+}
+
+// reconcileHistory removes the records of the registered migrations from a
+// tracking table kept by a clean and returns their names.
+func (q *GoMigration) reconcileHistory(ctx context.Context) ([]string, error) {
+	writer, err := q.historyWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	if err != nil {
+		return nil, err
+	}
+
+	var reset []string
+	for _, m := range executedMigrations {
+		if _, registered := q.migrations[m.Name]; !registered {
+			continue
+		}
+		if err := writer.RemoveExecutedMigration(ctx, m.Name); err != nil {
+			return reset, fmt.Errorf("failed to reset the record of %s: %w", m.Name, err)
+		}
+		reset = append(reset, m.Name)
+	}
+
+	q.logger.info(fmt.Sprintf("🗂️  Reset %d migration record(s), kept %d as history", len(reset), len(executedMigrations)-len(reset)), "reset migration records", "reset", len(reset), "kept", len(executedMigrations)-len(reset))
+	return reset, nil
+}
+
+// Reset rolls back all applied migrations and reapplies them from scratch.
+func (q *GoMigration) Reset(ctx context.Context) error {
+	q = q.snapshot()
+
+	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderAppliedDesc)
+	if err != nil {
+		return fmt.Errorf("failed to get executed migrations: %w", err)
+	}
+
+	if len(executedMigrations) == 0 {
+		q.logger.info("✅ No migrations to reset", "no migrations to reset")
+		return nil
+	}
+
+	q.logger.info(fmt.Sprintf("🔁 Resetting %d executed migration(s)...", len(executedMigrations)), "resetting executed migrations", "count", len(executedMigrations))
+
+	if err := q.Rollback(ctx, len(executedMigrations)); err != nil {
+		return fmt.Errorf("rollback failed during reset: %w", err)
+	}
+
+	if err := q.Migrate(ctx); err != nil {
+		return fmt.Errorf("migration failed during reset: %w", err)
+	}
+
+	q.logger.info("✅ Migration reset completed successfully", "migration reset completed")
+	return nil
+}
+
+// Rollback undoes the last `step` number of executed migrations while holding
+// the driver's migration lock.
+func (q *GoMigration) Rollback(ctx context.Context, step int) error {
+	q = q.snapshot()
+
+	if step <= 0 {
+		return ErrInvalidRollbackStep
+	}
+
+	return q.withLock(ctx, func() error {
+		migrationsToRollback, err := q.lastExecuted(ctx, step)
+		if err != nil {
+			return err
+		}
+		return q.unapply(ctx, migrationsToRollback)
+	})
+}
+
+// RollbackDryRun prints the names and SQL of the migrations Rollback would
+// undo without changing the database.
+func (q *GoMigration) RollbackDryRun(ctx context.Context, step int) error {
+	q = q.snapshot()
+
+	if step <= 0 {
+		return ErrInvalidRollbackStep
+	}
+
+	migrationsToRollback, err := q.lastExecuted(ctx, step)
+	if err != nil {
+		return err
+	}
+	return q.printRollbackDryRun(migrationsToRollback)
+}
+
+// RollbackLastBatch undoes every migration applied by the most recent Migrate
+// call, most recent first. Migrations recorded before batches were tracked, or
+// by MarkApplied, belong to no batch and are left alone.
+func (q *GoMigration) RollbackLastBatch(ctx context.Context) error {
+	q = q.snapshot()
+
+	return q.withLock(ctx, func() error {
+		migrationsToRollback, err := q.lastBatch(ctx)
+		if err != nil {
+			return err
+		}
+		return q.unapply(ctx, migrationsToRollback)
+	})
+}
+
+// RollbackLastBatchDryRun prints the names and SQL of the migrations
+// RollbackLastBatch would undo without changing the database.
+func (q *GoMigration) RollbackLastBatchDryRun(ctx context.Context) error {
+	q = q.snapshot()
+
+	migrationsToRollback, err := q.lastBatch(ctx)
+	if err != nil {
+		return err
+	}
+	return q.printRollbackDryRun(migrationsToRollback)
+}
+
+// RollbackTo undoes every migration applied after the named one, using the
+// recorded apply order. When inclusive is true the named migration is rolled
+// back as well. The driver's migration lock is held for the whole run.
+func (q *GoMigration) RollbackTo(ctx context.Context, name string, inclusive bool) error {
+	q = q.snapshot()
+
+	if name == "" {
+		return ErrMigrationNameNotProvided
+	}
+
+	return q.withLock(ctx, func() error {
+		migrationsToRollback, err := q.executedAfter(ctx, name, inclusive)
+		if err != nil {
+			return err
+		}
+		return q.unapply(ctx, migrationsToRollback)
+	})
+}
+
+// RollbackToDryRun prints the names and SQL of the migrations RollbackTo would
+// undo without changing the database.
+func (q *GoMigration) RollbackToDryRun(ctx context.Context, name string, inclusive bool) error {
+	q = q.snapshot()
+
+	if name == "" {
+		return ErrMigrationNameNotProvided
+	}
+
+	migrationsToRollback, err := q.executedAfter(ctx, name, inclusive)
+	if err != nil {
+		return err
+	}
+	return q.printRollbackDryRun(migrationsToRollback)
+}
+
+// lastExecuted returns the registered migrations for the last `step` executed
+// migrations, most recent first.
+func (q *GoMigration) lastExecuted(ctx context.Context, step int) ([]Migration, error) {
+	executedMigrations, err := q.executedMigrations(ctx, HistoryOrderAppliedDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	if step > len(executedMigrations) {
+		step = len(executedMigrations)
+	}
+
+	return q.registeredFor(executedMigrations[:step]), nil
+}
+
+// lastBatch returns the registered migrations of the most recent batch, most
+// recent first.
+func (q *GoMigration) lastBatch(ctx context.Context) ([]Migration, error) {
+	executedMigrations, err := q.executedMigrations(ctx, HistoryOrderAppliedDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := latestBatch(executedMigrations)
+	if batch == 0 {
+		return nil, nil
+	}
+
+	var inBatch []ExecutedMigration
+	for _, m := range executedMigrations {
+		if m.Batch == batch {
+			inBatch = append(inBatch, m)
+		}
+	}
+
+	return q.registeredFor(inBatch), nil
+}
+
+// executedAfter returns the registered migrations applied after the named one,
+// most recent first, optionally including the named migration itself.
+func (q *GoMigration) executedAfter(ctx context.Context, name string, inclusive bool) ([]Migration, error) {
+	executedMigrations, err := q.executedMigrations(ctx, HistoryOrderAppliedDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	target := -1
+	for i, m := range executedMigrations {
+		if m.Name == name {
+			target = i
+			break
+		}
+	}
+	if target < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationNotExecuted, name)
+	}
+
+	step := target
+	if inclusive {
+		step++
+	}
+
+	return q.registeredFor(executedMigrations[:step]), nil
+}
+
+// registeredFor maps executed migrations to their registered counterparts,
+// keeping the given order. Executed migrations that are no longer registered
+// are skipped with a warning.
+func (q *GoMigration) registeredFor(executedMigrations []ExecutedMigration) []Migration {
+	migrationMap := make(map[string]Migration, len(q.migrations))
+	for _, m := range q.migrations {
+		migrationMap[m.Name()] = m
+	}
+
+	migrations := make([]Migration, 0, len(executedMigrations))
+	for _, executedMigration := range executedMigrations {
+		if migration, found := migrationMap[executedMigration.Name]; found {
+			if executedMigration.Skipped {
+				migration = skippedMigration{Migration: migration}
+			}
+			migrations = append(migrations, migration)
+		} else {
+			q.logger.warn("⚠️  Migration not found for: "+executedMigration.Name, "migration not found", "migration", executedMigration.Name)
+		}
+	}
+
+	return migrations
+}
+
+// unapply rolls back the given migrations in the order provided.
+func (q *GoMigration) unapply(ctx context.Context, migrationsToRollback []Migration) error {
+	run := q.startRun(ctx, OperationRollback, len(migrationsToRollback))
+	if len(migrationsToRollback) == 0 {
+		q.logger.info("✅ No migrations to rollback", "no migrations to roll back")
+		return run.finish(nil)
+	}
+
+	q.logger.info(fmt.Sprintf("🔁 Rolling back %d migration(s)...", len(migrationsToRollback)), "rolling back migrations", "count", len(migrationsToRollback))
+
+	err := q.driver.UnapplyMigrations(
+		ctx,
+		migrationsToRollback,
+		func(m *Migration) {
+			run.migrationStartedEvent(*m)
+			q.logger.info("🔄 Rolling back: "+(*m).Name(), "rolling back", "migration", (*m).Name())
+			q.logger.sql(q.debugSql, q.downScript(*m), "migration", (*m).Name())
+		},
+		func(m *Migration) {
+			run.migrationSucceededEvent(*m)
+			q.logger.info("✅ Rolled back: "+(*m).Name(), "rolled back", "migration", (*m).Name())
+		},
+		func(m *Migration, err error) {
+			run.migrationFailedEvent(*m, err)
+			q.logger.error(fmt.Sprintf("❌ Rollback failed: %s - %s", (*m).Name(), err), "rollback failed", "migration", (*m).Name(), "error", err)
+		},
+	)
+	q.audit(ctx, OperationRollback, migrationNames(migrationsToRollback), err)
+	return run.finish(err)
+}
+
+// printRollbackDryRun prints the migrations a rollback would undo.
+func (q *GoMigration) printRollbackDryRun(migrationsToRollback []Migration) error {
+	if len(migrationsToRollback) == 0 {
+		q.logger.info("✅ No migrations to rollback", "no migrations to roll back")
+		return nil
+	}
+
+	q.logger.info(fmt.Sprintf("🔍 Dry run: %d migration(s) would be rolled back", len(migrationsToRollback)), "dry run, migrations would be rolled back", "count", len(migrationsToRollback))
+	for _, m := range migrationsToRollback {
+		script, err := q.renderScript(m.Name(), q.downScript(m))
+		if err != nil {
+			return err
+		}
+		q.logger.info("🔄 Would roll back: "+m.Name(), "would roll back", "migration", m.Name())
+		printScript(script)
+	}
+
+	return nil
+}
+
+// Clean drops all database tables and objects managed by the migration system.
+// The built-in drivers drop Config.CleanBatchSize tables per statement and
+// emit EventTablesDropped after each chunk; chunks dropped before a failure
+// stay dropped, so calling Clean again resumes with the tables left.
+func (q *GoMigration) Clean(ctx context.Context) error {
+	return q.CleanWithOptions(ctx, CleanOptions{})
+}
+
+// CleanWithOptions is Clean with opts. With opts.KeepHistory the tracking
+// table survives, still recording the migrations whose objects were dropped,
+// so the database is rebuilt with FreshWithOptions rather than Migrate.
+func (q *GoMigration) CleanWithOptions(ctx context.Context, opts CleanOptions) error {
+	q = q.snapshot()
+
+	q.logger.info("🧹 Cleaning database...", "cleaning database")
+
+	err := q.cleanDatabase(ctx, opts)
+	q.audit(ctx, OperationClean, nil, err)
+	if err != nil {
+		return fmt.Errorf("failed to clean database: %w", err)
+	}
+
+	q.logger.info("✅ Database cleaned successfully", "database cleaned")
+	return nil
+}
+
+// cleanDatabase drops the tables of the database, a chunk at a time when the
+// driver is a ChunkedCleaner, logging and emitting the progress.
+func (q *GoMigration) cleanDatabase(ctx context.Context, opts CleanOptions) error {
+	cleaner, ok := q.driver.(ChunkedCleaner)
+	if !ok {
+		if opts.KeepHistory {
+			return ErrKeepHistoryNotSupported
+		}
+		return q.driver.CleanDatabase(ctx)
+	}
+
+	return cleaner.CleanDatabaseChunked(ctx, opts, func(tables []string, dropped, total int) {
+		q.logger.info(fmt.Sprintf("🧹 Dropped %d/%d table(s)", dropped, total), "dropped tables", "dropped", dropped, "total", total)
+		q.emit(ctx, Event{
+			Type:      EventTablesDropped,
+			Operation: OperationClean,
+			Time:      time.Now(),
+			Total:     total,
+			Tables:    slices.Clone(tables),
+			Dropped:   dropped,
+		})
+	})
+}
+
+// MarkApplied records the named migration as applied without running its up
+// script, for migrations that were applied manually out-of-band.
+func (q *GoMigration) MarkApplied(ctx context.Context, name string) error {
+	q = q.snapshot()
+
+	migration, registered := q.migrations[name]
+	if !registered {
+		return fmt.Errorf("%w: %s", ErrMigrationNotRegistered, name)
+	}
+	writer, err := q.historyWriter()
+	if err != nil {
+		return err
+	}
+
+	return q.withLock(ctx, func() error {
+		if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		if _, executed, err := q.findExecuted(ctx, name); err != nil {
+			return err
+		} else if executed {
+			q.logger.warn("⚠️ Already marked as applied: "+name, "already marked as applied", "migration", name)
+			return nil
+		}
+
+		err := writer.InsertExecutedMigration(ctx, ExecutedMigration{
+			Name:       name,
+			ExecutedAt: time.Now(),
+			Checksum:   migrationChecksum(migration),
+			AppliedBy:  q.appliedBy,
+			Build:      q.build,
+			Namespace:  migrationSetOf(migration),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to mark %s as applied: %w", name, err)
+		}
+
+		q.logger.info("✅ Marked as applied: "+name, "marked as applied", "migration", name)
+		return nil
+	})
+}
+
+// MarkUnapplied removes the record of the named migration without running its
+// down script, for migrations that were reverted manually out-of-band. The
+// migration does not need to be registered.
+func (q *GoMigration) MarkUnapplied(ctx context.Context, name string) error {
+	q = q.snapshot()
+
+	writer, err := q.historyWriter()
+	if err != nil {
+		return err
+	}
+
+	return q.withLock(ctx, func() error {
+		if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		if _, executed, err := q.findExecuted(ctx, name); err != nil {
+			return err
+		} else if !executed {
+			q.logger.warn("⚠️ Already marked as unapplied: "+name, "already marked as unapplied", "migration", name)
+			return nil
+		}
+
+		if err := writer.RemoveExecutedMigration(ctx, name); err != nil {
+			return fmt.Errorf("failed to mark %s as unapplied: %w", name, err)
+		}
+
+		q.logger.info("✅ Marked as unapplied: "+name, "marked as unapplied", "migration", name)
+		return nil
+	})
+}
+
+// historyWriter returns the driver as a HistoryWriter, or a *CapabilityError
+// when it cannot write the tracking table.
+func (q *GoMigration) historyWriter() (HistoryWriter, error) {
+	writer, ok := q.driver.(HistoryWriter)
+	if !ok {
+		return nil, &CapabilityError{Capability: "HistoryWriter"}
+	}
+	return writer, nil
+}
+
+// findExecuted returns the tracking record of the named migration, if any.
+func (q *GoMigration) findExecuted(ctx context.Context, name string) (ExecutedMigration, bool, error) {
+	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	if err != nil {
+		return ExecutedMigration{}, false, err
+	}
+
+	for _, m := range executedMigrations {
+		if m.Name == name {
+			return m, true, nil
+		}
+	}
+	return ExecutedMigration{}, false, nil
+}
+
+// Repair brings the tracking table back in line with the registered
+// migrations: it records the current checksum of edited migrations, removes
+// records of migrations that no longer exist and fills in missing execution
+// times so the history sorts in apply order. No migration script is run.
+func (q *GoMigration) Repair(ctx context.Context) (RepairReport, error) {
+	q = q.snapshot()
+
+	writer, err := q.historyWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	var report RepairReport
+
+	err = q.withLock(ctx, func() error {
+		if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderName)
+		if err != nil {
+			return err
+		}
+
+		// Records without an execution time are placed right after the record
+		// preceding them by name, or before all others if there is none.
+		var lastExecutedAt time.Time
+		for _, m := range executedMigrations {
+			if !m.ExecutedAt.IsZero() && (lastExecutedAt.IsZero() || m.ExecutedAt.Before(lastExecutedAt)) {
+				lastExecutedAt = m.ExecutedAt
+			}
+		}
+		if lastExecutedAt.IsZero() {
+			lastExecutedAt = time.Now()
+		}
+
+		for _, executed := range executedMigrations {
+			migration, registered := q.migrations[executed.Name]
+			if !registered {
+				if err := writer.RemoveExecutedMigration(ctx, executed.Name); err != nil {
+					return fmt.Errorf("failed to remove record of %s: %w", executed.Name, err)
+				}
+				report = append(report, RepairAction{
+					Migration: executed.Name,
+					Type:      RepairRecordRemoved,
+					Detail:    "migration is no longer registered",
+				})
+				continue
+			}
+
+			repaired := executed
+			var actions []RepairAction
+
+			if checksum := migrationChecksum(migration); executed.Checksum != checksum {
+				repaired.Checksum = checksum
+				detail := "recorded " + checksum
+				if executed.Checksum != "" {
+					detail = fmt.Sprintf("%s -> %s", executed.Checksum, checksum)
+				}
+				actions = append(actions, RepairAction{
+					Migration: executed.Name,
+					Type:      RepairChecksumUpdated,
+					Detail:    detail,
+				})
+			}
+
+			if executed.ExecutedAt.IsZero() {
+				repaired.ExecutedAt = lastExecutedAt
+				actions = append(actions, RepairAction{
+					Migration: executed.Name,
+					Type:      RepairExecutedAtFixed,
+					Detail:    "set to " + lastExecutedAt.Format(time.RFC3339),
+				})
+			}
+			lastExecutedAt = repaired.ExecutedAt
+
+			if len(actions) == 0 {
+				continue
+			}
+			if err := writer.UpdateExecutedMigration(ctx, repaired); err != nil {
+				return fmt.Errorf("failed to repair record of %s: %w", executed.Name, err)
+			}
+			report = append(report, actions...)
+		}
+
+		q.logger.info(fmt.Sprintf("🛠️ Repair finished: %d change(s)", len(report)), "repair finished", "changes", len(report))
+		return nil
+	})
+	q.audit(ctx, OperationRepair, report.migrations(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Validate checks the migration history and the registered migrations for
+// inconsistencies: executed migrations that are not registered, registered
+// migrations with an up script that is empty or only holds comments,
+// migrations sharing a numeric prefix and
+// executed migrations that have been edited since. Nothing is changed: a
+// missing tracking table is read as an empty history, not created. The
+// error is only set when the check itself fails; use ValidationReport.Valid
+// to tell whether issues were found.
+func (q *GoMigration) Validate(ctx context.Context) (ValidationReport, error) {
+	q = q.snapshot()
+
+	executedMigrations, err := q.executedMigrations(ctx, HistoryOrderName)
+	if err != nil {
+		return nil, err
+	}
+
+	var report ValidationReport
+	executedNames := make(map[string]bool, len(executedMigrations))
+	for _, executed := range executedMigrations {
+		executedNames[executed.Name] = true
+		if _, registered := q.migrations[executed.Name]; !registered {
+			report = append(report, ValidationIssue{
+				Migration: executed.Name,
+				Type:      ValidationNotRegistered,
+				Detail:    "executed at " + executed.ExecutedAt.Format(time.RFC3339),
+			})
+		} else if q.editedSinceApplied(executed) {
+			report = append(report, ValidationIssue{
+				Migration: executed.Name,
+				Type:      ValidationChecksumMismatch,
+				Detail:    fmt.Sprintf("%s -> %s", executed.Checksum, migrationChecksum(q.migrations[executed.Name])),
+			})
+		}
+	}
+
+	names := getSortedMigrationName(q.migrations)
+	byPrefix := make(map[string][]string)
+	for _, name := range names {
+		if isEmptyScript(q.upScript(q.migrations[name])) {
+			report = append(report, ValidationIssue{
+				Migration: name,
+				Type:      ValidationEmptyUpScript,
+			})
+		}
+		if prefix := migrationPrefix(name); prefix != "" {
+			byPrefix[prefix] = append(byPrefix[prefix], name)
+		}
+	}
+	for _, name := range names {
+		prefix := migrationPrefix(name)
+		if len(byPrefix[prefix]) < 2 {
+			continue
+		}
+		report = append(report, ValidationIssue{
+			Migration: name,
+			Type:      ValidationDuplicatePrefix,
+			Detail:    fmt.Sprintf("prefix %s shared by %s", prefix, strings.Join(byPrefix[prefix], ", ")),
+		})
+	}
+
+	registered := make([]Migration, 0, len(names))
+	for _, name := range names {
+		registered = append(registered, q.migrations[name])
+		for _, dependency := range migrationDependencies(q.migrations[name]) {
+			if _, ok := q.migrations[dependency]; !ok && !executedNames[dependency] {
+				report = append(report, ValidationIssue{
+					Migration: name,
+					Type:      ValidationUnknownDependency,
+					Detail:    dependency,
+				})
+			}
+		}
+	}
+	_, cyclic := sortByDependencies(registered)
+	for _, name := range cyclic {
+		report = append(report, ValidationIssue{
+			Migration: name,
+			Type:      ValidationDependencyCycle,
+			Detail:    "depends on " + strings.Join(migrationDependencies(q.migrations[name]), ", "),
+		})
+	}
+
+	return report, nil
+}
+
+// squashExecutedMigrations replaces the records of the squashed migrations
+// with that of their baseline. The built-in drivers do so in a single
+// transaction. Other drivers record the baseline and then remove the squashed
+// records one by one; if a removal fails, the removed records are inserted
+// again and the baseline record removed, so the history is left as it was.
+func (q *GoMigration) squashExecutedMigrations(ctx context.Context, squashed []string, executed map[string]ExecutedMigration, baseline ExecutedMigration) error {
+	if driver, ok := q.driver.(squashingDriver); ok {
+		return driver.squashExecutedMigrations(ctx, squashed, baseline)
+	}
+
+	writer, err := q.historyWriter()
+	if err != nil {
+		return err
+	}
+	if err := writer.InsertExecutedMigration(ctx, baseline); err != nil {
+		return fmt.Errorf("failed to record %s: %w", baseline.Name, err)
+	}
+	for i, name := range squashed {
+		err := writer.RemoveExecutedMigration(ctx, name)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("failed to remove record of %s: %w", name, err)
+		for _, removed := range squashed[:i] {
+			if restoreErr := writer.InsertExecutedMigration(ctx, executed[removed]); restoreErr != nil {
+				return errors.Join(err, fmt.Errorf("failed to restore record of %s: %w", removed, restoreErr))
+			}
+		}
+		if restoreErr := writer.RemoveExecutedMigration(ctx, baseline.Name); restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to remove record of %s: %w", baseline.Name, restoreErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// Squash consolidates the registered migrations up to and including
+// upToName, which must all have been applied, into a single baseline
+// migration named after upToName with a "_squashed" suffix. Like the
+// migrations generated by Create, upToName must start with a timestamp.
+//
+// The baseline is generated from the current schema, as described by
+// InspectER: its up script creates the tables created or altered by the
+// squashed migrations, with their columns, types, nullability, primary keys
+// and foreign keys, and its down script drops them. Defaults, indexes, other
+// constraints and objects other than tables are not part of it, so review the
+// baseline before committing it. The driver must implement ERInspector, and
+// no migration after upToName may be applied, as the schema would hold its
+// changes too; otherwise Squash fails with ErrSquashNotSupported.
+//
+// The baseline file is written to the migration files directory and the
+// files of the squashed migrations are removed from it: their Go files, and
+// the SQL files of those registered by LoadFromDir, from the directory they
+// were loaded from. Migrations registered by LoadFromFS cannot be removed from
+// their file system, so squashing them fails with ErrSquashNotSupported. The
+// tracking history records the baseline as applied in place of them, and the
+// migration order file, if any, lists it in their place. The built-in drivers
+// swap the records in a single transaction; with other drivers, a failure
+// while removing the squashed records restores them, so a failed squash
+// leaves the history as it was. The code registering the squashed migrations
+// must then register the baseline instead.
+func (q *GoMigration) Squash(ctx context.Context, upToName string) error {
+	s := q.snapshot()
+
+	if _, registered := s.migrations[upToName]; !registered {
+		return fmt.Errorf("%w: %s", ErrMigrationNotRegistered, upToName)
+	}
+	if !migrationDirExists(s.migrationFilesDir) {
+		return fmt.Errorf("migration directory %q does not exist", s.migrationFilesDir)
+	}
+
+	names, err := s.orderedMigrationNames()
+	if err != nil {
+		return err
+	}
+	// Migrations of migration sets belong to their library and are left alone.
+	if set := migrationSetOf(s.migrations[upToName]); set != "" {
+		return fmt.Errorf("%w: cannot squash %s, owned by set %s", ErrInvalidMigrationSet, upToName, set)
+	}
+	var squashed []string
+	for _, name := range names[:slices.Index(names, upToName)+1] {
+		if migrationSetOf(s.migrations[name]) == "" {
+			squashed = append(squashed, name)
+		}
+	}
+
+	// The SQL files of squashed migrations are removed with their records, so
+	// they are not applied again on top of the baseline.
+	sqlFiles := make(map[string][]string)
+	for _, source := range s.fileSources {
+		for _, name := range source.names {
+			if !slices.Contains(squashed, name) {
+				continue
+			}
+			if source.dir == "" {
+				return fmt.Errorf("%w: %s is registered from %s by LoadFromFS, squash it in its source directory", ErrSquashNotSupported, name, source.location)
+			}
+			files, err := source.migrationFiles(name)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				sqlFiles[name] = append(sqlFiles[name], path.Join(source.dir, file))
+			}
+		}
+	}
+
+	inspector, ok := s.driver.(ERInspector)
+	if !ok {
+		return fmt.Errorf("%w: driver cannot inspect the schema", ErrSquashNotSupported)
+	}
+	tables, err := s.createdOrAlteredTables(squashed)
+	if err != nil {
+		return err
+	}
+
+	baseline := scriptMigration{name: upToName + "_squashed"}
+	baselineFileName := fmt.Sprintf("%s/%s.go", s.migrationFilesDir, baseline.name)
+	if fileExists(baselineFileName) {
+		return ErrMigrationFileAlreadyExists
+	}
+
+	err = s.withLock(ctx, func() error {
+		if err := s.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		executedMigrations, err := s.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+		if err != nil {
+			return err
+		}
+		executedByName := make(map[string]ExecutedMigration, len(executedMigrations))
+		for _, m := range executedMigrations {
+			executedByName[m.Name] = m
+		}
+
+		var pending, edited []string
+		batch := 0
+		for _, name := range squashed {
+			executed, ok := executedByName[name]
+			if !ok {
+				pending = append(pending, name)
+				continue
+			}
+			if s.editedSinceApplied(executed) {
+				edited = append(edited, name)
+			}
+			batch = max(batch, executed.Batch)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%w: %s", ErrMigrationNotExecuted, strings.Join(pending, ", "))
+		}
+		if err := checksumMismatchError(edited); err != nil {
+			return err
+		}
+		// The schema must not hold the changes of later migrations, which
+		// would then be made twice on databases migrated from the baseline.
+		for _, name := range names[slices.Index(names, upToName)+1:] {
+			if _, ok := executedByName[name]; ok && migrationSetOf(s.migrations[name]) == "" {
+				return fmt.Errorf("%w: %s is applied after %s, squash up to the last applied migration", ErrSquashNotSupported, name, upToName)
+			}
+		}
+
+		model, err := inspector.InspectER(ctx)
+		if err != nil {
+			return err
+		}
+		model = model.only(tables)
+		baseline.upScript = model.upScript(s.dialect())
+		baseline.downScript = model.downScript(s.dialect())
+		template, err := migrationFileTemplate(getPackageNameFromMigrationDir(s.migrationFilesDir), baseline.name, baseline.upScript, baseline.downScript, false)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(baselineFileName, []byte(template), 0644); err != nil {
+			return err
+		}
+
+		err = s.squashExecutedMigrations(ctx, squashed, executedByName, ExecutedMigration{
+			Name:       baseline.name,
+			ExecutedAt: time.Now(),
+			Checksum:   migrationChecksum(baseline),
+			Batch:      batch,
+			AppliedBy:  s.appliedBy,
+			Build:      s.build,
+		})
+		if err != nil {
+			_ = os.Remove(baselineFileName)
+			return err
+		}
+		s.logger.info("migration file created: "+baselineFileName, "migration file created", "file", baselineFileName)
+
+		for _, name := range squashed {
+			fileNames := append([]string{fmt.Sprintf("%s/%s.go", s.migrationFilesDir, name)}, sqlFiles[name]...)
+			for _, fileName := range fileNames {
+				if err := os.Remove(fileName); err == nil {
+					s.logger.info("migration file removed: "+fileName, "migration file removed", "file", fileName)
+				} else if !os.IsNotExist(err) {
+					s.logger.warn(fmt.Sprintf("⚠️ Failed to remove migration file %s: %v", fileName, err), "failed to remove migration file", "file", fileName, "error", err)
+				}
+			}
+		}
+
+		// The registry of q may have changed since the snapshot was taken.
+		q.mu.Lock()
+		for _, name := range squashed {
+			delete(q.migrations, name)
+			delete(q.registeredFrom, name)
+		}
+		q.migrations[baseline.name] = baseline
+		if q.migrationOrder != nil {
+			order := []string{}
+			for _, name := range q.migrationOrder {
+				if !slices.Contains(squashed, name) {
+					order = append(order, name)
+				} else if !slices.Contains(order, baseline.name) {
+					order = append(order, baseline.name)
+				}
+			}
+			q.migrationOrder = order
+		}
+		q.mu.Unlock()
+
+		if s.migrationOrderFile != "" {
+			if err := squashMigrationOrder(s.migrationOrderFile, squashed, baseline.name); err != nil {
+				return fmt.Errorf("failed to update migration order file: %w", err)
+			}
+		}
+
+		s.logger.info(fmt.Sprintf("🗜️ Squashed %d migration(s) into %s", len(squashed), baseline.name), "squashed migrations", "count", len(squashed), "baseline", baseline.name)
+		return nil
+	})
+	s.audit(ctx, OperationSquash, squashed, err)
+	return err
+}
+
+// NamespaceHistory moves the history of migration sets recorded without their
+// namespace, e.g. while a library's migrations were registered with Register,
+// under the names RegisterSet gives them, so they are not applied again. A
+// record is moved when exactly one registered set has a migration of that
+// name with the same checksum, and the service has none; it fails with
+// ErrInvalidMigrationSet when several sets match. Namespaced records without
+// the namespace column, created before it was tracked, get it filled in.
+// Execution time, checksum, batch and applied_by are kept.
+func (q *GoMigration) NamespaceHistory(ctx context.Context) error {
+	q = q.snapshot()
+
+	bySetName := make(map[string][]setMigration)
+	for _, migration := range q.migrations {
+		if m, ok := migration.(setMigration); ok {
+			bySetName[m.Migration.Name()] = append(bySetName[m.Migration.Name()], m)
+		}
+	}
+
+	writer, err := q.historyWriter()
+	if err != nil {
+		return err
+	}
+
+	var moved []string
+	err = q.withLock(ctx, func() error {
+		if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+		if err != nil {
+			return err
+		}
+		recorded := make(map[string]bool, len(executedMigrations))
+		for _, m := range executedMigrations {
+			recorded[m.Name] = true
+		}
+
+		type move struct {
+			record ExecutedMigration
+			to     setMigration
+		}
+		var moves []move
+		for _, record := range executedMigrations {
+			if m, ok := q.migrations[record.Name].(setMigration); ok {
+				if record.Namespace == "" {
+					moves = append(moves, move{record: record, to: m})
+				}
+				continue
+			}
+			if _, registered := q.migrations[record.Name]; registered {
+				continue
+			}
+
+			var matches []setMigration
+			var sets []string
+			for _, m := range bySetName[record.Name] {
+				if !recorded[m.Name()] && (record.Checksum == "" || record.Checksum == migrationChecksum(m)) {
+					matches = append(matches, m)
+					sets = append(sets, m.set)
+				}
+			}
+			if len(matches) > 1 {
+				slices.Sort(sets)
+				return fmt.Errorf("%w: %s matches migrations of sets %s", ErrInvalidMigrationSet, record.Name, strings.Join(sets, ", "))
+			}
+			if len(matches) == 1 {
+				moves = append(moves, move{record: record, to: matches[0]})
+			}
+		}
+
+		for _, mv := range moves {
+			record := mv.record
+			record.Name = mv.to.Name()
+			record.Namespace = mv.to.set
+			if mv.record.Name == record.Name {
+				if err := writer.RemoveExecutedMigration(ctx, record.Name); err != nil {
+					return fmt.Errorf("failed to remove record of %s: %w", record.Name, err)
+				}
+				if err := writer.InsertExecutedMigration(ctx, record); err != nil {
+					return fmt.Errorf("failed to record %s: %w", record.Name, err)
+				}
+			} else {
+				if err := writer.InsertExecutedMigration(ctx, record); err != nil {
+					return fmt.Errorf("failed to record %s: %w", record.Name, err)
+				}
+				if err := writer.RemoveExecutedMigration(ctx, mv.record.Name); err != nil {
+					return fmt.Errorf("failed to remove record of %s: %w", mv.record.Name, err)
+				}
+			}
+			moved = append(moved, record.Name)
+		}
+
+		q.logger.info(fmt.Sprintf("🏷️ Namespaced %d migration record(s)", len(moved)), "namespaced migration records", "count", len(moved))
+		return nil
+	})
+	q.audit(ctx, OperationNamespace, moved, err)
+	return err
+}
+
+// UpgradeTrackingTable adds the indexes and constraints that tracking tables
+// created by older versions are missing. Building indexes may lock a large
+// tracking table for a while, so schedule it like any other schema change.
+// The driver must implement TrackingTableUpgrader; otherwise it fails with a
+// *CapabilityError.
+func (q *GoMigration) UpgradeTrackingTable(ctx context.Context) error {
+	q = q.snapshot()
+
+	upgrader, ok := q.driver.(TrackingTableUpgrader)
+	if !ok {
+		return &CapabilityError{Capability: "TrackingTableUpgrader"}
+	}
+
+	return q.withLock(ctx, func() error {
+		q.logger.info(fmt.Sprintf("🔧 Upgrading tracking table %s...", q.migrationTableName), "upgrading tracking table", "table", q.migrationTableName)
+
+		if err := upgrader.UpgradeMigrationsTable(ctx); err != nil {
+			return fmt.Errorf("failed to upgrade tracking table: %w", err)
+		}
+
+		q.logger.info("✅ Tracking table is up to date", "tracking table is up to date")
+		return nil
+	})
+}
+
+// List returns all registered migrations along with their execution status.
+func (q *GoMigration) List(ctx context.Context) (RegisteredMigrationList, error) {
+	q = q.snapshot()
+
+	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	// Stream the history so only records of registered migrations are kept,
+	// however large the tracking table is.
+	executedMap := make(map[string]struct {
+		Executed   bool
+		Skipped    bool
+		ExecutedAt *time.Time
+	}, len(q.migrations))
+
+	var edited []string
+	err := q.driver.IterateExecutedMigrations(ctx, HistoryOrderApplied, func(m ExecutedMigration) error {
+		if _, registered := q.migrations[m.Name]; !registered {
+			return nil
+		}
+		if q.editedSinceApplied(m) {
+			edited = append(edited, m.Name)
+		}
+		executedMap[m.Name] = struct {
+			Executed   bool
+			Skipped    bool
+			ExecutedAt *time.Time
+		}{
+			Executed:   true,
+			Skipped:    m.Skipped,
+			ExecutedAt: &m.ExecutedAt,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checksumMismatchError(edited); err != nil {
+		return nil, err
+	}
+
+	names, err := q.orderedMigrationNames()
+	if err != nil {
+		return nil, err
 	}
 
 	registeredMigrations := make(RegisteredMigrationList, 0, len(q.migrations))
 
-	for _, k := range getSortedMigrationName(q.migrations) {
+	for _, k := range names {
 		migration := q.migrations[k]
 		name := migration.Name()
 		executed := executedMap[name]
@@ -326,9 +2740,464 @@ func (q *GoMigration) List(ctx context.Context) (RegisteredMigrationList, error)
 			UpScript:   migration.UpScript(),
 			DownScript: migration.DownScript(),
 			IsExecuted: executed.Executed,
+			IsSkipped:  executed.Skipped,
 			ExecutedAt: executed.ExecutedAt,
 		})
 	}
 
 	return registeredMigrations, nil
 }
+
+// Status returns a compact summary of executed, pending and unknown
+// migrations, for dashboards and deploy scripts that don't need the full List.
+func (q *GoMigration) Status(ctx context.Context) (MigrationStatus, error) {
+	q = q.snapshot()
+
+	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+		return MigrationStatus{}, err
+	}
+
+	var status MigrationStatus
+	err := q.driver.IterateExecutedMigrations(ctx, HistoryOrderApplied, func(m ExecutedMigration) error {
+		if _, registered := q.migrations[m.Name]; registered {
+			status.Executed++
+			if m.Skipped {
+				status.Skipped++
+			}
+		} else {
+			status.Unknown++
+		}
+		status.LastExecuted = &m
+		return nil
+	})
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	status.Pending = len(q.migrations) - status.Executed
+	status.UpToDate = status.Pending == 0
+
+	return status, nil
+}
+
+// IterateExecutedMigrations streams the executed migration history in apply
+// order, calling fn for each record. Iteration stops at the first error
+// returned by fn.
+func (q *GoMigration) IterateExecutedMigrations(ctx context.Context, fn func(migration ExecutedMigration) error) error {
+	return q.snapshot().driver.IterateExecutedMigrations(ctx, HistoryOrderApplied, fn)
+}
+
+// ExecutedMigrationsPage returns at most limit executed migrations in apply
+// order, skipping the first offset ones.
+func (q *GoMigration) ExecutedMigrationsPage(ctx context.Context, limit, offset int) ([]ExecutedMigration, error) {
+	q = q.snapshot()
+
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("invalid page: limit %d, offset %d", limit, offset)
+	}
+	return q.driver.GetExecutedMigrationsPage(ctx, HistoryOrderApplied, limit, offset)
+}
+
+// ExportHistory writes the executed migrations recorded in the tracking table
+// to w as an indented HistoryExport JSON document.
+func (q *GoMigration) ExportHistory(ctx context.Context, w io.Writer) error {
+	q = q.snapshot()
+
+	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(HistoryExport{
+		Table:      q.migrationTableName,
+		ExportedAt: time.Now(),
+		Migrations: executedMigrations,
+	})
+}
+
+// ImportHistory reads a HistoryExport JSON document written by ExportHistory
+// from r and records its migrations as executed, without running them. It
+// moves history between environments or restores it after the tracking table
+// was lost. Migrations already recorded are left unchanged, so importing the
+// same document twice is harmless.
+//
+// The document is checked before anything is recorded: every migration must
+// have a name and an execution time, and appear once, otherwise
+// ErrInvalidHistory is returned.
+func (q *GoMigration) ImportHistory(ctx context.Context, r io.Reader) error {
+	q = q.snapshot()
+
+	var history HistoryExport
+	if err := json.NewDecoder(r).Decode(&history); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHistory, err)
+	}
+
+	seen := make(map[string]bool, len(history.Migrations))
+	for i, m := range history.Migrations {
+		switch {
+		case m.Name == "":
+			return fmt.Errorf("%w: migration %d has no name", ErrInvalidHistory, i+1)
+		case m.ExecutedAt.IsZero():
+			return fmt.Errorf("%w: %s has no execution time", ErrInvalidHistory, m.Name)
+		case seen[m.Name]:
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidHistory, m.Name)
+		}
+		seen[m.Name] = true
+	}
+
+	return q.recordHistory(ctx, "history export", history.Migrations)
+}
+
+// recordHistory records as executed the migrations of records, which come
+// from source, without running them. Migrations already recorded are left
+// unchanged.
+func (q *GoMigration) recordHistory(ctx context.Context, source string, records []ExecutedMigration) error {
+	writer, err := q.historyWriter()
+	if err != nil {
+		return err
+	}
+
+	var imported []string
+	err = q.withLock(ctx, func() error {
+		if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+			return err
+		}
+
+		executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+		if err != nil {
+			return err
+		}
+		recorded := make(map[string]bool, len(executedMigrations))
+		for _, m := range executedMigrations {
+			recorded[m.Name] = true
+		}
+
+		skipped := 0
+		for _, m := range records {
+			if recorded[m.Name] {
+				skipped++
+				continue
+			}
+			if err := writer.InsertExecutedMigration(ctx, m); err != nil {
+				return fmt.Errorf("failed to record %s: %w", m.Name, err)
+			}
+			imported = append(imported, m.Name)
+		}
+
+		q.logger.info(fmt.Sprintf("📥 Imported %d migration record(s) from %s, %d already recorded", len(imported), source, skipped), "imported migration records", "imported", len(imported), "source", source, "skipped", skipped)
+		return nil
+	})
+	q.audit(ctx, OperationImport, imported, err)
+	return err
+}
+
+// MigrateFromGolangMigrate records as executed, without running them, the
+// registered migrations that golang-migrate has applied, so a project can
+// switch from golang-migrate without recreating its database. golang-migrate
+// only keeps the version of its last migration in its schema_migrations
+// table: every registered migration whose numeric name prefix is at most that
+// version is recorded. Migrations already recorded are left unchanged.
+//
+// The version must match the numeric prefix of a registered migration, and
+// the history must not be dirty, which golang-migrate marks when a migration
+// failed halfway: fix the database and run golang-migrate's force command
+// first. The driver must implement GolangMigrateReader, which the built-in
+// drivers do.
+func (q *GoMigration) MigrateFromGolangMigrate(ctx context.Context) error {
+	q = q.snapshot()
+
+	reader, ok := q.driver.(GolangMigrateReader)
+	if !ok {
+		return ErrGolangMigrateNotSupported
+	}
+
+	version, dirty, err := reader.GolangMigrateVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d failed halfway, fix it and force the version with golang-migrate first", ErrGolangMigrateDirty, version)
+	}
+	if version < 0 {
+		q.logger.info("✅ golang-migrate has not applied any migration, nothing to import", "golang-migrate has not applied any migration, nothing to import")
+		return nil
+	}
+
+	names, err := q.orderedMigrationNames()
+	if err != nil {
+		return err
+	}
+	var applied []string
+	found := false
+	for _, name := range names {
+		prefix, err := strconv.ParseInt(numericPrefix(name), 10, 64)
+		if err != nil || prefix > version {
+			continue
+		}
+		applied = append(applied, name)
+		found = found || prefix == version
+	}
+	if !found {
+		return fmt.Errorf("%w: no migration matches golang-migrate version %d", ErrMigrationNotRegistered, version)
+	}
+
+	executedAt := time.Now()
+	records := make([]ExecutedMigration, len(applied))
+	for i, name := range applied {
+		records[i] = ExecutedMigration{
+			Name:       name,
+			ExecutedAt: executedAt,
+			Checksum:   migrationChecksum(q.migrations[name]),
+			AppliedBy:  q.appliedBy,
+			Build:      q.build,
+		}
+	}
+	return q.recordHistory(ctx, fmt.Sprintf("golang-migrate version %d", version), records)
+}
+
+// MigrateFromGoose records as executed, without running them, the registered
+// migrations that goose has applied and not rolled back, with the time goose
+// applied them, so a project can switch from goose without recreating its
+// database. goose versions are matched against the numeric name prefixes of
+// the registered migrations. Migrations already recorded are left unchanged.
+//
+// Every applied version must match a registered migration. The driver must
+// implement GooseReader, which the built-in drivers do.
+func (q *GoMigration) MigrateFromGoose(ctx context.Context) error {
+	q = q.snapshot()
+
+	reader, ok := q.driver.(GooseReader)
+	if !ok {
+		return ErrGooseNotSupported
+	}
+
+	versions, err := reader.GooseVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	byVersion := q.migrationVersions()
+	var records []ExecutedMigration
+	var unknown []string
+	for _, v := range appliedGooseVersions(versions) {
+		name, ok := byVersion[v.VersionID]
+		if !ok {
+			unknown = append(unknown, strconv.FormatInt(v.VersionID, 10))
+			continue
+		}
+		records = append(records, ExecutedMigration{
+			Name:       name,
+			ExecutedAt: v.Tstamp,
+			Checksum:   migrationChecksum(q.migrations[name]),
+			AppliedBy:  q.appliedBy,
+			Build:      q.build,
+		})
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: no migration matches goose version(s) %s", ErrMigrationNotRegistered, strings.Join(unknown, ", "))
+	}
+
+	return q.recordHistory(ctx, "goose", records)
+}
+
+// MigrateFromFlyway records as executed, without running them, the registered
+// migrations that Flyway has applied and not undone, with the time and user
+// Flyway applied them with, so a project can switch from Flyway without
+// recreating its database. A Flyway migration matches the registered
+// migration named after its script without extension, e.g.
+// V1__create_users, or else the one whose numeric name prefix is its version.
+// Repeatable migrations and baselines are ignored. Migrations already
+// recorded are left unchanged.
+//
+// Flyway's checksum of each script is compared against the registered up
+// script, its variant for the dialect of the driver if it has one. Migrations
+// edited since Flyway applied them are recorded with a
+// checksum derived from Flyway's, so Migrate fails with ErrChecksumMismatch
+// until they are restored or repaired.
+//
+// Every applied migration must match a registered one, and none may have
+// failed, otherwise run flyway repair first. The driver must implement
+// FlywayReader, which the built-in drivers do.
+func (q *GoMigration) MigrateFromFlyway(ctx context.Context) error {
+	q = q.snapshot()
+
+	reader, ok := q.driver.(FlywayReader)
+	if !ok {
+		return ErrFlywayNotSupported
+	}
+
+	history, err := reader.FlywayHistory(ctx)
+	if err != nil {
+		return err
+	}
+
+	applied, failed := appliedFlywayMigrations(history)
+	if failed != nil {
+		return fmt.Errorf("%w: %s (%s), fix it and run flyway repair first", ErrFlywayFailed, failed.Version, failed.Script)
+	}
+
+	byVersion := q.migrationVersions()
+	var records []ExecutedMigration
+	var unknown, edited []string
+	for _, m := range applied {
+		name := strings.TrimSuffix(path.Base(m.Script), path.Ext(m.Script))
+		if _, ok := q.migrations[name]; !ok {
+			version, err := strconv.ParseInt(m.Version, 10, 64)
+			if name, ok = byVersion[version]; err != nil || !ok {
+				unknown = append(unknown, m.Version)
+				continue
+			}
+		}
+
+		record := ExecutedMigration{
+			Name:       name,
+			ExecutedAt: m.InstalledOn,
+			Checksum:   migrationChecksum(q.migrations[name]),
+			AppliedBy:  m.InstalledBy,
+		}
+		if m.Checksum != nil && *m.Checksum != flywayChecksum(q.upScript(q.migrations[name])) {
+			record.Checksum = flywayEditedChecksum(*m.Checksum)
+			edited = append(edited, name)
+		}
+		records = append(records, record)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: no migration matches Flyway version(s) %s", ErrMigrationNotRegistered, strings.Join(unknown, ", "))
+	}
+	if len(edited) > 0 {
+		q.logger.warn("⚠️ Edited since Flyway applied them, restore or repair before migrating: "+strings.Join(edited, ", "), "edited since Flyway applied them, restore or repair before migrating", "migrations", edited)
+	}
+
+	return q.recordHistory(ctx, "Flyway", records)
+}
+
+// migrationVersions maps the numeric name prefixes of the registered
+// migrations to their names.
+func (q *GoMigration) migrationVersions() map[int64]string {
+	versions := make(map[int64]string, len(q.migrations))
+	for name := range q.migrations {
+		if version, err := strconv.ParseInt(numericPrefix(name), 10, 64); err == nil {
+			versions[version] = name
+		}
+	}
+	return versions
+}
+
+// audit appends the outcome of operation to the audit log, if enabled. The
+// operation has already happened, so a failure to record it is only logged.
+func (q *GoMigration) audit(ctx context.Context, operation Operation, migrations []string, err error) {
+	if !q.auditLog {
+		return
+	}
+	logger, ok := q.driver.(AuditLogger)
+	if !ok {
+		q.logger.warn(fmt.Sprintf("⚠️ Driver does not support an audit log, %s not recorded", operation), "driver does not support an audit log, operation not recorded", "operation", operation)
+		return
+	}
+
+	event := AuditEvent{
+		Time:       time.Now(),
+		Operation:  operation,
+		Actor:      q.appliedBy,
+		Migrations: migrations,
+		Outcome:    AuditOutcomeSucceeded,
+	}
+	if err != nil {
+		event.Outcome = AuditOutcomeFailed
+		event.Error = err.Error()
+	}
+
+	if err := logger.AppendAuditEvent(ctx, event); err != nil {
+		q.logger.warn(fmt.Sprintf("⚠️ Failed to write audit log: %v", err), "failed to write audit log", "error", err)
+	}
+}
+
+// withLock runs fn while holding the migration lock, taken from the configured
+// Locker if any and from the driver otherwise. Acquisition is bounded by the
+// configured lock timeout, if any. Since fn may change the history, the stored
+// manifest hash is cleared first. Runs against a read-only endpoint are
+// refused before the lock is taken.
+func (q *GoMigration) withLock(ctx context.Context, fn func() error) error {
+	if err := q.checkWritable(ctx); err != nil {
+		return err
+	}
+
+	locked := fn
+	fn = func() error {
+		if err := q.forgetManifest(ctx); err != nil {
+			return err
+		}
+		return locked()
+	}
+
+	lockCtx := ctx
+	if q.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, q.lockTimeout)
+		defer cancel()
+	}
+
+	if q.locker != nil {
+		return q.withLocker(ctx, lockCtx, fn)
+	}
+
+	if err := q.driver.AcquireLock(lockCtx); err != nil {
+		return fmt.Errorf("%w: %w", ErrLockNotAcquired, err)
+	}
+	defer func() {
+		if err := q.driver.ReleaseLock(context.WithoutCancel(ctx)); err != nil {
+			q.logger.warn(fmt.Sprintf("⚠️  Failed to release migration lock: %s", err), "failed to release migration lock", "error", err)
+		}
+	}()
+
+	return fn()
+}
+
+// withLocker runs fn while holding the lock of the configured Locker, renewing
+// its lease in the background when the Locker supports it.
+func (q *GoMigration) withLocker(ctx context.Context, lockCtx context.Context, fn func() error) error {
+	key := migrationLockName(q.migrationTableName, q.lockScope)
+
+	if err := q.locker.Lock(lockCtx, key); err != nil {
+		return fmt.Errorf("%w: %w", ErrLockNotAcquired, err)
+	}
+	defer func() {
+		if err := q.locker.Unlock(context.WithoutCancel(ctx), key); err != nil {
+			q.logger.warn(fmt.Sprintf("⚠️  Failed to release migration lock: %s", err), "failed to release migration lock", "error", err)
+		}
+	}()
+
+	if renewer, ok := q.locker.(LeaseRenewer); ok {
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		defer func() {
+			close(stop)
+			<-done
+		}()
+
+		go func() {
+			defer close(done)
+			ticker := time.NewTicker(q.lockRenewInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if err := renewer.Renew(ctx, key); err != nil {
+						q.logger.warn(fmt.Sprintf("⚠️  Failed to renew migration lock: %s", err), "failed to renew migration lock", "error", err)
+					}
+				}
+			}
+		}()
+	}
+
+	return fn()
+}
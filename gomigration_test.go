@@ -1,9 +1,19 @@
 package gomigration
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -24,18 +34,68 @@ func (m *mockDriver) CreateMigrationsTable(ctx context.Context) error {
 	return args.Error(0)
 }
 
-func (m *mockDriver) GetExecutedMigrations(ctx context.Context, includeRollbacked bool) ([]ExecutedMigration, error) {
-	args := m.Called(ctx, includeRollbacked)
+func (m *mockDriver) UpgradeMigrationsTable(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *mockDriver) GetExecutedMigrations(ctx context.Context, order HistoryOrder) ([]ExecutedMigration, error) {
+	args := m.Called(ctx, order)
+	return args.Get(0).([]ExecutedMigration), args.Error(1)
+}
+
+func (m *mockDriver) IterateExecutedMigrations(ctx context.Context, order HistoryOrder, fn func(ExecutedMigration) error) error {
+	args := m.Called(ctx, order)
+	for _, migration := range args.Get(0).([]ExecutedMigration) {
+		if err := fn(migration); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *mockDriver) GetExecutedMigrationsPage(ctx context.Context, order HistoryOrder, limit, offset int) ([]ExecutedMigration, error) {
+	args := m.Called(ctx, order, limit, offset)
 	return args.Get(0).([]ExecutedMigration), args.Error(1)
 }
 
 func (m *mockDriver) ApplyMigrations(ctx context.Context, migrations []Migration, before, after func(*Migration), onError func(*Migration, error)) error {
 	args := m.Called(ctx, migrations)
+	runCallbacks(migrations, before, after, onError, args.Error(0))
 	return args.Error(0)
 }
 
+// runCallbacks mimics a driver calling its callbacks: every migration succeeds,
+// or the first one fails with err.
+func runCallbacks(migrations []Migration, before, after func(*Migration), onError func(*Migration, error), err error) {
+	for i := range migrations {
+		before(&migrations[i])
+		if err != nil {
+			onError(&migrations[i], err)
+			return
+		}
+		after(&migrations[i])
+	}
+}
+
 func (m *mockDriver) UnapplyMigrations(ctx context.Context, migrations []Migration, before, after func(*Migration), onError func(*Migration, error)) error {
 	args := m.Called(ctx, migrations)
+	runCallbacks(migrations, before, after, onError, args.Error(0))
+	return args.Error(0)
+}
+
+func (m *mockDriver) InsertExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *mockDriver) UpdateExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *mockDriver) RemoveExecutedMigration(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
 	return args.Error(0)
 }
 
@@ -44,6 +104,51 @@ func (m *mockDriver) CleanDatabase(ctx context.Context) error {
 	return args.Error(0)
 }
 
+func (m *mockDriver) AcquireLock(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *mockDriver) ReleaseLock(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+// manifestMockDriver is a mockDriver that also implements ManifestStore.
+type manifestMockDriver struct {
+	mockDriver
+}
+
+func (m *manifestMockDriver) GetManifestHash(ctx context.Context) (string, error) {
+	args := m.Called(ctx)
+	return args.String(0), args.Error(1)
+}
+
+func (m *manifestMockDriver) SetManifestHash(ctx context.Context, hash string) error {
+	args := m.Called(ctx, hash)
+	return args.Error(0)
+}
+
+// auditMockDriver is a mockDriver that also implements AuditLogger.
+type auditMockDriver struct {
+	mockDriver
+}
+
+func (m *auditMockDriver) AppendAuditEvent(ctx context.Context, event AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+// erMockDriver is a mockDriver that also implements ERInspector.
+type erMockDriver struct {
+	mockDriver
+}
+
+func (m *erMockDriver) InspectER(ctx context.Context) (ERModel, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(ERModel), args.Error(1)
+}
+
 func TestGoMigration_New_ErrorNilConfig(t *testing.T) {
 	q, err := New(nil)
 	assert.Nil(t, q)
@@ -57,6 +162,18 @@ func TestGoMigration_New_ErrorNilDriver(t *testing.T) {
 	assert.Equal(t, ErrDriverNotProvided, err)
 }
 
+func TestGoMigration_New_ErrorAuditLogNotSupported(t *testing.T) {
+	q, err := New(&Config{Driver: new(mockDriver), AuditLog: true})
+	assert.Nil(t, q)
+	assert.Equal(t, ErrAuditLogNotSupported, err)
+}
+
+func TestGoMigration_New_ErrorInvalidLockScope(t *testing.T) {
+	q, err := New(&Config{Driver: new(mockDriver), LockScope: "tenant-a; DROP"})
+	assert.Nil(t, q)
+	assert.ErrorContains(t, err, "invalid lock scope")
+}
+
 func TestGoMigration_Register_Duplicate(t *testing.T) {
 	q := &GoMigration{migrations: make(map[string]Migration)}
 
@@ -70,11 +187,267 @@ func TestGoMigration_Register_Duplicate(t *testing.T) {
 	assert.Contains(t, err.Error(), "registered more than once")
 }
 
+func TestGoMigration_Register_Concurrent(t *testing.T) {
+	q := &GoMigration{}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, q.Register(dummyMigration{name: fmt.Sprintf("%03d_package", i)}))
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, q.Migrations(), 20)
+}
+
+func TestGoMigration_RegisterMany(t *testing.T) {
+	q := &GoMigration{migrations: make(map[string]Migration)}
+	assert.NoError(t, q.Register(dummyMigration{name: "001_create_users"}))
+
+	err := q.RegisterMany(
+		dummyMigration{name: "002_create_orders"},
+		dummyMigration{name: "001_create_users"},
+		nil,
+		dummyMigration{},
+		dummyMigration{name: "002_create_orders"},
+	)
+	assert.ErrorContains(t, err, "migration 001_create_users registered more than once")
+	assert.ErrorContains(t, err, "migration 002_create_orders registered more than once")
+	assert.ErrorIs(t, err, ErrMigrationNotProvided)
+	assert.ErrorIs(t, err, ErrMigrationNameNotProvided)
+	assert.Len(t, q.migrations, 1)
+
+	assert.NoError(t, q.RegisterMany(dummyMigration{name: "002_create_orders"}, dummyMigration{name: "003_create_invoices"}))
+	assert.Len(t, q.migrations, 3)
+}
+
+func TestGoMigration_Unregister(t *testing.T) {
+	q := &GoMigration{migrations: make(map[string]Migration)}
+	assert.NoError(t, q.Register(
+		dummyMigration{name: "002_create_orders"},
+		dummyMigration{name: "001_create_users"},
+		dummyMigration{name: "003_create_invoices"},
+	))
+	assert.Equal(t, []string{"001_create_users", "002_create_orders", "003_create_invoices"}, migrationNames(q.Registered()))
+
+	q.Unregister("002_create_orders")
+	q.Unregister("004_unknown")
+	assert.Equal(t, []string{"001_create_users", "003_create_invoices"}, migrationNames(q.Registered()))
+	assert.NotContains(t, q.registeredFrom, "002_create_orders")
+
+	assert.NoError(t, q.Register(dummyMigration{name: "002_create_orders"}))
+	assert.Len(t, q.Registered(), 3)
+}
+
+func TestGoMigration_LoadFromDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"002_create_posts.up.sql":   "CREATE TABLE posts (id INTEGER);",
+		"001_create_users.up.sql":   "CREATE TABLE users (id INTEGER);",
+		"001_create_users.down.sql": "DROP TABLE users;",
+		"003_seed.go":               "package migrations\n",
+		"README.md":                 "notes",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	q := &GoMigration{migrationFilesDir: dir, migrations: make(map[string]Migration)}
+	assert.NoError(t, q.LoadFromDir())
+
+	infos := q.Migrations()
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "001_create_users", infos[0].Name)
+		assert.Equal(t, "002_create_posts", infos[1].Name)
+		assert.Equal(t, filepath.Join(dir, "001_create_users.up.sql"), infos[0].RegisteredFrom)
+	}
+	assert.Equal(t, "DROP TABLE users;", q.migrations["001_create_users"].DownScript())
+	assert.Empty(t, q.migrations["002_create_posts"].DownScript())
+
+	err := q.LoadFromDir()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "registered more than once")
+	assert.Len(t, q.migrations, 2)
+}
+
+func TestGoMigration_LoadFromDir_Errors(t *testing.T) {
+	q := &GoMigration{migrationFilesDir: filepath.Join(t.TempDir(), "missing"), migrations: make(map[string]Migration)}
+	assert.ErrorIs(t, q.LoadFromDir(), ErrMigrationDirNotExists)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "001_create_users.down.sql"), []byte("DROP TABLE users;"), 0644))
+	q.SetMigrationFilesDir(dir)
+	assert.ErrorIs(t, q.LoadFromDir(), ErrMigrationFileNotFound)
+	assert.Empty(t, q.migrations)
+}
+
+func TestGoMigration_CreateSQL(t *testing.T) {
+	dir := t.TempDir()
+	q := &GoMigration{migrationFilesDir: dir, migrations: make(map[string]Migration)}
+	assert.NoError(t, q.CreateSQL("create users"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_create_users.sql"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "001_create_posts.sql"), []byte("-- +goose Up\nCREATE TABLE posts (id INTEGER);\n-- +goose Down\nDROP TABLE posts;\n"), 0644))
+	assert.NoError(t, q.LoadFromDir())
+
+	infos := q.Migrations()
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "001_create_posts", infos[0].Name)
+		assert.Equal(t, filepath.Join(dir, "001_create_posts.sql"), infos[0].RegisteredFrom)
+		assert.Equal(t, strings.TrimSuffix(filepath.Base(files[0]), ".sql"), infos[1].Name)
+	}
+	assert.Equal(t, "DROP TABLE posts;", q.migrations["001_create_posts"].DownScript())
+	assert.True(t, isEmptyScript(q.migrations[infos[1].Name].UpScript()))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "002_broken.sql"), []byte("DROP TABLE posts;\n"), 0644))
+	q = &GoMigration{migrationFilesDir: dir, migrations: make(map[string]Migration)}
+	err = q.LoadFromDir()
+	assert.ErrorIs(t, err, ErrInvalidMigrationFile)
+	assert.ErrorContains(t, err, "002_broken.sql")
+	assert.Empty(t, q.migrations)
+}
+
+func TestGoMigration_Create_Templates(t *testing.T) {
+	dir := t.TempDir()
+	q := &GoMigration{migrationFilesDir: dir, appliedBy: "ada", createTemplates: CreateTemplates{
+		Up:   "-- {{ .Name }} by {{ .Author }} on {{ .Timestamp.Format \"2006-01-02\" }}\nSET lock_timeout = '5s';\n",
+		Down: "-- revert {{ .ID }}\n",
+	}}
+	assert.NoError(t, q.CreateSQL("create users"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_create_users.sql"))
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		name := strings.TrimSuffix(filepath.Base(files[0]), ".sql")
+		content, err := os.ReadFile(files[0])
+		assert.NoError(t, err)
+		assert.Equal(t, annotatedMigrationTemplate(
+			fmt.Sprintf("-- %s by ada on %s\nSET lock_timeout = '5s';\n", name, time.Now().Format("2006-01-02")),
+			"-- revert "+strings.TrimSuffix(name, "_create_users")+"\n",
+		), string(content))
+	}
+
+	q.createTemplates = CreateTemplates{Up: "{{ .Ticket }}"}
+	assert.ErrorContains(t, q.Create("create posts"), "failed to render up template")
+
+	_, err = New(&Config{Driver: new(mockDriver), CreateTemplates: CreateTemplates{Down: "{{ .Name"}})
+	assert.ErrorContains(t, err, "invalid create templates: invalid down template")
+}
+
+func TestGoMigration_CreateGo(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0o755))
+	q := &GoMigration{migrationFilesDir: dir}
+	assert.NoError(t, q.CreateGo("create users"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_create_users.go"))
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		content, err := os.ReadFile(files[0])
+		assert.NoError(t, err)
+		structName, err := migrationNameToStructName(strings.TrimSuffix(filepath.Base(files[0]), ".go"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "import \"github.com/openframebox/gomigration\"")
+		assert.Contains(t, string(content), "func init() {\n\tgomigration.Register(&"+structName+"{})\n}")
+	}
+}
+
+func TestGoMigration_RegisterGlobal(t *testing.T) {
+	t.Cleanup(func() { registry.migrations, registry.registeredFrom = nil, nil })
+
+	Register(dummyMigration{name: "001_create_users"}, dummyMigration{name: "002_create_orders"})
+	q := &GoMigration{}
+	assert.NoError(t, q.RegisterGlobal())
+	infos := q.Migrations()
+	if assert.Len(t, infos, 2) {
+		assert.Contains(t, infos[0].RegisteredFrom, "gomigration_test.go")
+	}
+
+	Register(dummyMigration{name: "003_create_invoices"})
+	err := q.RegisterGlobal()
+	assert.ErrorContains(t, err, "migration 001_create_users registered more than once")
+	assert.Len(t, q.Migrations(), 2)
+}
+
+func TestGoMigration_LoadFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER);")},
+		"migrations/001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations/nested/002_ignored.up.sql": {Data: []byte("CREATE TABLE ignored (id INTEGER);")},
+		"002_elsewhere.up.sql":                 {Data: []byte("CREATE TABLE elsewhere (id INTEGER);")},
+	}
+
+	q := &GoMigration{migrations: make(map[string]Migration)}
+	assert.NoError(t, q.LoadFromFS(fsys, "migrations"))
+
+	infos := q.Migrations()
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "001_create_users", infos[0].Name)
+		assert.Equal(t, "migrations/001_create_users.up.sql", infos[0].RegisteredFrom)
+	}
+	assert.Equal(t, "CREATE TABLE users (id INTEGER);", q.migrations["001_create_users"].UpScript())
+
+	assert.ErrorIs(t, q.LoadFromFS(nil, "migrations"), ErrEmbeddedFSNotProvided)
+	assert.ErrorIs(t, q.LoadFromFS(fsys, "missing"), ErrMigrationDirNotExists)
+}
+
+func TestGoMigration_LoadFromFS_DialectVariants(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_create_users.up.sql":          {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);")},
+		"001_create_users.down.sql":        {Data: []byte("DROP TABLE users;")},
+		"001_create_users.mysql.up.sql":    {Data: []byte("CREATE TABLE users (id INT AUTO_INCREMENT PRIMARY KEY);")},
+		"002_add_index.sqlite.up.sql":      {Data: []byte("CREATE INDEX users_id ON users (id);")},
+		"002_add_index.sqlite.down.sql":    {Data: []byte("DROP INDEX users_id;")},
+		"002_add_index.postgres.down.sql":  {Data: []byte("DROP INDEX users_id;")},
+		"002_add_index.postgres.up.sql":    {Data: []byte("CREATE INDEX CONCURRENTLY users_id ON users (id);")},
+		"003_unrelated.mariadb.up.sql":     {Data: []byte("SELECT 1;")},
+		"003_unrelated.mariadb.down.sql":   {Data: []byte("SELECT 1;")},
+		"004_orphan.mysql.down.sql.backup": {Data: []byte("")},
+	}
+
+	q := &GoMigration{migrations: make(map[string]Migration)}
+	assert.NoError(t, q.LoadFromFS(fsys, "."))
+
+	infos := q.Migrations()
+	if assert.Len(t, infos, 3) {
+		assert.Equal(t, "001_create_users", infos[0].Name)
+		assert.Equal(t, "001_create_users.up.sql", infos[0].RegisteredFrom)
+		assert.Equal(t, "002_add_index", infos[1].Name)
+		assert.Equal(t, "002_add_index.postgres.up.sql", infos[1].RegisteredFrom)
+		assert.Equal(t, "003_unrelated.mariadb", infos[2].Name)
+	}
+
+	users := q.migrations["001_create_users"]
+	up, down := dialectScripts(users, DialectMySQL)
+	assert.Equal(t, "CREATE TABLE users (id INT AUTO_INCREMENT PRIMARY KEY);", up)
+	assert.Equal(t, "DROP TABLE users;", down)
+	up, _ = dialectScripts(users, DialectPostgres)
+	assert.Equal(t, "CREATE TABLE users (id SERIAL PRIMARY KEY);", up)
+	assert.NotEqual(t, migrationChecksum(scriptMigration{upScript: users.UpScript()}), migrationChecksum(users))
+
+	index := q.migrations["002_add_index"]
+	assert.Empty(t, index.UpScript())
+	up, down = dialectScripts(index, DialectSQLite)
+	assert.Equal(t, "CREATE INDEX users_id ON users (id);", up)
+	assert.Equal(t, "DROP INDEX users_id;", down)
+
+	orphan := fstest.MapFS{"001_create_users.mysql.down.sql": {Data: []byte("DROP TABLE users;")}}
+	q = &GoMigration{migrations: make(map[string]Migration)}
+	assert.ErrorIs(t, q.LoadFromFS(orphan, "."), ErrMigrationFileNotFound)
+}
+
 func TestGoMigration_Migrate_NoMigrations(t *testing.T) {
 	ctx := context.TODO()
 	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
 	driver.On("CreateMigrationsTable", ctx).Return(nil)
-	driver.On("GetExecutedMigrations", ctx, false).Return([]ExecutedMigration{}, nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
 
 	q := &GoMigration{
 		driver:     driver,
@@ -86,68 +459,1805 @@ func TestGoMigration_Migrate_NoMigrations(t *testing.T) {
 	driver.AssertExpectations(t)
 }
 
-func TestGoMigration_Fresh_Success(t *testing.T) {
+func TestGoMigration_Migrate_ChecksumMismatch(t *testing.T) {
 	ctx := context.TODO()
+	applied := dummyMigration{name: "001_create_users"}
 	driver := new(mockDriver)
-	driver.On("CleanDatabase", ctx).Return(nil)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
 	driver.On("CreateMigrationsTable", ctx).Return(nil)
-	driver.On("GetExecutedMigrations", ctx, false).Return([]ExecutedMigration{}, nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "001_create_users", ExecutedAt: time.Now(), Checksum: "edited"},
+	}, nil)
 
 	q := &GoMigration{
-		driver:     driver,
-		migrations: map[string]Migration{},
+		driver: driver,
+		migrations: map[string]Migration{
+			applied.Name():     applied,
+			"002_create_posts": dummyMigration{name: "002_create_posts"},
+		},
 	}
 
-	err := q.Fresh(ctx)
+	err := q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Contains(t, err.Error(), "001_create_users")
+	driver.AssertNotCalled(t, "ApplyMigrations", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_Migrate_OutOfOrder(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "001_create_users", ExecutedAt: time.Now()},
+		{Name: "003_create_tags", ExecutedAt: time.Now()},
+	}, nil)
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_create_posts": dummyMigration{name: "002_create_posts"},
+			"003_create_tags":  dummyMigration{name: "003_create_tags"},
+			"004_create_roles": dummyMigration{name: "004_create_roles"},
+		},
+		outOfOrderPolicy: OutOfOrderFail,
+	}
+
+	err := q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrOutOfOrderMigration)
+	assert.ErrorContains(t, err, "002_create_posts (ordered before executed 003_create_tags)")
+	assert.NotContains(t, err.Error(), "004_create_roles")
+	driver.AssertNotCalled(t, "ApplyMigrations", mock.Anything, mock.Anything)
+
+	q.outOfOrderPolicy = OutOfOrderWarn
+	pending, err := q.pendingMigrations(ctx)
 	assert.NoError(t, err)
-	driver.AssertExpectations(t)
+	assert.Len(t, pending, 2)
 }
 
-func TestGoMigration_Reset_NoExecuted(t *testing.T) {
+func TestGoMigration_RegisterSet(t *testing.T) {
+	q := &GoMigration{migrations: make(map[string]Migration)}
+
+	assert.NoError(t, q.Register(dummyMigration{name: "001_create_orders"}))
+	assert.NoError(t, q.RegisterSet(
+		MigrationSet{Name: "billing", Migrations: []Migration{dummyMigration{name: "001_create_invoices"}}, After: []string{"auth"}},
+		MigrationSet{Name: "auth", Migrations: []Migration{dummyMigration{name: "002_create_roles"}, dummyMigration{name: "001_create_users"}}},
+		MigrationSet{Name: "audit", Migrations: []Migration{dummyMigration{name: "001_create_events"}}},
+	))
+
+	names, err := q.orderedMigrationNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"audit/001_create_events",
+		"auth/001_create_users",
+		"auth/002_create_roles",
+		"billing/001_create_invoices",
+		"001_create_orders",
+	}, names)
+
+	infos := q.Migrations()
+	assert.Equal(t, "audit", infos[0].Set)
+	assert.Equal(t, "gomigration.dummyMigration", infos[0].Source)
+	assert.Empty(t, infos[4].Set)
+
+	assert.ErrorIs(t, q.RegisterSet(MigrationSet{Name: "auth"}), ErrInvalidMigrationSet)
+	assert.ErrorIs(t, q.RegisterSet(MigrationSet{Name: "auth/v2"}), ErrInvalidMigrationSet)
+}
+
+func TestGoMigration_RegisterSet_OutOfOrder(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "auth/001_create_users", ExecutedAt: time.Now()},
+		{Name: "001_create_orders", ExecutedAt: time.Now()},
+	}, nil)
+
+	q := &GoMigration{driver: driver, migrations: make(map[string]Migration), outOfOrderPolicy: OutOfOrderFail}
+	assert.NoError(t, q.Register(dummyMigration{name: "001_create_orders"}))
+	assert.NoError(t, q.RegisterSet(MigrationSet{Name: "auth", Migrations: []Migration{
+		dummyMigration{name: "001_create_users"},
+		dummyMigration{name: "002_create_roles"},
+	}}))
+
+	// A new migration of a set is not out of order because the service's own
+	// migrations ran after the set.
+	pending, err := q.pendingMigrations(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "auth/002_create_roles", pending[0].Name())
+	}
+
+	assert.NoError(t, q.Register(dummyMigration{name: "000_bootstrap"}))
+	_, err = q.pendingMigrations(ctx)
+	assert.ErrorIs(t, err, ErrOutOfOrderMigration)
+	assert.ErrorContains(t, err, "000_bootstrap (ordered before executed 001_create_orders)")
+}
+
+func TestGoMigration_NamespaceHistory(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "namespace.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	authInit := scriptMigration{name: "001_init", upScript: "CREATE TABLE users (id INTEGER);"}
+	billingInit := scriptMigration{name: "001_init", upScript: "CREATE TABLE invoices (id INTEGER);"}
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations", migrations: make(map[string]Migration)}
+	driver.SetMigrationTableName(q.migrationTableName)
+	assert.NoError(t, q.RegisterSet(
+		MigrationSet{Name: "auth", Migrations: []Migration{authInit}},
+		MigrationSet{Name: "billing", Migrations: []Migration{billingInit}},
+	))
+
+	assert.NoError(t, driver.CreateMigrationsTable(ctx))
+	executedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, driver.InsertExecutedMigration(ctx, ExecutedMigration{Name: "001_init", ExecutedAt: executedAt, Checksum: migrationChecksum(authInit), Batch: 3}))
+	assert.NoError(t, driver.InsertExecutedMigration(ctx, ExecutedMigration{Name: "billing/001_init", ExecutedAt: executedAt, Checksum: migrationChecksum(billingInit), Batch: 4}))
+
+	assert.NoError(t, q.NamespaceHistory(ctx))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderName)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 2) {
+		assert.Equal(t, "auth/001_init", executed[0].Name)
+		assert.Equal(t, "auth", executed[0].Namespace)
+		assert.Equal(t, 3, executed[0].Batch)
+		assert.True(t, executed[0].ExecutedAt.Equal(executedAt))
+		assert.Equal(t, "billing/001_init", executed[1].Name)
+		assert.Equal(t, "billing", executed[1].Namespace)
+	}
+
+	pending, err := q.pendingMigrations(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestGoMigration_NamespaceHistory_Ambiguous(t *testing.T) {
 	ctx := context.TODO()
 	driver := new(mockDriver)
-	driver.On("GetExecutedMigrations", ctx, true).Return([]ExecutedMigration{}, nil)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_init"}}, nil)
+
+	q := &GoMigration{driver: driver, migrations: make(map[string]Migration)}
+	assert.NoError(t, q.RegisterSet(
+		MigrationSet{Name: "billing", Migrations: []Migration{dummyMigration{name: "001_init"}}},
+		MigrationSet{Name: "auth", Migrations: []Migration{dummyMigration{name: "001_init"}}},
+	))
+
+	err := q.NamespaceHistory(ctx)
+	assert.ErrorIs(t, err, ErrInvalidMigrationSet)
+	assert.ErrorContains(t, err, "001_init matches migrations of sets auth, billing")
+	driver.AssertNotCalled(t, "InsertExecutedMigration", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_Migrate_EmptyUpScript(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
 
 	q := &GoMigration{
 		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_merge_leftover": scriptMigration{
+				name:     "002_merge_leftover",
+				upScript: "-- TODO: add the posts table\n/* CREATE TABLE posts (id INT); */\n;",
+			},
+		},
+		emptyScriptPolicy: EmptyScriptFail,
 	}
 
-	err := q.Reset(ctx)
+	err := q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrEmptyUpScript)
+	assert.ErrorContains(t, err, "002_merge_leftover")
+	assert.NotContains(t, err.Error(), "001_create_users")
+	driver.AssertNotCalled(t, "ApplyMigrations", mock.Anything, mock.Anything)
+
+	driver.On("ApplyMigrations", runContext(ctx), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	q.emptyScriptPolicy = EmptyScriptWarn
+	assert.NoError(t, q.Migrate(ctx))
+}
+
+type gatedMigration struct {
+	dummyMigration
+	gate MigrationGate
+}
+
+func (m gatedMigration) Gate() MigrationGate { return m.gate }
+
+func TestGoMigration_Migrate_Gate(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_orders"}}, nil)
+
+	backfill := gatedMigration{dummyMigration{name: "003_backfill_totals"}, MigrationGate{After: "002_add_totals", MinVersion: "v1.8.0"}}
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_orders":   dummyMigration{name: "001_create_orders"},
+			"002_add_totals":      dummyMigration{name: "002_add_totals"},
+			"003_backfill_totals": backfill,
+		},
+		build: &BuildInfo{Version: "v1.7.3"},
+	}
+
+	err := q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrMigrationGateClosed)
+	assert.ErrorContains(t, err, "003_backfill_totals (needs 002_add_totals applied by an earlier run)")
+	assert.ErrorContains(t, err, "003_backfill_totals (needs application version v1.8.0 or later, running v1.7.3)")
+	driver.AssertNotCalled(t, "ApplyMigrations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	q.build = nil
+	err = q.Migrate(ctx)
+	assert.ErrorContains(t, err, "running an unknown version")
+
+	driver.On("ApplyMigrations", runContext(ctx), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	q.build = &BuildInfo{Version: "1.8.0-rc.1"}
+	q.migrations["003_backfill_totals"] = gatedMigration{backfill.dummyMigration, MigrationGate{After: "001_create_orders", MinVersion: "v1.8"}}
+	assert.NoError(t, q.Migrate(ctx))
+}
+
+func TestGoMigration_Migrate_ServerRequirement(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "requirement.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+	driver.SetMigrationTableName("migrations")
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations", migrations: map[string]Migration{
+		"001_create_users": scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER);"},
+		"002_add_settings": scriptMigration{name: "002_add_settings", upScript: "-- gomigration:requires-server >= sqlite 99\n" +
+			"-- gomigration:requires-server >= postgres 14\nALTER TABLE users ADD COLUMN settings JSONB;"},
+	}}
+
+	err = q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrServerVersionUnsupported)
+	assert.ErrorContains(t, err, "002_add_settings (needs sqlite 99 or later, running 3.")
+	assert.NotContains(t, err.Error(), "postgres")
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, status.Pending)
+
+	q.migrations["002_add_settings"] = scriptMigration{name: "002_add_settings", upScript: "-- gomigration:requires-server 14\nSELECT 1;"}
+	assert.ErrorIs(t, q.Migrate(ctx), ErrInvalidMigrationFile)
+
+	q.migrations["002_add_settings"] = scriptMigration{name: "002_add_settings", upScript: "-- gomigration:requires-server >= SQLite 3.8\nALTER TABLE users ADD COLUMN settings TEXT;"}
+	assert.NoError(t, q.Migrate(ctx))
+}
+
+type conditionalMigration struct {
+	scriptMigration
+	shouldRun func(ctx context.Context, d Driver) (bool, error)
+}
+
+func (m conditionalMigration) ShouldRun(ctx context.Context, d Driver) (bool, error) {
+	return m.shouldRun(ctx, d)
+}
+
+func TestGoMigration_Migrate_Conditional(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "conditional.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	hasTable := func(name string) func(ctx context.Context, d Driver) (bool, error) {
+		return func(ctx context.Context, d Driver) (bool, error) {
+			schema, err := d.(SchemaInspector).InspectSchema(ctx)
+			return slices.ContainsFunc(schema, func(table SchemaTable) bool { return table.Name == name }), err
+		}
+	}
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations", migrations: make(map[string]Migration)}
+	driver.SetMigrationTableName(q.migrationTableName)
+	assert.NoError(t, q.Register(
+		scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER);", downScript: "DROP TABLE users;"},
+		conditionalMigration{
+			scriptMigration{name: "002_backfill_legacy", upScript: "INSERT INTO legacy_users SELECT * FROM users;", downScript: "DELETE FROM legacy_users;"},
+			hasTable("legacy_users"),
+		},
+		WithTags(conditionalMigration{
+			scriptMigration{name: "003_index_users", upScript: "CREATE INDEX users_id_idx ON users (id);", downScript: "DROP INDEX users_id_idx;"},
+			hasTable("users"),
+		}, "indexes"),
+	))
+
+	assert.NoError(t, q.Migrate(ctx))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 3) {
+		assert.False(t, executed[0].Skipped)
+		assert.True(t, executed[1].Skipped)
+		assert.Equal(t, 1, executed[1].Batch)
+		assert.False(t, executed[2].Skipped)
+	}
+
+	status, err := q.Status(ctx)
 	assert.NoError(t, err)
+	assert.Equal(t, 3, status.Executed)
+	assert.Equal(t, 1, status.Skipped)
+	assert.True(t, status.UpToDate)
+
+	list, err := q.List(ctx)
+	assert.NoError(t, err)
+	assert.True(t, list[1].IsSkipped)
+	assert.False(t, list[2].IsSkipped)
+
+	// Rolling back the skipped migration must not run its down script, which
+	// would fail on the missing legacy_users table.
+	assert.NoError(t, q.Rollback(ctx, 2))
+	executed, err = driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	assert.NoError(t, err)
+	assert.Len(t, executed, 1)
+
+	q.migrations["002_backfill_legacy"] = conditionalMigration{
+		scriptMigration{name: "002_backfill_legacy", upScript: "SELECT 1;"},
+		func(ctx context.Context, d Driver) (bool, error) {
+			return false, errors.New("flag service unavailable")
+		},
+	}
+	assert.ErrorContains(t, q.Migrate(ctx), "flag service unavailable")
+}
+
+func TestGoMigration_Migrate_LegacyRecordWithoutChecksum(t *testing.T) {
+	ctx := context.TODO()
+	applied := dummyMigration{name: "001_create_users"}
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "001_create_users", ExecutedAt: time.Now()},
+	}, nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{applied.Name(): applied},
+	}
+
+	assert.NoError(t, q.Migrate(ctx))
 	driver.AssertExpectations(t)
 }
 
-func TestGoMigration_Clean_Error(t *testing.T) {
+func TestGoMigration_Migrate_LockNotAcquired(t *testing.T) {
 	ctx := context.TODO()
 	driver := new(mockDriver)
-	driver.On("CleanDatabase", ctx).Return(errors.New("clean error"))
+	driver.On("AcquireLock", ctx).Return(errors.New("lock busy"))
 
 	q := &GoMigration{
-		driver: driver,
+		driver:     driver,
+		migrations: map[string]Migration{},
 	}
 
-	err := q.Clean(ctx)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to clean database")
+	err := q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrLockNotAcquired)
+	driver.AssertNotCalled(t, "CreateMigrationsTable", ctx)
 	driver.AssertExpectations(t)
 }
 
-func TestGoMigration_List(t *testing.T) {
+func TestGoMigration_Rollback_AcquiresLock(t *testing.T) {
 	ctx := context.TODO()
 	driver := new(mockDriver)
-	driver.On("CreateMigrationsTable", ctx).Return(nil)
-	driver.On("GetExecutedMigrations", ctx, false).Return([]ExecutedMigration{{Name: "001_create_users", ExecutedAt: time.Now()}}, nil)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return([]ExecutedMigration{}, nil)
 
-	migration := dummyMigration{name: "001_create_users"}
 	q := &GoMigration{
 		driver:     driver,
-		migrations: map[string]Migration{"001_create_users": migration},
+		migrations: map[string]Migration{},
 	}
 
-	list, err := q.List(ctx)
+	err := q.Rollback(ctx, 1)
 	assert.NoError(t, err)
-	assert.Len(t, list, 1)
-	assert.True(t, list[0].IsExecuted)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Migrate_UsesLocker(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+
+	locker := &fakeLocker{}
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		locker:             locker,
+		lockRenewInterval:  time.Millisecond,
+		migrations:         map[string]Migration{},
+	}
+
+	err := q.withLock(ctx, func() error {
+		// Give the renewal loop a chance to run while the lock is held.
+		time.Sleep(20 * time.Millisecond)
+		return q.migrate(ctx, migrateOptions{})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lock:gomigration:migrations", "unlock:gomigration:migrations"}, locker.calls)
+	assert.Greater(t, locker.renewals.Load(), int32(0))
+	driver.AssertNotCalled(t, "AcquireLock", mock.Anything)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_WithLock_ScopedLocker(t *testing.T) {
+	ctx := context.TODO()
+	locker := &fakeLocker{}
+	q := &GoMigration{
+		driver:             new(mockDriver),
+		migrationTableName: "migrations",
+		lockScope:          "tenant_a",
+		locker:             locker,
+		lockRenewInterval:  time.Hour,
+	}
+
+	assert.NoError(t, q.withLock(ctx, func() error { return nil }))
+	assert.Equal(t, []string{"lock:gomigration:migrations:tenant_a", "unlock:gomigration:migrations:tenant_a"}, locker.calls)
+}
+
+func TestGoMigration_RollbackTo(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Now()
+	// Most recent first, as returned for HistoryOrderAppliedDesc. 002 was
+	// applied after 003, e.g. because it was merged late.
+	executed := []ExecutedMigration{
+		{Name: "002_create_roles", ExecutedAt: now.Add(-1 * time.Minute)},
+		{Name: "003_create_orders", ExecutedAt: now.Add(-2 * time.Minute)},
+		{Name: "001_create_users", ExecutedAt: now.Add(-3 * time.Minute)},
+	}
+	registered := map[string]Migration{
+		"001_create_users":  dummyMigration{name: "001_create_users"},
+		"002_create_roles":  dummyMigration{name: "002_create_roles"},
+		"003_create_orders": dummyMigration{name: "003_create_orders"},
+	}
+
+	tests := []struct {
+		name      string
+		inclusive bool
+		expected  []Migration
+	}{
+		{"exclusive", false, []Migration{registered["002_create_roles"]}},
+		{"inclusive", true, []Migration{registered["002_create_roles"], registered["003_create_orders"]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := new(mockDriver)
+			driver.On("AcquireLock", ctx).Return(nil)
+			driver.On("ReleaseLock", mock.Anything).Return(nil)
+			driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return(append([]ExecutedMigration(nil), executed...), nil)
+			driver.On("UnapplyMigrations", ctx, tt.expected).Return(nil)
+
+			q := &GoMigration{driver: driver, migrations: registered}
+
+			err := q.RollbackTo(ctx, "003_create_orders", tt.inclusive)
+			assert.NoError(t, err)
+			driver.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGoMigration_RollbackTo_NotExecuted(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return([]ExecutedMigration{{Name: "001_create_users"}}, nil)
+
+	q := &GoMigration{driver: driver, migrations: map[string]Migration{}}
+
+	err := q.RollbackTo(ctx, "002_create_roles", false)
+	assert.ErrorIs(t, err, ErrMigrationNotExecuted)
+	assert.Contains(t, err.Error(), "002_create_roles")
+	driver.AssertNotCalled(t, "UnapplyMigrations", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_RollbackLastBatch(t *testing.T) {
+	ctx := context.TODO()
+	registered := map[string]Migration{
+		"001_create_users":  dummyMigration{name: "001_create_users"},
+		"002_create_roles":  dummyMigration{name: "002_create_roles"},
+		"003_create_orders": dummyMigration{name: "003_create_orders"},
+	}
+
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return([]ExecutedMigration{
+		{Name: "003_create_orders", Batch: 2},
+		{Name: "002_create_roles", Batch: 2},
+		{Name: "001_create_users", Batch: 1},
+	}, nil)
+	driver.On("UnapplyMigrations", ctx, []Migration{registered["003_create_orders"], registered["002_create_roles"]}).Return(nil)
+
+	q := &GoMigration{driver: driver, migrations: registered}
+
+	err := q.RollbackLastBatch(ctx)
+	assert.NoError(t, err)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_RollbackLastBatch_NoBatches(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return([]ExecutedMigration{{Name: "001_create_users"}}, nil)
+
+	q := &GoMigration{driver: driver, migrations: map[string]Migration{
+		"001_create_users": dummyMigration{name: "001_create_users"},
+	}}
+
+	err := q.RollbackLastBatch(ctx)
+	assert.NoError(t, err)
+	driver.AssertNotCalled(t, "UnapplyMigrations", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_MigrateSteps(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	users := dummyMigration{name: "001_create_users"}
+	posts := dummyMigration{name: "002_create_posts"}
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+	driver.On("ApplyMigrations", runContext(ctx), []Migration{users}).Return(nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{users.Name(): users, posts.Name(): posts},
+	}
+
+	assert.NoError(t, q.MigrateSteps(ctx, 1))
+	assert.ErrorIs(t, q.MigrateSteps(ctx, 0), ErrInvalidMigrateStep)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Migrate_Phase(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	users := scriptMigration{name: "001_add_users_email", upScript: "ALTER TABLE users ADD email TEXT;"}
+	dropName := scriptMigration{name: "002_drop_users_name", upScript: "ALTER TABLE users DROP name;", phase: PostDeploy}
+	index := scriptMigration{name: "003_index_users_email", upScript: "CREATE INDEX users_email ON users (email);"}
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil).Once()
+	driver.On("ApplyMigrations", runContext(ctx), []Migration{users, index}).Return(nil).Once()
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{users.name: users, dropName.name: dropName, index.name: index},
+		// The post-deploy migration left behind must not fail the next run.
+		outOfOrderPolicy: OutOfOrderFail,
+	}
+
+	assert.NoError(t, q.Migrate(ctx, WithPhase(PreDeploy)))
+
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: users.name, ExecutedAt: time.Now()},
+		{Name: index.name, ExecutedAt: time.Now()},
+	}, nil)
+	driver.On("ApplyMigrations", runContext(ctx), []Migration{dropName}).Return(nil).Once()
+	assert.NoError(t, q.Migrate(ctx, WithPhase(PostDeploy)))
+	driver.AssertExpectations(t)
+
+	assert.ErrorIs(t, q.Migrate(ctx, WithPhase("during-deploy")), ErrUnknownPhase)
+}
+
+func TestSelectMigrations_Phase(t *testing.T) {
+	addColumn := scriptMigration{name: "001_add_column"}
+	dropColumn := scriptMigration{name: "002_drop_column", phase: PostDeploy, dependsOn: []string{"001_add_column"}}
+	backfill := scriptMigration{name: "003_backfill", dependsOn: []string{"002_drop_column"}}
+
+	selected, err := selectMigrations([]Migration{addColumn, dropColumn}, migrateOptions{phase: PreDeploy})
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{addColumn}, selected)
+
+	selected, err = selectMigrations([]Migration{dropColumn}, migrateOptions{phase: PostDeploy})
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{dropColumn}, selected)
+
+	_, err = selectMigrations([]Migration{addColumn, dropColumn}, migrateOptions{phase: PostDeploy})
+	assert.ErrorContains(t, err, "001_add_column (needed by 002_drop_column, not in phase post-deploy)")
+
+	_, err = selectMigrations([]Migration{addColumn, dropColumn, backfill}, migrateOptions{phase: PreDeploy})
+	assert.ErrorIs(t, err, ErrDependencyNotExecuted)
+	assert.ErrorContains(t, err, "002_drop_column (needed by 003_backfill, not in phase pre-deploy)")
+}
+
+func TestSelectMigrations_Tags(t *testing.T) {
+	invoices := WithTags(scriptMigration{name: "001_create_invoices", tags: []string{"billing"}}, "heavy")
+	users := scriptMigration{name: "002_create_users", dependsOn: []string{"001_create_invoices"}}
+	payments := WithTags(scriptMigration{name: "003_create_payments", noTransaction: true, phase: PostDeploy}, "billing")
+	assert.Equal(t, []string{"billing", "heavy"}, migrationTags(invoices))
+	assert.True(t, isNonTransactional(payments))
+	assert.Equal(t, PostDeploy, migrationPhase(payments))
+
+	var options migrateOptions
+	WithTagFilter("billing")(&options)
+	selected, err := selectMigrations([]Migration{invoices, payments}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{invoices, payments}, selected)
+
+	options = migrateOptions{}
+	WithTagFilter("billing", "!heavy")(&options)
+	selected, err = selectMigrations([]Migration{invoices, payments}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{payments}, selected)
+
+	options = migrateOptions{}
+	WithTagFilter("!heavy")(&options)
+	_, err = selectMigrations([]Migration{invoices, users, payments}, options)
+	assert.ErrorIs(t, err, ErrDependencyNotExecuted)
+	assert.ErrorContains(t, err, "001_create_invoices (needed by 002_create_users, tagged heavy)")
+
+	options = migrateOptions{}
+	WithTagFilter("auth")(&options)
+	selected, err = selectMigrations([]Migration{invoices, payments}, options)
+	assert.NoError(t, err)
+	assert.Empty(t, selected)
+	assert.Equal(t, "not tagged auth", options.exclusion(invoices))
+}
+
+func TestGoMigration_MigrateTo_Forward(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	users := dummyMigration{name: "001_create_users"}
+	posts := dummyMigration{name: "002_create_posts"}
+	tags := dummyMigration{name: "003_create_tags"}
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: users.Name(), ExecutedAt: time.Now()},
+	}, nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return([]ExecutedMigration{
+		{Name: users.Name(), ExecutedAt: time.Now()},
+	}, nil)
+	driver.On("ApplyMigrations", runContext(ctx), []Migration{posts}).Return(nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{users.Name(): users, posts.Name(): posts, tags.Name(): tags},
+	}
+
+	assert.NoError(t, q.MigrateTo(ctx, posts.Name()))
+	driver.AssertExpectations(t)
+	driver.AssertNotCalled(t, "UnapplyMigrations", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_MigrateTo_Backward(t *testing.T) {
+	ctx := context.TODO()
+	users := dummyMigration{name: "001_create_users"}
+	posts := dummyMigration{name: "002_create_posts"}
+	tags := dummyMigration{name: "003_create_tags"}
+	executed := []ExecutedMigration{
+		{Name: tags.Name(), ExecutedAt: time.Now()},
+		{Name: posts.Name(), ExecutedAt: time.Now().Add(-time.Minute)},
+		{Name: users.Name(), ExecutedAt: time.Now().Add(-2 * time.Minute)},
+	}
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return(executed, nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return(executed, nil)
+	driver.On("UnapplyMigrations", ctx, []Migration{tags, posts}).Return(nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{users.Name(): users, posts.Name(): posts, tags.Name(): tags},
+	}
+
+	assert.NoError(t, q.MigrateTo(ctx, users.Name()))
+	driver.AssertExpectations(t)
+	driver.AssertNotCalled(t, "ApplyMigrations", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_MigrateTo_NotRegistered(t *testing.T) {
+	q := &GoMigration{driver: new(mockDriver), migrations: map[string]Migration{}}
+
+	assert.ErrorIs(t, q.MigrateTo(context.TODO(), "001_unknown"), ErrMigrationNotRegistered)
+	assert.ErrorIs(t, q.MigrateTo(context.TODO(), ""), ErrMigrationNameNotProvided)
+}
+
+func TestGoMigration_MigrateDryRun(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users"}}, nil)
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_create_roles": dummyMigration{name: "002_create_roles"},
+		},
+	}
+
+	output := captureOutput(func() {
+		assert.NoError(t, q.MigrateDryRun(ctx))
+	})
+
+	assert.Contains(t, output, "CREATE TABLE dummy")
+	driver.AssertNotCalled(t, "AcquireLock", mock.Anything)
+	driver.AssertNotCalled(t, "CreateMigrationsTable", mock.Anything)
+	driver.AssertNotCalled(t, "ApplyMigrations", mock.Anything, mock.Anything)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_RollbackDryRun(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return([]ExecutedMigration{{Name: "001_create_users"}}, nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{"001_create_users": dummyMigration{name: "001_create_users"}},
+	}
+
+	output := captureOutput(func() {
+		assert.NoError(t, q.RollbackDryRun(ctx, 1))
+	})
+
+	assert.Contains(t, output, "DROP TABLE dummy")
+	driver.AssertNotCalled(t, "UnapplyMigrations", mock.Anything, mock.Anything)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_DryRun_FreshDatabase(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "fresh.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users": &scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		},
+	}
+
+	ctx := context.Background()
+	output := captureOutput(func() {
+		assert.NoError(t, q.MigrateDryRun(ctx))
+		assert.NoError(t, q.RollbackDryRun(ctx, 1))
+		assert.NoError(t, q.RollbackLastBatchDryRun(ctx))
+	})
+
+	assert.Contains(t, output, "CREATE TABLE users")
+	exists, err := driver.trackingTableExists(ctx)
+	assert.NoError(t, err)
+	assert.False(t, exists, "a dry run does not create the tracking table")
+}
+
+func TestGoMigration_Plan(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users", Batch: 3}}, nil)
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_it's_quoted":  dummyMigration{name: "002_it's_quoted"},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := q.Plan(ctx, &buf)
+	assert.NoError(t, err)
+
+	plan := buf.String()
+	assert.Contains(t, plan, "-- 1 pending migration(s)")
+	assert.NotContains(t, plan, "-- Migration: 001_create_users")
+	assert.Contains(t, plan, "-- Migration: 002_it's_quoted\nCREATE TABLE dummy (id INT);\n")
+	assert.Contains(t, plan, "INSERT INTO migrations (name, executed_at, checksum, batch, applied_by, namespace, build) VALUES ('002_it''s_quoted', CURRENT_TIMESTAMP, '"+migrationChecksum(dummyMigration{name: "002_it's_quoted"})+"', 4, NULL, NULL, NULL);")
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Plan_FreshDatabase(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "fresh.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users": &scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		},
+	}
+
+	ctx := context.Background()
+	var buf bytes.Buffer
+	assert.NoError(t, q.Plan(ctx, &buf))
+
+	plan := buf.String()
+	assert.Contains(t, plan, "-- Tracking table\nCREATE TABLE IF NOT EXISTS \"migrations\" (")
+	assert.Contains(t, plan, "CREATE INDEX migrations_executed_at_idx ON \"migrations\" (executed_at, name);")
+	assert.Less(t, strings.Index(plan, "CREATE TABLE IF NOT EXISTS"), strings.Index(plan, "INSERT INTO"))
+
+	// Planning only reads; running the plan creates and fills the table.
+	exists, err := driver.trackingTableExists(ctx)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	_, err = driver.db.ExecContext(ctx, plan)
+	assert.NoError(t, err)
+	upToDate, err := q.IsUpToDate(ctx)
+	assert.NoError(t, err)
+	assert.True(t, upToDate)
+}
+
+func TestGoMigration_Plan_TemplateData(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+
+	migration := scriptMigration{name: "001_create_users", upScript: "CREATE TABLE {{ .Schema }}.users (id INTEGER);"}
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations:         map[string]Migration{migration.name: migration},
+		templateData:       map[string]any{"Schema": "staging"},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, q.Plan(ctx, &buf))
+	assert.Contains(t, buf.String(), "-- Migration: 001_create_users\nCREATE TABLE staging.users (id INTEGER);\n")
+	assert.Contains(t, buf.String(), "'"+migrationChecksum(migration)+"'")
+}
+
+func TestGoMigration_Fresh_Success(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("CleanDatabase", ctx).Return(nil)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{},
+	}
+
+	err := q.Fresh(ctx)
+	assert.NoError(t, err)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Reset_NoExecuted(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderAppliedDesc).Return([]ExecutedMigration{}, nil)
+
+	q := &GoMigration{
+		driver: driver,
+	}
+
+	err := q.Reset(ctx)
+	assert.NoError(t, err)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Clean_Error(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("CleanDatabase", ctx).Return(errors.New("clean error"))
+
+	q := &GoMigration{
+		driver: driver,
+	}
+
+	err := q.Clean(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to clean database")
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Clean_ProgressEvents(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "clean.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+	driver.cleanBatchSize = 2
+
+	_, err = driver.db.ExecContext(ctx, "CREATE TABLE a (id INTEGER); CREATE TABLE b (id INTEGER); CREATE TABLE c (id INTEGER);")
+	assert.NoError(t, err)
+
+	q := &GoMigration{driver: driver}
+	var events []Event
+	q.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+		events = append(events, event)
+	}))
+
+	assert.NoError(t, q.Clean(ctx))
+	assert.Len(t, events, 2)
+	for i, dropped := range []int{2, 3} {
+		assert.Equal(t, EventTablesDropped, events[i].Type)
+		assert.Equal(t, OperationClean, events[i].Operation)
+		assert.Equal(t, 3, events[i].Total)
+		assert.Equal(t, dropped, events[i].Dropped)
+	}
+	assert.Len(t, events[1].Tables, 1)
+}
+
+func TestGoMigration_FreshWithOptions_KeepHistory(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "history.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations", migrations: make(map[string]Migration)}
+	driver.SetMigrationTableName(q.migrationTableName)
+	assert.NoError(t, q.Register(scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER);", downScript: "DROP TABLE users;"}))
+	assert.NoError(t, q.Migrate(ctx))
+	assert.NoError(t, driver.InsertExecutedMigration(ctx, ExecutedMigration{Name: "000_squashed", ExecutedAt: time.Now()}))
+
+	tables := func() []string {
+		schema, err := driver.InspectSchema(ctx)
+		assert.NoError(t, err)
+		var names []string
+		for _, table := range schema {
+			names = append(names, table.Name)
+		}
+		return names
+	}
+
+	assert.NoError(t, q.CleanWithOptions(ctx, CleanOptions{KeepHistory: true}))
+	assert.NotContains(t, tables(), "users")
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderName)
+	assert.NoError(t, err)
+	assert.Len(t, executed, 2)
+
+	assert.NoError(t, q.FreshWithOptions(ctx, CleanOptions{KeepHistory: true}))
+	assert.Contains(t, tables(), "users")
+	executed, err = driver.GetExecutedMigrations(ctx, HistoryOrderName)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 2) {
+		assert.Equal(t, "000_squashed", executed[0].Name)
+		assert.Equal(t, "001_create_users", executed[1].Name)
+		assert.Equal(t, 1, executed[1].Batch)
+	}
+
+	q = &GoMigration{driver: new(mockDriver)}
+	assert.ErrorIs(t, q.CleanWithOptions(ctx, CleanOptions{KeepHistory: true}), ErrKeepHistoryNotSupported)
+}
+
+func TestGoMigration_Clean_AuditLog(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(auditMockDriver)
+	driver.On("CleanDatabase", ctx).Return(errors.New("clean error"))
+	driver.On("AppendAuditEvent", ctx, mock.MatchedBy(func(event AuditEvent) bool {
+		return event.Operation == OperationClean &&
+			event.Actor == "ci" &&
+			event.Outcome == AuditOutcomeFailed &&
+			event.Error == "clean error"
+	})).Return(nil)
+
+	q := &GoMigration{driver: driver, appliedBy: "ci", auditLog: true}
+
+	assert.Error(t, q.Clean(ctx))
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_MarkApplied(t *testing.T) {
+	ctx := context.TODO()
+	users := dummyMigration{name: "001_create_users"}
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+	driver.On("InsertExecutedMigration", ctx, mock.MatchedBy(func(record ExecutedMigration) bool {
+		return record.Name == users.Name() && record.Checksum == migrationChecksum(users) && !record.ExecutedAt.IsZero()
+	})).Return(nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{users.Name(): users},
+	}
+
+	assert.NoError(t, q.MarkApplied(ctx, users.Name()))
+	assert.ErrorIs(t, q.MarkApplied(ctx, "002_unknown"), ErrMigrationNotRegistered)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_MarkUnapplied(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "001_deleted", ExecutedAt: time.Now()},
+	}, nil)
+	driver.On("RemoveExecutedMigration", ctx, "001_deleted").Return(nil).Once()
+
+	q := &GoMigration{driver: driver, migrations: map[string]Migration{}}
+
+	assert.NoError(t, q.MarkUnapplied(ctx, "001_deleted"))
+	assert.NoError(t, q.MarkUnapplied(ctx, "002_never_applied"))
+	driver.AssertExpectations(t)
+}
+
+// bareDriver hides the optional interfaces of the wrapped driver.
+type bareDriver struct {
+	Driver
+}
+
+func TestGoMigration_ErrorNoHistoryWriter(t *testing.T) {
+	ctx := context.TODO()
+	users := dummyMigration{name: "001_create_users"}
+	driver := new(mockDriver)
+
+	q := &GoMigration{
+		driver:     bareDriver{driver},
+		migrations: map[string]Migration{users.Name(): users},
+	}
+
+	var capability *CapabilityError
+	err := q.MarkApplied(ctx, users.Name())
+	assert.ErrorIs(t, err, ErrNotSupported)
+	if assert.ErrorAs(t, err, &capability) {
+		assert.Equal(t, "HistoryWriter", capability.Capability)
+	}
+	assert.ErrorIs(t, q.MarkUnapplied(ctx, users.Name()), ErrNotSupported)
+	_, err = q.Repair(ctx)
+	assert.ErrorIs(t, err, ErrNotSupported)
+	assert.ErrorIs(t, q.NamespaceHistory(ctx), ErrNotSupported)
+	assert.ErrorIs(t, q.ImportHistory(ctx, strings.NewReader(`{"migrations": []}`)), ErrNotSupported)
+
+	// The database is not cleaned when its history could not be kept.
+	assert.ErrorIs(t, q.FreshWithOptions(ctx, CleanOptions{KeepHistory: true}), ErrNotSupported)
+	driver.AssertNotCalled(t, "AcquireLock", mock.Anything)
+	driver.AssertNotCalled(t, "CleanDatabase", mock.Anything)
+}
+
+func TestGoMigration_Repair(t *testing.T) {
+	ctx := context.TODO()
+	executedAt := time.Date(2025, 4, 18, 22, 0, 0, 0, time.UTC)
+	users := dummyMigration{name: "001_create_users"}
+	posts := dummyMigration{name: "002_create_posts"}
+	tags := dummyMigration{name: "004_create_tags"}
+
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderName).Return([]ExecutedMigration{
+		{Name: "001_create_users", ExecutedAt: executedAt, Checksum: migrationChecksum(users)},
+		{Name: "002_create_posts", ExecutedAt: executedAt.Add(time.Minute), Checksum: "edited"},
+		{Name: "003_deleted", ExecutedAt: executedAt.Add(2 * time.Minute)},
+		{Name: "004_create_tags"},
+	}, nil)
+	driver.On("UpdateExecutedMigration", ctx, ExecutedMigration{
+		Name: "002_create_posts", ExecutedAt: executedAt.Add(time.Minute), Checksum: migrationChecksum(posts),
+	}).Return(nil)
+	driver.On("RemoveExecutedMigration", ctx, "003_deleted").Return(nil)
+	driver.On("UpdateExecutedMigration", ctx, ExecutedMigration{
+		Name: "004_create_tags", ExecutedAt: executedAt.Add(time.Minute), Checksum: migrationChecksum(tags),
+	}).Return(nil)
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			users.Name(): users,
+			posts.Name(): posts,
+			tags.Name():  tags,
+		},
+	}
+
+	report, err := q.Repair(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []RepairActionType{
+		RepairChecksumUpdated,
+		RepairRecordRemoved,
+		RepairChecksumUpdated,
+		RepairExecutedAtFixed,
+	}, []RepairActionType{report[0].Type, report[1].Type, report[2].Type, report[3].Type})
+	assert.Equal(t, "003_deleted", report[1].Migration)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Validate(t *testing.T) {
+	ctx := context.TODO()
+	executedAt := time.Date(2025, 4, 18, 22, 0, 11, 0, time.UTC)
+	users := dummyMigration{name: "001_create_users"}
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderName).Return([]ExecutedMigration{
+		{Name: "000_removed", ExecutedAt: executedAt},
+		{Name: users.Name(), ExecutedAt: executedAt, Checksum: "edited"},
+	}, nil)
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			users.Name():       users,
+			"002_create_posts": dummyMigration{name: "002_create_posts"},
+			"002_create_tags":  emptyDummyMigration{dummyMigration{name: "002_create_tags"}},
+			"003_backfill":     scriptMigration{name: "003_backfill", upScript: "SELECT 1;", dependsOn: []string{"000_removed", "001_unknown"}},
+			"004_a":            scriptMigration{name: "004_a", upScript: "SELECT 1;", dependsOn: []string{"005_b"}},
+			"005_b":            scriptMigration{name: "005_b", upScript: "SELECT 1;", dependsOn: []string{"004_a"}},
+		},
+	}
+
+	report, err := q.Validate(ctx)
+	assert.NoError(t, err)
+	assert.False(t, report.Valid())
+	assert.Equal(t, ValidationReport{
+		{Migration: "000_removed", Type: ValidationNotRegistered, Detail: "executed at 2025-04-18T22:00:11Z"},
+		{Migration: users.Name(), Type: ValidationChecksumMismatch, Detail: "edited -> " + migrationChecksum(users)},
+		{Migration: "002_create_tags", Type: ValidationEmptyUpScript},
+		{Migration: "002_create_posts", Type: ValidationDuplicatePrefix, Detail: "prefix 002 shared by 002_create_posts, 002_create_tags"},
+		{Migration: "002_create_tags", Type: ValidationDuplicatePrefix, Detail: "prefix 002 shared by 002_create_posts, 002_create_tags"},
+		{Migration: "003_backfill", Type: ValidationUnknownDependency, Detail: "001_unknown"},
+		{Migration: "004_a", Type: ValidationDependencyCycle, Detail: "depends on 005_b"},
+		{Migration: "005_b", Type: ValidationDependencyCycle, Detail: "depends on 004_a"},
+	}, report)
+	driver.AssertNotCalled(t, "CreateMigrationsTable", mock.Anything)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_Validate_FreshDatabase(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "fresh.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users": &scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		},
+	}
+
+	ctx := context.Background()
+	report, err := q.Validate(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid())
+
+	exists, err := driver.trackingTableExists(ctx)
+	assert.NoError(t, err)
+	assert.False(t, exists, "validating does not create the tracking table")
+}
+
+func TestGoMigration_PendingMigrations_Dependencies(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users"}}, nil)
+
+	q := &GoMigration{driver: driver, migrations: make(map[string]Migration)}
+	assert.NoError(t, q.Register(
+		scriptMigration{name: "001_create_users"},
+		scriptMigration{name: "002_backfill_orders", dependsOn: []string{"003_create_orders", "001_create_users"}},
+		scriptMigration{name: "003_create_orders"},
+	))
+	assert.NoError(t, q.RegisterSet(MigrationSet{Name: "billing", Migrations: []Migration{
+		scriptMigration{name: "001_invoices", dependsOn: []string{"002_customers"}},
+		scriptMigration{name: "002_customers"},
+	}}))
+
+	pending, err := q.pendingMigrations(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"billing/002_customers", "billing/001_invoices", "003_create_orders", "002_backfill_orders"}, migrationNames(pending))
+
+	q.migrations["004_report"] = scriptMigration{name: "004_report", dependsOn: []string{"005_missing"}}
+	_, err = q.pendingMigrations(ctx)
+	assert.ErrorIs(t, err, ErrDependencyNotExecuted)
+	assert.ErrorContains(t, err, "005_missing (needed by 004_report)")
+
+	q.migrations["004_report"] = scriptMigration{name: "004_report", dependsOn: []string{"005_summary"}}
+	q.migrations["005_summary"] = scriptMigration{name: "005_summary", dependsOn: []string{"004_report"}}
+	_, err = q.pendingMigrations(ctx)
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+	assert.ErrorContains(t, err, "004_report, 005_summary")
+}
+
+type emptyDummyMigration struct {
+	dummyMigration
+}
+
+func (emptyDummyMigration) UpScript() string {
+	return "  "
+}
+
+func TestGoMigration_UpgradeTrackingTable(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("UpgradeMigrationsTable", ctx).Return(errors.New("boom"))
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations"}
+
+	err := q.UpgradeTrackingTable(ctx)
+	assert.ErrorContains(t, err, "failed to upgrade tracking table: boom")
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_UpgradeTrackingTable_NotSupported(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	q := &GoMigration{driver: bareDriver{driver}, migrationTableName: "migrations"}
+
+	err := q.UpgradeTrackingTable(ctx)
+	var capability *CapabilityError
+	if assert.ErrorAs(t, err, &capability) {
+		assert.Equal(t, "TrackingTableUpgrader", capability.Capability)
+	}
+	driver.AssertNotCalled(t, "AcquireLock", mock.Anything)
+
+	cli, err := NewCli(CliConfig{GoMigration: q, Locale: "en"})
+	assert.NoError(t, err)
+	cmd := cli.UpgradeTrackingTableCommand(ctx)
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{})
+	assert.NoError(t, cmd.Execute())
+	assert.Contains(t, stderr.String(), "The driver cannot upgrade the tracking table: it does not implement TrackingTableUpgrader")
+}
+
+func TestGoMigration_List(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("IterateExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "000_removed_long_ago", ExecutedAt: time.Now()},
+		{Name: "001_create_users", ExecutedAt: time.Now()},
+	}, nil)
+
+	migration := dummyMigration{name: "001_create_users"}
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_users": migration,
+			"002_create_roles": dummyMigration{name: "002_create_roles"},
+		},
+	}
+
+	list, err := q.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.True(t, list[0].IsExecuted)
+	assert.False(t, list[1].IsExecuted)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_List_MigrationOrder(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("IterateExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_create_roles": dummyMigration{name: "002_create_roles"},
+		},
+	}
+	assert.NoError(t, q.LoadMigrationOrder(strings.NewReader("002_create_roles\n001_create_users\n")))
+
+	list, err := q.List(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "002_create_roles", list[0].Name)
+	assert.Equal(t, "001_create_users", list[1].Name)
+}
+
+func TestGoMigration_Migrations(t *testing.T) {
+	q := &GoMigration{migrations: map[string]Migration{}}
+	assert.NoError(t, q.Register(
+		WithTags(describedDummyMigration{dummyMigration{name: "002_create_roles"}}, "auth"),
+		dummyMigration{name: "001_create_users"},
+	))
+
+	infos := q.Migrations()
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "001_create_users", infos[0].Name)
+	assert.Equal(t, "gomigration.dummyMigration", infos[0].Source)
+	assert.Equal(t, migrationChecksum(dummyMigration{}), infos[0].Checksum)
+	assert.Contains(t, infos[0].RegisteredFrom, "gomigration_test.go:")
+	assert.Empty(t, infos[0].Description)
+	assert.Equal(t, "002_create_roles", infos[1].Name)
+	assert.Equal(t, "Creates the roles table", infos[1].Description)
+	assert.Equal(t, []string{"auth"}, infos[1].Tags)
+	assert.Equal(t, "gomigration.describedDummyMigration", infos[1].Source)
+}
+
+type describedDummyMigration struct {
+	dummyMigration
+}
+
+func (d describedDummyMigration) Description() string {
+	return "Creates the roles table"
+}
+
+func TestGoMigration_MigrationOrderMismatch(t *testing.T) {
+	q := &GoMigration{
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_create_roles": dummyMigration{name: "002_create_roles"},
+		},
+	}
+	assert.NoError(t, q.LoadMigrationOrder(strings.NewReader("001_create_users\n003_create_posts\n")))
+
+	_, err := q.orderedMigrationNames()
+	assert.ErrorIs(t, err, ErrMigrationOrderMismatch)
+	assert.ErrorContains(t, err, "not listed: 002_create_roles; not registered: 003_create_posts")
+}
+
+func TestGoMigration_Squash(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "squash.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	users := scriptMigration{name: "20250101000000_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT);", downScript: "DROP TABLE users;"}
+	posts := scriptMigration{
+		name:       "20250102000000_create_posts",
+		upScript:   "CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL REFERENCES users (id));\nALTER TABLE users ADD COLUMN name VARCHAR(100) NOT NULL DEFAULT '';",
+		downScript: "ALTER TABLE users DROP COLUMN name;\nDROP TABLE posts;",
+	}
+	tags := scriptMigration{name: "20250103000000_create_tags", upScript: "CREATE TABLE tags (id INTEGER);", downScript: "DROP TABLE tags;"}
+
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	for _, m := range []scriptMigration{users, posts, tags} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, m.name+".go"), []byte("package migrations\n"), 0644))
+	}
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationFilesDir:  dir,
+		migrationTableName: "migrations",
+		migrations:         map[string]Migration{users.name: users, posts.name: posts, tags.name: tags},
+	}
+	driver.SetMigrationTableName(q.migrationTableName)
+	assert.NoError(t, q.MigrateTo(ctx, posts.name))
+
+	assert.NoError(t, q.Squash(ctx, posts.name))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 1) {
+		assert.Equal(t, "20250102000000_create_posts_squashed", executed[0].Name)
+		assert.Equal(t, 1, executed[0].Batch)
+	}
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{"20250102000000_create_posts_squashed.go", "20250103000000_create_tags.go"}, names)
+
+	code, err := os.ReadFile(filepath.Join(dir, "20250102000000_create_posts_squashed.go"))
+	assert.NoError(t, err)
+	// The baseline creates the squashed tables as they are in the database.
+	assert.Contains(t, string(code), "CREATE TABLE \"users\" (\n\t\"id\" INTEGER,\n\t\"email\" TEXT,\n\t\"name\" VARCHAR(100) NOT NULL,\n\tPRIMARY KEY (\"id\")\n);\n\n"+
+		"CREATE TABLE \"posts\" (\n\t\"id\" INTEGER,\n\t\"user_id\" INTEGER NOT NULL,\n\tPRIMARY KEY (\"id\"),\n\tFOREIGN KEY (\"user_id\") REFERENCES \"users\" (\"id\")\n);")
+	assert.Contains(t, string(code), "DROP TABLE \"posts\";\nDROP TABLE \"users\";")
+
+	// The baseline is registered in place of the squashed migrations.
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, MigrationStatus{Executed: 1, Pending: 1, LastExecuted: &executed[0]}, status)
+}
+
+func TestGoMigration_Squash_SQLFiles(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "squash.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	for file, script := range map[string]string{
+		"20250101000000_create_users.up.sql":        "CREATE TABLE users (id INTEGER);",
+		"20250101000000_create_users.down.sql":      "DROP TABLE users;",
+		"20250101000000_create_users.mysql.up.sql":  "CREATE TABLE users (id INT);",
+		"20250102000000_create_posts.sql":           "-- +migrate Up\nCREATE TABLE posts (id INTEGER);\n-- +migrate Down\nDROP TABLE posts;\n",
+		"20250103000000_create_tags.up.sql":         "CREATE TABLE tags (id INTEGER);",
+		"20250101000000_create_users_notes.txt":     "not a migration",
+		"20250102000000_create_posts_backfill.sql":  "-- +migrate Up\nSELECT 1;\n",
+		"20250102000000_create_posts_backfill.keep": "",
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(script), 0644))
+	}
+
+	q := &GoMigration{driver: driver, migrationFilesDir: dir, migrationTableName: "migrations", migrations: map[string]Migration{}}
+	driver.SetMigrationTableName(q.migrationTableName)
+	assert.NoError(t, q.LoadFromDir())
+	assert.NoError(t, q.MigrateTo(ctx, "20250102000000_create_posts_backfill"))
+
+	assert.NoError(t, q.Squash(ctx, "20250102000000_create_posts_backfill"))
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{
+		"20250101000000_create_users_notes.txt",
+		"20250102000000_create_posts_backfill.keep",
+		"20250102000000_create_posts_backfill_squashed.go",
+		"20250103000000_create_tags.up.sql",
+	}, names)
+
+	// Loading the directory again finds nothing squashed to apply again.
+	reloaded := &GoMigration{driver: driver, migrationFilesDir: dir, migrationTableName: "migrations", migrations: map[string]Migration{}}
+	assert.NoError(t, reloaded.LoadFromDir())
+	assert.Equal(t, []string{"20250103000000_create_tags"}, slices.Sorted(maps.Keys(reloaded.migrations)))
+}
+
+func TestGoMigration_Squash_ErrorLoadedFromFS(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+
+	q := &GoMigration{driver: driver, migrationFilesDir: t.TempDir(), migrations: map[string]Migration{}}
+	assert.NoError(t, q.LoadFromFS(fstest.MapFS{
+		"migrations/20250101000000_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INTEGER);")},
+	}, "migrations"))
+
+	err := q.Squash(ctx, "20250101000000_create_users")
+	assert.ErrorIs(t, err, ErrSquashNotSupported)
+	assert.ErrorContains(t, err, "20250101000000_create_users is registered from migrations by LoadFromFS")
+	driver.AssertNotCalled(t, "AcquireLock", mock.Anything)
+}
+
+func TestGoMigration_Squash_ErrorPending(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(erMockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "20250101000000_create_users", Checksum: migrationChecksum(dummyMigration{name: "20250101000000_create_users"})},
+	}, nil)
+
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	q := &GoMigration{
+		driver:            driver,
+		migrationFilesDir: dir,
+		migrations: map[string]Migration{
+			"20250101000000_create_users": dummyMigration{name: "20250101000000_create_users"},
+			"20250102000000_create_posts": dummyMigration{name: "20250102000000_create_posts"},
+		},
+	}
+
+	err := q.Squash(ctx, "20250102000000_create_posts")
+	assert.ErrorIs(t, err, ErrMigrationNotExecuted)
+	assert.ErrorContains(t, err, "20250102000000_create_posts")
+	driver.AssertNotCalled(t, "InsertExecutedMigration", mock.Anything, mock.Anything)
+
+	assert.ErrorIs(t, q.Squash(ctx, "003_unknown"), ErrMigrationNotRegistered)
+}
+
+func TestGoMigration_Squash_RestoresHistory(t *testing.T) {
+	ctx := context.TODO()
+	usersMigration := scriptMigration{name: "20250101000000_create_users", upScript: "CREATE TABLE users (id INTEGER);"}
+	postsMigration := scriptMigration{name: "20250102000000_create_posts", upScript: "CREATE TABLE posts (id INTEGER);"}
+	users := ExecutedMigration{Name: usersMigration.name, Batch: 1, Checksum: migrationChecksum(usersMigration)}
+	posts := ExecutedMigration{Name: postsMigration.name, Batch: 1, Checksum: migrationChecksum(postsMigration)}
+	driver := new(erMockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{users, posts}, nil)
+	driver.On("InspectER", ctx).Return(ERModel{Tables: []ERTable{
+		{Name: "posts", Columns: []ERColumn{{Name: "id", Type: "INTEGER", Nullable: true}}},
+		{Name: "users", Columns: []ERColumn{{Name: "id", Type: "INTEGER", Nullable: true}}},
+	}}, nil)
+	driver.On("InsertExecutedMigration", ctx, mock.MatchedBy(func(record ExecutedMigration) bool {
+		return record.Name == "20250102000000_create_posts_squashed"
+	})).Return(nil).Once()
+	driver.On("RemoveExecutedMigration", ctx, users.Name).Return(nil).Once()
+	driver.On("RemoveExecutedMigration", ctx, posts.Name).Return(errors.New("connection lost")).Once()
+	driver.On("InsertExecutedMigration", ctx, users).Return(nil).Once()
+	driver.On("RemoveExecutedMigration", ctx, "20250102000000_create_posts_squashed").Return(nil).Once()
+
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	q := &GoMigration{
+		driver:            driver,
+		migrationFilesDir: dir,
+		migrations: map[string]Migration{
+			users.Name: usersMigration,
+			posts.Name: postsMigration,
+		},
+	}
+
+	err := q.Squash(ctx, posts.Name)
+	assert.ErrorContains(t, err, "failed to remove record of 20250102000000_create_posts: connection lost")
+	driver.AssertExpectations(t)
+
+	// The history, the files and the registry are left as they were.
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Equal(t, []string{users.Name, posts.Name}, slices.Sorted(maps.Keys(q.migrations)))
+}
+
+func TestGoMigration_Squash_ErrorNotInspectable(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+
+	q := &GoMigration{
+		driver:            driver,
+		migrationFilesDir: t.TempDir(),
+		migrations:        map[string]Migration{"20250101000000_create_users": dummyMigration{name: "20250101000000_create_users"}},
+	}
+
+	err := q.Squash(ctx, "20250101000000_create_users")
+	assert.ErrorIs(t, err, ErrSquashNotSupported)
+	assert.ErrorContains(t, err, "driver cannot inspect the schema")
+	driver.AssertNotCalled(t, "AcquireLock", mock.Anything)
+}
+
+func TestGoMigration_Squash_ErrorAppliedAfter(t *testing.T) {
+	ctx := context.TODO()
+	users := dummyMigration{name: "20250101000000_create_users"}
+	posts := dummyMigration{name: "20250102000000_create_posts"}
+	driver := new(erMockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: users.name, Checksum: migrationChecksum(users)},
+		{Name: posts.name, Checksum: migrationChecksum(posts)},
+	}, nil)
+
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	q := &GoMigration{driver: driver, migrationFilesDir: dir, migrations: map[string]Migration{users.name: users, posts.name: posts}}
+
+	err := q.Squash(ctx, users.name)
+	assert.ErrorIs(t, err, ErrSquashNotSupported)
+	assert.ErrorContains(t, err, "20250102000000_create_posts is applied after 20250101000000_create_users")
+	driver.AssertNotCalled(t, "InspectER", mock.Anything)
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestGoMigration_ExportImportHistory(t *testing.T) {
+	ctx := context.Background()
+	users := scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER);", downScript: "DROP TABLE users;"}
+	posts := scriptMigration{name: "002_create_posts", upScript: "CREATE TABLE posts (id INTEGER);", downScript: "DROP TABLE posts;"}
+
+	newSqlite := func(name string) (*GoMigration, *SqliteDriver) {
+		driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), name))
+		assert.NoError(t, err)
+		t.Cleanup(func() { driver.Close() })
+		driver.SetMigrationTableName("migrations")
+		return &GoMigration{
+			driver:             driver,
+			migrationTableName: "migrations",
+			migrations:         map[string]Migration{users.name: users, posts.name: posts},
+		}, driver
+	}
+
+	source, sourceDriver := newSqlite("source.db")
+	assert.NoError(t, source.Migrate(ctx))
+	var export bytes.Buffer
+	assert.NoError(t, source.ExportHistory(ctx, &export))
+
+	target, targetDriver := newSqlite("target.db")
+	assert.NoError(t, targetDriver.CreateMigrationsTable(ctx))
+	assert.NoError(t, targetDriver.InsertExecutedMigration(ctx, ExecutedMigration{Name: users.name, ExecutedAt: time.Now()}))
+	assert.NoError(t, target.ImportHistory(ctx, &export))
+
+	expected, err := sourceDriver.GetExecutedMigrations(ctx, HistoryOrderName)
+	assert.NoError(t, err)
+	imported, err := targetDriver.GetExecutedMigrations(ctx, HistoryOrderName)
+	assert.NoError(t, err)
+	if assert.Len(t, imported, 2) {
+		assert.Empty(t, imported[0].Checksum, "already recorded migrations are kept")
+		assert.Equal(t, expected[1].Checksum, imported[1].Checksum)
+		assert.True(t, expected[1].ExecutedAt.Equal(imported[1].ExecutedAt))
+		assert.Equal(t, expected[1].Batch, imported[1].Batch)
+	}
+}
+
+func TestGoMigration_ImportHistory_Invalid(t *testing.T) {
+	q := &GoMigration{driver: new(mockDriver)}
+
+	for _, document := range []string{
+		`not json`,
+		`{"migrations": [{"executed_at": "2025-04-18T22:00:00Z"}]}`,
+		`{"migrations": [{"name": "001_create_users"}]}`,
+		`{"migrations": [{"name": "001_create_users", "executed_at": "2025-04-18T22:00:00Z"}, {"name": "001_create_users", "executed_at": "2025-04-18T22:00:00Z"}]}`,
+	} {
+		err := q.ImportHistory(context.TODO(), strings.NewReader(document))
+		assert.ErrorIs(t, err, ErrInvalidHistory, document)
+	}
+}
+
+func TestGoMigration_Status(t *testing.T) {
+	ctx := context.TODO()
+	executedAt := time.Now()
+	driver := new(mockDriver)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("IterateExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "001_create_users", ExecutedAt: executedAt.Add(-time.Minute)},
+		{Name: "000_removed", ExecutedAt: executedAt},
+	}, nil)
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_create_posts": dummyMigration{name: "002_create_posts"},
+		},
+	}
+
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, status.Executed)
+	assert.Equal(t, 1, status.Pending)
+	assert.Equal(t, 1, status.Unknown)
+	assert.Equal(t, "000_removed", status.LastExecuted.Name)
+	assert.False(t, status.UpToDate)
+}
+
+func TestGoMigration_HasPending_ManifestHashMatches(t *testing.T) {
+	ctx := context.TODO()
+	migrations := map[string]Migration{
+		"001_create_users": dummyMigration{name: "001_create_users"},
+	}
+	driver := new(manifestMockDriver)
+	driver.On("GetManifestHash", ctx).Return(manifestHash(migrations), nil)
+
+	q := &GoMigration{driver: driver, migrations: migrations}
+
+	pending, err := q.HasPending(ctx)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	driver.AssertNotCalled(t, "GetExecutedMigrations", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_HasPending_FullComparison(t *testing.T) {
+	ctx := context.TODO()
+	migrations := map[string]Migration{
+		"001_create_users": dummyMigration{name: "001_create_users"},
+		"002_create_posts": dummyMigration{name: "002_create_posts"},
+	}
+	driver := new(manifestMockDriver)
+	driver.On("GetManifestHash", ctx).Return("stale", nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users"}}, nil).Once()
+
+	q := &GoMigration{driver: driver, migrations: migrations}
+
+	pending, err := q.HasPending(ctx)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	driver.AssertNotCalled(t, "SetManifestHash", mock.Anything, mock.Anything)
+
+	// Once everything is executed the hash is stored for the next check.
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users"}, {Name: "002_create_posts"}}, nil).Once()
+	driver.On("SetManifestHash", ctx, manifestHash(migrations)).Return(nil)
+
+	pending, err = q.HasPending(ctx)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_IsUpToDate(t *testing.T) {
+	ctx := context.TODO()
+	migrations := map[string]Migration{
+		"001_create_users": dummyMigration{name: "001_create_users"},
+		"002_create_posts": dummyMigration{name: "002_create_posts"},
+	}
+	driver := new(manifestMockDriver)
+	driver.On("GetManifestHash", ctx).Return("stale", nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users"}}, nil).Once()
+
+	q := &GoMigration{driver: driver, migrations: migrations}
+
+	upToDate, err := q.IsUpToDate(ctx)
+	assert.NoError(t, err)
+	assert.False(t, upToDate)
+
+	// Migrations applied by a newer release are not held against it.
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_create_users"}, {Name: "002_create_posts"}, {Name: "003_create_tags"}}, nil).Once()
+
+	upToDate, err = q.IsUpToDate(ctx)
+	assert.NoError(t, err)
+	assert.True(t, upToDate)
+	driver.AssertNotCalled(t, "CreateMigrationsTable", mock.Anything)
+	driver.AssertNotCalled(t, "SetManifestHash", mock.Anything, mock.Anything)
+}
+
+func TestGoMigration_IsUpToDate_FreshDatabase(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "fresh.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users": &scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		},
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, q.Ping(ctx))
+	upToDate, err := q.IsUpToDate(ctx)
+	assert.NoError(t, err)
+	assert.False(t, upToDate)
+
+	exists, err := driver.trackingTableExists(ctx)
+	assert.NoError(t, err)
+	assert.False(t, exists, "the probe does not create the tracking table")
+}
+
+func TestGoMigration_Ping(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "ping.db"))
+	assert.NoError(t, err)
+
+	q := &GoMigration{driver: driver, migrations: map[string]Migration{}}
+	assert.NoError(t, q.Ping(context.Background()))
+
+	assert.NoError(t, driver.Close())
+	assert.Error(t, q.Ping(context.Background()))
+}
+
+func TestGoMigration_Migrate_ManifestHash(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	migrations := map[string]Migration{
+		"001_create_users": dummyMigration{name: "001_create_users"},
+	}
+	driver := new(manifestMockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("SetManifestHash", ctx, "").Return(nil).Once()
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+	driver.On("ApplyMigrations", runContext(ctx), []Migration{migrations["001_create_users"]}).Return(nil)
+	driver.On("SetManifestHash", ctx, manifestHash(migrations)).Return(nil).Once()
+
+	q := &GoMigration{driver: driver, migrations: migrations}
+
+	err := q.Migrate(ctx)
+	assert.NoError(t, err)
+	driver.AssertExpectations(t)
+}
+
+func TestGoMigration_ExecutedMigrationsPage(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	page := []ExecutedMigration{{Name: "003_create_orders"}}
+	driver.On("GetExecutedMigrationsPage", ctx, HistoryOrderApplied, 1, 2).Return(page, nil)
+
+	q := &GoMigration{driver: driver}
+
+	result, err := q.ExecutedMigrationsPage(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, page, result)
+
+	_, err = q.ExecutedMigrationsPage(ctx, 0, 0)
+	assert.Error(t, err)
 	driver.AssertExpectations(t)
 }
 
@@ -157,6 +2267,27 @@ func TestSetMigrationFilesDir(t *testing.T) {
 	assert.Equal(t, "migrations", q.migrationFilesDir)
 }
 
+// fakeLocker records Locker calls and counts lease renewals.
+type fakeLocker struct {
+	calls    []string
+	renewals atomic.Int32
+}
+
+func (l *fakeLocker) Lock(ctx context.Context, key string) error {
+	l.calls = append(l.calls, "lock:"+key)
+	return nil
+}
+
+func (l *fakeLocker) Unlock(ctx context.Context, key string) error {
+	l.calls = append(l.calls, "unlock:"+key)
+	return nil
+}
+
+func (l *fakeLocker) Renew(ctx context.Context, key string) error {
+	l.renewals.Add(1)
+	return nil
+}
+
 // dummyMigration is a simple implementation of the Migration interface for testing.
 type dummyMigration struct {
 	name string
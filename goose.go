@@ -0,0 +1,65 @@
+package gomigration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// gooseTable is the table in which goose records its history.
+const gooseTable = "goose_db_version"
+
+// GooseVersion is a row of goose's goose_db_version table: a migration
+// version applied, or rolled back when IsApplied is false.
+type GooseVersion struct {
+	VersionID int64
+	IsApplied bool
+	Tstamp    time.Time
+}
+
+// GooseReader is implemented by drivers that can read the history recorded by
+// goose. It is required by MigrateFromGoose.
+type GooseReader interface {
+	// GooseVersions returns the rows of goose's goose_db_version table, oldest
+	// first.
+	GooseVersions(ctx context.Context) ([]GooseVersion, error)
+}
+
+// gooseVersions reads the rows of goose's version table from db.
+func gooseVersions(ctx context.Context, db *sql.DB) ([]GooseVersion, error) {
+	query := fmt.Sprintf(`SELECT version_id, is_applied, tstamp FROM %s ORDER BY id`, gooseTable)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read goose history from %s: %w", gooseTable, err)
+	}
+	defer rows.Close()
+
+	var versions []GooseVersion
+	for rows.Next() {
+		var v GooseVersion
+		if err := rows.Scan(&v.VersionID, &v.IsApplied, &v.Tstamp); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// appliedGooseVersions returns the versions goose considers applied, in the
+// order they were last applied, each with the row applying it. Version 0 is
+// the row goose inserts when it creates its table.
+func appliedGooseVersions(versions []GooseVersion) []GooseVersion {
+	latest := make(map[int64]int)
+	for i, v := range versions {
+		latest[v.VersionID] = i
+	}
+
+	var applied []GooseVersion
+	for i, v := range versions {
+		if v.VersionID != 0 && v.IsApplied && latest[v.VersionID] == i {
+			applied = append(applied, v)
+		}
+	}
+	return applied
+}
@@ -0,0 +1,71 @@
+package gomigration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppliedGooseVersions(t *testing.T) {
+	at := time.Date(2025, 4, 18, 22, 0, 0, 0, time.UTC)
+	applied := appliedGooseVersions([]GooseVersion{
+		{VersionID: 0, IsApplied: true, Tstamp: at},
+		{VersionID: 1, IsApplied: true, Tstamp: at.Add(time.Minute)},
+		{VersionID: 3, IsApplied: true, Tstamp: at.Add(2 * time.Minute)},
+		{VersionID: 3, IsApplied: false, Tstamp: at.Add(3 * time.Minute)},
+		{VersionID: 2, IsApplied: true, Tstamp: at.Add(4 * time.Minute)},
+	})
+
+	assert.Equal(t, []GooseVersion{
+		{VersionID: 1, IsApplied: true, Tstamp: at.Add(time.Minute)},
+		{VersionID: 2, IsApplied: true, Tstamp: at.Add(4 * time.Minute)},
+	}, applied)
+}
+
+func TestGoMigration_MigrateFromGoose(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "goose.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"20250101000000_create_users": dummyMigration{name: "20250101000000_create_users"},
+			"20250102000000_create_posts": dummyMigration{name: "20250102000000_create_posts"},
+		},
+	}
+
+	_, err = driver.db.ExecContext(ctx, `
+		CREATE TABLE goose_db_version (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			version_id INTEGER NOT NULL,
+			is_applied INTEGER NOT NULL,
+			tstamp TIMESTAMP DEFAULT (datetime('now'))
+		);
+		INSERT INTO goose_db_version (version_id, is_applied, tstamp) VALUES
+			(0, 1, '2025-01-01 00:00:00'),
+			(20250101000000, 1, '2025-01-01 10:00:00'),
+			(20250102000000, 1, '2025-01-02 10:00:00'),
+			(20250102000000, 0, '2025-01-02 11:00:00');
+	`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.MigrateFromGoose(ctx))
+
+	executed, err := driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 1) {
+		assert.Equal(t, "20250101000000_create_users", executed[0].Name)
+		assert.True(t, executed[0].ExecutedAt.Equal(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)))
+	}
+
+	_, err = driver.db.ExecContext(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES (20250103000000, 1)`)
+	assert.NoError(t, err)
+	err = q.MigrateFromGoose(ctx)
+	assert.ErrorIs(t, err, ErrMigrationNotRegistered)
+	assert.ErrorContains(t, err, "20250103000000")
+}
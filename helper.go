@@ -1,12 +1,22 @@
 package gomigration
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go/format"
+	"io"
 	"os"
 	"regexp"
+	"runtime/debug"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"unicode"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -24,10 +34,10 @@ func migrationDirExists(migrationFilesDir string) bool {
 	return !os.IsNotExist(err)
 }
 
-// printTable prints a 2D slice of strings as a formatted table.
-func printTable(data [][]string) {
+// printTable writes a 2D slice of strings as a formatted table to w.
+func printTable(w io.Writer, data [][]string) {
 	if len(data) == 0 {
-		fmt.Println("No data to display.")
+		fmt.Fprintln(w, "No data to display.")
 		return
 	}
 
@@ -41,20 +51,20 @@ func printTable(data [][]string) {
 	}
 
 	printRow := func(row []string) {
-		fmt.Print("|")
+		fmt.Fprint(w, "|")
 		for i, col := range row {
 			format := fmt.Sprintf(" %%-%ds |", colWidths[i])
-			fmt.Printf(format, col)
+			fmt.Fprintf(w, format, col)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
 	printSeparator := func() {
-		fmt.Print("+")
+		fmt.Fprint(w, "+")
 		for _, width := range colWidths {
-			fmt.Print(strings.Repeat("-", width+2) + "+")
+			fmt.Fprint(w, strings.Repeat("-", width+2)+"+")
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 
 	printSeparator()
@@ -67,6 +77,18 @@ func printTable(data [][]string) {
 	printSeparator()
 }
 
+// printScript prints a migration script between separator lines.
+func printScript(script string) {
+	fmt.Println("================================================")
+	fmt.Println(script)
+	fmt.Println("================================================")
+}
+
+// quoteSQLString quotes s as a standard SQL string literal.
+func quoteSQLString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
 // sanitizeMigrationName transforms a migration name into a standardized format
 // and validates it. Returns an error if the name contains invalid characters.
 func sanitizeMigrationName(name string) (string, error) {
@@ -87,34 +109,60 @@ func sanitizeMigrationName(name string) (string, error) {
 	return name, nil
 }
 
+// tableNameRegex matches a table name, optionally qualified by its schema,
+// e.g. migrations or infra.migrations. Each part is at most 64 characters,
+// the longest identifier MySQL allows.
+var tableNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]{1,64}(\.[a-zA-Z0-9_]{1,64})?$`)
+
 // sanitizeTableName validates the table name. Returns an error if it contains
-// invalid characters.
+// invalid characters or more than a schema and a table part.
 func sanitizeTableName(name string) (string, error) {
-	valid := regexp.MustCompile(`^[a-zA-Z0-9_.]+$`)
-	if !valid.MatchString(name) {
+	if !tableNameRegex.MatchString(name) {
 		return "", fmt.Errorf("invalid table name: %s", name)
 	}
 
 	return name, nil
 }
 
-// migrationNameToStructName converts a migration file name (with timestamp prefix)
-// to a Go struct name used in the migration template.
+// splitTableName splits a table name into its schema, empty when the name is
+// not schema-qualified, and the table itself.
+func splitTableName(name string) (schema, table string) {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// quoteIdentifier quotes each part of the dotted identifier name for dialect,
+// with backticks for MySQL and double quotes otherwise. Quote characters in a
+// part are doubled.
+func quoteIdentifier(dialect Dialect, name string) string {
+	quote := `"`
+	if dialect == DialectMySQL {
+		quote = "`"
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+// migrationNameToStructName converts a migration file name (with timestamp,
+// sequential, ULID or KSUID prefix) to a Go struct name used in the migration template.
 func migrationNameToStructName(migrationName string) (string, error) {
-	re := regexp.MustCompile(`^\d{14}_`)
-	matches := re.FindStringSubmatch(migrationName)
-	if len(matches) == 0 {
+	prefix := migrationPrefix(migrationName)
+	if !regexp.MustCompile(`^\d+$`).MatchString(prefix) && !migrationIDPattern.MatchString(migrationName) {
 		return "", fmt.Errorf("invalid migration name: %s", migrationName)
 	}
-	timestamp := strings.TrimRight(matches[0], "_")
-	nameWithoutTimestamp := strings.TrimPrefix(migrationName, timestamp)
+	nameWithoutPrefix := strings.TrimPrefix(migrationName, prefix)
 
-	parts := strings.Split(nameWithoutTimestamp, "_")
+	parts := strings.Split(nameWithoutPrefix, "_")
 	for i, part := range parts {
 		parts[i] = cases.Title(language.English).String(part)
 	}
 
-	structName := fmt.Sprintf("M%s%s", timestamp, strings.Join(parts, ""))
+	structName := fmt.Sprintf("M%s%s", prefix, strings.Join(parts, ""))
 	return structName, nil
 }
 
@@ -129,16 +177,30 @@ func getPackageNameFromMigrationDir(migrationFilesDir string) string {
 }
 
 // migrationFileTemplate generates a Go file template for a new migration
-// using the specified package and migration name. It returns formatted Go source code.
-func migrationFileTemplate(packageName string, migrationName string) (string, error) {
+// using the specified package and migration name, returning the given up and
+// down scripts, which are usually empty. With register, an init function
+// registers the migration with the package-level Register. It returns
+// formatted Go source code.
+func migrationFileTemplate(packageName string, migrationName string, upScript string, downScript string, register bool) (string, error) {
 	structName, err := migrationNameToStructName(migrationName)
 	if err != nil {
 		return "", err
 	}
 
+	registration := ""
+	if register {
+		registration = fmt.Sprintf(`
+			import "github.com/openframebox/gomigration"
+
+			func init() {
+				gomigration.Register(&%s{})
+			}
+		`, structName)
+	}
+
 	migrationTemplate := fmt.Sprintf(`
 		package %s
-
+		%s
 		type %s struct {}
 
 		func (m *%s) Name() string {
@@ -148,20 +210,23 @@ func migrationFileTemplate(packageName string, migrationName string) (string, er
 
 		func (m *%s) UpScript() string {
 		    // Write your migration SQL here
-			return ""
+			return %s
 		}
 
 		func (m *%s) DownScript() string {
 			// Write your rollback SQL here
-			return ""
+			return %s
 		}
 	`,
 		packageName,
+		registration,
 		structName,
 		structName,
 		migrationName,
 		structName,
+		goStringLiteral(upScript),
 		structName,
+		goStringLiteral(downScript),
 	)
 
 	formatted, err := format.Source([]byte(migrationTemplate))
@@ -172,6 +237,15 @@ func migrationFileTemplate(packageName string, migrationName string) (string, er
 	return string(formatted), nil
 }
 
+// goStringLiteral returns s as a Go string literal, a raw one spanning lines
+// when s is a multi-line script that allows it.
+func goStringLiteral(s string) string {
+	if s == "" || strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`\n" + s + "\n`"
+}
+
 // getSortedMigrationName returns a sorted list of migration names
 // from a map of migration structs.
 func getSortedMigrationName(migrations map[string]Migration) []string {
@@ -183,3 +257,606 @@ func getSortedMigrationName(migrations map[string]Migration) []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// migrationAnnotationRegex matches the annotation lines of single file SQL
+// migrations, such as -- +migrate Up or -- +goose Down.
+var migrationAnnotationRegex = regexp.MustCompile(`(?i)^--\s*\+(?:migrate|goose)\s+(.*?)\s*$`)
+
+// parseAnnotatedMigration parses a single file SQL migration: its up script
+// follows the Up annotation, and its down script the optional Down
+// annotation. Before the Up annotation, only comments and blank lines are
+// allowed. Other annotations, such as StatementBegin, are kept in the scripts.
+func parseAnnotatedMigration(name, content string) (scriptMigration, error) {
+	migration := scriptMigration{name: name}
+	var up, down *strings.Builder
+	var current *strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		matches := migrationAnnotationRegex.FindStringSubmatch(strings.TrimSpace(line))
+		annotation := ""
+		if matches != nil {
+			annotation = strings.ToLower(matches[1])
+		}
+
+		switch {
+		case annotation == "up":
+			if up != nil {
+				return migration, fmt.Errorf("%w: more than one Up annotation", ErrInvalidMigrationFile)
+			}
+			if down != nil {
+				return migration, fmt.Errorf("%w: Up annotation after the Down annotation", ErrInvalidMigrationFile)
+			}
+			up = &strings.Builder{}
+			current = up
+		case annotation == "down":
+			if up == nil {
+				return migration, fmt.Errorf("%w: Down annotation before the Up annotation", ErrInvalidMigrationFile)
+			}
+			if down != nil {
+				return migration, fmt.Errorf("%w: more than one Down annotation", ErrInvalidMigrationFile)
+			}
+			down = &strings.Builder{}
+			current = down
+		case annotation == "notransaction" || annotation == "no transaction":
+			migration.noTransaction = true
+		case strings.HasPrefix(annotation, "dependson "):
+			names := strings.FieldsFunc(matches[1][len("dependson "):], func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})
+			migration.dependsOn = append(migration.dependsOn, names...)
+		case strings.HasPrefix(annotation, "tags "):
+			tags := strings.FieldsFunc(matches[1][len("tags "):], func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})
+			migration.tags = append(migration.tags, tags...)
+		case strings.HasPrefix(annotation, "phase "):
+			phase := Phase(strings.TrimSpace(annotation[len("phase "):]))
+			if phase != PreDeploy && phase != PostDeploy {
+				return migration, fmt.Errorf("%w: unknown phase %q", ErrInvalidMigrationFile, phase)
+			}
+			migration.phase = phase
+		case current != nil:
+			current.WriteString(line)
+		case strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "--"):
+			return migration, fmt.Errorf("%w: statement before the Up annotation", ErrInvalidMigrationFile)
+		}
+	}
+
+	if up == nil {
+		return migration, fmt.Errorf("%w: missing Up annotation", ErrInvalidMigrationFile)
+	}
+	migration.upScript = strings.TrimSpace(up.String())
+	if down != nil {
+		migration.downScript = strings.TrimSpace(down.String())
+	}
+	return migration, nil
+}
+
+// annotatedMigrationTemplate returns the content of a single file SQL
+// migration with the given scripts.
+func annotatedMigrationTemplate(upScript, downScript string) string {
+	return fmt.Sprintf("-- +migrate Up\n%s\n\n-- +migrate Down\n%s\n", upScript, downScript)
+}
+
+// migrationSetNameRegex matches valid MigrationSet names.
+var migrationSetNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// migrationSource returns the Go type implementing m, looking through the
+// wrappers of migration sets and WithTags.
+func migrationSource(m Migration) string {
+	return fmt.Sprintf("%T", unwrapMigration(m))
+}
+
+// unwrapMigration returns the migration wrapped by the wrappers of migration
+// sets and WithTags, m itself if it is not wrapped.
+func unwrapMigration(m Migration) Migration {
+	for {
+		switch wrapper := m.(type) {
+		case setMigration:
+			m = wrapper.Migration
+		case taggedMigration:
+			m = wrapper.Migration
+		default:
+			return m
+		}
+	}
+}
+
+// migrationSetOf returns the name of the migration set m belongs to, empty for
+// the service's own migrations.
+func migrationSetOf(m Migration) string {
+	if sm, ok := m.(setMigration); ok {
+		return sm.set
+	}
+	return ""
+}
+
+// sortMigrationSets returns the names of sets in the order they are applied:
+// every set after the sets listed in its After, and sets free to go next by
+// name. It fails if a set lists an unknown set or sets depend on each other.
+func sortMigrationSets(sets map[string]MigrationSet) ([]string, error) {
+	waitingOn := make(map[string]int, len(sets))
+	dependents := make(map[string][]string, len(sets))
+	for name, set := range sets {
+		for _, after := range set.After {
+			if _, ok := sets[after]; !ok {
+				return nil, fmt.Errorf("%w: %s is applied after unknown set %s", ErrInvalidMigrationSet, name, after)
+			}
+			waitingOn[name]++
+			dependents[after] = append(dependents[after], name)
+		}
+	}
+
+	var ready []string
+	for name := range sets {
+		if waitingOn[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	order := make([]string, 0, len(sets))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dependent := range dependents[name] {
+			waitingOn[dependent]--
+			if waitingOn[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) < len(sets) {
+		var cycle []string
+		for name := range sets {
+			if waitingOn[name] > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("%w: sets applied after each other: %s", ErrInvalidMigrationSet, strings.Join(cycle, ", "))
+	}
+	return order, nil
+}
+
+// isEmptyScript reports whether script has no statement: it is empty or only
+// holds whitespace, comments and semicolons.
+func isEmptyScript(script string) bool {
+	return strings.Trim(stripSQLCommentsAndStrings(script), " \t\r\n;") == ""
+}
+
+// stripSQLCommentsAndStrings blanks out the comments and quoted strings and
+// identifiers of script, so statements can be told apart by their keywords.
+// Line breaks are kept and every other byte is replaced by a space, so offsets
+// in the result are offsets in script.
+func stripSQLCommentsAndStrings(script string) string {
+	b := []byte(script)
+	blank := func(from, to int) {
+		for ; from < to && from < len(b); from++ {
+			if b[from] != '\n' {
+				b[from] = ' '
+			}
+		}
+	}
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '-' && strings.HasPrefix(script[i:], "--"), c == '#':
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			blank(i, i+end)
+			i += end
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			blank(i, i+end+4)
+			i += end + 3
+		case c == '\'' || c == '"' || c == '`':
+			start := i
+			for i++; i < len(script) && script[i] != c; i++ {
+				if script[i] == '\\' {
+					i++
+				}
+			}
+			blank(start, i+1)
+		}
+	}
+	return string(b)
+}
+
+// numericPrefix returns the leading digits of a migration name, such as the
+// timestamp of generated migrations, or an empty string if there are none.
+func numericPrefix(name string) string {
+	end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		return name
+	}
+	return name[:end]
+}
+
+// migrationNames returns the names of migrations, in order.
+func migrationNames(migrations []Migration) []string {
+	names := make([]string, len(migrations))
+	for i, m := range migrations {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+// appliedByFromEnv returns the GOMIGRATION_APPLIED_BY environment variable, or
+// the host name if it is not set.
+func appliedByFromEnv() string {
+	if value := os.Getenv("GOMIGRATION_APPLIED_BY"); value != "" {
+		return value
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// buildInfoFromEnv fills the empty fields of build from the
+// GOMIGRATION_COMMIT, GOMIGRATION_VERSION and GOMIGRATION_RUN_URL environment
+// variables, falling back to the VCS revision stamped in the binary for the
+// commit.
+func buildInfoFromEnv(build BuildInfo) BuildInfo {
+	if build.Commit == "" {
+		build.Commit = os.Getenv("GOMIGRATION_COMMIT")
+	}
+	if build.Commit == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range info.Settings {
+				if setting.Key == "vcs.revision" {
+					build.Commit = setting.Value
+				}
+			}
+		}
+	}
+	if build.Version == "" {
+		build.Version = os.Getenv("GOMIGRATION_VERSION")
+	}
+	if build.RunURL == "" {
+		build.RunURL = os.Getenv("GOMIGRATION_RUN_URL")
+	}
+	return build
+}
+
+// parseMigrationOrder reads migration names, one per line, skipping blank
+// lines and # comments. A name listed twice is an error.
+func parseMigrationOrder(r io.Reader) ([]string, error) {
+	order := []string{}
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s listed more than once", ErrMigrationOrderMismatch, name)
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read migration order: %w", err)
+	}
+
+	return order, nil
+}
+
+// appendMigrationOrder adds name as the last entry of the migration order file.
+func appendMigrationOrder(fileName string, name string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	entry := name + "\n"
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		entry = "\n" + entry
+	}
+
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry)
+	return err
+}
+
+// squashMigrationOrder rewrites the migration order file fileName, listing
+// baseline in place of the first of the squashed migrations and dropping the
+// others. Comments and blank lines are kept.
+func squashMigrationOrder(fileName string, squashed []string, baseline string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	isSquashed := make(map[string]bool, len(squashed))
+	for _, name := range squashed {
+		isSquashed[name] = true
+	}
+
+	var lines []string
+	replaced := false
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if !isSquashed[strings.TrimSpace(line)] {
+			lines = append(lines, line)
+			continue
+		}
+		if !replaced {
+			lines = append(lines, strings.Replace(line, strings.TrimSpace(line), baseline, 1))
+			replaced = true
+		}
+	}
+
+	return os.WriteFile(fileName, []byte(strings.Join(lines, "")), 0644)
+}
+
+// migrationChecksum returns the hex encoded SHA-256 of the migration's up
+// script, followed by the up scripts of its dialect variants, if any.
+func migrationChecksum(m Migration) string {
+	h := sha256.New()
+	h.Write([]byte(m.UpScript()))
+	if variants, ok := m.(DialectMigration); ok {
+		for _, dialect := range dialects {
+			if upScript, _, ok := variants.DialectScript(dialect); ok {
+				fmt.Fprintf(h, "\x00%s\x00%s", dialect, upScript)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// manifestHash returns the hex encoded SHA-256 of the names and checksums of
+// the given migrations, independent of registration order.
+func manifestHash(migrations map[string]Migration) string {
+	h := sha256.New()
+	for _, name := range getSortedMigrationName(migrations) {
+		fmt.Fprintf(h, "%s\x00%s\n", name, migrationChecksum(migrations[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// latestBatch returns the highest batch number among the executed migrations,
+// 0 if none was applied in a batch.
+func latestBatch(executedMigrations []ExecutedMigration) int {
+	batch := 0
+	for _, m := range executedMigrations {
+		if m.Batch > batch {
+			batch = m.Batch
+		}
+	}
+	return batch
+}
+
+// renderScript executes the script of migration as a text/template with data,
+// unless data is nil. Keys missing from data are errors.
+func renderScript(migration, script string, data map[string]any) (string, error) {
+	if data == nil {
+		return script, nil
+	}
+
+	tmpl, err := template.New(migration).Option("missingkey=error").Parse(script)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse script of %s as a template: %w", migration, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render script of %s: %w", migration, err)
+	}
+	return b.String(), nil
+}
+
+// envVarRegex matches the ${VAR} references expanded by expandEnv.
+var envVarRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references of the script of migration with
+// the environment as selected by mode. Strict mode fails if any variable is
+// not set, naming all of them.
+func expandEnv(migration, script string, mode EnvExpansion) (string, error) {
+	if mode == EnvExpansionOff {
+		return script, nil
+	}
+
+	var undefined []string
+	expanded := envVarRegex.ReplaceAllStringFunc(script, func(ref string) string {
+		name := envVarRegex.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && !slices.Contains(undefined, name) {
+			undefined = append(undefined, name)
+		}
+		return value
+	})
+	if mode == EnvExpansionStrict && len(undefined) > 0 {
+		return "", fmt.Errorf("%w: %s in %s", ErrUndefinedEnvVar, strings.Join(undefined, ", "), migration)
+	}
+	return expanded, nil
+}
+
+// migrationTags returns the tags of m, none unless it is a TaggedMigration.
+func migrationTags(m Migration) []string {
+	if tagged, ok := m.(TaggedMigration); ok {
+		return tagged.Tags()
+	}
+	return nil
+}
+
+// migrationPhase returns the Phase of m, PreDeploy unless it is a
+// PhasedMigration.
+func migrationPhase(m Migration) Phase {
+	if phased, ok := m.(PhasedMigration); ok {
+		return phased.Phase()
+	}
+	return PreDeploy
+}
+
+// shouldRun reports whether m is to be applied to the database of d, true
+// unless it is a ConditionalMigration deciding otherwise.
+func shouldRun(ctx context.Context, d Driver, m Migration) (bool, error) {
+	if conditional, ok := m.(ConditionalMigration); ok {
+		return conditional.ShouldRun(ctx, d)
+	}
+	return true, nil
+}
+
+// isConditional reports whether m, or the migration it wraps, is a
+// ConditionalMigration.
+func isConditional(m Migration) bool {
+	_, ok := unwrapMigration(m).(ConditionalMigration)
+	return ok
+}
+
+// migrationDependencies returns the names of the migrations m depends on, none
+// unless it is a DependentMigration.
+func migrationDependencies(m Migration) []string {
+	if dependent, ok := m.(DependentMigration); ok {
+		return slices.Clone(dependent.DependsOn())
+	}
+	return nil
+}
+
+// sortByDependencies orders migrations after the ones they depend on, keeping
+// their order otherwise. It returns the names of the migrations that could
+// not be ordered because they depend on each other, in their order.
+func sortByDependencies(migrations []Migration) (sorted []Migration, cyclic []string) {
+	index := make(map[string]int, len(migrations))
+	for i, m := range migrations {
+		index[m.Name()] = i
+	}
+
+	waitingOn := make([]int, len(migrations))
+	dependents := make([][]int, len(migrations))
+	for i, m := range migrations {
+		for _, dependency := range migrationDependencies(m) {
+			if j, ok := index[dependency]; ok {
+				waitingOn[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	var ready []int
+	for i := range migrations {
+		if waitingOn[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	sorted = make([]Migration, 0, len(migrations))
+	for len(ready) > 0 {
+		slices.Sort(ready)
+		i := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, migrations[i])
+		for _, dependent := range dependents[i] {
+			waitingOn[dependent]--
+			if waitingOn[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	for i, m := range migrations {
+		if waitingOn[i] > 0 {
+			cyclic = append(cyclic, m.Name())
+		}
+	}
+	return sorted, cyclic
+}
+
+// splitDialect splits the dialect of a migration file name without its
+// .up.sql or .down.sql extension, such as "mysql" in "001_create_users.mysql".
+// The dialect is empty when name has none.
+func splitDialect(name string) (string, Dialect) {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 && slices.Contains(dialects, Dialect(name[i+1:])) {
+		return name[:i], Dialect(name[i+1:])
+	}
+	return name, ""
+}
+
+// majorVersion returns the major version of a server version such as
+// "8.0.36" or "3.05.2", 0 if it has none.
+func majorVersion(version string) int {
+	major, _, _ := strings.Cut(version, ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
+
+// versionBelow tells why version is not at least minVersion, both dotted
+// numbers with an optional v prefix and ignoring pre-release and build
+// suffixes. It returns an empty string if version is at least minVersion.
+func versionBelow(version, minVersion string) string {
+	if version == "" {
+		return "running an unknown version"
+	}
+	have, err := parseVersion(version)
+	if err != nil {
+		return fmt.Sprintf("running unparsable version %s", version)
+	}
+	want, err := parseVersion(minVersion)
+	if err != nil {
+		return fmt.Sprintf("unparsable minimum version %s", minVersion)
+	}
+
+	for i := range max(len(have), len(want)) {
+		var h, w int
+		if i < len(have) {
+			h = have[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if h != w {
+			if h < w {
+				return "running " + version
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// parseVersion parses the dotted numbers of version, such as v1.8.0-rc.1.
+func parseVersion(version string) ([]int, error) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// dialectScripts returns the up and down scripts of m on dialect: those of its
+// DialectMigration variant for dialect if it has one, UpScript and DownScript
+// otherwise.
+func dialectScripts(m Migration, dialect Dialect) (upScript, downScript string) {
+	if variants, ok := m.(DialectMigration); ok && dialect != "" {
+		if upScript, downScript, ok := variants.DialectScript(dialect); ok {
+			return upScript, downScript
+		}
+	}
+	return m.UpScript(), m.DownScript()
+}
+
+// isNonTransactional reports whether the migration opted out of running
+// inside a transaction.
+func isNonTransactional(m Migration) bool {
+	nt, ok := m.(NonTransactionalMigration)
+	return ok && nt.NonTransactional()
+}
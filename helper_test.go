@@ -1,6 +1,9 @@
 package gomigration
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,6 +43,12 @@ func TestSanitizeTableName(t *testing.T) {
 		{"valid_table_name", "valid_table_name", false},
 		{"invalid table!", "", true},
 		{"AnotherOne123", "AnotherOne123", false},
+		{"infra.migrations", "infra.migrations", false},
+		{"a.b.c", "", true},
+		{".migrations", "", true},
+		{"infra.", "", true},
+		{"migrations; DROP TABLE users", "", true},
+		{"mig\"rations", "", true},
 	}
 
 	for _, tt := range tests {
@@ -54,6 +63,26 @@ func TestSanitizeTableName(t *testing.T) {
 	}
 }
 
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		input    string
+		expected string
+	}{
+		{DialectPostgres, "migrations", `"migrations"`},
+		{DialectPostgres, "infra.migrations", `"infra"."migrations"`},
+		{DialectMySQL, "infra.migrations", "`infra`.`migrations`"},
+		{DialectSQLite, `odd"name`, `"odd""name"`},
+		{DialectMySQL, "odd`name", "`odd``name`"},
+	}
+
+	for _, tt := range tests {
+		if result := quoteIdentifier(tt.dialect, tt.input); result != tt.expected {
+			t.Errorf("quoteIdentifier(%q, %q) = %v, want %v", tt.dialect, tt.input, result, tt.expected)
+		}
+	}
+}
+
 func TestMigrationNameToStructName(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -61,6 +90,8 @@ func TestMigrationNameToStructName(t *testing.T) {
 		wantErr  bool
 	}{
 		{"20240426123456_create_users_table", "M20240426123456CreateUsersTable", false},
+		{"01JQ3Z5V8KXG0Y7M2R4T6W9ABC_create_users_table", "M01JQ3Z5V8KXG0Y7M2R4T6W9ABCCreateUsersTable", false},
+		{"2mWc6T0GZ9vK3pQ8sX1yR7fJ4hL_create_users_table", "M2mWc6T0GZ9vK3pQ8sX1yR7fJ4hLCreateUsersTable", false},
 		{"invalid_name_without_timestamp", "", true},
 	}
 
@@ -87,13 +118,22 @@ func TestGetPackageNameFromMigrationDir(t *testing.T) {
 }
 
 func TestMigrationFileTemplate(t *testing.T) {
-	code, err := migrationFileTemplate("migrations", "20240426123456_create_users_table")
+	code, err := migrationFileTemplate("migrations", "20240426123456_create_users_table", "", "", false)
 
 	assert.NoError(t, err)
 	assert.Contains(t, code, "package migrations")
 	assert.Contains(t, code, "type M20240426123456CreateUsersTable struct")
 	assert.Contains(t, code, "func (m *M20240426123456CreateUsersTable) Name() string")
 	assert.Contains(t, code, "return \"20240426123456_create_users_table\"")
+	assert.Contains(t, code, "return \"\"")
+}
+
+func TestMigrationFileTemplate_Scripts(t *testing.T) {
+	code, err := migrationFileTemplate("migrations", "20240426123456_add_email", "ALTER TABLE users ADD COLUMN email TEXT;", "ALTER TABLE `users` DROP COLUMN `email`;", false)
+
+	assert.NoError(t, err)
+	assert.Contains(t, code, "return `\nALTER TABLE users ADD COLUMN email TEXT;\n`")
+	assert.Contains(t, code, "return \"ALTER TABLE `users` DROP COLUMN `email`;\"")
 }
 
 func TestGetSortedMigrationName(t *testing.T) {
@@ -108,3 +148,211 @@ func TestGetSortedMigrationName(t *testing.T) {
 	assert := assert.New(t)
 	assert.Equal([]string{"a_migration", "b_migration", "c_migration"}, sorted)
 }
+
+func TestParseMigrationOrder(t *testing.T) {
+	order, err := parseMigrationOrder(strings.NewReader("# reviewed order\n002_b\n\n  001_a  \n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"002_b", "001_a"}, order)
+
+	_, err = parseMigrationOrder(strings.NewReader("001_a\n001_a\n"))
+	assert.ErrorIs(t, err, ErrMigrationOrderMismatch)
+}
+
+func TestSquashMigrationOrder(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "order.txt")
+	assert.NoError(t, os.WriteFile(fileName, []byte("# reviewed order\n001_a\n002_b\n\n003_c\n"), 0644))
+
+	assert.NoError(t, squashMigrationOrder(fileName, []string{"001_a", "002_b"}, "002_b_squashed"))
+
+	content, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "# reviewed order\n002_b_squashed\n\n003_c\n", string(content))
+}
+
+func TestRenderScript(t *testing.T) {
+	script := "CREATE TABLE {{ .Schema }}.users (id INTEGER);"
+
+	rendered, err := renderScript("001_create_users", script, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, script, rendered)
+
+	rendered, err = renderScript("001_create_users", script, map[string]any{"Schema": "staging"})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE staging.users (id INTEGER);", rendered)
+
+	_, err = renderScript("001_create_users", script, map[string]any{"Env": "staging"})
+	assert.ErrorContains(t, err, "failed to render script of 001_create_users")
+
+	_, err = renderScript("001_create_users", "CREATE TABLE {{ .Schema .users;", map[string]any{})
+	assert.ErrorContains(t, err, "failed to parse script of 001_create_users")
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("GOMIGRATION_TABLESPACE", "fast_ssd")
+	script := "CREATE TABLE users (id INTEGER) TABLESPACE ${GOMIGRATION_TABLESPACE}${GOMIGRATION_UNSET}; SELECT $1, $$body$$, $HOME;"
+
+	expanded, err := expandEnv("001_create_users", script, EnvExpansionOff)
+	assert.NoError(t, err)
+	assert.Equal(t, script, expanded)
+
+	expanded, err = expandEnv("001_create_users", script, EnvExpansionLenient)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (id INTEGER) TABLESPACE fast_ssd; SELECT $1, $$body$$, $HOME;", expanded)
+
+	_, err = expandEnv("001_create_users", script+" ${GOMIGRATION_UNSET} ${GOMIGRATION_OTHER}", EnvExpansionStrict)
+	assert.ErrorIs(t, err, ErrUndefinedEnvVar)
+	assert.ErrorContains(t, err, "GOMIGRATION_UNSET, GOMIGRATION_OTHER in 001_create_users")
+}
+
+func TestParseAnnotatedMigration(t *testing.T) {
+	m, err := parseAnnotatedMigration("001_create_users", `-- Users of the app
+-- +migrate Up
+CREATE TABLE users (id INTEGER);
+
+-- +migrate Down
+DROP TABLE users;
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (id INTEGER);", m.UpScript())
+	assert.Equal(t, "DROP TABLE users;", m.DownScript())
+	assert.False(t, m.NonTransactional())
+
+	m, err = parseAnnotatedMigration("002_index_users", `-- +goose NO TRANSACTION
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX CONCURRENTLY users_id ON users (id);
+-- +goose StatementEnd
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- +goose StatementBegin\nCREATE INDEX CONCURRENTLY users_id ON users (id);\n-- +goose StatementEnd", m.UpScript())
+	assert.Empty(t, m.DownScript())
+	assert.True(t, m.NonTransactional())
+
+	m, err = parseAnnotatedMigration("003_backfill_users", `-- +migrate DependsOn 001_create_users, 002_index_users
+-- +migrate Up
+UPDATE users SET id = id;
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"001_create_users", "002_index_users"}, m.DependsOn())
+	assert.Equal(t, PreDeploy, m.Phase())
+
+	m, err = parseAnnotatedMigration("004_drop_users_name", "-- +migrate Phase post-deploy\n-- +migrate Tags cleanup, users\n-- +migrate Up\nALTER TABLE users DROP name;\n")
+	assert.NoError(t, err)
+	assert.Equal(t, PostDeploy, m.Phase())
+	assert.Equal(t, []string{"cleanup", "users"}, m.Tags())
+
+	for _, content := range []string{
+		"CREATE TABLE users (id INTEGER);",
+		"CREATE TABLE users (id INTEGER);\n-- +migrate Up\n",
+		"-- +migrate Down\nDROP TABLE users;\n-- +migrate Up\n",
+		"-- +migrate Up\n-- +migrate Up\n",
+		"-- +migrate Up\n-- +migrate Down\n-- +migrate Down\n",
+		"-- +migrate Phase mid-deploy\n-- +migrate Up\n",
+	} {
+		_, err := parseAnnotatedMigration("001_create_users", content)
+		assert.ErrorIs(t, err, ErrInvalidMigrationFile, content)
+	}
+}
+
+func TestSortMigrationSets(t *testing.T) {
+	order, err := sortMigrationSets(map[string]MigrationSet{
+		"billing": {Name: "billing", After: []string{"auth", "audit"}},
+		"auth":    {Name: "auth"},
+		"audit":   {Name: "audit", After: []string{"auth"}},
+		"flags":   {Name: "flags"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"auth", "audit", "billing", "flags"}, order)
+
+	_, err = sortMigrationSets(map[string]MigrationSet{"auth": {Name: "auth", After: []string{"missing"}}})
+	assert.ErrorIs(t, err, ErrInvalidMigrationSet)
+
+	_, err = sortMigrationSets(map[string]MigrationSet{
+		"auth":  {Name: "auth", After: []string{"audit"}},
+		"audit": {Name: "audit", After: []string{"auth"}},
+		"flags": {Name: "flags"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidMigrationSet)
+	assert.ErrorContains(t, err, "audit, auth")
+}
+
+func TestIsEmptyScript(t *testing.T) {
+	assert.True(t, isEmptyScript(""))
+	assert.True(t, isEmptyScript(" \n\t;"))
+	assert.True(t, isEmptyScript("-- nothing yet\n/* CREATE TABLE users (id INT); */"))
+	assert.False(t, isEmptyScript("-- users\nCREATE TABLE users (id INT);"))
+}
+
+func TestSummarizeStatement(t *testing.T) {
+	assert.Equal(t, "none", summarizeStatement(""))
+	assert.Equal(t, "ALTER TABLE users ADD email TEXT", summarizeStatement("ALTER TABLE users\n\tADD email TEXT"))
+	assert.Equal(t, strings.Repeat("x", 60)+"...", summarizeStatement(strings.Repeat("x", 61)))
+}
+
+func TestAppliedByFromEnv(t *testing.T) {
+	t.Setenv("GOMIGRATION_APPLIED_BY", "ci-job-42")
+	assert.Equal(t, "ci-job-42", appliedByFromEnv())
+
+	t.Setenv("GOMIGRATION_APPLIED_BY", "")
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, appliedByFromEnv())
+}
+
+func TestBuildInfoFromEnv(t *testing.T) {
+	t.Setenv("GOMIGRATION_COMMIT", "4f2c1e9")
+	t.Setenv("GOMIGRATION_VERSION", "v1.8.0")
+	t.Setenv("GOMIGRATION_RUN_URL", "https://ci.example.com/runs/42")
+	assert.Equal(t, BuildInfo{Commit: "4f2c1e9", Version: "v1.8.0", RunURL: "https://ci.example.com/runs/42"}, buildInfoFromEnv(BuildInfo{}))
+	assert.Equal(t, BuildInfo{Commit: "abc", Version: "v2.0.0", RunURL: "https://ci.example.com/runs/42"}, buildInfoFromEnv(BuildInfo{Commit: "abc", Version: "v2.0.0"}))
+}
+
+func TestSortByDependencies(t *testing.T) {
+	migrations := []Migration{
+		scriptMigration{name: "001_a"},
+		scriptMigration{name: "002_b", dependsOn: []string{"004_d"}},
+		scriptMigration{name: "003_c"},
+		scriptMigration{name: "004_d", dependsOn: []string{"000_executed"}},
+	}
+	sorted, cyclic := sortByDependencies(migrations)
+	assert.Equal(t, []string{"001_a", "003_c", "004_d", "002_b"}, migrationNames(sorted))
+	assert.Empty(t, cyclic)
+
+	migrations = []Migration{
+		scriptMigration{name: "001_a", dependsOn: []string{"003_c"}},
+		scriptMigration{name: "002_b"},
+		scriptMigration{name: "003_c", dependsOn: []string{"001_a"}},
+	}
+	sorted, cyclic = sortByDependencies(migrations)
+	assert.Equal(t, []string{"002_b"}, migrationNames(sorted))
+	assert.Equal(t, []string{"001_a", "003_c"}, cyclic)
+}
+
+func TestVersionBelow(t *testing.T) {
+	assert.Empty(t, versionBelow("v1.8.0", "v1.8.0"))
+	assert.Empty(t, versionBelow("1.10.2", "v1.9"))
+	assert.Empty(t, versionBelow("v2.0.0-rc.1+build.5", "1.99.99"))
+	assert.Equal(t, "running v1.7.9", versionBelow("v1.7.9", "v1.8.0"))
+	assert.Equal(t, "running 1.8", versionBelow("1.8", "1.8.1"))
+	assert.Equal(t, "running an unknown version", versionBelow("", "v1.0.0"))
+	assert.Equal(t, "running unparsable version main", versionBelow("main", "v1.0.0"))
+	assert.Equal(t, "unparsable minimum version next", versionBelow("v1.0.0", "next"))
+}
+
+func TestNumericPrefix(t *testing.T) {
+	assert.Equal(t, "20240101120000", numericPrefix("20240101120000_create_users"))
+	assert.Equal(t, "001", numericPrefix("001"))
+	assert.Equal(t, "", numericPrefix("create_users"))
+}
+
+func TestIsNonTransactional(t *testing.T) {
+	assert.False(t, isNonTransactional(dummyMigration{name: "001_plain"}))
+	assert.True(t, isNonTransactional(nonTransactionalDummyMigration{dummyMigration{name: "002_concurrent_index"}}))
+}
+
+type nonTransactionalDummyMigration struct {
+	dummyMigration
+}
+
+func (d nonTransactionalDummyMigration) NonTransactional() bool {
+	return true
+}
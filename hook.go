@@ -0,0 +1,212 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ApplyHook is a side effect run after specific migrations have been applied,
+// such as flushing a cache or notifying another service of a renamed column.
+// It is registered with GoMigration.OnApplied.
+type ApplyHook struct {
+	// Migration selects the migration named Migration, and Tag the migrations
+	// whose TaggedMigration tags include it. The hook runs after a migration
+	// selected by either.
+	Migration string
+	Tag       string
+
+	// Run performs the side effect for the applied migration.
+	Run func(ctx context.Context, migration string) error
+
+	// Attempts is how many times Run is tried before giving up, once when 0.
+	Attempts int
+	// Backoff is the delay before the second attempt, doubled before each
+	// following one.
+	Backoff time.Duration
+}
+
+// OnApplied registers hook to run after each migration it selects has been
+// applied and recorded, by any Migrate variant. Hooks run on the
+// EventMigrationSucceeded event, so like other event listeners they run
+// synchronously before the next migration starts. A hook that still fails
+// after its last attempt is logged and does not fail the run, as the
+// migration is already applied. OnApplied returns a function removing the
+// hook again.
+func (q *GoMigration) OnApplied(hook ApplyHook) (remove func(), err error) {
+	if hook.Run == nil {
+		return nil, fmt.Errorf("%w: Run is not set", ErrInvalidApplyHook)
+	}
+	if hook.Migration == "" && hook.Tag == "" {
+		return nil, fmt.Errorf("%w: neither Migration nor Tag is set", ErrInvalidApplyHook)
+	}
+
+	return q.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+		if event.Type != EventMigrationSucceeded || event.Operation != OperationMigrate {
+			return
+		}
+
+		q.mu.Lock()
+		migration, registered := q.migrations[event.Migration]
+		q.mu.Unlock()
+		if !registered || !hook.selects(migration) {
+			return
+		}
+
+		if attempts, err := hook.run(ctx, event.Migration); err != nil {
+			q.logger.warn(fmt.Sprintf("⚠️ Apply hook after %s failed after %d attempt(s): %s", event.Migration, attempts, err), "apply hook failed", "migration", event.Migration, "attempts", attempts, "error", err)
+		}
+	})), nil
+}
+
+// selects reports whether the hook runs after m.
+func (h ApplyHook) selects(m Migration) bool {
+	if h.Migration != "" && m.Name() == h.Migration {
+		return true
+	}
+	return h.Tag != "" && slices.Contains(migrationTags(m), h.Tag)
+}
+
+// run calls Run until it succeeds, the attempts are exhausted or ctx is done,
+// returning the number of attempts made and the last error.
+func (h ApplyHook) run(ctx context.Context, migration string) (int, error) {
+	attempts := max(h.Attempts, 1)
+	backoff := h.Backoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = h.Run(ctx, migration); err == nil || attempt == attempts {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// runHook is a hook registered with OnBeforeMigrate, OnAfterMigrate or
+// OnError, with one of its functions set.
+type runHook struct {
+	id            int
+	beforeMigrate func(ctx context.Context, migrations []string) error
+	afterMigrate  func(ctx context.Context, applied []string, err error)
+	onError       func(ctx context.Context, migration string, err error)
+}
+
+// OnBeforeMigrate registers fn to run before a Migrate variant applies
+// migrations, with their names in order, e.g. to put the application in
+// maintenance mode. An error fails the run before any migration is applied.
+// It is not called when nothing is pending. OnBeforeMigrate returns a
+// function removing the hook again.
+func (q *GoMigration) OnBeforeMigrate(fn func(ctx context.Context, migrations []string) error) (remove func()) {
+	return q.addRunHook(runHook{beforeMigrate: fn})
+}
+
+// OnAfterMigrate registers fn to run once a Migrate variant that had
+// migrations to apply ends, with the migrations it applied and the error it
+// failed with, if any, e.g. to bust caches. It runs on a context that is not
+// cancelled with that of the run. OnAfterMigrate returns a function removing
+// the hook again.
+func (q *GoMigration) OnAfterMigrate(fn func(ctx context.Context, applied []string, err error)) (remove func()) {
+	return q.addRunHook(runHook{afterMigrate: fn})
+}
+
+// OnBeforeEach registers fn to run before each migration a Migrate variant
+// applies. Like the hooks of OnApplied, it runs on the EventMigrationStarted
+// event. OnBeforeEach returns a function removing the hook again.
+func (q *GoMigration) OnBeforeEach(fn func(ctx context.Context, migration string)) (remove func()) {
+	return q.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+		if event.Type == EventMigrationStarted && event.Operation == OperationMigrate {
+			fn(ctx, event.Migration)
+		}
+	}))
+}
+
+// OnAfterEach registers fn to run after each migration a Migrate variant
+// applied and recorded, with how long it took. It runs on the
+// EventMigrationSucceeded event. OnAfterEach returns a function removing the
+// hook again.
+func (q *GoMigration) OnAfterEach(fn func(ctx context.Context, migration string, duration time.Duration)) (remove func()) {
+	return q.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+		if event.Type == EventMigrationSucceeded && event.Operation == OperationMigrate {
+			fn(ctx, event.Migration, event.Duration)
+		}
+	}))
+}
+
+// OnError registers fn to run when a Migrate variant fails, e.g. to notify
+// on-call, with the migration that failed, empty when the run failed before
+// or between migrations, and the error of the run. It runs before the hooks
+// of OnAfterMigrate, on a context that is not cancelled with that of the run.
+// OnError returns a function removing the hook again.
+func (q *GoMigration) OnError(fn func(ctx context.Context, migration string, err error)) (remove func()) {
+	return q.addRunHook(runHook{onError: fn})
+}
+
+// addRunHook registers hook and returns a function removing it again.
+func (q *GoMigration) addRunHook(hook runHook) (remove func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextSubscriptionID++
+	id := q.nextSubscriptionID
+	hook.id = id
+	q.runHooks = append(q.runHooks, hook)
+
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		for i, h := range q.runHooks {
+			if h.id == id {
+				q.runHooks = append(q.runHooks[:i:i], q.runHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// runBeforeHooks runs the OnBeforeMigrate hooks in registration order,
+// stopping at the first failing one.
+func (q *GoMigration) runBeforeHooks(ctx context.Context, migrations []string) error {
+	q.mu.Lock()
+	hooks := q.runHooks
+	q.mu.Unlock()
+
+	for _, hook := range hooks {
+		if hook.beforeMigrate == nil {
+			continue
+		}
+		if err := hook.beforeMigrate(ctx, migrations); err != nil {
+			return fmt.Errorf("before migrate hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// runAfterHooks runs the OnError hooks when the run failed, with the
+// migration that failed, if any, then the OnAfterMigrate hooks.
+func (q *GoMigration) runAfterHooks(ctx context.Context, applied []string, failed string, err error) {
+	q.mu.Lock()
+	hooks := q.runHooks
+	q.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	if err != nil {
+		for _, hook := range hooks {
+			if hook.onError != nil {
+				hook.onError(ctx, failed, err)
+			}
+		}
+	}
+	for _, hook := range hooks {
+		if hook.afterMigrate != nil {
+			hook.afterMigrate(ctx, applied, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package gomigration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGoMigration_OnApplied(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), testContextKey{}, t.Name())
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+	driver.On("ApplyMigrations", runContext(ctx), mock.Anything).Return(nil)
+
+	q := &GoMigration{
+		driver: driver,
+		migrations: map[string]Migration{
+			"001_create_users": dummyMigration{name: "001_create_users"},
+			"002_rename_email": WithTags(dummyMigration{name: "002_rename_email"}, "cache"),
+			"003_create_posts": dummyMigration{name: "003_create_posts"},
+		},
+	}
+
+	var ran []string
+	_, err := q.OnApplied(ApplyHook{Tag: "cache", Run: func(ctx context.Context, migration string) error {
+		ran = append(ran, "flush after "+migration)
+		return nil
+	}})
+	assert.NoError(t, err)
+
+	failures := 0
+	remove, err := q.OnApplied(ApplyHook{Migration: "003_create_posts", Attempts: 3, Backoff: time.Millisecond, Run: func(ctx context.Context, migration string) error {
+		failures++
+		if failures < 3 {
+			return errors.New("service unavailable")
+		}
+		ran = append(ran, "notify after "+migration)
+		return nil
+	}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.Migrate(ctx))
+	assert.Equal(t, []string{"flush after 002_rename_email", "notify after 003_create_posts"}, ran)
+	assert.Equal(t, 3, failures)
+
+	remove()
+	ran = nil
+	q.emit(ctx, Event{Type: EventMigrationSucceeded, Operation: OperationMigrate, Migration: "003_create_posts"})
+	q.emit(ctx, Event{Type: EventMigrationSucceeded, Operation: OperationRollback, Migration: "002_rename_email"})
+	assert.Empty(t, ran)
+}
+
+func TestGoMigration_OnApplied_Invalid(t *testing.T) {
+	q := &GoMigration{migrations: map[string]Migration{}}
+
+	_, err := q.OnApplied(ApplyHook{Tag: "cache"})
+	assert.ErrorIs(t, err, ErrInvalidApplyHook)
+
+	_, err = q.OnApplied(ApplyHook{Run: func(ctx context.Context, migration string) error { return nil }})
+	assert.ErrorIs(t, err, ErrInvalidApplyHook)
+}
+
+func TestApplyHook_RunGivesUp(t *testing.T) {
+	calls := 0
+	hook := ApplyHook{Attempts: 2, Run: func(ctx context.Context, migration string) error {
+		calls++
+		return errors.New("service unavailable")
+	}}
+
+	attempts, err := hook.run(context.Background(), "001_create_users")
+	assert.Error(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, 2, calls)
+}
+
+func TestGoMigration_LifecycleHooks(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "hooks.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users":       &scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+			"002_create_users_again": &scriptMigration{name: "002_create_users_again", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		},
+	}
+
+	var calls []string
+	q.OnBeforeMigrate(func(ctx context.Context, migrations []string) error {
+		calls = append(calls, fmt.Sprintf("before migrate %v", migrations))
+		return nil
+	})
+	q.OnBeforeEach(func(ctx context.Context, migration string) {
+		calls = append(calls, "before "+migration)
+	})
+	q.OnAfterEach(func(ctx context.Context, migration string, duration time.Duration) {
+		calls = append(calls, "after "+migration)
+	})
+	q.OnError(func(ctx context.Context, migration string, err error) {
+		calls = append(calls, "error in "+migration)
+	})
+	q.OnAfterMigrate(func(ctx context.Context, applied []string, err error) {
+		calls = append(calls, fmt.Sprintf("after migrate %v: %t", applied, err != nil))
+	})
+
+	assert.Error(t, q.Migrate(context.Background()))
+	assert.Equal(t, []string{
+		"before migrate [001_create_users 002_create_users_again]",
+		"before 001_create_users",
+		"after 001_create_users",
+		"before 002_create_users_again",
+		"error in 002_create_users_again",
+		"after migrate [001_create_users]: true",
+	}, calls)
+}
+
+func TestGoMigration_OnBeforeMigrate_Error(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "hooks.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users": &scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		},
+	}
+
+	maintenance := errors.New("maintenance mode unavailable")
+	q.OnBeforeMigrate(func(ctx context.Context, migrations []string) error { return maintenance })
+	var failed []string
+	q.OnError(func(ctx context.Context, migration string, err error) {
+		failed = append(failed, migration)
+		assert.ErrorIs(t, err, maintenance)
+	})
+	remove := q.OnAfterMigrate(func(ctx context.Context, applied []string, err error) {
+		t.Error("removed hook called")
+	})
+	remove()
+
+	err = q.Migrate(context.Background())
+	assert.ErrorIs(t, err, maintenance)
+	assert.EqualError(t, err, "before migrate hook failed: maintenance mode unavailable")
+	assert.Equal(t, []string{""}, failed)
+
+	executed, err := driver.GetExecutedMigrations(context.Background(), HistoryOrderApplied)
+	assert.NoError(t, err)
+	assert.Empty(t, executed)
+}
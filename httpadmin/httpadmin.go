@@ -0,0 +1,149 @@
+// Package httpadmin exposes the migration state of a GoMigration as JSON over
+// HTTP, so internal tooling can inspect it without a shell in the pod:
+//
+//	GET  /status   the gomigration.MigrationStatus
+//	GET  /list     every registered migration, as gomigration.List returns it
+//	GET  /pending  the registered migrations not executed yet
+//	POST /migrate  runs Migrate, then responds with the status
+//
+// POST /migrate changes the database, so it is refused unless Config.Token or
+// Config.Authorize is set. Mount the Handler under a prefix with
+// http.StripPrefix, e.g. /admin/migrations/, on an internal listener.
+package httpadmin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/openframebox/gomigration"
+)
+
+// Config configures a Handler.
+type Config struct {
+	// Token is the bearer token POST /migrate requires in its Authorization
+	// header.
+	Token string
+	// Authorize, if set, decides instead of Token whether r may trigger a
+	// migration, e.g. by checking a client certificate.
+	Authorize func(r *http.Request) bool
+}
+
+// Handler serves the endpoints of the package documentation.
+type Handler struct {
+	migration *gomigration.GoMigration
+	config    Config
+	mux       *http.ServeMux
+}
+
+// New creates a Handler serving the state of migration. config may be nil,
+// leaving POST /migrate disabled.
+func New(migration *gomigration.GoMigration, config *Config) *Handler {
+	h := &Handler{migration: migration, mux: http.NewServeMux()}
+	if config != nil {
+		h.config = *config
+	}
+
+	h.mux.HandleFunc("GET /status", h.status)
+	h.mux.HandleFunc("GET /list", h.list)
+	h.mux.HandleFunc("GET /pending", h.pending)
+	h.mux.HandleFunc("POST /migrate", h.migrate)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	status, err := h.migration.Status(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	migrations, err := h.migration.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, migrations)
+}
+
+func (h *Handler) pending(w http.ResponseWriter, r *http.Request) {
+	migrations, err := h.migration.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	pending := gomigration.RegisteredMigrationList{}
+	for _, m := range migrations {
+		if !m.IsExecuted {
+			pending = append(pending, m)
+		}
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+// migrate runs Migrate for an authorized request. The run is not canceled when
+// the client goes away, so a dropped connection does not interrupt it.
+func (h *Handler) migrate(w http.ResponseWriter, r *http.Request) {
+	if h.config.Token == "" && h.config.Authorize == nil {
+		writeError(w, http.StatusForbidden, errors.New("migrating over HTTP is disabled"))
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("not authorized to migrate"))
+		return
+	}
+
+	ctx := context.WithoutCancel(r.Context())
+	if err := h.migration.Migrate(ctx); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, gomigration.ErrLockNotAcquired) {
+			code = http.StatusConflict
+		}
+		writeError(w, code, err)
+		return
+	}
+
+	status, err := h.migration.Status(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// authorized reports whether r may trigger a migration.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.config.Authorize != nil {
+		return h.config.Authorize(r)
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.config.Token)) == 1
+}
+
+// errorResponse is the body of failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError writes err, with the credentials in its message masked.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: gomigration.MaskCredentials(err.Error())})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,89 @@
+package httpadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/openframebox/gomigration"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMigration struct {
+	name string
+	up   string
+}
+
+func (m testMigration) Name() string       { return m.name }
+func (m testMigration) UpScript() string   { return m.up }
+func (m testMigration) DownScript() string { return "" }
+
+func newMigration(t *testing.T) *gomigration.GoMigration {
+	driver, err := gomigration.NewSqliteDriver(filepath.Join(t.TempDir(), "admin.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { driver.Close() })
+
+	q, err := gomigration.New(&gomigration.Config{Driver: driver, MigrationTableName: "migrations"})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(
+		testMigration{name: "001_create_users", up: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		testMigration{name: "002_create_posts", up: "CREATE TABLE posts (id INTEGER PRIMARY KEY);"},
+	))
+	return q
+}
+
+func serve(h http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	h := New(newMigration(t), &Config{Token: "s3cr3t"})
+
+	rec := serve(h, http.MethodGet, "/pending", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var pending gomigration.RegisteredMigrationList
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pending))
+	assert.Len(t, pending, 2)
+
+	rec = serve(h, http.MethodPost, "/migrate", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"error": "not authorized to migrate"}`, rec.Body.String())
+
+	rec = serve(h, http.MethodPost, "/migrate", "s3cr3t")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var status gomigration.MigrationStatus
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, 2, status.Executed)
+	assert.True(t, status.UpToDate)
+
+	rec = serve(h, http.MethodGet, "/list", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var list gomigration.RegisteredMigrationList
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	if assert.Len(t, list, 2) {
+		assert.True(t, list[0].IsExecuted)
+	}
+
+	rec = serve(h, http.MethodGet, "/migrate", "s3cr3t")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandler_MigrateDisabled(t *testing.T) {
+	h := New(newMigration(t), nil)
+
+	rec := serve(h, http.MethodPost, "/migrate", "anything")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serve(h, http.MethodGet, "/status", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"pending":2`)
+}
@@ -0,0 +1,195 @@
+package gomigration
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MigrationIDGenerator returns the prefix Create gives a migration created at
+// now. The prefixes of successive migrations must sort as strings in the
+// order they are created, and may only hold letters and digits, as they are
+// part of the name of the generated Go type.
+type MigrationIDGenerator func(now time.Time) (string, error)
+
+// NamingStrategy decides how Create prefixes the names of new migrations.
+type NamingStrategy int
+
+const (
+	// NamingTimestamp prefixes new migrations with the ID returned by
+	// Config.MigrationIDGenerator, a timestamp such as 20250301120000 by
+	// default.
+	NamingTimestamp NamingStrategy = iota
+	// NamingSequential prefixes new migrations with the number following the
+	// highest one used by the migration files directory and the registered
+	// migrations, zero-padded to four digits, such as 0042. Timestamp
+	// prefixes are not counted.
+	NamingSequential
+)
+
+// sequentialIDWidth is the minimum number of digits of sequential prefixes.
+const sequentialIDWidth = 4
+
+// nextSequentialID returns the sequential prefix following the numeric
+// prefixes of names, as many digits wide as the widest of them and at least
+// sequentialIDWidth. It fails with ErrMigrationNumberCollision if two names
+// share a number, as happens when branches adding migrations are merged.
+func nextSequentialID(names []string) (string, error) {
+	width := sequentialIDWidth
+	last := -1
+	used := make(map[int]string)
+	var collisions []string
+	for _, name := range names {
+		prefix := numericPrefix(name)
+		if prefix == "" || len(prefix) >= 14 {
+			continue
+		}
+		n, err := strconv.Atoi(prefix)
+		if err != nil {
+			continue
+		}
+		if other, ok := used[n]; ok && other != name {
+			collisions = append(collisions, fmt.Sprintf("%s and %s", other, name))
+		}
+		used[n] = name
+		width = max(width, len(prefix))
+		last = max(last, n)
+	}
+	if len(collisions) > 0 {
+		return "", fmt.Errorf("%w: %s, renumber one of each pair", ErrMigrationNumberCollision, strings.Join(collisions, ", "))
+	}
+	return fmt.Sprintf("%0*d", width, last+1), nil
+}
+
+// TimestampID is the default MigrationIDGenerator: now with a second
+// resolution, such as 20250301120000.
+func TimestampID(now time.Time) (string, error) {
+	return now.Format("20060102150405"), nil
+}
+
+const (
+	// crockfordAlphabet is the Crockford base32 alphabet of ULIDs.
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// base62Alphabet is the alphabet of KSUIDs, in ASCII order.
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// ksuidEpoch is the Unix time KSUID timestamps count from.
+	ksuidEpoch = 1400000000
+)
+
+// migrationIDPattern matches a migration name starting with a ULID or a
+// KSUID followed by an underscore.
+var migrationIDPattern = regexp.MustCompile(`^(?:[0-9A-HJKMNP-TV-Z]{26}|[0-9A-Za-z]{27})_`)
+
+// NewULIDGenerator returns a MigrationIDGenerator of ULIDs: a millisecond
+// timestamp followed by 80 random bits, 26 characters in all. IDs generated
+// within the same millisecond increment the random part of the previous one,
+// so they stay ordered.
+func NewULIDGenerator() MigrationIDGenerator {
+	g := &monotonicID{timeSize: 6, size: 16}
+	return func(now time.Time) (string, error) {
+		id, err := g.next(uint64(now.UnixMilli()))
+		if err != nil {
+			return "", err
+		}
+		return encodeID(id, crockfordAlphabet, 26), nil
+	}
+}
+
+// NewKSUIDGenerator returns a MigrationIDGenerator of KSUIDs: a second
+// timestamp followed by 128 random bits, 27 characters in all. IDs generated
+// within the same second increment the random part of the previous one, so
+// they stay ordered.
+func NewKSUIDGenerator() MigrationIDGenerator {
+	g := &monotonicID{timeSize: 4, size: 20}
+	return func(now time.Time) (string, error) {
+		seconds := now.Unix() - ksuidEpoch
+		if seconds < 0 {
+			return "", errors.New("time is before the KSUID epoch")
+		}
+		id, err := g.next(uint64(seconds))
+		if err != nil {
+			return "", err
+		}
+		return encodeID(id, base62Alphabet, 27), nil
+	}
+}
+
+// monotonicID generates IDs of size bytes made of a big endian timestamp of
+// timeSize bytes followed by random bytes.
+type monotonicID struct {
+	timeSize int
+	size     int
+
+	mu   sync.Mutex
+	last []byte
+}
+
+// next returns the ID of timestamp ts: the previous one incremented if ts
+// did not move forward, a new random one otherwise.
+func (g *monotonicID) next(ts uint64) ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := make([]byte, g.size)
+	for i := g.timeSize - 1; i >= 0; i-- {
+		id[i] = byte(ts)
+		ts >>= 8
+	}
+
+	if g.last != nil && string(id[:g.timeSize]) <= string(g.last[:g.timeSize]) {
+		copy(id, g.last)
+		i := g.size - 1
+		for ; i >= g.timeSize; i-- {
+			id[i]++
+			if id[i] != 0 {
+				break
+			}
+		}
+		if i < g.timeSize {
+			return nil, errors.New("too many IDs generated within the same time unit")
+		}
+	} else if _, err := rand.Read(id[g.timeSize:]); err != nil {
+		return nil, err
+	}
+
+	g.last = id
+	return id, nil
+}
+
+// encodeID encodes id in alphabet, left-padded with its first character to
+// length characters.
+func encodeID(id []byte, alphabet string, length int) string {
+	n := new(big.Int).SetBytes(id)
+	base := big.NewInt(int64(len(alphabet)))
+	digit := new(big.Int)
+
+	var b strings.Builder
+	encoded := make([]byte, 0, length)
+	for n.Sign() > 0 {
+		n.DivMod(n, base, digit)
+		encoded = append(encoded, alphabet[digit.Int64()])
+	}
+	for range length - len(encoded) {
+		b.WriteByte(alphabet[0])
+	}
+	for i := len(encoded) - 1; i >= 0; i-- {
+		b.WriteByte(encoded[i])
+	}
+	return b.String()
+}
+
+// migrationPrefix returns the prefix ordering a migration name: its ULID or
+// KSUID, or else its leading digits, such as the timestamp of migrations
+// created with TimestampID.
+func migrationPrefix(name string) string {
+	if loc := migrationIDPattern.FindStringIndex(name); loc != nil {
+		return name[:loc[1]-1]
+	}
+	return numericPrefix(name)
+}
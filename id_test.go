@@ -0,0 +1,107 @@
+package gomigration
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampID(t *testing.T) {
+	id, err := TimestampID(time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local))
+	assert.NoError(t, err)
+	assert.Equal(t, "20250301120000", id)
+}
+
+func TestMigrationIDGenerators(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name     string
+		generate MigrationIDGenerator
+		length   int
+	}{
+		{"ulid", NewULIDGenerator(), 26},
+		{"ksuid", NewKSUIDGenerator(), 27},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			// The same instant twice, then a later one, then an earlier one.
+			for _, at := range []time.Time{now, now, now.Add(time.Second), now} {
+				id, err := tt.generate(at)
+				assert.NoError(t, err)
+				assert.Len(t, id, tt.length)
+				assert.True(t, migrationIDPattern.MatchString(id+"_x"), id)
+				ids = append(ids, id)
+			}
+			assert.True(t, slices.IsSorted(ids), ids)
+			assert.Len(t, slices.Compact(slices.Clone(ids)), len(ids))
+		})
+	}
+
+	_, err := NewKSUIDGenerator()(time.Unix(0, 0))
+	assert.Error(t, err)
+}
+
+func TestEncodeID(t *testing.T) {
+	assert.Equal(t, "00000000000000000000000001", encodeID([]byte{1}, crockfordAlphabet, 26))
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeID(slices.Repeat([]byte{0xff}, 16), crockfordAlphabet, 26))
+	assert.Equal(t, "aWgEPTl1tmebfsQzFP4bxwgy80V", encodeID(slices.Repeat([]byte{0xff}, 20), base62Alphabet, 27))
+}
+
+func TestGoMigration_Create_MigrationIDGenerator(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	q := &GoMigration{migrationFilesDir: dir, migrations: make(map[string]Migration), migrationID: NewULIDGenerator()}
+
+	assert.NoError(t, q.Create("create users"))
+	assert.NoError(t, q.Create("create posts"))
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 2) {
+		assert.Regexp(t, `^[0-9A-HJKMNP-TV-Z]{26}_create_(users|posts)\.go$`, files[0].Name())
+		code, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+		assert.NoError(t, err)
+		assert.Contains(t, string(code), "type M"+files[0].Name()[:26])
+	}
+}
+
+func TestNextSequentialID(t *testing.T) {
+	id, err := nextSequentialID(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "0000", id)
+
+	id, err = nextSequentialID([]string{"0001_create_users", "0041_add_email", "20250301120000_legacy", "README"})
+	assert.NoError(t, err)
+	assert.Equal(t, "0042", id)
+
+	id, err = nextSequentialID([]string{"00099_create_users"})
+	assert.NoError(t, err)
+	assert.Equal(t, "00100", id)
+
+	_, err = nextSequentialID([]string{"0041_add_email", "0041_add_phone", "0042_add_index"})
+	assert.ErrorIs(t, err, ErrMigrationNumberCollision)
+	assert.ErrorContains(t, err, "0041_add_email and 0041_add_phone")
+}
+
+func TestGoMigration_Create_NamingSequential(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "0001_create_users.up.sql"), []byte("CREATE TABLE users (id INTEGER);"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "0001_create_users.down.sql"), []byte("DROP TABLE users;"), 0644))
+	q := &GoMigration{migrationFilesDir: dir, migrations: make(map[string]Migration), namingStrategy: NamingSequential}
+	assert.NoError(t, q.Register(dummyMigration{name: "0002_seed_roles"}))
+
+	assert.NoError(t, q.CreateSQL("create posts"))
+	assert.NoError(t, q.Create("create tags"))
+	assert.FileExists(t, filepath.Join(dir, "0003_create_posts.sql"))
+	code, err := os.ReadFile(filepath.Join(dir, "0004_create_tags.go"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(code), "type M0004CreateTags struct")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "0004_create_comments.sql"), []byte("-- +migrate Up\n"), 0644))
+	assert.ErrorIs(t, q.Create("create likes"), ErrMigrationNumberCollision)
+}
@@ -0,0 +1,81 @@
+package gomigration
+
+import (
+	"context"
+	"sync"
+)
+
+// interruptGuard shields the migration in flight from the cancellation of
+// the context of a run. Its context is cancelled once the parent context is
+// done and no migration is in flight, so the migration running when the run
+// is interrupted finishes, and is recorded, while the next one fails before
+// running any statement.
+type interruptGuard struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	parent    context.Context
+	stopAfter func() bool
+
+	mu          sync.Mutex
+	inFlight    bool
+	interrupted bool
+}
+
+// newInterruptGuard guards a run under parent. Only the cancellation of parent
+// is shielded: its deadline still bounds the migration in flight.
+func newInterruptGuard(parent context.Context) *interruptGuard {
+	g := &interruptGuard{parent: parent}
+	if deadline, ok := parent.Deadline(); ok {
+		g.ctx, g.cancel = context.WithDeadline(context.WithoutCancel(parent), deadline)
+	} else {
+		g.ctx, g.cancel = context.WithCancel(context.WithoutCancel(parent))
+	}
+	// Stop a driver busy between migrations, e.g. checking conditions.
+	g.stopAfter = context.AfterFunc(parent, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if !g.inFlight {
+			g.cancel()
+		}
+	})
+	return g
+}
+
+// begin marks a migration as in flight. It returns false when the run was
+// interrupted, in which case the migration fails without running.
+func (g *interruptGuard) begin() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.parent.Err() != nil {
+		g.interrupted = true
+		g.cancel()
+		return false
+	}
+	g.inFlight = true
+	return true
+}
+
+// end marks the migration in flight as done, stopping the run if it was
+// interrupted meanwhile.
+func (g *interruptGuard) end() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inFlight = false
+	if g.parent.Err() != nil {
+		g.cancel()
+	}
+}
+
+// wasInterrupted reports whether a migration was kept from starting because
+// the parent context was done.
+func (g *interruptGuard) wasInterrupted() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.interrupted
+}
+
+// stop releases the guard once the run ended.
+func (g *interruptGuard) stop() {
+	g.stopAfter()
+	g.cancel()
+}
@@ -0,0 +1,129 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGoMigration_Migrate_Interrupted(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "interrupt.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_create_users": &scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+			"002_create_posts": &scriptMigration{name: "002_create_posts", upScript: "CREATE TABLE posts (id INTEGER PRIMARY KEY);"},
+			"003_create_tags":  &scriptMigration{name: "003_create_tags", upScript: "CREATE TABLE tags (id INTEGER PRIMARY KEY);"},
+		},
+	}
+
+	// Cancel as soon as the first migration starts, e.g. on Ctrl-C.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Subscribe(EventListenerFunc(func(_ context.Context, event Event) {
+		if event.Type == EventMigrationStarted {
+			cancel()
+		}
+	}))
+
+	err = q.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrInterrupted)
+	assert.ErrorIs(t, err, context.Canceled)
+	var interrupted *InterruptedError
+	if assert.ErrorAs(t, err, &interrupted) {
+		assert.Equal(t, []string{"001_create_users"}, interrupted.Applied)
+		assert.Equal(t, []string{"002_create_posts", "003_create_tags"}, interrupted.Remaining)
+	}
+	assert.EqualError(t, err, "migration run interrupted after applying 1 of 3 migration(s): context canceled")
+
+	// The migration in flight finished and was recorded.
+	executed, err := driver.GetExecutedMigrations(context.Background(), HistoryOrderApplied)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 1) {
+		assert.Equal(t, "001_create_users", executed[0].Name)
+	}
+	_, err = driver.db.Exec(`SELECT id FROM posts`)
+	assert.ErrorContains(t, err, "no such table")
+}
+
+func TestCli_HandleSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts cannot be sent to the own process on Windows")
+	}
+
+	q := &GoMigration{}
+	cli, err := NewCli(CliConfig{GoMigration: q, Locale: "en"})
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	ctx, stop := cli.handleSignals(context.Background(), &out)
+	defer stop()
+
+	q.emit(context.Background(), Event{Type: EventMigrationStarted, Migration: "002_backfill_totals"})
+	process, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, process.Signal(os.Interrupt))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not cancelled on interrupt")
+	}
+	assert.Equal(t, "Interrupted: finishing migration 002_backfill_totals before stopping, interrupt again to abort it\n", out.String())
+}
+
+func TestGoMigration_Migrate_ContextDeadline(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "deadline.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations: map[string]Migration{
+			"001_hang": &scriptMigration{name: "001_hang", upScript: "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c;"},
+		},
+	}
+
+	// The deadline of the context still bounds the migration in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- q.Migrate(ctx) }()
+
+	select {
+	case err = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("migration not stopped at the deadline of the context")
+	}
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, ErrInterrupted)
+	var deadlineErr *DeadlineError
+	if assert.ErrorAs(t, err, &deadlineErr) {
+		assert.Equal(t, "001_hang", deadlineErr.Migration)
+	}
+}
+
+// testContextKey tags the context of a test, so runContext can tell it apart.
+type testContextKey struct{}
+
+// runContext matches the context a run passes to the driver: ctx shielded
+// from cancellation by the interrupt guard, keeping its values and deadline.
+func runContext(ctx context.Context) any {
+	return mock.MatchedBy(func(c context.Context) bool {
+		want, hasDeadline := ctx.Deadline()
+		got, ok := c.Deadline()
+		return c.Value(testContextKey{}) == ctx.Value(testContextKey{}) && ok == hasDeadline && got.Equal(want)
+	})
+}
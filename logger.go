@@ -0,0 +1,116 @@
+package gomigration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// runLogger writes the log of GoMigration and the built-in drivers: to
+// Config.Logger when set, as records with a level and attributes, and to the
+// standard logger otherwise, as the lines of text it always wrote.
+type runLogger struct {
+	logger *slog.Logger
+	// warnings counts the warnings written to logger, which the CLI reports
+	// in its run summary.
+	warnings *atomic.Int32
+	// logSQL makes the statements of migrations logged as they run, see
+	// Config.LogSQL.
+	logSQL *atomic.Bool
+}
+
+// newRunLogger creates a runLogger writing to logger, or to the standard
+// logger when it is nil, logging every statement run when logSQL is set.
+func newRunLogger(logger *slog.Logger, logSQL bool) runLogger {
+	l := runLogger{logger: logger, warnings: &atomic.Int32{}, logSQL: &atomic.Bool{}}
+	l.logSQL.Store(logSQL)
+	return l
+}
+
+// log logs msg at level with the attributes args, given as key-value pairs
+// like slog.Logger.Log. Without a slog.Logger, text is printed instead.
+// Credentials are masked either way.
+func (l runLogger) log(level slog.Level, text, msg string, args ...any) {
+	if l.logger == nil {
+		log.Println(MaskCredentials(text))
+		return
+	}
+	if level == slog.LevelWarn && l.warnings != nil {
+		l.warnings.Add(1)
+	}
+	l.logger.Log(context.Background(), level, MaskCredentials(msg), maskArgs(args)...)
+}
+
+func (l runLogger) info(text, msg string, args ...any) {
+	l.log(slog.LevelInfo, text, msg, args...)
+}
+
+func (l runLogger) warn(text, msg string, args ...any) {
+	l.log(slog.LevelWarn, text, msg, args...)
+}
+
+func (l runLogger) error(text, msg string, args ...any) {
+	l.log(slog.LevelError, text, msg, args...)
+}
+
+// sql logs script, the SQL of a migration or seeder, at debug level with the
+// attributes args. Without a slog.Logger, it is printed only when debug, the
+// DebugSql option, is set.
+func (l runLogger) sql(debug bool, script string, args ...any) {
+	if l.logger == nil {
+		if debug {
+			log.Println("🧾 Running SQL:")
+			printScript(MaskCredentials(script))
+		}
+		return
+	}
+	l.logger.Debug("running SQL", maskArgs(append(args, "sql", script))...)
+}
+
+// statement logs query, run in duration with the result res or the error err,
+// when statements are logged.
+func (l runLogger) statement(query string, duration time.Duration, res sql.Result, err error) {
+	if l.logSQL == nil || !l.logSQL.Load() {
+		return
+	}
+
+	query = strings.TrimSpace(query)
+	duration = duration.Round(time.Microsecond)
+	if err != nil {
+		l.error(fmt.Sprintf("🧾 %s (%s): %v", query, duration, err), "statement failed", "sql", query, "duration", duration, "error", err)
+		return
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		l.info(fmt.Sprintf("🧾 %s (%s)", query, duration), "executed statement", "sql", query, "duration", duration)
+		return
+	}
+	l.info(fmt.Sprintf("🧾 %s (%s, %d row(s))", query, duration, rows), "executed statement", "sql", query, "duration", duration, "rows", rows)
+}
+
+// setLogSQL turns the logging of statements on or off, returning whether it
+// was on. It does nothing on a runLogger not created by newRunLogger.
+func (l runLogger) setLogSQL(on bool) bool {
+	if l.logSQL == nil {
+		return false
+	}
+	return l.logSQL.Swap(on)
+}
+
+// warningCount returns the number of warnings written to the slog.Logger.
+func (l runLogger) warningCount() int {
+	if l.warnings == nil {
+		return 0
+	}
+	return int(l.warnings.Load())
+}
+
+// loggingDriver is implemented by drivers writing to the log of GoMigration.
+type loggingDriver interface {
+	setLogger(logger runLogger)
+}
@@ -0,0 +1,117 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoMigration_Migrate_Logger(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "logger.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	var out bytes.Buffer
+	q, err := New(&Config{
+		Driver:             driver,
+		MigrationTableName: "migrations",
+		Logger:             slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(
+		&scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		&scriptMigration{name: "002_create_users_again", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+	))
+
+	assert.Error(t, q.Migrate(context.Background()))
+
+	type record struct {
+		Level     string `json:"level"`
+		Msg       string `json:"msg"`
+		Migration string `json:"migration"`
+		Count     int    `json:"count"`
+		SQL       string `json:"sql"`
+		Error     string `json:"error"`
+	}
+	var records []record
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r record
+		assert.NoError(t, dec.Decode(&r))
+		records = append(records, r)
+	}
+
+	assert.Equal(t, []record{
+		{Level: "INFO", Msg: "applying migrations", Count: 2},
+		{Level: "INFO", Msg: "migrating", Migration: "001_create_users"},
+		{Level: "DEBUG", Msg: "running SQL", Migration: "001_create_users", SQL: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		{Level: "INFO", Msg: "migrated", Migration: "001_create_users"},
+		{Level: "INFO", Msg: "migrating", Migration: "002_create_users_again"},
+		{Level: "DEBUG", Msg: "running SQL", Migration: "002_create_users_again", SQL: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		{Level: "ERROR", Msg: "migration failed", Migration: "002_create_users_again", Error: records[len(records)-1].Error},
+	}, records)
+	assert.Contains(t, records[len(records)-1].Error, "table users already exists")
+}
+
+func TestRunLogger_WarningCount(t *testing.T) {
+	var out bytes.Buffer
+	logger := newRunLogger(slog.New(slog.NewTextHandler(&out, nil)), false)
+
+	logger.info("✅ Migrated: 001_create_users", "migrated", "migration", "001_create_users")
+	logger.warn("⚠️ Already marked as applied: 001_create_users", "already marked as applied", "migration", "001_create_users")
+
+	assert.Equal(t, 1, logger.warningCount())
+	assert.Contains(t, out.String(), `level=WARN msg="already marked as applied" migration=001_create_users`)
+	assert.Equal(t, 0, runLogger{}.warningCount())
+}
+
+func TestGoMigration_Migrate_LogSQL(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "logsql.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	var out bytes.Buffer
+	q, err := New(&Config{
+		Driver:             driver,
+		MigrationTableName: "migrations",
+		Logger:             slog.New(slog.NewJSONHandler(&out, nil)),
+		LogSQL:             true,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(
+		&scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);\nINSERT INTO users (id) VALUES (1), (2);"},
+	))
+
+	assert.NoError(t, q.Migrate(context.Background()))
+
+	type record struct {
+		Msg      string `json:"msg"`
+		SQL      string `json:"sql"`
+		Rows     *int   `json:"rows"`
+		Duration *int64 `json:"duration"`
+	}
+	var statements []record
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r record
+		assert.NoError(t, dec.Decode(&r))
+		if r.Msg == "executed statement" {
+			statements = append(statements, r)
+		}
+	}
+
+	if assert.NotEmpty(t, statements) {
+		first := statements[0]
+		assert.Equal(t, "CREATE TABLE users (id INTEGER PRIMARY KEY);\nINSERT INTO users (id) VALUES (1), (2);", first.SQL)
+		if assert.NotNil(t, first.Rows) {
+			assert.Equal(t, 2, *first.Rows)
+		}
+		assert.NotNil(t, first.Duration)
+	}
+	assert.Greater(t, len(statements), 1, "tracking table updates are logged too")
+}
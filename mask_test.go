@@ -0,0 +1,65 @@
+package gomigration
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskCredentials(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   "host=db port=5432 user=app password=s3cr3t dbname=app sslmode=disable",
+			want: "host=db port=5432 user=app password=xxxxx dbname=app sslmode=disable",
+		},
+		{
+			in:   "host=db user=app password='with space' dbname=app",
+			want: "host=db user=app password=xxxxx dbname=app",
+		},
+		{
+			in:   "dial postgres://app:s3cr3t@db:5432/app?sslmode=disable: connection refused",
+			want: "dial postgres://app:xxxxx@db:5432/app?sslmode=disable: connection refused",
+		},
+		{
+			in:   "invalid DSN app:p@ss:word@tcp(db:3306)/app?charset=utf8mb4",
+			want: "invalid DSN app:xxxxx@tcp(db:3306)/app?charset=utf8mb4",
+		},
+		{
+			in:   "sqlserver://db?database=app&password=s3cr3t&encrypt=true",
+			want: "sqlserver://db?database=app&password=xxxxx&encrypt=true",
+		},
+		{
+			in:   "migration 001_create_users applied at 12:30:00",
+			want: "migration 001_create_users applied at 12:30:00",
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MaskCredentials(tt.in))
+	}
+}
+
+func TestMaskError(t *testing.T) {
+	cause := errors.New("cannot parse `host=db password=s3cr3t`: invalid port")
+	err := maskError(cause)
+
+	assert.EqualError(t, err, "cannot parse `host=db password=xxxxx`: invalid port")
+	assert.ErrorIs(t, err, cause)
+	assert.NoError(t, maskError(nil))
+}
+
+func TestRunLogger_MasksCredentials(t *testing.T) {
+	var out bytes.Buffer
+	logger := newRunLogger(slog.New(slog.NewTextHandler(&out, nil)), false)
+
+	logger.warn("⏳ Database not reachable", "database not reachable, retrying", "error", errors.New("dial app:s3cr3t@tcp(db:3306)/app: refused"))
+
+	assert.Contains(t, out.String(), `error="dial app:xxxxx@tcp(db:3306)/app: refused"`)
+	assert.NotContains(t, out.String(), "s3cr3t")
+}
@@ -0,0 +1,494 @@
+package gomigration
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// MessageKey identifies a user-facing CLI message in a Catalog.
+type MessageKey string
+
+// Keys of the messages printed by the CLI. Messages ending in a colon are
+// followed by the error they describe.
+const (
+	MsgRootShort               MessageKey = "root.short"
+	MsgListShort               MessageKey = "list.short"
+	MsgListError               MessageKey = "list.error"
+	MsgStatusShort             MessageKey = "status.short"
+	MsgStatusError             MessageKey = "status.error"
+	MsgStatusWriteError        MessageKey = "status.write_error"
+	MsgStatusFlagJSON          MessageKey = "status.flag.json"
+	MsgMigrateShort            MessageKey = "migrate.short"
+	MsgMigrateInvalidFresh     MessageKey = "migrate.invalid_fresh"
+	MsgMigrateDryRunWithFresh  MessageKey = "migrate.dry_run_with_fresh"
+	MsgMigrateDryRunError      MessageKey = "migrate.dry_run_error"
+	MsgMigrateFreshError       MessageKey = "migrate.fresh_error"
+	MsgMigrateError            MessageKey = "migrate.error"
+	MsgMigrateFlagFresh        MessageKey = "migrate.flag.fresh"
+	MsgMigrateFlagStep         MessageKey = "migrate.flag.step"
+	MsgMigrateFlagTo           MessageKey = "migrate.flag.to"
+	MsgMigrateFlagDryRun       MessageKey = "migrate.flag.dry_run"
+	MsgPlanShort               MessageKey = "plan.short"
+	MsgPlanCreateFileError     MessageKey = "plan.create_file_error"
+	MsgPlanError               MessageKey = "plan.error"
+	MsgPlanWritten             MessageKey = "plan.written"
+	MsgPlanFlagOut             MessageKey = "plan.flag.out"
+	MsgRollbackShort           MessageKey = "rollback.short"
+	MsgRollbackError           MessageKey = "rollback.error"
+	MsgRollbackInvalidStep     MessageKey = "rollback.invalid_step"
+	MsgRollbackStepNotPositive MessageKey = "rollback.step_not_positive"
+	MsgRollbackFlagStep        MessageKey = "rollback.flag.step"
+	MsgRollbackFlagTo          MessageKey = "rollback.flag.to"
+	MsgRollbackFlagInclusive   MessageKey = "rollback.flag.inclusive"
+	MsgRollbackFlagBatch       MessageKey = "rollback.flag.batch"
+	MsgRollbackFlagDryRun      MessageKey = "rollback.flag.dry_run"
+	MsgResetShort              MessageKey = "reset.short"
+	MsgResetError              MessageKey = "reset.error"
+	MsgCleanShort              MessageKey = "clean.short"
+	MsgCleanError              MessageKey = "clean.error"
+	MsgMarkShort               MessageKey = "mark.short"
+	MsgMarkError               MessageKey = "mark.error"
+	MsgMarkFlagApplied         MessageKey = "mark.flag.applied"
+	MsgMarkFlagUnapplied       MessageKey = "mark.flag.unapplied"
+	MsgRepairShort             MessageKey = "repair.short"
+	MsgRepairError             MessageKey = "repair.error"
+	MsgValidateShort           MessageKey = "validate.short"
+	MsgValidateError           MessageKey = "validate.error"
+	MsgUpgradeShort            MessageKey = "upgrade_tracking_table.short"
+	MsgUpgradeError            MessageKey = "upgrade_tracking_table.error"
+	MsgUpgradeNotSupported     MessageKey = "upgrade_tracking_table.not_supported"
+	MsgCreateShort             MessageKey = "create.short"
+	MsgCreateError             MessageKey = "create.error"
+	MsgCreateFlagName          MessageKey = "create.flag.name"
+	MsgCreateFlagDir           MessageKey = "create.flag.dir"
+	MsgCreateFlagSql           MessageKey = "create.flag.sql"
+	MsgCreateFlagType          MessageKey = "create.flag.type"
+	MsgCreateInvalidType       MessageKey = "create.invalid_type"
+	MsgDiffShort               MessageKey = "diff.short"
+	MsgDiffError               MessageKey = "diff.error"
+	MsgDiffFlagSchema          MessageKey = "diff.flag.schema"
+	MsgSquashShort             MessageKey = "squash.short"
+	MsgSquashError             MessageKey = "squash.error"
+	MsgSquashFlagTo            MessageKey = "squash.flag.to"
+	MsgDiagramShort            MessageKey = "diagram.short"
+	MsgDiagramCreateFileError  MessageKey = "diagram.create_file_error"
+	MsgDiagramError            MessageKey = "diagram.error"
+	MsgDiagramWritten          MessageKey = "diagram.written"
+	MsgDiagramFlagFormat       MessageKey = "diagram.flag.format"
+	MsgDiagramFlagFrom         MessageKey = "diagram.flag.from"
+	MsgDiagramFlagTo           MessageKey = "diagram.flag.to"
+	MsgDiagramFlagOut          MessageKey = "diagram.flag.out"
+	MsgSeedShort               MessageKey = "seed.short"
+	MsgSeedError               MessageKey = "seed.error"
+	MsgSeedFlagEnv             MessageKey = "seed.flag.env"
+	MsgSeedFlagOnly            MessageKey = "seed.flag.only"
+	MsgFixturesShort           MessageKey = "fixtures.short"
+	MsgFixturesLoadShort       MessageKey = "fixtures.load.short"
+	MsgFixturesLoadError       MessageKey = "fixtures.load.error"
+	MsgFixturesFlagDir         MessageKey = "fixtures.flag.dir"
+	MsgFixturesFlagTruncate    MessageKey = "fixtures.flag.truncate"
+	MsgFixturesFlagOnly        MessageKey = "fixtures.flag.only"
+	MsgPlanFlagEstimateFrom    MessageKey = "plan.flag.estimate_from"
+	MsgPlanEstimateError       MessageKey = "plan.estimate_error"
+	MsgMigrateFlagPhase        MessageKey = "migrate.flag.phase"
+	MsgMigrateFlagTags         MessageKey = "migrate.flag.tags"
+	MsgMigrateFlagKeepHistory  MessageKey = "migrate.flag.keep-history"
+	MsgCleanFlagKeepHistory    MessageKey = "clean.flag.keep-history"
+	MsgMigrateFlagTenant       MessageKey = "migrate.flag.tenant"
+	MsgMigrateFlagAllTenants   MessageKey = "migrate.flag.all-tenants"
+	MsgMigrateFlagSeed         MessageKey = "migrate.flag.seed"
+	MsgEventsOutFlag           MessageKey = "events_out.flag"
+	MsgEventsOutError          MessageKey = "events_out.error"
+	MsgSummaryOutFlag          MessageKey = "summary_out.flag"
+	MsgSummaryOutError         MessageKey = "summary_out.error"
+	MsgOutputFlag              MessageKey = "output.flag"
+	MsgProgressFlag            MessageKey = "progress.flag"
+	MsgVerboseFlag             MessageKey = "verbose.flag"
+	MsgOutputInvalid           MessageKey = "output.invalid"
+	MsgRenderError             MessageKey = "output.render_error"
+	MsgInterruptedMigration    MessageKey = "interrupt.migration"
+	MsgInterruptedIdle         MessageKey = "interrupt.idle"
+	MsgRootLong                MessageKey = "root.long"
+	MsgListLong                MessageKey = "list.long"
+	MsgStatusLong              MessageKey = "status.long"
+	MsgMigrateLong             MessageKey = "migrate.long"
+	MsgPlanLong                MessageKey = "plan.long"
+	MsgRollbackLong            MessageKey = "rollback.long"
+	MsgResetLong               MessageKey = "reset.long"
+	MsgCleanLong               MessageKey = "clean.long"
+	MsgMarkLong                MessageKey = "mark.long"
+	MsgRepairLong              MessageKey = "repair.long"
+	MsgValidateLong            MessageKey = "validate.long"
+	MsgUpgradeLong             MessageKey = "upgrade_tracking_table.long"
+	MsgCreateLong              MessageKey = "create.long"
+	MsgDiffLong                MessageKey = "diff.long"
+	MsgSquashLong              MessageKey = "squash.long"
+	MsgDiagramLong             MessageKey = "diagram.long"
+	MsgSeedLong                MessageKey = "seed.long"
+	MsgFixturesLong            MessageKey = "fixtures.long"
+	MsgFixturesLoadLong        MessageKey = "fixtures.load.long"
+	MsgHelpGroupSelection      MessageKey = "help.group.selection"
+	MsgHelpGroupMode           MessageKey = "help.group.mode"
+	MsgHelpGroupOutput         MessageKey = "help.group.output"
+	MsgHelpGroupMigrationFile  MessageKey = "help.group.migration_file"
+	MsgHelpGroupOther          MessageKey = "help.group.other"
+	MsgHelpGroupFlags          MessageKey = "help.group.flags"
+	MsgTopicsShort             MessageKey = "topics.short"
+	MsgTopicsLong              MessageKey = "topics.long"
+	MsgTopicsFooter            MessageKey = "topics.footer"
+	MsgTopicLockingShort       MessageKey = "topic.locking.short"
+	MsgTopicLockingLong        MessageKey = "topic.locking.long"
+	MsgTopicBatchesShort       MessageKey = "topic.batches.short"
+	MsgTopicBatchesLong        MessageKey = "topic.batches.long"
+	MsgTopicChecksumsShort     MessageKey = "topic.checksums.short"
+	MsgTopicChecksumsLong      MessageKey = "topic.checksums.long"
+	MsgTopicTransactionsShort  MessageKey = "topic.transactions.short"
+	MsgTopicTransactionsLong   MessageKey = "topic.transactions.long"
+)
+
+// Catalog holds the CLI messages of one locale. Keys missing from a catalog
+// fall back to English.
+type Catalog map[MessageKey]string
+
+// builtinCatalogs are the catalogs shipped with the CLI, by locale.
+var builtinCatalogs = map[string]Catalog{
+	"en": {
+		MsgRootShort:               "GoMigration CLI",
+		MsgListShort:               "List all migrations",
+		MsgListError:               "Error listing migrations:",
+		MsgStatusShort:             "Show a summary of executed and pending migrations",
+		MsgStatusError:             "Error getting migration status:",
+		MsgStatusWriteError:        "Error writing migration status:",
+		MsgStatusFlagJSON:          "Print the status as JSON",
+		MsgMigrateShort:            "Run all pending migrations",
+		MsgMigrateInvalidFresh:     "Invalid fresh flag:",
+		MsgMigrateDryRunWithFresh:  "--dry-run cannot be combined with --fresh",
+		MsgMigrateDryRunError:      "Error running migrations dry run:",
+		MsgMigrateFreshError:       "Error running fresh migrations:",
+		MsgMigrateError:            "Error running migrations:",
+		MsgMigrateFlagFresh:        "Run fresh migrations",
+		MsgMigrateFlagStep:         "Number of pending migrations to apply",
+		MsgMigrateFlagTo:           "Migrate up or down to the named migration",
+		MsgMigrateFlagDryRun:       "Print the migrations and SQL that would run without touching the database",
+		MsgPlanShort:               "Write the SQL of pending migrations for manual execution",
+		MsgPlanCreateFileError:     "Error creating plan file:",
+		MsgPlanError:               "Error generating migration plan:",
+		MsgPlanWritten:             "migration plan written to: %s",
+		MsgPlanFlagOut:             "file to write the plan to (default stdout)",
+		MsgRollbackShort:           "Rollback the last migration",
+		MsgRollbackError:           "Error rolling back migrations:",
+		MsgRollbackInvalidStep:     "Invalid step:",
+		MsgRollbackStepNotPositive: "Step must be greater than 0",
+		MsgRollbackFlagStep:        "Number of migrations to rollback",
+		MsgRollbackFlagTo:          "Rollback every migration applied after the named one",
+		MsgRollbackFlagInclusive:   "Also rollback the migration named by --to",
+		MsgRollbackFlagBatch:       "Rollback every migration applied by the last migrate run",
+		MsgRollbackFlagDryRun:      "Print the migrations and SQL that would be rolled back without touching the database",
+		MsgResetShort:              "Rollback all migrations and re-run all migrations",
+		MsgResetError:              "Error resetting migrations:",
+		MsgCleanShort:              "Clean database (delete all tables)",
+		MsgCleanError:              "Error cleaning database:",
+		MsgMarkShort:               "Mark a migration as applied or unapplied without running it",
+		MsgMarkError:               "Error marking migration:",
+		MsgMarkFlagApplied:         "Record the named migration as applied",
+		MsgMarkFlagUnapplied:       "Remove the record of the named migration",
+		MsgRepairShort:             "Fix checksum and history mismatches in the migration tracking table",
+		MsgRepairError:             "Error repairing tracking table:",
+		MsgValidateShort:           "Check the migration history and registry for inconsistencies",
+		MsgValidateError:           "Error validating migrations:",
+		MsgUpgradeShort:            "Add missing indexes and constraints to the migration tracking table",
+		MsgUpgradeError:            "Error upgrading tracking table:",
+		MsgUpgradeNotSupported:     "The driver cannot upgrade the tracking table: it does not implement TrackingTableUpgrader",
+		MsgCreateShort:             "Create a new migration",
+		MsgCreateError:             "Error creating migration:",
+		MsgCreateFlagName:          "name of the migration",
+		MsgCreateFlagDir:           "directory of the migration",
+		MsgCreateFlagSql:           "create a single SQL file with -- +migrate Up and Down sections instead of a Go file",
+		MsgCreateFlagType:          "type of the migration file: go for a Go file registering itself from init, or sql",
+		MsgCreateInvalidType:       "--type must be go or sql",
+		MsgDiffShort:               "Generate a migration from a schema file",
+		MsgDiffError:               "Error generating migration from schema:",
+		MsgDiffFlagSchema:          "schema file describing the target tables",
+		MsgSquashShort:             "Squash applied migrations into a single baseline migration",
+		MsgSquashError:             "Error squashing migrations:",
+		MsgSquashFlagTo:            "name of the last migration to squash",
+		MsgDiagramShort:            "Write an entity relationship diagram of the migrated schema",
+		MsgDiagramCreateFileError:  "Error creating diagram file:",
+		MsgDiagramError:            "Error generating diagram:",
+		MsgDiagramWritten:          "diagram written to: %s",
+		MsgDiagramFlagFormat:       "diagram notation: mermaid, plantuml or dot",
+		MsgDiagramFlagFrom:         "first migration whose tables are drawn",
+		MsgDiagramFlagTo:           "last migration whose tables are drawn",
+		MsgDiagramFlagOut:          "file to write the diagram to (default stdout)",
+		MsgSeedShort:               "Run the seeders of an environment",
+		MsgSeedError:               "Error seeding database:",
+		MsgSeedFlagEnv:             "environment to seed (default $GOMIGRATION_ENV)",
+		MsgSeedFlagOnly:            "only run the named seeders",
+		MsgFixturesShort:           "Manage fixture data",
+		MsgFixturesLoadShort:       "Load fixture files into the database",
+		MsgFixturesLoadError:       "Error loading fixtures:",
+		MsgFixturesFlagDir:         "directory of the fixture files (default Config.FixturesDir)",
+		MsgFixturesFlagTruncate:    "delete the rows of the fixture tables first",
+		MsgFixturesFlagOnly:        "only load the fixtures of the named tables",
+		MsgPlanFlagEstimateFrom:    "history export of an environment that applied the pending migrations, to estimate how long they take (repeatable)",
+		MsgPlanEstimateError:       "Error estimating migration durations:",
+		MsgMigrateFlagPhase:        "only apply the migrations of a phase: pre-deploy or post-deploy",
+		MsgMigrateFlagTags:         "only apply the migrations with one of the tags; prefix a tag with ! to leave its migrations out (repeatable)",
+		MsgMigrateFlagKeepHistory:  "with --fresh, keep the tracking table and audit log, resetting the records of registered migrations",
+		MsgCleanFlagKeepHistory:    "keep the tracking table and audit log, so the migration history survives",
+		MsgMigrateFlagTenant:       "migrate the schema or database of a single tenant",
+		MsgMigrateFlagAllTenants:   "migrate every tenant listed by the tenant provider, one after another",
+		MsgMigrateFlagSeed:         "run the seeders after migrating",
+		MsgEventsOutFlag:           "Write JSON Lines lifecycle events to this file or FIFO",
+		MsgEventsOutError:          "Error opening events output:",
+		MsgSummaryOutFlag:          "Also write the run summary as JSON to this file",
+		MsgSummaryOutError:         "Error writing run summary:",
+		MsgOutputFlag:              "Output format: table, json or quiet",
+		MsgProgressFlag:            "Show the progress of the run, migration by migration, instead of its log",
+		MsgVerboseFlag:             "Log every statement run, with its duration and the rows it affected",
+		MsgOutputInvalid:           "Invalid output format:",
+		MsgRenderError:             "Error rendering output:",
+		MsgInterruptedMigration:    "Interrupted: finishing migration %s before stopping, interrupt again to abort it",
+		MsgInterruptedIdle:         "Interrupted: stopping",
+		MsgRootLong:                "GoMigration applies, rolls back and inspects the SQL migrations registered\nwith your application. Every change is recorded in a tracking table, and\ncommands that change the database hold a lock so concurrent runs wait.",
+		MsgListLong:                "List every registered migration together with when it was executed.\nMigrations that have not run yet are shown as pending.",
+		MsgStatusLong:              "Show how many migrations are executed, pending or unknown to this build,\nthe last executed migration, and whether the database is up to date.",
+		MsgMigrateLong:             "Apply pending migrations in name order. By default every pending migration\nruns; --step applies only the next N, and --to moves the database up or\ndown to the named migration. --dry-run prints what would run, --fresh\ncleans the database before migrating, and --seed runs the seeders after.\n--phase pre-deploy or --phase post-deploy only applies the migrations of\nthat phase, and --tags only those with one of the tags, leaving out those\nwith a tag prefixed with !.",
+		MsgPlanLong:                "Write the SQL of every pending migration, including the statements that\nrecord them in the tracking table, so it can be reviewed or executed by hand.\nWith --estimate-from, the plan ends with how long the migrations are expected\nto take, from the history exported by an environment that already applied\nthem.",
+		MsgRollbackLong:            "Roll back executed migrations, newest first, by running their down scripts.\nWithout flags only the last migration is rolled back; --step rolls back N,\n--to rolls back everything applied after the named migration, and --batch\nrolls back the last migrate run.",
+		MsgResetLong:               "Roll back every executed migration and then apply all migrations again.",
+		MsgCleanLong:               "Drop every table in the database, including the tracking table unless\n--keep-history is given. This cannot be undone.",
+		MsgMarkLong:                "Record a migration as applied, or remove its record, without running any of\nits SQL. Use it when a change was made by hand.",
+		MsgRepairLong:              "Accept edited migration scripts by updating their checksums, remove records\nof migrations that are no longer registered, and fill in missing execution\ntimes. A report of every change is printed.",
+		MsgValidateLong:            "Report executed migrations that are not registered, registered migrations\nwith an empty up script, migrations sharing a numeric prefix, and edited\nmigrations whose checksum no longer matches. Nothing is changed. The\ncommand fails when any issue is found, so it can gate a CI pipeline.",
+		MsgUpgradeLong:             "Bring a tracking table created by an older version up to date by adding\nthe columns, indexes and constraints it is missing.",
+		MsgCreateLong:              "Create a Go file for a new migration in the given directory, or with --sql\na SQL file holding both scripts. With --type go, the Go file registers the\nmigration from its init function. The file name and migration name are\nprefixed with the current timestamp.",
+		MsgDiffLong:                "Compare the CREATE TABLE statements of a schema file against the database and\ncreate a migration adding the missing tables and columns. Tables and columns\nonly found in the database are listed as comments, and column types are not\ncompared, so review the migration before applying it.",
+		MsgSquashLong:              "Replace the migrations up to and including --to, which must all be applied, with\na single baseline migration creating their tables as they are in the database.\nNo later migration may be applied. The baseline file is created in --dir, the squashed files are removed from it, and the tracking\ntable records the baseline in their place. Register the baseline instead of\nthe squashed migrations afterwards.",
+		MsgDiagramLong:             "Write an entity relationship diagram of the tables, columns and foreign keys\nof the migrated database, as Mermaid, PlantUML or Graphviz DOT. With --from\nor --to, only the tables created or altered by that range of migrations are\ndrawn. The database is only read.",
+		MsgSeedLong:                "Run the registered seeders, in the order they were registered, to fill the\ndatabase with reference data or fixtures. Seeders limited to other\nenvironments than --env are skipped, and --only runs just the named ones.\nSeeders are not recorded, so they run again every time.",
+		MsgFixturesLong:            "Manage fixture data: table rows kept in YAML, JSON or CSV files, such as the\ndata of integration test databases.",
+		MsgFixturesLoadLong:        "Insert the rows of the fixture files in --dir in a single transaction. Each\nfile holds the rows of the table it is named after, such as users.yaml, and\nfiles load in name order; prefix them with numbers to load referenced tables\nfirst. --truncate deletes the rows of the fixture tables beforehand.",
+		MsgHelpGroupSelection:      "Selection Flags",
+		MsgHelpGroupMode:           "Mode Flags",
+		MsgHelpGroupOutput:         "Output Flags",
+		MsgHelpGroupMigrationFile:  "Migration File Flags",
+		MsgHelpGroupOther:          "Other Flags",
+		MsgHelpGroupFlags:          "Flags",
+		MsgTopicsShort:             "List the help topics",
+		MsgTopicsLong:              "Concepts explained by the help command:",
+		MsgTopicsFooter:            "Use \"%s help <topic>\" to read about a topic.",
+		MsgTopicLockingShort:       "How concurrent runs are serialized",
+		MsgTopicLockingLong:        "Commands that change the database first take a lock: an advisory lock on\nPostgres and MySQL, and a lock table on SQLite. A second run waits for the\nlock to be released, so migrations never run twice when several instances\nstart at once. Read-only commands such as list and status do not lock.",
+		MsgTopicBatchesShort:       "How migrations applied together are rolled back together",
+		MsgTopicBatchesLong:        "Every migration applied by one migrate run is recorded with the same batch\nnumber, one higher than the last. rollback --batch undoes the whole last\nbatch, newest first, so a deploy can be reverted in one step. Migrations\nrecorded by mark --applied or before batches existed belong to no batch\nand are never rolled back by --batch.",
+		MsgTopicChecksumsShort:     "How edits to applied migrations are detected",
+		MsgTopicChecksumsLong:      "The SHA-256 of each up script is stored in the tracking table when the\nmigration is applied. If the script changes afterwards, migrate and list\nfail with a checksum mismatch. Restore the original script, or run repair\nto accept the edit. Records written before checksums existed are skipped.",
+		MsgTopicTransactionsShort:  "How migrations are wrapped in transactions",
+		MsgTopicTransactionsLong:   "When transactions are enabled, each migration and its tracking record are\ncommitted together, so a failed migration leaves nothing behind. Migrations\nthat cannot run in a transaction, such as CREATE INDEX CONCURRENTLY, can opt\nout by implementing NonTransactional.",
+	},
+	"id": {
+		MsgRootShort:               "CLI GoMigration",
+		MsgListShort:               "Tampilkan semua migrasi",
+		MsgListError:               "Gagal menampilkan migrasi:",
+		MsgStatusShort:             "Tampilkan ringkasan migrasi yang sudah dan belum dijalankan",
+		MsgStatusError:             "Gagal mengambil status migrasi:",
+		MsgStatusWriteError:        "Gagal menulis status migrasi:",
+		MsgStatusFlagJSON:          "Tampilkan status sebagai JSON",
+		MsgMigrateShort:            "Jalankan semua migrasi yang tertunda",
+		MsgMigrateInvalidFresh:     "Flag fresh tidak valid:",
+		MsgMigrateDryRunWithFresh:  "--dry-run tidak dapat digabung dengan --fresh",
+		MsgMigrateDryRunError:      "Gagal menjalankan simulasi migrasi:",
+		MsgMigrateFreshError:       "Gagal menjalankan migrasi dari awal:",
+		MsgMigrateError:            "Gagal menjalankan migrasi:",
+		MsgMigrateFlagFresh:        "Jalankan migrasi dari awal",
+		MsgMigrateFlagStep:         "Jumlah migrasi tertunda yang dijalankan",
+		MsgMigrateFlagTo:           "Migrasi naik atau turun ke migrasi ini",
+		MsgMigrateFlagDryRun:       "Tampilkan migrasi dan SQL yang akan dijalankan tanpa mengubah database",
+		MsgPlanShort:               "Tulis SQL migrasi yang tertunda untuk dijalankan manual",
+		MsgPlanCreateFileError:     "Gagal membuat file rencana:",
+		MsgPlanError:               "Gagal membuat rencana migrasi:",
+		MsgPlanWritten:             "rencana migrasi ditulis ke: %s",
+		MsgPlanFlagOut:             "file tujuan rencana (bawaan stdout)",
+		MsgRollbackShort:           "Batalkan migrasi terakhir",
+		MsgRollbackError:           "Gagal membatalkan migrasi:",
+		MsgRollbackInvalidStep:     "Step tidak valid:",
+		MsgRollbackStepNotPositive: "Step harus lebih besar dari 0",
+		MsgRollbackFlagStep:        "Jumlah migrasi yang dibatalkan",
+		MsgRollbackFlagTo:          "Batalkan semua migrasi yang dijalankan setelah migrasi ini",
+		MsgRollbackFlagInclusive:   "Batalkan juga migrasi yang disebut oleh --to",
+		MsgRollbackFlagBatch:       "Batalkan semua migrasi yang dijalankan oleh migrate terakhir",
+		MsgRollbackFlagDryRun:      "Tampilkan migrasi dan SQL yang akan dibatalkan tanpa mengubah database",
+		MsgResetShort:              "Batalkan semua migrasi lalu jalankan ulang",
+		MsgResetError:              "Gagal mereset migrasi:",
+		MsgCleanShort:              "Bersihkan database (hapus semua tabel)",
+		MsgCleanError:              "Gagal membersihkan database:",
+		MsgMarkShort:               "Tandai migrasi sebagai sudah atau belum dijalankan tanpa menjalankannya",
+		MsgMarkError:               "Gagal menandai migrasi:",
+		MsgMarkFlagApplied:         "Catat migrasi ini sebagai sudah dijalankan",
+		MsgMarkFlagUnapplied:       "Hapus catatan migrasi ini",
+		MsgRepairShort:             "Perbaiki ketidaksesuaian checksum dan riwayat di tabel pelacak migrasi",
+		MsgRepairError:             "Gagal memperbaiki tabel pelacak:",
+		MsgValidateShort:           "Periksa ketidakkonsistenan riwayat dan daftar migrasi",
+		MsgValidateError:           "Gagal memvalidasi migrasi:",
+		MsgUpgradeShort:            "Tambahkan indeks dan constraint yang belum ada ke tabel pelacak migrasi",
+		MsgUpgradeError:            "Gagal memperbarui tabel pelacak:",
+		MsgUpgradeNotSupported:     "Driver tidak dapat memperbarui tabel pelacak: driver tidak mengimplementasikan TrackingTableUpgrader",
+		MsgCreateShort:             "Buat migrasi baru",
+		MsgCreateError:             "Gagal membuat migrasi:",
+		MsgCreateFlagName:          "nama migrasi",
+		MsgCreateFlagDir:           "direktori migrasi",
+		MsgCreateFlagSql:           "buat satu file SQL dengan bagian -- +migrate Up dan Down alih-alih file Go",
+		MsgCreateFlagType:          "jenis file migrasi: go untuk file Go yang mendaftarkan dirinya dari init, atau sql",
+		MsgCreateInvalidType:       "--type harus go atau sql",
+		MsgDiffShort:               "Buat migrasi dari file skema",
+		MsgDiffError:               "Gagal membuat migrasi dari skema:",
+		MsgDiffFlagSchema:          "file skema yang menjelaskan tabel tujuan",
+		MsgSquashShort:             "Gabungkan migrasi yang sudah dijalankan menjadi satu migrasi dasar",
+		MsgSquashError:             "Gagal menggabungkan migrasi:",
+		MsgSquashFlagTo:            "nama migrasi terakhir yang digabungkan",
+		MsgDiagramShort:            "Tulis diagram relasi entitas dari skema yang sudah dimigrasi",
+		MsgDiagramCreateFileError:  "Gagal membuat file diagram:",
+		MsgDiagramError:            "Gagal membuat diagram:",
+		MsgDiagramWritten:          "diagram ditulis ke: %s",
+		MsgDiagramFlagFormat:       "notasi diagram: mermaid, plantuml atau dot",
+		MsgDiagramFlagFrom:         "migrasi pertama yang tabelnya digambar",
+		MsgDiagramFlagTo:           "migrasi terakhir yang tabelnya digambar",
+		MsgDiagramFlagOut:          "file tujuan diagram (bawaan stdout)",
+		MsgSeedShort:               "Jalankan seeder untuk suatu lingkungan",
+		MsgSeedError:               "Gagal mengisi database:",
+		MsgSeedFlagEnv:             "lingkungan yang diisi (bawaan $GOMIGRATION_ENV)",
+		MsgSeedFlagOnly:            "hanya jalankan seeder yang disebut",
+		MsgFixturesShort:           "Kelola data fixture",
+		MsgFixturesLoadShort:       "Muat file fixture ke database",
+		MsgFixturesLoadError:       "Gagal memuat fixture:",
+		MsgFixturesFlagDir:         "direktori file fixture (bawaan Config.FixturesDir)",
+		MsgFixturesFlagTruncate:    "hapus baris tabel fixture terlebih dahulu",
+		MsgFixturesFlagOnly:        "hanya muat fixture dari tabel yang disebut",
+		MsgPlanFlagEstimateFrom:    "ekspor riwayat dari lingkungan yang sudah menjalankan migrasi tertunda, untuk memperkirakan durasinya (dapat diulang)",
+		MsgPlanEstimateError:       "Gagal memperkirakan durasi migrasi:",
+		MsgMigrateFlagPhase:        "hanya jalankan migrasi dari satu fase: pre-deploy atau post-deploy",
+		MsgMigrateFlagTags:         "hanya jalankan migrasi dengan salah satu tag; beri awalan ! untuk melewati migrasi dengan tag tersebut (dapat diulang)",
+		MsgMigrateFlagKeepHistory:  "dengan --fresh, pertahankan tabel pelacak dan log audit, mengatur ulang catatan migrasi yang terdaftar",
+		MsgCleanFlagKeepHistory:    "pertahankan tabel pelacak dan log audit, agar riwayat migrasi tetap ada",
+		MsgMigrateFlagTenant:       "migrasikan skema atau database satu tenant",
+		MsgMigrateFlagAllTenants:   "migrasikan setiap tenant dari penyedia tenant, satu per satu",
+		MsgMigrateFlagSeed:         "jalankan seeder setelah migrasi",
+		MsgEventsOutFlag:           "Tulis event siklus hidup dalam format JSON Lines ke file atau FIFO ini",
+		MsgEventsOutError:          "Gagal membuka output event:",
+		MsgSummaryOutFlag:          "Tulis juga ringkasan eksekusi dalam format JSON ke file ini",
+		MsgSummaryOutError:         "Gagal menulis ringkasan eksekusi:",
+		MsgOutputFlag:              "Format output: table, json atau quiet",
+		MsgProgressFlag:            "Tampilkan kemajuan proses, migrasi demi migrasi, sebagai ganti log-nya",
+		MsgVerboseFlag:             "Catat setiap pernyataan yang dijalankan, beserta durasi dan jumlah baris yang terpengaruh",
+		MsgOutputInvalid:           "Format output tidak valid:",
+		MsgRenderError:             "Gagal menampilkan output:",
+		MsgInterruptedMigration:    "Diinterupsi: menyelesaikan migrasi %s sebelum berhenti, interupsi lagi untuk membatalkannya",
+		MsgInterruptedIdle:         "Diinterupsi: berhenti",
+		MsgRootLong:                "GoMigration menjalankan, membatalkan dan memeriksa migrasi SQL yang terdaftar\ndi aplikasi Anda. Setiap perubahan dicatat di tabel pelacak, dan perintah\nyang mengubah database memegang lock sehingga proses lain menunggu.",
+		MsgListLong:                "Tampilkan semua migrasi yang terdaftar beserta waktu dijalankannya.\nMigrasi yang belum dijalankan ditampilkan sebagai tertunda.",
+		MsgStatusLong:              "Tampilkan jumlah migrasi yang sudah dijalankan, tertunda atau tidak dikenal\noleh build ini, migrasi terakhir yang dijalankan, dan apakah database sudah\nterbaru.",
+		MsgMigrateLong:             "Jalankan migrasi yang tertunda sesuai urutan nama. Secara bawaan semua\nmigrasi tertunda dijalankan; --step hanya menjalankan N migrasi berikutnya,\ndan --to menaikkan atau menurunkan database ke migrasi yang disebut.\n--dry-run menampilkan apa yang akan dijalankan, --fresh membersihkan\ndatabase sebelum migrasi, dan --seed menjalankan seeder sesudahnya.\n--phase pre-deploy atau --phase post-deploy hanya menjalankan migrasi\ndari fase tersebut, dan --tags hanya migrasi dengan salah satu tag,\nkecuali yang memiliki tag berawalan !.",
+		MsgPlanLong:                "Tulis SQL semua migrasi yang tertunda, termasuk perintah yang mencatatnya\ndi tabel pelacak, agar dapat ditinjau atau dijalankan secara manual. Dengan\n--estimate-from, rencana diakhiri dengan perkiraan durasi migrasi, dari\nriwayat yang diekspor lingkungan yang sudah menjalankannya.",
+		MsgRollbackLong:            "Batalkan migrasi yang sudah dijalankan, dimulai dari yang terbaru, dengan\nmenjalankan skrip down-nya. Tanpa flag hanya migrasi terakhir yang\ndibatalkan; --step membatalkan N migrasi, --to membatalkan semua migrasi\nsetelah migrasi yang disebut, dan --batch membatalkan proses migrate\nterakhir.",
+		MsgResetLong:               "Batalkan semua migrasi yang sudah dijalankan lalu jalankan ulang semuanya.",
+		MsgCleanLong:               "Hapus semua tabel di database, termasuk tabel pelacak kecuali\n--keep-history diberikan. Tindakan ini tidak dapat dibatalkan.",
+		MsgMarkLong:                "Catat migrasi sebagai sudah dijalankan, atau hapus catatannya, tanpa\nmenjalankan SQL-nya. Gunakan saat perubahan dilakukan secara manual.",
+		MsgRepairLong:              "Terima skrip migrasi yang diubah dengan memperbarui checksum-nya, hapus\ncatatan migrasi yang tidak lagi terdaftar, dan isi waktu eksekusi yang\nkosong. Laporan setiap perubahan ditampilkan.",
+		MsgValidateLong:            "Laporkan migrasi yang sudah dijalankan tetapi tidak terdaftar, migrasi\nterdaftar dengan skrip up kosong, migrasi dengan prefiks angka yang sama,\ndan migrasi yang diubah sehingga checksum-nya tidak cocok. Tidak ada yang\ndiubah. Perintah gagal jika ada masalah, sehingga dapat dipakai di CI.",
+		MsgUpgradeLong:             "Perbarui tabel pelacak yang dibuat oleh versi lama dengan menambahkan\nkolom, indeks dan constraint yang belum ada.",
+		MsgCreateLong:              "Buat file Go untuk migrasi baru di direktori yang diberikan, atau dengan --sql\nfile SQL yang memuat kedua skrip. Dengan --type go, file Go mendaftarkan\nmigrasi dari fungsi init-nya. Nama file dan nama migrasi diawali dengan\ntimestamp saat ini.",
+		MsgDiffLong:                "Bandingkan pernyataan CREATE TABLE dari file skema dengan database dan buat\nmigrasi yang menambahkan tabel dan kolom yang belum ada. Tabel dan kolom yang\nhanya ada di database dicantumkan sebagai komentar, dan tipe kolom tidak\ndibandingkan, jadi periksa migrasi sebelum menjalankannya.",
+		MsgSquashLong:              "Ganti migrasi hingga dan termasuk --to, yang semuanya harus sudah dijalankan,\ndengan satu migrasi dasar yang membuat tabelnya seperti yang ada di database.\nTidak boleh ada migrasi setelahnya yang sudah dijalankan. File migrasi dasar\ndibuat di --dir, file yang digabungkan dihapus darinya, dan tabel\npelacak mencatat migrasi dasar sebagai gantinya. Setelah itu, daftarkan migrasi\ndasar sebagai pengganti migrasi yang digabungkan.",
+		MsgDiagramLong:             "Tulis diagram relasi entitas dari tabel, kolom, dan foreign key database yang\nsudah dimigrasi, dalam format Mermaid, PlantUML, atau Graphviz DOT. Dengan\n--from atau --to, hanya tabel yang dibuat atau diubah oleh rentang migrasi\ntersebut yang digambar. Database hanya dibaca.",
+		MsgSeedLong:                "Jalankan seeder yang terdaftar, sesuai urutan pendaftarannya, untuk mengisi\ndatabase dengan data referensi atau fixture. Seeder yang dibatasi untuk\nlingkungan selain --env dilewati, dan --only hanya menjalankan yang disebut.\nSeeder tidak dicatat, sehingga dijalankan lagi setiap kali.",
+		MsgFixturesLong:            "Kelola data fixture: baris tabel yang disimpan dalam file YAML, JSON, atau\nCSV, seperti data database untuk pengujian integrasi.",
+		MsgFixturesLoadLong:        "Sisipkan baris dari file fixture di --dir dalam satu transaksi. Setiap file\nberisi baris tabel sesuai namanya, seperti users.yaml, dan file dimuat sesuai\nurutan nama; beri awalan angka agar tabel yang dirujuk dimuat lebih dulu.\n--truncate menghapus baris tabel fixture terlebih dahulu.",
+		MsgHelpGroupSelection:      "Flag Pilihan",
+		MsgHelpGroupMode:           "Flag Mode",
+		MsgHelpGroupOutput:         "Flag Output",
+		MsgHelpGroupMigrationFile:  "Flag File Migrasi",
+		MsgHelpGroupOther:          "Flag Lainnya",
+		MsgHelpGroupFlags:          "Flag",
+		MsgTopicsShort:             "Tampilkan topik bantuan",
+		MsgTopicsLong:              "Konsep yang dijelaskan oleh perintah help:",
+		MsgTopicsFooter:            "Gunakan \"%s help <topik>\" untuk membaca sebuah topik.",
+		MsgTopicLockingShort:       "Cara proses bersamaan diserialkan",
+		MsgTopicLockingLong:        "Perintah yang mengubah database terlebih dahulu mengambil lock: advisory lock\ndi Postgres dan MySQL, dan tabel lock di SQLite. Proses kedua menunggu lock\ndilepas, sehingga migrasi tidak pernah dijalankan dua kali saat beberapa\ninstance dimulai bersamaan. Perintah baca seperti list dan status tidak\nmengambil lock.",
+		MsgTopicBatchesShort:       "Cara migrasi yang dijalankan bersama dibatalkan bersama",
+		MsgTopicBatchesLong:        "Setiap migrasi yang dijalankan oleh satu proses migrate dicatat dengan nomor\nbatch yang sama, satu lebih tinggi dari sebelumnya. rollback --batch\nmembatalkan seluruh batch terakhir, dimulai dari yang terbaru, sehingga\nsebuah deploy dapat dibatalkan dalam satu langkah. Migrasi yang dicatat oleh\nmark --applied atau sebelum ada batch tidak termasuk batch mana pun dan\ntidak pernah dibatalkan oleh --batch.",
+		MsgTopicChecksumsShort:     "Cara perubahan pada migrasi yang sudah dijalankan dideteksi",
+		MsgTopicChecksumsLong:      "SHA-256 dari setiap skrip up disimpan di tabel pelacak saat migrasi\ndijalankan. Jika skrip berubah setelahnya, migrate dan list gagal karena\nchecksum tidak cocok. Kembalikan skrip aslinya, atau jalankan repair untuk\nmenerima perubahan. Catatan yang ditulis sebelum ada checksum dilewati.",
+		MsgTopicTransactionsShort:  "Cara migrasi dibungkus dalam transaksi",
+		MsgTopicTransactionsLong:   "Saat transaksi diaktifkan, setiap migrasi dan catatan pelacaknya di-commit\nbersamaan, sehingga migrasi yang gagal tidak meninggalkan apa pun. Migrasi\nyang tidak dapat berjalan dalam transaksi, seperti CREATE INDEX CONCURRENTLY,\ndapat dikecualikan dengan mengimplementasikan NonTransactional.",
+	},
+}
+
+// messageCatalog resolves CLI messages for a locale, falling back to English.
+type messageCatalog struct {
+	catalogs []Catalog
+}
+
+// newMessageCatalog selects the catalogs for locale from custom and the
+// built-in ones. Custom catalogs take precedence over built-in ones of the
+// same locale. An empty locale is read from the environment.
+func newMessageCatalog(locale string, custom map[string]Catalog) messageCatalog {
+	if locale == "" {
+		locale = localeFromEnv()
+	}
+
+	var mc messageCatalog
+	for _, candidate := range localeCandidates(locale) {
+		if catalog, ok := custom[candidate]; ok {
+			mc.catalogs = append(mc.catalogs, catalog)
+		}
+		if catalog, ok := builtinCatalogs[candidate]; ok {
+			mc.catalogs = append(mc.catalogs, catalog)
+		}
+	}
+	mc.catalogs = append(mc.catalogs, builtinCatalogs["en"])
+
+	return mc
+}
+
+// get returns the message for key, or the key itself if no catalog has it.
+func (mc messageCatalog) get(key MessageKey) string {
+	for _, catalog := range mc.catalogs {
+		if msg, ok := catalog[key]; ok {
+			return msg
+		}
+	}
+	return string(key)
+}
+
+// localeFromEnv returns the locale configured by GOMIGRATION_LOCALE or the
+// usual POSIX variables, in that order of precedence.
+func localeFromEnv() string {
+	for _, name := range []string{"GOMIGRATION_LOCALE", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return "en"
+}
+
+// localeCandidates returns the catalog keys to try for locale, most specific
+// first, e.g. "pt-BR" and "pt" for "pt_BR.UTF-8".
+func localeCandidates(locale string) []string {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	locale = strings.ReplaceAll(locale, "_", "-")
+
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil
+	}
+
+	candidates := []string{tag.String()}
+	if base, _ := tag.Base(); base.String() != tag.String() {
+		candidates = append(candidates, base.String())
+	}
+	return candidates
+}
@@ -0,0 +1,45 @@
+package gomigration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocaleCandidates(t *testing.T) {
+	assert.Equal(t, []string{"id"}, localeCandidates("id"))
+	assert.Equal(t, []string{"pt-BR", "pt"}, localeCandidates("pt_BR.UTF-8"))
+	assert.Equal(t, []string{"en-US", "en"}, localeCandidates("en_US@euro"))
+	assert.Nil(t, localeCandidates("C"))
+}
+
+func TestMessageCatalog(t *testing.T) {
+	mc := newMessageCatalog("id_ID.UTF-8", nil)
+	assert.Equal(t, "Gagal menjalankan migrasi:", mc.get(MsgMigrateError))
+
+	mc = newMessageCatalog("fr", nil)
+	assert.Equal(t, "Error running migrations:", mc.get(MsgMigrateError))
+
+	mc = newMessageCatalog("fr-CA", map[string]Catalog{
+		"fr": {MsgMigrateError: "Échec des migrations :"},
+	})
+	assert.Equal(t, "Échec des migrations :", mc.get(MsgMigrateError))
+	assert.Equal(t, "List all migrations", mc.get(MsgListShort))
+
+	mc = newMessageCatalog("id", map[string]Catalog{
+		"id": {MsgListShort: "Daftar migrasi"},
+	})
+	assert.Equal(t, "Daftar migrasi", mc.get(MsgListShort))
+	assert.Equal(t, "Buat migrasi baru", mc.get(MsgCreateShort))
+}
+
+func TestMessageCatalog_LocaleFromEnv(t *testing.T) {
+	t.Setenv("GOMIGRATION_LOCALE", "id")
+	assert.Equal(t, "Buat migrasi baru", newMessageCatalog("", nil).get(MsgCreateShort))
+}
+
+func TestBuiltinCatalogsComplete(t *testing.T) {
+	for key := range builtinCatalogs["en"] {
+		assert.Contains(t, builtinCatalogs["id"], key)
+	}
+}
@@ -0,0 +1,241 @@
+package gomigration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsCollector receives the metrics of runs, see Config.MetricsCollector.
+// Like an EventListener, it is called synchronously from the run.
+type MetricsCollector interface {
+	// MigrationApplied is called when a migration was applied, or rolled back
+	// for OperationRollback, in duration.
+	MigrationApplied(operation Operation, duration time.Duration)
+	// MigrationFailed is called when a migration failed after duration.
+	MigrationFailed(operation Operation, duration time.Duration)
+	// BatchFinished is called when a run ends, with the error it failed with,
+	// if any.
+	BatchFinished(operation Operation, duration time.Duration, err error)
+	// PendingMigrations is called with the number of pending migrations
+	// whenever it is known to change, e.g. when Migrate or HasPending looked
+	// them up and after each migration applied or rolled back.
+	PendingMigrations(count int)
+}
+
+// metricsListener feeds a MetricsCollector from the lifecycle events of runs,
+// keeping track of the number of pending migrations.
+type metricsListener struct {
+	collector MetricsCollector
+
+	mu      sync.Mutex
+	pending int
+	known   bool
+}
+
+// setPending reports count pending migrations, as looked up. It does nothing
+// on a nil metricsListener, when no MetricsCollector is configured.
+func (l *metricsListener) setPending(count int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending, l.known = count, true
+	l.collector.PendingMigrations(count)
+}
+
+// addPending reports delta more pending migrations, once their number is
+// known.
+func (l *metricsListener) addPending(delta int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.known {
+		return
+	}
+	l.pending = max(l.pending+delta, 0)
+	l.collector.PendingMigrations(l.pending)
+}
+
+func (l *metricsListener) HandleEvent(ctx context.Context, event Event) {
+	switch event.Type {
+	case EventMigrationSucceeded:
+		l.collector.MigrationApplied(event.Operation, event.Duration)
+		switch event.Operation {
+		case OperationMigrate:
+			l.addPending(-1)
+		case OperationRollback:
+			l.addPending(1)
+		}
+	case EventMigrationFailed:
+		l.collector.MigrationFailed(event.Operation, event.Duration)
+	case EventRunFinished:
+		var err error
+		if event.Error != "" {
+			err = errors.New(event.Error)
+		}
+		l.collector.BatchFinished(event.Operation, event.Duration, err)
+	}
+}
+
+// PrometheusMetrics is a MetricsCollector exposing its metrics in the
+// Prometheus text format, served by ServeHTTP for a scraper:
+//
+//	<namespace>_migrations_applied_total{operation}     counter
+//	<namespace>_migrations_failed_total{operation}      counter
+//	<namespace>_migration_duration_seconds{operation}   histogram
+//	<namespace>_batch_duration_seconds{operation,outcome} histogram
+//	<namespace>_pending_migrations                       gauge
+//
+// It needs no Prometheus client library, so it is served on its own path,
+// e.g. /metrics/migrations, next to the metrics of the application.
+type PrometheusMetrics struct {
+	namespace string
+
+	mu      sync.Mutex
+	applied map[Operation]float64
+	failed  map[Operation]float64
+	// The histograms are keyed by their labels, e.g. operation="migrate".
+	migrationDuration map[string]*histogram
+	batchDuration     map[string]*histogram
+	pending           float64
+}
+
+// prometheusBuckets are the upper bounds, in seconds, of the buckets of the
+// duration histograms, from quick DDL to long backfills.
+var prometheusBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600}
+
+// NewPrometheusMetrics creates a PrometheusMetrics naming its metrics with
+// the prefix namespace, "gomigration" when empty.
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	if namespace == "" {
+		namespace = "gomigration"
+	}
+	return &PrometheusMetrics{
+		namespace:         namespace,
+		applied:           make(map[Operation]float64),
+		failed:            make(map[Operation]float64),
+		migrationDuration: make(map[string]*histogram),
+		batchDuration:     make(map[string]*histogram),
+	}
+}
+
+func (p *PrometheusMetrics) MigrationApplied(operation Operation, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.applied[operation]++
+	observe(p.migrationDuration, fmt.Sprintf("operation=%q", operation), duration)
+}
+
+func (p *PrometheusMetrics) MigrationFailed(operation Operation, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failed[operation]++
+	observe(p.migrationDuration, fmt.Sprintf("operation=%q", operation), duration)
+}
+
+func (p *PrometheusMetrics) BatchFinished(operation Operation, duration time.Duration, err error) {
+	outcome := AuditOutcomeSucceeded
+	if err != nil {
+		outcome = AuditOutcomeFailed
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	observe(p.batchDuration, fmt.Sprintf("operation=%q,outcome=%q", operation, outcome), duration)
+}
+
+func (p *PrometheusMetrics) PendingMigrations(count int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = float64(count)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	name := func(metric string) string { return p.namespace + "_" + metric }
+
+	writeHeader(&b, name("migrations_applied_total"), "counter", "Migrations applied, or rolled back, by operation.")
+	for _, operation := range slices.Sorted(maps.Keys(p.applied)) {
+		fmt.Fprintf(&b, "%s{operation=%q} %s\n", name("migrations_applied_total"), operation, formatFloat(p.applied[operation]))
+	}
+	writeHeader(&b, name("migrations_failed_total"), "counter", "Migrations failed, by operation.")
+	for _, operation := range slices.Sorted(maps.Keys(p.failed)) {
+		fmt.Fprintf(&b, "%s{operation=%q} %s\n", name("migrations_failed_total"), operation, formatFloat(p.failed[operation]))
+	}
+	writeHeader(&b, name("migration_duration_seconds"), "histogram", "Time taken by migrations, by operation.")
+	for _, labels := range slices.Sorted(maps.Keys(p.migrationDuration)) {
+		p.migrationDuration[labels].write(&b, name("migration_duration_seconds"), labels)
+	}
+	writeHeader(&b, name("batch_duration_seconds"), "histogram", "Time taken by runs, by operation and outcome.")
+	for _, labels := range slices.Sorted(maps.Keys(p.batchDuration)) {
+		p.batchDuration[labels].write(&b, name("batch_duration_seconds"), labels)
+	}
+	writeHeader(&b, name("pending_migrations"), "gauge", "Migrations not applied yet.")
+	fmt.Fprintf(&b, "%s %s\n", name("pending_migrations"), formatFloat(p.pending))
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// histogram is a Prometheus histogram of durations, in seconds.
+type histogram struct {
+	counts []float64
+	count  float64
+	sum    float64
+}
+
+// observe records duration in the histogram with labels in histograms.
+func observe(histograms map[string]*histogram, labels string, duration time.Duration) {
+	h, ok := histograms[labels]
+	if !ok {
+		h = &histogram{counts: make([]float64, len(prometheusBuckets))}
+		histograms[labels] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range prometheusBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// write writes the buckets, sum and count of the histogram named name, with
+// the labels labels.
+func (h *histogram) write(b *strings.Builder, name, labels string) {
+	for i, bound := range prometheusBuckets {
+		fmt.Fprintf(b, "%s_bucket{%s,le=%q} %s\n", name, labels, formatFloat(bound), formatFloat(h.counts[i]))
+	}
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %s\n", name, labels, formatFloat(h.count))
+	fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count{%s} %s\n", name, labels, formatFloat(h.count))
+}
+
+// writeHeader writes the HELP and TYPE lines of the metric name.
+func writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// formatFloat formats f as a Prometheus sample value.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,53 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoMigration_Migrate_Metrics(t *testing.T) {
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "metrics.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	metrics := NewPrometheusMetrics("")
+	q, err := New(&Config{Driver: driver, MigrationTableName: "migrations", MetricsCollector: metrics})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(
+		&scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+		&scriptMigration{name: "002_create_posts", upScript: "CREATE TABLE posts (id INTEGER PRIMARY KEY);"},
+		&scriptMigration{name: "003_create_users_again", upScript: "CREATE TABLE users (id INTEGER PRIMARY KEY);"},
+	))
+
+	assert.Error(t, q.Migrate(context.Background()))
+
+	var out bytes.Buffer
+	_, err = metrics.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "# TYPE gomigration_migrations_applied_total counter\n"+
+		"gomigration_migrations_applied_total{operation=\"migrate\"} 2\n")
+	assert.Contains(t, out.String(), "gomigration_migrations_failed_total{operation=\"migrate\"} 1\n")
+	assert.Contains(t, out.String(), "gomigration_migration_duration_seconds_count{operation=\"migrate\"} 3\n")
+	assert.Contains(t, out.String(), "gomigration_batch_duration_seconds_count{operation=\"migrate\",outcome=\"failed\"} 1\n")
+	assert.Contains(t, out.String(), "# TYPE gomigration_pending_migrations gauge\ngomigration_pending_migrations 1\n")
+}
+
+func TestPrometheusMetrics_ServeHTTP(t *testing.T) {
+	metrics := NewPrometheusMetrics("app")
+	metrics.BatchFinished(OperationRollback, 2*time.Second, nil)
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics/migrations", nil))
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "app_batch_duration_seconds_bucket{operation=\"rollback\",outcome=\"succeeded\",le=\"1\"} 0\n"+
+		"app_batch_duration_seconds_bucket{operation=\"rollback\",outcome=\"succeeded\",le=\"5\"} 1\n")
+	assert.Contains(t, rec.Body.String(), "app_batch_duration_seconds_sum{operation=\"rollback\",outcome=\"succeeded\"} 2\n")
+	assert.Contains(t, rec.Body.String(), "app_pending_migrations 0\n")
+}
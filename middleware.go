@@ -0,0 +1,31 @@
+package gomigration
+
+import "slices"
+
+// DriverMiddleware wraps a Driver to add cross-cutting behavior, such as
+// logging, metrics, statement rewriting or read-only enforcement, without
+// forking the driver. It typically returns a struct that embeds the wrapped
+// Driver and overrides the methods it is interested in.
+//
+// The wrapper hides optional interfaces of the wrapped driver, such as
+// ManifestStore, unless it implements them as well.
+type DriverMiddleware func(next Driver) Driver
+
+// Use wraps the driver with the given middleware. Like HTTP middleware, the
+// first one registered is the outermost: Use(a, b) makes every call go through
+// a, then b, then the driver. Middleware registered by later calls wraps the
+// earlier ones. Drivers returned for tenants by ForTenant are wrapped the
+// same way.
+func (q *GoMigration) Use(middleware ...DriverMiddleware) *GoMigration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.baseDriver == nil {
+		q.baseDriver = q.driver
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		q.driver = middleware[i](q.driver)
+	}
+	q.middleware = append(slices.Clone(middleware), q.middleware...)
+	return q
+}
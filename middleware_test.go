@@ -0,0 +1,70 @@
+package gomigration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingDriver records its name in calls before delegating to Driver.
+type recordingDriver struct {
+	Driver
+	name  string
+	calls *[]string
+}
+
+func (d recordingDriver) CreateMigrationsTable(ctx context.Context) error {
+	*d.calls = append(*d.calls, d.name)
+	return d.Driver.CreateMigrationsTable(ctx)
+}
+
+func recordingMiddleware(name string, calls *[]string) DriverMiddleware {
+	return func(next Driver) Driver {
+		return recordingDriver{Driver: next, name: name, calls: calls}
+	}
+}
+
+func TestGoMigration_Use(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+
+	var calls []string
+	q := &GoMigration{driver: driver}
+	q.Use(recordingMiddleware("a", &calls), recordingMiddleware("b", &calls)).
+		Use(recordingMiddleware("c", &calls))
+
+	assert.NoError(t, q.driver.CreateMigrationsTable(ctx))
+	assert.Equal(t, []string{"c", "a", "b"}, calls)
+	driver.AssertExpectations(t)
+}
+
+// readOnlyDriver refuses to apply migrations.
+type readOnlyDriver struct {
+	Driver
+}
+
+func (readOnlyDriver) ApplyMigrations(context.Context, []Migration, func(*Migration), func(*Migration), func(*Migration, error)) error {
+	return errors.New("read-only")
+}
+
+func TestGoMigration_Use_ReadOnly(t *testing.T) {
+	ctx := context.TODO()
+	driver := new(mockDriver)
+	driver.On("AcquireLock", ctx).Return(nil)
+	driver.On("ReleaseLock", mock.Anything).Return(nil)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{}, nil)
+
+	q := &GoMigration{
+		driver:     driver,
+		migrations: map[string]Migration{"001_create_users": dummyMigration{name: "001_create_users"}},
+	}
+	q.Use(func(next Driver) Driver { return readOnlyDriver{next} })
+
+	assert.ErrorContains(t, q.Migrate(ctx), "read-only")
+	driver.AssertExpectations(t)
+}
@@ -0,0 +1,170 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Target is a database migrated by a MultiMigrator.
+type Target struct {
+	// Name identifies the target in progress callbacks and errors, e.g. the
+	// region of the database.
+	Name      string
+	Migration *GoMigration
+}
+
+// MultiConfig configures a MultiMigrator.
+type MultiConfig struct {
+	Targets []Target
+	// Parallelism is how many targets are migrated at the same time, 1 by
+	// default.
+	Parallelism int
+	// FailFast stops starting new targets once one failed. Targets already
+	// running are finished either way.
+	FailFast bool
+	// OnProgress, if not nil, is called with every event of every target. It
+	// is called from the goroutine of the target, so concurrently when
+	// Parallelism is more than 1.
+	OnProgress func(target string, event Event)
+}
+
+// MultiMigrator applies the same migrations to several databases, such as
+// regional copies of one schema, and reports the failures of every target
+// together.
+type MultiMigrator struct {
+	targets     []Target
+	parallelism int
+	failFast    bool
+	onProgress  func(target string, event Event)
+}
+
+// NewMultiMigrator creates a MultiMigrator for the targets of config. Each
+// target is a GoMigration of its own, created with New for its driver; a
+// migration set shared by every target is registered with Register.
+func NewMultiMigrator(config *MultiConfig) (*MultiMigrator, error) {
+	if config == nil {
+		return nil, ErrConfigNotProvided
+	}
+	if len(config.Targets) == 0 {
+		return nil, ErrNoTargets
+	}
+
+	seen := make(map[string]bool, len(config.Targets))
+	for _, target := range config.Targets {
+		if target.Name == "" || target.Migration == nil {
+			return nil, fmt.Errorf("%w: every target needs a name and a GoMigration", ErrInvalidTarget)
+		}
+		if seen[target.Name] {
+			return nil, fmt.Errorf("%w: %s configured more than once", ErrInvalidTarget, target.Name)
+		}
+		seen[target.Name] = true
+	}
+
+	parallelism := config.Parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	return &MultiMigrator{
+		targets:     append([]Target(nil), config.Targets...),
+		parallelism: parallelism,
+		failFast:    config.FailFast,
+		onProgress:  config.OnProgress,
+	}, nil
+}
+
+// Register registers migrations with every target.
+func (mm *MultiMigrator) Register(migrations ...Migration) error {
+	for _, target := range mm.targets {
+		if err := target.Migration.Register(migrations...); err != nil {
+			return fmt.Errorf("%s: %w", target.Name, err)
+		}
+	}
+	return nil
+}
+
+// Migrate applies the pending migrations of every target, as GoMigration
+// Migrate does with opts. A failing target does not stop the others unless
+// FailFast is set; the failures are returned together as a *MultiError.
+func (mm *MultiMigrator) Migrate(ctx context.Context, opts ...MigrateOption) error {
+	return mm.run(ctx, func(ctx context.Context, target Target) error {
+		return target.Migration.Migrate(ctx, opts...)
+	})
+}
+
+// Status returns the status of every target by name. Targets whose status
+// cannot be read are reported in a *MultiError along with the others.
+func (mm *MultiMigrator) Status(ctx context.Context) (map[string]MigrationStatus, error) {
+	var mu sync.Mutex
+	statuses := make(map[string]MigrationStatus, len(mm.targets))
+	err := mm.run(ctx, func(ctx context.Context, target Target) error {
+		status, err := target.Migration.Status(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		statuses[target.Name] = status
+		return nil
+	})
+	return statuses, err
+}
+
+// run calls fn for every target, up to mm.parallelism at a time, collecting
+// the failures in target order.
+func (mm *MultiMigrator) run(ctx context.Context, fn func(ctx context.Context, target Target) error) error {
+	errs := make([]error, len(mm.targets))
+	var failed bool
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, mm.parallelism)
+
+	for i, target := range mm.targets {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := mm.failFast && failed
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			errs[i] = ErrTargetNotStarted
+			if !stop {
+				errs[i] = fmt.Errorf("%w: %w", ErrTargetNotStarted, ctx.Err())
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if mm.onProgress != nil {
+				unsubscribe := target.Migration.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+					mm.onProgress(target.Name, event)
+				}))
+				defer unsubscribe()
+			}
+
+			target.Migration.logger.info(fmt.Sprintf("🌐 [%s] Starting", target.Name), "starting target", "target", target.Name)
+			if err := fn(ctx, target); err != nil {
+				target.Migration.logger.error(fmt.Sprintf("❌ [%s] %s", target.Name, err), "target failed", "target", target.Name, "error", err)
+				errs[i] = err
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+				return
+			}
+			target.Migration.logger.info(fmt.Sprintf("✅ [%s] Done", target.Name), "target done", "target", target.Name)
+		}()
+	}
+	wg.Wait()
+
+	multiErr := &MultiError{}
+	for i, err := range errs {
+		if err != nil {
+			multiErr.Failures = append(multiErr.Failures, TargetFailure{Target: mm.targets[i].Name, Err: err})
+		}
+	}
+	return multiErr.orNil()
+}
@@ -0,0 +1,70 @@
+package gomigration
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiMigrator_Migrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	target := func(name string) Target {
+		driver, err := NewSqliteDriver(filepath.Join(dir, name+".db"))
+		assert.NoError(t, err)
+		t.Cleanup(func() { driver.Close() })
+		driver.SetMigrationTableName("migrations")
+		return Target{Name: name, Migration: &GoMigration{driver: driver, migrationTableName: "migrations", migrations: make(map[string]Migration)}}
+	}
+	eu, us, ap := target("eu"), target("us"), target("ap")
+	_, err := us.Migration.driver.(*SqliteDriver).db.ExecContext(ctx, "CREATE TABLE users (id INTEGER);")
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	started := make(map[string]int)
+	mm, err := NewMultiMigrator(&MultiConfig{
+		Targets:     []Target{eu, us, ap},
+		Parallelism: 2,
+		OnProgress: func(target string, event Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			if event.Type == EventMigrationStarted {
+				started[target]++
+			}
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mm.Register(
+		scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER);"},
+		scriptMigration{name: "002_create_orders", upScript: "CREATE TABLE orders (id INTEGER);"},
+	))
+
+	err = mm.Migrate(ctx)
+	var multiErr *MultiError
+	if assert.ErrorAs(t, err, &multiErr) && assert.Len(t, multiErr.Failures, 1) {
+		assert.Equal(t, "us", multiErr.Failures[0].Target)
+	}
+	assert.ErrorContains(t, err, "1 target(s) failed; us:")
+	assert.Equal(t, map[string]int{"eu": 2, "us": 1, "ap": 2}, started)
+
+	statuses, err := mm.Status(ctx)
+	assert.NoError(t, err)
+	assert.True(t, statuses["eu"].UpToDate)
+	assert.Equal(t, 2, statuses["us"].Pending)
+	assert.True(t, statuses["ap"].UpToDate)
+
+	mm.failFast, mm.parallelism = true, 1
+	mm.targets = []Target{us, eu}
+	err = mm.Migrate(ctx)
+	assert.ErrorIs(t, err, ErrTargetNotStarted)
+	assert.ErrorContains(t, err, "eu: target not started")
+
+	_, err = NewMultiMigrator(&MultiConfig{Targets: []Target{eu, eu}})
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+	_, err = NewMultiMigrator(&MultiConfig{})
+	assert.ErrorIs(t, err, ErrNoTargets)
+}
@@ -0,0 +1,195 @@
+package gomigration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Platform identifies the database service behind a driver connection.
+type Platform string
+
+const (
+	PlatformPostgres       Platform = "postgres"
+	PlatformMySQL          Platform = "mysql"
+	PlatformSQLite         Platform = "sqlite"
+	PlatformAuroraPostgres Platform = "aurora-postgresql"
+	PlatformAuroraMySQL    Platform = "aurora-mysql"
+	PlatformAlloyDB        Platform = "alloydb"
+)
+
+// PlatformInfo describes the database service a driver is connected to.
+type PlatformInfo struct {
+	Platform Platform
+	// Version is the server version, or the Aurora version on Aurora MySQL.
+	Version string
+	// Endpoint is the host, port and database, or the file, the driver is
+	// connected to, when known.
+	Endpoint string
+	// ReadOnly reports whether the connection cannot write, e.g. because it
+	// goes through an Aurora reader endpoint, to an AlloyDB read pool or to
+	// a replica, and ReadOnlyReason why.
+	ReadOnly       bool
+	ReadOnlyReason string
+	// FastDDL reports whether columns can be added without copying or
+	// rewriting the table: instant ADD COLUMN on MySQL 8, Aurora MySQL 3 and
+	// Aurora MySQL 2 with lab mode, or Postgres 11 and later.
+	FastDDL bool
+}
+
+// PlatformInspector is implemented by drivers that can tell which database
+// service they are connected to. The built-in drivers do.
+type PlatformInspector interface {
+	InspectPlatform(ctx context.Context) (PlatformInfo, error)
+}
+
+// Platform returns the database service the driver is connected to, such as
+// Aurora or AlloyDB, and whether it can be written to.
+func (q *GoMigration) Platform(ctx context.Context) (PlatformInfo, error) {
+	q = q.snapshot()
+
+	inspector, ok := q.driver.(PlatformInspector)
+	if !ok {
+		return PlatformInfo{}, ErrPlatformNotSupported
+	}
+	return inspector.InspectPlatform(ctx)
+}
+
+// checkWritable fails with a *ReadOnlyError when the driver is connected to
+// a read-only target, so a run is refused up front instead of failing
+// midway. Drivers that cannot tell are assumed to be writable, and a failed
+// inspection is only logged.
+func (q *GoMigration) checkWritable(ctx context.Context) error {
+	inspector, ok := q.driver.(PlatformInspector)
+	if !ok {
+		return nil
+	}
+
+	info, err := inspector.InspectPlatform(ctx)
+	if errors.Is(err, ErrPlatformNotSupported) {
+		return nil
+	}
+	if err != nil {
+		q.logger.warn(fmt.Sprintf("⚠️  Failed to inspect the database platform: %s", err), "failed to inspect the database platform", "error", err)
+		return nil
+	}
+	if info.ReadOnly {
+		return &ReadOnlyError{Endpoint: info.Endpoint, Platform: info.Platform, Reason: info.ReadOnlyReason}
+	}
+	return nil
+}
+
+// serverRequirementRegex matches the annotation declaring the minimum server
+// version a migration needs, such as -- gomigration:requires-server >= postgres 14.
+var serverRequirementRegex = regexp.MustCompile(`(?i)^--\s*gomigration:requires-server\s+(.*?)\s*$`)
+
+// serverVersionRegex matches the leading version number of a server version,
+// such as 14.5 in "14.5 (Debian 14.5-1.pgdg110+1)".
+var serverVersionRegex = regexp.MustCompile(`^\d+(?:\.\d+)*`)
+
+// serverRequirement is the minimum server version a migration needs, declared
+// in its up script with -- gomigration:requires-server >= <server> <version>.
+type serverRequirement struct {
+	// Server is a Dialect, which covers every platform reporting that
+	// database's own version, or a Platform, such as aurora-mysql, whose
+	// version is that of the service.
+	Server     string
+	MinVersion string
+}
+
+// appliesTo reports whether r constrains the servers of platform.
+func (r serverRequirement) appliesTo(platform Platform) bool {
+	if r.Server == string(platform) {
+		return true
+	}
+	switch platform {
+	case PlatformPostgres, PlatformAuroraPostgres, PlatformAlloyDB:
+		return r.Server == string(DialectPostgres)
+	case PlatformMySQL:
+		return r.Server == string(DialectMySQL)
+	case PlatformSQLite:
+		return r.Server == string(DialectSQLite)
+	}
+	// Aurora MySQL reports its own version rather than the MySQL one.
+	return false
+}
+
+// parseServerRequirements returns the server requirements declared in script.
+func parseServerRequirements(script string) ([]serverRequirement, error) {
+	var requirements []serverRequirement
+	for _, line := range strings.Split(script, "\n") {
+		matches := serverRequirementRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+
+		fields := strings.Fields(matches[1])
+		if len(fields) != 3 || fields[0] != ">=" {
+			return nil, fmt.Errorf("%w: requires-server must read \">= <server> <version>\", got %q", ErrInvalidMigrationFile, matches[1])
+		}
+		server := strings.ToLower(fields[1])
+		switch Dialect(server) {
+		case DialectPostgres, DialectMySQL, DialectSQLite:
+		default:
+			switch Platform(server) {
+			case PlatformAuroraPostgres, PlatformAuroraMySQL, PlatformAlloyDB:
+			default:
+				return nil, fmt.Errorf("%w: requires-server names unknown server %q", ErrInvalidMigrationFile, fields[1])
+			}
+		}
+		if _, err := parseVersion(fields[2]); err != nil {
+			return nil, fmt.Errorf("%w: requires-server has unparsable version %q", ErrInvalidMigrationFile, fields[2])
+		}
+		requirements = append(requirements, serverRequirement{Server: server, MinVersion: fields[2]})
+	}
+	return requirements, nil
+}
+
+// checkServerRequirements fails with ErrServerVersionUnsupported if the
+// server the driver is connected to is older than a migration about to be
+// applied requires, so it is refused up front instead of failing with a
+// syntax error. The platform is only inspected when a migration declares a
+// requirement; drivers that cannot tell are assumed to meet it.
+func (q *GoMigration) checkServerRequirements(ctx context.Context, migrations []Migration) error {
+	var info *PlatformInfo
+	var unsupported []string
+	for _, m := range migrations {
+		requirements, err := parseServerRequirements(q.upScript(m))
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.Name(), err)
+		}
+		if len(requirements) == 0 {
+			continue
+		}
+
+		if info == nil {
+			inspector, ok := q.driver.(PlatformInspector)
+			if !ok {
+				q.logger.warn(fmt.Sprintf("⚠️  Cannot check the server versions required by %s: driver cannot inspect the database platform", m.Name()), "cannot check the required server versions, driver cannot inspect the database platform", "migration", m.Name())
+				return nil
+			}
+			inspected, err := inspector.InspectPlatform(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to check the server versions required by %s: %w", m.Name(), err)
+			}
+			info = &inspected
+		}
+
+		version := serverVersionRegex.FindString(info.Version)
+		for _, requirement := range requirements {
+			if !requirement.appliesTo(info.Platform) {
+				continue
+			}
+			if reason := versionBelow(version, requirement.MinVersion); reason != "" {
+				unsupported = append(unsupported, fmt.Sprintf("%s (needs %s %s or later, %s)", m.Name(), requirement.Server, requirement.MinVersion, reason))
+			}
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%w: %s", ErrServerVersionUnsupported, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package gomigration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// BatchProgress describes a run of migrations to a ProgressReporter.
+type BatchProgress struct {
+	Operation Operation
+	// Total is the number of migrations of the run.
+	Total int
+	// Duration is how long the run took, set when it finished.
+	Duration time.Duration
+	Tenant   string
+}
+
+// MigrationProgress describes a migration of a run to a ProgressReporter.
+type MigrationProgress struct {
+	Operation Operation
+	Name      string
+	// Index is the position of the migration in the run, from 1, out of
+	// Total.
+	Index int
+	Total int
+	// Duration is how long the migration took, set when it finished.
+	Duration time.Duration
+	Tenant   string
+}
+
+// ProgressReporter follows the progress of runs, such as Migrate and
+// Rollback, migration by migration. Register one with ReportProgress. Like an
+// EventListener, it is called synchronously from the run.
+type ProgressReporter interface {
+	BatchStarted(ctx context.Context, batch BatchProgress)
+	MigrationStarted(ctx context.Context, migration MigrationProgress)
+	MigrationSucceeded(ctx context.Context, migration MigrationProgress)
+	MigrationFailed(ctx context.Context, migration MigrationProgress, err error)
+	// BatchFinished is called when a run ends, with the error it failed with,
+	// if any.
+	BatchFinished(ctx context.Context, batch BatchProgress, err error)
+}
+
+// ReportProgress registers reporter for the progress of subsequent runs and
+// returns a function that removes it again. It is fed from the lifecycle
+// events, see Subscribe.
+func (q *GoMigration) ReportProgress(reporter ProgressReporter) (unsubscribe func()) {
+	return q.Subscribe(&progressListener{reporter: reporter})
+}
+
+// progressListener translates the lifecycle events of runs for a
+// ProgressReporter.
+type progressListener struct {
+	reporter ProgressReporter
+}
+
+func (l *progressListener) HandleEvent(ctx context.Context, event Event) {
+	batch := BatchProgress{Operation: event.Operation, Total: event.Total, Duration: event.Duration, Tenant: event.Tenant}
+	migration := MigrationProgress{
+		Operation: event.Operation,
+		Name:      event.Migration,
+		Index:     event.Index,
+		Total:     event.Total,
+		Duration:  event.Duration,
+		Tenant:    event.Tenant,
+	}
+
+	switch event.Type {
+	case EventRunStarted:
+		l.reporter.BatchStarted(ctx, batch)
+	case EventMigrationStarted:
+		l.reporter.MigrationStarted(ctx, migration)
+	case EventMigrationSucceeded:
+		l.reporter.MigrationSucceeded(ctx, migration)
+	case EventMigrationFailed:
+		l.reporter.MigrationFailed(ctx, migration, errors.New(event.Error))
+	case EventRunFinished:
+		var err error
+		if event.Error != "" {
+			err = errors.New(event.Error)
+		}
+		l.reporter.BatchFinished(ctx, batch, err)
+	}
+}
+
+// ProgressPrinter is a ProgressReporter printing a line per migration, such
+// as "[2/5] ✅ 002_create_posts (1.2s)". On a terminal, the line of the
+// migration in flight is redrawn every second with its elapsed time.
+type ProgressPrinter struct {
+	w    io.Writer
+	live bool
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewProgressPrinter creates a ProgressPrinter writing to w, live when w is a
+// terminal.
+func NewProgressPrinter(w io.Writer) *ProgressPrinter {
+	return &ProgressPrinter{w: w, live: isTerminal(w)}
+}
+
+// isTerminal reports whether w is a character device, such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// BatchStarted does nothing, as the migrations tell the progress.
+func (p *ProgressPrinter) BatchStarted(ctx context.Context, batch BatchProgress) {}
+
+// MigrationStarted prints the migration as in flight.
+func (p *ProgressPrinter) MigrationStarted(ctx context.Context, migration MigrationProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.live {
+		fmt.Fprintf(p.w, "%s ⏳ %s\n", progressPosition(migration), migration.Name)
+		return
+	}
+
+	fmt.Fprintf(p.w, "%s ⏳ %s", progressPosition(migration), migration.Name)
+	p.stop, p.done = make(chan struct{}), make(chan struct{})
+	go p.tick(migration, time.Now(), p.stop, p.done)
+}
+
+// tick redraws the line of migration every second until stop is closed.
+func (p *ProgressPrinter) tick(migration MigrationProgress, started time.Time, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			fmt.Fprintf(p.w, "\r\033[K%s ⏳ %s %s", progressPosition(migration), migration.Name, time.Since(started).Round(time.Second))
+			p.mu.Unlock()
+		}
+	}
+}
+
+// MigrationSucceeded prints the migration as done.
+func (p *ProgressPrinter) MigrationSucceeded(ctx context.Context, migration MigrationProgress) {
+	p.finish(fmt.Sprintf("%s ✅ %s (%s)", progressPosition(migration), migration.Name, migration.Duration.Round(time.Millisecond)))
+}
+
+// MigrationFailed prints the migration as failed.
+func (p *ProgressPrinter) MigrationFailed(ctx context.Context, migration MigrationProgress, err error) {
+	p.finish(fmt.Sprintf("%s ❌ %s (%s): %s", progressPosition(migration), migration.Name, migration.Duration.Round(time.Millisecond), err))
+}
+
+// BatchFinished does nothing, as the migrations tell the progress.
+func (p *ProgressPrinter) BatchFinished(ctx context.Context, batch BatchProgress, err error) {}
+
+// finish stops redrawing the migration in flight and prints line in its
+// place.
+func (p *ProgressPrinter) finish(line string) {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.live {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+// progressPosition formats the position of migration in its run, e.g. [2/5].
+func progressPosition(migration MigrationProgress) string {
+	return fmt.Sprintf("[%d/%d]", migration.Index, migration.Total)
+}
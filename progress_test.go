@@ -0,0 +1,85 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// progressRecorder is a ProgressReporter recording the calls it receives.
+type progressRecorder struct {
+	calls []string
+}
+
+func (r *progressRecorder) BatchStarted(ctx context.Context, batch BatchProgress) {
+	r.calls = append(r.calls, fmt.Sprintf("batch started: %s of %d", batch.Operation, batch.Total))
+}
+
+func (r *progressRecorder) MigrationStarted(ctx context.Context, migration MigrationProgress) {
+	r.calls = append(r.calls, fmt.Sprintf("started: %s %d/%d", migration.Name, migration.Index, migration.Total))
+}
+
+func (r *progressRecorder) MigrationSucceeded(ctx context.Context, migration MigrationProgress) {
+	r.calls = append(r.calls, fmt.Sprintf("succeeded: %s %d/%d", migration.Name, migration.Index, migration.Total))
+}
+
+func (r *progressRecorder) MigrationFailed(ctx context.Context, migration MigrationProgress, err error) {
+	r.calls = append(r.calls, fmt.Sprintf("failed: %s %d/%d: %s", migration.Name, migration.Index, migration.Total, err))
+}
+
+func (r *progressRecorder) BatchFinished(ctx context.Context, batch BatchProgress, err error) {
+	r.calls = append(r.calls, fmt.Sprintf("batch finished: %s: %v", batch.Operation, err))
+}
+
+func TestGoMigration_ReportProgress(t *testing.T) {
+	ctx := context.Background()
+	q := &GoMigration{}
+	recorder := &progressRecorder{}
+	unsubscribe := q.ReportProgress(recorder)
+
+	run := q.startRun(ctx, OperationMigrate, 2)
+	run.migrationStartedEvent(dummyMigration{name: "001_create_users"})
+	run.migrationSucceededEvent(dummyMigration{name: "001_create_users"})
+	run.migrationStartedEvent(dummyMigration{name: "002_create_posts"})
+	run.migrationFailedEvent(dummyMigration{name: "002_create_posts"}, errors.New("boom"))
+	_ = run.finish(errors.New("boom"))
+
+	unsubscribe()
+	_ = q.startRun(ctx, OperationRollback, 1).finish(nil)
+
+	assert.Equal(t, []string{
+		"batch started: migrate of 2",
+		"started: 001_create_users 1/2",
+		"succeeded: 001_create_users 1/2",
+		"started: 002_create_posts 2/2",
+		"failed: 002_create_posts 2/2: boom",
+		"batch finished: migrate: boom",
+	}, recorder.calls)
+}
+
+func TestProgressPrinter(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	printer := NewProgressPrinter(&out)
+
+	users := MigrationProgress{Operation: OperationMigrate, Name: "001_create_users", Index: 1, Total: 2}
+	posts := MigrationProgress{Operation: OperationMigrate, Name: "002_create_posts", Index: 2, Total: 2}
+	printer.BatchStarted(ctx, BatchProgress{Operation: OperationMigrate, Total: 2})
+	printer.MigrationStarted(ctx, users)
+	users.Duration = 1200 * time.Millisecond
+	printer.MigrationSucceeded(ctx, users)
+	printer.MigrationStarted(ctx, posts)
+	posts.Duration = 30 * time.Millisecond
+	printer.MigrationFailed(ctx, posts, errors.New("table exists"))
+	printer.BatchFinished(ctx, BatchProgress{Operation: OperationMigrate, Total: 2}, errors.New("table exists"))
+
+	assert.Equal(t, "[1/2] ⏳ 001_create_users\n"+
+		"[1/2] ✅ 001_create_users (1.2s)\n"+
+		"[2/2] ⏳ 002_create_posts\n"+
+		"[2/2] ❌ 002_create_posts (30ms): table exists\n", out.String())
+}
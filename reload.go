@@ -0,0 +1,127 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+)
+
+// Reload reads the SQL migrations of the directories registered by LoadFromDir
+// and LoadFromFS again, along with Config.MigrationOrderFile, so a long-running
+// process picks up migrations shipped after it started. Migrations registered
+// in Go are kept as they are.
+//
+// The new registry is validated before it replaces the current one: the files
+// must parse, names must be unique, the migration order must match, and the
+// scripts of applied migrations must not have been edited, failing with
+// ErrChecksumMismatch. On failure the current registry stays in place. On
+// success EventMigrationAdded and EventMigrationRemoved are emitted for the
+// migrations that appeared and disappeared. Pending migrations whose files
+// changed are registered with their new scripts.
+func (q *GoMigration) Reload(ctx context.Context) error {
+	if err := q.driver.CreateMigrationsTable(ctx); err != nil {
+		return err
+	}
+	executedMigrations, err := q.driver.GetExecutedMigrations(ctx, HistoryOrderApplied)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	added, removed, err := q.reload(executedMigrations)
+	total := len(q.migrations)
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	q.logger.info(fmt.Sprintf("🔄 Reloaded migrations: %d added, %d removed", len(added), len(removed)), "reloaded migrations", "added", len(added), "removed", len(removed))
+	for _, name := range added {
+		q.emit(ctx, Event{Type: EventMigrationAdded, Operation: OperationReload, Time: time.Now(), Migration: name, Total: total})
+	}
+	for _, name := range removed {
+		q.emit(ctx, Event{Type: EventMigrationRemoved, Operation: OperationReload, Time: time.Now(), Migration: name, Total: total})
+	}
+	return nil
+}
+
+// reload swaps the registry for one read from the file sources again,
+// returning the names of the migrations added and removed. The registry is
+// left unchanged if the new one is invalid. It must be called with q.mu held.
+func (q *GoMigration) reload(executedMigrations []ExecutedMigration) (added, removed []string, err error) {
+	migrations := maps.Clone(q.migrations)
+	registeredFrom := maps.Clone(q.registeredFrom)
+	for _, source := range q.fileSources {
+		for _, name := range source.names {
+			delete(migrations, name)
+			delete(registeredFrom, name)
+		}
+	}
+
+	sources := slices.Clone(q.fileSources)
+	for i, source := range sources {
+		read, files, err := source.read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to reload %s: %w", source.location, err)
+		}
+		for _, m := range read {
+			if _, exists := migrations[m.Name()]; exists {
+				return nil, nil, fmt.Errorf("failed to reload %s: migration %s registered more than once", source.location, m.Name())
+			}
+			migrations[m.Name()] = m
+			registeredFrom[m.Name()] = files[m.Name()]
+		}
+		sources[i].names = slices.Sorted(maps.Keys(files))
+	}
+
+	order := q.migrationOrder
+	if q.migrationOrderFile != "" {
+		f, err := os.Open(q.migrationOrderFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open migration order file: %w", err)
+		}
+		defer f.Close()
+		if order, err = parseMigrationOrder(f); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	previous, previousFrom, previousOrder := q.migrations, q.registeredFrom, q.migrationOrder
+	q.migrations, q.registeredFrom, q.migrationOrder = migrations, registeredFrom, order
+	if err := q.validateRegistry(executedMigrations); err != nil {
+		q.migrations, q.registeredFrom, q.migrationOrder = previous, previousFrom, previousOrder
+		return nil, nil, err
+	}
+	q.fileSources = sources
+
+	for _, name := range slices.Sorted(maps.Keys(migrations)) {
+		if _, ok := previous[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(previous)) {
+		if _, ok := migrations[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed, nil
+}
+
+// validateRegistry checks that the registered migrations can be ordered and
+// that none of the executed migrations was edited since it was applied.
+func (q *GoMigration) validateRegistry(executedMigrations []ExecutedMigration) error {
+	if _, err := q.orderedMigrationNames(); err != nil {
+		return err
+	}
+
+	var edited []string
+	for _, m := range executedMigrations {
+		if q.editedSinceApplied(m) {
+			edited = append(edited, m.Name)
+		}
+	}
+	return checksumMismatchError(edited)
+}
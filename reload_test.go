@@ -0,0 +1,108 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoMigration_Reload(t *testing.T) {
+	ctx := context.TODO()
+	fsys := fstest.MapFS{
+		"001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INTEGER);")},
+	}
+	applied := scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER);"}
+
+	driver := new(mockDriver)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("GetExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{
+		{Name: "001_create_users", Checksum: migrationChecksum(applied)},
+	}, nil)
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations", migrations: make(map[string]Migration)}
+	assert.NoError(t, q.Register(dummyMigration{name: "000_seed_roles"}))
+	assert.NoError(t, q.LoadFromFS(fsys, "."))
+
+	var events []Event
+	q.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+		events = append(events, event)
+	}))
+
+	fsys["002_create_orders.sql"] = &fstest.MapFile{Data: []byte("-- +migrate Up\nCREATE TABLE orders (id INTEGER);\n")}
+	assert.NoError(t, q.Reload(ctx))
+	assert.Len(t, q.Migrations(), 3)
+	assert.Equal(t, []Event{{Type: EventMigrationAdded, Operation: OperationReload, Migration: "002_create_orders", Total: 3}}, withoutTime(events))
+
+	events = nil
+	delete(fsys, "002_create_orders.sql")
+	assert.NoError(t, q.Reload(ctx))
+	assert.Len(t, q.Migrations(), 2)
+	assert.Equal(t, []Event{{Type: EventMigrationRemoved, Operation: OperationReload, Migration: "002_create_orders", Total: 2}}, withoutTime(events))
+
+	events = nil
+	fsys["000_seed_roles.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	assert.ErrorContains(t, q.Reload(ctx), "migration 000_seed_roles registered more than once")
+	delete(fsys, "000_seed_roles.up.sql")
+
+	fsys["001_create_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id BIGINT);")}
+	fsys["003_create_invoices.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE invoices (id INTEGER);")}
+	assert.ErrorIs(t, q.Reload(ctx), ErrChecksumMismatch)
+	assert.Len(t, q.Migrations(), 2)
+	assert.Equal(t, applied, q.migrations["001_create_users"])
+	assert.Empty(t, events)
+}
+
+func TestGoMigration_Reload_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "reload.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "001_create_users.up.sql"), []byte("CREATE TABLE users (id INTEGER);"), 0644))
+
+	q, err := New(&Config{Driver: driver, MigrationTableName: "migrations", MigrationFilesDir: dir})
+	assert.NoError(t, err)
+	assert.NoError(t, q.LoadFromDir())
+	assert.NoError(t, q.Migrate(ctx))
+
+	// Readers run against the registry while Register and Reload change it,
+	// as in a long-running admin process; go test -race reports any race.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 20 {
+			assert.NoError(t, q.Register(dummyMigration{name: fmt.Sprintf("%03d_registered", 100+i)}))
+			assert.NoError(t, q.Reload(ctx))
+		}
+	}()
+	for range 20 {
+		_, err := q.Status(ctx)
+		assert.NoError(t, err)
+		_, err = q.List(ctx)
+		assert.NoError(t, err)
+		_, err = q.Validate(ctx)
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, status.Pending)
+}
+
+// withoutTime returns events with their Time cleared, for comparison.
+func withoutTime(events []Event) []Event {
+	for i := range events {
+		events[i].Time = time.Time{}
+	}
+	return events
+}
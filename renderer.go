@@ -0,0 +1,153 @@
+package gomigration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+// Renderer formats everything the CLI prints. Embedders can set
+// CliConfig.Renderer to brand or reformat the output of the built-in commands
+// without reimplementing them.
+//
+// Results are written to the command's standard output, messages and errors
+// to its standard error.
+type Renderer interface {
+	// List renders the result of the list command.
+	List(w io.Writer, list RegisteredMigrationList) error
+	// Status renders the result of the status command.
+	Status(w io.Writer, status MigrationStatus) error
+	// RepairReport renders the changes made by the repair command.
+	RepairReport(w io.Writer, report RepairReport) error
+	// ValidationReport renders the result of the validate command.
+	ValidationReport(w io.Writer, report ValidationReport) error
+	// RunSummary renders the summary printed when a command that ran
+	// migrations exits.
+	RunSummary(w io.Writer, summary RunSummary) error
+	// Message renders an informational message.
+	Message(w io.Writer, msg string) error
+	// Error renders a command failure. msg describes what failed and err, which
+	// may be nil, is the cause.
+	Error(w io.Writer, msg string, err error) error
+}
+
+// builtinRenderers are the renderers selectable with the --output flag.
+var builtinRenderers = map[string]Renderer{
+	"table": TableRenderer{},
+	"json":  JSONRenderer{},
+	"quiet": QuietRenderer{},
+}
+
+// TableRenderer renders results as text tables and messages as log lines. It
+// is the default Renderer.
+type TableRenderer struct{}
+
+func (TableRenderer) List(w io.Writer, list RegisteredMigrationList) error {
+	list.fprint(w)
+	return nil
+}
+
+func (TableRenderer) Status(w io.Writer, status MigrationStatus) error {
+	status.fprint(w)
+	return nil
+}
+
+func (TableRenderer) RepairReport(w io.Writer, report RepairReport) error {
+	report.fprint(w)
+	return nil
+}
+
+func (TableRenderer) ValidationReport(w io.Writer, report ValidationReport) error {
+	report.fprint(w)
+	return nil
+}
+
+func (TableRenderer) RunSummary(w io.Writer, summary RunSummary) error {
+	summary.fprint(w)
+	return nil
+}
+
+func (TableRenderer) Message(w io.Writer, msg string) error {
+	log.New(w, "", log.LstdFlags).Println(msg)
+	return nil
+}
+
+func (TableRenderer) Error(w io.Writer, msg string, err error) error {
+	if err == nil {
+		log.New(w, "", log.LstdFlags).Println(msg)
+		return nil
+	}
+	log.New(w, "", log.LstdFlags).Println(msg, err)
+	return nil
+}
+
+// JSONRenderer renders results, messages and errors as JSON documents, one
+// per line, for consumption by other programs.
+type JSONRenderer struct{}
+
+func (JSONRenderer) List(w io.Writer, list RegisteredMigrationList) error {
+	if list == nil {
+		list = RegisteredMigrationList{}
+	}
+	return json.NewEncoder(w).Encode(list)
+}
+
+func (JSONRenderer) Status(w io.Writer, status MigrationStatus) error {
+	return json.NewEncoder(w).Encode(status)
+}
+
+func (JSONRenderer) RepairReport(w io.Writer, report RepairReport) error {
+	if report == nil {
+		report = RepairReport{}
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+func (JSONRenderer) ValidationReport(w io.Writer, report ValidationReport) error {
+	if report == nil {
+		report = ValidationReport{}
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+func (JSONRenderer) RunSummary(w io.Writer, summary RunSummary) error {
+	return json.NewEncoder(w).Encode(summary)
+}
+
+func (JSONRenderer) Message(w io.Writer, msg string) error {
+	return json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
+
+func (JSONRenderer) Error(w io.Writer, msg string, err error) error {
+	out := map[string]string{"message": msg}
+	if err != nil {
+		out["error"] = err.Error()
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// QuietRenderer renders nothing but errors, as plain lines, for scripts that
+// only care about the exit status.
+type QuietRenderer struct{}
+
+func (QuietRenderer) List(io.Writer, RegisteredMigrationList) error { return nil }
+
+func (QuietRenderer) Status(io.Writer, MigrationStatus) error { return nil }
+
+func (QuietRenderer) RepairReport(io.Writer, RepairReport) error { return nil }
+
+func (QuietRenderer) ValidationReport(io.Writer, ValidationReport) error { return nil }
+
+func (QuietRenderer) RunSummary(io.Writer, RunSummary) error { return nil }
+
+func (QuietRenderer) Message(io.Writer, string) error { return nil }
+
+func (QuietRenderer) Error(w io.Writer, msg string, err error) error {
+	if err == nil {
+		_, werr := fmt.Fprintln(w, msg)
+		return werr
+	}
+	_, werr := fmt.Fprintln(w, msg, err)
+	return werr
+}
@@ -0,0 +1,76 @@
+package gomigration
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	r := TableRenderer{}
+
+	assert.NoError(t, r.List(&buf, RegisteredMigrationList{{Name: "create_orders"}}))
+	assert.Contains(t, buf.String(), "Migration Name")
+	assert.Contains(t, buf.String(), "create_orders")
+
+	buf.Reset()
+	assert.NoError(t, r.RepairReport(&buf, nil))
+	assert.Equal(t, "Nothing to repair.\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, r.ValidationReport(&buf, nil))
+	assert.Equal(t, "All migrations are valid.\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, r.RunSummary(&buf, RunSummary{Command: "migrate", Applied: []string{"001_a"}, Slowest: "001_a", SlowestDuration: 1500 * time.Millisecond}))
+	assert.Contains(t, buf.String(), "001_a (1.5s)")
+	assert.Contains(t, buf.String(), "succeeded")
+
+	buf.Reset()
+	assert.NoError(t, r.Error(&buf, "Error running migrations:", errors.New("boom")))
+	assert.Contains(t, buf.String(), "Error running migrations: boom\n")
+}
+
+func TestJSONRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	r := JSONRenderer{}
+
+	executedAt := time.Date(2025, 4, 18, 22, 0, 11, 0, time.UTC)
+	assert.NoError(t, r.List(&buf, RegisteredMigrationList{
+		{Name: "create_orders", UpScript: "CREATE TABLE orders (id INT);", IsExecuted: true, ExecutedAt: &executedAt},
+	}))
+	assert.JSONEq(t, `[{"name":"create_orders","up_script":"CREATE TABLE orders (id INT);","down_script":"","is_executed":true,"executed_at":"2025-04-18T22:00:11Z"}]`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, r.RepairReport(&buf, nil))
+	assert.JSONEq(t, `[]`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, r.ValidationReport(&buf, ValidationReport{{Migration: "001_a", Type: ValidationEmptyUpScript}}))
+	assert.JSONEq(t, `[{"migration":"001_a","type":"empty up script","detail":""}]`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, r.Error(&buf, "Error running migrations:", errors.New("boom")))
+	assert.JSONEq(t, `{"message":"Error running migrations:","error":"boom"}`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, r.Message(&buf, "migration plan written to: plan.sql"))
+	assert.JSONEq(t, `{"message":"migration plan written to: plan.sql"}`, buf.String())
+}
+
+func TestQuietRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	r := QuietRenderer{}
+
+	assert.NoError(t, r.List(&buf, RegisteredMigrationList{{Name: "create_orders"}}))
+	assert.NoError(t, r.Status(&buf, MigrationStatus{Pending: 1}))
+	assert.NoError(t, r.Message(&buf, "migration plan written to: plan.sql"))
+	assert.Empty(t, buf.String())
+
+	assert.NoError(t, r.Error(&buf, "Step must be greater than 0", nil))
+	assert.Equal(t, "Step must be greater than 0\n", buf.String())
+}
@@ -0,0 +1,316 @@
+package gomigration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Schema describes the tables of a database and their columns, in order.
+type Schema []SchemaTable
+
+// SchemaTable is a table of a Schema.
+type SchemaTable struct {
+	// Name is the table name, quoted as written in the schema file.
+	Name string
+	// Definition is the CREATE TABLE statement of the table. It is empty for
+	// tables inspected from the database.
+	Definition string
+	Columns    []SchemaColumn
+}
+
+// SchemaColumn is a column of a SchemaTable.
+type SchemaColumn struct {
+	// Name is the column name, quoted as written in the schema file.
+	Name string
+	// Definition is the column definition, such as "email VARCHAR(255) NOT
+	// NULL". It is empty for columns inspected from the database.
+	Definition string
+}
+
+// SchemaInspector is implemented by drivers that can describe the tables of
+// the live database. It is required by Diff.
+type SchemaInspector interface {
+	// InspectSchema returns the tables of the current schema and their
+	// columns, leaving out the tracking table and the tables kept next to it.
+	InspectSchema(ctx context.Context) (Schema, error)
+}
+
+// createTablePattern matches the start of a CREATE TABLE statement, up to the
+// table name.
+var createTablePattern = regexp.MustCompile(`(?i)^\s*CREATE\s+(?:TEMPORARY\s+|TEMP\s+|UNLOGGED\s+)?TABLE(?:\s+IF\s+NOT\s+EXISTS)?\b`)
+
+// tableConstraintKeywords start the entries of a CREATE TABLE statement that
+// are constraints or indexes rather than columns.
+var tableConstraintKeywords = map[string]bool{
+	"CONSTRAINT": true,
+	"PRIMARY":    true,
+	"UNIQUE":     true,
+	"FOREIGN":    true,
+	"CHECK":      true,
+	"INDEX":      true,
+	"KEY":        true,
+	"FULLTEXT":   true,
+	"SPATIAL":    true,
+	"EXCLUDE":    true,
+}
+
+// ParseSchema reads the CREATE TABLE statements of a schema file describing
+// the target state of the database. Other statements are ignored.
+func ParseSchema(r io.Reader) (Schema, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	script := string(data)
+	stripped := stripSQLCommentsAndStrings(script)
+
+	schema := Schema{}
+	start := 0
+	for start < len(script) {
+		end := strings.IndexByte(stripped[start:], ';')
+		if end < 0 {
+			end = len(script)
+		} else {
+			end += start
+		}
+
+		table, ok, err := parseCreateTable(script[start:end], stripped[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			schema = append(schema, table)
+		}
+		start = end + 1
+	}
+
+	return schema, nil
+}
+
+// parseCreateTable parses statement if it is a CREATE TABLE statement.
+// stripped is statement with its comments and strings blanked out.
+func parseCreateTable(statement, stripped string) (SchemaTable, bool, error) {
+	loc := createTablePattern.FindStringIndex(stripped)
+	if loc == nil {
+		return SchemaTable{}, false, nil
+	}
+
+	rest := statement[loc[1]:]
+	start := loc[1] + len(rest) - len(strings.TrimLeft(rest, " \t\r\n"))
+	name := sqlIdentifier(statement[start:])
+	after := start + len(name)
+	paren := strings.IndexByte(stripped[after:], '(')
+	if name == "" || paren < 0 {
+		return SchemaTable{}, false, fmt.Errorf("invalid CREATE TABLE statement: %s", strings.TrimSpace(statement))
+	}
+
+	// Leave out the comments around the statement.
+	begin := len(stripped) - len(strings.TrimLeft(stripped, " \t\r\n"))
+	end := len(strings.TrimRight(stripped, " \t\r\n"))
+	table := SchemaTable{Name: name, Definition: statement[begin:end] + ";"}
+	depth := 0
+	entry := after + paren + 1
+	for i := after + paren; i < len(stripped); i++ {
+		switch stripped[i] {
+		case '(':
+			depth++
+			continue
+		case ')':
+			depth--
+			if depth > 0 {
+				continue
+			}
+		case ',':
+			if depth > 1 {
+				continue
+			}
+		default:
+			continue
+		}
+
+		// An entry of the table definition ends here.
+		definition := strings.TrimSpace(statement[entry:i])
+		entry = i + 1
+		if column := sqlIdentifier(definition); definition != "" && !tableConstraintKeywords[strings.ToUpper(column)] {
+			table.Columns = append(table.Columns, SchemaColumn{Name: column, Definition: definition})
+		}
+		if depth == 0 {
+			return table, true, nil
+		}
+	}
+
+	return SchemaTable{}, false, fmt.Errorf("unterminated CREATE TABLE statement: %s", strings.TrimSpace(statement))
+}
+
+// sqlIdentifier returns the possibly quoted and qualified identifier s starts
+// with, after any leading spaces.
+func sqlIdentifier(s string) string {
+	s = strings.TrimLeft(s, " \t\r\n")
+	end := 0
+	for end < len(s) {
+		switch c := s[end]; {
+		case c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			next := strings.IndexByte(s[end+1:], closing)
+			if next < 0 {
+				return s
+			}
+			end += next + 2
+		case c == '_' || c == '.' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+			end++
+		default:
+			return s[:end]
+		}
+	}
+	return s
+}
+
+// schemaKey returns the name tables and columns are matched by: unquoted,
+// unqualified and lower-cased.
+func schemaKey(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(strings.Trim(name, "\"`[]"))
+}
+
+// schemaDiff lists the changes converging a live schema to a target one.
+type schemaDiff struct {
+	// createTables are the target tables missing from the database.
+	createTables []SchemaTable
+	// addColumns are the target tables whose columns are missing from the
+	// database, with only these columns.
+	addColumns []SchemaTable
+	// unknown are the tables and columns, as "table" or "table.column", that
+	// exist in the database but not in the target schema.
+	unknown []string
+}
+
+// diffSchema compares the target schema against the live one. Tables and
+// columns are matched by name. Column types and constraints are not compared.
+func diffSchema(target, live Schema) schemaDiff {
+	liveColumns := map[string]map[string]bool{}
+	for _, table := range live {
+		columns := map[string]bool{}
+		for _, column := range table.Columns {
+			columns[schemaKey(column.Name)] = true
+		}
+		liveColumns[schemaKey(table.Name)] = columns
+	}
+
+	diff := schemaDiff{}
+	targetColumns := map[string]map[string]bool{}
+	for _, table := range target {
+		columns := map[string]bool{}
+		for _, column := range table.Columns {
+			columns[schemaKey(column.Name)] = true
+		}
+		targetColumns[schemaKey(table.Name)] = columns
+
+		existing, ok := liveColumns[schemaKey(table.Name)]
+		if !ok {
+			diff.createTables = append(diff.createTables, table)
+			continue
+		}
+		missing := SchemaTable{Name: table.Name}
+		for _, column := range table.Columns {
+			if !existing[schemaKey(column.Name)] {
+				missing.Columns = append(missing.Columns, column)
+			}
+		}
+		if len(missing.Columns) > 0 {
+			diff.addColumns = append(diff.addColumns, missing)
+		}
+	}
+
+	for _, table := range live {
+		columns, ok := targetColumns[schemaKey(table.Name)]
+		if !ok {
+			diff.unknown = append(diff.unknown, table.Name)
+			continue
+		}
+		for _, column := range table.Columns {
+			if !columns[schemaKey(column.Name)] {
+				diff.unknown = append(diff.unknown, table.Name+"."+column.Name)
+			}
+		}
+	}
+
+	return diff
+}
+
+// empty reports whether the target schema has nothing the database lacks.
+func (d schemaDiff) empty() bool {
+	return len(d.createTables) == 0 && len(d.addColumns) == 0
+}
+
+// upScript returns the statements creating the missing tables and columns.
+// Tables and columns only found in the database are listed as comments, to
+// be dropped by hand if intended.
+func (d schemaDiff) upScript() string {
+	var statements []string
+	for _, table := range d.createTables {
+		statements = append(statements, table.Definition)
+	}
+	for _, table := range d.addColumns {
+		for _, column := range table.Columns {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table.Name, column.Definition))
+		}
+	}
+	for _, name := range d.unknown {
+		statements = append(statements, fmt.Sprintf("-- %s exists in the database but not in the schema file", name))
+	}
+	return strings.Join(statements, "\n")
+}
+
+// downScript returns the statements reverting upScript, in reverse order.
+func (d schemaDiff) downScript() string {
+	var statements []string
+	for i := len(d.addColumns) - 1; i >= 0; i-- {
+		table := d.addColumns[i]
+		for j := len(table.Columns) - 1; j >= 0; j-- {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table.Name, table.Columns[j].Name))
+		}
+	}
+	for i := len(d.createTables) - 1; i >= 0; i-- {
+		statements = append(statements, fmt.Sprintf("DROP TABLE %s;", d.createTables[i].Name))
+	}
+	return strings.Join(statements, "\n")
+}
+
+// inspectSchema runs query, a dialect specific catalog query returning the
+// table and column names of the current schema ordered by table and column
+// position. The tracking table named table and the tables kept next to it,
+// whose names start with table followed by an underscore, are left out.
+func inspectSchema(ctx context.Context, db *sql.DB, table, query string) (Schema, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	defer rows.Close()
+
+	schema := Schema{}
+	for rows.Next() {
+		var tableName, columnName string
+		if err := rows.Scan(&tableName, &columnName); err != nil {
+			return nil, err
+		}
+		if tableName == table || strings.HasPrefix(tableName, table+"_") {
+			continue
+		}
+		if len(schema) == 0 || schema[len(schema)-1].Name != tableName {
+			schema = append(schema, SchemaTable{Name: tableName})
+		}
+		last := &schema[len(schema)-1]
+		last.Columns = append(last.Columns, SchemaColumn{Name: columnName})
+	}
+
+	return schema, rows.Err()
+}
@@ -0,0 +1,130 @@
+package gomigration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchema = `
+-- Target schema
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	email VARCHAR(255) NOT NULL DEFAULT '',
+	"display_name" TEXT,
+	balance NUMERIC(10, 2),
+	CONSTRAINT users_email_key UNIQUE (email)
+);
+
+CREATE INDEX users_email_idx ON users (email);
+
+CREATE TABLE orders (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER REFERENCES users (id),
+	note TEXT DEFAULT 'a; b, (c)'
+);
+`
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema(strings.NewReader(testSchema))
+	assert.NoError(t, err)
+	if !assert.Len(t, schema, 2) {
+		return
+	}
+
+	assert.Equal(t, "users", schema[0].Name)
+	assert.Equal(t, []SchemaColumn{
+		{Name: "id", Definition: "id SERIAL PRIMARY KEY"},
+		{Name: "email", Definition: "email VARCHAR(255) NOT NULL DEFAULT ''"},
+		{Name: `"display_name"`, Definition: `"display_name" TEXT`},
+		{Name: "balance", Definition: "balance NUMERIC(10, 2)"},
+	}, schema[0].Columns)
+	assert.True(t, strings.HasPrefix(schema[0].Definition, "CREATE TABLE IF NOT EXISTS users ("))
+	assert.True(t, strings.HasSuffix(schema[0].Definition, ");"))
+
+	assert.Equal(t, "orders", schema[1].Name)
+	assert.Len(t, schema[1].Columns, 3)
+	assert.Equal(t, "note TEXT DEFAULT 'a; b, (c)'", schema[1].Columns[2].Definition)
+}
+
+func TestParseSchema_Unterminated(t *testing.T) {
+	_, err := ParseSchema(strings.NewReader("CREATE TABLE users (id INTEGER"))
+	assert.ErrorContains(t, err, "unterminated CREATE TABLE statement")
+}
+
+func TestDiffSchema(t *testing.T) {
+	target, err := ParseSchema(strings.NewReader(testSchema))
+	assert.NoError(t, err)
+	live := Schema{
+		{Name: "users", Columns: []SchemaColumn{{Name: "id"}, {Name: "email"}, {Name: "legacy_flag"}}},
+		{Name: "sessions", Columns: []SchemaColumn{{Name: "id"}}},
+	}
+
+	diff := diffSchema(target, live)
+	assert.False(t, diff.empty())
+	assert.Equal(t, []string{"users.legacy_flag", "sessions"}, diff.unknown)
+
+	up := diff.upScript()
+	assert.Contains(t, up, "CREATE TABLE orders (")
+	assert.Contains(t, up, `ALTER TABLE users ADD COLUMN "display_name" TEXT;`+"\n"+"ALTER TABLE users ADD COLUMN balance NUMERIC(10, 2);")
+	assert.Contains(t, up, "-- sessions exists in the database but not in the schema file")
+	assert.NotContains(t, up, "users_email_idx")
+
+	assert.Equal(t, "ALTER TABLE users DROP COLUMN balance;\n"+`ALTER TABLE users DROP COLUMN "display_name";`+"\nDROP TABLE orders;", diff.downScript())
+}
+
+func TestDiffSchema_UpToDate(t *testing.T) {
+	target, err := ParseSchema(strings.NewReader(`CREATE TABLE "Users" (ID INTEGER, Email TEXT);`))
+	assert.NoError(t, err)
+	live := Schema{{Name: "users", Columns: []SchemaColumn{{Name: "id"}, {Name: "email"}}}}
+
+	assert.True(t, diffSchema(target, live).empty())
+}
+
+func TestGoMigration_Diff(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "diff.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	_, err = driver.db.ExecContext(ctx, `CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)`)
+	assert.NoError(t, err)
+	assert.NoError(t, driver.CreateMigrationsTable(ctx))
+
+	dir := filepath.Join(t.TempDir(), "migrations")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	q := &GoMigration{driver: driver, migrationFilesDir: dir}
+
+	schema := `CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, name TEXT NOT NULL DEFAULT '');`
+	assert.NoError(t, q.Diff(ctx, "add_name", strings.NewReader(schema)))
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 1) {
+		return
+	}
+	assert.True(t, strings.HasSuffix(files[0].Name(), "_add_name.go"))
+	code, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.NoError(t, err)
+	assert.Contains(t, string(code), "ALTER TABLE users ADD COLUMN name TEXT NOT NULL DEFAULT '';")
+	assert.Contains(t, string(code), "ALTER TABLE users DROP COLUMN name;")
+
+	// Nothing is generated once the database matches the schema.
+	_, err = driver.db.ExecContext(ctx, `ALTER TABLE users ADD COLUMN name TEXT NOT NULL DEFAULT ''`)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Diff(ctx, "add_name_again", strings.NewReader(schema)))
+	files, err = os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestGoMigration_Diff_NotSupported(t *testing.T) {
+	q := &GoMigration{driver: new(mockDriver), migrationFilesDir: t.TempDir()}
+
+	err := q.Diff(context.Background(), "sync_schema", strings.NewReader(""))
+	assert.Equal(t, ErrSchemaDiffNotSupported, err)
+}
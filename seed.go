@@ -0,0 +1,172 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Seeder fills the database with data, such as reference data or fixtures
+// for development. Unlike migrations, seeders are not recorded and may run any
+// number of times, so their scripts should be idempotent.
+type Seeder interface {
+	Name() string
+	SeedScript() string
+}
+
+// EnvironmentSeeder can optionally be implemented by a Seeder that only runs
+// in some environments, such as fixtures meant for "development" and
+// "staging". Seeders that do not implement it run in every environment.
+type EnvironmentSeeder interface {
+	Environments() []string
+}
+
+// SeedRunner is implemented by drivers that can run seeders. The built-in
+// drivers do.
+type SeedRunner interface {
+	// RunSeeder executes the script of seeder without recording it.
+	RunSeeder(ctx context.Context, seeder Seeder) error
+}
+
+// SeedOptions selects the seeders Seed runs.
+type SeedOptions struct {
+	// Env is the environment to seed. Defaults to Config.Environment.
+	Env string
+	// Only restricts the run to the named seeders.
+	Only []string
+}
+
+// NewSeeder returns a Seeder running script, only in the given environments
+// if any are given.
+func NewSeeder(name, script string, environments ...string) Seeder {
+	return scriptSeeder{name: name, script: script, environments: environments}
+}
+
+type scriptSeeder struct {
+	name         string
+	script       string
+	environments []string
+}
+
+func (s scriptSeeder) Name() string       { return s.name }
+func (s scriptSeeder) SeedScript() string { return s.script }
+
+func (s scriptSeeder) Environments() []string { return s.environments }
+
+// seederMigration runs a seeder through the migration machinery of the
+// built-in drivers, with the same transactions, timeouts and retries.
+type seederMigration struct {
+	seeder Seeder
+}
+
+func (m seederMigration) Name() string       { return m.seeder.Name() }
+func (m seederMigration) UpScript() string   { return m.seeder.SeedScript() }
+func (m seederMigration) DownScript() string { return "" }
+
+// RegisterSeeders adds seeders to the registry. Seed runs them in the order
+// they are registered, so seeders depending on the data of others must be
+// registered after them.
+func (q *GoMigration) RegisterSeeders(seeders ...Seeder) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, seeder := range seeders {
+		name := seeder.Name()
+		if name == "" {
+			return ErrSeederNameNotProvided
+		}
+		if q.seeder(name) != nil || slices.ContainsFunc(seeders[:i], func(s Seeder) bool { return s.Name() == name }) {
+			return fmt.Errorf("seeder %s registered more than once", name)
+		}
+	}
+
+	q.seeders = append(q.seeders, seeders...)
+	return nil
+}
+
+// seeder returns the registered seeder named name, nil if there is none.
+func (q *GoMigration) seeder(name string) Seeder {
+	for _, seeder := range q.seeders {
+		if seeder.Name() == name {
+			return seeder
+		}
+	}
+	return nil
+}
+
+// Seed runs the registered seeders of the environment, in the order they were
+// registered, while holding the migration lock. Seeders restricted to other
+// environments are skipped, and naming one in opts.Only is an error.
+func (q *GoMigration) Seed(ctx context.Context, opts SeedOptions) error {
+	q = q.snapshot()
+
+	runner, ok := q.driver.(SeedRunner)
+	if !ok {
+		return ErrSeedNotSupported
+	}
+
+	seeders, err := q.selectSeeders(opts)
+	if err != nil {
+		return err
+	}
+
+	return q.withLock(ctx, func() error {
+		if len(seeders) == 0 {
+			q.logger.info("✅ No seeders to run", "no seeders to run")
+			return nil
+		}
+
+		q.logger.info(fmt.Sprintf("🌱 Running %d seeder(s)...", len(seeders)), "running seeders", "count", len(seeders))
+		for _, seeder := range seeders {
+			q.logger.info("📦 Seeding: "+seeder.Name(), "seeding", "seeder", seeder.Name())
+			q.logger.sql(q.debugSql, seeder.SeedScript(), "seeder", seeder.Name())
+			if err := runner.RunSeeder(ctx, seeder); err != nil {
+				q.logger.error(fmt.Sprintf("❌ Seeder failed: %s - %s", seeder.Name(), err), "seeder failed", "seeder", seeder.Name(), "error", err)
+				return fmt.Errorf("failed to run seeder %s: %w", seeder.Name(), err)
+			}
+			q.logger.info("✅ Seeded: "+seeder.Name(), "seeded", "seeder", seeder.Name())
+		}
+		return nil
+	})
+}
+
+// selectSeeders returns the seeders opts selects, in registration order.
+func (q *GoMigration) selectSeeders(opts SeedOptions) ([]Seeder, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	env := opts.Env
+	if env == "" {
+		env = q.environment
+	}
+
+	for _, name := range opts.Only {
+		seeder := q.seeder(name)
+		if seeder == nil {
+			return nil, fmt.Errorf("%w: %s", ErrSeederNotRegistered, name)
+		}
+		if !seedsEnvironment(seeder, env) {
+			return nil, fmt.Errorf("%w: %s does not run in environment %q", ErrSeederNotInEnvironment, name, env)
+		}
+	}
+
+	var seeders []Seeder
+	for _, seeder := range q.seeders {
+		if len(opts.Only) > 0 && !slices.Contains(opts.Only, seeder.Name()) {
+			continue
+		}
+		if seedsEnvironment(seeder, env) {
+			seeders = append(seeders, seeder)
+		}
+	}
+	return seeders, nil
+}
+
+// seedsEnvironment reports whether seeder runs in env.
+func seedsEnvironment(seeder Seeder, env string) bool {
+	scoped, ok := seeder.(EnvironmentSeeder)
+	if !ok || len(scoped.Environments()) == 0 {
+		return true
+	}
+	return slices.Contains(scoped.Environments(), env)
+}
@@ -0,0 +1,55 @@
+package gomigration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoMigration_Seed(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(filepath.Join(t.TempDir(), "seed.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	_, err = driver.db.ExecContext(ctx, "CREATE TABLE countries (code TEXT PRIMARY KEY); CREATE TABLE users (name TEXT PRIMARY KEY);")
+	assert.NoError(t, err)
+
+	q := &GoMigration{driver: driver, migrationTableName: "migrations", migrations: make(map[string]Migration), environment: "production"}
+	driver.SetMigrationTableName(q.migrationTableName)
+	assert.NoError(t, q.RegisterSeeders(
+		NewSeeder("countries", "INSERT OR IGNORE INTO countries (code) VALUES ('ID'), ('NL');"),
+		NewSeeder("demo_users", "INSERT OR IGNORE INTO users (name) VALUES ('demo');", "development", "staging"),
+	))
+
+	count := func(table string) int {
+		var n int
+		assert.NoError(t, driver.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n))
+		return n
+	}
+
+	assert.NoError(t, q.Seed(ctx, SeedOptions{}))
+	assert.Equal(t, 2, count("countries"))
+	assert.Equal(t, 0, count("users"))
+
+	assert.NoError(t, q.Seed(ctx, SeedOptions{Env: "staging", Only: []string{"demo_users"}}))
+	assert.Equal(t, 1, count("users"))
+
+	err = q.Seed(ctx, SeedOptions{Only: []string{"demo_users"}})
+	assert.ErrorIs(t, err, ErrSeederNotInEnvironment)
+
+	err = q.Seed(ctx, SeedOptions{Only: []string{"unknown"}})
+	assert.ErrorIs(t, err, ErrSeederNotRegistered)
+
+	assert.ErrorContains(t, q.RegisterSeeders(NewSeeder("countries", "")), "seeder countries registered more than once")
+	assert.ErrorIs(t, q.RegisterSeeders(NewSeeder("", "")), ErrSeederNameNotProvided)
+
+	assert.NoError(t, q.RegisterSeeders(NewSeeder("broken", "INSERT INTO missing VALUES (1);")))
+	err = q.Seed(ctx, SeedOptions{})
+	assert.ErrorContains(t, err, "failed to run seeder broken")
+
+	q = &GoMigration{driver: &mockDriver{}}
+	assert.ErrorIs(t, q.Seed(ctx, SeedOptions{}), ErrSeedNotSupported)
+}
@@ -0,0 +1,139 @@
+package gomigration
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sqlStatement is a statement of a migration script.
+type sqlStatement struct {
+	sql string
+	// line is the line of the script the statement starts on, from 1,
+	// leading comments aside.
+	line int
+}
+
+// dollarQuotePattern matches the opening tag of a Postgres dollar-quoted
+// string, such as $$ or $body$.
+var dollarQuotePattern = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// splitStatements splits script into its statements, for databases that run
+// one statement per Exec. Statements end at semicolons outside of quoted
+// strings, quoted identifiers and comments; the semicolons are dropped, and
+// statements holding nothing but comments are left out.
+//
+// Function, trigger and procedure bodies are kept whole: on MySQL, DELIMITER
+// lines switch to another delimiter, such as $$, as the mysql client does; on
+// Postgres, dollar-quoted strings are skipped; and on any dialect, the lines
+// between -- +migrate StatementBegin and StatementEnd annotations, or their
+// goose equivalents, make a single statement.
+func splitStatements(script string, dialect Dialect) []sqlStatement {
+	var statements []sqlStatement
+	delimiter := ";"
+	// start is where the current statement starts, leading comments
+	// included, and code where its first byte of code is, or -1.
+	start, code := 0, -1
+	block := false
+	emit := func(end int) {
+		if code >= 0 {
+			sql := strings.TrimSpace(script[start:end])
+			if block {
+				sql = strings.TrimSpace(strings.TrimSuffix(sql, delimiter))
+			}
+			statements = append(statements, sqlStatement{sql: sql, line: 1 + strings.Count(script[:code], "\n")})
+		}
+		code = -1
+	}
+
+	for i := 0; i < len(script); {
+		if i == 0 || script[i-1] == '\n' {
+			lineEnd := strings.IndexByte(script[i:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(script)
+			} else {
+				lineEnd += i + 1
+			}
+			line := strings.TrimSpace(script[i:lineEnd])
+
+			annotation := ""
+			if matches := migrationAnnotationRegex.FindStringSubmatch(line); matches != nil {
+				annotation = strings.ToLower(matches[1])
+			}
+			fields := strings.Fields(line)
+			switch {
+			case annotation == "statementbegin" && !block:
+				emit(i)
+				block = true
+				i, start = lineEnd, lineEnd
+				continue
+			case annotation == "statementend" && block:
+				emit(i)
+				block = false
+				i, start = lineEnd, lineEnd
+				continue
+			case dialect == DialectMySQL && !block && len(fields) == 2 && strings.EqualFold(fields[0], "DELIMITER"):
+				emit(i)
+				delimiter = fields[1]
+				i, start = lineEnd, lineEnd
+				continue
+			}
+		}
+
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"), c == '#' && dialect == DialectMySQL:
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+			continue
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 4
+			}
+			i += end + 4
+			continue
+		case !block && strings.HasPrefix(script[i:], delimiter):
+			emit(i)
+			i += len(delimiter)
+			start = i
+			continue
+		}
+
+		if code < 0 && c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			code = i
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(script) && script[i] != c; i++ {
+				if script[i] == '\\' && dialect == DialectMySQL {
+					i++
+				}
+			}
+		case c == '$' && dialect == DialectPostgres && !isIdentifierByte(script, i-1):
+			if tag := dollarQuotePattern.FindString(script[i:]); tag != "" {
+				end := strings.Index(script[i+len(tag):], tag)
+				if end < 0 {
+					i = len(script)
+					continue
+				}
+				i += len(tag) + end + len(tag) - 1
+			}
+		}
+		i++
+	}
+	emit(len(script))
+	return statements
+}
+
+// isIdentifierByte reports whether script[i] can be part of an unquoted
+// identifier, which a dollar sign following it belongs to.
+func isIdentifierByte(script string, i int) bool {
+	if i < 0 {
+		return false
+	}
+	c := script[i]
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
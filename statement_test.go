@@ -0,0 +1,67 @@
+package gomigration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := "CREATE TABLE users (name TEXT DEFAULT 'it''s; fine');\n" +
+		"/* a; b */ INSERT INTO users VALUES (\"x;y\");\n" +
+		"\n" +
+		"-- only a comment;\n" +
+		"SELECT `a;b` FROM users"
+
+	assert.Equal(t, []sqlStatement{
+		{sql: "CREATE TABLE users (name TEXT DEFAULT 'it''s; fine')", line: 1},
+		{sql: "/* a; b */ INSERT INTO users VALUES (\"x;y\")", line: 2},
+		{sql: "-- only a comment;\nSELECT `a;b` FROM users", line: 5},
+	}, splitStatements(script, DialectMySQL))
+	assert.Empty(t, splitStatements("  ;\n-- nothing\n;", DialectMySQL))
+}
+
+func TestSplitStatements_Delimiter(t *testing.T) {
+	script := "CREATE TABLE audit (id INT);\n" +
+		"DELIMITER $$\n" +
+		"CREATE TRIGGER users_audit AFTER INSERT ON users FOR EACH ROW\n" +
+		"BEGIN\n" +
+		"  INSERT INTO audit VALUES (NEW.id);\n" +
+		"END$$\n" +
+		"DELIMITER ;\n" +
+		"DROP TABLE old_audit;\n"
+
+	assert.Equal(t, []sqlStatement{
+		{sql: "CREATE TABLE audit (id INT)", line: 1},
+		{sql: "CREATE TRIGGER users_audit AFTER INSERT ON users FOR EACH ROW\nBEGIN\n  INSERT INTO audit VALUES (NEW.id);\nEND", line: 3},
+		{sql: "DROP TABLE old_audit", line: 8},
+	}, splitStatements(script, DialectMySQL))
+}
+
+func TestSplitStatements_DollarQuotes(t *testing.T) {
+	script := "CREATE FUNCTION touch() RETURNS trigger AS $body$\n" +
+		"BEGIN\n" +
+		"  NEW.note := $$it's; fine$$;\n" +
+		"  RETURN NEW;\n" +
+		"END;\n" +
+		"$body$ LANGUAGE plpgsql;\n" +
+		"SELECT price$1 FROM t;"
+
+	assert.Equal(t, []sqlStatement{
+		{sql: "CREATE FUNCTION touch() RETURNS trigger AS $body$\nBEGIN\n  NEW.note := $$it's; fine$$;\n  RETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql", line: 1},
+		{sql: "SELECT price$1 FROM t", line: 7},
+	}, splitStatements(script, DialectPostgres))
+}
+
+func TestSplitStatements_StatementBlock(t *testing.T) {
+	script := "-- +goose StatementBegin\n" +
+		"CREATE FUNCTION one() RETURNS int AS 'SELECT 1; ' LANGUAGE sql;\n" +
+		"SELECT 2;\n" +
+		"-- +goose StatementEnd\n" +
+		"SELECT 3;"
+
+	assert.Equal(t, []sqlStatement{
+		{sql: "CREATE FUNCTION one() RETURNS int AS 'SELECT 1; ' LANGUAGE sql;\nSELECT 2", line: 2},
+		{sql: "SELECT 3", line: 5},
+	}, splitStatements(script, DialectSQLite))
+}
@@ -0,0 +1,80 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// runSummaryRecorder is an EventListener building the RunSummary of a CLI
+// command from the lifecycle events of its runs.
+type runSummaryRecorder struct {
+	mu      sync.Mutex
+	runs    int
+	summary RunSummary
+}
+
+func (r *runSummaryRecorder) HandleEvent(ctx context.Context, event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch event.Type {
+	case EventRunStarted:
+		r.runs++
+	case EventMigrationSucceeded:
+		if event.Operation == OperationRollback {
+			r.summary.RolledBack = append(r.summary.RolledBack, event.Migration)
+		} else {
+			r.summary.Applied = append(r.summary.Applied, event.Migration)
+		}
+		if event.Duration > r.summary.SlowestDuration {
+			r.summary.Slowest = event.Migration
+			r.summary.SlowestDuration = event.Duration
+		}
+	case EventMigrationFailed:
+		r.summary.Failed = event.Migration
+	case EventRunFinished:
+		if event.Error != "" {
+			r.summary.Error = event.Error
+		}
+	}
+}
+
+// result returns the summary recorded so far, and whether any run happened.
+func (r *runSummaryRecorder) result() (RunSummary, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	summary := r.summary
+	summary.Applied = append([]string{}, summary.Applied...)
+	summary.RolledBack = append([]string{}, summary.RolledBack...)
+	return summary, r.runs > 0
+}
+
+// warningCounter forwards log output to w and counts the warnings, the lines
+// marked with ⚠️.
+type warningCounter struct {
+	w     io.Writer
+	count atomic.Int32
+}
+
+func (c *warningCounter) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("⚠️")) {
+		c.count.Add(1)
+	}
+	return c.w.Write(p)
+}
+
+// writeRunSummary writes summary as indented JSON to the file at path,
+// replacing it.
+func writeRunSummary(path string, summary RunSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,112 @@
+package gomigration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSummaryRecorder(t *testing.T) {
+	ctx := context.Background()
+	r := &runSummaryRecorder{}
+
+	_, ran := r.result()
+	assert.False(t, ran)
+
+	r.HandleEvent(ctx, Event{Type: EventRunStarted, Operation: OperationMigrate})
+	r.HandleEvent(ctx, Event{Type: EventMigrationSucceeded, Operation: OperationMigrate, Migration: "001_a", Duration: time.Second})
+	r.HandleEvent(ctx, Event{Type: EventMigrationSucceeded, Operation: OperationMigrate, Migration: "002_b", Duration: 3 * time.Second})
+	r.HandleEvent(ctx, Event{Type: EventMigrationFailed, Operation: OperationMigrate, Migration: "003_c", Error: "boom"})
+	r.HandleEvent(ctx, Event{Type: EventRunFinished, Operation: OperationMigrate, Error: "boom"})
+	r.HandleEvent(ctx, Event{Type: EventRunStarted, Operation: OperationRollback})
+	r.HandleEvent(ctx, Event{Type: EventMigrationSucceeded, Operation: OperationRollback, Migration: "002_b", Duration: time.Second})
+	r.HandleEvent(ctx, Event{Type: EventRunFinished, Operation: OperationRollback})
+
+	summary, ran := r.result()
+	assert.True(t, ran)
+	assert.Equal(t, []string{"001_a", "002_b"}, summary.Applied)
+	assert.Equal(t, []string{"002_b"}, summary.RolledBack)
+	assert.Equal(t, "003_c", summary.Failed)
+	assert.Equal(t, "002_b", summary.Slowest)
+	assert.Equal(t, 3*time.Second, summary.SlowestDuration)
+	assert.Equal(t, "boom", summary.Error)
+}
+
+func TestWarningCounter(t *testing.T) {
+	var out bytes.Buffer
+	counter := &warningCounter{w: &out}
+	logger := log.New(counter, "", 0)
+
+	logger.Println("📦 Migrating: 001_a")
+	logger.Println("⚠️ Applying out of order, before executed 002_b: 001_a")
+	logger.Println("⚠️  Failed to release migration lock: boom")
+
+	assert.Equal(t, int32(2), counter.count.Load())
+	assert.Contains(t, out.String(), "📦 Migrating: 001_a")
+}
+
+func TestCli_RunSummary(t *testing.T) {
+	ctx := context.Background()
+	driver := new(mockDriver)
+	driver.On("CreateMigrationsTable", ctx).Return(nil)
+	driver.On("IterateExecutedMigrations", ctx, HistoryOrderApplied).Return([]ExecutedMigration{{Name: "001_a"}}, nil)
+	q := &GoMigration{driver: driver, migrations: map[string]Migration{}}
+
+	cli, err := NewCli(CliConfig{GoMigration: q, Locale: "en"})
+	assert.NoError(t, err)
+
+	cmd := cli.instrument(ctx, &cobra.Command{
+		Use: "migrate",
+		Run: func(cmd *cobra.Command, args []string) {
+			run := q.startRun(ctx, OperationMigrate, 1)
+			m := dummyMigration{name: "001_a"}
+			run.migrationStartedEvent(m)
+			log.Println("⚠️ Applying out of order")
+			run.migrationSucceededEvent(m)
+			_ = run.finish(nil)
+		},
+	})
+	path := filepath.Join(t.TempDir(), "summary.json")
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--output", "json", "--summary-out", path})
+	assert.NoError(t, cmd.Execute())
+
+	var rendered RunSummary
+	assert.NoError(t, json.Unmarshal(stderr.Bytes(), &rendered))
+	assert.Equal(t, "migrate", rendered.Command)
+	assert.Equal(t, []string{"001_a"}, rendered.Applied)
+	assert.Equal(t, 1, rendered.Warnings)
+	assert.Equal(t, "001_a", rendered.SchemaVersion)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var written RunSummary
+	assert.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, rendered, written)
+}
+
+func TestCli_RunSummary_NoRun(t *testing.T) {
+	cli, err := NewCli(CliConfig{GoMigration: &GoMigration{}, Locale: "en"})
+	assert.NoError(t, err)
+
+	cmd := cli.instrument(context.Background(), &cobra.Command{
+		Use:  "create",
+		RunE: func(cmd *cobra.Command, args []string) error { return errors.New("boom") },
+	})
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetOut(&stderr)
+	cmd.SetArgs([]string{})
+	assert.Error(t, cmd.Execute())
+	assert.NotContains(t, stderr.String(), "Summary")
+}
@@ -0,0 +1,122 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// TenantProvider lists the tenants of a multi-tenant application, such as the
+// customers of a SaaS that each get a schema of their own.
+type TenantProvider interface {
+	Tenants(ctx context.Context) ([]string, error)
+}
+
+// TenantProviderFunc adapts a function to the TenantProvider interface.
+type TenantProviderFunc func(ctx context.Context) ([]string, error)
+
+func (f TenantProviderFunc) Tenants(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// tenantNameRegex matches the tenant names that are safe to use as a schema,
+// database or file name.
+var tenantNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// validateTenant returns ErrInvalidTenant if tenant is not a valid tenant name.
+func validateTenant(tenant string) error {
+	if !tenantNameRegex.MatchString(tenant) {
+		return fmt.Errorf("%w: %q, only letters, digits and underscores are allowed", ErrInvalidTenant, tenant)
+	}
+	return nil
+}
+
+// ForTenant returns a GoMigration that migrates the schema or database of
+// tenant, with a tracking table of its own, as returned by the ForTenant of
+// the driver, which must implement TenantDriver. It starts with the
+// migrations, options and event listeners q has at the time of the call, and
+// its events carry the tenant. Call CloseTenant on it once done.
+func (q *GoMigration) ForTenant(ctx context.Context, tenant string) (*GoMigration, error) {
+	q.mu.Lock()
+	base := q.driver
+	if q.baseDriver != nil {
+		base = q.baseDriver
+	}
+	q.mu.Unlock()
+
+	td, ok := base.(TenantDriver)
+	if !ok {
+		return nil, ErrTenantsNotSupported
+	}
+	driver, err := td.ForTenant(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to tenant %s: %w", tenant, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := len(q.middleware) - 1; i >= 0; i-- {
+		driver = q.middleware[i](driver)
+	}
+	tq := q.clone()
+	tq.driver, tq.baseDriver, tq.tenant = driver, nil, tenant
+	return tq, nil
+}
+
+// Tenant returns the tenant q migrates, empty unless q was returned by
+// ForTenant.
+func (q *GoMigration) Tenant() string {
+	return q.tenant
+}
+
+// CloseTenant closes the driver of a GoMigration returned by ForTenant.
+func (q *GoMigration) CloseTenant() error {
+	if q.tenant == "" {
+		return fmt.Errorf("%w: not a tenant migration", ErrInvalidTenant)
+	}
+	return q.driver.Close()
+}
+
+// MigrateTenant applies the pending migrations to the schema or database of
+// tenant, as Migrate does with opts.
+func (q *GoMigration) MigrateTenant(ctx context.Context, tenant string, opts ...MigrateOption) error {
+	tq, err := q.ForTenant(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	defer tq.CloseTenant()
+
+	return tq.Migrate(ctx, opts...)
+}
+
+// MigrateAllTenants applies the pending migrations to every tenant listed by
+// Config.TenantProvider, one tenant at a time and in the listed order. A
+// failing tenant does not stop the others; the failures are returned
+// together as a *MultiError whose targets are the tenants.
+func (q *GoMigration) MigrateAllTenants(ctx context.Context, opts ...MigrateOption) error {
+	if q.tenantProvider == nil {
+		return ErrNoTenantProvider
+	}
+	tenants, err := q.tenantProvider.Tenants(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tenants: %w", err)
+	}
+
+	multiErr := &MultiError{}
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			multiErr.Failures = append(multiErr.Failures, TargetFailure{Target: tenant, Err: fmt.Errorf("%w: %w", ErrTargetNotStarted, ctx.Err())})
+			continue
+		}
+
+		q.logger.info(fmt.Sprintf("🏢 [%s] Migrating tenant", tenant), "migrating tenant", "tenant", tenant)
+		if err := q.MigrateTenant(ctx, tenant, opts...); err != nil {
+			q.logger.error(fmt.Sprintf("❌ [%s] %s", tenant, err), "tenant migration failed", "tenant", tenant, "error", err)
+			multiErr.Failures = append(multiErr.Failures, TargetFailure{Target: tenant, Err: err})
+			continue
+		}
+		q.logger.info(fmt.Sprintf("✅ [%s] Done", tenant), "tenant migrated", "tenant", tenant)
+	}
+	return multiErr.orNil()
+}
@@ -0,0 +1,68 @@
+package gomigration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoMigration_MigrateAllTenants(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	driver, err := NewSqliteDriver(filepath.Join(dir, "app.db"))
+	assert.NoError(t, err)
+	defer driver.Close()
+	driver.SetMigrationTableName("migrations")
+
+	q := &GoMigration{
+		driver:             driver,
+		migrationTableName: "migrations",
+		migrations:         make(map[string]Migration),
+		tenantProvider: TenantProviderFunc(func(ctx context.Context) ([]string, error) {
+			return []string{"acme", "bad-name", "globex"}, nil
+		}),
+	}
+	assert.NoError(t, q.Register(scriptMigration{name: "001_create_users", upScript: "CREATE TABLE users (id INTEGER);"}))
+
+	tenants := make(map[string]int)
+	q.Subscribe(EventListenerFunc(func(ctx context.Context, event Event) {
+		if event.Type == EventMigrationSucceeded {
+			tenants[event.Tenant]++
+		}
+	}))
+
+	err = q.MigrateAllTenants(ctx)
+	var multiErr *MultiError
+	if assert.ErrorAs(t, err, &multiErr) && assert.Len(t, multiErr.Failures, 1) {
+		assert.Equal(t, "bad-name", multiErr.Failures[0].Target)
+	}
+	assert.ErrorIs(t, err, ErrInvalidTenant)
+	assert.Equal(t, map[string]int{"acme": 1, "globex": 1}, tenants)
+
+	for _, tenant := range []string{"acme", "globex"} {
+		tq, err := q.ForTenant(ctx, tenant)
+		assert.NoError(t, err)
+		assert.Equal(t, tenant, tq.Tenant())
+		status, err := tq.Status(ctx)
+		assert.NoError(t, err)
+		assert.True(t, status.UpToDate)
+		assert.NoError(t, tq.CloseTenant())
+	}
+	assert.FileExists(t, filepath.Join(dir, "app_acme.db"))
+
+	status, err := q.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, status.Pending)
+	assert.Error(t, q.CloseTenant())
+
+	assert.NoError(t, q.MigrateTenant(ctx, "acme"))
+
+	q.tenantProvider = nil
+	assert.ErrorIs(t, q.MigrateAllTenants(ctx), ErrNoTenantProvider)
+
+	q = &GoMigration{driver: &mockDriver{}}
+	_, err = q.ForTenant(ctx, "acme")
+	assert.ErrorIs(t, err, ErrTenantsNotSupported)
+}
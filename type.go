@@ -1,13 +1,194 @@
 package gomigration
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
 	"time"
 )
 
 type ExecutedMigration struct {
 	Name       string    `json:"name"`
 	ExecutedAt time.Time `json:"executed_at"`
+	// Checksum is the SHA-256 of the up script at the time the migration was
+	// applied. It is empty for records created before checksums were tracked.
+	Checksum string `json:"checksum"`
+	// Batch numbers the Migrate call that applied the migration. It is 0 for
+	// records created before batches were tracked or by MarkApplied.
+	Batch int `json:"batch,omitempty"`
+	// AppliedBy identifies who or what applied the migration, as configured by
+	// Config.AppliedBy. It is empty for records created before it was tracked.
+	AppliedBy string `json:"applied_by,omitempty"`
+	// Namespace is the name of the MigrationSet the migration belongs to. It
+	// is empty for the service's own migrations and for records created
+	// before it was tracked.
+	Namespace string `json:"namespace,omitempty"`
+	// Build identifies the code revision that applied the migration, as
+	// configured by Config.Build. It is nil for records created without build
+	// information.
+	Build *BuildInfo `json:"build,omitempty"`
+	// Skipped reports whether the migration was recorded without running
+	// because its ConditionalMigration.ShouldRun returned false.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// BuildInfo identifies the build of the code applying migrations, so every
+// schema change can be traced back to the revision that shipped it.
+type BuildInfo struct {
+	// Commit is the VCS revision, such as a git SHA.
+	Commit string `json:"commit,omitempty"`
+	// Version is the release version, such as a tag.
+	Version string `json:"version,omitempty"`
+	// RunURL links to the CI run or deployment that applied the migrations.
+	RunURL string `json:"run_url,omitempty"`
+}
+
+// orNil returns nil if b holds no information, so nothing is recorded.
+func (b BuildInfo) orNil() *BuildInfo {
+	if b == (BuildInfo{}) {
+		return nil
+	}
+	return &b
+}
+
+// HistoryExport is the JSON document written by ExportHistory and read by
+// ImportHistory.
+type HistoryExport struct {
+	// Table is the name of the tracking table the history was exported from.
+	Table      string    `json:"table"`
+	ExportedAt time.Time `json:"exported_at"`
+	// Migrations are the executed migrations, in apply order.
+	Migrations []ExecutedMigration `json:"migrations"`
+}
+
+// HistoryOrder selects how the executed migration history is sorted.
+type HistoryOrder int
+
+const (
+	// HistoryOrderApplied sorts by application order, oldest first.
+	HistoryOrderApplied HistoryOrder = iota
+	// HistoryOrderAppliedDesc sorts by application order, most recent first.
+	// Rollbacks use it to undo migrations in the reverse of how they ran.
+	HistoryOrderAppliedDesc
+	// HistoryOrderName sorts by migration name.
+	HistoryOrderName
+	// HistoryOrderNameDesc sorts by migration name, descending.
+	HistoryOrderNameDesc
+)
+
+// OutOfOrderPolicy decides what happens when a pending migration is ordered
+// before one that has already been executed, typically because it comes from
+// a branch merged late.
+type OutOfOrderPolicy int
+
+const (
+	// OutOfOrderAllow applies out-of-order migrations silently.
+	OutOfOrderAllow OutOfOrderPolicy = iota
+	// OutOfOrderWarn applies out-of-order migrations and logs a warning.
+	OutOfOrderWarn
+	// OutOfOrderFail refuses to apply anything while migrations are out of
+	// order, failing with ErrOutOfOrderMigration.
+	OutOfOrderFail
+)
+
+// ImplicitCommitPolicy decides what happens when a migration that runs in a
+// transaction on MySQL contains DDL, which commits implicitly and so makes the
+// migration not atomic.
+type ImplicitCommitPolicy int
+
+const (
+	// ImplicitCommitWarn runs the migration and logs a warning.
+	ImplicitCommitWarn ImplicitCommitPolicy = iota
+	// ImplicitCommitFail refuses to run the migration, failing with
+	// ErrImplicitCommit.
+	ImplicitCommitFail
+	// ImplicitCommitAllow runs the migration silently.
+	ImplicitCommitAllow
+)
+
+// EmptyScriptPolicy decides what happens when a migration about to be applied
+// has an up script that is empty or only holds comments, which is almost
+// always a merge mistake.
+type EmptyScriptPolicy int
+
+const (
+	// EmptyScriptWarn applies the migration, recording it, and logs a warning.
+	EmptyScriptWarn EmptyScriptPolicy = iota
+	// EmptyScriptFail refuses to apply anything while a migration to apply
+	// has an empty up script, failing with ErrEmptyUpScript.
+	EmptyScriptFail
+	// EmptyScriptAllow applies the migration silently.
+	EmptyScriptAllow
+)
+
+// FailurePolicy decides what happens to the rest of a batch when a migration
+// fails to apply.
+type FailurePolicy int
+
+const (
+	// FailureStop stops at the first failed migration.
+	FailureStop FailurePolicy = iota
+	// FailureContinue applies the remaining migrations anyway and fails with
+	// a *BatchError holding the failure of every failed migration. Failed
+	// migrations are not recorded, so the next run retries them. It is meant
+	// for independent, idempotent migrations, such as per-table ANALYZE
+	// scripts: a failed non-transactional migration may be left half applied.
+	FailureContinue
+)
+
+// EnvExpansion decides whether ${VAR} references in migration scripts are
+// expanded from the environment when the scripts are executed.
+type EnvExpansion int
+
+const (
+	// EnvExpansionOff executes scripts as written.
+	EnvExpansionOff EnvExpansion = iota
+	// EnvExpansionLenient replaces ${VAR} with the value of the environment
+	// variable VAR, empty when it is not set.
+	EnvExpansionLenient
+	// EnvExpansionStrict replaces ${VAR} like EnvExpansionLenient, but fails
+	// the migration with ErrUndefinedEnvVar when VAR is not set.
+	EnvExpansionStrict
+)
+
+// RetryPolicy retries statements failing with transient errors: deadlocks,
+// lock wait timeouts, serialization failures and SQLITE_BUSY.
+type RetryPolicy struct {
+	// Attempts is how many times a failing statement is retried. Zero
+	// disables retrying.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled before each
+	// following one.
+	Backoff time.Duration
+}
+
+// wait sleeps before retry number attempt, counted from 0, returning early
+// with the error of ctx when it is done.
+func (r RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(r.Backoff << attempt)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ConnectRetryPolicy retries connecting to a database that is not reachable
+// yet, such as one starting alongside the application. New and the driver
+// constructors take no context, so the wait cannot be canceled; it is bounded
+// instead, each of the Attempts+1 pings giving up after 10 seconds.
+type ConnectRetryPolicy struct {
+	// Attempts is how many times a failed connection attempt is retried.
+	// Zero disables retrying.
+	Attempts int
+	// Interval is the wait between attempts.
+	Interval time.Duration
 }
 
 type Config struct {
@@ -15,25 +196,645 @@ type Config struct {
 	MigrationFilesDir  string
 	MigrationTableName string
 	DebugSql           bool
+
+	// Logger receives the log of GoMigration, of the built-in drivers and of
+	// the CLI as records with a level and attributes, instead of the lines
+	// written to the standard logger. The SQL of migrations and seeders is
+	// logged at debug level, whether DebugSql is set or not.
+	Logger *slog.Logger
+
+	// LogSQL logs every statement migrations and seeders run, tracking table
+	// updates included, with its duration and the number of rows it affected,
+	// at info level. The CLI turns it on with --verbose.
+	LogSQL bool
+
+	// MetricsCollector receives counters and durations of the migrations
+	// applied and failed, the duration of runs and the number of pending
+	// migrations, e.g. a PrometheusMetrics for deploy dashboards.
+	MetricsCollector MetricsCollector
+
+	// UseTransactions runs every migration, together with its tracking record,
+	// inside a single transaction. Migrations implementing
+	// NonTransactionalMigration can opt out individually.
+	UseTransactions bool
+
+	// CredentialRefresher is consulted by the MySQL and Postgres drivers when
+	// the database rejects the current credentials, typically because a
+	// short-lived IAM or Vault token expired mid-batch. The driver reconnects
+	// with the returned credentials and resumes with the migration that failed.
+	CredentialRefresher CredentialRefresher
+
+	// LockTimeout bounds how long Migrate and Rollback wait for the migration
+	// lock held by another process. Zero waits until the context is done.
+	LockTimeout time.Duration
+
+	// Locker replaces the driver's native migration lock with a custom one,
+	// e.g. backed by Redis, etcd or Consul, for drivers without native locking.
+	Locker Locker
+
+	// LockRenewInterval is how often the lease of a Locker implementing
+	// LeaseRenewer is renewed while migrations run. Defaults to 10 seconds.
+	LockRenewInterval time.Duration
+
+	// TrackingTimeout bounds each read or write of the tracking table, so a
+	// hung metadata query fails quickly. Defaults to 30 seconds; a negative
+	// value disables it.
+	TrackingTimeout time.Duration
+
+	// StatementTimeout bounds each migration script, which may legitimately
+	// run for a long time, e.g. to build an index. Postgres also enforces it
+	// on the server, as the statement_timeout of the connection the migration
+	// runs on. Zero means no limit.
+	StatementTimeout time.Duration
+
+	// DDLLockTimeout bounds how long each statement of a migration waits for a
+	// table or row lock, so DDL blocked behind application traffic fails fast
+	// instead of queuing, along with the queries behind it. Unlike LockTimeout,
+	// it is enforced by the server: it is set as the lock_timeout of Postgres
+	// and the lock_wait_timeout of MySQL, in whole seconds, on the connection
+	// the migration runs on, and reset afterwards. SQLite ignores it; set
+	// _pragma=busy_timeout(ms) in its DSN instead. Zero keeps the server
+	// defaults.
+	DDLLockTimeout time.Duration
+
+	// Retry retries the statements of migrations that fail with transient
+	// errors, so a deadlock with application traffic does not fail the
+	// deploy. Outside of transactions, MySQL and Postgres retry the failing
+	// statement; in transactions, the rolled back transaction is retried as a
+	// whole. SQLite only retries migrations run in transactions, as a script
+	// failing halfway may have been partly applied. Zero disables retrying.
+	Retry RetryPolicy
+
+	// ConnectRetry makes New wait for the database of the driver to accept
+	// connections, for built-in drivers created before it started. The MySQL
+	// and Postgres constructors take the same policy, as they connect right
+	// away. The wait cannot be canceled, but is bounded by the policy.
+	ConnectRetry ConnectRetryPolicy
+
+	// MigrationOrderFile is the path of an explicit migration order manifest,
+	// conventionally migrations.list, listing one migration name per line.
+	// When set, migrations run in the listed order instead of by name, and
+	// Create appends new migrations to it. See GoMigration.LoadMigrationOrder.
+	MigrationOrderFile string
+
+	// MigrationIDGenerator returns the prefix Create gives new migrations.
+	// Defaults to TimestampID; NewULIDGenerator and NewKSUIDGenerator avoid
+	// the collisions of migrations created within the same second, e.g. by
+	// code generators running per commit. ULID and KSUID names sort before
+	// timestamp ones, so pick the generator when starting a migration
+	// directory.
+	MigrationIDGenerator MigrationIDGenerator
+
+	// NamingStrategy decides how Create prefixes new migrations: with the ID
+	// of MigrationIDGenerator, or with sequential numbers such as 0042.
+	// Defaults to NamingTimestamp.
+	NamingStrategy NamingStrategy
+
+	// AppliedBy is recorded with every migration applied or marked as applied,
+	// so audits can tell CI runs from developer laptops or the application
+	// itself, e.g. a CI job ID or a user name. Defaults to the
+	// GOMIGRATION_APPLIED_BY environment variable, or the host name.
+	AppliedBy string
+
+	// Build is recorded with every migration applied or marked as applied.
+	// Empty fields default to the GOMIGRATION_COMMIT, GOMIGRATION_VERSION and
+	// GOMIGRATION_RUN_URL environment variables, and the commit to the VCS
+	// revision stamped in the binary by the go command.
+	Build BuildInfo
+
+	// AuditLog keeps an append-only audit log of every apply, rollback, clean
+	// and repair in a <MigrationTableName>_audit table, which CleanDatabase
+	// leaves in place. The driver must implement AuditLogger.
+	AuditLog bool
+
+	// OutOfOrderPolicy decides what happens when a pending migration is
+	// ordered before an executed one. Defaults to OutOfOrderAllow.
+	OutOfOrderPolicy OutOfOrderPolicy
+
+	// StatementRewriter, if set, may rewrite every migration script before the
+	// built-in drivers execute it.
+	StatementRewriter StatementRewriter
+
+	// TemplateData, if set, runs every migration script through text/template
+	// with TemplateData as its data before the built-in drivers execute it, so
+	// the same migrations can use e.g. {{ .Schema }} to target a different
+	// schema per environment. Templating happens before StatementRewriter, a
+	// key missing from TemplateData fails the migration, and checksums are
+	// computed on the scripts before templating.
+	TemplateData map[string]any
+
+	// EnvExpansion, if set, expands ${VAR} references in migration scripts
+	// from the environment before the built-in drivers execute them, e.g. to
+	// pick a tablespace or storage options per host. Only the braced form is
+	// expanded, so $1 placeholders and dollar quoting are left alone.
+	// Expansion happens after templating and before StatementRewriter, and
+	// checksums are computed on the scripts before expansion. Defaults to
+	// EnvExpansionOff.
+	EnvExpansion EnvExpansion
+
+	// MaxMigrationDuration bounds how long each migration, including recording
+	// it, may run before it is cancelled and fails with ErrMigrationTimedOut,
+	// so a hung ALTER does not hang the deploy. Migrations implementing
+	// TimeBoxedMigration override it. Zero means no limit.
+	MaxMigrationDuration time.Duration
+
+	// ImplicitCommitPolicy decides what happens when a MySQL migration run in
+	// a transaction contains statements that commit implicitly, such as ALTER
+	// TABLE. Defaults to ImplicitCommitWarn.
+	ImplicitCommitPolicy ImplicitCommitPolicy
+
+	// SqliteFileLock makes the SQLite driver also hold an OS-level lock on a
+	// file next to the database while migrations run, named after the
+	// database and its lock table, e.g. app.db.migrations_lock. It keeps
+	// processes on different hosts sharing the database's volume from
+	// interleaving DDL. In-memory databases are not affected.
+	SqliteFileLock bool
+
+	// EmptyScriptPolicy decides what happens when a migration to apply has an
+	// up script that is empty or only holds comments. Defaults to
+	// EmptyScriptWarn.
+	EmptyScriptPolicy EmptyScriptPolicy
+
+	// FailurePolicy decides whether a failed migration stops the rest of the
+	// batch. It defaults to FailureStop.
+	FailurePolicy FailurePolicy
+
+	// LockScope narrows the migration lock, which otherwise covers everything
+	// sharing the tracking table. Runs with different scopes, e.g. one per
+	// group of migrations or tenant, migrate concurrently, while runs with the
+	// same scope are serialized. It must be a valid identifier, as SQLite keeps
+	// a lock table per scope.
+	LockScope string
+
+	// CleanBatchSize is the number of tables CleanDatabase drops per
+	// statement, so databases with thousands of tables stay within statement
+	// limits and StatementTimeout applies to each chunk. Defaults to 100.
+	CleanBatchSize int
+
+	// CleanParallelism is the number of chunks of tables CleanDatabase drops
+	// concurrently where that is safe, which is only on MySQL: Postgres drops
+	// them one at a time, as chunks dropped with CASCADE could deadlock, and
+	// SQLite allows a single writer. Defaults to 1.
+	CleanParallelism int
+
+	// FixturesDir is the directory LoadFixtures reads fixture files from.
+	// Defaults to "fixtures".
+	FixturesDir string
+
+	// Environment names the environment Seed runs seeders for, such as
+	// "development" or "production". Defaults to the GOMIGRATION_ENV
+	// environment variable.
+	Environment string
+
+	// CreateTemplates, if set, are the templates of the up and down scripts
+	// Create and CreateSQL write into new migrations instead of empty ones.
+	CreateTemplates CreateTemplates
+
+	// TenantProvider lists the tenants MigrateAllTenants migrates, each in a
+	// schema (Postgres), database (MySQL) or database file (SQLite) of its
+	// own. The driver must implement TenantDriver.
+	TenantProvider TenantProvider
+}
+
+// Locker is a distributed lock provider used to serialize migration runs.
+type Locker interface {
+	// Lock blocks until the lock identified by key is held or ctx is done.
+	Lock(ctx context.Context, key string) error
+
+	// Unlock releases the lock identified by key.
+	Unlock(ctx context.Context, key string) error
 }
 
+// LeaseRenewer is implemented by a Locker whose locks expire unless renewed.
+type LeaseRenewer interface {
+	Renew(ctx context.Context, key string) error
+}
+
+// CredentialRefresher returns fresh database credentials.
+type CredentialRefresher func(ctx context.Context) (user string, password string, err error)
+
 type Migration interface {
 	Name() string
 	UpScript() string
 	DownScript() string
 }
 
-type RegisteredMigration struct {
+// DescribedMigration can optionally be implemented by a Migration to explain
+// what it does. The description is reported by GoMigration.Migrations.
+type DescribedMigration interface {
+	Description() string
+}
+
+// TaggedMigration can optionally be implemented by a Migration to label it,
+// e.g. "cache" for migrations after which caches must be flushed. Tags select
+// the migrations an ApplyHook runs after, and those WithTagFilter runs.
+type TaggedMigration interface {
+	Tags() []string
+}
+
+// WithTags returns m labelled with tags, in addition to its own if it is a
+// TaggedMigration, for migrations that cannot implement TaggedMigration
+// themselves:
+//
+//	q.Register(gomigration.WithTags(&CreateInvoicesTable{}, "billing", "heavy"))
+func WithTags(m Migration, tags ...string) Migration {
+	return taggedMigration{Migration: m, tags: tags}
+}
+
+// taggedMigration is a migration labelled by WithTags. It keeps the optional
+// interfaces of the migration it wraps.
+type taggedMigration struct {
+	Migration
+	tags []string
+}
+
+func (m taggedMigration) Tags() []string {
+	tags := slices.Clone(migrationTags(m.Migration))
+	for _, tag := range m.tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (m taggedMigration) Description() string {
+	if described, ok := m.Migration.(DescribedMigration); ok {
+		return described.Description()
+	}
+	return ""
+}
+
+func (m taggedMigration) MaxDuration() time.Duration {
+	if timeBoxed, ok := m.Migration.(TimeBoxedMigration); ok {
+		return timeBoxed.MaxDuration()
+	}
+	return 0
+}
+
+func (m taggedMigration) NonTransactional() bool { return isNonTransactional(m.Migration) }
+
+func (m taggedMigration) Gate() MigrationGate {
+	if gated, ok := m.Migration.(GatedMigration); ok {
+		return gated.Gate()
+	}
+	return MigrationGate{}
+}
+
+func (m taggedMigration) Phase() Phase { return migrationPhase(m.Migration) }
+
+func (m taggedMigration) DependsOn() []string { return migrationDependencies(m.Migration) }
+
+func (m taggedMigration) ShouldRun(ctx context.Context, d Driver) (bool, error) {
+	return shouldRun(ctx, d, m.Migration)
+}
+
+func (m taggedMigration) DialectScript(dialect Dialect) (string, string, bool) {
+	if variants, ok := m.Migration.(DialectMigration); ok {
+		return variants.DialectScript(dialect)
+	}
+	return "", "", false
+}
+
+// DialectMigration can optionally be implemented by a Migration whose scripts
+// differ per database, e.g. a table created with SERIAL on Postgres and
+// AUTO_INCREMENT on MySQL. When ok is true, the built-in drivers run the
+// scripts returned for their Dialect instead of UpScript and DownScript.
+type DialectMigration interface {
+	DialectScript(dialect Dialect) (upScript, downScript string, ok bool)
+}
+
+// scriptMigration is a Migration made of its scripts, such as the baseline
+// registered by Squash.
+type scriptMigration struct {
+	name          string
+	upScript      string
+	downScript    string
+	noTransaction bool
+	// dependsOn are the names of the migrations it depends on.
+	dependsOn []string
+	// phase is the Phase of the migration, PreDeploy when empty.
+	phase Phase
+	// tags are the labels of the migration.
+	tags []string
+	// variants are the scripts replacing upScript and downScript on a
+	// dialect.
+	variants map[Dialect]scriptVariant
+}
+
+// scriptVariant holds the scripts of a scriptMigration on one dialect.
+type scriptVariant struct {
+	upScript   string
+	downScript string
+}
+
+func (m scriptMigration) Name() string           { return m.name }
+func (m scriptMigration) UpScript() string       { return m.upScript }
+func (m scriptMigration) DownScript() string     { return m.downScript }
+func (m scriptMigration) NonTransactional() bool { return m.noTransaction }
+
+func (m scriptMigration) DependsOn() []string { return m.dependsOn }
+func (m scriptMigration) Tags() []string      { return m.tags }
+
+func (m scriptMigration) Phase() Phase {
+	if m.phase == "" {
+		return PreDeploy
+	}
+	return m.phase
+}
+
+func (m scriptMigration) DialectScript(dialect Dialect) (string, string, bool) {
+	variant, ok := m.variants[dialect]
+	return variant.upScript, variant.downScript, ok
+}
+
+// MigrationSet is a group of migrations owned by a library, such as the tables
+// of a shared auth module, registered with GoMigration.RegisterSet. Libraries
+// typically export a function returning their set.
+type MigrationSet struct {
+	// Name namespaces the migrations of the set: they are registered and
+	// recorded as <Name>/<migration name>, so sets cannot collide with each
+	// other or with the service's own migrations. It may only hold letters,
+	// digits and underscores.
 	Name       string
-	UpScript   string
-	DownScript string
-	IsExecuted bool
-	ExecutedAt *time.Time
+	Migrations []Migration
+	// After names the sets whose migrations are applied before those of this
+	// set, e.g. the set creating tables this one references.
+	After []string
+}
+
+// setMigration is a migration of a MigrationSet, named within the namespace
+// of its set. It keeps the optional interfaces of the migration it wraps.
+type setMigration struct {
+	Migration
+	set string
+}
+
+func (m setMigration) Name() string { return m.set + "/" + m.Migration.Name() }
+
+func (m setMigration) Description() string {
+	if described, ok := m.Migration.(DescribedMigration); ok {
+		return described.Description()
+	}
+	return ""
+}
+
+func (m setMigration) Tags() []string { return migrationTags(m.Migration) }
+
+func (m setMigration) MaxDuration() time.Duration {
+	if timeBoxed, ok := m.Migration.(TimeBoxedMigration); ok {
+		return timeBoxed.MaxDuration()
+	}
+	return 0
+}
+
+func (m setMigration) NonTransactional() bool { return isNonTransactional(m.Migration) }
+
+func (m setMigration) Gate() MigrationGate {
+	gated, ok := m.Migration.(GatedMigration)
+	if !ok {
+		return MigrationGate{}
+	}
+	gate := gated.Gate()
+	if gate.After != "" && !strings.Contains(gate.After, "/") {
+		gate.After = m.set + "/" + gate.After
+	}
+	return gate
+}
+
+func (m setMigration) Phase() Phase { return migrationPhase(m.Migration) }
+
+func (m setMigration) ShouldRun(ctx context.Context, d Driver) (bool, error) {
+	return shouldRun(ctx, d, m.Migration)
+}
+
+func (m setMigration) DependsOn() []string {
+	dependencies := migrationDependencies(m.Migration)
+	for i, name := range dependencies {
+		if !strings.Contains(name, "/") {
+			dependencies[i] = m.set + "/" + name
+		}
+	}
+	return dependencies
+}
+
+func (m setMigration) DialectScript(dialect Dialect) (string, string, bool) {
+	if variants, ok := m.Migration.(DialectMigration); ok {
+		return variants.DialectScript(dialect)
+	}
+	return "", "", false
+}
+
+// MigrationInfo describes a registered migration. It is a copy, so changing it
+// has no effect on the registered migrations.
+type MigrationInfo struct {
+	Name string `json:"name"`
+	// Description is empty unless the migration implements DescribedMigration.
+	Description string `json:"description,omitempty"`
+	// Tags are empty unless the migration implements TaggedMigration.
+	Tags []string `json:"tags,omitempty"`
+	// DependsOn is empty unless the migration implements DependentMigration.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Phase is PreDeploy unless the migration implements PhasedMigration.
+	Phase Phase `json:"phase"`
+	// Set is the name of the MigrationSet the migration belongs to, empty for
+	// the service's own migrations.
+	Set string `json:"set,omitempty"`
+	// Source is the Go type implementing the migration.
+	Source string `json:"source"`
+	// Checksum is the checksum of the up script recorded when it is applied.
+	Checksum string `json:"checksum"`
+	// RegisteredFrom is the file and line of the Register call that added the
+	// migration, or the SQL file loaded by LoadFromDir or LoadFromFS.
+	RegisteredFrom string `json:"registered_from"`
+}
+
+// DependentMigration can optionally be implemented by a Migration that must
+// run after other migrations, e.g. because it comes from a feature branch
+// that may be merged before theirs. DependsOn returns their names. Pending
+// migrations are applied after the pending migrations they depend on, and
+// none is applied while one of its dependencies is neither executed nor
+// pending. Within a MigrationSet, names without a "/" refer to migrations of
+// the same set.
+type DependentMigration interface {
+	DependsOn() []string
+}
+
+// GatedMigration can optionally be implemented by a Migration that must only
+// run at a version boundary, such as a data backfill that older instances of
+// the application do not understand. A run refuses to apply it, failing with
+// ErrMigrationGateClosed, until its gate opens.
+type GatedMigration interface {
+	Gate() MigrationGate
+}
+
+// MigrationGate is the condition for applying a GatedMigration. Empty fields
+// are not checked.
+type MigrationGate struct {
+	// After is a migration that must have been applied by an earlier run.
+	// Within a MigrationSet, a name without a "/" refers to a migration of
+	// the same set.
+	After string
+	// MinVersion is the oldest version of the application allowed to apply
+	// the migration, compared with Config.Build.Version as dotted numbers,
+	// such as v1.8.0. Applications of an unknown version are refused.
+	MinVersion string
+}
+
+// Phase is when a migration runs relative to deploying the code that needs
+// it, for expand/contract rollouts applying additive changes before the new
+// code and destructive cleanup after.
+type Phase string
+
+const (
+	// PreDeploy migrations run before the new code is deployed, so they must
+	// keep the schema usable by the old code, e.g. by adding tables or
+	// nullable columns.
+	PreDeploy Phase = "pre-deploy"
+	// PostDeploy migrations run once the new code is deployed everywhere, e.g.
+	// to drop the columns only the old code used.
+	PostDeploy Phase = "post-deploy"
+)
+
+// Direction tells whether a migration is applied or rolled back.
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// PhasedMigration can optionally be implemented by a Migration to choose its
+// Phase. Migrations that do not implement it are PreDeploy.
+type PhasedMigration interface {
+	Phase() Phase
+}
+
+// ConditionalMigration can optionally be implemented by a Migration that only
+// applies to some databases, e.g. behind a feature flag or when an extension
+// is installed. ShouldRun is asked right before the migration would run, so
+// it sees the schema left by the migrations applied before it in the run, and
+// may query the database through d. When it returns false the migration is
+// recorded as skipped instead of being applied, so it is no longer pending;
+// rolling it back only removes the record. An error fails the migration.
+type ConditionalMigration interface {
+	ShouldRun(ctx context.Context, d Driver) (bool, error)
+}
+
+// skippedMigration is a migration recorded as skipped by its ShouldRun. Its
+// scripts are empty, so rolling it back only removes its record.
+type skippedMigration struct {
+	Migration
+}
+
+func (m skippedMigration) UpScript() string   { return "" }
+func (m skippedMigration) DownScript() string { return "" }
+
+// CleanOptions are the options of CleanWithOptions and FreshWithOptions.
+type CleanOptions struct {
+	// KeepHistory leaves the tracking table and its audit log in place, so the
+	// migration history survives wiping the data. The driver must be a
+	// ChunkedCleaner, otherwise ErrKeepHistoryNotSupported is returned.
+	KeepHistory bool
+}
+
+// MigrateOption configures a Migrate call.
+type MigrateOption func(*migrateOptions)
+
+// migrateOptions holds the options of a Migrate call.
+type migrateOptions struct {
+	// phase restricts the run to the migrations of a phase, when not empty.
+	phase Phase
+	// includeTags restricts the run to the migrations with one of them, when
+	// not empty, and excludeTags leaves out the migrations with one of them.
+	includeTags []string
+	excludeTags []string
+}
+
+// WithPhase restricts Migrate to the pending migrations of phase, leaving the
+// others pending.
+func WithPhase(phase Phase) MigrateOption {
+	return func(o *migrateOptions) {
+		o.phase = phase
+	}
+}
+
+// WithTagFilter restricts Migrate to the pending migrations with one of tags,
+// leaving the others pending. Tags prefixed with "!" leave out the migrations
+// with them instead, so WithTagFilter("!heavy") applies every pending
+// migration but those tagged "heavy".
+func WithTagFilter(tags ...string) MigrateOption {
+	return func(o *migrateOptions) {
+		for _, tag := range tags {
+			if excluded, ok := strings.CutPrefix(tag, "!"); ok {
+				o.excludeTags = append(o.excludeTags, excluded)
+			} else if tag != "" {
+				o.includeTags = append(o.includeTags, tag)
+			}
+		}
+	}
+}
+
+// selective reports whether the options leave pending migrations out of the
+// run.
+func (o migrateOptions) selective() bool {
+	return o.phase != "" || len(o.includeTags) > 0 || len(o.excludeTags) > 0
+}
+
+// exclusion returns why the options leave m out of the run, empty if they do
+// not.
+func (o migrateOptions) exclusion(m Migration) string {
+	if o.phase != "" && migrationPhase(m) != o.phase {
+		return "not in phase " + string(o.phase)
+	}
+	tags := migrationTags(m)
+	if len(o.includeTags) > 0 && !slices.ContainsFunc(o.includeTags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+		return "not tagged " + strings.Join(o.includeTags, " or ")
+	}
+	for _, tag := range o.excludeTags {
+		if slices.Contains(tags, tag) {
+			return "tagged " + tag
+		}
+	}
+	return ""
+}
+
+// TimeBoxedMigration can optionally be implemented by a Migration that must not
+// run longer than MaxDuration, overriding Config.MaxMigrationDuration. A
+// migration exceeding it is cancelled on the server, rolled back if it runs in
+// a transaction, reported as failed, and stops the run with
+// ErrMigrationTimedOut.
+type TimeBoxedMigration interface {
+	MaxDuration() time.Duration
+}
+
+// NonTransactionalMigration can optionally be implemented by a Migration whose
+// scripts cannot run inside a transaction, such as CREATE INDEX CONCURRENTLY
+// or VACUUM. When NonTransactional returns true the migration is executed
+// without a transaction even if Config.UseTransactions is enabled.
+type NonTransactionalMigration interface {
+	NonTransactional() bool
+}
+
+type RegisteredMigration struct {
+	Name       string     `json:"name"`
+	UpScript   string     `json:"up_script"`
+	DownScript string     `json:"down_script"`
+	IsExecuted bool       `json:"is_executed"`
+	ExecutedAt *time.Time `json:"executed_at"`
+	// IsSkipped reports whether the migration was recorded as skipped by its
+	// ConditionalMigration.ShouldRun rather than applied.
+	IsSkipped bool `json:"is_skipped,omitempty"`
 }
 
 type RegisteredMigrationList []RegisteredMigration
 
 func (m RegisteredMigrationList) Print() {
+	m.fprint(os.Stdout)
+}
+
+// fprint writes the list in a tabular format to w.
+func (m RegisteredMigrationList) fprint(w io.Writer) {
 	var tableData [][]string
 	tableData = append(tableData, []string{"Migration Name", "Is Executed", "Executed At"})
 
@@ -42,13 +843,234 @@ func (m RegisteredMigrationList) Print() {
 		if migration.ExecutedAt != nil {
 			executedAt = migration.ExecutedAt.Format(time.RFC3339)
 		}
+		isExecuted := fmt.Sprintf("%t", migration.IsExecuted)
+		if migration.IsSkipped {
+			isExecuted += " (skipped)"
+		}
 		row := []string{
 			migration.Name,
-			fmt.Sprintf("%t", migration.IsExecuted),
+			isExecuted,
 			executedAt,
 		}
 		tableData = append(tableData, row)
 	}
 
-	printTable(tableData)
+	printTable(w, tableData)
+}
+
+// MigrationStatus is a compact summary of the migration state of a database.
+type MigrationStatus struct {
+	// Executed is the number of registered migrations that have been applied.
+	Executed int `json:"executed"`
+	// Pending is the number of registered migrations not applied yet.
+	Pending int `json:"pending"`
+	// Skipped is the number of the Executed migrations recorded as skipped
+	// by their ConditionalMigration.ShouldRun.
+	Skipped int `json:"skipped"`
+	// Unknown is the number of applied migrations that are not registered.
+	Unknown int `json:"unknown"`
+	// LastExecuted is the most recently applied migration, nil if none.
+	LastExecuted *ExecutedMigration `json:"last_executed"`
+	// UpToDate reports whether there are no pending migrations.
+	UpToDate bool `json:"up_to_date"`
+}
+
+// Print displays the status summary in a tabular format.
+func (s MigrationStatus) Print() {
+	s.fprint(os.Stdout)
+}
+
+// fprint writes the status summary in a tabular format to w.
+func (s MigrationStatus) fprint(w io.Writer) {
+	lastExecuted, lastExecutedAt := "N/A", "N/A"
+	if s.LastExecuted != nil {
+		lastExecuted = s.LastExecuted.Name
+		lastExecutedAt = s.LastExecuted.ExecutedAt.Format(time.RFC3339)
+	}
+
+	printTable(w, [][]string{
+		{"Status", "Value"},
+		{"Executed", fmt.Sprintf("%d", s.Executed)},
+		{"Pending", fmt.Sprintf("%d", s.Pending)},
+		{"Skipped", fmt.Sprintf("%d", s.Skipped)},
+		{"Unknown", fmt.Sprintf("%d", s.Unknown)},
+		{"Last Executed", lastExecuted},
+		{"Last Executed At", lastExecutedAt},
+		{"Up To Date", fmt.Sprintf("%t", s.UpToDate)},
+	})
+}
+
+// RepairActionType identifies a kind of change made by Repair.
+type RepairActionType string
+
+const (
+	// RepairChecksumUpdated means the recorded checksum was replaced with the
+	// one of the current up script.
+	RepairChecksumUpdated RepairActionType = "checksum updated"
+	// RepairRecordRemoved means the record of a migration that is no longer
+	// registered was deleted.
+	RepairRecordRemoved RepairActionType = "record removed"
+	// RepairExecutedAtFixed means a missing execution time was filled in so the
+	// record sorts in apply order again.
+	RepairExecutedAtFixed RepairActionType = "executed_at fixed"
+)
+
+// RepairAction is a single change made to the tracking table by Repair.
+type RepairAction struct {
+	Migration string           `json:"migration"`
+	Type      RepairActionType `json:"type"`
+	Detail    string           `json:"detail"`
+}
+
+// RepairReport lists the changes made by Repair.
+type RepairReport []RepairAction
+
+// Print displays the repair report in a tabular format.
+func (r RepairReport) Print() {
+	r.fprint(os.Stdout)
+}
+
+// fprint writes the repair report in a tabular format to w.
+func (r RepairReport) fprint(w io.Writer) {
+	if len(r) == 0 {
+		fmt.Fprintln(w, "Nothing to repair.")
+		return
+	}
+
+	var tableData [][]string
+	tableData = append(tableData, []string{"Migration Name", "Action", "Detail"})
+
+	for _, action := range r {
+		tableData = append(tableData, []string{action.Migration, string(action.Type), action.Detail})
+	}
+
+	printTable(w, tableData)
+}
+
+// migrations returns the names of the repaired migrations, each once, in
+// report order.
+func (r RepairReport) migrations() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, action := range r {
+		if !seen[action.Migration] {
+			seen[action.Migration] = true
+			names = append(names, action.Migration)
+		}
+	}
+	return names
+}
+
+// ValidationIssueType names an inconsistency found by Validate.
+type ValidationIssueType string
+
+const (
+	// ValidationNotRegistered means an executed migration is not registered.
+	ValidationNotRegistered ValidationIssueType = "not registered"
+	// ValidationEmptyUpScript means a registered migration has no up script.
+	ValidationEmptyUpScript ValidationIssueType = "empty up script"
+	// ValidationDuplicatePrefix means several migrations share a numeric
+	// prefix, so their relative order is decided by the rest of their name.
+	ValidationDuplicatePrefix ValidationIssueType = "duplicate prefix"
+	// ValidationChecksumMismatch means an executed migration has been edited.
+	ValidationChecksumMismatch ValidationIssueType = "checksum mismatch"
+	// ValidationUnknownDependency means a registered migration depends on a
+	// migration that is neither registered nor executed.
+	ValidationUnknownDependency ValidationIssueType = "unknown dependency"
+	// ValidationDependencyCycle means registered migrations depend on each
+	// other.
+	ValidationDependencyCycle ValidationIssueType = "dependency cycle"
+)
+
+// ValidationIssue is a single inconsistency found by Validate.
+type ValidationIssue struct {
+	Migration string              `json:"migration"`
+	Type      ValidationIssueType `json:"type"`
+	Detail    string              `json:"detail"`
+}
+
+// ValidationReport lists the inconsistencies found by Validate.
+type ValidationReport []ValidationIssue
+
+// Valid reports whether no inconsistency was found.
+func (r ValidationReport) Valid() bool {
+	return len(r) == 0
+}
+
+// Print displays the validation report in a tabular format.
+func (r ValidationReport) Print() {
+	r.fprint(os.Stdout)
+}
+
+// fprint writes the validation report in a tabular format to w.
+func (r ValidationReport) fprint(w io.Writer) {
+	if r.Valid() {
+		fmt.Fprintln(w, "All migrations are valid.")
+		return
+	}
+
+	var tableData [][]string
+	tableData = append(tableData, []string{"Migration Name", "Issue", "Detail"})
+
+	for _, issue := range r {
+		tableData = append(tableData, []string{issue.Migration, string(issue.Type), issue.Detail})
+	}
+
+	printTable(w, tableData)
+}
+
+// RunSummary sums up the migration runs of a CLI command. It is printed when
+// the command exits.
+type RunSummary struct {
+	Command string `json:"command"`
+	// Applied and RolledBack are the migrations applied and rolled back, in
+	// order.
+	Applied    []string `json:"applied"`
+	RolledBack []string `json:"rolled_back"`
+	// Failed is the migration that failed, if any.
+	Failed   string        `json:"failed,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	// Slowest is the migration that took longest to apply or roll back.
+	Slowest         string        `json:"slowest,omitempty"`
+	SlowestDuration time.Duration `json:"slowest_duration_ns,omitempty"`
+	// Warnings is the number of warnings logged while the command ran.
+	Warnings int `json:"warnings"`
+	// SchemaVersion is the most recently applied migration once the command
+	// finished, empty if none.
+	SchemaVersion string `json:"schema_version,omitempty"`
+	// Error describes why the command failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Print displays the run summary in a tabular format.
+func (s RunSummary) Print() {
+	s.fprint(os.Stdout)
+}
+
+// fprint writes the run summary in a tabular format to w.
+func (s RunSummary) fprint(w io.Writer) {
+	slowest, version, result := "N/A", "N/A", "succeeded"
+	if s.Slowest != "" {
+		slowest = fmt.Sprintf("%s (%s)", s.Slowest, s.SlowestDuration.Round(time.Millisecond))
+	}
+	if s.SchemaVersion != "" {
+		version = s.SchemaVersion
+	}
+	if s.Failed != "" {
+		result = "failed at " + s.Failed
+	} else if s.Error != "" {
+		result = "failed: " + s.Error
+	}
+
+	printTable(w, [][]string{
+		{"Summary", "Value"},
+		{"Command", s.Command},
+		{"Applied", fmt.Sprintf("%d", len(s.Applied))},
+		{"Rolled Back", fmt.Sprintf("%d", len(s.RolledBack))},
+		{"Duration", s.Duration.Round(time.Millisecond).String()},
+		{"Slowest", slowest},
+		{"Warnings", fmt.Sprintf("%d", s.Warnings)},
+		{"Schema Version", version},
+		{"Result", result},
+	})
 }
@@ -49,3 +49,42 @@ func TestRegisteredMigrationList_Print(t *testing.T) {
 	assert.Contains(t, output, "add_customer_id")
 	assert.Contains(t, output, "N/A") // Check for non-executed migration's "Executed At" field
 }
+
+func TestRepairReport_Print(t *testing.T) {
+	report := RepairReport{
+		{Migration: "create_orders", Type: RepairChecksumUpdated, Detail: "abc -> def"},
+		{Migration: "drop_legacy", Type: RepairRecordRemoved, Detail: "migration is no longer registered"},
+	}
+
+	output := captureOutput(func() {
+		report.Print()
+	})
+
+	assert.Contains(t, output, "Action")
+	assert.Contains(t, output, "create_orders")
+	assert.Contains(t, output, "checksum updated")
+	assert.Contains(t, output, "record removed")
+
+	output = captureOutput(func() {
+		RepairReport{}.Print()
+	})
+	assert.Contains(t, output, "Nothing to repair.")
+}
+
+func TestMigrationStatus_Print(t *testing.T) {
+	now := time.Now()
+	status := MigrationStatus{
+		Executed:     3,
+		Pending:      1,
+		LastExecuted: &ExecutedMigration{Name: "create_orders", ExecutedAt: now},
+	}
+
+	output := captureOutput(func() {
+		status.Print()
+	})
+
+	assert.Contains(t, output, "Pending")
+	assert.Contains(t, output, "create_orders")
+	assert.Contains(t, output, now.Format(time.RFC3339))
+	assert.Contains(t, output, "false")
+}
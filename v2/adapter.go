@@ -0,0 +1,459 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	v1 "github.com/openframebox/gomigration"
+)
+
+// FromV1 adapts a driver written for version 1 to Driver. The result
+// implements Locking, Cleaner and TrackingTableUpgrader, and New hands the
+// original driver to the engine, so its other capabilities keep working. Its
+// record methods and UpgradeTrackingTable fail with a *CapabilityError unless
+// the driver implements v1.HistoryWriter and v1.TrackingTableUpgrader.
+func FromV1(driver v1.Driver) Driver {
+	return &v1Driver{driver: driver}
+}
+
+// v1Driver is a version 1 driver adapted to Driver.
+type v1Driver struct {
+	driver v1.Driver
+}
+
+func (d *v1Driver) Init(ctx context.Context, opts InitOptions) error {
+	d.driver.SetMigrationTableName(opts.TrackingTable)
+	return d.driver.CreateMigrationsTable(ctx)
+}
+
+func (d *v1Driver) History(ctx context.Context, opts HistoryOptions, fn func(migration ExecutedMigration) error) error {
+	if opts.Limit <= 0 && opts.Offset <= 0 {
+		return d.driver.IterateExecutedMigrations(ctx, opts.Order, fn)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	page, err := d.driver.GetExecutedMigrationsPage(ctx, opts.Order, limit, opts.Offset)
+	if err != nil {
+		return err
+	}
+	for _, migration := range page {
+		if err := fn(migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *v1Driver) InsertRecord(ctx context.Context, record ExecutedMigration) error {
+	writer, ok := d.driver.(v1.HistoryWriter)
+	if !ok {
+		return &CapabilityError{Capability: "HistoryWriter"}
+	}
+	return writer.InsertExecutedMigration(ctx, record)
+}
+
+func (d *v1Driver) UpdateRecord(ctx context.Context, record ExecutedMigration) error {
+	writer, ok := d.driver.(v1.HistoryWriter)
+	if !ok {
+		return &CapabilityError{Capability: "HistoryWriter"}
+	}
+	return writer.UpdateExecutedMigration(ctx, record)
+}
+
+func (d *v1Driver) RemoveRecord(ctx context.Context, name string) error {
+	writer, ok := d.driver.(v1.HistoryWriter)
+	if !ok {
+		return &CapabilityError{Capability: "HistoryWriter"}
+	}
+	return writer.RemoveExecutedMigration(ctx, name)
+}
+
+func (d *v1Driver) Apply(ctx context.Context, opts ApplyOptions) error {
+	run := d.driver.ApplyMigrations
+	if opts.Direction == DirectionDown {
+		run = d.driver.UnapplyMigrations
+	}
+	return applyV1(ctx, run, opts)
+}
+
+// applyV1 runs opts with run, the ApplyMigrations or UnapplyMigrations method
+// of a version 1 driver.
+func applyV1(ctx context.Context, run func(ctx context.Context, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error, opts ApplyOptions) error {
+	var failed string
+	err := run(
+		ctx,
+		opts.Migrations,
+		func(m *Migration) { opts.Hooks.running(*m) },
+		func(m *Migration) { opts.Hooks.success(*m) },
+		func(m *Migration, err error) {
+			failed = (*m).Name()
+			opts.Hooks.failed(*m, err)
+		},
+	)
+	if err != nil && failed != "" {
+		return &MigrationError{Migration: failed, Direction: opts.Direction, Err: err}
+	}
+	return err
+}
+
+func (d *v1Driver) AcquireLock(ctx context.Context) error {
+	return d.driver.AcquireLock(ctx)
+}
+
+func (d *v1Driver) ReleaseLock(ctx context.Context) error {
+	return d.driver.ReleaseLock(ctx)
+}
+
+func (d *v1Driver) CleanDatabase(ctx context.Context) error {
+	return d.driver.CleanDatabase(ctx)
+}
+
+func (d *v1Driver) UpgradeTrackingTable(ctx context.Context) error {
+	upgrader, ok := d.driver.(v1.TrackingTableUpgrader)
+	if !ok {
+		return &CapabilityError{Capability: "TrackingTableUpgrader"}
+	}
+	return upgrader.UpgradeMigrationsTable(ctx)
+}
+
+func (d *v1Driver) Close() error {
+	return d.driver.Close()
+}
+
+// LegacyDriver is the Driver interface of the first release of version 1,
+// before the tracking table methods and locking were added to it.
+type LegacyDriver interface {
+	SetMigrationTableName(name string)
+	CreateMigrationsTable(ctx context.Context) error
+	GetExecutedMigrations(ctx context.Context, reverse bool) ([]ExecutedMigration, error)
+	CleanDatabase(ctx context.Context) error
+	ApplyMigrations(ctx context.Context, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error
+	UnapplyMigrations(ctx context.Context, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error
+	Close() error
+}
+
+// FromLegacy adapts a driver written for the first release of version 1 to
+// Driver. The result implements Cleaner. Such drivers cannot record or remove
+// a migration without running it, so InsertRecord, UpdateRecord and
+// RemoveRecord fail with a *CapabilityError, and they hold no lock, so runs
+// need Config.Locker.
+func FromLegacy(driver LegacyDriver) Driver {
+	return &legacyDriver{driver: driver}
+}
+
+// legacyDriver is a driver of the first release of version 1 adapted to
+// Driver.
+type legacyDriver struct {
+	driver LegacyDriver
+}
+
+func (d *legacyDriver) Init(ctx context.Context, opts InitOptions) error {
+	d.driver.SetMigrationTableName(opts.TrackingTable)
+	return d.driver.CreateMigrationsTable(ctx)
+}
+
+// History reads the whole history and sorts and pages it in memory, as the
+// driver only lists it in application order.
+func (d *legacyDriver) History(ctx context.Context, opts HistoryOptions, fn func(migration ExecutedMigration) error) error {
+	history, err := d.driver.GetExecutedMigrations(ctx, opts.Order == HistoryOrderAppliedDesc)
+	if err != nil {
+		return err
+	}
+	switch opts.Order {
+	case HistoryOrderName:
+		slices.SortStableFunc(history, func(a, b ExecutedMigration) int { return strings.Compare(a.Name, b.Name) })
+	case HistoryOrderNameDesc:
+		slices.SortStableFunc(history, func(a, b ExecutedMigration) int { return strings.Compare(b.Name, a.Name) })
+	}
+
+	history = history[min(max(opts.Offset, 0), len(history)):]
+	if opts.Limit > 0 && opts.Limit < len(history) {
+		history = history[:opts.Limit]
+	}
+	for _, migration := range history {
+		if err := fn(migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *legacyDriver) InsertRecord(ctx context.Context, record ExecutedMigration) error {
+	return &CapabilityError{Capability: "HistoryWriter"}
+}
+
+func (d *legacyDriver) UpdateRecord(ctx context.Context, record ExecutedMigration) error {
+	return &CapabilityError{Capability: "HistoryWriter"}
+}
+
+func (d *legacyDriver) RemoveRecord(ctx context.Context, name string) error {
+	return &CapabilityError{Capability: "HistoryWriter"}
+}
+
+func (d *legacyDriver) Apply(ctx context.Context, opts ApplyOptions) error {
+	run := d.driver.ApplyMigrations
+	if opts.Direction == DirectionDown {
+		run = d.driver.UnapplyMigrations
+	}
+	return applyV1(ctx, run, opts)
+}
+
+func (d *legacyDriver) CleanDatabase(ctx context.Context) error {
+	return d.driver.CleanDatabase(ctx)
+}
+
+func (d *legacyDriver) Close() error {
+	return d.driver.Close()
+}
+
+// toV1 returns the version 1 driver the engine runs driver with: the original
+// one for drivers adapted by FromV1, an adapter otherwise.
+func toV1(driver Driver) v1.Driver {
+	if d, ok := driver.(*v1Driver); ok {
+		return d.driver
+	}
+	return &engineDriver{driver: driver}
+}
+
+// engineDriver runs a Driver on the version 1 engine. Missing capabilities
+// fail with a *CapabilityError, except ManifestStore, whose absence only
+// disables the fast pending check.
+type engineDriver struct {
+	driver Driver
+
+	mu          sync.Mutex
+	table       string
+	initialized bool
+}
+
+// init calls Driver.Init once before the tracking table is first used.
+func (e *engineDriver) init(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.initialized {
+		return nil
+	}
+	if err := e.driver.Init(ctx, InitOptions{TrackingTable: e.table}); err != nil {
+		return err
+	}
+	e.initialized = true
+	return nil
+}
+
+func (e *engineDriver) SetMigrationTableName(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.table = name
+	e.initialized = false
+}
+
+func (e *engineDriver) CreateMigrationsTable(ctx context.Context) error {
+	e.mu.Lock()
+	e.initialized = false
+	e.mu.Unlock()
+
+	return e.init(ctx)
+}
+
+func (e *engineDriver) UpgradeMigrationsTable(ctx context.Context) error {
+	upgrader, ok := e.driver.(TrackingTableUpgrader)
+	if !ok {
+		return &CapabilityError{Capability: "TrackingTableUpgrader"}
+	}
+	if err := e.init(ctx); err != nil {
+		return err
+	}
+	return upgrader.UpgradeTrackingTable(ctx)
+}
+
+func (e *engineDriver) GetExecutedMigrations(ctx context.Context, order HistoryOrder) ([]ExecutedMigration, error) {
+	return e.GetExecutedMigrationsPage(ctx, order, 0, 0)
+}
+
+func (e *engineDriver) IterateExecutedMigrations(ctx context.Context, order HistoryOrder, fn func(migration ExecutedMigration) error) error {
+	if err := e.init(ctx); err != nil {
+		return err
+	}
+	return e.driver.History(ctx, HistoryOptions{Order: order}, fn)
+}
+
+func (e *engineDriver) GetExecutedMigrationsPage(ctx context.Context, order HistoryOrder, limit, offset int) ([]ExecutedMigration, error) {
+	if err := e.init(ctx); err != nil {
+		return nil, err
+	}
+
+	migrations := []ExecutedMigration{}
+	err := e.driver.History(ctx, HistoryOptions{Order: order, Limit: limit, Offset: offset}, func(m ExecutedMigration) error {
+		migrations = append(migrations, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+func (e *engineDriver) InsertExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	if err := e.init(ctx); err != nil {
+		return err
+	}
+	return e.driver.InsertRecord(ctx, record)
+}
+
+func (e *engineDriver) UpdateExecutedMigration(ctx context.Context, record ExecutedMigration) error {
+	if err := e.init(ctx); err != nil {
+		return err
+	}
+	return e.driver.UpdateRecord(ctx, record)
+}
+
+func (e *engineDriver) RemoveExecutedMigration(ctx context.Context, name string) error {
+	if err := e.init(ctx); err != nil {
+		return err
+	}
+	return e.driver.RemoveRecord(ctx, name)
+}
+
+func (e *engineDriver) CleanDatabase(ctx context.Context) error {
+	cleaner, ok := e.driver.(Cleaner)
+	if !ok {
+		return &CapabilityError{Capability: "Cleaner"}
+	}
+	return cleaner.CleanDatabase(ctx)
+}
+
+func (e *engineDriver) ApplyMigrations(ctx context.Context, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error {
+	return e.apply(ctx, DirectionUp, migrations, onRunning, onSuccess, onFailed)
+}
+
+func (e *engineDriver) UnapplyMigrations(ctx context.Context, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error {
+	return e.apply(ctx, DirectionDown, migrations, onRunning, onSuccess, onFailed)
+}
+
+func (e *engineDriver) apply(ctx context.Context, direction Direction, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error {
+	if err := e.init(ctx); err != nil {
+		return err
+	}
+	return e.driver.Apply(ctx, ApplyOptions{
+		Direction:  direction,
+		Migrations: migrations,
+		Hooks: ApplyHooks{
+			OnRunning: func(m Migration) { onRunning(&m) },
+			OnSuccess: func(m Migration) { onSuccess(&m) },
+			OnFailed:  func(m Migration, err error) { onFailed(&m, err) },
+		},
+	})
+}
+
+func (e *engineDriver) AcquireLock(ctx context.Context) error {
+	locking, ok := e.driver.(Locking)
+	if !ok {
+		return &CapabilityError{Capability: "Locking"}
+	}
+	return locking.AcquireLock(ctx)
+}
+
+func (e *engineDriver) ReleaseLock(ctx context.Context) error {
+	locking, ok := e.driver.(Locking)
+	if !ok {
+		return &CapabilityError{Capability: "Locking"}
+	}
+	return locking.ReleaseLock(ctx)
+}
+
+// GetManifestHash returns the stored manifest hash, or none when the driver
+// is not a ManifestStore.
+func (e *engineDriver) GetManifestHash(ctx context.Context) (string, error) {
+	if store, ok := e.driver.(ManifestStore); ok {
+		return store.GetManifestHash(ctx)
+	}
+	return "", nil
+}
+
+// SetManifestHash stores hash when the driver is a ManifestStore.
+func (e *engineDriver) SetManifestHash(ctx context.Context, hash string) error {
+	if store, ok := e.driver.(ManifestStore); ok {
+		return store.SetManifestHash(ctx, hash)
+	}
+	return nil
+}
+
+// Ping pings the database when the driver is a Pinger, and reads the first
+// executed migration otherwise, like the engine does for version 1 drivers.
+func (e *engineDriver) Ping(ctx context.Context) error {
+	if pinger, ok := e.driver.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	_, err := e.GetExecutedMigrationsPage(ctx, HistoryOrderApplied, 1, 0)
+	return err
+}
+
+func (e *engineDriver) AppendAuditEvent(ctx context.Context, event v1.AuditEvent) error {
+	logger, ok := e.driver.(AuditLogger)
+	if !ok {
+		return fmt.Errorf("%w: %w", v1.ErrAuditLogNotSupported, &CapabilityError{Capability: "AuditLogger"})
+	}
+	return logger.AppendAuditEvent(ctx, event)
+}
+
+func (e *engineDriver) InspectSchema(ctx context.Context) (v1.Schema, error) {
+	inspector, ok := e.driver.(SchemaInspector)
+	if !ok {
+		return nil, fmt.Errorf("%w: %w", v1.ErrSchemaDiffNotSupported, &CapabilityError{Capability: "SchemaInspector"})
+	}
+	return inspector.InspectSchema(ctx)
+}
+
+func (e *engineDriver) InspectER(ctx context.Context) (ERModel, error) {
+	inspector, ok := e.driver.(ERInspector)
+	if !ok {
+		return ERModel{}, fmt.Errorf("%w: %w", v1.ErrDiagramNotSupported, &CapabilityError{Capability: "ERInspector"})
+	}
+	return inspector.InspectER(ctx)
+}
+
+func (e *engineDriver) InspectPlatform(ctx context.Context) (PlatformInfo, error) {
+	inspector, ok := e.driver.(PlatformInspector)
+	if !ok {
+		return PlatformInfo{}, fmt.Errorf("%w: %w", v1.ErrPlatformNotSupported, &CapabilityError{Capability: "PlatformInspector"})
+	}
+	return inspector.InspectPlatform(ctx)
+}
+
+func (e *engineDriver) RunSeeder(ctx context.Context, seeder Seeder) error {
+	runner, ok := e.driver.(SeedRunner)
+	if !ok {
+		return fmt.Errorf("%w: %w", v1.ErrSeedNotSupported, &CapabilityError{Capability: "SeedRunner"})
+	}
+	return runner.RunSeeder(ctx, seeder)
+}
+
+func (e *engineDriver) LoadFixtures(ctx context.Context, fixtures []Fixture, truncate bool) error {
+	loader, ok := e.driver.(FixtureLoader)
+	if !ok {
+		return fmt.Errorf("%w: %w", v1.ErrFixturesNotSupported, &CapabilityError{Capability: "FixtureLoader"})
+	}
+	return loader.LoadFixtures(ctx, fixtures, truncate)
+}
+
+func (e *engineDriver) Close() error {
+	return e.driver.Close()
+}
+
+// supports reports whether driver has the capability checked by has, looking
+// through the FromV1 adapter.
+func supports(driver Driver, has func(any) bool) bool {
+	if d, ok := driver.(*v1Driver); ok {
+		return has(d.driver)
+	}
+	return has(driver)
+}
@@ -0,0 +1,266 @@
+package gomigration
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryDriver is a Driver keeping the tracking table in memory. It has the
+// Locking capability only.
+type memoryDriver struct {
+	table    string
+	executed []ExecutedMigration
+	locked   bool
+	fail     string
+}
+
+func (d *memoryDriver) Init(ctx context.Context, opts InitOptions) error {
+	d.table = opts.TrackingTable
+	return nil
+}
+
+func (d *memoryDriver) History(ctx context.Context, opts HistoryOptions, fn func(migration ExecutedMigration) error) error {
+	history := append([]ExecutedMigration{}, d.executed...)
+	if opts.Order == HistoryOrderAppliedDesc || opts.Order == HistoryOrderNameDesc {
+		slices.Reverse(history)
+	}
+	for _, m := range history {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *memoryDriver) InsertRecord(ctx context.Context, record ExecutedMigration) error {
+	d.executed = append(d.executed, record)
+	return nil
+}
+
+func (d *memoryDriver) UpdateRecord(ctx context.Context, record ExecutedMigration) error {
+	return nil
+}
+
+func (d *memoryDriver) RemoveRecord(ctx context.Context, name string) error {
+	for i, m := range d.executed {
+		if m.Name == name {
+			d.executed = append(d.executed[:i], d.executed[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (d *memoryDriver) Apply(ctx context.Context, opts ApplyOptions) error {
+	for _, m := range opts.Migrations {
+		opts.Hooks.running(m)
+		if m.Name() == d.fail {
+			err := errors.New("boom")
+			opts.Hooks.failed(m, err)
+			return &MigrationError{Migration: m.Name(), Direction: opts.Direction, Err: err}
+		}
+		if opts.Direction == DirectionUp {
+			_ = d.InsertRecord(ctx, ExecutedMigration{Name: m.Name()})
+		} else {
+			_ = d.RemoveRecord(ctx, m.Name())
+		}
+		opts.Hooks.success(m)
+	}
+	return nil
+}
+
+func (d *memoryDriver) AcquireLock(ctx context.Context) error {
+	d.locked = true
+	return nil
+}
+
+func (d *memoryDriver) ReleaseLock(ctx context.Context) error {
+	d.locked = false
+	return nil
+}
+
+func (d *memoryDriver) Close() error {
+	return nil
+}
+
+// testMigration is a Migration with fixed scripts.
+type testMigration struct {
+	name, up, down string
+}
+
+func (m testMigration) Name() string       { return m.name }
+func (m testMigration) UpScript() string   { return m.up }
+func (m testMigration) DownScript() string { return m.down }
+
+func TestNew_Driver(t *testing.T) {
+	ctx := context.Background()
+	driver := &memoryDriver{}
+	q, err := New(driver, &Config{MigrationTableName: "schema_history"})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(testMigration{name: "001_a"}, testMigration{name: "002_b"}))
+
+	assert.NoError(t, q.Migrate(ctx))
+	assert.Equal(t, "schema_history", driver.table)
+	assert.Len(t, driver.executed, 2)
+	assert.False(t, driver.locked)
+
+	assert.NoError(t, q.Rollback(ctx, 1))
+	assert.Len(t, driver.executed, 1)
+	assert.Equal(t, "001_a", driver.executed[0].Name)
+
+	err = q.Clean(ctx)
+	assert.ErrorIs(t, err, ErrNotSupported)
+	var capability *CapabilityError
+	assert.ErrorAs(t, err, &capability)
+	assert.Equal(t, "Cleaner", capability.Capability)
+}
+
+func TestNew_DriverMigrationError(t *testing.T) {
+	driver := &memoryDriver{fail: "002_b"}
+	q, err := New(driver, &Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(testMigration{name: "001_a"}, testMigration{name: "002_b"}))
+
+	err = q.Migrate(context.Background())
+	var migrationErr *MigrationError
+	if assert.ErrorAs(t, err, &migrationErr) {
+		assert.Equal(t, "002_b", migrationErr.Migration)
+		assert.Equal(t, DirectionUp, migrationErr.Direction)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(nil, &Config{})
+	assert.Equal(t, ErrDriverNotProvided, err)
+
+	_, err = New(&memoryDriver{}, nil)
+	assert.Equal(t, ErrConfigNotProvided, err)
+
+	_, err = New(&memoryDriver{}, &Config{AuditLog: true})
+	assert.Equal(t, ErrAuditLogNotSupported, err)
+}
+
+func TestFromV1(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewSqliteDriver(SqliteOptions{Database: filepath.Join(t.TempDir(), "v2.db")})
+	assert.NoError(t, err)
+	defer driver.Close()
+
+	// The engine gets the built-in driver itself, with all its capabilities.
+	assert.Same(t, driver.(*v1Driver).driver, toV1(driver))
+
+	q, err := New(driver, &Config{AuditLog: true})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(
+		testMigration{name: "001_create_users", up: "CREATE TABLE users (id INTEGER);", down: "DROP TABLE users;"},
+		testMigration{name: "002_broken", up: "CREATE TABLE;", down: ""},
+	))
+	assert.Error(t, q.Migrate(ctx))
+
+	// Driver methods can be called directly too.
+	var names []string
+	err = driver.History(ctx, HistoryOptions{Order: HistoryOrderApplied}, func(m ExecutedMigration) error {
+		names = append(names, m.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"001_create_users"}, names)
+
+	err = driver.Apply(ctx, ApplyOptions{Migrations: []Migration{testMigration{name: "002_broken", up: "CREATE TABLE;"}}})
+	var migrationErr *MigrationError
+	if assert.ErrorAs(t, err, &migrationErr) {
+		assert.Equal(t, "002_broken", migrationErr.Migration)
+	}
+}
+
+// legacyMemoryDriver is a driver written for the first release of version 1,
+// keeping the tracking table in memory.
+type legacyMemoryDriver struct {
+	table    string
+	executed []ExecutedMigration
+	cleaned  bool
+}
+
+func (d *legacyMemoryDriver) SetMigrationTableName(name string) { d.table = name }
+
+func (d *legacyMemoryDriver) CreateMigrationsTable(ctx context.Context) error { return nil }
+
+func (d *legacyMemoryDriver) GetExecutedMigrations(ctx context.Context, reverse bool) ([]ExecutedMigration, error) {
+	executed := append([]ExecutedMigration{}, d.executed...)
+	if reverse {
+		slices.Reverse(executed)
+	}
+	return executed, nil
+}
+
+func (d *legacyMemoryDriver) CleanDatabase(ctx context.Context) error {
+	d.cleaned = true
+	return nil
+}
+
+func (d *legacyMemoryDriver) ApplyMigrations(ctx context.Context, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error {
+	for _, m := range migrations {
+		onRunning(&m)
+		d.executed = append(d.executed, ExecutedMigration{Name: m.Name()})
+		onSuccess(&m)
+	}
+	return nil
+}
+
+func (d *legacyMemoryDriver) UnapplyMigrations(ctx context.Context, migrations []Migration, onRunning, onSuccess func(migration *Migration), onFailed func(migration *Migration, err error)) error {
+	for _, m := range migrations {
+		onRunning(&m)
+		d.executed = slices.DeleteFunc(d.executed, func(e ExecutedMigration) bool { return e.Name == m.Name() })
+		onSuccess(&m)
+	}
+	return nil
+}
+
+func (d *legacyMemoryDriver) Close() error { return nil }
+
+// memoryLocker is a Locker that never blocks.
+type memoryLocker struct{}
+
+func (memoryLocker) Lock(ctx context.Context, key string) error   { return nil }
+func (memoryLocker) Unlock(ctx context.Context, key string) error { return nil }
+
+func TestFromLegacy(t *testing.T) {
+	ctx := context.Background()
+	legacy := &legacyMemoryDriver{}
+	driver := FromLegacy(legacy)
+
+	q, err := New(driver, &Config{MigrationTableName: "schema_history", Locker: memoryLocker{}})
+	assert.NoError(t, err)
+	assert.NoError(t, q.Register(testMigration{name: "001_a"}, testMigration{name: "002_b"}, testMigration{name: "003_c"}))
+
+	assert.NoError(t, q.Migrate(ctx))
+	assert.Equal(t, "schema_history", legacy.table)
+	assert.Len(t, legacy.executed, 3)
+
+	assert.NoError(t, q.Rollback(ctx, 1))
+	assert.Equal(t, []ExecutedMigration{{Name: "001_a"}, {Name: "002_b"}}, legacy.executed)
+
+	// History is sorted and paged by the adapter.
+	var names []string
+	err = driver.History(ctx, HistoryOptions{Order: HistoryOrderNameDesc, Offset: 1}, func(m ExecutedMigration) error {
+		names = append(names, m.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"001_a"}, names)
+
+	assert.ErrorIs(t, q.MarkApplied(ctx, "003_c"), ErrNotSupported)
+
+	assert.NoError(t, q.Clean(ctx))
+	assert.True(t, legacy.cleaned)
+
+	// Without a Locker, runs need the Locking capability legacy drivers lack.
+	q, err = New(FromLegacy(&legacyMemoryDriver{}), &Config{})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, q.Migrate(ctx), ErrNotSupported)
+}
@@ -0,0 +1,33 @@
+// Package gomigration is version 2 of GoMigration. It runs the same engine as
+// version 1 behind a smaller Driver interface, so tools can be built against
+// an API that does not change shape with each feature.
+//
+// # Drivers
+//
+// A Driver only keeps the tracking table and runs migration scripts. Every
+// other feature a driver may offer, such as locking or cleaning the database,
+// is a capability: an optional interface checked at run time. New features
+// arrive as new capabilities or new fields of the options structs passed to
+// Driver methods, never as new Driver methods.
+//
+// Drivers written for version 1 keep working: FromV1 adapts those
+// implementing the current version 1 Driver interface, FromLegacy those
+// written against its first release, and New accepts the result. The
+// built-in drivers are available through NewPostgresDriver, NewMySqlDriver
+// and NewSqliteDriver.
+//
+// # Compatibility
+//
+// Within version 2:
+//
+//   - The Driver interface and the capability interfaces do not change.
+//   - Fields are only added to options structs, and their zero value keeps
+//     the previous behavior.
+//   - Errors keep their identity: sentinel errors stay comparable with
+//     errors.Is and typed errors with errors.As.
+//   - Exported identifiers are not removed or renamed. Deprecated ones are
+//     marked as such and kept until the next major version.
+//
+// Types aliased from version 1, such as Config and Migration, follow the
+// same rules.
+package gomigration
@@ -0,0 +1,185 @@
+package gomigration
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/openframebox/gomigration"
+)
+
+// Driver is implemented by migration drivers. It covers what every database
+// can do: keep the tracking table and run migration scripts. Everything else
+// is a capability, an optional interface such as Locking or Cleaner.
+type Driver interface {
+	// Init prepares the tracking table described by opts: it creates the table
+	// if it does not exist and adds the columns introduced since. It is called
+	// before the tracking table is used, and may be called again.
+	Init(ctx context.Context, opts InitOptions) error
+
+	// History streams the executed migrations selected by opts, calling fn for
+	// each one. Iteration stops at the first error returned by fn.
+	History(ctx context.Context, opts HistoryOptions, fn func(migration ExecutedMigration) error) error
+
+	// InsertRecord records a migration as executed without running it.
+	InsertRecord(ctx context.Context, record ExecutedMigration) error
+
+	// UpdateRecord overwrites the execution time and checksum of the record
+	// with the same name.
+	UpdateRecord(ctx context.Context, record ExecutedMigration) error
+
+	// RemoveRecord deletes the record of the named migration without running
+	// its down script.
+	RemoveRecord(ctx context.Context, name string) error
+
+	// Apply runs the up or down scripts of opts.Migrations in order and
+	// records or removes them. A failing migration is reported as a
+	// *MigrationError.
+	Apply(ctx context.Context, opts ApplyOptions) error
+
+	// Close releases the resources of the driver.
+	Close() error
+}
+
+// InitOptions are the options of Driver.Init.
+type InitOptions struct {
+	// TrackingTable is the name of the table recording executed migrations.
+	TrackingTable string
+}
+
+// HistoryOptions are the options of Driver.History.
+type HistoryOptions struct {
+	Order HistoryOrder
+	// Limit is the maximum number of migrations returned, all of them when 0.
+	Limit int
+	// Offset is the number of migrations skipped.
+	Offset int
+}
+
+// Direction tells Driver.Apply whether to run up or down scripts.
+type Direction int
+
+const (
+	// DirectionUp applies migrations with their up scripts.
+	DirectionUp Direction = iota
+	// DirectionDown rolls migrations back with their down scripts.
+	DirectionDown
+)
+
+func (d Direction) String() string {
+	if d == DirectionDown {
+		return "down"
+	}
+	return "up"
+}
+
+// ApplyOptions are the options of Driver.Apply.
+type ApplyOptions struct {
+	Direction  Direction
+	Migrations []Migration
+	Hooks      ApplyHooks
+}
+
+// ApplyHooks are called by Driver.Apply around each migration. Nil hooks are
+// skipped.
+type ApplyHooks struct {
+	OnRunning func(migration Migration)
+	OnSuccess func(migration Migration)
+	OnFailed  func(migration Migration, err error)
+}
+
+func (h ApplyHooks) running(m Migration) {
+	if h.OnRunning != nil {
+		h.OnRunning(m)
+	}
+}
+
+func (h ApplyHooks) success(m Migration) {
+	if h.OnSuccess != nil {
+		h.OnSuccess(m)
+	}
+}
+
+func (h ApplyHooks) failed(m Migration, err error) {
+	if h.OnFailed != nil {
+		h.OnFailed(m, err)
+	}
+}
+
+// Locking is the capability of drivers holding a database-wide lock while
+// migrations run, so concurrent processes cannot race. Without it, runs fail
+// with a *CapabilityError unless Config.Locker is set.
+type Locking interface {
+	// AcquireLock obtains the lock, blocking until it is available or ctx is
+	// done.
+	AcquireLock(ctx context.Context) error
+
+	// ReleaseLock releases the lock obtained by AcquireLock.
+	ReleaseLock(ctx context.Context) error
+}
+
+// Cleaner is the capability of drivers that can drop all user tables, as
+// needed by Clean and Fresh.
+type Cleaner interface {
+	CleanDatabase(ctx context.Context) error
+}
+
+// TrackingTableUpgrader is the capability of drivers that can add the indexes
+// and constraints of new tracking tables to one created by an older version.
+type TrackingTableUpgrader interface {
+	UpgradeTrackingTable(ctx context.Context) error
+}
+
+// ManifestStore, Pinger, AuditLogger, SchemaInspector, ERInspector,
+// PlatformInspector, SeedRunner and FixtureLoader are the capabilities of the
+// same name of version 1.
+type (
+	ManifestStore     = v1.ManifestStore
+	Pinger            = v1.Pinger
+	AuditLogger       = v1.AuditLogger
+	SchemaInspector   = v1.SchemaInspector
+	ERInspector       = v1.ERInspector
+	PlatformInspector = v1.PlatformInspector
+	SeedRunner        = v1.SeedRunner
+	FixtureLoader     = v1.FixtureLoader
+)
+
+// PlatformInfo describes the database service a PlatformInspector is
+// connected to.
+type (
+	Platform     = v1.Platform
+	PlatformInfo = v1.PlatformInfo
+)
+
+// ReadOnlyError is returned when a run would change a read-only database.
+type ReadOnlyError = v1.ReadOnlyError
+
+// ERModel and the types it is made of describe a database for ERInspector.
+type (
+	ERModel      = v1.ERModel
+	ERTable      = v1.ERTable
+	ERColumn     = v1.ERColumn
+	ERForeignKey = v1.ERForeignKey
+)
+
+// ErrNotSupported is matched by every *CapabilityError.
+var ErrNotSupported = v1.ErrNotSupported
+
+// CapabilityError is returned when an operation needs a capability the driver
+// does not implement. It is the error version 1 returns for missing optional
+// interfaces too.
+type CapabilityError = v1.CapabilityError
+
+// MigrationError is returned by Driver.Apply when a migration fails.
+type MigrationError struct {
+	Migration string
+	Direction Direction
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %s (%s) failed: %s", e.Migration, e.Direction, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
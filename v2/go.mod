@@ -0,0 +1,30 @@
+module github.com/openframebox/gomigration/v2
+
+go 1.24.0
+
+require (
+	github.com/openframebox/gomigration v0.0.0-20261015073032-9eb41779008b
+	github.com/stretchr/testify v1.10.0
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-sql-driver/mysql v1.9.2 // indirect
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/ncruces/go-sqlite3 v0.29.1 // indirect
+	github.com/ncruces/julianday v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/spf13/cobra v1.9.1 // indirect
+	github.com/spf13/pflag v1.0.6 // indirect
+	github.com/tetratelabs/wazero v1.9.0 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+	golang.org/x/text v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The replace directive only applies when building v2 inside this
+// repository; modules depending on v2 get the root module at the version
+// required above. Bump it when v2 starts using a newer root module.
+replace github.com/openframebox/gomigration => ../
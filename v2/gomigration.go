@@ -0,0 +1,258 @@
+package gomigration
+
+import (
+	"io"
+	"io/fs"
+
+	v1 "github.com/openframebox/gomigration"
+)
+
+// The engine, its configuration and the migration types are those of
+// version 1.
+type (
+	GoMigration          = v1.GoMigration
+	Config               = v1.Config
+	Migration            = v1.Migration
+	MigrationSet         = v1.MigrationSet
+	DialectMigration     = v1.DialectMigration
+	DependentMigration   = v1.DependentMigration
+	GatedMigration       = v1.GatedMigration
+	MigrationGate        = v1.MigrationGate
+	PhasedMigration      = v1.PhasedMigration
+	ConditionalMigration = v1.ConditionalMigration
+	CleanOptions         = v1.CleanOptions
+	MultiMigrator        = v1.MultiMigrator
+	MultiConfig          = v1.MultiConfig
+	Target               = v1.Target
+	MultiError           = v1.MultiError
+	TargetFailure        = v1.TargetFailure
+	TenantProvider       = v1.TenantProvider
+	TenantProviderFunc   = v1.TenantProviderFunc
+	CreateTemplates      = v1.CreateTemplates
+	CreateTemplateData   = v1.CreateTemplateData
+	NamingStrategy       = v1.NamingStrategy
+	RetryPolicy          = v1.RetryPolicy
+	ConnectRetryPolicy   = v1.ConnectRetryPolicy
+	Phase                = v1.Phase
+	MigrateOption        = v1.MigrateOption
+	Dialect              = v1.Dialect
+	ExecutedMigration    = v1.ExecutedMigration
+	BuildInfo            = v1.BuildInfo
+	HistoryOrder         = v1.HistoryOrder
+	DiagramOptions       = v1.DiagramOptions
+	DiagramFormat        = v1.DiagramFormat
+	Seeder               = v1.Seeder
+	SeedOptions          = v1.SeedOptions
+	Fixture              = v1.Fixture
+	FixtureOptions       = v1.FixtureOptions
+	BatchError           = v1.BatchError
+	BatchFailure         = v1.BatchFailure
+	BatchStage           = v1.BatchStage
+	StatementError       = v1.StatementError
+	InterruptedError     = v1.InterruptedError
+	ProgressReporter     = v1.ProgressReporter
+	BatchProgress        = v1.BatchProgress
+	MigrationProgress    = v1.MigrationProgress
+	ProgressPrinter      = v1.ProgressPrinter
+	MetricsCollector     = v1.MetricsCollector
+	PrometheusMetrics    = v1.PrometheusMetrics
+	Cli                  = v1.Cli
+	CliConfig            = v1.CliConfig
+)
+
+const (
+	HistoryOrderApplied     = v1.HistoryOrderApplied
+	HistoryOrderAppliedDesc = v1.HistoryOrderAppliedDesc
+	HistoryOrderName        = v1.HistoryOrderName
+	HistoryOrderNameDesc    = v1.HistoryOrderNameDesc
+
+	DialectPostgres = v1.DialectPostgres
+	DialectMySQL    = v1.DialectMySQL
+	DialectSQLite   = v1.DialectSQLite
+
+	DiagramMermaid  = v1.DiagramMermaid
+	DiagramPlantUML = v1.DiagramPlantUML
+	DiagramDOT      = v1.DiagramDOT
+
+	StageCondition = v1.StageCondition
+	StageConnect   = v1.StageConnect
+	StageExecute   = v1.StageExecute
+	StageRecord    = v1.StageRecord
+
+	PreDeploy  = v1.PreDeploy
+	PostDeploy = v1.PostDeploy
+
+	NamingTimestamp  = v1.NamingTimestamp
+	NamingSequential = v1.NamingSequential
+)
+
+var (
+	ErrConfigNotProvided        = v1.ErrConfigNotProvided
+	ErrDriverNotProvided        = v1.ErrDriverNotProvided
+	ErrAuditLogNotSupported     = v1.ErrAuditLogNotSupported
+	ErrSchemaDiffNotSupported   = v1.ErrSchemaDiffNotSupported
+	ErrDiagramNotSupported      = v1.ErrDiagramNotSupported
+	ErrPlatformNotSupported     = v1.ErrPlatformNotSupported
+	ErrReadOnlyTarget           = v1.ErrReadOnlyTarget
+	ErrSeedNotSupported         = v1.ErrSeedNotSupported
+	ErrFixturesNotSupported     = v1.ErrFixturesNotSupported
+	ErrInvalidFixture           = v1.ErrInvalidFixture
+	ErrUnknownPhase             = v1.ErrUnknownPhase
+	ErrKeepHistoryNotSupported  = v1.ErrKeepHistoryNotSupported
+	ErrNoTargets                = v1.ErrNoTargets
+	ErrInvalidTarget            = v1.ErrInvalidTarget
+	ErrTargetNotStarted         = v1.ErrTargetNotStarted
+	ErrTenantsNotSupported      = v1.ErrTenantsNotSupported
+	ErrNoTenantProvider         = v1.ErrNoTenantProvider
+	ErrInvalidTenant            = v1.ErrInvalidTenant
+	ErrServerVersionUnsupported = v1.ErrServerVersionUnsupported
+	ErrMigrationNumberCollision = v1.ErrMigrationNumberCollision
+	ErrInterrupted              = v1.ErrInterrupted
+	ErrSquashNotSupported       = v1.ErrSquashNotSupported
+)
+
+// Register registers migrations with the package, for RegisterGlobal.
+func Register(migrations ...Migration) {
+	v1.Register(migrations...)
+}
+
+// NewMultiMigrator creates a MultiMigrator for the targets of config.
+func NewMultiMigrator(config *MultiConfig) (*MultiMigrator, error) {
+	return v1.NewMultiMigrator(config)
+}
+
+// WithPhase restricts Migrate to the pending migrations of phase.
+func WithPhase(phase Phase) MigrateOption {
+	return v1.WithPhase(phase)
+}
+
+// WithTagFilter restricts Migrate to the pending migrations with one of tags,
+// leaving out those with one of the tags prefixed with "!".
+func WithTagFilter(tags ...string) MigrateOption {
+	return v1.WithTagFilter(tags...)
+}
+
+// WithTags returns m labelled with tags, in addition to its own.
+func WithTags(m Migration, tags ...string) Migration {
+	return v1.WithTags(m, tags...)
+}
+
+// ReadFixtures reads the fixture files in the root directory of fsys, in name
+// order.
+func ReadFixtures(fsys fs.FS, root string) ([]Fixture, error) {
+	return v1.ReadFixtures(fsys, root)
+}
+
+// NewSeeder returns a Seeder running script, only in the given environments
+// if any are given.
+func NewSeeder(name, script string, environments ...string) Seeder {
+	return v1.NewSeeder(name, script, environments...)
+}
+
+// New creates a GoMigration running migrations with driver. config.Driver is
+// ignored and replaced by driver.
+func New(driver Driver, config *Config) (*GoMigration, error) {
+	if config == nil {
+		return nil, ErrConfigNotProvided
+	}
+	if driver == nil {
+		return nil, ErrDriverNotProvided
+	}
+
+	isAuditLogger := func(d any) bool {
+		_, ok := d.(AuditLogger)
+		return ok
+	}
+	if config.AuditLog && !supports(driver, isAuditLogger) {
+		return nil, ErrAuditLogNotSupported
+	}
+
+	config.Driver = toV1(driver)
+	return v1.New(config)
+}
+
+// NewProgressPrinter creates a ProgressPrinter writing to w.
+func NewProgressPrinter(w io.Writer) *ProgressPrinter {
+	return v1.NewProgressPrinter(w)
+}
+
+// NewPrometheusMetrics creates a PrometheusMetrics naming its metrics with
+// the prefix namespace, "gomigration" when empty.
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	return v1.NewPrometheusMetrics(namespace)
+}
+
+// MaskCredentials returns s with the passwords of the DSNs it contains
+// masked.
+func MaskCredentials(s string) string {
+	return v1.MaskCredentials(s)
+}
+
+// NewCli creates the CLI of a GoMigration.
+func NewCli(config CliConfig) (*Cli, error) {
+	return v1.NewCli(config)
+}
+
+// PostgresOptions are the connection options of the built-in Postgres driver.
+type PostgresOptions struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Database string
+	// Schema is the search path, "public" when empty.
+	Schema string
+	// ConnectRetry makes the driver wait for a database that is still
+	// starting.
+	ConnectRetry ConnectRetryPolicy
+}
+
+// NewPostgresDriver connects the built-in Postgres driver.
+func NewPostgresDriver(opts PostgresOptions) (Driver, error) {
+	if opts.Schema == "" {
+		opts.Schema = "public"
+	}
+	driver, err := v1.NewPostgresDriver(opts.Host, opts.Port, opts.User, opts.Password, opts.Database, opts.Schema, opts.ConnectRetry)
+	if err != nil {
+		return nil, err
+	}
+	return FromV1(driver), nil
+}
+
+// MySqlOptions are the connection options of the built-in MySQL driver.
+type MySqlOptions struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Database string
+	// Charset is the connection character set, "utf8mb4" when empty.
+	Charset string
+	// ConnectRetry makes the driver wait for a database that is still
+	// starting.
+	ConnectRetry ConnectRetryPolicy
+}
+
+// NewMySqlDriver connects the built-in MySQL driver.
+func NewMySqlDriver(opts MySqlOptions) (Driver, error) {
+	driver, err := v1.NewMySqlDriver(opts.Host, opts.Port, opts.User, opts.Password, opts.Database, opts.Charset, opts.ConnectRetry)
+	if err != nil {
+		return nil, err
+	}
+	return FromV1(driver), nil
+}
+
+// SqliteOptions are the options of the built-in SQLite driver.
+type SqliteOptions struct {
+	// Database is the path of the database file, or a SQLite URI.
+	Database string
+}
+
+// NewSqliteDriver opens the built-in SQLite driver.
+func NewSqliteDriver(opts SqliteOptions) (Driver, error) {
+	driver, err := v1.NewSqliteDriver(opts.Database)
+	if err != nil {
+		return nil, err
+	}
+	return FromV1(driver), nil
+}